@@ -35,8 +35,10 @@ import (
 
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/node"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/datamover"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/utils"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/vcsim"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
@@ -45,15 +47,10 @@ import (
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/syncer/admissionhandler"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/syncer/cnsoperator/manager"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/syncer/k8scloudoperator"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/syncer/multivcmigration"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/syncer/storagepool"
 )
 
-// OperationModeWebHookServer starts container for webhook server.
-const operationModeWebHookServer = "WEBHOOK_SERVER"
-
-// OperationModeWebHookServer starts container for metadata sync.
-const operationModeMetaDataSync = "METADATA_SYNC"
-
 var (
 	enableLeaderElection    = flag.Bool("leader-election", false, "Enable leader election.")
 	leaderElectionNamespace = flag.String("leader-election-namespace", "", "Namespace where the leader "+
@@ -68,15 +65,19 @@ var (
 		"Duration in seconds, the LeaderElector clients should wait between tries of actions. "+
 			"Defaults to 5 seconds.")
 	printVersion  = flag.Bool("version", false, "Print syncer version and exit")
-	operationMode = flag.String("operation-mode", operationModeMetaDataSync,
-		"specify operation mode METADATA_SYNC or WEBHOOK_SERVER")
+	operationMode = flag.String("operation-mode", string(common.OperationModeMetaDataSync),
+		"specify operation mode, one of: "+common.RegisteredOperationModes())
 
 	supervisorFSSName = flag.String("supervisor-fss-name", "",
 		"Name of the feature state switch configmap in supervisor cluster")
 	supervisorFSSNamespace = flag.String("supervisor-fss-namespace", "",
-		"Namespace of the feature state switch configmap in supervisor cluster")
+		"Namespace of the feature state switch configmap in supervisor cluster. In guest clusters, it can "+
+			"differ from the namespace of the internal feature state switch configmap")
 	internalFSSName      = flag.String("fss-name", "", "Name of the feature state switch configmap")
 	internalFSSNamespace = flag.String("fss-namespace", "", "Namespace of the feature state switch configmap")
+
+	// simulator is the vCenter simulator the driver runs against, if any.
+	simulator *vcsim.Simulator
 )
 
 // main for vsphere syncer.
@@ -91,6 +92,17 @@ func main() {
 	ctx, log := logger.GetNewContextWithLogger()
 	log.Infof("Version : %s", syncer.Version)
 
+	// Run against an in-process vCenter simulator for local development.
+	if vcsim.IsEnabled() {
+		sim, err := vcsim.Start(ctx, vcsim.ParamsFromEnv(ctx))
+		if err != nil {
+			log.Errorf("failed to start vCenter simulator. Error: %v", err)
+			os.Exit(1)
+		}
+		simulator = sim
+		defer simulator.Stop(ctx)
+	}
+
 	// Set CO agnostic init params.
 	clusterFlavor, err := config.GetClusterFlavor(ctx)
 	if err != nil {
@@ -115,21 +127,44 @@ func main() {
 			sig := <-ch
 			if sig == syscall.SIGTERM {
 				log.Info("SIGTERM signal received")
+				syncer.Shutdown(ctx)
 				utils.LogoutAllvCenterSessions(ctx)
-				os.Exit(0)
+				exit(ctx, 0)
 			}
 		}
 	}()
 
-	if *operationMode == operationModeWebHookServer {
-		log.Infof("Starting container with operation mode: %v", operationModeWebHookServer)
+	subsystems, err := common.GetOperationModeSubsystems(*operationMode, "")
+	if err != nil || subsystems.CSIServer {
+		log.Fatalf("unsupported operation mode: %v", *operationMode)
+	}
+	log.Infof("Starting container with operation mode: %v", *operationMode)
+	if subsystems.DataMover {
+		params, err := datamover.ParamsFromEnv()
+		if err != nil {
+			log.Fatalf("invalid data mover parameters. Error: %v", err)
+		}
+		if _, err := datamover.Run(ctx, params); err != nil {
+			log.Fatalf("data mover failed. Error: %v", err)
+		}
+		return
+	}
+	if subsystems.MultiVCMigration {
+		params, err := multivcmigration.ParamsFromEnv()
+		if err != nil {
+			log.Fatalf("invalid multi vCenter migration parameters. Error: %v", err)
+		}
+		if _, err := multivcmigration.Run(ctx, params); err != nil {
+			log.Fatalf("multi vCenter migration failed. Error: %v", err)
+		}
+		return
+	}
+	if subsystems.WebhookServer {
 		if webHookStartError := admissionhandler.StartWebhookServer(ctx); webHookStartError != nil {
 			log.Fatalf("failed to start webhook server. err: %v", webHookStartError)
 		}
-	} else if *operationMode == operationModeMetaDataSync {
-		log.Infof("Starting container with operation mode: %v", operationModeMetaDataSync)
-		var err error
-
+	}
+	if subsystems.MetadataSync || subsystems.Operators {
 		// run will be executed if this instance is elected as the leader
 		// or if leader election is not enabled.
 		var run func(ctx context.Context)
@@ -137,7 +172,7 @@ func main() {
 		// Initialize K8sCloudOperator for every instance of vsphere-syncer in the
 		// Supervisor Cluster, independent of whether leader election is enabled.
 		// K8sCloudOperator should run on every node where csi controller can run.
-		if clusterFlavor == cnstypes.CnsClusterFlavorWorkload {
+		if clusterFlavor == cnstypes.CnsClusterFlavorWorkload && subsystems.Operators {
 			go func() {
 				defer func() {
 					log.Info("Cleaning up vc sessions cloud operator service")
@@ -163,7 +198,7 @@ func main() {
 			for {
 				log.Info("Starting the http server to expose Prometheus metrics..")
 				http.Handle("/metrics", promhttp.Handler())
-				err = http.ListenAndServe(":2113", nil)
+				err := http.ListenAndServe(":2113", nil)
 				if err != nil {
 					log.Warnf("Http server that exposes the Prometheus exited with err: %+v", err)
 				}
@@ -173,7 +208,8 @@ func main() {
 
 		// Initialize syncer components that are dependant on the outcome of
 		// leader election, if enabled.
-		run = initSyncerComponents(ctx, clusterFlavor, &syncer.COInitParams)
+		syncer.SyncerSubsystems = subsystems
+		run = initSyncerComponents(ctx, clusterFlavor, &syncer.COInitParams, subsystems)
 
 		if !*enableLeaderElection {
 			run(ctx)
@@ -183,6 +219,11 @@ func main() {
 				log.Fatalf("Creating Kubernetes client failed. Err: %v", err)
 			}
 			lockName := "vsphere-syncer"
+			if !subsystems.Operators {
+				// The metadata syncer runs in a dedicated deployment, next to the
+				// syncer running the operators in the controller deployment.
+				lockName = "vsphere-syncer-metadata"
+			}
 			le := leaderelection.NewLeaderElection(k8sClient, lockName, run)
 
 			if *leaderElectionNamespace != "" {
@@ -197,8 +238,6 @@ func main() {
 				log.Fatalf("Error initializing leader election: %v", err)
 			}
 		}
-	} else {
-		log.Fatalf("unsupported operation mode: %v", *operationMode)
 	}
 }
 
@@ -208,7 +247,7 @@ func main() {
 // TODO: Change name from initSyncerComponents to init<Name>Components where
 // <Name> will be the name of this container.
 func initSyncerComponents(ctx context.Context, clusterFlavor cnstypes.CnsClusterFlavor,
-	coInitParams *interface{}) func(ctx context.Context) {
+	coInitParams *interface{}, subsystems common.OperationModeSubsystems) func(ctx context.Context) {
 	return func(ctx context.Context) {
 		log := logger.GetLogger(ctx)
 		// Disconnect vCenter sessions on restart
@@ -220,7 +259,7 @@ func initSyncerComponents(ctx context.Context, clusterFlavor cnstypes.CnsCluster
 		}()
 		if err := manager.InitCommonModules(ctx, clusterFlavor, coInitParams); err != nil {
 			log.Errorf("Error initializing common modules for all flavors. Error: %+v", err)
-			os.Exit(1)
+			exit(ctx, 1)
 		}
 		var configInfo *config.ConfigurationInfo
 		var err error
@@ -229,18 +268,18 @@ func initSyncerComponents(ctx context.Context, clusterFlavor cnstypes.CnsCluster
 			configInfo, err = syncer.SyncerInitConfigInfo(ctx)
 			if err != nil {
 				log.Errorf("failed to initialize the configInfo. Err: %+v", err)
-				os.Exit(1)
+				exit(ctx, 1)
 			}
 		} else {
 			configInfo, err = config.InitConfigInfo(ctx)
 			if err != nil {
 				log.Errorf("failed to initialize the configInfo. Err: %+v", err)
-				os.Exit(1)
+				exit(ctx, 1)
 			}
 		}
 
 		// Initialize CNS Operator for Supervisor clusters.
-		if clusterFlavor == cnstypes.CnsClusterFlavorWorkload {
+		if clusterFlavor == cnstypes.CnsClusterFlavorWorkload && subsystems.Operators {
 			go func() {
 				defer func() {
 					log.Info("Cleaning up vc sessions storage pool service")
@@ -251,7 +290,7 @@ func initSyncerComponents(ctx context.Context, clusterFlavor cnstypes.CnsCluster
 				if err := storagepool.InitStoragePoolService(ctx, configInfo, coInitParams); err != nil {
 					log.Errorf("Error initializing StoragePool Service. Error: %+v", err)
 					utils.LogoutAllvCenterSessions(ctx)
-					os.Exit(0)
+					exit(ctx, 0)
 				}
 			}()
 		}
@@ -262,25 +301,25 @@ func initSyncerComponents(ctx context.Context, clusterFlavor cnstypes.CnsCluster
 			err = nodeMgr.Initialize(ctx)
 			if err != nil {
 				log.Errorf("failed to initialize nodeManager. Error: %+v", err)
-				os.Exit(1)
+				exit(ctx, 1)
 			}
 			if configInfo.Cfg.Global.ClusterDistribution == "" {
 				config, err := rest.InClusterConfig()
 				if err != nil {
 					log.Errorf("failed to get InClusterConfig: %v", err)
-					os.Exit(1)
+					exit(ctx, 1)
 				}
 				clientset, err := kubernetes.NewForConfig(config)
 				if err != nil {
 					log.Errorf("failed to create kubernetes client with err: %v", err)
-					os.Exit(1)
+					exit(ctx, 1)
 				}
 
 				// Get the version info for the Kubernetes API server
 				versionInfo, err := clientset.Discovery().ServerVersion()
 				if err != nil {
 					log.Errorf("failed to fetch versionInfo with err: %v", err)
-					os.Exit(1)
+					exit(ctx, 1)
 				}
 
 				// Extract the version string from the version info
@@ -311,23 +350,30 @@ func initSyncerComponents(ctx context.Context, clusterFlavor cnstypes.CnsCluster
 				}
 			}
 		}
-		go func() {
-			defer func() {
-				log.Info("Cleaning up vc sessions cns operator")
-				if r := recover(); r != nil {
-					cleanupSessions(ctx, r)
+		if subsystems.Operators {
+			go func() {
+				defer func() {
+					log.Info("Cleaning up vc sessions cns operator")
+					if r := recover(); r != nil {
+						cleanupSessions(ctx, r)
+					}
+				}()
+				if err := manager.InitCnsOperator(ctx, clusterFlavor, configInfo, coInitParams); err != nil {
+					log.Errorf("Error initializing Cns Operator. Error: %+v", err)
+					utils.LogoutAllvCenterSessions(ctx)
+					exit(ctx, 0)
 				}
 			}()
-			if err := manager.InitCnsOperator(ctx, clusterFlavor, configInfo, coInitParams); err != nil {
-				log.Errorf("Error initializing Cns Operator. Error: %+v", err)
-				utils.LogoutAllvCenterSessions(ctx)
-				os.Exit(0)
-			}
-		}()
+		}
+		if !subsystems.MetadataSync {
+			log.Info("Metadata syncer is not run in this operation mode")
+			<-ctx.Done()
+			return
+		}
 		if err := syncer.InitMetadataSyncer(ctx, clusterFlavor, configInfo); err != nil {
 			log.Errorf("Error initializing Metadata Syncer. Error: %+v", err)
 			utils.LogoutAllvCenterSessions(ctx)
-			os.Exit(0)
+			exit(ctx, 0)
 		}
 	}
 }
@@ -337,5 +383,13 @@ func cleanupSessions(ctx context.Context, r interface{}) {
 	log.Errorf("Observed a panic and a restart was invoked, panic: %+v", r)
 	log.Info("Recovered from panic. Disconnecting the existing vc sessions.")
 	utils.LogoutAllvCenterSessions(ctx)
-	os.Exit(0)
+	exit(ctx, 0)
+}
+
+// exit stops the vCenter simulator, if any, and exits with the given code.
+// os.Exit skips deferred calls, so the simulator would otherwise be left
+// running with its generated config file.
+func exit(ctx context.Context, code int) {
+	simulator.Stop(ctx)
+	os.Exit(code)
 }
@@ -26,6 +26,7 @@ import (
 
 	csiconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/utils"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/vcsim"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
@@ -38,9 +39,13 @@ var (
 	supervisorFSSName = flag.String("supervisor-fss-name", "",
 		"Name of the feature state switch configmap in supervisor cluster")
 	supervisorFSSNamespace = flag.String("supervisor-fss-namespace", "",
-		"Namespace of the feature state switch configmap in supervisor cluster")
+		"Namespace of the feature state switch configmap in supervisor cluster. In guest clusters, it can "+
+			"differ from the namespace of the internal feature state switch configmap")
 	internalFSSName      = flag.String("fss-name", "", "Name of the feature state switch configmap")
 	internalFSSNamespace = flag.String("fss-namespace", "", "Namespace of the feature state switch configmap")
+
+	// simulator is the vCenter simulator the driver runs against, if any.
+	simulator *vcsim.Simulator
 )
 
 // main is ignored when this package is built as a go plug-in.
@@ -55,6 +60,17 @@ func main() {
 	ctx, log := logger.GetNewContextWithLogger()
 	log.Infof("Version : %s", service.Version)
 
+	// Run against an in-process vCenter simulator for local development.
+	if vcsim.IsEnabled() {
+		sim, err := vcsim.Start(ctx, vcsim.ParamsFromEnv(ctx))
+		if err != nil {
+			log.Errorf("failed to start vCenter simulator. Error: %v", err)
+			os.Exit(1)
+		}
+		simulator = sim
+		defer simulator.Stop(ctx)
+	}
+
 	// Set CO Init params.
 	clusterFlavor, err := csiconfig.GetClusterFlavor(ctx)
 	if err != nil {
@@ -68,7 +84,7 @@ func main() {
 	CSIEndpoint := os.Getenv(csitypes.EnvVarEndpoint)
 	if CSIEndpoint == "" {
 		log.Error("CSI endpoint cannot be empty. Please set the env variable.")
-		os.Exit(1)
+		exit(ctx, 1)
 	}
 	log.Info("Enable logging off for vCenter sessions on exit")
 	// Disconnect VC session on restart
@@ -79,6 +95,8 @@ func main() {
 		}
 	}()
 
+	vSphereCSIDriver := service.NewDriver()
+
 	ch := make(chan os.Signal, 1)
 	signal.Notify(ch, syscall.SIGTERM)
 	go func() {
@@ -86,13 +104,13 @@ func main() {
 			sig := <-ch
 			if sig == syscall.SIGTERM {
 				log.Info("SIGTERM signal received")
+				vSphereCSIDriver.Shutdown(ctx)
 				utils.LogoutAllvCenterSessions(ctx)
-				os.Exit(0)
+				exit(ctx, 0)
 			}
 		}
 	}()
 
-	vSphereCSIDriver := service.NewDriver()
 	vSphereCSIDriver.Run(ctx, CSIEndpoint)
 
 }
@@ -102,5 +120,13 @@ func cleanupSessions(ctx context.Context, r interface{}) {
 	log.Errorf("Observed a panic and a restart was invoked, panic: %+v", r)
 	log.Info("Recovered from panic. Disconnecting the existing vc sessions.")
 	utils.LogoutAllvCenterSessions(ctx)
-	os.Exit(0)
+	exit(ctx, 0)
+}
+
+// exit stops the vCenter simulator, if any, and exits with the given code.
+// os.Exit skips deferred calls, so the simulator would otherwise be left
+// running with its generated config file.
+func exit(ctx context.Context, code int) {
+	simulator.Stop(ctx)
+	os.Exit(code)
 }
@@ -91,6 +91,9 @@ cat <<eof >"${tmpdir}"/webhook.config
 port = "8443"
 cert-file = "/run/secrets/tls/tls.crt"
 key-file = "/run/secrets/tls/tls.key"
+[SystemResourceProtection]
+# Users allowed to delete the feature states ConfigMap, in addition to the namespace and garbage collector controllers.
+# allowed-service-account = "system:serviceaccount:vmware-system-csi:vsphere-csi-controller"
 eof
 
 kubectl delete secret ${secret} --namespace "${namespace}" 2>/dev/null || true
@@ -133,6 +133,12 @@ const (
 
 type CnsOperatorEntityReference cnstypes.CnsKubernetesEntityReference
 
+// GuestClusterIDLabel is the label set on the objects created by a guest
+// cluster in its supervisor namespace, with the guest cluster ID as value.
+// It allows each guest cluster to only list its own objects when several
+// guest clusters share the same supervisor namespace.
+const GuestClusterIDLabel = "cns.vmware.com/guest-cluster-id"
+
 // CreateCnsVolumeMetadataSpec returns a cnsvolumemetadata object from the
 // input parameters.
 func CreateCnsVolumeMetadataSpec(volumeHandle []string, gcConfig config.GCConfig, uid string, name string,
@@ -140,7 +146,8 @@ func CreateCnsVolumeMetadataSpec(volumeHandle []string, gcConfig config.GCConfig
 	reference []CnsOperatorEntityReference) *CnsVolumeMetadata {
 	return &CnsVolumeMetadata{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: GetCnsVolumeMetadataName(gcConfig.TanzuKubernetesClusterUID, uid),
+			Name:   GetCnsVolumeMetadataName(gcConfig.TanzuKubernetesClusterUID, uid),
+			Labels: map[string]string{GuestClusterIDLabel: gcConfig.TanzuKubernetesClusterUID},
 			OwnerReferences: []metav1.OwnerReference{GetCnsVolumeMetadataOwnerReference(gcConfig.ClusterAPIVersion,
 				gcConfig.ClusterKind, gcConfig.TanzuKubernetesClusterName, gcConfig.TanzuKubernetesClusterUID)},
 		},
@@ -194,7 +194,8 @@ func GetVolumeMigrationService(ctx context.Context, volumeManager *cnsvolume.Man
 							volumeMigrationObject.Spec.VolumePath, volumeMigrationObject.Spec.VolumeID)
 					},
 				}
-				_, err = informer.Informer().AddEventHandler(handlers)
+				_, err = informer.Informer().AddEventHandler(k8s.NewEventHandler("cnsvspherevolumemigrations",
+					handlers.AddFunc, handlers.UpdateFunc, handlers.DeleteFunc))
 				if err != nil {
 					log.Errorf("failed to add event handler on informer for cnsvspherevolumemigrations CR. "+
 						"Error: %v", err)
@@ -19,8 +19,14 @@ package node
 import (
 	"context"
 	"errors"
+	"sort"
+	"strings"
 	"sync"
 
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
@@ -28,6 +34,8 @@ import (
 	clientset "k8s.io/client-go/kubernetes"
 
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsnodeinfo"
+	cnsnodeinfov1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsnodeinfo/v1alpha1"
 	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
 )
 
@@ -40,6 +48,9 @@ var (
 type Manager interface {
 	// SetKubernetesClient sets kubernetes client for node manager.
 	SetKubernetesClient(client clientset.Interface)
+	// SetNodeInfoService sets the service persisting the vCenter details of
+	// the registered nodes in CnsNodeInfo CRs.
+	SetNodeInfoService(nodeInfoService cnsnodeinfo.NodeInfoService)
 	// RegisterNode registers a node given its UUID, name.
 	RegisterNode(ctx context.Context, nodeUUID string, nodeName string) error
 	// DiscoverNode discovers a registered node given its UUID. This method
@@ -76,6 +87,11 @@ type Manager interface {
 	UnregisterNode(ctx context.Context, nodeName string) error
 	// UnregisterAllNodes unregisters all registered nodes with the node manager.
 	UnregisterAllNodes(ctx context.Context) error
+	// RediscoverStaleNodes discovers again the registered nodes whose VM was
+	// removed from the vCenter inventory or changed its UUID.
+	RediscoverStaleNodes(ctx context.Context)
+	// UpdateNodeInfos refreshes the CnsNodeInfo CRs of all registered nodes.
+	UpdateNodeInfos(ctx context.Context)
 }
 
 // Metadata represents node metadata.
@@ -109,6 +125,9 @@ type defaultManager struct {
 	nodeNameToUUID sync.Map
 	// k8s client.
 	k8sClient clientset.Interface
+	// nodeInfoService persists the vCenter details of the nodes in CnsNodeInfo
+	// CRs. It is nil if the CnsNodeInfo CRs are not maintained.
+	nodeInfoService cnsnodeinfo.NodeInfoService
 }
 
 // SetKubernetesClient sets specified kubernetes client to defaultManager.k8sClient
@@ -116,6 +135,12 @@ func (m *defaultManager) SetKubernetesClient(client clientset.Interface) {
 	m.k8sClient = client
 }
 
+// SetNodeInfoService sets the service persisting the vCenter details of the
+// registered nodes in CnsNodeInfo CRs.
+func (m *defaultManager) SetNodeInfoService(nodeInfoService cnsnodeinfo.NodeInfoService) {
+	m.nodeInfoService = nodeInfoService
+}
+
 // RegisterNode registers a node with node manager using its UUID, name.
 func (m *defaultManager) RegisterNode(ctx context.Context, nodeUUID string, nodeName string) error {
 	log := logger.GetLogger(ctx)
@@ -126,7 +151,15 @@ func (m *defaultManager) RegisterNode(ctx context.Context, nodeUUID string, node
 		return err
 	}
 	log.Infof("Successfully discovered node: %q with nodeUUID %q", nodeName, nodeUUID)
-	m.nodeNameToUUID.Store(nodeName, nodeUUID)
+	oldNodeUUID, found := m.nodeNameToUUID.Swap(nodeName, nodeUUID)
+	if found && oldNodeUUID != nil && oldNodeUUID.(string) != "" && oldNodeUUID.(string) != nodeUUID {
+		// The node VM got a new UUID, drop the VM registered with the old one.
+		log.Infof("Node: %q was registered with nodeUUID %q, removing it from the cache", nodeName, oldNodeUUID)
+		m.nodeVMs.Delete(oldNodeUUID)
+	}
+	if vmInf, discovered := m.nodeVMs.Load(nodeUUID); discovered {
+		m.updateNodeInfo(ctx, nodeName, vmInf.(*vsphere.VirtualMachine))
+	}
 	log.Infof("Successfully registered node: %q with nodeUUID %q", nodeName, nodeUUID)
 	return nil
 }
@@ -136,6 +169,11 @@ func (m *defaultManager) RegisterNode(ctx context.Context, nodeUUID string, node
 // is returned to the caller.
 func (m *defaultManager) DiscoverNode(ctx context.Context, nodeUUID string) error {
 	log := logger.GetLogger(ctx)
+	if vm := m.getNodeVMFromNodeInfo(ctx, nodeUUID); vm != nil {
+		m.nodeVMs.Store(nodeUUID, vm)
+		log.Infof("Successfully discovered node with nodeUUID %s in vm %v from its CnsNodeInfo", nodeUUID, vm)
+		return nil
+	}
 	vm, err := vsphere.GetVirtualMachineByUUID(ctx, nodeUUID, false)
 	if err != nil {
 		log.Errorf("Couldn't find VM instance with nodeUUID %s, failed to discover with err: %v", nodeUUID, err)
@@ -265,6 +303,9 @@ func (m *defaultManager) GetNodeVMByUuid(ctx context.Context,
 	vmInf, discovered := m.nodeVMs.Load(nodeUUID)
 	if !discovered {
 		log.Infof("Node VM not found with nodeUUID %s", nodeUUID)
+		if vm := m.getNodeVMFromNodeInfo(ctx, nodeUUID); vm != nil {
+			return vm, nil
+		}
 		vm, err := vsphere.GetVirtualMachineByUUID(ctx, nodeUUID, false)
 		if err != nil {
 			log.Errorf("Couldn't find VM instance with nodeUUID %s, failed to discover with err: %v", nodeUUID, err)
@@ -422,6 +463,11 @@ func (m *defaultManager) UnregisterNode(ctx context.Context, nodeName string) er
 	}
 	m.nodeNameToUUID.Delete(nodeName)
 	m.nodeVMs.Delete(nodeUUID)
+	if m.nodeInfoService != nil {
+		if err := m.nodeInfoService.DeleteNodeInfo(ctx, nodeName); err != nil {
+			log.Warnf("failed to delete CnsNodeInfo of node: %q. Err: %v", nodeName, err)
+		}
+	}
 	log.Infof("Successfully unregistered node with nodeName %s", nodeName)
 	return nil
 }
@@ -450,3 +496,155 @@ func (m *defaultManager) UnregisterAllNodes(ctx context.Context) error {
 	log.Infof("Successfully unregistered all nodes.")
 	return nil
 }
+
+// RediscoverStaleNodes checks that the VMs of the registered nodes still
+// exist with the UUID they were discovered with. A VM removed from and added
+// back to the vCenter inventory gets a new managed object reference, and may
+// get a new UUID, while the cached VM keeps the old ones and attach operations
+// on the node fail. Such VMs are discovered again using their UUID. If the
+// UUID of the VM changed, the node is registered again with the new UUID once
+// the node plugin restarts and publishes it in the CSINode object.
+func (m *defaultManager) RediscoverStaleNodes(ctx context.Context) {
+	log := logger.GetLogger(ctx)
+	m.nodeNameToUUID.Range(func(nodeName, nodeUUID interface{}) bool {
+		if nodeUUID == nil || nodeUUID.(string) == "" {
+			return true
+		}
+		vmInf, discovered := m.nodeVMs.Load(nodeUUID)
+		if !discovered || vmInf == nil {
+			return true
+		}
+		vm := vmInf.(*vsphere.VirtualMachine)
+		stale, err := isStaleNodeVM(ctx, vm)
+		if err != nil {
+			log.Warnf("failed to check VM %v of node: %q. Err: %v", vm, nodeName, err)
+			return true
+		}
+		if !stale {
+			return true
+		}
+		log.Infof("VM %v of node: %q is stale, discovering the node again using nodeUUID %q", vm, nodeName, nodeUUID)
+		m.nodeVMs.Delete(nodeUUID)
+		if m.nodeInfoService != nil {
+			// The CnsNodeInfo holds the stale VM, it must not be used to
+			// discover the node again.
+			if err := m.nodeInfoService.DeleteNodeInfo(ctx, nodeName.(string)); err != nil {
+				log.Warnf("failed to delete CnsNodeInfo of node: %q. Err: %v", nodeName, err)
+			}
+		}
+		if err := m.DiscoverNode(ctx, nodeUUID.(string)); err != nil {
+			log.Errorf("failed to discover node: %q again with nodeUUID %q, waiting for the node plugin "+
+				"to publish the new UUID of the node. Err: %v", nodeName, nodeUUID, err)
+			return true
+		}
+		if vmInf, discovered := m.nodeVMs.Load(nodeUUID); discovered {
+			m.updateNodeInfo(ctx, nodeName.(string), vmInf.(*vsphere.VirtualMachine))
+		}
+		return true
+	})
+}
+
+// isStaleNodeVM returns true if the VM doesn't exist anymore on its vCenter,
+// or if its UUID changed.
+func isStaleNodeVM(ctx context.Context, vm *vsphere.VirtualMachine) (bool, error) {
+	if err := vm.Renew(ctx, false); err != nil {
+		return false, err
+	}
+	var vmMo mo.VirtualMachine
+	err := vm.Properties(ctx, vm.Reference(), []string{"config.uuid"}, &vmMo)
+	if err != nil {
+		if vsphere.IsManagedObjectNotFound(err, vm.Reference()) {
+			return true, nil
+		}
+		return false, err
+	}
+	return vmMo.Config == nil || !strings.EqualFold(vmMo.Config.Uuid, vm.UUID), nil
+}
+
+// UpdateNodeInfos refreshes the CnsNodeInfo CRs of all registered nodes, as
+// the node VMs may have moved to another host.
+func (m *defaultManager) UpdateNodeInfos(ctx context.Context) {
+	if m.nodeInfoService == nil {
+		return
+	}
+	m.nodeNameToUUID.Range(func(nodeName, nodeUUID interface{}) bool {
+		if nodeUUID == nil || nodeUUID.(string) == "" {
+			return true
+		}
+		if vmInf, discovered := m.nodeVMs.Load(nodeUUID); discovered && vmInf != nil {
+			m.updateNodeInfo(ctx, nodeName.(string), vmInf.(*vsphere.VirtualMachine))
+		}
+		return true
+	})
+}
+
+// updateNodeInfo persists the vCenter details of the node VM in the CnsNodeInfo
+// CR of the node. Failures are logged, the CnsNodeInfo CRs are only used to
+// avoid looking up the node VMs in vCenter.
+func (m *defaultManager) updateNodeInfo(ctx context.Context, nodeName string, vm *vsphere.VirtualMachine) {
+	log := logger.GetLogger(ctx)
+	if m.nodeInfoService == nil {
+		return
+	}
+	spec := cnsnodeinfov1alpha1.CnsNodeInfoSpec{
+		NodeName:       nodeName,
+		NodeUUID:       vm.UUID,
+		VCenterServer:  vm.VirtualCenterHost,
+		Datacenter:     vm.Datacenter.Reference().Value,
+		VirtualMachine: vm.Reference().Value,
+	}
+	host, err := vm.HostSystem(ctx)
+	if err != nil {
+		log.Warnf("failed to get host of VM %v of node: %q. Err: %v", vm, nodeName, err)
+	} else {
+		spec.Host = host.Reference().Value
+	}
+	datastores, err := vm.GetAllAccessibleDatastores(ctx)
+	if err != nil {
+		log.Warnf("failed to get accessible datastores of VM %v of node: %q. Err: %v", vm, nodeName, err)
+	}
+	for _, datastore := range datastores {
+		spec.AccessibleDatastores = append(spec.AccessibleDatastores, datastore.Info.Url)
+	}
+	sort.Strings(spec.AccessibleDatastores)
+	now := metav1.Now()
+	spec.LastUpdateTime = &now
+	if err := m.nodeInfoService.UpdateNodeInfo(ctx, spec); err != nil {
+		log.Warnf("failed to update CnsNodeInfo of node: %q. Err: %v", nodeName, err)
+	}
+}
+
+// getNodeVMFromNodeInfo returns the VM recorded in the CnsNodeInfo CR of the
+// node VM with the given UUID, or nil if there is none.
+func (m *defaultManager) getNodeVMFromNodeInfo(ctx context.Context, nodeUUID string) *vsphere.VirtualMachine {
+	log := logger.GetLogger(ctx)
+	if m.nodeInfoService == nil {
+		return nil
+	}
+	nodeInfo, err := m.nodeInfoService.GetNodeInfoByUUID(ctx, nodeUUID)
+	if err != nil {
+		return nil
+	}
+	vc, err := vsphere.GetVirtualCenterManager(ctx).GetVirtualCenter(ctx, nodeInfo.Spec.VCenterServer)
+	if err != nil {
+		log.Warnf("failed to get vCenter %q of CnsNodeInfo %q. Err: %v", nodeInfo.Spec.VCenterServer,
+			nodeInfo.Name, err)
+		return nil
+	}
+	if err := vc.Connect(ctx); err != nil {
+		log.Warnf("failed to connect to vCenter %q of CnsNodeInfo %q. Err: %v", nodeInfo.Spec.VCenterServer,
+			nodeInfo.Name, err)
+		return nil
+	}
+	dcRef := types.ManagedObjectReference{Type: "Datacenter", Value: nodeInfo.Spec.Datacenter}
+	vmRef := types.ManagedObjectReference{Type: "VirtualMachine", Value: nodeInfo.Spec.VirtualMachine}
+	return &vsphere.VirtualMachine{
+		VirtualCenterHost: nodeInfo.Spec.VCenterServer,
+		UUID:              nodeInfo.Spec.NodeUUID,
+		VirtualMachine:    object.NewVirtualMachine(vc.Client.Client, vmRef),
+		Datacenter: &vsphere.Datacenter{
+			Datacenter:        object.NewDatacenter(vc.Client.Client, dcRef),
+			VirtualCenterHost: nodeInfo.Spec.VCenterServer,
+		},
+	}
+}
@@ -19,6 +19,8 @@ package node
 import (
 	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	storagev1 "k8s.io/api/storage/v1"
 
@@ -27,6 +29,13 @@ import (
 	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
 )
 
+// staleNodeCheckInterval is the interval at which the VMs of the registered
+// nodes are checked for removal from the vCenter inventory.
+const staleNodeCheckInterval = 5 * time.Minute
+
+// staleNodeCheckOnce starts the periodic check of the stale nodes.
+var staleNodeCheckOnce sync.Once
+
 // Nodes comprises cns node manager and kubernetes informer.
 type Nodes struct {
 	cnsNodeManager Manager
@@ -50,9 +59,27 @@ func (nodes *Nodes) Initialize(ctx context.Context) error {
 		return fmt.Errorf("failed to listen on CSINodes. Error: %v", err)
 	}
 	nodes.informMgr.Listen()
+	// The node manager is a singleton, the stale nodes are checked once for
+	// all the re-initializations of Nodes.
+	staleNodeCheckOnce.Do(func() {
+		go rediscoverStaleNodes(nodes.cnsNodeManager)
+	})
 	return nil
 }
 
+// rediscoverStaleNodes periodically discovers again the registered nodes
+// whose VM was removed from and added back to the vCenter inventory, and
+// refreshes the CnsNodeInfo CRs of the nodes.
+func rediscoverStaleNodes(nodeManager Manager) {
+	ticker := time.NewTicker(staleNodeCheckInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		ctx, _ := logger.GetNewContextWithLogger()
+		nodeManager.RediscoverStaleNodes(ctx)
+		nodeManager.UpdateNodeInfos(ctx)
+	}
+}
+
 func (nodes *Nodes) csiNodeAdd(obj interface{}) {
 	ctx, log := logger.GetNewContextWithLogger()
 	csiNode, ok := obj.(*storagev1.CSINode)
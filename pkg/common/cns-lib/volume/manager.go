@@ -31,6 +31,7 @@ import (
 	"github.com/vmware/govmomi/vim25/soap"
 	vim25types "github.com/vmware/govmomi/vim25/types"
 	"github.com/vmware/govmomi/vslm"
+	vslmtypes "github.com/vmware/govmomi/vslm/types"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -51,6 +52,10 @@ const (
 	// and internal map that couldn't be removed due to any vc issues
 	defaultListViewCleanupInvalidTasksInMinutes = 15
 
+	// vslmListObjectsMaxResult is the maximum number of virtual disks returned
+	// by a single Vslm list query.
+	vslmListObjectsMaxResult = 1000
+
 	// timeout duration for a http request
 	// used only for listView
 	noTimeout = 0 * time.Minute
@@ -92,6 +97,12 @@ type Manager interface {
 	// should not be nil.
 	AttachVolume(ctx context.Context, vm *cnsvsphere.VirtualMachine,
 		volumeID string, checkNVMeController bool) (string, string, error)
+	// BatchAttachVolumes attaches multiple volumes to a virtual machine using a
+	// single CNS AttachVolume task, so that vCenter reconfigures the VM once.
+	// The returned map holds the result for each volume. The second and third
+	// return values are set when the task itself fails.
+	BatchAttachVolumes(ctx context.Context, vm *cnsvsphere.VirtualMachine, volumeIDs []string) (
+		map[string]*BatchAttachResult, string, error)
 	// DetachVolume detaches a volume from the virtual machine given the spec.
 	// When DetachVolume failed, the first return value (faultType) and second return value(error) need to be set, and
 	// should not be nil.
@@ -102,6 +113,10 @@ type Manager interface {
 	DeleteVolume(ctx context.Context, volumeID string, deleteDisk bool) (string, error)
 	// UpdateVolumeMetadata updates a volume metadata given its spec.
 	UpdateVolumeMetadata(ctx context.Context, spec *cnstypes.CnsVolumeMetadataUpdateSpec) error
+	// ReconfigVolumePolicy changes the storage policy of a volume. The volume
+	// may not be compliant with the new storage policy when it returns, while
+	// its data is moved to satisfy the policy.
+	ReconfigVolumePolicy(ctx context.Context, volumeID string, storagePolicyID string) error
 	// QueryVolumeInfo calls the CNS QueryVolumeInfo API and return a task, from
 	// which CnsQueryVolumeInfoResult is extracted.
 	QueryVolumeInfo(ctx context.Context, volumeIDList []cnstypes.CnsVolumeId) (*cnstypes.CnsQueryVolumeInfoResult, error)
@@ -131,6 +146,28 @@ type Manager interface {
 	RegisterDisk(ctx context.Context, path string, name string) (string, error)
 	// RetrieveVStorageObject helps in retreiving virtual disk information for a given volume id.
 	RetrieveVStorageObject(ctx context.Context, volumeID string) (*vim25types.VStorageObject, error)
+	// UpdateVStorageObjectMetadata adds or updates the given key-value metadata on a virtual disk
+	// and removes the metadata entries for deleteKeys.
+	UpdateVStorageObjectMetadata(ctx context.Context, volumeID string, metadata map[string]string,
+		deleteKeys []string) error
+	// RetrieveVStorageObjectMetadataValue returns the value of a metadata key on a virtual disk.
+	// An empty value is returned if the key is not set.
+	RetrieveVStorageObjectMetadataValue(ctx context.Context, volumeID string, key string) (string, error)
+	// RetrieveSnapshotChangeID returns the changed block tracking ID of a snapshot of a virtual disk.
+	RetrieveSnapshotChangeID(ctx context.Context, volumeID string, snapshotID string) (string, error)
+	// QueryChangedDiskAreas returns the areas of a snapshot of a virtual disk changed since the snapshot
+	// with the given changed block tracking ID, starting at startOffset. The allocated areas of the snapshot
+	// are returned if changeID is "*".
+	QueryChangedDiskAreas(ctx context.Context, volumeID string, snapshotID string, startOffset int64,
+		changeID string) (*vim25types.DiskChangeInfo, error)
+	// ListVStorageObjectsWithMetadataKey returns the IDs of virtual disks having the given metadata key.
+	ListVStorageObjectsWithMetadataKey(ctx context.Context, key string) ([]string, error)
+	// DeleteVStorageObject deletes a virtual disk which is not tracked by CNS using Vslm endpoint.
+	DeleteVStorageObject(ctx context.Context, volumeID string) error
+	// ConvertVStorageObjectProvisioningType converts the virtual disk of a volume to the given
+	// provisioning type by relocating it to the given datastore using Vslm endpoint.
+	ConvertVStorageObjectProvisioningType(ctx context.Context, volumeID string,
+		datastore vim25types.ManagedObjectReference, provisioningType string) error
 	// ProtectVolumeFromVMDeletion sets keepAfterDeleteVm control flag on migrated volume
 	ProtectVolumeFromVMDeletion(ctx context.Context, volumeID string) error
 	// CreateSnapshot helps create a snapshot for a block volume
@@ -158,6 +195,14 @@ type CnsVolumeInfo struct {
 	VolumeID     cnstypes.CnsVolumeId
 }
 
+// BatchAttachResult holds the outcome of attaching one volume as part of
+// BatchAttachVolumes.
+type BatchAttachResult struct {
+	DiskUUID  string
+	FaultType string
+	Err       error
+}
+
 type CnsSnapshotInfo struct {
 	SnapshotID                string
 	SourceVolumeID            string
@@ -632,6 +677,8 @@ func (m *defaultManager) createVolumeWithImprovedIdempotency(ctx context.Context
 			// Don't return if CreateVolume details can't be stored.
 			log.Warnf("failed to store CreateVolume details with error: %v", err)
 		}
+		taskRecorded := trackTaskRecord()
+		defer taskRecorded()
 		task, finalErr = invokeCNSCreateVolume(ctx, m.virtualCenter, spec)
 		if finalErr != nil {
 			log.Errorf("failed to create volume with error: %v", finalErr)
@@ -652,6 +699,7 @@ func (m *defaultManager) createVolumeWithImprovedIdempotency(ctx context.Context
 				log.Warnf("failed to store CreateVolume details with error: %v", err)
 			}
 		}
+		taskRecorded()
 	}
 
 	return m.MonitorCreateVolumeTask(ctx, &volumeOperationDetails, task, volNameFromInputSpec,
@@ -865,7 +913,7 @@ func (m *defaultManager) createVolume(ctx context.Context, spec *cnstypes.CnsVol
 // CreateVolume creates a new volume given its spec.
 func (m *defaultManager) CreateVolume(ctx context.Context, spec *cnstypes.CnsVolumeCreateSpec,
 	extraParams interface{}) (*CnsVolumeInfo, string, error) {
-	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, m.operationTimeouts().CreateVolume)
 	defer cancelFunc()
 	internalCreateVolume := func() (*CnsVolumeInfo, string, error) {
 		log := logger.GetLogger(ctx)
@@ -890,6 +938,9 @@ func (m *defaultManager) CreateVolume(ctx context.Context, spec *cnstypes.CnsVol
 	}
 	start := time.Now()
 	resp, faultType, err := internalCreateVolume()
+	if m.reloginOnStaleSession(ctx, "CreateVolume", err) {
+		resp, faultType, err = internalCreateVolume()
+	}
 	log := logger.GetLogger(ctx)
 	log.Debugf("internalCreateVolume: returns fault %q", faultType)
 	if err != nil {
@@ -903,22 +954,37 @@ func (m *defaultManager) CreateVolume(ctx context.Context, spec *cnstypes.CnsVol
 	return resp, faultType, err
 }
 
-// ensureOperationContextHasATimeout checks if the passed context has a timeout associated with it.
-// If there is no timeout set, we set it to 300 seconds. This is the same as set by sidecars.
-// If a timeout is already set, we don't change it.
-func ensureOperationContextHasATimeout(ctx context.Context) (context.Context, context.CancelFunc) {
-	_, ok := ctx.Deadline()
-	if !ok {
-		// no timeout is set, so we need to set it
-		return context.WithTimeout(ctx, VolumeOperationTimeoutInSeconds*time.Second)
+// ensureOperationContextHasATimeout limits the passed context to the given
+// operation timeout. If a shorter timeout is already set, it is kept, as the
+// deadline of a derived context never exceeds the one of its parent.
+func ensureOperationContextHasATimeout(ctx context.Context, timeoutInSeconds int) (context.Context,
+	context.CancelFunc) {
+	return context.WithTimeout(ctx, getOperationTimeout(timeoutInSeconds))
+}
+
+// getOperationTimeout returns the given operation timeout as a duration. If
+// the timeout isn't set, VolumeOperationTimeoutInSeconds is used. This is the
+// same as set by sidecars.
+func getOperationTimeout(timeoutInSeconds int) time.Duration {
+	if timeoutInSeconds <= 0 {
+		timeoutInSeconds = VolumeOperationTimeoutInSeconds
+	}
+	return time.Duration(timeoutInSeconds) * time.Second
+}
+
+// operationTimeouts returns the CNS operation timeouts configured for the
+// vCenter of the manager.
+func (m *defaultManager) operationTimeouts() cnsvsphere.OperationTimeouts {
+	if m.virtualCenter == nil || m.virtualCenter.Config == nil {
+		return cnsvsphere.OperationTimeouts{}
 	}
-	return context.WithCancel(ctx)
+	return m.virtualCenter.Config.OperationTimeouts
 }
 
 // AttachVolume attaches a volume to a virtual machine given the spec.
 func (m *defaultManager) AttachVolume(ctx context.Context,
 	vm *cnsvsphere.VirtualMachine, volumeID string, checkNVMeController bool) (string, string, error) {
-	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, m.operationTimeouts().AttachVolume)
 	defer cancelFunc()
 	internalAttachVolume := func() (string, string, error) {
 		log := logger.GetLogger(ctx)
@@ -1011,6 +1077,9 @@ func (m *defaultManager) AttachVolume(ctx context.Context,
 	}
 	start := time.Now()
 	resp, faultType, err := internalAttachVolume()
+	if m.reloginOnStaleSession(ctx, "AttachVolume", err) {
+		resp, faultType, err = internalAttachVolume()
+	}
 	log := logger.GetLogger(ctx)
 	log.Debugf("internalAttachVolume: returns fault %q for volume %q", faultType, volumeID)
 	if err != nil {
@@ -1019,14 +1088,122 @@ func (m *defaultManager) AttachVolume(ctx context.Context,
 	} else {
 		prometheus.CnsControlOpsHistVec.WithLabelValues(prometheus.PrometheusCnsAttachVolumeOpType,
 			prometheus.PrometheusPassStatus).Observe(time.Since(start).Seconds())
+		prometheus.AttachDetachLatencyHistVec.WithLabelValues(prometheus.PrometheusAttachVolumeOpType,
+			m.virtualCenter.Config.Host, prometheus.PrometheusVCTaskStage).Observe(time.Since(start).Seconds())
 	}
 	return resp, faultType, err
 }
 
+// BatchAttachVolumes attaches multiple volumes to a virtual machine in a single CNS task.
+func (m *defaultManager) BatchAttachVolumes(ctx context.Context, vm *cnsvsphere.VirtualMachine,
+	volumeIDs []string) (map[string]*BatchAttachResult, string, error) {
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, m.operationTimeouts().AttachVolume)
+	defer cancelFunc()
+	internalBatchAttachVolumes := func() (map[string]*BatchAttachResult, string, error) {
+		log := logger.GetLogger(ctx)
+		err := validateManager(ctx, m)
+		if err != nil {
+			return nil, ExtractFaultTypeFromErr(ctx, err), err
+		}
+		// Set up the VC connection.
+		err = m.virtualCenter.ConnectCns(ctx)
+		if err != nil {
+			log.Errorf("ConnectCns failed with err: %+v", err)
+			return nil, ExtractFaultTypeFromErr(ctx, err), err
+		}
+		var cnsAttachSpecList []cnstypes.CnsVolumeAttachDetachSpec
+		for _, volumeID := range volumeIDs {
+			cnsAttachSpecList = append(cnsAttachSpecList, cnstypes.CnsVolumeAttachDetachSpec{
+				VolumeId: cnstypes.CnsVolumeId{
+					Id: volumeID,
+				},
+				Vm: vm.Reference(),
+			})
+		}
+		task, err := m.virtualCenter.CnsClient.AttachVolume(ctx, cnsAttachSpecList)
+		if err != nil {
+			log.Errorf("CNS AttachVolume failed from vCenter %q with err: %v", m.virtualCenter.Config.Host, err)
+			return nil, ExtractFaultTypeFromErr(ctx, err), err
+		}
+		var taskInfo *vim25types.TaskInfo
+		if m.tasksListViewEnabled {
+			taskInfo, err = m.waitOnTask(ctx, task.Reference())
+		} else {
+			taskInfo, err = cns.GetTaskInfo(ctx, task)
+		}
+		if err != nil || taskInfo == nil {
+			log.Errorf("failed to get taskInfo for AttachVolume task from vCenter %q with err: %v",
+				m.virtualCenter.Config.Host, err)
+			if err != nil {
+				return nil, ExtractFaultTypeFromErr(ctx, err), err
+			}
+			return nil, csifault.CSITaskInfoEmptyFault, logger.LogNewErrorf(log,
+				"taskInfo is empty for AttachVolume task: %q", task.Reference().Value)
+		}
+		log.Infof("BatchAttachVolumes: volumeIDs: %v, vm: %q, opId: %q", volumeIDs, vm.String(),
+			taskInfo.ActivationId)
+		taskResults, err := cns.GetTaskResultArray(ctx, taskInfo)
+		if err != nil {
+			log.Errorf("unable to find AttachVolume results from vCenter %q with taskID %s",
+				m.virtualCenter.Config.Host, taskInfo.Task.Value)
+			return nil, ExtractFaultTypeFromErr(ctx, err), err
+		}
+		results := make(map[string]*BatchAttachResult)
+		for _, taskResult := range taskResults {
+			volumeOperationRes := taskResult.GetCnsVolumeOperationResult()
+			volumeID := volumeOperationRes.VolumeId.Id
+			if volumeOperationRes.Fault != nil {
+				result := &BatchAttachResult{
+					FaultType: ExtractFaultTypeFromVolumeResponseResult(ctx, volumeOperationRes),
+				}
+				if _, isResourceInUseFault := volumeOperationRes.Fault.Fault.(*vim25types.ResourceInUse); isResourceInUseFault {
+					// Check if volume is already attached to the requested node.
+					diskUUID, err := IsDiskAttached(ctx, vm, volumeID, false)
+					if err == nil && diskUUID != "" {
+						results[volumeID] = &BatchAttachResult{DiskUUID: diskUUID}
+						continue
+					}
+				}
+				result.Err = fmt.Errorf("failed to attach cns volume: %q to node vm: %q. fault: %q. opId: %q",
+					volumeID, vm.String(), spew.Sdump(volumeOperationRes.Fault), taskInfo.ActivationId)
+				log.Error(result.Err)
+				results[volumeID] = result
+				continue
+			}
+			attachResult, ok := taskResult.(*cnstypes.CnsVolumeAttachResult)
+			if !ok {
+				results[volumeID] = &BatchAttachResult{FaultType: csifault.CSITaskResultEmptyFault,
+					Err: fmt.Errorf("unexpected attach result %+v for volume %q", taskResult, volumeID)}
+				continue
+			}
+			results[volumeID] = &BatchAttachResult{DiskUUID: attachResult.DiskUUID}
+		}
+		// Volumes missing from the task result are reported as failed so that
+		// callers can retry them individually.
+		for _, volumeID := range volumeIDs {
+			if _, ok := results[volumeID]; !ok {
+				results[volumeID] = &BatchAttachResult{FaultType: csifault.CSITaskResultEmptyFault,
+					Err: fmt.Errorf("no attach result found for volume %q in task %q", volumeID, taskInfo.Task.Value)}
+			}
+		}
+		return results, "", nil
+	}
+	start := time.Now()
+	results, faultType, err := internalBatchAttachVolumes()
+	if err != nil {
+		prometheus.CnsControlOpsHistVec.WithLabelValues(prometheus.PrometheusCnsAttachVolumeOpType,
+			prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
+	} else {
+		prometheus.CnsControlOpsHistVec.WithLabelValues(prometheus.PrometheusCnsAttachVolumeOpType,
+			prometheus.PrometheusPassStatus).Observe(time.Since(start).Seconds())
+	}
+	return results, faultType, err
+}
+
 // DetachVolume detaches a volume from the virtual machine given the spec.
 func (m *defaultManager) DetachVolume(ctx context.Context, vm *cnsvsphere.VirtualMachine, volumeID string) (string,
 	error) {
-	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, m.operationTimeouts().DetachVolume)
 	defer cancelFunc()
 	internalDetachVolume := func() (string, error) {
 		log := logger.GetLogger(ctx)
@@ -1154,6 +1331,9 @@ func (m *defaultManager) DetachVolume(ctx context.Context, vm *cnsvsphere.Virtua
 	}
 	start := time.Now()
 	faultType, err := internalDetachVolume()
+	if m.reloginOnStaleSession(ctx, "DetachVolume", err) {
+		faultType, err = internalDetachVolume()
+	}
 	log := logger.GetLogger(ctx)
 	log.Debugf("internalDetachVolume: returns fault %q for volume %q", faultType, volumeID)
 	if err != nil {
@@ -1162,13 +1342,15 @@ func (m *defaultManager) DetachVolume(ctx context.Context, vm *cnsvsphere.Virtua
 	} else {
 		prometheus.CnsControlOpsHistVec.WithLabelValues(prometheus.PrometheusCnsDetachVolumeOpType,
 			prometheus.PrometheusPassStatus).Observe(time.Since(start).Seconds())
+		prometheus.AttachDetachLatencyHistVec.WithLabelValues(prometheus.PrometheusDetachVolumeOpType,
+			m.virtualCenter.Config.Host, prometheus.PrometheusVCTaskStage).Observe(time.Since(start).Seconds())
 	}
 	return faultType, err
 }
 
 // DeleteVolume deletes a volume given its spec.
 func (m *defaultManager) DeleteVolume(ctx context.Context, volumeID string, deleteDisk bool) (string, error) {
-	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, m.operationTimeouts().DeleteVolume)
 	defer cancelFunc()
 	internalDeleteVolume := func() (string, error) {
 		log := logger.GetLogger(ctx)
@@ -1193,6 +1375,9 @@ func (m *defaultManager) DeleteVolume(ctx context.Context, volumeID string, dele
 	}
 	start := time.Now()
 	faultType, err := internalDeleteVolume()
+	if m.reloginOnStaleSession(ctx, "DeleteVolume", err) {
+		faultType, err = internalDeleteVolume()
+	}
 	log := logger.GetLogger(ctx)
 	log.Debugf("internalDeleteVolume: returns fault %q for volume %q", faultType, volumeID)
 	if err != nil {
@@ -1348,6 +1533,8 @@ func (m *defaultManager) deleteVolumeWithImprovedIdempotency(ctx context.Context
 		}
 		// Call the CNS DeleteVolume.
 		cnsVolumeIDList = append(cnsVolumeIDList, cnsVolumeID)
+		taskRecorded := trackTaskRecord()
+		defer taskRecorded()
 		task, err = m.virtualCenter.CnsClient.DeleteVolume(ctx, cnsVolumeIDList, deleteDisk)
 		if err != nil {
 			faultType = ExtractFaultTypeFromErr(ctx, err)
@@ -1377,6 +1564,7 @@ func (m *defaultManager) deleteVolumeWithImprovedIdempotency(ctx context.Context
 		if err != nil {
 			log.Warnf("failed to store DeleteVolume details with error: %v", err)
 		}
+		taskRecorded()
 	}
 
 	// Get the taskInfo.
@@ -1447,7 +1635,7 @@ func (m *defaultManager) deleteVolumeWithImprovedIdempotency(ctx context.Context
 
 // UpdateVolumeMetadata updates a volume given its spec.
 func (m *defaultManager) UpdateVolumeMetadata(ctx context.Context, spec *cnstypes.CnsVolumeMetadataUpdateSpec) error {
-	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, m.operationTimeouts().UpdateVolume)
 	defer cancelFunc()
 	internalUpdateVolumeMetadata := func() error {
 		log := logger.GetLogger(ctx)
@@ -1526,6 +1714,9 @@ func (m *defaultManager) UpdateVolumeMetadata(ctx context.Context, spec *cnstype
 	}
 	start := time.Now()
 	err := internalUpdateVolumeMetadata()
+	if m.reloginOnStaleSession(ctx, "UpdateVolumeMetadata", err) {
+		err = internalUpdateVolumeMetadata()
+	}
 	if err != nil {
 		prometheus.CnsControlOpsHistVec.WithLabelValues(prometheus.PrometheusCnsUpdateVolumeMetadataOpType,
 			prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
@@ -1536,10 +1727,73 @@ func (m *defaultManager) UpdateVolumeMetadata(ctx context.Context, spec *cnstype
 	return err
 }
 
+// ReconfigVolumePolicy changes the storage policy of a volume.
+func (m *defaultManager) ReconfigVolumePolicy(ctx context.Context, volumeID string, storagePolicyID string) error {
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, m.operationTimeouts().UpdateVolume)
+	defer cancelFunc()
+	log := logger.GetLogger(ctx)
+	err := validateManager(ctx, m)
+	if err != nil {
+		return err
+	}
+	// Set up the VC connection.
+	err = m.virtualCenter.ConnectCns(ctx)
+	if err != nil {
+		log.Errorf("ConnectCns failed with err: %+v", err)
+		return err
+	}
+	reconfigSpecs := []cnstypes.CnsVolumePolicyReconfigSpec{
+		{
+			VolumeId: cnstypes.CnsVolumeId{Id: volumeID},
+			Profile: []vim25types.BaseVirtualMachineProfileSpec{
+				&vim25types.VirtualMachineDefinedProfileSpec{
+					ProfileId: storagePolicyID,
+				},
+			},
+		},
+	}
+	task, err := m.virtualCenter.CnsClient.ReconfigVolumePolicy(ctx, reconfigSpecs)
+	if err != nil {
+		return logger.LogNewErrorf(log, "CNS ReconfigVolumePolicy failed from vCenter %q with err: %v",
+			m.virtualCenter.Config.Host, err)
+	}
+	// Get the taskInfo.
+	var taskInfo *vim25types.TaskInfo
+	if m.tasksListViewEnabled {
+		taskInfo, err = m.waitOnTask(ctx, task.Reference())
+	} else {
+		taskInfo, err = cns.GetTaskInfo(ctx, task)
+	}
+	if err != nil || taskInfo == nil {
+		return logger.LogNewErrorf(log, "failed to get ReconfigVolumePolicy taskInfo from vCenter %q with err: %v",
+			m.virtualCenter.Config.Host, err)
+	}
+	log.Infof("ReconfigVolumePolicy: volumeID: %q, opId: %q", volumeID, taskInfo.ActivationId)
+	// Get the task results for the given task.
+	taskResult, err := cns.GetTaskResult(ctx, taskInfo)
+	if err != nil {
+		return logger.LogNewErrorf(log, "unable to find ReconfigVolumePolicy result from vCenter %q: "+
+			"taskID %q, opId %q. Err: %v", m.virtualCenter.Config.Host, taskInfo.Task.Value,
+			taskInfo.ActivationId, err)
+	}
+	if taskResult == nil {
+		return logger.LogNewErrorf(log, "taskResult is empty for ReconfigVolumePolicy task: %q, opId: %q",
+			taskInfo.Task.Value, taskInfo.ActivationId)
+	}
+	volumeOperationRes := taskResult.GetCnsVolumeOperationResult()
+	if volumeOperationRes.Fault != nil {
+		return logger.LogNewErrorf(log, "failed to reconfigure storage policy of volume %q to %q. fault: %q, opID: %q",
+			volumeID, storagePolicyID, spew.Sdump(volumeOperationRes.Fault), taskInfo.ActivationId)
+	}
+	log.Infof("ReconfigVolumePolicy: Storage policy of volume %q changed to %q successfully. opId: %q",
+		volumeID, storagePolicyID, taskInfo.ActivationId)
+	return nil
+}
+
 // ExpandVolume expands a volume given its spec.
 func (m *defaultManager) ExpandVolume(ctx context.Context, volumeID string, size int64,
 	extraParams interface{}) (string, error) {
-	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, m.operationTimeouts().ExpandVolume)
 	defer cancelFunc()
 	internalExpandVolume := func() (string, error) {
 		log := logger.GetLogger(ctx)
@@ -1565,6 +1819,9 @@ func (m *defaultManager) ExpandVolume(ctx context.Context, volumeID string, size
 	}
 	start := time.Now()
 	faultType, err := internalExpandVolume()
+	if m.reloginOnStaleSession(ctx, "ExpandVolume", err) {
+		faultType, err = internalExpandVolume()
+	}
 	log := logger.GetLogger(ctx)
 	log.Debugf("internalExpandVolume: returns fault %q for volume %q", faultType, volumeID)
 	if err != nil {
@@ -1770,6 +2027,8 @@ func (m *defaultManager) expandVolumeWithImprovedIdempotency(ctx context.Context
 		// Call the CNS ExtendVolume.
 		log.Infof("Calling CnsClient.ExtendVolume: VolumeID [%q] Size [%d] cnsExtendSpecList [%#v]",
 			volumeID, size, cnsExtendSpecList)
+		taskRecorded := trackTaskRecord()
+		defer taskRecorded()
 		task, finalErr = m.virtualCenter.CnsClient.ExtendVolume(ctx, cnsExtendSpecList)
 		if finalErr != nil {
 			faultType = ExtractFaultTypeFromErr(ctx, finalErr)
@@ -1788,6 +2047,7 @@ func (m *defaultManager) expandVolumeWithImprovedIdempotency(ctx context.Context
 		if err != nil {
 			log.Warnf("failed to store ExpandVolume details with error: %v", err)
 		}
+		taskRecorded()
 	}
 
 	var taskInfo *vim25types.TaskInfo
@@ -1882,7 +2142,7 @@ func (m *defaultManager) expandVolumeWithImprovedIdempotency(ctx context.Context
 // QueryVolume returns volumes matching the given filter.
 func (m *defaultManager) QueryVolume(ctx context.Context,
 	queryFilter cnstypes.CnsQueryFilter) (*cnstypes.CnsQueryResult, error) {
-	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, m.operationTimeouts().Query)
 	defer cancelFunc()
 	internalQueryVolume := func() (*cnstypes.CnsQueryResult, error) {
 		log := logger.GetLogger(ctx)
@@ -1907,6 +2167,9 @@ func (m *defaultManager) QueryVolume(ctx context.Context,
 	}
 	start := time.Now()
 	resp, err := internalQueryVolume()
+	if m.reloginOnStaleSession(ctx, "QueryVolume", err) {
+		resp, err = internalQueryVolume()
+	}
 	if err != nil {
 		prometheus.CnsControlOpsHistVec.WithLabelValues(prometheus.PrometheusCnsQueryVolumeOpType,
 			prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
@@ -1920,7 +2183,7 @@ func (m *defaultManager) QueryVolume(ctx context.Context,
 // QueryAllVolume returns all volumes matching the given filter and selection.
 func (m *defaultManager) QueryAllVolume(ctx context.Context, queryFilter cnstypes.CnsQueryFilter,
 	querySelection cnstypes.CnsQuerySelection) (*cnstypes.CnsQueryResult, error) {
-	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, m.operationTimeouts().Query)
 	defer cancelFunc()
 	internalQueryAllVolume := func() (*cnstypes.CnsQueryResult, error) {
 		log := logger.GetLogger(ctx)
@@ -1945,6 +2208,9 @@ func (m *defaultManager) QueryAllVolume(ctx context.Context, queryFilter cnstype
 	}
 	start := time.Now()
 	resp, err := internalQueryAllVolume()
+	if m.reloginOnStaleSession(ctx, "QueryAllVolume", err) {
+		resp, err = internalQueryAllVolume()
+	}
 	if err != nil {
 		prometheus.CnsControlOpsHistVec.WithLabelValues(prometheus.PrometheusCnsQueryAllVolumeOpType,
 			prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
@@ -1959,7 +2225,7 @@ func (m *defaultManager) QueryAllVolume(ctx context.Context, queryFilter cnstype
 // which CnsQueryVolumeInfoResult is extracted.
 func (m *defaultManager) QueryVolumeInfo(ctx context.Context,
 	volumeIDList []cnstypes.CnsVolumeId) (*cnstypes.CnsQueryVolumeInfoResult, error) {
-	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, m.operationTimeouts().Query)
 	defer cancelFunc()
 	internalQueryVolumeInfo := func() (*cnstypes.CnsQueryVolumeInfoResult, error) {
 		log := logger.GetLogger(ctx)
@@ -2016,6 +2282,9 @@ func (m *defaultManager) QueryVolumeInfo(ctx context.Context,
 	}
 	start := time.Now()
 	resp, err := internalQueryVolumeInfo()
+	if m.reloginOnStaleSession(ctx, "QueryVolumeInfo", err) {
+		resp, err = internalQueryVolumeInfo()
+	}
 	if err != nil {
 		prometheus.CnsControlOpsHistVec.WithLabelValues(prometheus.PrometheusCnsQueryVolumeInfoOpType,
 			prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
@@ -2028,7 +2297,7 @@ func (m *defaultManager) QueryVolumeInfo(ctx context.Context,
 
 func (m *defaultManager) RelocateVolume(ctx context.Context,
 	relocateSpecList ...cnstypes.BaseCnsVolumeRelocateSpec) (*object.Task, error) {
-	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, m.operationTimeouts().RelocateVolume)
 	defer cancelFunc()
 	internalRelocateVolume := func() (*object.Task, error) {
 		log := logger.GetLogger(ctx)
@@ -2065,7 +2334,7 @@ func (m *defaultManager) RelocateVolume(ctx context.Context,
 
 // ConfigureVolumeACLs configures net permissions for a given CnsVolumeACLConfigureSpec.
 func (m *defaultManager) ConfigureVolumeACLs(ctx context.Context, spec cnstypes.CnsVolumeACLConfigureSpec) error {
-	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, m.operationTimeouts().ConfigureACLs)
 	defer cancelFunc()
 	internalConfigureVolumeACLs := func() error {
 		log := logger.GetLogger(ctx)
@@ -2196,6 +2465,256 @@ func (m *defaultManager) RetrieveVStorageObject(ctx context.Context,
 	return vStorageObject, nil
 }
 
+// UpdateVStorageObjectMetadata adds or updates the given key-value metadata
+// on a virtual disk and removes the metadata entries for deleteKeys.
+func (m *defaultManager) UpdateVStorageObjectMetadata(ctx context.Context, volumeID string,
+	metadata map[string]string, deleteKeys []string) error {
+	log := logger.GetLogger(ctx)
+	err := validateManager(ctx, m)
+	if err != nil {
+		log.Errorf("failed to validate volume manager with err: %+v", err)
+		return err
+	}
+	// Set up the VC connection
+	err = m.virtualCenter.ConnectVslm(ctx)
+	if err != nil {
+		log.Errorf("ConnectVslm failed with err: %+v", err)
+		return err
+	}
+	var keyValues []vim25types.KeyValue
+	for key, value := range metadata {
+		keyValues = append(keyValues, vim25types.KeyValue{Key: key, Value: value})
+	}
+	globalObjectManager := vslm.NewGlobalObjectManager(m.virtualCenter.VslmClient)
+	task, err := globalObjectManager.UpdateMetadata(ctx, vim25types.ID{Id: volumeID}, keyValues, deleteKeys)
+	if err != nil {
+		log.Errorf("failed to update metadata for volumeID %q with err: %v", volumeID, err)
+		return err
+	}
+	_, err = task.Wait(ctx, getOperationTimeout(m.operationTimeouts().UpdateVolume))
+	if err != nil {
+		log.Errorf("failed to update metadata for volumeID %q with err: %v", volumeID, err)
+		return err
+	}
+	log.Infof("Successfully updated metadata %v, removed keys %v for volumeID: %q", metadata, deleteKeys, volumeID)
+	return nil
+}
+
+// RetrieveVStorageObjectMetadataValue returns the value of a metadata key on
+// a virtual disk. An empty value is returned if the key is not set.
+func (m *defaultManager) RetrieveVStorageObjectMetadataValue(ctx context.Context, volumeID string,
+	key string) (string, error) {
+	log := logger.GetLogger(ctx)
+	err := validateManager(ctx, m)
+	if err != nil {
+		log.Errorf("failed to validate volume manager with err: %+v", err)
+		return "", err
+	}
+	// Set up the VC connection
+	err = m.virtualCenter.ConnectVslm(ctx)
+	if err != nil {
+		log.Errorf("ConnectVslm failed with err: %+v", err)
+		return "", err
+	}
+	globalObjectManager := vslm.NewGlobalObjectManager(m.virtualCenter.VslmClient)
+	// Retrieve by prefix, as retrieving the value of a missing key is a fault.
+	keyValues, err := globalObjectManager.RetrieveMetadata(ctx, vim25types.ID{Id: volumeID}, nil, key)
+	if err != nil {
+		log.Errorf("failed to retrieve metadata for volumeID %q with err: %v", volumeID, err)
+		return "", err
+	}
+	for _, keyValue := range keyValues {
+		if keyValue.Key == key {
+			return keyValue.Value, nil
+		}
+	}
+	return "", nil
+}
+
+// RetrieveSnapshotChangeID returns the changed block tracking ID of a
+// snapshot of a virtual disk.
+func (m *defaultManager) RetrieveSnapshotChangeID(ctx context.Context, volumeID string,
+	snapshotID string) (string, error) {
+	log := logger.GetLogger(ctx)
+	err := validateManager(ctx, m)
+	if err != nil {
+		log.Errorf("failed to validate volume manager with err: %+v", err)
+		return "", err
+	}
+	// Set up the VC connection
+	err = m.virtualCenter.ConnectVslm(ctx)
+	if err != nil {
+		log.Errorf("ConnectVslm failed with err: %+v", err)
+		return "", err
+	}
+	globalObjectManager := vslm.NewGlobalObjectManager(m.virtualCenter.VslmClient)
+	details, err := globalObjectManager.RetrieveSnapshotDetails(ctx, vim25types.ID{Id: volumeID},
+		vim25types.ID{Id: snapshotID})
+	if err != nil {
+		log.Errorf("failed to retrieve details of snapshot %q of volumeID %q with err: %v", snapshotID,
+			volumeID, err)
+		return "", err
+	}
+	return details.ChangedBlockTrackingId, nil
+}
+
+// QueryChangedDiskAreas returns the areas of a snapshot of a virtual disk
+// changed since the snapshot with the given changed block tracking ID,
+// starting at startOffset. The allocated areas of the snapshot are returned
+// if changeID is "*".
+func (m *defaultManager) QueryChangedDiskAreas(ctx context.Context, volumeID string, snapshotID string,
+	startOffset int64, changeID string) (*vim25types.DiskChangeInfo, error) {
+	log := logger.GetLogger(ctx)
+	err := validateManager(ctx, m)
+	if err != nil {
+		log.Errorf("failed to validate volume manager with err: %+v", err)
+		return nil, err
+	}
+	// Set up the VC connection
+	err = m.virtualCenter.ConnectVslm(ctx)
+	if err != nil {
+		log.Errorf("ConnectVslm failed with err: %+v", err)
+		return nil, err
+	}
+	globalObjectManager := vslm.NewGlobalObjectManager(m.virtualCenter.VslmClient)
+	changeInfo, err := globalObjectManager.QueryChangedDiskAreas(ctx, vim25types.ID{Id: volumeID},
+		vim25types.ID{Id: snapshotID}, startOffset, changeID)
+	if err != nil {
+		log.Errorf("failed to query changed areas of snapshot %q of volumeID %q from offset %d with err: %v",
+			snapshotID, volumeID, startOffset, err)
+		return nil, err
+	}
+	return changeInfo, nil
+}
+
+// ListVStorageObjectsWithMetadataKey returns the IDs of virtual disks having
+// the given metadata key. The virtual disks are listed in pages of
+// vslmListObjectsMaxResult, each page querying the IDs greater than the last
+// ID of the previous one, as VSLM returns the IDs in ascending order.
+func (m *defaultManager) ListVStorageObjectsWithMetadataKey(ctx context.Context, key string) ([]string, error) {
+	log := logger.GetLogger(ctx)
+	err := validateManager(ctx, m)
+	if err != nil {
+		log.Errorf("failed to validate volume manager with err: %+v", err)
+		return nil, err
+	}
+	// Set up the VC connection
+	err = m.virtualCenter.ConnectVslm(ctx)
+	if err != nil {
+		log.Errorf("ConnectVslm failed with err: %+v", err)
+		return nil, err
+	}
+	globalObjectManager := vslm.NewGlobalObjectManager(m.virtualCenter.VslmClient)
+	var volumeIDs []string
+	for {
+		query := []vslmtypes.VslmVsoVStorageObjectQuerySpec{
+			{
+				QueryField:    string(vslmtypes.VslmVsoVStorageObjectQuerySpecQueryFieldEnumMetadataKey),
+				QueryOperator: string(vslmtypes.VslmVsoVStorageObjectQuerySpecQueryOperatorEnumEquals),
+				QueryValue:    []string{key},
+			},
+		}
+		if len(volumeIDs) > 0 {
+			query = append(query, vslmtypes.VslmVsoVStorageObjectQuerySpec{
+				QueryField:    string(vslmtypes.VslmVsoVStorageObjectQuerySpecQueryFieldEnumId),
+				QueryOperator: string(vslmtypes.VslmVsoVStorageObjectQuerySpecQueryOperatorEnumGreaterThan),
+				QueryValue:    []string{volumeIDs[len(volumeIDs)-1]},
+			})
+		}
+		result, err := globalObjectManager.ListObjectsForSpec(ctx, query, vslmListObjectsMaxResult)
+		if err != nil {
+			log.Errorf("failed to list virtual disks with metadata key %q with err: %v", key, err)
+			return nil, err
+		}
+		for _, id := range result.Id {
+			volumeIDs = append(volumeIDs, id.Id)
+		}
+		if result.AllRecordsReturned {
+			break
+		}
+		if len(result.Id) == 0 {
+			return nil, logger.LogNewErrorf(log, "failed to list virtual disks with metadata key %q: "+
+				"no virtual disk returned while more records are available", key)
+		}
+		log.Debugf("Listed %d virtual disks with metadata key %q so far, listing the next page",
+			len(volumeIDs), key)
+	}
+	return volumeIDs, nil
+}
+
+// DeleteVStorageObject deletes a virtual disk which is not tracked by CNS.
+func (m *defaultManager) DeleteVStorageObject(ctx context.Context, volumeID string) error {
+	log := logger.GetLogger(ctx)
+	err := validateManager(ctx, m)
+	if err != nil {
+		log.Errorf("failed to validate volume manager with err: %+v", err)
+		return err
+	}
+	// Set up the VC connection
+	err = m.virtualCenter.ConnectVslm(ctx)
+	if err != nil {
+		log.Errorf("ConnectVslm failed with err: %+v", err)
+		return err
+	}
+	globalObjectManager := vslm.NewGlobalObjectManager(m.virtualCenter.VslmClient)
+	task, err := globalObjectManager.Delete(ctx, vim25types.ID{Id: volumeID})
+	if err != nil {
+		log.Errorf("failed to delete virtual disk for volumeID %q with err: %v", volumeID, err)
+		return err
+	}
+	_, err = task.Wait(ctx, getOperationTimeout(m.operationTimeouts().DeleteVolume))
+	if err != nil {
+		log.Errorf("failed to delete virtual disk for volumeID %q with err: %v", volumeID, err)
+		return err
+	}
+	log.Infof("Successfully deleted virtual disk for volumeID: %q", volumeID)
+	return nil
+}
+
+// ConvertVStorageObjectProvisioningType converts the virtual disk of a volume
+// to the given provisioning type, one of the
+// BaseConfigInfoDiskFileBackingInfoProvisioningType values, by relocating it
+// to the given datastore, which may be its current datastore.
+func (m *defaultManager) ConvertVStorageObjectProvisioningType(ctx context.Context, volumeID string,
+	datastore vim25types.ManagedObjectReference, provisioningType string) error {
+	log := logger.GetLogger(ctx)
+	err := validateManager(ctx, m)
+	if err != nil {
+		log.Errorf("failed to validate volume manager with err: %+v", err)
+		return err
+	}
+	// Set up the VC connection
+	err = m.virtualCenter.ConnectVslm(ctx)
+	if err != nil {
+		log.Errorf("ConnectVslm failed with err: %+v", err)
+		return err
+	}
+	spec := vim25types.VslmRelocateSpec{
+		VslmMigrateSpec: vim25types.VslmMigrateSpec{
+			BackingSpec: &vim25types.VslmCreateSpecDiskFileBackingSpec{
+				VslmCreateSpecBackingSpec: vim25types.VslmCreateSpecBackingSpec{Datastore: datastore},
+				ProvisioningType:          provisioningType,
+			},
+		},
+	}
+	globalObjectManager := vslm.NewGlobalObjectManager(m.virtualCenter.VslmClient)
+	task, err := globalObjectManager.Relocate(ctx, vim25types.ID{Id: volumeID}, spec)
+	if err != nil {
+		log.Errorf("failed to convert virtual disk for volumeID %q to provisioning type %q with err: %v",
+			volumeID, provisioningType, err)
+		return err
+	}
+	_, err = task.Wait(ctx, getOperationTimeout(m.operationTimeouts().CreateVolume))
+	if err != nil {
+		log.Errorf("failed to convert virtual disk for volumeID %q to provisioning type %q with err: %v",
+			volumeID, provisioningType, err)
+		return err
+	}
+	log.Infof("Successfully converted virtual disk for volumeID: %q to provisioning type %q", volumeID,
+		provisioningType)
+	return nil
+}
+
 // QueryVolumeAsync returns volumes matching the given filter by using
 // CnsQueryAsync API. QueryVolumeAsync takes querySelection spec which helps
 // to specify which fields for the query entities to be returned. All volume
@@ -2203,7 +2722,7 @@ func (m *defaultManager) RetrieveVStorageObject(ctx context.Context,
 // parameters are not specified.
 func (m *defaultManager) QueryVolumeAsync(ctx context.Context, queryFilter cnstypes.CnsQueryFilter,
 	querySelection *cnstypes.CnsQuerySelection) (*cnstypes.CnsQueryResult, error) {
-	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, m.operationTimeouts().Query)
 	defer cancelFunc()
 	log := logger.GetLogger(ctx)
 	err := validateManager(ctx, m)
@@ -2269,7 +2788,7 @@ func (m *defaultManager) QueryVolumeAsync(ctx context.Context, queryFilter cnsty
 
 func (m *defaultManager) QuerySnapshots(ctx context.Context, snapshotQueryFilter cnstypes.CnsSnapshotQueryFilter) (
 	*cnstypes.CnsSnapshotQueryResult, error) {
-	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, m.operationTimeouts().Query)
 	defer cancelFunc()
 	internalQuerySnapshots := func() (*cnstypes.CnsSnapshotQueryResult, error) {
 		log := logger.GetLogger(ctx)
@@ -2310,6 +2829,9 @@ func (m *defaultManager) QuerySnapshots(ctx context.Context, snapshotQueryFilter
 	}
 	start := time.Now()
 	resp, err := internalQuerySnapshots()
+	if m.reloginOnStaleSession(ctx, "QuerySnapshots", err) {
+		resp, err = internalQuerySnapshots()
+	}
 	if err != nil {
 		prometheus.CnsControlOpsHistVec.WithLabelValues(prometheus.PrometheusQuerySnapshotsOpType,
 			prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
@@ -2424,6 +2946,8 @@ func (m *defaultManager) createSnapshotWithImprovedIdempotencyCheck(ctx context.
 	}()
 
 	if createSnapshotsTask == nil {
+		taskRecorded := trackTaskRecord()
+		defer taskRecorded()
 		createSnapshotsTask, err = invokeCNSCreateSnapshot(ctx, m.virtualCenter, volumeID, instanceName)
 		if err != nil {
 			if m.idempotencyHandlingEnabled {
@@ -2458,6 +2982,7 @@ func (m *defaultManager) createSnapshotWithImprovedIdempotencyCheck(ctx context.
 			}()
 
 		}
+		taskRecorded()
 	}
 
 	// Get the taskInfo and more!
@@ -2587,7 +3112,7 @@ func (m *defaultManager) createSnapshotWithImprovedIdempotencyCheck(ctx context.
 // which is generated by the CSI snapshotter sidecar.
 func (m *defaultManager) CreateSnapshot(
 	ctx context.Context, volumeID string, snapshotName string, extraParams interface{}) (*CnsSnapshotInfo, error) {
-	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, m.operationTimeouts().Snapshot)
 	defer cancelFunc()
 	internalCreateSnapshot := func() (*CnsSnapshotInfo, error) {
 		log := logger.GetLogger(ctx)
@@ -2606,6 +3131,9 @@ func (m *defaultManager) CreateSnapshot(
 
 	start := time.Now()
 	cnsSnapshotInfo, err := internalCreateSnapshot()
+	if m.reloginOnStaleSession(ctx, "CreateSnapshot", err) {
+		cnsSnapshotInfo, err = internalCreateSnapshot()
+	}
 	if err != nil {
 		prometheus.CnsControlOpsHistVec.WithLabelValues(prometheus.PrometheusCnsCreateSnapshotOpType,
 			prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
@@ -2678,6 +3206,8 @@ func (m *defaultManager) deleteSnapshotWithImprovedIdempotencyCheck(
 	}()
 
 	if deleteSnapshotTask == nil {
+		taskRecorded := trackTaskRecord()
+		defer taskRecorded()
 		deleteSnapshotTask, err = invokeCNSDeleteSnapshot(ctx, m.virtualCenter, volumeID, snapshotID)
 		if err != nil {
 			if cnsvsphere.IsNotFoundError(err) {
@@ -2712,6 +3242,7 @@ func (m *defaultManager) deleteSnapshotWithImprovedIdempotencyCheck(
 				log.Warnf("failed to store DeleteSnapshot operation details with error: %v", err)
 			}
 		}
+		taskRecorded()
 	}
 
 	// Get the taskInfo
@@ -2813,7 +3344,7 @@ func (m *defaultManager) deleteSnapshotWithImprovedIdempotencyCheck(
 }
 
 func (m *defaultManager) DeleteSnapshot(ctx context.Context, volumeID string, snapshotID string) error {
-	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, m.operationTimeouts().Snapshot)
 	defer cancelFunc()
 	internalDeleteSnapshot := func() error {
 		log := logger.GetLogger(ctx)
@@ -2833,6 +3364,9 @@ func (m *defaultManager) DeleteSnapshot(ctx context.Context, volumeID string, sn
 
 	start := time.Now()
 	err := internalDeleteSnapshot()
+	if m.reloginOnStaleSession(ctx, "DeleteSnapshot", err) {
+		err = internalDeleteSnapshot()
+	}
 	if err != nil {
 		prometheus.CnsControlOpsHistVec.WithLabelValues(prometheus.PrometheusCnsDeleteSnapshotOpType,
 			prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
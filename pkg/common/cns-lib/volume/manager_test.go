@@ -50,3 +50,29 @@ func performSlowTask(ch chan TaskResult, delay time.Duration) {
 		Err:      nil,
 	}
 }
+
+func TestEnsureOperationContextHasATimeout(t *testing.T) {
+	// the operation timeout applies to a context without deadline
+	ctx, cancelFunc := ensureOperationContextHasATimeout(context.TODO(), 60)
+	defer cancelFunc()
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(60*time.Second), deadline, time.Second)
+
+	// the operation timeout applies if it is shorter than the deadline
+	longCtx, longCancelFunc := context.WithTimeout(context.TODO(), time.Hour)
+	defer longCancelFunc()
+	ctx, cancelFunc = ensureOperationContextHasATimeout(longCtx, 60)
+	defer cancelFunc()
+	deadline, _ = ctx.Deadline()
+	assert.WithinDuration(t, time.Now().Add(60*time.Second), deadline, time.Second)
+
+	// a shorter deadline is kept
+	shortCtx, shortCancelFunc := context.WithTimeout(context.TODO(), 10*time.Second)
+	defer shortCancelFunc()
+	ctx, cancelFunc = ensureOperationContextHasATimeout(shortCtx, 60)
+	defer cancelFunc()
+	shortDeadline, _ := shortCtx.Deadline()
+	deadline, _ = ctx.Deadline()
+	assert.Equal(t, shortDeadline, deadline)
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package volume
+
+import (
+	"context"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+// reloginOnStaleSession returns true if err shows that the vCenter session
+// of the manager expired while the given operation was in progress and a
+// new session could be created. The caller then replays the operation once,
+// instead of failing the request and relying on the retry of the sidecars.
+func (m *defaultManager) reloginOnStaleSession(ctx context.Context, operation string, err error) bool {
+	if err == nil || !cnsvsphere.IsNotAuthenticatedError(err) {
+		return false
+	}
+	log := logger.GetLogger(ctx)
+	log.Warnf("%s failed as the session to vCenter %q is not authenticated anymore. Logging in again to "+
+		"replay it. Err: %v", operation, m.virtualCenter.Config.Host, err)
+	// Connect creates a new session only if the current one is not valid,
+	// so that concurrent operations failing on the same stale session log
+	// in only once.
+	if connectErr := m.virtualCenter.Connect(ctx); connectErr != nil {
+		log.Errorf("failed to log in again to vCenter %q to replay %s. Err: %v",
+			m.virtualCenter.Config.Host, operation, connectErr)
+		return false
+	}
+	return true
+}
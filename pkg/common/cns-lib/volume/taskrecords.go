@@ -0,0 +1,70 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package volume
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+// taskRecordPollInterval is the interval at which WaitForTaskRecords checks
+// whether all pending task records are persisted.
+const taskRecordPollInterval = 100 * time.Millisecond
+
+// pendingTaskRecords is the number of CNS tasks invoked by the volume
+// managers whose details are not persisted in the operation store yet.
+var pendingTaskRecords atomic.Int64
+
+// trackTaskRecord marks a CNS task as about to be invoked and returns the
+// function to call once the details of the task are persisted in the
+// operation store, or once the task failed to be created. The returned
+// function can be called several times.
+func trackTaskRecord() func() {
+	pendingTaskRecords.Add(1)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			pendingTaskRecords.Add(-1)
+		})
+	}
+}
+
+// WaitForTaskRecords waits until the details of all CNS tasks invoked by the
+// volume managers are persisted in the operation store, so that a restarted
+// driver resumes them instead of invoking them again. It returns an error if
+// ctx is done first.
+func WaitForTaskRecords(ctx context.Context) error {
+	log := logger.GetLogger(ctx)
+	ticker := time.NewTicker(taskRecordPollInterval)
+	defer ticker.Stop()
+	for {
+		pending := pendingTaskRecords.Load()
+		if pending == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return logger.LogNewErrorf(log, "%d CNS task(s) are not persisted in the operation store. Err: %v",
+				pending, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
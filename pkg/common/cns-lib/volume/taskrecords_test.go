@@ -0,0 +1,52 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package volume
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWaitForTaskRecords(t *testing.T) {
+	ctx := context.Background()
+	if err := WaitForTaskRecords(ctx); err != nil {
+		t.Fatalf("expected no error without pending task records, got %v", err)
+	}
+
+	taskRecorded := trackTaskRecord()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*taskRecordPollInterval)
+	defer cancel()
+	if err := WaitForTaskRecords(timeoutCtx); err == nil {
+		t.Fatalf("expected an error while a task record is pending")
+	}
+
+	go func() {
+		time.Sleep(taskRecordPollInterval)
+		taskRecorded()
+		// Recording the task again must not change the pending count.
+		taskRecorded()
+	}()
+	waitCtx, cancelWait := context.WithTimeout(ctx, 10*time.Second)
+	defer cancelWait()
+	if err := WaitForTaskRecords(waitCtx); err != nil {
+		t.Fatalf("expected no error once the task is recorded, got %v", err)
+	}
+	if pending := pendingTaskRecords.Load(); pending != 0 {
+		t.Errorf("expected no pending task records, got %d", pending)
+	}
+}
@@ -353,7 +353,7 @@ func ExtractFaultTypeFromErr(ctx context.Context, err error) string {
 		return vimFaultType
 	}
 	log.Infof("err %+v is not a SoapFault\n", err)
-	return csifault.CSIInternalFault
+	return csifault.FaultTypeFromError(err, csifault.CSIInternalFault)
 }
 
 // ExtractFaultTypeFromVolumeResponseResult extracts the fault type from CnsVolumeOperationResult.
@@ -49,25 +49,8 @@ func (dc *Datacenter) String() string {
 // GetDatastoreInfoByURL returns the *DatastoreInfo instance given its URL.
 func (dc *Datacenter) GetDatastoreInfoByURL(ctx context.Context, datastoreURL string) (*DatastoreInfo, error) {
 	log := logger.GetLogger(ctx)
-	finder := find.NewFinder(dc.Datacenter.Client(), false)
-	finder.SetDatacenter(dc.Datacenter)
-	datastores, err := finder.DatastoreList(ctx, "*")
-	if err != nil {
-		log.Errorf("failed to get all the datastores. err: %+v", err)
-		return nil, err
-	}
-	var dsList []types.ManagedObjectReference
-	for _, ds := range datastores {
-		dsList = append(dsList, ds.Reference())
-	}
-
-	var dsMoList []mo.Datastore
-	pc := property.DefaultCollector(dc.Client())
-	properties := []string{DatastoreInfoProperty, "customValue"}
-	err = pc.Retrieve(ctx, dsList, properties, &dsMoList)
+	dsMoList, err := dc.getDatastoreMoList(ctx)
 	if err != nil {
-		log.Errorf("failed to get Datastore managed objects from datastore objects."+
-			" dsObjList: %+v, properties: %+v, err: %v", dsList, properties, err)
 		return nil, err
 	}
 	for _, dsMo := range dsMoList {
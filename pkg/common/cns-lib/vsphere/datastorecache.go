@@ -0,0 +1,223 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vsphere
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+const (
+	// datastorePropertyRetryInterval is the delay before watching the
+	// datastore properties of vCenter again after the watch failed.
+	datastorePropertyRetryInterval = time.Minute
+
+	cacheLookupHit  = "hit"
+	cacheLookupMiss = "miss"
+)
+
+// datastoreCachedProperties are the datastore properties kept in the
+// datastore property cache.
+var datastoreCachedProperties = []string{DatastoreInfoProperty, "customValue"}
+
+// datastorePropertyCache caches the datastore properties of the datacenters
+// of a vCenter, which GetDatastoreInfoByURL otherwise retrieves from vCenter
+// for every volume placement. Entries are dropped as soon as the property
+// collector of vCenter reports a change of one of their datastores.
+type datastorePropertyCache struct {
+	// mu guards datacenters and generation.
+	mu sync.Mutex
+	// datacenters holds the datastores of the datacenters, keyed by
+	// datacenter MoRef value.
+	datacenters map[string][]mo.Datastore
+	// generation is incremented on every invalidation, so that datastores
+	// retrieved before an invalidation are not cached after it.
+	generation uint64
+}
+
+var (
+	// datastoreCachesLock protects datastoreCaches.
+	datastoreCachesLock sync.RWMutex
+	// datastoreCaches holds the datastore property caches keyed by vCenter
+	// host. A vCenter has a cache only while its datastore properties are
+	// watched.
+	datastoreCaches = make(map[string]*datastorePropertyCache)
+)
+
+// WatchDatastoreProperties caches the datastore properties of the given
+// vCenter used for volume placement, and keeps the cache in sync with the
+// property collector of vCenter.
+func WatchDatastoreProperties(vc *VirtualCenter) {
+	ctx, log := logger.GetNewContextWithLogger()
+	log.Infof("WatchDatastoreProperties entered for vCenter %q", vc.Config.Host)
+	for {
+		err := watchDatastoreProperties(ctx, vc)
+		// Stop using the cache while datastore changes aren't watched.
+		datastoreCachesLock.Lock()
+		delete(datastoreCaches, vc.Config.Host)
+		datastoreCachesLock.Unlock()
+		log.Warnf("watch of datastore properties for vCenter %q exited, restarting in %v. Err: %v",
+			vc.Config.Host, datastorePropertyRetryInterval, err)
+		time.Sleep(datastorePropertyRetryInterval)
+	}
+}
+
+// watchDatastoreProperties enables the datastore property cache of the
+// given vCenter and invalidates its entries on the datastore changes
+// reported by the property collector, until the watch fails.
+func watchDatastoreProperties(ctx context.Context, vc *VirtualCenter) error {
+	log := logger.GetLogger(ctx)
+	if err := vc.Connect(ctx); err != nil {
+		return err
+	}
+	viewManager := view.NewManager(vc.Client.Client)
+	containerView, err := viewManager.CreateContainerView(ctx, vc.Client.ServiceContent.RootFolder,
+		[]string{"Datastore"}, true)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if destroyErr := containerView.Destroy(ctx); destroyErr != nil {
+			log.Debugf("failed to destroy container view of vCenter %q. Err: %v", vc.Config.Host, destroyErr)
+		}
+	}()
+	ts := types.TraversalSpec{
+		Type: "ContainerView",
+		Path: "view",
+		Skip: types.NewBool(false),
+	}
+	filter := new(property.WaitFilter)
+	filter.Add(containerView.Reference(), "Datastore", datastoreCachedProperties, &ts)
+
+	cache := &datastorePropertyCache{datacenters: make(map[string][]mo.Datastore)}
+	datastoreCachesLock.Lock()
+	datastoreCaches[vc.Config.Host] = cache
+	datastoreCachesLock.Unlock()
+	log.Infof("Caching datastore properties of vCenter %q", vc.Config.Host)
+
+	pc := property.DefaultCollector(vc.Client.Client)
+	return property.WaitForUpdatesEx(ctx, pc, filter, func(updates []types.ObjectUpdate) bool {
+		for _, update := range updates {
+			log.Debugf("Got %s update for datastore %v of vCenter %q, invalidating it",
+				update.Kind, update.Obj, vc.Config.Host)
+			cache.invalidate(update.Obj, update.Kind)
+		}
+		return false
+	})
+}
+
+// getDatastoreCache returns the datastore property cache of the given
+// vCenter, or nil if its datastore properties aren't watched.
+func getDatastoreCache(vcHost string) *datastorePropertyCache {
+	datastoreCachesLock.RLock()
+	defer datastoreCachesLock.RUnlock()
+	return datastoreCaches[vcHost]
+}
+
+// get returns the cached datastores of the given datacenter, along with the
+// generation of the cache to pass to set when they aren't cached.
+func (c *datastorePropertyCache) get(dcMoRef string) ([]mo.Datastore, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	datastores, ok := c.datacenters[dcMoRef]
+	return datastores, c.generation, ok
+}
+
+// set caches the datastores of the given datacenter, unless the cache was
+// invalidated since the given generation.
+func (c *datastorePropertyCache) set(dcMoRef string, generation uint64, datastores []mo.Datastore) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation == generation {
+		c.datacenters[dcMoRef] = datastores
+	}
+}
+
+// invalidate drops the datacenters holding the given datastore from the
+// cache. All the datacenters are dropped when a datastore is added, as the
+// datacenter it belongs to is unknown.
+func (c *datastorePropertyCache) invalidate(ds types.ManagedObjectReference, kind types.ObjectUpdateKind) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	if kind == types.ObjectUpdateKindEnter {
+		c.datacenters = make(map[string][]mo.Datastore)
+		return
+	}
+	for dcMoRef, datastores := range c.datacenters {
+		for _, dsMo := range datastores {
+			if dsMo.Reference() == ds {
+				delete(c.datacenters, dcMoRef)
+				break
+			}
+		}
+	}
+}
+
+// getDatastoreMoList returns the datastores of the datacenter with the
+// cached properties, from the datastore property cache if its vCenter has
+// one.
+func (dc *Datacenter) getDatastoreMoList(ctx context.Context) ([]mo.Datastore, error) {
+	log := logger.GetLogger(ctx)
+	cache := getDatastoreCache(dc.VirtualCenterHost)
+	var generation uint64
+	if cache != nil {
+		datastores, cachedGeneration, ok := cache.get(dc.Reference().Value)
+		if ok {
+			prometheus.DatastorePropertyCacheLookupsCounter.WithLabelValues(dc.VirtualCenterHost,
+				cacheLookupHit).Inc()
+			return datastores, nil
+		}
+		generation = cachedGeneration
+		prometheus.DatastorePropertyCacheLookupsCounter.WithLabelValues(dc.VirtualCenterHost,
+			cacheLookupMiss).Inc()
+	}
+
+	finder := find.NewFinder(dc.Datacenter.Client(), false)
+	finder.SetDatacenter(dc.Datacenter)
+	datastores, err := finder.DatastoreList(ctx, "*")
+	if err != nil {
+		log.Errorf("failed to get all the datastores. err: %+v", err)
+		return nil, err
+	}
+	var dsList []types.ManagedObjectReference
+	for _, ds := range datastores {
+		dsList = append(dsList, ds.Reference())
+	}
+	var dsMoList []mo.Datastore
+	pc := property.DefaultCollector(dc.Client())
+	err = pc.Retrieve(ctx, dsList, datastoreCachedProperties, &dsMoList)
+	if err != nil {
+		log.Errorf("failed to get Datastore managed objects from datastore objects."+
+			" dsObjList: %+v, properties: %+v, err: %v", dsList, datastoreCachedProperties, err)
+		return nil, err
+	}
+	if cache != nil {
+		cache.set(dc.Reference().Value, generation, dsMoList)
+	}
+	return dsMoList, nil
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vsphere
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestDatastorePropertyCache(t *testing.T) {
+	newDatastore := func(value string) mo.Datastore {
+		var ds mo.Datastore
+		ds.Self = types.ManagedObjectReference{Type: "Datastore", Value: value}
+		return ds
+	}
+	cache := &datastorePropertyCache{datacenters: make(map[string][]mo.Datastore)}
+
+	_, generation, ok := cache.get("datacenter-1")
+	assert.False(t, ok)
+	cache.set("datacenter-1", generation, []mo.Datastore{newDatastore("datastore-1")})
+	_, generation, _ = cache.get("datacenter-2")
+	cache.set("datacenter-2", generation, []mo.Datastore{newDatastore("datastore-2")})
+
+	// A change of a datastore only drops the datacenter holding it.
+	cache.invalidate(newDatastore("datastore-1").Self, types.ObjectUpdateKindModify)
+	_, _, ok = cache.get("datacenter-1")
+	assert.False(t, ok)
+	datastores, staleGeneration, ok := cache.get("datacenter-2")
+	assert.True(t, ok)
+	assert.Len(t, datastores, 1)
+
+	// Datastores retrieved before an invalidation aren't cached.
+	cache.invalidate(newDatastore("datastore-3").Self, types.ObjectUpdateKindEnter)
+	cache.set("datacenter-1", staleGeneration, []mo.Datastore{newDatastore("datastore-1")})
+	_, _, ok = cache.get("datacenter-1")
+	assert.False(t, ok)
+	_, _, ok = cache.get("datacenter-2")
+	assert.False(t, ok)
+}
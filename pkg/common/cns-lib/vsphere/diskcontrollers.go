@@ -0,0 +1,192 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vsphere
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+const (
+	// MaxSCSIControllersPerVM is the number of SCSI controllers a VM can have.
+	MaxSCSIControllersPerVM = 4
+	// MaxDisksPerSCSIController is the number of disks on a SCSI controller,
+	// excluding the unit reserved for the controller itself.
+	MaxDisksPerSCSIController = 15
+	// MaxDisksPerPVSCSIController is the number of disks on a PVSCSI
+	// controller supporting 64 targets.
+	MaxDisksPerPVSCSIController = 63
+	// MaxNVMeControllersPerVM is the number of NVMe controllers a VM can have.
+	MaxNVMeControllersPerVM = 4
+	// MaxDisksPerNVMeController is the number of disks on an NVMe controller.
+	MaxDisksPerNVMeController = 15
+	// MaxPCIDevicesPerVM is the number of devices the virtual PCI controller
+	// of a VM can hold, each taking a PCI slot.
+	MaxPCIDevicesPerVM = 32
+)
+
+// ErrNoFreeDiskSlots is returned when a VM has no free unit left on its disk
+// controllers for a new disk.
+var ErrNoFreeDiskSlots = errors.New("no free disk slots")
+
+// DiskControllerSlots is the disk slot usage of a disk controller of a VM.
+type DiskControllerSlots struct {
+	// Key is the device key of the controller.
+	Key int32
+	// Type is the device type name of the controller.
+	Type string
+	// Capacity is the number of disks the controller can hold.
+	Capacity int
+	// Used is the number of disks on the controller.
+	Used int
+}
+
+// Free returns the number of disks that can still be added to the controller.
+func (s DiskControllerSlots) Free() int {
+	return max(s.Capacity-s.Used, 0)
+}
+
+// GetDiskControllerSlots returns the slot usage of the SCSI and NVMe
+// controllers among the given devices.
+func GetDiskControllerSlots(devices object.VirtualDeviceList) []DiskControllerSlots {
+	var slots []DiskControllerSlots
+	for _, device := range devices {
+		var capacity int
+		var used []int32
+		switch controller := device.(type) {
+		case *types.ParaVirtualSCSIController:
+			capacity, used = MaxDisksPerPVSCSIController, controller.Device
+		case types.BaseVirtualSCSIController:
+			capacity, used = MaxDisksPerSCSIController, controller.GetVirtualSCSIController().Device
+		case *types.VirtualNVMEController:
+			capacity, used = MaxDisksPerNVMeController, controller.Device
+		default:
+			continue
+		}
+		slots = append(slots, DiskControllerSlots{
+			Key:      device.GetVirtualDevice().Key,
+			Type:     devices.TypeName(device),
+			Capacity: capacity,
+			Used:     len(used),
+		})
+	}
+	return slots
+}
+
+// GetFreePCISlots returns the number of devices that can still be added to
+// the PCI controller among the given devices.
+func GetFreePCISlots(devices object.VirtualDeviceList) int {
+	for _, device := range devices {
+		if controller, ok := device.(*types.VirtualPCIController); ok {
+			return max(MaxPCIDevicesPerVM-len(controller.Device), 0)
+		}
+	}
+	return MaxPCIDevicesPerVM
+}
+
+// CheckFreeSCSIDiskSlots checks that count more disks can be attached to the
+// SCSI controllers among the given devices. The disks which don't fit on the
+// existing controllers need new PVSCSI controllers, each of which needs to
+// stay within the controller limit of the VM and to take a free PCI slot.
+// The returned error wraps ErrNoFreeDiskSlots.
+func CheckFreeSCSIDiskSlots(devices object.VirtualDeviceList, count int) error {
+	var freeUnits, numControllers int
+	for _, slot := range GetDiskControllerSlots(devices) {
+		if slot.Type == "VirtualNVMEController" {
+			continue
+		}
+		numControllers++
+		freeUnits += slot.Free()
+	}
+	if count <= freeUnits {
+		return nil
+	}
+	newControllers := (count - freeUnits + MaxDisksPerPVSCSIController - 1) / MaxDisksPerPVSCSIController
+	if numControllers+newControllers > MaxSCSIControllersPerVM {
+		return fmt.Errorf("%w: %d free units on %d SCSI controllers, %d more controllers are needed "+
+			"for %d disks", ErrNoFreeDiskSlots, freeUnits, numControllers, newControllers, count)
+	}
+	if freePCISlots := GetFreePCISlots(devices); freePCISlots < newControllers {
+		return fmt.Errorf("%w: %d free PCI slots, %d are needed for the SCSI controllers of %d disks",
+			ErrNoFreeDiskSlots, freePCISlots, newControllers, count)
+	}
+	return nil
+}
+
+// EnsureFreeDiskSlot checks that a disk can be attached to the VM. If every
+// disk controller of the VM is full, a new controller is added when
+// addController is true and the VM is below its controller limit, otherwise
+// an error wrapping ErrNoFreeDiskSlots is returned. The new controller is an
+// NVMe controller if the VM only has NVMe controllers, and a PVSCSI
+// controller otherwise.
+func (vm *VirtualMachine) EnsureFreeDiskSlot(ctx context.Context, addController bool) error {
+	log := logger.GetLogger(ctx)
+	devices, err := vm.Device(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get devices of VM %q. Err: %v", vm.String(), err)
+	}
+	var numSCSI, numNVMe int
+	for _, slot := range GetDiskControllerSlots(devices) {
+		log.Debugf("Controller %d (%s) of VM %q has %d of %d disk slots used", slot.Key, slot.Type,
+			vm.String(), slot.Used, slot.Capacity)
+		if slot.Free() > 0 {
+			return nil
+		}
+		if slot.Type == "VirtualNVMEController" {
+			numNVMe++
+		} else {
+			numSCSI++
+		}
+	}
+
+	var controller types.BaseVirtualDevice
+	if numNVMe > 0 && numSCSI == 0 {
+		if numNVMe >= MaxNVMeControllersPerVM {
+			return fmt.Errorf("%w on VM %q: all %d NVMe controllers are full", ErrNoFreeDiskSlots,
+				vm.String(), numNVMe)
+		}
+		if addController {
+			controller, err = devices.CreateNVMEController()
+		}
+	} else {
+		if numSCSI >= MaxSCSIControllersPerVM {
+			return fmt.Errorf("%w on VM %q: all %d SCSI controllers are full", ErrNoFreeDiskSlots,
+				vm.String(), numSCSI)
+		}
+		if addController {
+			controller, err = devices.CreateSCSIController("pvscsi")
+		}
+	}
+	if !addController {
+		return fmt.Errorf("%w on VM %q: all %d disk controllers are full", ErrNoFreeDiskSlots,
+			vm.String(), numSCSI+numNVMe)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create disk controller for VM %q. Err: %v", vm.String(), err)
+	}
+	log.Infof("All disk controllers of VM %q are full, adding a %s", vm.String(), devices.TypeName(controller))
+	if err := vm.AddDevice(ctx, controller); err != nil {
+		return fmt.Errorf("failed to add %s to VM %q. Err: %v", devices.TypeName(controller), vm.String(), err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vsphere
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestGetDiskControllerSlots(t *testing.T) {
+	pvscsi := &types.ParaVirtualSCSIController{}
+	pvscsi.Key = 1000
+	pvscsi.Device = []int32{2000, 2001}
+	lsiLogic := &types.VirtualLsiLogicController{}
+	lsiLogic.Key = 1001
+	lsiLogic.Device = make([]int32, MaxDisksPerSCSIController)
+	nvme := &types.VirtualNVMEController{}
+	nvme.Key = 31000
+	devices := object.VirtualDeviceList{pvscsi, lsiLogic, nvme, &types.VirtualDisk{}}
+
+	slots := GetDiskControllerSlots(devices)
+	assert.Equal(t, []DiskControllerSlots{
+		{Key: 1000, Type: "ParaVirtualSCSIController", Capacity: MaxDisksPerPVSCSIController, Used: 2},
+		{Key: 1001, Type: "VirtualLsiLogicController", Capacity: MaxDisksPerSCSIController, Used: MaxDisksPerSCSIController},
+		{Key: 31000, Type: "VirtualNVMEController", Capacity: MaxDisksPerNVMeController, Used: 0},
+	}, slots)
+	assert.Equal(t, MaxDisksPerPVSCSIController-2, slots[0].Free())
+	assert.Equal(t, 0, slots[1].Free())
+}
+
+func TestCheckFreeSCSIDiskSlots(t *testing.T) {
+	pvscsi := &types.ParaVirtualSCSIController{}
+	pvscsi.Device = make([]int32, MaxDisksPerPVSCSIController-2)
+	pci := &types.VirtualPCIController{}
+	pci.Device = make([]int32, MaxPCIDevicesPerVM-1)
+	devices := object.VirtualDeviceList{pci, pvscsi}
+
+	assert.Equal(t, 1, GetFreePCISlots(devices))
+	// The disks fit on the existing controller.
+	assert.NoError(t, CheckFreeSCSIDiskSlots(devices, 2))
+	// A new controller is needed, taking the last PCI slot.
+	assert.NoError(t, CheckFreeSCSIDiskSlots(devices, 3))
+	// Two new controllers are needed, with a single PCI slot left.
+	assert.ErrorIs(t, CheckFreeSCSIDiskSlots(devices, MaxDisksPerPVSCSIController+3), ErrNoFreeDiskSlots)
+
+	// Controllers are limited per VM regardless of the PCI slots.
+	devices = object.VirtualDeviceList{&types.VirtualPCIController{}}
+	for i := 0; i < MaxSCSIControllersPerVM; i++ {
+		full := &types.ParaVirtualSCSIController{}
+		full.Device = make([]int32, MaxDisksPerPVSCSIController)
+		devices = append(devices, full)
+	}
+	assert.ErrorIs(t, CheckFreeSCSIDiskSlots(devices, 1), ErrNoFreeDiskSlots)
+}
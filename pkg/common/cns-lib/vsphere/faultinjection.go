@@ -0,0 +1,188 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vmware/govmomi/vim25/soap"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+const (
+	// EnvCnsFaultInjection holds the fault injection rules for CNS calls.
+	// It is meant for resilience testing in CI and must not be set in
+	// production. The value is a ';' separated list of rules of the form
+	//   <operation>:<percentage>:fail
+	//   <operation>:<percentage>:delay:<duration>
+	// where <operation> is the CNS method name (e.g. CnsCreateVolume,
+	// CnsQueryVolume) or "*" for all methods.
+	// Example: "CnsAttachVolume:20:fail;CnsQueryVolume:50:delay:5s"
+	EnvCnsFaultInjection = "X_CSI_CNS_FAULT_INJECTION"
+
+	faultActionFail  = "fail"
+	faultActionDelay = "delay"
+	faultAnyOp       = "*"
+)
+
+// faultRule describes a fault to inject into a percentage of calls for an
+// operation.
+type faultRule struct {
+	operation  string
+	percentage int
+	action     string
+	delay      time.Duration
+}
+
+// FaultInjectionRoundTripper delays or fails a percentage of the calls made
+// through the wrapped round tripper, based on the configured rules.
+type FaultInjectionRoundTripper struct {
+	roundTripper soap.RoundTripper
+	rules        []faultRule
+	randLock     sync.Mutex
+	rand         *rand.Rand
+}
+
+// parseFaultRules parses fault injection rules in the format documented on
+// EnvCnsFaultInjection.
+func parseFaultRules(spec string) ([]faultRule, error) {
+	var rules []faultRule
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		fields := strings.Split(entry, ":")
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("invalid fault injection rule %q", entry)
+		}
+		percentage, err := strconv.Atoi(fields[1])
+		if err != nil || percentage < 0 || percentage > 100 {
+			return nil, fmt.Errorf("invalid percentage in fault injection rule %q", entry)
+		}
+		rule := faultRule{
+			operation:  fields[0],
+			percentage: percentage,
+			action:     fields[2],
+		}
+		switch rule.action {
+		case faultActionFail:
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("invalid fault injection rule %q", entry)
+			}
+		case faultActionDelay:
+			if len(fields) != 4 {
+				return nil, fmt.Errorf("delay is missing in fault injection rule %q", entry)
+			}
+			rule.delay, err = time.ParseDuration(fields[3])
+			if err != nil {
+				return nil, fmt.Errorf("invalid delay in fault injection rule %q. Err: %v", entry, err)
+			}
+		default:
+			return nil, fmt.Errorf("unknown action %q in fault injection rule %q", rule.action, entry)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// wrapWithFaultInjection wraps rt with a FaultInjectionRoundTripper if fault
+// injection rules are configured through EnvCnsFaultInjection. rt is
+// returned as is otherwise.
+func wrapWithFaultInjection(ctx context.Context, rt soap.RoundTripper) soap.RoundTripper {
+	log := logger.GetLogger(ctx)
+	spec := os.Getenv(EnvCnsFaultInjection)
+	if spec == "" {
+		return rt
+	}
+	rules, err := parseFaultRules(spec)
+	if err != nil {
+		log.Errorf("ignoring fault injection rules %q. Err: %v", spec, err)
+		return rt
+	}
+	if len(rules) == 0 {
+		return rt
+	}
+	log.Warnf("CNS fault injection is enabled with rules: %q", spec)
+	return &FaultInjectionRoundTripper{
+		roundTripper: rt,
+		rules:        rules,
+		rand:         rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// hit returns true if a call should be affected by a rule with the given
+// percentage.
+func (f *FaultInjectionRoundTripper) hit(percentage int) bool {
+	f.randLock.Lock()
+	defer f.randLock.Unlock()
+	return f.rand.Intn(100) < percentage
+}
+
+// RoundTrip injects configured faults before delegating to the wrapped
+// round tripper.
+func (f *FaultInjectionRoundTripper) RoundTrip(ctx context.Context, req, resp soap.HasFault) error {
+	log := logger.GetLogger(ctx)
+	operation := requestOperationName(req)
+	for _, rule := range f.rules {
+		if rule.operation != faultAnyOp && rule.operation != operation {
+			continue
+		}
+		if !f.hit(rule.percentage) {
+			continue
+		}
+		switch rule.action {
+		case faultActionFail:
+			log.Infof("fault injection: failing %s", operation)
+			return fmt.Errorf("injected fault for %s", operation)
+		case faultActionDelay:
+			log.Infof("fault injection: delaying %s by %v", operation, rule.delay)
+			select {
+			case <-time.After(rule.delay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	return f.roundTripper.RoundTrip(ctx, req, resp)
+}
+
+// requestOperationName returns the vSphere method name for a SOAP request,
+// e.g. "CnsCreateVolume" for a CnsCreateVolumeRequestType request.
+func requestOperationName(req soap.HasFault) string {
+	v := reflect.ValueOf(req)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+	field := v.FieldByName("Req")
+	if !field.IsValid() || field.IsNil() {
+		return ""
+	}
+	return strings.TrimSuffix(field.Elem().Type().Name(), "RequestType")
+}
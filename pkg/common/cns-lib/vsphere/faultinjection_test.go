@@ -0,0 +1,81 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vsphere
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	cnsmethods "github.com/vmware/govmomi/cns/methods"
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	"github.com/vmware/govmomi/vim25/soap"
+)
+
+type countingRoundTripper struct {
+	calls int
+}
+
+func (c *countingRoundTripper) RoundTrip(ctx context.Context, req, resp soap.HasFault) error {
+	c.calls++
+	return nil
+}
+
+func TestParseFaultRules(t *testing.T) {
+	rules, err := parseFaultRules("CnsAttachVolume:20:fail; CnsQueryVolume:50:delay:5s;")
+	assert.Nil(t, err)
+	assert.Equal(t, []faultRule{
+		{operation: "CnsAttachVolume", percentage: 20, action: faultActionFail},
+		{operation: "CnsQueryVolume", percentage: 50, action: faultActionDelay, delay: 5 * time.Second},
+	}, rules)
+
+	for _, spec := range []string{
+		"CnsAttachVolume:20",
+		"CnsAttachVolume:120:fail",
+		"CnsAttachVolume:20:explode",
+		"CnsAttachVolume:20:delay",
+		"CnsAttachVolume:20:delay:soon",
+		"CnsAttachVolume:20:fail:5s",
+	} {
+		_, err := parseFaultRules(spec)
+		assert.NotNil(t, err, spec)
+	}
+}
+
+func TestFaultInjectionRoundTripper(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv(EnvCnsFaultInjection, "CnsCreateVolume:100:fail")
+	inner := &countingRoundTripper{}
+	rt := wrapWithFaultInjection(ctx, inner)
+
+	createReq := &cnsmethods.CnsCreateVolumeBody{Req: &cnstypes.CnsCreateVolume{}}
+	err := rt.RoundTrip(ctx, createReq, &cnsmethods.CnsCreateVolumeBody{})
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, inner.calls)
+
+	deleteReq := &cnsmethods.CnsDeleteVolumeBody{Req: &cnstypes.CnsDeleteVolume{}}
+	err = rt.RoundTrip(ctx, deleteReq, &cnsmethods.CnsDeleteVolumeBody{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, inner.calls)
+}
+
+func TestFaultInjectionDisabled(t *testing.T) {
+	t.Setenv(EnvCnsFaultInjection, "")
+	inner := &countingRoundTripper{}
+	assert.Equal(t, soap.RoundTripper(inner), wrapWithFaultInjection(context.Background(), inner))
+}
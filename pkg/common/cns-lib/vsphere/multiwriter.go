@@ -0,0 +1,105 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vsphere
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vmware/govmomi/vim25/types"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+// AttachMultiWriterDisk attaches the disk file at the given datastore path to
+// the VM with the multi-writer sharing mode, which lets other VMs attach the
+// disk at the same time. Multi-writer disks are only supported on SCSI
+// controllers. If the disk is already attached to the VM, its sharing mode is
+// set to multi-writer if needed. The UUID of the disk is returned.
+func (vm *VirtualMachine) AttachMultiWriterDisk(ctx context.Context, datastore types.ManagedObjectReference,
+	diskPath string) (string, error) {
+	log := logger.GetLogger(ctx)
+	if disk, err := vm.getDiskByPath(ctx, diskPath); err != nil {
+		return "", err
+	} else if disk != nil {
+		backing := disk.Backing.(*types.VirtualDiskFlatVer2BackingInfo)
+		if backing.Sharing == string(types.VirtualDiskSharingSharingMultiWriter) {
+			log.Debugf("Disk %q is already attached to VM %q in multi-writer mode", diskPath, vm.String())
+			return backing.Uuid, nil
+		}
+		backing.Sharing = string(types.VirtualDiskSharingSharingMultiWriter)
+		// The device is edited without file operation, for the disk file to
+		// be kept as is.
+		spec := types.VirtualMachineConfigSpec{DeviceChange: []types.BaseVirtualDeviceConfigSpec{
+			&types.VirtualDeviceConfigSpec{Operation: types.VirtualDeviceConfigSpecOperationEdit, Device: disk},
+		}}
+		task, err := vm.Reconfigure(ctx, spec)
+		if err == nil {
+			err = task.Wait(ctx)
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to set the sharing mode of disk %q on VM %q to multi-writer. Err: %v",
+				diskPath, vm.String(), err)
+		}
+		log.Infof("Set the sharing mode of disk %q on VM %q to multi-writer", diskPath, vm.String())
+		return backing.Uuid, nil
+	}
+
+	devices, err := vm.Device(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get devices of VM %q. Err: %v", vm.String(), err)
+	}
+	controller := devices.PickController((*types.VirtualSCSIController)(nil))
+	if controller == nil {
+		return "", fmt.Errorf("%w on VM %q: no SCSI controller has a free unit for multi-writer disk %q",
+			ErrNoFreeDiskSlots, vm.String(), diskPath)
+	}
+	disk := devices.CreateDisk(controller, datastore, diskPath)
+	backing := disk.Backing.(*types.VirtualDiskFlatVer2BackingInfo)
+	// The provisioning type is the one of the existing disk file.
+	backing.ThinProvisioned = nil
+	backing.Sharing = string(types.VirtualDiskSharingSharingMultiWriter)
+	if err := vm.AddDevice(ctx, disk); err != nil {
+		return "", fmt.Errorf("failed to attach disk %q to VM %q in multi-writer mode. Err: %v",
+			diskPath, vm.String(), err)
+	}
+	attached, err := vm.getDiskByPath(ctx, diskPath)
+	if err != nil {
+		return "", err
+	}
+	if attached == nil {
+		return "", fmt.Errorf("disk %q not found on VM %q after attaching it", diskPath, vm.String())
+	}
+	log.Infof("Attached disk %q to VM %q in multi-writer mode", diskPath, vm.String())
+	return attached.Backing.(*types.VirtualDiskFlatVer2BackingInfo).Uuid, nil
+}
+
+// getDiskByPath returns the disk of the VM backed by the disk file at the
+// given datastore path, or nil if the disk isn't attached to the VM.
+func (vm *VirtualMachine) getDiskByPath(ctx context.Context, diskPath string) (*types.VirtualDisk, error) {
+	devices, err := vm.Device(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get devices of VM %q. Err: %v", vm.String(), err)
+	}
+	for _, device := range devices.SelectByType((*types.VirtualDisk)(nil)) {
+		disk := device.(*types.VirtualDisk)
+		if backing, ok := disk.Backing.(*types.VirtualDiskFlatVer2BackingInfo); ok && backing.FileName == diskPath {
+			return disk, nil
+		}
+	}
+	return nil, nil
+}
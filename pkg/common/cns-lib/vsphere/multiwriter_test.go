@@ -0,0 +1,91 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vsphere
+
+import (
+	"context"
+	"testing"
+
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestAttachMultiWriterDiskToTwoVMs(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		finder := find.NewFinder(c)
+		dc, err := finder.DefaultDatacenter(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		finder.SetDatacenter(dc)
+		ds, err := finder.DefaultDatastore(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vms, err := finder.VirtualMachineList(ctx, "*")
+		if err != nil || len(vms) < 2 {
+			t.Fatalf("expected at least 2 VMs, got %d. Error: %v", len(vms), err)
+		}
+		diskPath := ds.Path("multiwriter.vmdk")
+		task, err := object.NewVirtualDiskManager(c).CreateVirtualDisk(ctx, diskPath, dc,
+			&types.FileBackedVirtualDiskSpec{
+				VirtualDiskSpec: types.VirtualDiskSpec{
+					DiskType:    string(types.VirtualDiskTypeEagerZeroedThick),
+					AdapterType: string(types.VirtualDiskAdapterTypeLsiLogic),
+				},
+				CapacityKb: 1024,
+			})
+		if err == nil {
+			err = task.Wait(ctx)
+		}
+		if err != nil {
+			t.Fatalf("failed to create disk %q. Error: %v", diskPath, err)
+		}
+
+		for _, objectVM := range vms[:2] {
+			vm := &VirtualMachine{VirtualMachine: objectVM}
+			// Attaching again is a no-op.
+			for i := 0; i < 2; i++ {
+				if _, err := vm.AttachMultiWriterDisk(ctx, ds.Reference(), diskPath); err != nil {
+					t.Fatalf("failed to attach disk to VM %q. Error: %v", vm.String(), err)
+				}
+			}
+			devices, err := vm.Device(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var attached int
+			for _, device := range devices.SelectByType((*types.VirtualDisk)(nil)) {
+				backing := device.(*types.VirtualDisk).Backing.(*types.VirtualDiskFlatVer2BackingInfo)
+				if backing.FileName != diskPath {
+					continue
+				}
+				attached++
+				if backing.Sharing != string(types.VirtualDiskSharingSharingMultiWriter) {
+					t.Errorf("expected disk on VM %q to be shared in multi-writer mode, got %q", vm.String(),
+						backing.Sharing)
+				}
+			}
+			if attached != 1 {
+				t.Errorf("expected disk to be attached once to VM %q, got %d", vm.String(), attached)
+			}
+		}
+	})
+}
@@ -152,6 +152,37 @@ func (vc *VirtualCenter) PbmRetrieveContent(ctx context.Context, policyIds []str
 	return simplifyProfileStructs(ctx, profiles), err
 }
 
+// PbmQueryStorageProfiles returns the storage requirement profiles, i.e. the
+// storage policies, defined in vCenter.
+func (vc *VirtualCenter) PbmQueryStorageProfiles(ctx context.Context) ([]*pbmtypes.PbmCapabilityProfile, error) {
+	log := logger.GetLogger(ctx)
+	err := vc.ConnectPbm(ctx)
+	if err != nil {
+		log.Errorf("Error occurred while connecting to PBM, err: %+v", err)
+		return nil, err
+	}
+	profileIds, err := vc.PbmClient.QueryProfile(ctx, pbmtypes.PbmProfileResourceType{
+		ResourceType: string(pbmtypes.PbmProfileResourceTypeEnumSTORAGE),
+	}, string(pbmtypes.PbmProfileCategoryEnumREQUIREMENT))
+	if err != nil {
+		return nil, err
+	}
+	if len(profileIds) == 0 {
+		return nil, nil
+	}
+	profiles, err := vc.PbmClient.RetrieveContent(ctx, profileIds)
+	if err != nil {
+		return nil, err
+	}
+	capabilityProfiles := make([]*pbmtypes.PbmCapabilityProfile, 0, len(profiles))
+	for _, profile := range profiles {
+		if capabilityProfile, ok := profile.(*pbmtypes.PbmCapabilityProfile); ok {
+			capabilityProfiles = append(capabilityProfiles, capabilityProfile)
+		}
+	}
+	return capabilityProfiles, nil
+}
+
 func simplifyProfileStructs(ctx context.Context, profiles []pbmtypes.BasePbmProfile) []SpbmPolicyContent {
 	log := logger.GetLogger(ctx)
 	out := make([]SpbmPolicyContent, 0)
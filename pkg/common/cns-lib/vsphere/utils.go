@@ -60,6 +60,18 @@ func IsNotFoundError(err error) bool {
 	return isNotFoundError
 }
 
+// IsNotAuthenticatedError checks if err is the NotAuthenticated fault, which
+// vCenter returns once the session of the client expired or was terminated.
+func IsNotAuthenticatedError(err error) bool {
+	isNotAuthenticatedError := false
+	if soap.IsSoapFault(err) {
+		_, isNotAuthenticatedError = soap.ToSoapFault(err).VimFault().(types.NotAuthenticated)
+	} else if soap.IsVimFault(err) {
+		_, isNotAuthenticatedError = soap.ToVimFault(err).(*types.NotAuthenticated)
+	}
+	return isNotAuthenticatedError
+}
+
 // IsAlreadyExists checks if err is the AlreadyExists fault.
 // If the error is AlreadyExists fault, the method returns true along with the
 // name of the managed object. Otherwise, returns false.
@@ -173,6 +185,23 @@ func CreateCnsKuberenetesEntityReference(entityType string, entityName string,
 	}
 }
 
+// getOperationTimeouts returns the CNS operation timeouts set in the Global
+// section of the vSphere Configuration.
+func getOperationTimeouts(cfg *config.Config) OperationTimeouts {
+	return OperationTimeouts{
+		CreateVolume:   cfg.Global.CreateVolumeTimeoutInSeconds,
+		DeleteVolume:   cfg.Global.DeleteVolumeTimeoutInSeconds,
+		AttachVolume:   cfg.Global.AttachVolumeTimeoutInSeconds,
+		DetachVolume:   cfg.Global.DetachVolumeTimeoutInSeconds,
+		UpdateVolume:   cfg.Global.UpdateVolumeTimeoutInSeconds,
+		ExpandVolume:   cfg.Global.ExpandVolumeTimeoutInSeconds,
+		RelocateVolume: cfg.Global.RelocateVolumeTimeoutInSeconds,
+		ConfigureACLs:  cfg.Global.ConfigureACLsTimeoutInSeconds,
+		Snapshot:       cfg.Global.SnapshotTimeoutInSeconds,
+		Query:          cfg.Global.QueryTimeoutInSeconds,
+	}
+}
+
 // GetVirtualCenterConfig returns VirtualCenterConfig Object created using
 // vSphere Configuration specified in the argument.
 func GetVirtualCenterConfig(ctx context.Context, cfg *config.Config) (*VirtualCenterConfig, error) {
@@ -218,6 +247,7 @@ func GetVirtualCenterConfig(ctx context.Context, cfg *config.Config) (*VirtualCe
 		Insecure:                    cfg.VirtualCenter[host].InsecureFlag,
 		TargetvSANFileShareClusters: targetvSANClustersForFile,
 		VCClientTimeout:             vcClientTimeout,
+		OperationTimeouts:           getOperationTimeouts(cfg),
 		QueryLimit:                  cfg.Global.QueryLimit,
 		ListVolumeThreshold:         cfg.Global.ListVolumeThreshold,
 		MigrationDataStoreURL:       cfg.VirtualCenter[host].MigrationDataStoreURL,
@@ -275,6 +305,7 @@ func GetVirtualCenterConfigs(ctx context.Context, cfg *config.Config) ([]*Virtua
 			Insecure:                    cfg.VirtualCenter[vCenterIP].InsecureFlag,
 			TargetvSANFileShareClusters: targetvSANClustersForFile,
 			VCClientTimeout:             vcClientTimeout,
+			OperationTimeouts:           getOperationTimeouts(cfg),
 			QueryLimit:                  cfg.Global.QueryLimit,
 			ListVolumeThreshold:         cfg.Global.ListVolumeThreshold,
 		}
@@ -2,10 +2,12 @@ package vsphere
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/soap"
 	"github.com/vmware/govmomi/vim25/types"
 )
 
@@ -61,3 +63,12 @@ func TestFilterSuspendedDatastoresWhenDatastoreIsNotSuspended(t *testing.T) {
 	assert.Equal(t, 1, len(outputDsInfo))
 
 }
+
+func TestIsNotAuthenticatedError(t *testing.T) {
+	fault := &soap.Fault{String: "The session is not authenticated."}
+	fault.Detail.Fault = types.NotAuthenticated{}
+	assert.True(t, IsNotAuthenticatedError(soap.WrapSoapFault(fault)))
+	assert.True(t, IsNotAuthenticatedError(soap.WrapVimFault(&types.NotAuthenticated{})))
+	assert.False(t, IsNotAuthenticatedError(soap.WrapVimFault(&types.NotFound{})))
+	assert.False(t, IsNotAuthenticatedError(errors.New("not authenticated")))
+}
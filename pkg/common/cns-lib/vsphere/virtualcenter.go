@@ -136,6 +136,9 @@ type VirtualCenterConfig struct {
 	TargetvSANFileShareClusters []string
 	// VCClientTimeout is the limit in minutes for requests made by vCenter client.
 	VCClientTimeout int
+	// OperationTimeouts are the limits of CNS operations made by the volume
+	// manager. The limit of the caller applies if it is shorter.
+	OperationTimeouts OperationTimeouts
 	// QueryLimit specifies the number of volumes that can be fetched by CNS
 	// QueryAll API at a time
 	QueryLimit int
@@ -150,6 +153,22 @@ type VirtualCenterConfig struct {
 	ReloadVCConfigForNewClient bool
 }
 
+// OperationTimeouts holds the time limits, in seconds, of CNS operations.
+// UpdateVolume limits the updates of both the metadata and the storage policy
+// of volumes.
+type OperationTimeouts struct {
+	CreateVolume   int
+	DeleteVolume   int
+	AttachVolume   int
+	DetachVolume   int
+	UpdateVolume   int
+	ExpandVolume   int
+	RelocateVolume int
+	ConfigureACLs  int
+	Snapshot       int
+	Query          int
+}
+
 // NewClient creates a new govmomi Client instance.
 func (vc *VirtualCenter) NewClient(ctx context.Context, useragent string) (*govmomi.Client, error) {
 	log := logger.GetLogger(ctx)
@@ -280,6 +280,31 @@ func (vm *VirtualMachine) GetAncestors(ctx context.Context) ([]mo.ManagedEntity,
 	return objects, nil
 }
 
+// GetHostAndClusterName returns the names of the ESXi host running the VM
+// and of the vSphere cluster the host belongs to. clusterName is empty for
+// standalone hosts.
+func (vm *VirtualMachine) GetHostAndClusterName(ctx context.Context) (hostName string, clusterName string,
+	err error) {
+	log := logger.GetLogger(ctx)
+	objects, err := vm.GetAncestors(ctx)
+	if err != nil {
+		log.Errorf("GetAncestors failed for %s with err %v", vm.Reference(), err)
+		return "", "", err
+	}
+	for _, obj := range objects {
+		switch obj.Self.Type {
+		case "HostSystem":
+			hostName = obj.Name
+		case "ClusterComputeResource":
+			clusterName = obj.Name
+		}
+	}
+	if hostName == "" {
+		return "", "", fmt.Errorf("failed to find host system in ancestors of vm: %v", vm)
+	}
+	return hostName, clusterName, nil
+}
+
 // GetZoneRegion returns zone and region of the node vm.
 func (vm *VirtualMachine) GetZoneRegion(ctx context.Context, zoneCategoryName string,
 	regionCategoryName string, tagManager *tags.Manager) (zone string, region string, err error) {
@@ -24,11 +24,13 @@ import (
 	"testing"
 
 	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
 	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/simulator"
 	"github.com/vmware/govmomi/vapi/rest"
 	_ "github.com/vmware/govmomi/vapi/simulator"
 	"github.com/vmware/govmomi/vapi/tags"
+	"github.com/vmware/govmomi/vim25"
 )
 
 var (
@@ -180,3 +182,53 @@ func TestIsInZoneRegion(t *testing.T) {
 		t.Fatalf("VM should belong to specified zone and region")
 	}
 }
+
+// TestGetHostAndClusterName checks the host and cluster names of a VM on a
+// cluster host and of a VM on a standalone host of the default vcsim
+// inventory.
+func TestGetHostAndClusterName(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		finder := find.NewFinder(c)
+		dc, err := finder.DefaultDatacenter(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		finder.SetDatacenter(dc)
+		tests := []struct {
+			vmName          string
+			expectedHost    string
+			expectedCluster string
+		}{
+			{vmName: "DC0_C0_RP0_VM0", expectedCluster: "DC0_C0"},
+			{vmName: "DC0_H0_VM0", expectedHost: "DC0_H0"},
+		}
+		for _, test := range tests {
+			objectVM, err := finder.VirtualMachine(ctx, test.vmName)
+			if err != nil {
+				t.Fatalf("failed to find VM %q. Error: %v", test.vmName, err)
+			}
+			vm := &VirtualMachine{
+				Datacenter:     &Datacenter{Datacenter: dc},
+				VirtualMachine: objectVM,
+			}
+			expectedHost := test.expectedHost
+			if expectedHost == "" {
+				host, err := objectVM.HostSystem(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if expectedHost, err = host.ObjectName(ctx); err != nil {
+					t.Fatal(err)
+				}
+			}
+			hostName, clusterName, err := vm.GetHostAndClusterName(ctx)
+			if err != nil {
+				t.Fatalf("failed to get host and cluster name of VM %q. Error: %v", test.vmName, err)
+			}
+			if hostName != expectedHost || clusterName != test.expectedCluster {
+				t.Errorf("expected host %q and cluster %q for VM %q, got host %q and cluster %q",
+					expectedHost, test.expectedCluster, test.vmName, hostName, clusterName)
+			}
+		}
+	})
+}
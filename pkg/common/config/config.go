@@ -17,10 +17,12 @@ limitations under the License.
 package config
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
 	"regexp"
 	"strconv"
@@ -92,6 +94,43 @@ const (
 	// DefaultListVolumeThreshold specifies the default maximum number of differences in volumes between CNS
 	// and kubernetes
 	DefaultListVolumeThreshold = 50
+	// MaxVolumeSizeInGb is the maximum size of a block volume, 62 TiB.
+	MaxVolumeSizeInGb = 62 * 1024
+	// DefaultArchiveRetentionInHours is the default time archived volumes are
+	// kept before they are permanently deleted.
+	DefaultArchiveRetentionInHours = 168
+	// DefaultSnapshotRetentionInHours is the default time volumes retained
+	// with a safety snapshot are kept before they are permanently deleted.
+	DefaultSnapshotRetentionInHours = 168
+	// DefaultRecentSnapshotWindowInHours is the default time after the last
+	// snapshot of a volume during which its deletion is deferred.
+	DefaultRecentSnapshotWindowInHours = 24
+	// DefaultPolicyEngineTimeoutInSeconds is the default time limit of the
+	// review requests sent to the policy service.
+	DefaultPolicyEngineTimeoutInSeconds = 10
+	// DefaultOperationTimeoutInSeconds is the default time limit of CNS
+	// operations. This is the same as set by the CSI sidecars.
+	DefaultOperationTimeoutInSeconds = 300
+	// VolumeOperationRequestStoreCRD persists volume operation details in
+	// CnsVolumeOperationRequest instances.
+	VolumeOperationRequestStoreCRD = "crd"
+	// VolumeOperationRequestStoreConfigMap persists volume operation details
+	// in ConfigMaps.
+	VolumeOperationRequestStoreConfigMap = "configmap"
+	// VolumeNameTemplateCluster, VolumeNameTemplateNamespace,
+	// VolumeNameTemplatePVC and VolumeNameTemplateHash are the placeholders of
+	// the volume name template, replaced with the cluster ID, the namespace and
+	// the name of the PVC, and a short hash of the name of the PV.
+	VolumeNameTemplateCluster   = "{cluster}"
+	VolumeNameTemplateNamespace = "{namespace}"
+	VolumeNameTemplatePVC       = "{pvc}"
+	VolumeNameTemplateHash      = "{hash}"
+	// MaxVolumeNameLength is the maximum length of the names of the CNS volumes
+	// generated from the volume name template.
+	MaxVolumeNameLength = 80
+	// VolumeNameHashLength is the length of the hash of the PV name in the
+	// names generated from the volume name template.
+	VolumeNameHashLength = 8
 	// supervisorIDPrefix is added before the SupervisorID
 	// Using this CNS UI can form an appropriate URL to navigate from CNS UI to WCP UI
 	supervisorIDPrefix = "vSphereSupervisorID-"
@@ -258,6 +297,30 @@ func FromEnv(ctx context.Context, cfg *Config) error {
 			cfg.Snapshot.GranularMaxSnapshotsPerBlockVolumeInVVOL = maxSnaps
 		}
 	}
+	if v := os.Getenv("VSPHERE_VOLUME_OPERATION_REQUEST_STORE"); v != "" {
+		cfg.Global.VolumeOperationRequestStore = v
+	}
+	for env, timeout := range map[string]*int{
+		"VSPHERE_CREATE_VOLUME_TIMEOUT_SECONDS":   &cfg.Global.CreateVolumeTimeoutInSeconds,
+		"VSPHERE_DELETE_VOLUME_TIMEOUT_SECONDS":   &cfg.Global.DeleteVolumeTimeoutInSeconds,
+		"VSPHERE_ATTACH_VOLUME_TIMEOUT_SECONDS":   &cfg.Global.AttachVolumeTimeoutInSeconds,
+		"VSPHERE_DETACH_VOLUME_TIMEOUT_SECONDS":   &cfg.Global.DetachVolumeTimeoutInSeconds,
+		"VSPHERE_UPDATE_VOLUME_TIMEOUT_SECONDS":   &cfg.Global.UpdateVolumeTimeoutInSeconds,
+		"VSPHERE_EXPAND_VOLUME_TIMEOUT_SECONDS":   &cfg.Global.ExpandVolumeTimeoutInSeconds,
+		"VSPHERE_RELOCATE_VOLUME_TIMEOUT_SECONDS": &cfg.Global.RelocateVolumeTimeoutInSeconds,
+		"VSPHERE_CONFIGURE_ACLS_TIMEOUT_SECONDS":  &cfg.Global.ConfigureACLsTimeoutInSeconds,
+		"VSPHERE_SNAPSHOT_TIMEOUT_SECONDS":        &cfg.Global.SnapshotTimeoutInSeconds,
+		"VSPHERE_QUERY_TIMEOUT_SECONDS":           &cfg.Global.QueryTimeoutInSeconds,
+	} {
+		if v := os.Getenv(env); v != "" {
+			value, err := strconv.Atoi(v)
+			if err != nil {
+				log.Errorf("failed to parse %s: %s", env, err)
+			} else {
+				*timeout = value
+			}
+		}
+	}
 	// Build VirtualCenter from ENVs.
 	for _, e := range os.Environ() {
 		pair := strings.Split(e, "=")
@@ -462,6 +525,10 @@ func validateConfig(ctx context.Context, cfg *Config) error {
 	if cfg.Snapshot.GlobalMaxSnapshotsPerBlockVolume == 0 {
 		cfg.Snapshot.GlobalMaxSnapshotsPerBlockVolume = DefaultGlobalMaxSnapshotsPerBlockVolume
 	}
+	if cfg.Placement.DatastoreLatencyThresholdInMs < 0 {
+		return logger.LogNewErrorf(log, "invalid datastore-latency-threshold-ms %d in Placement section",
+			cfg.Placement.DatastoreLatencyThresholdInMs)
+	}
 
 	// Labels section validation - the customer can either provide topology
 	// domain info using zone,region parameters or by using the topologyCategories
@@ -472,6 +539,14 @@ func validateConfig(ctx context.Context, cfg *Config) error {
 			"zone and region parameters should be skipped when topologyCategories is specified.")
 	}
 
+	// Host and cluster level topology segments are published alongside
+	// topologyCategories and cannot be combined with zone, region parameters.
+	if (cfg.Labels.HostTopology || cfg.Labels.ClusterTopology) &&
+		strings.TrimSpace(cfg.Labels.TopologyCategories) == "" {
+		return logger.LogNewErrorf(log,
+			"topologyCategories should be specified when host-topology or cluster-topology is enabled.")
+	}
+
 	// Validate length of topologyCategories in Labels section
 	if strings.TrimSpace(cfg.Labels.TopologyCategories) != "" {
 		if len(strings.Split(cfg.Labels.TopologyCategories, ",")) > MaxNumberOfTopologyCategories {
@@ -491,6 +566,58 @@ func validateConfig(ctx context.Context, cfg *Config) error {
 		}
 	}
 
+	if cfg.Archive.RetentionInHours < 0 {
+		return logger.LogNewErrorf(log, "invalid retention-hours %d in Archive section",
+			cfg.Archive.RetentionInHours)
+	}
+	if cfg.Archive.RetentionInHours == 0 {
+		cfg.Archive.RetentionInHours = DefaultArchiveRetentionInHours
+	}
+	if cfg.DeletionProtection.SnapshotRetentionInHours < 0 {
+		return logger.LogNewErrorf(log, "invalid snapshot-retention-hours %d in DeletionProtection section",
+			cfg.DeletionProtection.SnapshotRetentionInHours)
+	}
+	if cfg.DeletionProtection.SnapshotRetentionInHours == 0 {
+		cfg.DeletionProtection.SnapshotRetentionInHours = DefaultSnapshotRetentionInHours
+	}
+	if cfg.DeletionProtection.RecentSnapshotWindowInHours < 0 {
+		return logger.LogNewErrorf(log, "invalid recent-snapshot-window-hours %d in DeletionProtection section",
+			cfg.DeletionProtection.RecentSnapshotWindowInHours)
+	}
+	if cfg.DeletionProtection.RecentSnapshotWindowInHours == 0 {
+		cfg.DeletionProtection.RecentSnapshotWindowInHours = DefaultRecentSnapshotWindowInHours
+	}
+	if cfg.PolicyEngine.Endpoint != "" {
+		endpoint, err := url.Parse(cfg.PolicyEngine.Endpoint)
+		if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+			return logger.LogNewErrorf(log, "invalid endpoint %q in PolicyEngine section",
+				cfg.PolicyEngine.Endpoint)
+		}
+	}
+	if cfg.PolicyEngine.TimeoutInSeconds < 0 {
+		return logger.LogNewErrorf(log, "invalid timeout-seconds %d in PolicyEngine section",
+			cfg.PolicyEngine.TimeoutInSeconds)
+	}
+	if cfg.PolicyEngine.TimeoutInSeconds == 0 {
+		cfg.PolicyEngine.TimeoutInSeconds = DefaultPolicyEngineTimeoutInSeconds
+	}
+
+	for name, limit := range map[string]int64{
+		"global-max-volume-size-gb":        cfg.VolumeSizeLimits.GlobalMaxVolumeSizeInGb,
+		"granular-max-volume-size-gb-vsan": cfg.VolumeSizeLimits.GranularMaxVolumeSizeInGbInVSAN,
+		"granular-max-volume-size-gb-vvol": cfg.VolumeSizeLimits.GranularMaxVolumeSizeInGbInVVOL,
+	} {
+		if limit < 0 || limit > MaxVolumeSizeInGb {
+			return logger.LogNewErrorf(log, "invalid %s %d in VolumeSizeLimits section, "+
+				"it should be between 0 and %d", name, limit, MaxVolumeSizeInGb)
+		}
+	}
+
+	if err := validateVolumeNameTemplate(cfg.Global.VolumeNameTemplate); err != nil {
+		return logger.LogNewErrorf(log, "invalid volume-name-template %q in Global section. Err: %v",
+			cfg.Global.VolumeNameTemplate, err)
+	}
+
 	if cfg.Global.QueryLimit == 0 {
 		cfg.Global.QueryLimit = DefaultQueryLimit
 		log.Debugf("Setting default queryLimit to %v", cfg.Global.QueryLimit)
@@ -500,9 +627,45 @@ func validateConfig(ctx context.Context, cfg *Config) error {
 		cfg.Global.ListVolumeThreshold = DefaultListVolumeThreshold
 		log.Debugf("Setting default list volume threshold to %v", cfg.Global.ListVolumeThreshold)
 	}
+
+	switch cfg.Global.VolumeOperationRequestStore {
+	case "":
+		cfg.Global.VolumeOperationRequestStore = VolumeOperationRequestStoreCRD
+	case VolumeOperationRequestStoreCRD, VolumeOperationRequestStoreConfigMap:
+	default:
+		return logger.LogNewErrorf(log, "invalid volume-operation-request-store %q in Global section, "+
+			"supported values are %q and %q", cfg.Global.VolumeOperationRequestStore,
+			VolumeOperationRequestStoreCRD, VolumeOperationRequestStoreConfigMap)
+	}
+
+	for name, timeout := range getOperationTimeouts(cfg) {
+		if *timeout < 0 {
+			return logger.LogNewErrorf(log, "invalid %s %d in Global section", name, *timeout)
+		}
+		if *timeout == 0 {
+			*timeout = DefaultOperationTimeoutInSeconds
+		}
+	}
 	return nil
 }
 
+// getOperationTimeouts returns the operation timeouts of the Global section
+// keyed by their config name.
+func getOperationTimeouts(cfg *Config) map[string]*int {
+	return map[string]*int{
+		"create-volume-timeout-seconds":   &cfg.Global.CreateVolumeTimeoutInSeconds,
+		"delete-volume-timeout-seconds":   &cfg.Global.DeleteVolumeTimeoutInSeconds,
+		"attach-volume-timeout-seconds":   &cfg.Global.AttachVolumeTimeoutInSeconds,
+		"detach-volume-timeout-seconds":   &cfg.Global.DetachVolumeTimeoutInSeconds,
+		"update-volume-timeout-seconds":   &cfg.Global.UpdateVolumeTimeoutInSeconds,
+		"expand-volume-timeout-seconds":   &cfg.Global.ExpandVolumeTimeoutInSeconds,
+		"relocate-volume-timeout-seconds": &cfg.Global.RelocateVolumeTimeoutInSeconds,
+		"configure-acls-timeout-seconds":  &cfg.Global.ConfigureACLsTimeoutInSeconds,
+		"snapshot-timeout-seconds":        &cfg.Global.SnapshotTimeoutInSeconds,
+		"query-timeout-seconds":           &cfg.Global.QueryTimeoutInSeconds,
+	}
+}
+
 // ReadConfig parses vSphere cloud config file and stores it into VSphereConfig.
 // Environment variables are also checked.
 func ReadConfig(ctx context.Context, config io.Reader) (*Config, error) {
@@ -537,12 +700,12 @@ func GetCnsconfig(ctx context.Context, cfgPath string) (*Config, error) {
 			return cfg, err
 		}
 	} else {
-		config, err := os.Open(cfgPath)
+		config, err := readConfigFile(ctx, cfgPath)
 		if err != nil {
 			log.Errorf("failed to open %s. Err: %v", cfgPath, err)
 			return cfg, err
 		}
-		cfg, err = ReadConfig(ctx, config)
+		cfg, err = ReadConfig(ctx, bytes.NewReader(config))
 		if err != nil {
 			log.Errorf("failed to parse config. Err: %v", err)
 			return cfg, err
@@ -785,3 +948,32 @@ func GetSessionUserAgent(ctx context.Context) (string, error) {
 	}
 	return useragent, nil
 }
+
+// validateVolumeNameTemplate checks that the given volume name template only
+// uses known placeholders, uses the hash placeholder, and leaves room for
+// the values of the placeholders within MaxVolumeNameLength.
+func validateVolumeNameTemplate(template string) error {
+	if template == "" {
+		return nil
+	}
+	if !strings.Contains(template, VolumeNameTemplateHash) {
+		return fmt.Errorf("template must contain %s", VolumeNameTemplateHash)
+	}
+	// The minimum length of the names gives each placeholder other than the
+	// hash a single character.
+	minLength := 0
+	fixed := template
+	for placeholder, length := range map[string]int{VolumeNameTemplateCluster: 1, VolumeNameTemplateNamespace: 1,
+		VolumeNameTemplatePVC: 1, VolumeNameTemplateHash: VolumeNameHashLength} {
+		minLength += strings.Count(fixed, placeholder) * length
+		fixed = strings.ReplaceAll(fixed, placeholder, "")
+	}
+	if strings.ContainsAny(fixed, "{}") {
+		return fmt.Errorf("template contains unknown placeholders, supported placeholders are %s, %s, %s and %s",
+			VolumeNameTemplateCluster, VolumeNameTemplateNamespace, VolumeNameTemplatePVC, VolumeNameTemplateHash)
+	}
+	if minLength+len(fixed) > MaxVolumeNameLength {
+		return fmt.Errorf("template leaves no room for its placeholders within %d characters", MaxVolumeNameLength)
+	}
+	return nil
+}
@@ -20,6 +20,7 @@ import (
 	"context"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -249,6 +250,84 @@ func TestSnapshotConfigWhenMaxSpecifiedAsEnv(t *testing.T) {
 	}
 }
 
+func TestOperationTimeoutsWhenUnspecified(t *testing.T) {
+	cfg := &Config{
+		VirtualCenter: idealVCConfig,
+	}
+	err := validateConfig(ctx, cfg)
+	if err != nil {
+		t.Errorf("Unexpected error during config validation - %+v", *cfg)
+	}
+	for name, timeout := range getOperationTimeouts(cfg) {
+		if *timeout != DefaultOperationTimeoutInSeconds {
+			t.Errorf("Default %s incorrect: %d", name, *timeout)
+		}
+	}
+}
+
+func TestOperationTimeoutSpecifiedAsEnv(t *testing.T) {
+	cfg := &Config{
+		VirtualCenter: idealVCConfig,
+	}
+	// Temporarily set env variable.
+	os.Setenv("VSPHERE_CREATE_VOLUME_TIMEOUT_SECONDS", "600")
+	err := FromEnv(ctx, cfg)
+	if err != nil {
+		t.Errorf("Unexpected error during config validation - %+v", *cfg)
+	}
+	// Unset after reading to prevent effects on future tests.
+	os.Unsetenv("VSPHERE_CREATE_VOLUME_TIMEOUT_SECONDS")
+	if cfg.Global.CreateVolumeTimeoutInSeconds != 600 {
+		t.Errorf("Create volume timeout from env variable ignored")
+	}
+	if cfg.Global.DeleteVolumeTimeoutInSeconds != DefaultOperationTimeoutInSeconds {
+		t.Errorf("Default delete volume timeout incorrect: %d", cfg.Global.DeleteVolumeTimeoutInSeconds)
+	}
+}
+
+func TestOperationTimeoutNegative(t *testing.T) {
+	cfg := &Config{
+		VirtualCenter: idealVCConfig,
+	}
+	cfg.Global.QueryTimeoutInSeconds = -1
+	if err := validateConfig(ctx, cfg); err == nil {
+		t.Errorf("Expected error for negative query timeout")
+	}
+}
+
+func TestVolumeSizeLimitsOutOfRange(t *testing.T) {
+	cfg := &Config{
+		VirtualCenter: idealVCConfig,
+	}
+	cfg.VolumeSizeLimits.GranularMaxVolumeSizeInGbInVSAN = -1
+	if err := validateConfig(ctx, cfg); err == nil {
+		t.Errorf("Expected error for negative vSAN max volume size")
+	}
+	cfg.VolumeSizeLimits.GranularMaxVolumeSizeInGbInVSAN = 0
+	cfg.VolumeSizeLimits.GlobalMaxVolumeSizeInGb = MaxVolumeSizeInGb + 1
+	if err := validateConfig(ctx, cfg); err == nil {
+		t.Errorf("Expected error for global max volume size above %d GiB", MaxVolumeSizeInGb)
+	}
+}
+
+func TestValidateVolumeNameTemplate(t *testing.T) {
+	for template, valid := range map[string]bool{
+		"":                                   true,
+		"{cluster}-{namespace}-{pvc}-{hash}": true,
+		"{pvc}":                              false,
+		"{pvc}-{uid}-{hash}":                 false,
+		strings.Repeat("x", MaxVolumeNameLength-VolumeNameHashLength) + "{hash}":      true,
+		strings.Repeat("x", MaxVolumeNameLength-VolumeNameHashLength) + "{pvc}{hash}": false,
+	} {
+		err := validateVolumeNameTemplate(template)
+		if valid && err != nil {
+			t.Errorf("Unexpected error for volume name template %q: %v", template, err)
+		} else if !valid && err == nil {
+			t.Errorf("Expected error for volume name template %q", template)
+		}
+	}
+}
+
 func isConfigEqual(actual *Config, expected *Config) bool {
 	// TODO: Compare Global struct
 	// Compare VC Config
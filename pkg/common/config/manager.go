@@ -0,0 +1,238 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+var (
+	// configManagerInstance is the process wide config manager.
+	configManagerInstance *Manager
+	// configManagerInstanceLock guards configManagerInstance.
+	configManagerInstanceLock sync.Mutex
+)
+
+// Manager holds the configuration of the driver in memory, so that hot paths
+// don't read and parse the config secret on every call. Callers get
+// immutable snapshots or typed values, and are notified when a reload
+// changes the configuration.
+type Manager struct {
+	// mu guards cfg and listeners.
+	mu sync.RWMutex
+	// cfg is the current configuration. It is never handed out or mutated;
+	// a reload replaces it.
+	cfg *Config
+	// load reads the configuration.
+	load func(ctx context.Context) (*Config, error)
+	// listeners are called with a snapshot of the configuration after a
+	// reload changes it.
+	listeners []func(cfg *Config)
+}
+
+// GetConfigManager returns the config manager of the process, loading the
+// configuration on first use.
+func GetConfigManager(ctx context.Context) (*Manager, error) {
+	configManagerInstanceLock.Lock()
+	defer configManagerInstanceLock.Unlock()
+	if configManagerInstance != nil {
+		return configManagerInstance, nil
+	}
+	manager := newManager(GetConfig)
+	if err := manager.Reload(ctx); err != nil {
+		return nil, err
+	}
+	configManagerInstance = manager
+	return configManagerInstance, nil
+}
+
+// GetConfigSnapshot returns a snapshot of the configuration held by the
+// config manager of the process.
+func GetConfigSnapshot(ctx context.Context) (*Config, error) {
+	manager, err := GetConfigManager(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return manager.Snapshot(), nil
+}
+
+// newManager returns a config manager reading the configuration with load.
+func newManager(load func(ctx context.Context) (*Config, error)) *Manager {
+	return &Manager{load: load}
+}
+
+// Reload reads the configuration again. The current configuration is kept
+// if it can't be read. Subscribers are notified if the configuration changed.
+func (m *Manager) Reload(ctx context.Context) error {
+	log := logger.GetLogger(ctx)
+	cfg, err := m.load(ctx)
+	if err != nil {
+		return logger.LogNewErrorf(log, "failed to reload config. Err: %v", err)
+	}
+	m.mu.Lock()
+	changed := m.cfg != nil && !reflect.DeepEqual(m.cfg, cfg)
+	m.cfg = cfg
+	listeners := append([]func(cfg *Config){}, m.listeners...)
+	m.mu.Unlock()
+	if changed {
+		log.Info("Configuration changed, notifying subscribers")
+		for _, listener := range listeners {
+			listener(cfg.DeepCopy())
+		}
+	}
+	return nil
+}
+
+// Snapshot returns a copy of the current configuration. Callers may modify
+// the copy without affecting other callers.
+func (m *Manager) Snapshot() *Config {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.cfg.DeepCopy()
+}
+
+// Subscribe registers a function called with a snapshot of the configuration
+// each time a reload changes it.
+func (m *Manager) Subscribe(listener func(cfg *Config)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listeners = append(m.listeners, listener)
+}
+
+// ClusterID returns the cluster ID of the configuration.
+func (m *Manager) ClusterID() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.cfg == nil {
+		return ""
+	}
+	return m.cfg.Global.ClusterID
+}
+
+// VirtualCenterHosts returns the sorted hosts of the configured vCenters.
+func (m *Manager) VirtualCenterHosts() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.cfg == nil {
+		return nil
+	}
+	hosts := make([]string, 0, len(m.cfg.VirtualCenter))
+	for host := range m.cfg.VirtualCenter {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// VirtualCenter returns the configuration of the given vCenter, and false if
+// the vCenter is not configured.
+func (m *Manager) VirtualCenter(host string) (VirtualCenterConfig, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.cfg == nil || m.cfg.VirtualCenter[host] == nil {
+		return VirtualCenterConfig{}, false
+	}
+	return *m.cfg.VirtualCenter[host], true
+}
+
+// TopologyCategories returns the topology categories of the configuration.
+func (m *Manager) TopologyCategories() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.cfg == nil {
+		return ""
+	}
+	return m.cfg.Labels.TopologyCategories
+}
+
+// SnapshotConfig returns the snapshot configuration.
+func (m *Manager) SnapshotConfig() SnapshotConfig {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.cfg == nil {
+		return SnapshotConfig{}
+	}
+	return m.cfg.Snapshot
+}
+
+// VolumeSizeLimits returns the volume size limits configuration.
+func (m *Manager) VolumeSizeLimits() VolumeSizeLimitsConfig {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.cfg == nil {
+		return VolumeSizeLimitsConfig{}
+	}
+	return m.cfg.VolumeSizeLimits
+}
+
+// ArchiveConfig returns the configuration of archived volumes.
+func (m *Manager) ArchiveConfig() ArchiveConfig {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.cfg == nil {
+		return ArchiveConfig{}
+	}
+	return m.cfg.Archive
+}
+
+// DeepCopy returns a copy of the configuration sharing no maps or pointers
+// with it.
+func (cfg *Config) DeepCopy() *Config {
+	if cfg == nil {
+		return nil
+	}
+	out := *cfg
+	if cfg.NetPermissions != nil {
+		out.NetPermissions = make(map[string]*NetPermissionConfig, len(cfg.NetPermissions))
+		for key, value := range cfg.NetPermissions {
+			if value != nil {
+				value := *value
+				out.NetPermissions[key] = &value
+			} else {
+				out.NetPermissions[key] = nil
+			}
+		}
+	}
+	if cfg.VirtualCenter != nil {
+		out.VirtualCenter = make(map[string]*VirtualCenterConfig, len(cfg.VirtualCenter))
+		for key, value := range cfg.VirtualCenter {
+			if value != nil {
+				value := *value
+				out.VirtualCenter[key] = &value
+			} else {
+				out.VirtualCenter[key] = nil
+			}
+		}
+	}
+	if cfg.TopologyCategory != nil {
+		out.TopologyCategory = make(map[string]*TopologyCategoryInfo, len(cfg.TopologyCategory))
+		for key, value := range cfg.TopologyCategory {
+			if value != nil {
+				value := *value
+				out.TopologyCategory[key] = &value
+			} else {
+				out.TopologyCategory[key] = nil
+			}
+		}
+	}
+	return &out
+}
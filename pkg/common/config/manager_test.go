@@ -0,0 +1,89 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestConfigManagerSnapshotIsImmutable(t *testing.T) {
+	cfg := &Config{VirtualCenter: map[string]*VirtualCenterConfig{"vc1": {User: "user"}}}
+	cfg.Global.ClusterID = "cluster"
+	manager := newManager(func(ctx context.Context) (*Config, error) {
+		return cfg, nil
+	})
+	if err := manager.Reload(ctx); err != nil {
+		t.Fatalf("failed to load config. Err: %v", err)
+	}
+	snapshot := manager.Snapshot()
+	snapshot.Global.ClusterID = "modified"
+	snapshot.VirtualCenter["vc1"].User = "modified"
+	snapshot.VirtualCenter["vc2"] = &VirtualCenterConfig{}
+
+	if manager.ClusterID() != "cluster" {
+		t.Errorf("expected cluster ID %q, got %q", "cluster", manager.ClusterID())
+	}
+	vcConfig, ok := manager.VirtualCenter("vc1")
+	if !ok || vcConfig.User != "user" {
+		t.Errorf("expected user %q of vCenter vc1, got %+v", "user", vcConfig)
+	}
+	if hosts := manager.VirtualCenterHosts(); !reflect.DeepEqual(hosts, []string{"vc1"}) {
+		t.Errorf("expected vCenter hosts [vc1], got %v", hosts)
+	}
+}
+
+func TestConfigManagerReload(t *testing.T) {
+	clusterID := "cluster1"
+	var loadErr error
+	manager := newManager(func(ctx context.Context) (*Config, error) {
+		if loadErr != nil {
+			return nil, loadErr
+		}
+		cfg := &Config{}
+		cfg.Global.ClusterID = clusterID
+		return cfg, nil
+	})
+	var notified []string
+	manager.Subscribe(func(cfg *Config) {
+		notified = append(notified, cfg.Global.ClusterID)
+	})
+	if err := manager.Reload(ctx); err != nil {
+		t.Fatalf("failed to load config. Err: %v", err)
+	}
+	// Reloading an unchanged config must not notify subscribers.
+	if err := manager.Reload(ctx); err != nil {
+		t.Fatalf("failed to reload config. Err: %v", err)
+	}
+	clusterID = "cluster2"
+	if err := manager.Reload(ctx); err != nil {
+		t.Fatalf("failed to reload config. Err: %v", err)
+	}
+	if !reflect.DeepEqual(notified, []string{"cluster2"}) {
+		t.Errorf("expected subscribers notified with [cluster2], got %v", notified)
+	}
+	// A config which can't be read must not replace the current one.
+	loadErr = errors.New("read error")
+	if err := manager.Reload(ctx); err == nil {
+		t.Errorf("expected reload to fail")
+	}
+	if manager.ClusterID() != "cluster2" {
+		t.Errorf("expected cluster ID %q, got %q", "cluster2", manager.ClusterID())
+	}
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+const (
+	// EnvExpandConfigEnvVars enables the expansion of ${ENV_VAR} references
+	// in the CSI vSphere Config when set to "true".
+	EnvExpandConfigEnvVars = "VSPHERE_CSI_CONFIG_EXPAND_ENV"
+	// includeDirective is the directive, at the start of a line, replaced by
+	// the content of the file it names.
+	includeDirective = "@include"
+	// maxIncludeDepth is the maximum nesting of included files.
+	maxIncludeDepth = 8
+	// maxConfigLineLength is the maximum length of a line of a config file.
+	maxConfigLineLength = 1024 * 1024
+)
+
+// envVarReference matches ${NAME} references, and $${ which escapes them.
+var envVarReference = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// readConfigFile reads the config file at cfgPath, replaces the include
+// directives with the content of the included files and, if enabled through
+// EnvExpandConfigEnvVars, expands the environment variables referenced in
+// the files. Included files are not watched, so changing them requires the
+// main config file to be updated for the change to be reloaded.
+func readConfigFile(ctx context.Context, cfgPath string) ([]byte, error) {
+	expandEnv := false
+	if v := os.Getenv(EnvExpandConfigEnvVars); v != "" {
+		var err error
+		expandEnv, err = strconv.ParseBool(v)
+		if err != nil {
+			return nil, logger.LogNewErrorf(logger.GetLogger(ctx), "invalid value %q for %s. Err: %v",
+				v, EnvExpandConfigEnvVars, err)
+		}
+	}
+	return preprocessConfigFile(ctx, cfgPath, expandEnv, nil)
+}
+
+// preprocessConfigFile returns the content of the given config file with
+// its include directives resolved. includedBy holds the files including it,
+// to detect include cycles.
+func preprocessConfigFile(ctx context.Context, cfgPath string, expandEnv bool, includedBy []string) (
+	[]byte, error) {
+	log := logger.GetLogger(ctx)
+	absPath, err := filepath.Abs(cfgPath)
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to resolve config file path %q. Err: %v", cfgPath, err)
+	}
+	for _, path := range includedBy {
+		if path == absPath {
+			return nil, logger.LogNewErrorf(log, "config file %q includes itself through %v", absPath, includedBy)
+		}
+	}
+	if len(includedBy) > maxIncludeDepth {
+		return nil, logger.LogNewErrorf(log, "config file %q exceeds the maximum include depth of %d",
+			absPath, maxIncludeDepth)
+	}
+	content, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to read config file %q. Err: %v", absPath, err)
+	}
+	if expandEnv {
+		content, err = expandEnvVars(content)
+		if err != nil {
+			return nil, logger.LogNewErrorf(log, "failed to expand config file %q. Err: %v", absPath, err)
+		}
+	}
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxConfigLineLength)
+	for scanner.Scan() {
+		line := scanner.Text()
+		includePath, ok := parseIncludeDirective(line)
+		if !ok {
+			out.WriteString(line)
+			out.WriteByte('\n')
+			continue
+		}
+		if includePath == "" {
+			return nil, logger.LogNewErrorf(log, "missing file name in %q of config file %q", line, absPath)
+		}
+		if !filepath.IsAbs(includePath) {
+			includePath = filepath.Join(filepath.Dir(absPath), includePath)
+		}
+		included, err := preprocessConfigFile(ctx, includePath, expandEnv, append(includedBy, absPath))
+		if err != nil {
+			return nil, err
+		}
+		log.Debugf("Included config file %q in %q", includePath, absPath)
+		out.Write(included)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to read config file %q. Err: %v", absPath, err)
+	}
+	return out.Bytes(), nil
+}
+
+// parseIncludeDirective returns the file name of an include directive line,
+// with the surrounding quotes removed, and false if the line is not one.
+func parseIncludeDirective(line string) (string, bool) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || fields[0] != includeDirective {
+		return "", false
+	}
+	path := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), includeDirective))
+	if unquoted, err := strconv.Unquote(path); err == nil {
+		path = unquoted
+	}
+	return path, true
+}
+
+// expandEnvVars replaces the ${NAME} references in content with the values
+// of the environment variables. $${ is replaced by a literal ${. Referencing
+// an unset environment variable is an error, so that a missing secret isn't
+// silently replaced by an empty value.
+func expandEnvVars(content []byte) ([]byte, error) {
+	var missing []string
+	expanded := envVarReference.ReplaceAllFunc(content, func(match []byte) []byte {
+		if string(match) == "$${" {
+			return []byte("${")
+		}
+		name := string(match[2 : len(match)-1])
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+			return match
+		}
+		return []byte(value)
+	})
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("environment variables %v are not set", missing)
+	}
+	return expanded, nil
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("failed to create directory of %q. Err: %v", path, err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write %q. Err: %v", path, err)
+	}
+}
+
+func TestGetCnsconfigWithIncludesAndEnvVars(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigFile(t, filepath.Join(dir, "common", "global.conf"), `[Global]
+cluster-id = "${TEST_CLUSTER_ID}"
+`)
+	writeConfigFile(t, filepath.Join(dir, "vc.conf"), `[VirtualCenter "1.1.1.1"]
+user = "Administrator@vsphere.local"
+password = "pa$${ss}"
+datacenters = "dc1"
+`)
+	cfgPath := filepath.Join(dir, "vsphere.conf")
+	writeConfigFile(t, cfgPath, "@include \"common/global.conf\"\n@include "+filepath.Join(dir, "vc.conf")+"\n")
+	t.Setenv(EnvExpandConfigEnvVars, "true")
+	t.Setenv("TEST_CLUSTER_ID", "cluster1")
+
+	cfg, err := GetCnsconfig(ctx, cfgPath)
+	if err != nil {
+		t.Fatalf("failed to read config. Err: %v", err)
+	}
+	if cfg.Global.ClusterID != "cluster1" {
+		t.Errorf("expected cluster ID %q, got %q", "cluster1", cfg.Global.ClusterID)
+	}
+	vcConfig := cfg.VirtualCenter["1.1.1.1"]
+	if vcConfig == nil || vcConfig.Password != "pa${ss}" {
+		t.Errorf("expected password %q of vCenter 1.1.1.1, got %+v", "pa${ss}", vcConfig)
+	}
+}
+
+func TestReadConfigFileWithoutEnvExpansion(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "vsphere.conf")
+	writeConfigFile(t, cfgPath, "[Global]\ncluster-id = \"${TEST_CLUSTER_ID}\"\n")
+	t.Setenv("TEST_CLUSTER_ID", "cluster1")
+
+	content, err := readConfigFile(ctx, cfgPath)
+	if err != nil {
+		t.Fatalf("failed to read config. Err: %v", err)
+	}
+	if !strings.Contains(string(content), "${TEST_CLUSTER_ID}") {
+		t.Errorf("expected environment variables not to be expanded, got %q", content)
+	}
+}
+
+func TestReadConfigFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigFile(t, filepath.Join(dir, "a.conf"), "@include b.conf\n")
+	writeConfigFile(t, filepath.Join(dir, "b.conf"), "@include a.conf\n")
+	writeConfigFile(t, filepath.Join(dir, "missing-include.conf"), "@include not-found.conf\n")
+	writeConfigFile(t, filepath.Join(dir, "missing-env.conf"), "[Global]\nuser = \"${TEST_UNSET_VAR}\"\n")
+	t.Setenv(EnvExpandConfigEnvVars, "true")
+	os.Unsetenv("TEST_UNSET_VAR")
+
+	for _, name := range []string{"a.conf", "missing-include.conf", "missing-env.conf"} {
+		if _, err := readConfigFile(ctx, filepath.Join(dir, name)); err == nil {
+			t.Errorf("expected reading %q to fail", name)
+		}
+	}
+}
@@ -63,6 +63,16 @@ type Config struct {
 		// CnsVolumeOperationRequestCleanupIntervalInMin specifies the interval after which
 		// stale CnsVolumeOperationRequest instances will be cleaned up.
 		CnsVolumeOperationRequestCleanupIntervalInMin int `gcfg:"cnsvolumeoperationrequest-cleanup-intervalinmin"`
+		// VolumeOperationRequestStore specifies where volume operation details
+		// are persisted for idempotency. Supported values are "crd" (default),
+		// one CnsVolumeOperationRequest instance per request, and "configmap",
+		// a fixed set of ConfigMaps suited to clusters with high volume churn.
+		VolumeOperationRequestStore string `gcfg:"volume-operation-request-store"`
+		// VolumeNameTemplate specifies the name of the CNS volumes created for
+		// PVCs, instead of the name of the PV. It may use the {cluster},
+		// {namespace}, {pvc} and {hash} placeholders, and must use {hash}, a
+		// short hash of the PV name, so that names are unique.
+		VolumeNameTemplate string `gcfg:"volume-name-template"`
 		// CSIFetchPreferredDatastoresIntervalInMin specifies the interval
 		// after which the preferred datastores cache is refreshed in the driver.
 		CSIFetchPreferredDatastoresIntervalInMin int `gcfg:"csi-fetch-preferred-datastores-intervalinmin"`
@@ -72,6 +82,24 @@ type Config struct {
 		// ListVolumeThreshold specifies the maximum number of differences in volume that can exist between CNS
 		// and kubernetes
 		ListVolumeThreshold int `gcfg:"list-volume-threshold"`
+
+		// CreateVolumeTimeoutInSeconds, DeleteVolumeTimeoutInSeconds,
+		// AttachVolumeTimeoutInSeconds, DetachVolumeTimeoutInSeconds,
+		// UpdateVolumeTimeoutInSeconds, ExpandVolumeTimeoutInSeconds,
+		// RelocateVolumeTimeoutInSeconds, ConfigureACLsTimeoutInSeconds,
+		// SnapshotTimeoutInSeconds and QueryTimeoutInSeconds specify the time
+		// limit of the corresponding CNS operations. The limit of the caller
+		// applies if it is shorter. If not set, default will be 300 seconds.
+		CreateVolumeTimeoutInSeconds   int `gcfg:"create-volume-timeout-seconds"`
+		DeleteVolumeTimeoutInSeconds   int `gcfg:"delete-volume-timeout-seconds"`
+		AttachVolumeTimeoutInSeconds   int `gcfg:"attach-volume-timeout-seconds"`
+		DetachVolumeTimeoutInSeconds   int `gcfg:"detach-volume-timeout-seconds"`
+		UpdateVolumeTimeoutInSeconds   int `gcfg:"update-volume-timeout-seconds"`
+		ExpandVolumeTimeoutInSeconds   int `gcfg:"expand-volume-timeout-seconds"`
+		RelocateVolumeTimeoutInSeconds int `gcfg:"relocate-volume-timeout-seconds"`
+		ConfigureACLsTimeoutInSeconds  int `gcfg:"configure-acls-timeout-seconds"`
+		SnapshotTimeoutInSeconds       int `gcfg:"snapshot-timeout-seconds"`
+		QueryTimeoutInSeconds          int `gcfg:"query-timeout-seconds"`
 	}
 
 	// Multiple sets of Net Permissions applied to all file shares
@@ -84,6 +112,20 @@ type Config struct {
 	// Snapshot configurations.
 	Snapshot SnapshotConfig
 
+	// Placement configurations.
+	Placement PlacementConfig
+	// Attach configurations.
+	Attach AttachConfig
+	// Archive configurations for volumes using the archive reclaim action.
+	Archive ArchiveConfig
+	// DeletionProtection configurations for volumes protected from deletion.
+	DeletionProtection DeletionProtectionConfig
+	// PolicyEngine configurations of the external policy service reviewing
+	// provisioning requests.
+	PolicyEngine PolicyEngineConfig
+	// VolumeSizeLimits configurations.
+	VolumeSizeLimits VolumeSizeLimitsConfig
+
 	// Guest Cluster configurations, only used by GC
 	GC GCConfig
 
@@ -99,6 +141,11 @@ type Config struct {
 		// create in the inventory using the UI.
 		// Maximum number of categories allowed is 5.
 		TopologyCategories string `gcfg:"topology-categories"`
+		// HostTopology and ClusterTopology publish the ESXi host and vSphere
+		// cluster of the node VM as additional topology segments. These
+		// segments are derived from the inventory and do not require tags.
+		HostTopology    bool `gcfg:"host-topology"`
+		ClusterTopology bool `gcfg:"cluster-topology"`
 	}
 
 	TopologyCategory map[string]*TopologyCategoryInfo
@@ -189,5 +236,74 @@ type SnapshotConfig struct {
 	GranularMaxSnapshotsPerBlockVolumeInVVOL int `gcfg:"granular-max-snapshots-per-block-volume-vvol"`
 }
 
+// PlacementConfig contains volume placement configuration.
+type PlacementConfig struct {
+	// DatastoreLatencyThresholdInMs specifies the maximum recent read or write
+	// latency, in milliseconds, a datastore may report to be picked for new
+	// volumes. Datastores above the threshold are skipped even if they have
+	// enough capacity. Set to 0 to disable latency based placement.
+	DatastoreLatencyThresholdInMs int `gcfg:"datastore-latency-threshold-ms"`
+}
+
+// AttachConfig contains volume attach configuration.
+type AttachConfig struct {
+	// AddControllersOnDemand specifies whether a PVSCSI or NVMe controller is
+	// added to a node VM when all its disk controllers are full. If not set,
+	// attaching a volume to such a node VM fails without calling CNS.
+	AddControllersOnDemand bool `gcfg:"add-controllers-on-demand"`
+}
+
+// ArchiveConfig contains the configuration of archived volumes.
+type ArchiveConfig struct {
+	// RetentionInHours is how long an archived volume is kept before it is
+	// permanently deleted.
+	RetentionInHours int `gcfg:"retention-hours"`
+	// DatastoreURL is the datastore archived volumes are relocated to. If not
+	// set, archived volumes stay on their current datastore.
+	DatastoreURL string `gcfg:"datastore-url"`
+}
+
+// DeletionProtectionConfig contains the configuration of volumes whose PV or
+// PVC is annotated for deletion protection.
+type DeletionProtectionConfig struct {
+	// SnapshotRetentionInHours is how long a volume retained with a safety
+	// snapshot, when its PV is deleted, is kept before it is permanently
+	// deleted along with its safety snapshot. Volumes with other snapshots
+	// are kept until these are deleted.
+	SnapshotRetentionInHours int `gcfg:"snapshot-retention-hours"`
+	// RecentSnapshotWindowInHours is how long after the last snapshot of a
+	// volume the deletion of the volume is deferred.
+	RecentSnapshotWindowInHours int `gcfg:"recent-snapshot-window-hours"`
+}
+
+// PolicyEngineConfig contains the configuration of the external policy
+// service reviewing CreateVolume and CreateSnapshot requests.
+type PolicyEngineConfig struct {
+	// Endpoint is the URL the review requests are posted to, such as the
+	// data API of an OPA policy. Requests are not reviewed if not set.
+	Endpoint string `gcfg:"endpoint"`
+	// CAFile is the path of the CA certificate bundle used to verify the
+	// certificate of an HTTPS endpoint. The system roots are used if not set.
+	CAFile string `gcfg:"ca-file"`
+	// TimeoutInSeconds is the time limit of a review request.
+	TimeoutInSeconds int `gcfg:"timeout-seconds"`
+	// FailOpen allows provisioning requests when the policy service can't be
+	// reached. Requests are denied by default.
+	FailOpen bool `gcfg:"fail-open"`
+}
+
+// VolumeSizeLimitsConfig contains the maximum sizes of new block volumes.
+// A limit set to 0 is not enforced.
+type VolumeSizeLimitsConfig struct {
+	// GlobalMaxVolumeSizeInGb specifies the maximum size, in GiB, of block volumes.
+	GlobalMaxVolumeSizeInGb int64 `gcfg:"global-max-volume-size-gb"`
+	// GranularMaxVolumeSizeInGbInVSAN specifies the maximum size, in GiB, of block
+	// volumes in VSAN datastores.
+	GranularMaxVolumeSizeInGbInVSAN int64 `gcfg:"granular-max-volume-size-gb-vsan"`
+	// GranularMaxVolumeSizeInGbInVVOL specifies the maximum size, in GiB, of block
+	// volumes in VVOL datastores.
+	GranularMaxVolumeSizeInGbInVVOL int64 `gcfg:"granular-max-volume-size-gb-vvol"`
+}
+
 // EnvClusterFlavor is the k8s cluster type on which CSI Driver is being deployed
 const EnvClusterFlavor = "CLUSTER_FLAVOR"
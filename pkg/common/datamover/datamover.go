@@ -0,0 +1,266 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package datamover uploads the content of a block device, the temporary
+// clone of a volume snapshot, to an S3-compatible bucket. It is run by the
+// data mover jobs of the CnsSnapshotExport controller, in the syncer image
+// started in the DATA_MOVER operation mode.
+//
+// The content is uploaded as a multipart upload, each part being verified by
+// the object store against its MD5 checksum. If the data mover is restarted,
+// it resumes the multipart upload in progress for the key, and only uploads
+// the parts which are missing or whose ETag doesn't match the content of the
+// device. Once the object is complete, the SHA-256 checksum of the content is
+// uploaded to the <key>.sha256 object, in the sha256sum format.
+package datamover
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+const (
+	// EnvDevicePath is the path of the block device uploaded by the data mover.
+	EnvDevicePath = "DATA_MOVER_DEVICE_PATH"
+	// EnvPartSizeMB overrides the size, in MiB, of the parts of the upload.
+	EnvPartSizeMB = "DATA_MOVER_PART_SIZE_MB"
+	// EnvResultPath is the path of the file the JSON encoded Result is written
+	// to. Defaults to the termination message path of the container.
+	EnvResultPath = "DATA_MOVER_RESULT_PATH"
+	// EnvS3Endpoint is the URL of the S3-compatible endpoint.
+	EnvS3Endpoint = "S3_ENDPOINT"
+	// EnvS3Region is the region of the bucket.
+	EnvS3Region = "S3_REGION"
+	// EnvS3Bucket is the name of the bucket.
+	EnvS3Bucket = "S3_BUCKET"
+	// EnvS3Key is the key of the uploaded object.
+	EnvS3Key = "S3_KEY"
+	// EnvS3AccessKeyID is the access key ID used to access the bucket.
+	EnvS3AccessKeyID = "AWS_ACCESS_KEY_ID"
+	// EnvS3SecretAccessKey is the secret access key used to access the bucket.
+	EnvS3SecretAccessKey = "AWS_SECRET_ACCESS_KEY"
+
+	// ChecksumKeySuffix is the suffix of the key of the object holding the
+	// SHA-256 checksum of the uploaded content.
+	ChecksumKeySuffix = ".sha256"
+
+	// defaultPartSize is the default size of the parts of the upload.
+	defaultPartSize = 64 * 1024 * 1024
+	// defaultResultPath is the default termination message path of a
+	// container.
+	defaultResultPath = "/dev/termination-log"
+)
+
+// Params are the parameters of an upload.
+type Params struct {
+	DevicePath      string
+	Endpoint        string
+	Region          string
+	Bucket          string
+	Key             string
+	AccessKeyID     string
+	SecretAccessKey string
+	// PartSize is the minimum size of the parts of the upload. It is raised
+	// if needed to stay within the maximum number of parts.
+	PartSize   int64
+	ResultPath string
+}
+
+// Result is the outcome of a successful upload.
+type Result struct {
+	// SizeBytes is the size of the uploaded content.
+	SizeBytes int64 `json:"sizeBytes"`
+	// Checksum is the hex encoded SHA-256 checksum of the uploaded content.
+	Checksum string `json:"checksum"`
+	// Parts is the number of parts of the upload.
+	Parts int `json:"parts"`
+	// ResumedParts is the number of parts already uploaded by a previous run.
+	ResumedParts int `json:"resumedParts"`
+}
+
+// ParamsFromEnv returns the upload parameters set in the environment.
+func ParamsFromEnv() (Params, error) {
+	params := Params{
+		DevicePath:      os.Getenv(EnvDevicePath),
+		Endpoint:        os.Getenv(EnvS3Endpoint),
+		Region:          os.Getenv(EnvS3Region),
+		Bucket:          os.Getenv(EnvS3Bucket),
+		Key:             os.Getenv(EnvS3Key),
+		AccessKeyID:     os.Getenv(EnvS3AccessKeyID),
+		SecretAccessKey: os.Getenv(EnvS3SecretAccessKey),
+		PartSize:        defaultPartSize,
+		ResultPath:      os.Getenv(EnvResultPath),
+	}
+	for name, value := range map[string]string{
+		EnvDevicePath:        params.DevicePath,
+		EnvS3Endpoint:        params.Endpoint,
+		EnvS3Bucket:          params.Bucket,
+		EnvS3Key:             params.Key,
+		EnvS3AccessKeyID:     params.AccessKeyID,
+		EnvS3SecretAccessKey: params.SecretAccessKey,
+	} {
+		if value == "" {
+			return Params{}, fmt.Errorf("environment variable %s is not set", name)
+		}
+	}
+	if v := os.Getenv(EnvPartSizeMB); v != "" {
+		partSizeMB, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || partSizeMB*1024*1024 < s3MinPartSize {
+			return Params{}, fmt.Errorf("environment variable %s must be an integer of at least %d, got %q",
+				EnvPartSizeMB, s3MinPartSize/(1024*1024), v)
+		}
+		params.PartSize = partSizeMB * 1024 * 1024
+	}
+	if params.ResultPath == "" {
+		params.ResultPath = defaultResultPath
+	}
+	return params, nil
+}
+
+// Run uploads the content of the device to the bucket, resuming the upload
+// in progress for the key if any, and writes the Result to the result path.
+func Run(ctx context.Context, params Params) (*Result, error) {
+	log := logger.GetLogger(ctx)
+	client, err := newS3Client(params.Endpoint, params.Region, params.Bucket, params.AccessKeyID,
+		params.SecretAccessKey)
+	if err != nil {
+		return nil, err
+	}
+	device, err := os.Open(params.DevicePath)
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to open device %q. Error: %v", params.DevicePath, err)
+	}
+	defer device.Close()
+	size, err := device.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to get the size of device %q. Error: %v",
+			params.DevicePath, err)
+	}
+	if size == 0 {
+		return nil, logger.LogNewErrorf(log, "device %q is empty", params.DevicePath)
+	}
+	result, err := upload(ctx, client, device, size, params.Key, getPartSize(size, params.PartSize))
+	if err != nil {
+		return nil, err
+	}
+	if params.ResultPath != "" {
+		data, err := json.Marshal(result)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(params.ResultPath, data, 0644); err != nil {
+			log.Warnf("failed to write the result to %q. Error: %v", params.ResultPath, err)
+		}
+	}
+	return result, nil
+}
+
+// getPartSize returns the size of the parts of the upload of size bytes. It
+// depends only on the size of the content, so that a resumed upload uses the
+// same parts.
+func getPartSize(size, partSize int64) int64 {
+	if partSize < s3MinPartSize {
+		partSize = s3MinPartSize
+	}
+	if minPartSize := (size + s3MaxParts - 1) / s3MaxParts; partSize < minPartSize {
+		// Round up to a MiB.
+		partSize = (minPartSize + 1024*1024 - 1) / (1024 * 1024) * (1024 * 1024)
+	}
+	return partSize
+}
+
+// upload uploads the content of r, of the given size, to the key.
+func upload(ctx context.Context, client *s3Client, r io.ReaderAt, size int64, key string,
+	partSize int64) (*Result, error) {
+	log := logger.GetLogger(ctx)
+	uploadID, err := client.findMultipartUpload(ctx, key)
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to list the multipart uploads of %q. Error: %v", key, err)
+	}
+	uploaded := make(map[int]s3Part)
+	if uploadID != "" {
+		parts, err := client.listParts(ctx, key, uploadID)
+		if err != nil {
+			return nil, logger.LogNewErrorf(log, "failed to list the parts of upload %q. Error: %v", uploadID, err)
+		}
+		for _, part := range parts {
+			uploaded[part.PartNumber] = part
+		}
+		log.Infof("Resuming upload %q of %q with %d parts already uploaded", uploadID, key, len(parts))
+	} else {
+		uploadID, err = client.createMultipartUpload(ctx, key)
+		if err != nil {
+			return nil, logger.LogNewErrorf(log, "failed to create a multipart upload of %q. Error: %v", key, err)
+		}
+		log.Infof("Created upload %q of %q", uploadID, key)
+	}
+
+	result := &Result{SizeBytes: size}
+	checksum := sha256.New()
+	buf := make([]byte, partSize)
+	var parts []s3Part
+	for offset, partNumber := int64(0), 1; offset < size; offset, partNumber = offset+partSize, partNumber+1 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		data := buf[:min(partSize, size-offset)]
+		if _, err := r.ReadAt(data, offset); err != nil && err != io.EOF {
+			return nil, logger.LogNewErrorf(log, "failed to read %d bytes at offset %d. Error: %v",
+				len(data), offset, err)
+		}
+		checksum.Write(data)
+		sum := md5.Sum(data)
+		etag := `"` + hex.EncodeToString(sum[:]) + `"`
+		if part, ok := uploaded[partNumber]; ok && part.Size == int64(len(data)) &&
+			strings.Trim(part.ETag, `"`) == strings.Trim(etag, `"`) {
+			parts = append(parts, s3Part{PartNumber: partNumber, ETag: part.ETag})
+			result.ResumedParts++
+			continue
+		}
+		uploadedETag, err := client.uploadPart(ctx, key, uploadID, partNumber, data)
+		if err != nil {
+			return nil, logger.LogNewErrorf(log, "failed to upload part %d of %q. Error: %v", partNumber, key, err)
+		}
+		if uploadedETag == "" {
+			uploadedETag = etag
+		}
+		parts = append(parts, s3Part{PartNumber: partNumber, ETag: uploadedETag})
+		log.Debugf("Uploaded part %d of %q, %d/%d bytes", partNumber, key, offset+int64(len(data)), size)
+	}
+	if err := client.completeMultipartUpload(ctx, key, uploadID, parts); err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to complete upload %q of %q. Error: %v", uploadID, key, err)
+	}
+	result.Parts = len(parts)
+	result.Checksum = hex.EncodeToString(checksum.Sum(nil))
+	checksumLine := result.Checksum + "  " + path.Base(key) + "\n"
+	if err := client.putObject(ctx, key+ChecksumKeySuffix, []byte(checksumLine)); err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to upload the checksum of %q. Error: %v", key, err)
+	}
+	log.Infof("Uploaded %d bytes to %q in %d parts, %d of them resumed. SHA-256: %s", size, key,
+		result.Parts, result.ResumedParts, result.Checksum)
+	return result, nil
+}
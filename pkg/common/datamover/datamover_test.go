@@ -0,0 +1,215 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package datamover
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// completeMultipartUpload is the body of a CompleteMultipartUpload request.
+type completeMultipartUpload struct {
+	XMLName xml.Name `xml:"CompleteMultipartUpload"`
+	Parts   []struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	} `xml:"Part"`
+}
+
+// fakeS3 is an in-memory S3 server supporting the requests of s3Client.
+type fakeS3 struct {
+	mu      sync.Mutex
+	uploads map[string]map[int][]byte
+	keys    map[string]string
+	objects map[string][]byte
+	// uploadedParts counts the UploadPart requests.
+	uploadedParts int
+	// failPart is the number of the part whose upload fails, if not zero.
+	failPart int
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{uploads: map[string]map[int][]byte{}, keys: map[string]string{}, objects: map[string][]byte{}}
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=access/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	key := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/bucket"), "/")
+	query := r.URL.Query()
+	body, _ := io.ReadAll(r.Body)
+	if strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		body = decodeChunkedPayload(body)
+	}
+	uploadID := query.Get("uploadId")
+	switch {
+	case r.Method == http.MethodGet && query.Has("uploads"):
+		fmt.Fprint(w, "<ListMultipartUploadsResult>")
+		for id, k := range s.keys {
+			fmt.Fprintf(w, "<Upload><Key>%s</Key><UploadId>%s</UploadId></Upload>", k, id)
+		}
+		fmt.Fprint(w, "</ListMultipartUploadsResult>")
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		id := strconv.Itoa(len(s.keys) + 1)
+		s.keys[id], s.uploads[id] = key, map[int][]byte{}
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+	case r.Method == http.MethodGet && uploadID != "":
+		var numbers []int
+		for number := range s.uploads[uploadID] {
+			numbers = append(numbers, number)
+		}
+		sort.Ints(numbers)
+		fmt.Fprint(w, "<ListPartsResult>")
+		for _, number := range numbers {
+			data := s.uploads[uploadID][number]
+			fmt.Fprintf(w, "<Part><PartNumber>%d</PartNumber><ETag>%q</ETag><Size>%d</Size></Part>",
+				number, etag(data), len(data))
+		}
+		fmt.Fprint(w, "</ListPartsResult>")
+	case r.Method == http.MethodPut && uploadID != "":
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		sum := md5.Sum(body)
+		if number == s.failPart || r.Header.Get("Content-Md5") != base64.StdEncoding.EncodeToString(sum[:]) {
+			// Not a retryable error, for the client to fail right away.
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, "<Error><Code>BadDigest</Code></Error>")
+			return
+		}
+		s.uploadedParts++
+		s.uploads[uploadID][number] = body
+		w.Header().Set("ETag", `"`+etag(body)+`"`)
+	case r.Method == http.MethodPost && uploadID != "":
+		var completed completeMultipartUpload
+		_ = xml.Unmarshal(body, &completed)
+		var object []byte
+		for _, part := range completed.Parts {
+			object = append(object, s.uploads[uploadID][part.PartNumber]...)
+		}
+		s.objects[key] = object
+		delete(s.uploads, uploadID)
+		delete(s.keys, uploadID)
+		fmt.Fprintf(w, "<CompleteMultipartUploadResult><Bucket>bucket</Bucket><Key>%s</Key>"+
+			"<ETag>%q</ETag></CompleteMultipartUploadResult>", key, etag(object))
+	case r.Method == http.MethodPut:
+		s.objects[key] = body
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// decodeChunkedPayload returns the payload of a body sent with the streaming
+// signature, made of "<hex size>;chunk-signature=<signature>\r\n<data>\r\n"
+// chunks ended by a chunk of size 0.
+func decodeChunkedPayload(body []byte) []byte {
+	var payload []byte
+	for {
+		header, rest, ok := bytes.Cut(body, []byte("\r\n"))
+		if !ok {
+			return payload
+		}
+		sizeHex, _, _ := bytes.Cut(header, []byte(";"))
+		size, err := strconv.ParseInt(string(sizeHex), 16, 64)
+		if err != nil || size == 0 || int64(len(rest)) < size {
+			return payload
+		}
+		payload = append(payload, rest[:size]...)
+		body = bytes.TrimPrefix(rest[size:], []byte("\r\n"))
+	}
+}
+
+func etag(data []byte) string {
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestUploadResume(t *testing.T) {
+	ctx := context.Background()
+	s3 := newFakeS3()
+	server := httptest.NewServer(s3)
+	defer server.Close()
+	client, err := newS3Client(server.URL, "", "bucket", "access", "secret")
+	if err != nil {
+		t.Fatalf("failed to create S3 client. Error: %v", err)
+	}
+	content := make([]byte, 3*s3MinPartSize+1024)
+	rand.New(rand.NewSource(1)).Read(content)
+	key := "default/snap 1.img"
+
+	// The first run fails on the third part.
+	s3.failPart = 3
+	if _, err := upload(ctx, client, bytes.NewReader(content), int64(len(content)), key, s3MinPartSize); err == nil {
+		t.Fatalf("expected the upload to fail")
+	}
+	if s3.uploadedParts != 2 {
+		t.Fatalf("expected 2 uploaded parts, got %d", s3.uploadedParts)
+	}
+
+	// The second run resumes the upload, and doesn't upload the first parts
+	// again.
+	s3.failPart = 0
+	result, err := upload(ctx, client, bytes.NewReader(content), int64(len(content)), key, s3MinPartSize)
+	if err != nil {
+		t.Fatalf("failed to upload. Error: %v", err)
+	}
+	if result.Parts != 4 || result.ResumedParts != 2 || s3.uploadedParts != 4 {
+		t.Errorf("expected 4 parts with 2 resumed, got %+v with %d uploaded parts", result, s3.uploadedParts)
+	}
+	if !bytes.Equal(s3.objects[key], content) {
+		t.Errorf("uploaded object doesn't match the content")
+	}
+	sum := sha256.Sum256(content)
+	if result.Checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("expected checksum %x, got %s", sum, result.Checksum)
+	}
+	if expected := result.Checksum + "  snap 1.img\n"; string(s3.objects[key+ChecksumKeySuffix]) != expected {
+		t.Errorf("expected checksum object %q, got %q", expected, s3.objects[key+ChecksumKeySuffix])
+	}
+}
+
+func TestGetPartSize(t *testing.T) {
+	for _, test := range []struct {
+		size, partSize, expected int64
+	}{
+		{size: 1024, partSize: 1024, expected: s3MinPartSize},
+		{size: 10 << 30, partSize: defaultPartSize, expected: defaultPartSize},
+		// 2 TiB don't fit in 10000 parts of 64 MiB.
+		{size: 2 << 40, partSize: defaultPartSize, expected: 210 << 20},
+	} {
+		if partSize := getPartSize(test.size, test.partSize); partSize != test.expected {
+			t.Errorf("expected part size %d for %d bytes, got %d", test.expected, test.size, partSize)
+		}
+	}
+}
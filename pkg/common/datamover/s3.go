@@ -0,0 +1,170 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package datamover
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+const (
+	// defaultS3Region is the region of the bucket if none is set. Setting it
+	// spares the lookup of the bucket location.
+	defaultS3Region = "us-east-1"
+	// s3MaxParts is the maximum number of parts of a multipart upload.
+	s3MaxParts = 10000
+	// s3MinPartSize is the minimum size of the parts of a multipart upload,
+	// except the last one.
+	s3MinPartSize = 5 * 1024 * 1024
+	// s3MaxListedParts is the maximum number of parts listed per request.
+	s3MaxListedParts = 1000
+)
+
+// s3Client is a client of a bucket, covering the multipart upload of a single
+// object. Objects are addressed path-style, so that S3-compatible object
+// stores are supported.
+type s3Client struct {
+	core   *minio.Core
+	bucket string
+}
+
+// s3Part is a part of a multipart upload.
+type s3Part struct {
+	PartNumber int
+	ETag       string
+	Size       int64
+}
+
+// newS3Client returns a client of the bucket on the S3-compatible endpoint.
+func newS3Client(endpoint, region, bucket, accessKeyID, secretAccessKey string) (*s3Client, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") ||
+		(u.Path != "" && u.Path != "/") {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", endpoint)
+	}
+	if region == "" {
+		region = defaultS3Region
+	}
+	core, err := minio.NewCore(u.Host, &minio.Options{
+		Creds:        credentials.NewStaticV4(accessKeyID, secretAccessKey, ""),
+		Secure:       u.Scheme == "https",
+		Region:       region,
+		BucketLookup: minio.BucketLookupPath,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the client of S3 endpoint %q. Error: %v", endpoint, err)
+	}
+	return &s3Client{core: core, bucket: bucket}, nil
+}
+
+// findMultipartUpload returns the ID of the most recently initiated multipart
+// upload of the key which is still in progress, or "" if there is none.
+func (c *s3Client) findMultipartUpload(ctx context.Context, key string) (string, error) {
+	var uploadID, keyMarker, uploadIDMarker string
+	var initiated time.Time
+	for {
+		result, err := c.core.ListMultipartUploads(ctx, c.bucket, key, keyMarker, uploadIDMarker, "", 0)
+		if err != nil {
+			return "", err
+		}
+		for _, upload := range result.Uploads {
+			if upload.Key == key && (uploadID == "" || upload.Initiated.After(initiated)) {
+				uploadID, initiated = upload.UploadID, upload.Initiated
+			}
+		}
+		if !result.IsTruncated {
+			return uploadID, nil
+		}
+		keyMarker, uploadIDMarker = result.NextKeyMarker, result.NextUploadIDMarker
+	}
+}
+
+// createMultipartUpload initiates a multipart upload of the key and returns
+// its ID.
+func (c *s3Client) createMultipartUpload(ctx context.Context, key string) (string, error) {
+	uploadID, err := c.core.NewMultipartUpload(ctx, c.bucket, key, minio.PutObjectOptions{})
+	if err != nil {
+		return "", err
+	}
+	if uploadID == "" {
+		return "", fmt.Errorf("no upload ID returned for the multipart upload of %q", key)
+	}
+	return uploadID, nil
+}
+
+// listParts returns the parts already uploaded to the multipart upload.
+func (c *s3Client) listParts(ctx context.Context, key, uploadID string) ([]s3Part, error) {
+	var parts []s3Part
+	marker := 0
+	for {
+		result, err := c.core.ListObjectParts(ctx, c.bucket, key, uploadID, marker, s3MaxListedParts)
+		if err != nil {
+			return nil, err
+		}
+		for _, part := range result.ObjectParts {
+			parts = append(parts, s3Part{PartNumber: part.PartNumber, ETag: part.ETag, Size: part.Size})
+		}
+		if !result.IsTruncated || result.NextPartNumberMarker <= marker {
+			return parts, nil
+		}
+		marker = result.NextPartNumberMarker
+	}
+}
+
+// uploadPart uploads the part of the multipart upload. S3 verifies the data
+// against its MD5 checksum and returns the ETag of the part.
+func (c *s3Client) uploadPart(ctx context.Context, key, uploadID string, partNumber int,
+	data []byte) (string, error) {
+	part, err := c.core.PutObjectPart(ctx, c.bucket, key, uploadID, partNumber, bytes.NewReader(data),
+		int64(len(data)), minio.PutObjectPartOptions{Md5Base64: md5Base64(data)})
+	if err != nil {
+		return "", err
+	}
+	return part.ETag, nil
+}
+
+// completeMultipartUpload assembles the uploaded parts into the object.
+func (c *s3Client) completeMultipartUpload(ctx context.Context, key, uploadID string, parts []s3Part) error {
+	completed := make([]minio.CompletePart, 0, len(parts))
+	for _, part := range parts {
+		completed = append(completed, minio.CompletePart{PartNumber: part.PartNumber, ETag: part.ETag})
+	}
+	_, err := c.core.CompleteMultipartUpload(ctx, c.bucket, key, uploadID, completed, minio.PutObjectOptions{})
+	return err
+}
+
+// putObject uploads the object in a single request.
+func (c *s3Client) putObject(ctx context.Context, key string, data []byte) error {
+	_, err := c.core.PutObject(ctx, c.bucket, key, bytes.NewReader(data), int64(len(data)), md5Base64(data), "",
+		minio.PutObjectOptions{})
+	return err
+}
+
+// md5Base64 returns the base64 encoded MD5 checksum of data, verified by the
+// object store.
+func md5Base64(data []byte) string {
+	sum := md5.Sum(data)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
@@ -51,6 +51,10 @@ const (
 	// before the volume is provisioned in supervisor cluster.
 	CSIPvNotFoundInPvcSpecFault = "csi.fault.nonstorage.PvNotFoundInPvcSpec"
 
+	// CSIProvisioningDryRunFault is the fault type when CreateVolume only reports the placement decision of
+	// a volume whose PVC requests a dry run of the provisioning.
+	CSIProvisioningDryRunFault = "csi.fault.nonstorage.ProvisioningDryRun"
+
 	// CSIVSanFileServiceDisabledFault is the fault type when trying to create a RWX volume on a cluster which vsan file
 	// service is disabled.
 	CSIVSanFileServiceDisabledFault = "csi.fault.invalidconfig.VSanFileServiceDisabled"
@@ -69,6 +73,21 @@ const (
 	// CSIInvalidStoragePolicyConfigurationFault is the fault type returned when the user provides invalid storage policy.
 	CSIInvalidStoragePolicyConfigurationFault = "csi.fault.invalidconfig.InvalidStoragePolicyConfiguration"
 
+	// CSIVCenterUnreachableFault is the fault type returned when the vCenter can't be reached.
+	CSIVCenterUnreachableFault = "csi.fault.nonstorage.VCenterUnreachable"
+	// CSIStoragePolicyNotFoundFault is the fault type returned when the storage policy of the StorageClass
+	// doesn't exist in the vCenter.
+	CSIStoragePolicyNotFoundFault = "csi.fault.invalidconfig.StoragePolicyNotFound"
+	// CSIQuotaExceededFault is the fault type returned when a storage quota of the namespace is exceeded.
+	CSIQuotaExceededFault = "csi.fault.QuotaExceeded"
+	// CSIDatastoreFullFault is the fault type returned when the datastore doesn't have enough free space.
+	CSIDatastoreFullFault = "csi.fault.DatastoreFull"
+	// CSITaskTimeoutFault is the fault type returned when a vCenter task doesn't complete in time.
+	CSITaskTimeoutFault = "csi.fault.TaskTimeout"
+	// CSIReadOnlyModeFault is the fault type returned when a request changing storage is rejected because the
+	// controller is in read-only mode.
+	CSIReadOnlyModeFault = "csi.fault.nonstorage.ReadOnlyMode"
+
 	// Below is the list of faults coming from downstream vCenter components that we want to classify
 	// as non-storage faults.
 
@@ -77,4 +96,9 @@ const (
 	VimFaultInvalidHostState = VimFaultPrefix + "InvalidHostState"
 	// VimFaultHostNotConnected is the fault returned from CNS when host is not connected.
 	VimFaultHostNotConnected = VimFaultPrefix + "HostNotConnected"
+	// VimFaultNoDiskSpace is the fault returned from CNS when the datastore is out of space.
+	VimFaultNoDiskSpace = VimFaultPrefix + "NoDiskSpace"
+	// VimFaultInsufficientStorageSpace is the fault returned from CNS when the datastore doesn't have enough
+	// free space for the volume.
+	VimFaultInsufficientStorageSpace = VimFaultPrefix + "InsufficientStorageSpace"
 )
@@ -0,0 +1,170 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package fault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// ErrorDomain is the domain of the ErrorInfo detail of the gRPC status of an Error.
+const ErrorDomain = "csi.vsphere.vmware.com"
+
+var (
+	// faultCodes maps the fault types to the gRPC code of the CSI responses failing with them.
+	faultCodes = map[string]codes.Code{
+		CSIVCenterUnreachableFault:    codes.Unavailable,
+		CSIStoragePolicyNotFoundFault: codes.InvalidArgument,
+		CSIQuotaExceededFault:         codes.ResourceExhausted,
+		CSIDatastoreFullFault:         codes.ResourceExhausted,
+		CSITaskTimeoutFault:           codes.DeadlineExceeded,
+		CSIReadOnlyModeFault:          codes.Unavailable,
+	}
+	// vimFaultTypes maps the vim faults from downstream components to the fault types they stand for.
+	vimFaultTypes = map[string]string{
+		VimFaultNoDiskSpace:              CSIDatastoreFullFault,
+		VimFaultInsufficientStorageSpace: CSIDatastoreFullFault,
+	}
+)
+
+// Error is a failure classified by fault type. Its gRPC status has the code of the fault type and an
+// ErrorInfo detail holding the fault type, and its message starts with the fault type, so that the
+// failure can be acted upon, e.g. from the events recorded by the sidecars, without parsing the message.
+type Error struct {
+	// FaultType is the fault type of the failure, e.g. CSIDatastoreFullFault.
+	FaultType string
+	// Code is the gRPC code of the failure.
+	Code codes.Code
+	// Message describes the failure.
+	Message string
+}
+
+// Error returns the message of the failure prefixed with its fault type.
+func (e *Error) Error() string {
+	return fmt.Sprintf("[%s] %s", e.FaultType, e.Message)
+}
+
+// GRPCStatus returns the gRPC status of the failure, used by the gRPC server to build the response.
+func (e *Error) GRPCStatus() *status.Status {
+	st := status.New(e.Code, e.Error())
+	detailed, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   errorInfoReason(e.FaultType),
+		Domain:   ErrorDomain,
+		Metadata: map[string]string{"faultType": e.FaultType},
+	})
+	if err != nil {
+		return st
+	}
+	return detailed
+}
+
+// errorInfoReason converts the last element of a fault type to the UPPER_SNAKE_CASE reason of an
+// ErrorInfo, e.g. "csi.fault.DatastoreFull" to "DATASTORE_FULL".
+func errorInfoReason(faultType string) string {
+	name := faultType[strings.LastIndex(faultType, ".")+1:]
+	var reason strings.Builder
+	for i, r := range name {
+		if i > 0 && unicode.IsUpper(r) && !unicode.IsUpper(rune(name[i-1])) {
+			reason.WriteByte('_')
+		}
+		reason.WriteRune(unicode.ToUpper(r))
+	}
+	return reason.String()
+}
+
+// Code returns the gRPC code of the CSI responses failing with the given fault type, or defaultCode if
+// the fault type doesn't determine the code.
+func Code(faultType string, defaultCode codes.Code) codes.Code {
+	if code, ok := faultCodes[faultType]; ok {
+		return code
+	}
+	return defaultCode
+}
+
+// LogNewErrorf logs and returns an Error with the given fault type and the gRPC code of the fault type,
+// or codes.Internal if the fault type doesn't determine the code.
+func LogNewErrorf(log *zap.SugaredLogger, faultType string, format string, a ...interface{}) error {
+	err := &Error{
+		FaultType: faultType,
+		Code:      Code(faultType, codes.Internal),
+		Message:   fmt.Sprintf(format, a...),
+	}
+	log.Desugar().WithOptions(zap.AddCallerSkip(1)).Sugar().Error(err.Error())
+	return err
+}
+
+// FaultTypeFromError returns the fault type of the failure described by err, or defaultFaultType if the
+// failure is not classified.
+func FaultTypeFromError(err error, defaultFaultType string) string {
+	if err == nil {
+		return defaultFaultType
+	}
+	var faultErr *Error
+	if errors.As(err, &faultErr) {
+		return faultErr.FaultType
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return CSIVCenterUnreachableFault
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return CSITaskTimeoutFault
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return CSIVCenterUnreachableFault
+	}
+	if apierrors.IsForbidden(err) && strings.Contains(err.Error(), "exceeded quota") {
+		return CSIQuotaExceededFault
+	}
+	// govmomi doesn't return a typed error when the storage policy doesn't exist.
+	if strings.Contains(err.Error(), "no pbm profile found with name") {
+		return CSIStoragePolicyNotFoundFault
+	}
+	return defaultFaultType
+}
+
+// WithFaultType returns err as an Error with the given fault type. The gRPC code of err is kept unless
+// the fault type determines it. Vim faults standing for a fault type of the driver are replaced by it.
+func WithFaultType(err error, faultType string) error {
+	if err == nil || faultType == "" {
+		return err
+	}
+	var faultErr *Error
+	if errors.As(err, &faultErr) {
+		return err
+	}
+	if mapped, ok := vimFaultTypes[faultType]; ok {
+		faultType = mapped
+	}
+	st := status.Convert(err)
+	return &Error{
+		FaultType: faultType,
+		Code:      Code(faultType, st.Code()),
+		Message:   st.Message(),
+	}
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package fault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestFaultTypeFromError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{"vCenter unreachable", &url.Error{Op: "Post", URL: "https://vc.example.com/sdk",
+			Err: errors.New("connection refused")}, CSIVCenterUnreachableFault},
+		{"task timeout", fmt.Errorf("failed to wait for task: %w", context.DeadlineExceeded), CSITaskTimeoutFault},
+		{"quota exceeded", apierrors.NewForbidden(schema.GroupResource{Resource: "persistentvolumeclaims"},
+			"pvc-1", errors.New("exceeded quota: storage-quota")), CSIQuotaExceededFault},
+		{"storage policy missing", errors.New(`no pbm profile found with name: "gold"`),
+			CSIStoragePolicyNotFoundFault},
+		{"classified error", &Error{FaultType: CSIDatastoreFullFault}, CSIDatastoreFullFault},
+		{"unclassified error", errors.New("boom"), CSIInternalFault},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, FaultTypeFromError(test.err, CSIInternalFault), test.name)
+	}
+}
+
+func TestWithFaultType(t *testing.T) {
+	// The code of the fault type replaces the code of the error.
+	err := WithFaultType(status.Error(codes.Internal, "failed to create volume"), VimFaultNoDiskSpace)
+	st := status.Convert(err)
+	assert.Equal(t, codes.ResourceExhausted, st.Code())
+	assert.Equal(t, "[csi.fault.DatastoreFull] failed to create volume", st.Message())
+	assert.Len(t, st.Details(), 1)
+	errorInfo, ok := st.Details()[0].(*errdetails.ErrorInfo)
+	assert.True(t, ok)
+	assert.Equal(t, "DATASTORE_FULL", errorInfo.Reason)
+	assert.Equal(t, ErrorDomain, errorInfo.Domain)
+	assert.Equal(t, CSIDatastoreFullFault, errorInfo.Metadata["faultType"])
+
+	// The code of the error is kept for the fault types which don't determine one.
+	err = WithFaultType(status.Error(codes.InvalidArgument, "invalid capability"), CSIInternalFault)
+	st = status.Convert(err)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	assert.Equal(t, "[csi.fault.Internal] invalid capability", st.Message())
+
+	assert.Nil(t, WithFaultType(nil, CSIInternalFault))
+	assert.Equal(t, "VCENTER_UNREACHABLE", errorInfoReason(CSIVCenterUnreachableFault))
+}
@@ -85,10 +85,55 @@ const (
 	// PrometheusInaccessibleVolumes represents inaccessible volumes.
 	PrometheusInaccessibleVolumes = "inaccessible-volumes"
 
+	// PrometheusReadDirection represents read IO in volume IO statistics.
+	PrometheusReadDirection = "read"
+	// PrometheusWriteDirection represents write IO in volume IO statistics.
+	PrometheusWriteDirection = "write"
+
+	// Attach and detach latency stages
+
+	// PrometheusVCTaskStage is the time taken by the CNS attach or detach task
+	// on vCenter.
+	PrometheusVCTaskStage = "vc-task"
+	// PrometheusCSIRequestStage is the time from the CSI ControllerPublishVolume
+	// or ControllerUnpublishVolume request to the completion of its vCenter task.
+	PrometheusCSIRequestStage = "csi-request"
+	// PrometheusVolumeAttachmentStage is the time from the creation, or the
+	// deletion request, of a VolumeAttachment to its status being updated, or
+	// to its removal.
+	PrometheusVolumeAttachmentStage = "volume-attachment"
+
 	// PrometheusPassStatus represents a successful API run.
 	PrometheusPassStatus = "pass"
 	// PrometheusFailStatus represents an unsuccessful API run.
 	PrometheusFailStatus = "fail"
+
+	// PrometheusFSSEnabled represents a feature state switch evaluated to true.
+	PrometheusFSSEnabled = "enabled"
+	// PrometheusFSSDisabled represents a feature state switch set to false.
+	PrometheusFSSDisabled = "disabled"
+	// PrometheusFSSMissing represents a feature state switch evaluated to
+	// false because it is missing from its ConfigMap.
+	PrometheusFSSMissing = "missing"
+	// PrometheusFSSError represents a feature state switch evaluated to false
+	// because its ConfigMap couldn't be read or its value isn't a boolean.
+	PrometheusFSSError = "error"
+
+	// Backoff statuses of the responses to the sidecars
+
+	// PrometheusBackoffNone represents a successful response.
+	PrometheusBackoffNone = "none"
+	// PrometheusBackoffRetry represents a transient error, returned when the
+	// driver or vCenter is busy, after which the sidecar retries with backoff.
+	// A high rate suggests lowering the worker threads of the sidecar.
+	PrometheusBackoffRetry = "retry"
+	// PrometheusBackoffTimeout represents a request which timed out or was
+	// cancelled by the sidecar. A high rate suggests raising the timeout of
+	// the sidecar.
+	PrometheusBackoffTimeout = "timeout"
+	// PrometheusBackoffFinal represents an error which retrying the request
+	// doesn't fix.
+	PrometheusBackoffFinal = "final"
 )
 
 var (
@@ -134,6 +179,18 @@ var (
 		// Possible status - "pass", "fail"
 		[]string{"optype", "status"})
 
+	// AttachDetachLatencyHistVec is a histogram vector metric to observe the
+	// latency of successful volume attach and detach operations at each stage
+	// of their path, from the CSI request to the VolumeAttachment update.
+	AttachDetachLatencyHistVec = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "vsphere_csi_attach_detach_latency_seconds",
+		Help:    "Histogram vector for the latency of volume attach and detach operations per stage.",
+		Buckets: []float64{1, 2, 5, 10, 15, 20, 30, 60, 120, 300, 600},
+	},
+		// Possible optype - "attach-volume", "detach-volume"
+		// Possible stage - "vc-task", "csi-request", "volume-attachment"
+		[]string{"optype", "vcenter", "stage"})
+
 	// VolumeHealthGaugeVec is a gauge metric to observe the number of accessible and inaccessible volumes.
 	VolumeHealthGaugeVec = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "vsphere_volume_health_gauge",
@@ -159,4 +216,141 @@ var (
 		Help:    "Histogram vector for individual request to vCenter",
 		Buckets: []float64{2, 5, 10, 15, 20, 25, 30, 60, 120, 180},
 	}, []string{"request", "client", "status"})
+
+	// VolumeIOPSGaugeVec is a gauge metric to observe the average IOPS of an
+	// attached volume as reported by the vCenter performance manager.
+	VolumeIOPSGaugeVec = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vsphere_volume_iops",
+		Help: "Average number of IO operations per second on the volume",
+	},
+		// Possible direction - "read", "write"
+		[]string{"namespace", "pvc", "direction"})
+
+	// VolumeLatencyGaugeVec is a gauge metric to observe the average IO latency
+	// of an attached volume in milliseconds.
+	VolumeLatencyGaugeVec = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vsphere_volume_latency_milliseconds",
+		Help: "Average IO latency on the volume in milliseconds",
+	},
+		// Possible direction - "read", "write"
+		[]string{"namespace", "pvc", "direction"})
+
+	// VolumeThroughputGaugeVec is a gauge metric to observe the average
+	// throughput of an attached volume in kilobytes per second.
+	VolumeThroughputGaugeVec = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vsphere_volume_throughput_kilobytes_per_second",
+		Help: "Average throughput on the volume in kilobytes per second",
+	},
+		// Possible direction - "read", "write"
+		[]string{"namespace", "pvc", "direction"})
+
+	// SupervisorTokenAgeGauge is a gauge metric to observe the age, in seconds,
+	// of the token used by pvCSI to authenticate against the supervisor cluster.
+	SupervisorTokenAgeGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "vsphere_supervisor_token_age_seconds",
+		Help: "Age of the token used to authenticate against the supervisor cluster",
+	})
+
+	// SupervisorTokenRefreshFailuresCounter is a counter metric to observe the
+	// number of failures to refresh the supervisor cluster token.
+	SupervisorTokenRefreshFailuresCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "vsphere_supervisor_token_refresh_failures_total",
+		Help: "Number of failures to refresh the token used to authenticate against the supervisor cluster",
+	})
+
+	// WCPCapabilityCheckFailuresCounter is a counter metric to observe the
+	// number of failures to read a capability of the supervisor cluster.
+	WCPCapabilityCheckFailuresCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "vsphere_wcp_capability_check_failures_total",
+		Help: "Number of failures to read a capability of the supervisor cluster",
+	}, []string{"capability"})
+
+	// InformerEventHandlerFailuresCounter is a counter metric to observe the
+	// number of informer events the listeners failed to handle.
+	InformerEventHandlerFailuresCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "vsphere_informer_event_handler_failures_total",
+		Help: "Number of informer events the listeners failed to handle",
+	},
+		// Possible event - "add", "update", "delete"
+		// Possible reason - "panic", "nil-object", "unexpected-type"
+		[]string{"listener", "event", "reason"})
+
+	// OrchestratorCacheEntriesGaugeVec is a gauge metric to observe the number
+	// of entries in the container orchestrator caches.
+	OrchestratorCacheEntriesGaugeVec = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vsphere_orchestrator_cache_entries",
+		Help: "Number of entries in the container orchestrator caches",
+	}, []string{"cache"})
+
+	// OrchestratorCacheLookupsCounter is a counter metric to observe the
+	// lookups on the container orchestrator caches.
+	OrchestratorCacheLookupsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "vsphere_orchestrator_cache_lookups_total",
+		Help: "Number of lookups on the container orchestrator caches",
+	},
+		// Possible result - "hit", "miss"
+		[]string{"cache", "result"})
+
+	// OrchestratorCacheEvictionsCounter is a counter metric to observe the
+	// number of entries evicted from the bounded container orchestrator caches.
+	OrchestratorCacheEvictionsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "vsphere_orchestrator_cache_evictions_total",
+		Help: "Number of entries evicted from the bounded container orchestrator caches",
+	}, []string{"cache"})
+
+	// FSSEvaluationsCounter is a counter metric to observe the evaluations of
+	// the feature state switches and their outcome.
+	FSSEvaluationsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "vsphere_fss_evaluations_total",
+		Help: "Number of evaluations of the feature state switches",
+	},
+		// Possible outcome - "enabled", "disabled", "missing", "error"
+		[]string{"feature", "outcome"})
+
+	// DatastoreUsageAlarmGaugeVec is a gauge metric to observe the datastores
+	// deprioritized for new volumes because of a triggered usage alarm in
+	// vCenter. The value is 1 for a yellow alarm and 2 for a red alarm.
+	DatastoreUsageAlarmGaugeVec = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vsphere_datastore_usage_alarm",
+		Help: "Datastores deprioritized for new volumes because of a usage alarm, by alarm severity",
+	}, []string{"vcenter", "datastore"})
+
+	// DatastoreUsageAlarmSkipsCounter is a counter metric to observe the
+	// datastores skipped for new volumes because of a usage alarm.
+	DatastoreUsageAlarmSkipsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "vsphere_datastore_usage_alarm_skips_total",
+		Help: "Number of times a datastore with a usage alarm was skipped for a new volume",
+	}, []string{"vcenter", "datastore"})
+
+	// ProvisioningSegmentBackoffSkipsCounter is a counter metric to observe
+	// the topology segments skipped for a new volume because provisioning
+	// recently failed in them repeatedly.
+	ProvisioningSegmentBackoffSkipsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "vsphere_provisioning_segment_backoff_skips_total",
+		Help: "Number of times a topology segment in provisioning backoff was skipped for a new volume",
+	}, []string{"vcenter", "segment"})
+
+	// DatastorePropertyCacheLookupsCounter is a counter metric to observe
+	// the lookups of datastore properties in the datastore property cache,
+	// by result: hit or miss.
+	DatastorePropertyCacheLookupsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "vsphere_datastore_property_cache_lookups_total",
+		Help: "Number of lookups of datastore properties in the datastore property cache, by result",
+	}, []string{"vcenter", "result"})
+
+	// SidecarResponsesCounter is a counter metric to observe the gRPC codes
+	// returned to the sidecars, along with the backoff status of the codes.
+	SidecarResponsesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "vsphere_csi_sidecar_responses_total",
+		Help: "Number of responses to the CSI sidecars, by gRPC code and backoff status",
+	},
+		// Possible backoff - "none", "retry", "timeout", "final"
+		[]string{"sidecar", "method", "code", "backoff"})
+
+	// SidecarInFlightRequestsGaugeVec is a gauge metric to observe the
+	// requests of the sidecars being processed by the driver.
+	SidecarInFlightRequestsGaugeVec = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vsphere_csi_sidecar_inflight_requests",
+		Help: "Number of requests of the CSI sidecars being processed",
+	}, []string{"sidecar", "method"})
 )
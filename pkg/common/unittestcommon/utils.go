@@ -97,6 +97,18 @@ func (c *FakeK8SOrchestrator) IsFSSEnabled(ctx context.Context, featureName stri
 	return false
 }
 
+// GetFeatureStates returns the FSS values of all the features
+func (c *FakeK8SOrchestrator) GetFeatureStates(ctx context.Context) map[string]bool {
+	c.featureStatesLock.RLock()
+	defer c.featureStatesLock.RUnlock()
+	featureStates := make(map[string]bool, len(c.featureStates))
+	for name, flag := range c.featureStates {
+		featureState, err := strconv.ParseBool(flag)
+		featureStates[name] = err == nil && featureState
+	}
+	return featureStates
+}
+
 // IsFakeAttachAllowed checks if the passed volume can be fake attached and mark it as fake attached.
 func (c *FakeK8SOrchestrator) IsFakeAttachAllowed(
 	ctx context.Context,
@@ -328,6 +340,26 @@ func (c *FakeK8SOrchestrator) InitializeCSINodes(ctx context.Context) error {
 	return nil
 }
 
+// GetSnapshotVCenter returns the vCenter a CSI snapshot ID is scoped to.
+func (c *FakeK8SOrchestrator) GetSnapshotVCenter(ctx context.Context, csiSnapshotID string) (string, bool) {
+	_, _, vCenterHost, err := common.ParseCSISnapshotIDWithVCenter(csiSnapshotID)
+	return vCenterHost, err == nil && vCenterHost != ""
+}
+
+// SetSnapshotVCenter caches the vCenter of the snapshot with the given CSI snapshot ID.
+func (c *FakeK8SOrchestrator) SetSnapshotVCenter(ctx context.Context, csiSnapshotID string, vCenterHost string) {
+}
+
+// ForgetSnapshotVCenter removes the cached vCenter of the snapshot with the given CSI snapshot ID.
+func (c *FakeK8SOrchestrator) ForgetSnapshotVCenter(ctx context.Context, csiSnapshotID string) {
+}
+
+// GetSupervisorPVCForGuestPVC returns the supervisor PVC backing the given guest PVC.
+func (c *FakeK8SOrchestrator) GetSupervisorPVCForGuestPVC(ctx context.Context, namespace string,
+	name string) (string, string, bool) {
+	return "", "", false
+}
+
 // configFromVCSim starts a vcsim instance and returns config for use against the
 // vcsim instance. The vcsim instance is configured with an empty tls.Config.
 func configFromVCSim(vcsimParams VcsimParams, isTopologyEnv bool) (*config.Config, func()) {
@@ -0,0 +1,180 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package unittestcommon
+
+import (
+	"context"
+	"sort"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+
+	cnsvolume "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
+)
+
+// FakeVolumeManager is a volume manager keeping the CNS volumes and the
+// metadata and the snapshots of virtual disks in memory. Only the methods used by unit tests
+// are implemented, calling any other method panics.
+type FakeVolumeManager struct {
+	cnsvolume.Manager
+	// Volumes are the volumes registered with CNS, keyed by volume ID.
+	Volumes map[string]*cnstypes.CnsVolume
+	// Metadata is the metadata of the virtual disks, keyed by volume ID.
+	Metadata map[string]map[string]string
+	// Snapshots are the CNS snapshot IDs of the volumes, keyed by volume ID.
+	Snapshots map[string][]string
+	// DeletedVolumes are the IDs of the volumes whose virtual disk got
+	// deleted.
+	DeletedVolumes []string
+}
+
+// NewFakeVolumeManager returns a FakeVolumeManager with the given metadata
+// of virtual disks, keyed by volume ID.
+func NewFakeVolumeManager(metadata map[string]map[string]string) *FakeVolumeManager {
+	if metadata == nil {
+		metadata = make(map[string]map[string]string)
+	}
+	return &FakeVolumeManager{
+		Volumes:   make(map[string]*cnstypes.CnsVolume),
+		Metadata:  metadata,
+		Snapshots: make(map[string][]string),
+	}
+}
+
+// RetrieveVStorageObjectMetadataValue returns the value of a metadata key of
+// a virtual disk.
+func (m *FakeVolumeManager) RetrieveVStorageObjectMetadataValue(ctx context.Context, volumeID string,
+	key string) (string, error) {
+	return m.Metadata[volumeID][key], nil
+}
+
+// UpdateVStorageObjectMetadata adds or updates the given metadata of a
+// virtual disk and removes the metadata entries for deleteKeys.
+func (m *FakeVolumeManager) UpdateVStorageObjectMetadata(ctx context.Context, volumeID string,
+	metadata map[string]string, deleteKeys []string) error {
+	if m.Metadata[volumeID] == nil {
+		m.Metadata[volumeID] = make(map[string]string)
+	}
+	for key, value := range metadata {
+		m.Metadata[volumeID][key] = value
+	}
+	for _, key := range deleteKeys {
+		delete(m.Metadata[volumeID], key)
+	}
+	return nil
+}
+
+// ListVStorageObjectsWithMetadataKey returns the sorted IDs of the virtual
+// disks having the given metadata key.
+func (m *FakeVolumeManager) ListVStorageObjectsWithMetadataKey(ctx context.Context, key string) ([]string, error) {
+	var volumeIDs []string
+	for volumeID, metadata := range m.Metadata {
+		if _, ok := metadata[key]; ok {
+			volumeIDs = append(volumeIDs, volumeID)
+		}
+	}
+	sort.Strings(volumeIDs)
+	return volumeIDs, nil
+}
+
+// DeleteVStorageObject deletes a virtual disk.
+func (m *FakeVolumeManager) DeleteVStorageObject(ctx context.Context, volumeID string) error {
+	delete(m.Metadata, volumeID)
+	m.DeletedVolumes = append(m.DeletedVolumes, volumeID)
+	return nil
+}
+
+// CreateVolume registers the backing disk of a block volume create spec as a
+// CNS volume.
+func (m *FakeVolumeManager) CreateVolume(ctx context.Context, spec *cnstypes.CnsVolumeCreateSpec,
+	extraParams interface{}) (*cnsvolume.CnsVolumeInfo, string, error) {
+	volumeID := cnstypes.CnsVolumeId{
+		Id: spec.BackingObjectDetails.(*cnstypes.CnsBlockBackingDetails).BackingDiskId,
+	}
+	m.Volumes[volumeID.Id] = &cnstypes.CnsVolume{
+		VolumeId:             volumeID,
+		Name:                 spec.Name,
+		VolumeType:           spec.VolumeType,
+		BackingObjectDetails: spec.BackingObjectDetails,
+	}
+	return &cnsvolume.CnsVolumeInfo{VolumeID: volumeID}, "", nil
+}
+
+// DeleteVolume removes a volume from CNS, and deletes its virtual disk if
+// deleteDisk is set.
+func (m *FakeVolumeManager) DeleteVolume(ctx context.Context, volumeID string, deleteDisk bool) (string, error) {
+	delete(m.Volumes, volumeID)
+	if deleteDisk {
+		return "", m.DeleteVStorageObject(ctx, volumeID)
+	}
+	return "", nil
+}
+
+// QueryVolume returns the registered volumes with the IDs of the filter.
+func (m *FakeVolumeManager) QueryVolume(ctx context.Context, queryFilter cnstypes.CnsQueryFilter) (
+	*cnstypes.CnsQueryResult, error) {
+	result := &cnstypes.CnsQueryResult{}
+	for _, volumeID := range queryFilter.VolumeIds {
+		if volume, ok := m.Volumes[volumeID.Id]; ok {
+			result.Volumes = append(result.Volumes, *volume)
+		}
+	}
+	return result, nil
+}
+
+// QueryVolumeAsync returns the registered volumes with the IDs of the
+// filter. The selection is ignored.
+func (m *FakeVolumeManager) QueryVolumeAsync(ctx context.Context, queryFilter cnstypes.CnsQueryFilter,
+	querySelection *cnstypes.CnsQuerySelection) (*cnstypes.CnsQueryResult, error) {
+	return m.QueryVolume(ctx, queryFilter)
+}
+
+// QuerySnapshots returns the snapshots of the volume of the first query spec,
+// within the range of the cursor of the filter.
+func (m *FakeVolumeManager) QuerySnapshots(ctx context.Context, snapshotQueryFilter cnstypes.CnsSnapshotQueryFilter) (
+	*cnstypes.CnsSnapshotQueryResult, error) {
+	volumeID := snapshotQueryFilter.SnapshotQuerySpecs[0].VolumeId.Id
+	snapshotIDs := m.Snapshots[volumeID]
+	offset, limit := int64(0), int64(len(snapshotIDs))
+	if cursor := snapshotQueryFilter.Cursor; cursor != nil {
+		offset, limit = cursor.Offset, cursor.Limit
+	}
+	result := &cnstypes.CnsSnapshotQueryResult{
+		Cursor: cnstypes.CnsCursor{TotalRecords: int64(len(snapshotIDs))},
+	}
+	for i := offset; i < int64(len(snapshotIDs)) && i < offset+limit; i++ {
+		result.Entries = append(result.Entries, cnstypes.CnsSnapshotQueryResultEntry{
+			Snapshot: cnstypes.CnsSnapshot{
+				SnapshotId: cnstypes.CnsSnapshotId{Id: snapshotIDs[i]},
+				VolumeId:   cnstypes.CnsVolumeId{Id: volumeID},
+			},
+		})
+		result.Cursor.Offset = i + 1
+	}
+	return result, nil
+}
+
+// DeleteSnapshot deletes a snapshot of a volume.
+func (m *FakeVolumeManager) DeleteSnapshot(ctx context.Context, volumeID string, snapshotID string) error {
+	snapshotIDs := m.Snapshots[volumeID]
+	for i, id := range snapshotIDs {
+		if id == snapshotID {
+			m.Snapshots[volumeID] = append(snapshotIDs[:i:i], snapshotIDs[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
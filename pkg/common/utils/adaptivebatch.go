@@ -0,0 +1,74 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// AdaptiveBatchSize adjusts the page size of paginated CNS queries to the
+// response times of vCenter. The page size is halved when a page fails or
+// takes longer than the target latency, and grows by a quarter when a page
+// takes less than half of the target latency, within the given bounds.
+type AdaptiveBatchSize struct {
+	lock          sync.Mutex
+	size          int64
+	minSize       int64
+	maxSize       int64
+	targetLatency time.Duration
+}
+
+// NewAdaptiveBatchSize returns an AdaptiveBatchSize starting at the initial
+// page size.
+func NewAdaptiveBatchSize(initialSize, minSize, maxSize int64, targetLatency time.Duration) *AdaptiveBatchSize {
+	return &AdaptiveBatchSize{
+		size:          initialSize,
+		minSize:       minSize,
+		maxSize:       maxSize,
+		targetLatency: targetLatency,
+	}
+}
+
+// Size returns the page size of the next query.
+func (b *AdaptiveBatchSize) Size() int64 {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.size
+}
+
+// Observe adjusts the page size after a query which took the given latency
+// and returned the given error. It returns true if the page size changed.
+func (b *AdaptiveBatchSize) Observe(latency time.Duration, err error) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	size := b.size
+	if err != nil || latency > b.targetLatency {
+		size /= 2
+	} else if latency < b.targetLatency/2 {
+		size += size/4 + 1
+	}
+	if size < b.minSize {
+		size = b.minSize
+	}
+	if size > b.maxSize {
+		size = b.maxSize
+	}
+	changed := size != b.size
+	b.size = size
+	return changed
+}
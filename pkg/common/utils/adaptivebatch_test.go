@@ -0,0 +1,49 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveBatchSize(t *testing.T) {
+	batchSize := NewAdaptiveBatchSize(100, 10, 200, 10*time.Second)
+	steps := []struct {
+		latency  time.Duration
+		err      error
+		expected int64
+	}{
+		{latency: 6 * time.Second, expected: 100},
+		{latency: time.Second, expected: 126},
+		{latency: time.Second, expected: 158},
+		{latency: time.Second, expected: 198},
+		{latency: time.Second, expected: 200},
+		{latency: 20 * time.Second, expected: 100},
+		{latency: time.Second, err: errors.New("timeout"), expected: 50},
+		{latency: time.Second, err: errors.New("timeout"), expected: 25},
+		{latency: time.Second, err: errors.New("timeout"), expected: 12},
+		{latency: time.Second, err: errors.New("timeout"), expected: 10},
+	}
+	for i, step := range steps {
+		batchSize.Observe(step.latency, step.err)
+		if size := batchSize.Size(); size != step.expected {
+			t.Fatalf("step %d: expected page size %d, got %d", i, step.expected, size)
+		}
+	}
+}
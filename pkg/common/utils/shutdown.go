@@ -0,0 +1,86 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+)
+
+// DefaultShutdownDrainTimeout is the default maximum duration a process
+// drains in-flight operations for on SIGTERM. It stays below the default
+// termination grace period of 30 seconds of pods.
+const DefaultShutdownDrainTimeout = 25 * time.Second
+
+// cacheFlusher persists or hands over the in-memory state of a component.
+type cacheFlusher struct {
+	name  string
+	flush func(ctx context.Context)
+}
+
+var (
+	cacheFlushers     []cacheFlusher
+	cacheFlushersLock sync.Mutex
+)
+
+// RegisterCacheFlusher registers a function flushing the in-memory state of a
+// component, which would otherwise be lost when the process exits. The
+// registered functions are called by FlushCaches on shutdown.
+func RegisterCacheFlusher(name string, flush func(ctx context.Context)) {
+	cacheFlushersLock.Lock()
+	defer cacheFlushersLock.Unlock()
+	cacheFlushers = append(cacheFlushers, cacheFlusher{name: name, flush: flush})
+}
+
+// FlushCaches calls the registered cache flushers in the order they were
+// registered. Flushers which are not called before ctx is done are skipped.
+func FlushCaches(ctx context.Context) {
+	log := logger.GetLogger(ctx)
+	cacheFlushersLock.Lock()
+	flushers := append([]cacheFlusher(nil), cacheFlushers...)
+	cacheFlushersLock.Unlock()
+	for _, flusher := range flushers {
+		if ctx.Err() != nil {
+			log.Warnf("Skipping the flush of %s. Err: %v", flusher.name, ctx.Err())
+			continue
+		}
+		log.Infof("Flushing %s", flusher.name)
+		flusher.flush(ctx)
+	}
+}
+
+// GetShutdownDrainTimeout returns the maximum duration the process drains
+// in-flight operations for on SIGTERM, set with X_CSI_SHUTDOWN_DRAIN_TIMEOUT.
+func GetShutdownDrainTimeout(ctx context.Context) time.Duration {
+	log := logger.GetLogger(ctx)
+	value := os.Getenv(csitypes.EnvVarShutdownDrainTimeout)
+	if value == "" {
+		return DefaultShutdownDrainTimeout
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout < 0 {
+		log.Warnf("invalid value %q for %s, using the default of %v. Err: %v",
+			value, csitypes.EnvVarShutdownDrainTimeout, DefaultShutdownDrainTimeout, err)
+		return DefaultShutdownDrainTimeout
+	}
+	return timeout
+}
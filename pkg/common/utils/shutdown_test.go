@@ -0,0 +1,43 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFlushCaches(t *testing.T) {
+	defer func() { cacheFlushers = nil }()
+	var flushed []string
+	RegisterCacheFlusher("first", func(ctx context.Context) { flushed = append(flushed, "first") })
+	RegisterCacheFlusher("second", func(ctx context.Context) { flushed = append(flushed, "second") })
+
+	FlushCaches(context.Background())
+	if len(flushed) != 2 || flushed[0] != "first" || flushed[1] != "second" {
+		t.Errorf("expected the caches to be flushed in registration order, got %v", flushed)
+	}
+
+	// No cache is flushed once the drain timeout is over.
+	flushed = nil
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	FlushCaches(ctx)
+	if len(flushed) != 0 {
+		t.Errorf("expected no cache to be flushed after the timeout, got %v", flushed)
+	}
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package vcsim runs the driver against an in-process govmomi vCenter
+// simulator. The simulator registers the CNS and PBM endpoints, and FCDs are
+// emulated in memory by the simulator's VStorageObjectManager, so controller
+// and syncer flows can be exercised without a real vCenter.
+//
+// The simulator is only compiled into binaries built with the "vcsim" build
+// tag, e.g. "go build -tags vcsim ./cmd/vsphere-csi". Other binaries fail to
+// start in simulator mode.
+package vcsim
+
+import (
+	"context"
+	"os"
+	"strconv"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+const (
+	// EnvVarSimulatorMode enables the simulated backend when set to "true".
+	EnvVarSimulatorMode = "X_CSI_VCSIM_MODE"
+	// EnvVarSimulatorDatacenters overrides the number of simulated datacenters.
+	EnvVarSimulatorDatacenters = "X_CSI_VCSIM_DATACENTERS"
+	// EnvVarSimulatorDatastores overrides the number of simulated datastores.
+	EnvVarSimulatorDatastores = "X_CSI_VCSIM_DATASTORES"
+	// EnvVarSimulatorConfigDir is the directory the generated vsphere.conf is
+	// written to. Defaults to the OS temp directory.
+	EnvVarSimulatorConfigDir = "X_CSI_VCSIM_CONFIG_DIR"
+)
+
+// Params holds the inventory shape of the simulated vCenter.
+type Params struct {
+	Datacenters     int
+	Clusters        int
+	HostsPerCluster int
+	Datastores      int
+	VMsPerCluster   int
+	// ConfigDir is the directory the generated vsphere.conf is written to.
+	ConfigDir string
+}
+
+// IsEnabled returns true if the driver is asked to run in simulator mode.
+func IsEnabled() bool {
+	enabled, err := strconv.ParseBool(os.Getenv(EnvVarSimulatorMode))
+	return err == nil && enabled
+}
+
+// ParamsFromEnv returns simulator parameters with env var overrides applied
+// on top of the vcsim VPX defaults.
+func ParamsFromEnv(ctx context.Context) Params {
+	log := logger.GetLogger(ctx)
+	params := Params{
+		Datacenters:     1,
+		Clusters:        1,
+		HostsPerCluster: 3,
+		Datastores:      1,
+		VMsPerCluster:   2,
+		ConfigDir:       os.TempDir(),
+	}
+	if v := os.Getenv(EnvVarSimulatorDatacenters); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			params.Datacenters = n
+		} else {
+			log.Warnf("ignoring invalid value %q for %s", v, EnvVarSimulatorDatacenters)
+		}
+	}
+	if v := os.Getenv(EnvVarSimulatorDatastores); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			params.Datastores = n
+		} else {
+			log.Warnf("ignoring invalid value %q for %s", v, EnvVarSimulatorDatastores)
+		}
+	}
+	if v := os.Getenv(EnvVarSimulatorConfigDir); v != "" {
+		params.ConfigDir = v
+	}
+	return params
+}
@@ -0,0 +1,32 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vcsim
+
+import (
+	"testing"
+)
+
+func TestIsEnabled(t *testing.T) {
+	t.Setenv(EnvVarSimulatorMode, "true")
+	if !IsEnabled() {
+		t.Fatal("expected simulator mode to be enabled")
+	}
+	t.Setenv(EnvVarSimulatorMode, "not-a-bool")
+	if IsEnabled() {
+		t.Fatal("expected simulator mode to be disabled for invalid value")
+	}
+}
@@ -0,0 +1,125 @@
+//go:build vcsim
+// +build vcsim
+
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vcsim
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	cnssim "github.com/vmware/govmomi/cns/simulator"
+	pbmsim "github.com/vmware/govmomi/pbm/simulator"
+	"github.com/vmware/govmomi/simulator"
+
+	cnsconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+// simulatorConfigFileName is the name of the generated vsphere.conf.
+const simulatorConfigFileName = "csi-vsphere-vcsim.conf"
+
+// Simulator is a running vCenter simulator along with the driver
+// configuration that points to it.
+type Simulator struct {
+	model      *simulator.Model
+	server     *simulator.Server
+	ConfigPath string
+	Config     *cnsconfig.Config
+	stopOnce   sync.Once
+}
+
+// Start creates the simulated inventory, starts the SOAP server with the CNS
+// and PBM simulators registered, writes a vsphere.conf pointing at it and
+// exports its path via cnsconfig.EnvVSphereCSIConfig so that the regular
+// config loading path picks it up.
+func Start(ctx context.Context, params Params) (*Simulator, error) {
+	log := logger.GetLogger(ctx)
+	model := simulator.VPX()
+	model.Datacenter = params.Datacenters
+	model.Cluster = params.Clusters
+	model.ClusterHost = params.HostsPerCluster
+	model.Datastore = params.Datastores
+	model.Machine = params.VMsPerCluster
+
+	if err := model.Create(); err != nil {
+		model.Remove()
+		return nil, logger.LogNewErrorf(log, "failed to create vcsim model. Err: %v", err)
+	}
+	server := model.Service.NewServer()
+	model.Service.RegisterSDK(cnssim.New())
+	model.Service.RegisterSDK(pbmsim.New())
+
+	cfg := &cnsconfig.Config{}
+	cfg.Global.InsecureFlag = true
+	cfg.Global.VCenterIP = server.URL.Hostname()
+	cfg.Global.VCenterPort = server.URL.Port()
+	cfg.Global.User = server.URL.User.Username()
+	cfg.Global.Password, _ = server.URL.User.Password()
+	cfg.Global.ClusterID = "vcsim-cluster"
+	datacenters := "DC0"
+	for i := 1; i < params.Datacenters; i++ {
+		datacenters = datacenters + ", DC" + strconv.Itoa(i)
+	}
+	cfg.Global.Datacenters = datacenters
+
+	sim := &Simulator{
+		model:  model,
+		server: server,
+		Config: cfg,
+	}
+	sim.ConfigPath = filepath.Join(params.ConfigDir, simulatorConfigFileName)
+	conf := fmt.Sprintf("[Global]\ncluster-id = \"%s\"\ninsecure-flag = \"%t\"\n"+
+		"[VirtualCenter \"%s\"]\nuser = \"%s\"\npassword = \"%s\"\ndatacenters = \"%s\"\nport = \"%s\"\n",
+		cfg.Global.ClusterID, cfg.Global.InsecureFlag, cfg.Global.VCenterIP, cfg.Global.User,
+		cfg.Global.Password, cfg.Global.Datacenters, cfg.Global.VCenterPort)
+	if err := os.WriteFile(sim.ConfigPath, []byte(conf), 0600); err != nil {
+		sim.Stop(ctx)
+		return nil, logger.LogNewErrorf(log, "failed to write vcsim config to %q. Err: %v", sim.ConfigPath, err)
+	}
+	if err := os.Setenv(cnsconfig.EnvVSphereCSIConfig, sim.ConfigPath); err != nil {
+		sim.Stop(ctx)
+		return nil, logger.LogNewErrorf(log, "failed to set %s. Err: %v", cnsconfig.EnvVSphereCSIConfig, err)
+	}
+	log.Infof("vCenter simulator listening on %s:%s, config written to %q",
+		cfg.Global.VCenterIP, cfg.Global.VCenterPort, sim.ConfigPath)
+	return sim, nil
+}
+
+// Stop shuts down the simulator and removes the generated config file. It is
+// a no-op on a nil Simulator.
+func (s *Simulator) Stop(ctx context.Context) {
+	if s == nil {
+		return
+	}
+	log := logger.GetLogger(ctx)
+	s.stopOnce.Do(func() {
+		s.server.Close()
+		s.model.Remove()
+		if s.ConfigPath != "" {
+			if err := os.Remove(s.ConfigPath); err != nil && !os.IsNotExist(err) {
+				log.Warnf("failed to remove vcsim config %q. Err: %v", s.ConfigPath, err)
+			}
+		}
+		log.Info("vCenter simulator stopped")
+	})
+}
@@ -0,0 +1,45 @@
+//go:build !vcsim
+// +build !vcsim
+
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vcsim
+
+import (
+	"context"
+
+	cnsconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+// Simulator is a running vCenter simulator along with the driver
+// configuration that points to it. It can't be started in binaries built
+// without the "vcsim" build tag.
+type Simulator struct {
+	ConfigPath string
+	Config     *cnsconfig.Config
+}
+
+// Start fails as the simulator isn't compiled into this binary.
+func Start(ctx context.Context, params Params) (*Simulator, error) {
+	log := logger.GetLogger(ctx)
+	return nil, logger.LogNewError(log,
+		"vCenter simulator is not available, the binary must be built with the \"vcsim\" build tag")
+}
+
+// Stop is a no-op, as the simulator can't be started.
+func (s *Simulator) Stop(ctx context.Context) {}
@@ -0,0 +1,60 @@
+//go:build vcsim
+// +build vcsim
+
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vcsim
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	cnsconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+)
+
+func TestStartWritesLoadableConfig(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	params := ParamsFromEnv(ctx)
+	params.ConfigDir = t.TempDir()
+	// Start exports the path of the generated config, which is restored once
+	// the test is done.
+	t.Setenv(cnsconfig.EnvVSphereCSIConfig, "")
+
+	sim, err := Start(ctx, params)
+	if err != nil {
+		t.Fatalf("failed to start simulator: %v", err)
+	}
+	defer sim.Stop(ctx)
+
+	if got := os.Getenv(cnsconfig.EnvVSphereCSIConfig); got != sim.ConfigPath {
+		t.Fatalf("expected %s to be %q, got %q", cnsconfig.EnvVSphereCSIConfig, sim.ConfigPath, got)
+	}
+	cfg, err := cnsconfig.GetCnsconfig(ctx, sim.ConfigPath)
+	if err != nil {
+		t.Fatalf("failed to read generated config: %v", err)
+	}
+	if _, ok := cfg.VirtualCenter[sim.Config.Global.VCenterIP]; !ok {
+		t.Fatalf("generated config does not contain vCenter %q", sim.Config.Global.VCenterIP)
+	}
+
+	sim.Stop(ctx)
+	if _, err := os.Stat(sim.ConfigPath); !os.IsNotExist(err) {
+		t.Fatalf("expected config %q to be removed after Stop", sim.ConfigPath)
+	}
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import "sync"
+
+// UnknownVCenter is the vCenter label of attach and detach latencies of
+// volumes whose vCenter isn't known.
+const UnknownVCenter = "unknown"
+
+// volumeVCenters maps the ID of the volumes attached or detached by this
+// controller to the host of their vCenter, so that the latency observed on
+// their VolumeAttachment is reported for the right vCenter.
+var volumeVCenters sync.Map
+
+// SetVolumeVCenter records the vCenter host of a volume attached or detached
+// by this controller.
+func SetVolumeVCenter(volumeID, vcHost string) {
+	volumeVCenters.Store(volumeID, vcHost)
+}
+
+// GetVolumeVCenter returns the vCenter host recorded for the volume by
+// SetVolumeVCenter, or "unknown". The entry is removed if forget is true.
+func GetVolumeVCenter(volumeID string, forget bool) string {
+	var vcHost any
+	var ok bool
+	if forget {
+		vcHost, ok = volumeVCenters.LoadAndDelete(volumeID)
+	} else {
+		vcHost, ok = volumeVCenters.Load(volumeID)
+	}
+	if !ok {
+		return UnknownVCenter
+	}
+	return vcHost.(string)
+}
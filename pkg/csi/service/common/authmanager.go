@@ -50,6 +50,12 @@ type AuthorizationService interface {
 
 	// ResetvCenterInstance sets new vCenter instance for AuthorizationService
 	ResetvCenterInstance(ctx context.Context, vCenter *cnsvsphere.VirtualCenter)
+
+	// ValidateStoragePolicy checks, against the cached storage policies, that
+	// the storage policy with the given name exists and can be used by the CSI
+	// VC user, and returns its ID. An empty ID and no error are returned until
+	// the storage policies are cached.
+	ValidateStoragePolicy(ctx context.Context, storagePolicyName string) (string, error)
 }
 
 // AuthManager maintains an internal map to track the datastores that need to be
@@ -62,6 +68,12 @@ type AuthManager struct {
 	// for that cluster. The datastore info objects are to be used when invoking
 	// CNS CreateVolume API.
 	fsEnabledClusterToDsMap map[string][]*cnsvsphere.DatastoreInfo
+	// Map the storage policy name to storage policy ID, for the storage
+	// policies of vCenter. It is nil until the storage policies are cached.
+	storagePolicyIDs map[string]string
+	// hasStorageProfileViewPriv is true if the CSI VC user has the
+	// StorageProfile.View privilege, as of the last refresh of storagePolicyIDs.
+	hasStorageProfileViewPriv bool
 	// Make sure the update for datastoreMap is mutually exclusive.
 	rwMutex sync.RWMutex
 	// VCenter Instance.
@@ -149,6 +161,62 @@ func (authManager *AuthManager) ResetvCenterInstance(ctx context.Context, vCente
 	authManager.vcenter = vCenter
 }
 
+// ValidateStoragePolicy checks that the storage policy with the given name
+// exists and can be used by the CSI VC user, and returns its ID. A storage
+// policy missing from the cache is looked up in vCenter, as it may have been
+// created since the last refresh of the cache.
+func (authManager *AuthManager) ValidateStoragePolicy(ctx context.Context,
+	storagePolicyName string) (string, error) {
+	log := logger.GetLogger(ctx)
+	authManager.rwMutex.RLock()
+	storagePolicyIDs, hasPriv := authManager.storagePolicyIDs, authManager.hasStorageProfileViewPriv
+	storagePolicyID, found := storagePolicyIDs[storagePolicyName]
+	authManager.rwMutex.RUnlock()
+	if storagePolicyIDs == nil {
+		return "", nil
+	}
+	vcenterHost := authManager.vcenter.Config.Host
+	if !hasPriv {
+		return "", logger.LogNewErrorCodef(log, codes.PermissionDenied,
+			"user %s doesn't have privilege %s on vCenter %q to use storage policy %q",
+			authManager.vcenter.Config.Username, StorageProfileViewPriv, vcenterHost, storagePolicyName)
+	}
+	if found {
+		return storagePolicyID, nil
+	}
+	storagePolicyID, err := authManager.vcenter.GetStoragePolicyIDByName(ctx, storagePolicyName)
+	if err != nil {
+		return "", logger.LogNewErrorCodef(log, codes.NotFound,
+			"storage policy %q not found in vCenter %q. Error: %v", storagePolicyName, vcenterHost, err)
+	}
+	authManager.rwMutex.Lock()
+	defer authManager.rwMutex.Unlock()
+	if authManager.storagePolicyIDs != nil {
+		authManager.storagePolicyIDs[storagePolicyName] = storagePolicyID
+	}
+	return storagePolicyID, nil
+}
+
+// refreshStoragePolicyIDs lists the storage policies of vCenter and checks
+// the privilege of the CSI VC user on them, to update storagePolicyIDs.
+func (authManager *AuthManager) refreshStoragePolicyIDs() {
+	ctx, log := logger.GetNewContextWithLogger()
+	vcenterHost := authManager.vcenter.Config.Host
+	log.Debugf("auth manager: refreshStoragePolicyIDs is triggered for vCenter %q", vcenterHost)
+	newStoragePolicyIDs, hasPriv, err := GenerateStoragePolicyIDs(ctx, authManager.vcenter)
+	if err == nil {
+		authManager.rwMutex.Lock()
+		defer authManager.rwMutex.Unlock()
+		authManager.storagePolicyIDs = newStoragePolicyIDs
+		authManager.hasStorageProfileViewPriv = hasPriv
+		log.Infof("auth manager: storagePolicyIDs is updated to %v for vCenter %q, %s privilege: %t",
+			newStoragePolicyIDs, vcenterHost, StorageProfileViewPriv, hasPriv)
+	} else {
+		log.Warnf("auth manager: failed to get updated storagePolicyIDs for vCenter %q, Err: %v",
+			vcenterHost, err)
+	}
+}
+
 // refreshDatastoreMapForBlockVolumes scans all datastores in vCenter to check
 // privileges, and compute the datastoreMapForBlockVolumes.
 func (authManager *AuthManager) refreshDatastoreMapForBlockVolumes() {
@@ -212,6 +280,52 @@ func ComputeFSEnabledClustersToDsMap(authManager *AuthManager, authCheckInterval
 	}
 }
 
+// ComputeStoragePolicyIDs refreshes storagePolicyIDs periodically.
+func ComputeStoragePolicyIDs(authManager *AuthManager, authCheckInterval int) {
+	log := logger.GetLoggerWithNoContext()
+	log.Infof("auth manager: ComputeStoragePolicyIDs entered for vCenter %q",
+		authManager.vcenter.Config.Host)
+	ticker := time.NewTicker(time.Duration(authCheckInterval) * time.Minute)
+	for ; true; <-ticker.C {
+		authManager.refreshStoragePolicyIDs()
+	}
+}
+
+// GenerateStoragePolicyIDs returns a map of storage policy name to storage
+// policy ID for the storage policies of vCenter, and whether the CSI VC user
+// has the StorageProfile.View privilege needed to use them.
+func GenerateStoragePolicyIDs(ctx context.Context, vc *cnsvsphere.VirtualCenter) (map[string]string, bool, error) {
+	log := logger.GetLogger(ctx)
+	authMgr := object.NewAuthorizationManager(vc.Client.Client)
+	privIds := []string{StorageProfileViewPriv}
+	userName := vc.Config.Username
+	entities := []vim25types.ManagedObjectReference{vc.Client.ServiceContent.RootFolder}
+	result, err := authMgr.HasUserPrivilegeOnEntities(ctx, entities, userName, privIds)
+	if err != nil {
+		log.Errorf("auth manager: failed to check privilege %v on entities %v for user %s and for vCenter %q",
+			privIds, entities, userName, vc.Config.Host)
+		return nil, false, err
+	}
+	hasPriv := len(result) != 0
+	for _, entityPriv := range result {
+		for _, privAvail := range entityPriv.PrivAvailability {
+			if !privAvail.IsGranted {
+				hasPriv = false
+			}
+		}
+	}
+	profiles, err := vc.PbmQueryStorageProfiles(ctx)
+	if err != nil {
+		log.Errorf("failed to query storage policies for vCenter %q. Error: %+v", vc.Config.Host, err)
+		return nil, false, err
+	}
+	storagePolicyIDs := make(map[string]string)
+	for _, profile := range profiles {
+		storagePolicyIDs[profile.Name] = profile.ProfileId.UniqueId
+	}
+	return storagePolicyIDs, hasPriv, nil
+}
+
 // GenerateDatastoreMapForBlockVolumes scans all datastores in Vcenter and do
 // privilege check on those datastoes. It will return datastores which has the
 // privileges for creating block volume.
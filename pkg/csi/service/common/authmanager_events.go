@@ -0,0 +1,153 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/vmware/govmomi/event"
+	"github.com/vmware/govmomi/vim25/methods"
+	vim25types "github.com/vmware/govmomi/vim25/types"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+const (
+	// permissionEventDebounce is how long the auth manager waits after a
+	// permission change event before refreshing its maps, so that the events
+	// of a single change of permissions trigger a single refresh.
+	permissionEventDebounce = 5 * time.Second
+	// permissionEventRetryInterval is the delay before watching the events of
+	// vCenter again after the watch failed.
+	permissionEventRetryInterval = time.Minute
+	// permissionEventPageSize is the page size of the event collector.
+	permissionEventPageSize = 100
+)
+
+// permissionEventTypes are the vCenter events which may change the
+// privileges of the CSI VC user.
+var permissionEventTypes = []string{
+	"PermissionAddedEvent",
+	"PermissionUpdatedEvent",
+	"PermissionRemovedEvent",
+	"RoleUpdatedEvent",
+	"RoleRemovedEvent",
+}
+
+// authRefreshScope selects the maps of the auth manager to refresh.
+type authRefreshScope struct {
+	blockVolumes bool
+	fileVolumes  bool
+}
+
+// getPermissionEventScope returns the maps of the auth manager affected by
+// the given event. Permissions set on a datastore only affect the datastores
+// for block volumes, and permissions set on a cluster only affect the
+// clusters for file volumes. Other permissions, set on folders or
+// datacenters, and role changes may affect both.
+func getPermissionEventScope(e vim25types.BaseEvent) authRefreshScope {
+	if permissionEvent, ok := e.(vim25types.BasePermissionEvent); ok {
+		switch permissionEvent.GetPermissionEvent().Entity.Entity.Type {
+		case "Datastore":
+			return authRefreshScope{blockVolumes: true}
+		case "ClusterComputeResource":
+			return authRefreshScope{fileVolumes: true}
+		}
+	}
+	return authRefreshScope{blockVolumes: true, fileVolumes: true}
+}
+
+// WatchPermissionEvents refreshes the maps of the auth manager as soon as
+// the permissions of vCenter change, so that newly granted datastores and
+// clusters can be used without waiting for the periodic refresh. The file
+// volumes map is only refreshed if fileVolumesEnabled is true.
+func WatchPermissionEvents(authManager *AuthManager, fileVolumesEnabled bool) {
+	ctx, log := logger.GetNewContextWithLogger()
+	vcenterHost := authManager.vcenter.Config.Host
+	log.Infof("auth manager: WatchPermissionEvents entered for vCenter %q", vcenterHost)
+
+	var (
+		pendingLock sync.Mutex
+		pending     authRefreshScope
+		trigger     = make(chan struct{}, 1)
+	)
+	go func() {
+		for range trigger {
+			time.Sleep(permissionEventDebounce)
+			pendingLock.Lock()
+			scope := pending
+			pending = authRefreshScope{}
+			pendingLock.Unlock()
+			if scope.blockVolumes {
+				authManager.refreshDatastoreMapForBlockVolumes()
+			}
+			if scope.fileVolumes && fileVolumesEnabled {
+				authManager.refreshFSEnabledClustersToDsMap()
+			}
+		}
+	}()
+	notify := func(scope authRefreshScope) {
+		pendingLock.Lock()
+		pending.blockVolumes = pending.blockVolumes || scope.blockVolumes
+		pending.fileVolumes = pending.fileVolumes || scope.fileVolumes
+		pendingLock.Unlock()
+		select {
+		case trigger <- struct{}{}:
+		default:
+		}
+	}
+
+	for {
+		err := watchPermissionEvents(ctx, authManager, notify)
+		log.Warnf("auth manager: watch of permission events for vCenter %q exited, restarting in %v. Err: %v",
+			vcenterHost, permissionEventRetryInterval, err)
+		time.Sleep(permissionEventRetryInterval)
+	}
+}
+
+// watchPermissionEvents tails the permission change events of vCenter and
+// notifies the maps they affect, until the watch fails.
+func watchPermissionEvents(ctx context.Context, authManager *AuthManager,
+	notify func(authRefreshScope)) error {
+	log := logger.GetLogger(ctx)
+	vc := authManager.vcenter
+	if err := vc.Connect(ctx); err != nil {
+		return err
+	}
+	// The first page of events returned by the collector holds past events,
+	// which are already reflected in the maps.
+	since := time.Now()
+	if now, err := methods.GetCurrentTime(ctx, vc.Client.Client); err == nil && now != nil {
+		since = *now
+	}
+	eventManager := event.NewManager(vc.Client.Client)
+	return eventManager.Events(ctx, []vim25types.ManagedObjectReference{vc.Client.ServiceContent.RootFolder},
+		permissionEventPageSize, true, false,
+		func(_ vim25types.ManagedObjectReference, events []vim25types.BaseEvent) error {
+			for _, e := range events {
+				if e.GetEvent().CreatedTime.Before(since) {
+					continue
+				}
+				log.Infof("auth manager: permissions of vCenter %q changed: %s", vc.Config.Host,
+					e.GetEvent().FullFormattedMessage)
+				notify(getPermissionEventScope(e))
+			}
+			return nil
+		}, permissionEventTypes...)
+}
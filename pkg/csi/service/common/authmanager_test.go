@@ -0,0 +1,66 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	vim25types "github.com/vmware/govmomi/vim25/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+)
+
+func TestValidateStoragePolicyWithCache(t *testing.T) {
+	authManager := &AuthManager{
+		vcenter: &cnsvsphere.VirtualCenter{
+			Config: &cnsvsphere.VirtualCenterConfig{Host: "vc1", Username: "csi-user"},
+		},
+	}
+
+	// Storage policies are not validated until they are cached.
+	storagePolicyID, err := authManager.ValidateStoragePolicy(ctx, "gold")
+	assert.NoError(t, err)
+	assert.Empty(t, storagePolicyID)
+
+	authManager.storagePolicyIDs = map[string]string{"gold": "gold-id"}
+	_, err = authManager.ValidateStoragePolicy(ctx, "gold")
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	authManager.hasStorageProfileViewPriv = true
+	storagePolicyID, err = authManager.ValidateStoragePolicy(ctx, "gold")
+	assert.NoError(t, err)
+	assert.Equal(t, "gold-id", storagePolicyID)
+}
+
+func TestGetPermissionEventScope(t *testing.T) {
+	newPermissionEvent := func(entityType string) *vim25types.PermissionAddedEvent {
+		e := &vim25types.PermissionAddedEvent{}
+		e.Entity.Entity = vim25types.ManagedObjectReference{Type: entityType, Value: "entity-1"}
+		return e
+	}
+	assert.Equal(t, authRefreshScope{blockVolumes: true},
+		getPermissionEventScope(newPermissionEvent("Datastore")))
+	assert.Equal(t, authRefreshScope{fileVolumes: true},
+		getPermissionEventScope(newPermissionEvent("ClusterComputeResource")))
+	assert.Equal(t, authRefreshScope{blockVolumes: true, fileVolumes: true},
+		getPermissionEventScope(newPermissionEvent("Folder")))
+	assert.Equal(t, authRefreshScope{blockVolumes: true, fileVolumes: true},
+		getPermissionEventScope(&vim25types.RoleUpdatedEvent{}))
+}
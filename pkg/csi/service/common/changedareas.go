@@ -0,0 +1,114 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"context"
+
+	cnsvolume "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+const (
+	// AllocatedAreasChangeID is the changed block tracking ID querying the
+	// allocated areas of a snapshot, instead of the areas changed since a
+	// previous snapshot.
+	AllocatedAreasChangeID = "*"
+	// DefaultMaxChangedAreas is the default maximum number of areas returned
+	// by QueryChangedAreas.
+	DefaultMaxChangedAreas = 10000
+)
+
+// DiskArea is an area of a virtual disk.
+type DiskArea struct {
+	// Offset is the offset, in bytes, of the area.
+	Offset int64 `json:"offset"`
+	// Length is the length, in bytes, of the area.
+	Length int64 `json:"length"`
+}
+
+// ChangedAreas are the areas of a snapshot of a volume changed since a base
+// snapshot of the same volume.
+type ChangedAreas struct {
+	// Areas are the changed areas, sorted by offset.
+	Areas []DiskArea
+	// ChangedBytes is the total length of the changed areas.
+	ChangedBytes int64
+	// CapacityBytes is the capacity of the volume.
+	CapacityBytes int64
+	// NextOffset is the offset to query the remaining changed areas from, if
+	// the areas were truncated to the maximum number of areas. It is 0 if all
+	// the changed areas are returned.
+	NextOffset int64
+}
+
+// QueryChangedAreas returns the areas of the snapshot snapshotID of the volume
+// changed since the snapshot baseSnapshotID, or the allocated areas of the
+// snapshot if baseSnapshotID is empty, using the changed block tracking of
+// the volume. The areas are queried from startOffset to the end of the volume,
+// and truncated to maxAreas areas.
+func QueryChangedAreas(ctx context.Context, volManager cnsvolume.Manager, volumeID, snapshotID,
+	baseSnapshotID string, startOffset int64, maxAreas int) (*ChangedAreas, error) {
+	log := logger.GetLogger(ctx)
+	vStorageObject, err := volManager.RetrieveVStorageObject(ctx, volumeID)
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to retrieve volume %q. Error: %v", volumeID, err)
+	}
+	if vStorageObject.Config.ChangedBlockTrackingEnabled == nil ||
+		!*vStorageObject.Config.ChangedBlockTrackingEnabled {
+		return nil, logger.LogNewErrorf(log, "changed block tracking is not enabled on volume %q", volumeID)
+	}
+	changeID := AllocatedAreasChangeID
+	if baseSnapshotID != "" {
+		changeID, err = volManager.RetrieveSnapshotChangeID(ctx, volumeID, baseSnapshotID)
+		if err != nil {
+			return nil, logger.LogNewErrorf(log, "failed to retrieve the change ID of snapshot %q of volume %q. "+
+				"Error: %v", baseSnapshotID, volumeID, err)
+		}
+		if changeID == "" {
+			return nil, logger.LogNewErrorf(log, "snapshot %q of volume %q has no change ID, it was taken "+
+				"before changed block tracking was enabled", baseSnapshotID, volumeID)
+		}
+	}
+	if maxAreas <= 0 {
+		maxAreas = DefaultMaxChangedAreas
+	}
+
+	result := &ChangedAreas{CapacityBytes: vStorageObject.Config.CapacityInMB * MbInBytes}
+	for offset := startOffset; offset < result.CapacityBytes; {
+		changeInfo, err := volManager.QueryChangedDiskAreas(ctx, volumeID, snapshotID, offset, changeID)
+		if err != nil {
+			return nil, logger.LogNewErrorf(log, "failed to query the changed areas of snapshot %q of volume %q "+
+				"from offset %d. Error: %v", snapshotID, volumeID, offset, err)
+		}
+		for _, extent := range changeInfo.ChangedArea {
+			if len(result.Areas) == maxAreas {
+				result.NextOffset = extent.Start
+				return result, nil
+			}
+			result.Areas = append(result.Areas, DiskArea{Offset: extent.Start, Length: extent.Length})
+			result.ChangedBytes += extent.Length
+		}
+		if changeInfo.Length <= 0 {
+			break
+		}
+		offset = changeInfo.StartOffset + changeInfo.Length
+	}
+	log.Infof("Found %d changed areas, %d bytes, in snapshot %q of volume %q since %q from offset %d",
+		len(result.Areas), result.ChangedBytes, snapshotID, volumeID, changeID, startOffset)
+	return result, nil
+}
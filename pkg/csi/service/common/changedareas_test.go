@@ -0,0 +1,105 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	vim25types "github.com/vmware/govmomi/vim25/types"
+
+	cnsvolume "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
+)
+
+// fakeChangedAreasManager returns the changed areas of a 4 MiB volume in
+// chunks of 1 MiB.
+type fakeChangedAreasManager struct {
+	cnsvolume.Manager
+	extents []vim25types.DiskChangeExtent
+	// changeIDs are the change IDs the areas were queried with.
+	changeIDs []string
+}
+
+func (m *fakeChangedAreasManager) RetrieveVStorageObject(ctx context.Context,
+	volumeID string) (*vim25types.VStorageObject, error) {
+	enabled := true
+	return &vim25types.VStorageObject{Config: vim25types.VStorageObjectConfigInfo{
+		BaseConfigInfo: vim25types.BaseConfigInfo{ChangedBlockTrackingEnabled: &enabled},
+		CapacityInMB:   4,
+	}}, nil
+}
+
+func (m *fakeChangedAreasManager) RetrieveSnapshotChangeID(ctx context.Context, volumeID string,
+	snapshotID string) (string, error) {
+	return "change-" + snapshotID, nil
+}
+
+func (m *fakeChangedAreasManager) QueryChangedDiskAreas(ctx context.Context, volumeID string, snapshotID string,
+	startOffset int64, changeID string) (*vim25types.DiskChangeInfo, error) {
+	m.changeIDs = append(m.changeIDs, changeID)
+	changeInfo := &vim25types.DiskChangeInfo{StartOffset: startOffset, Length: MbInBytes}
+	for _, extent := range m.extents {
+		if extent.Start >= startOffset && extent.Start < startOffset+MbInBytes {
+			changeInfo.ChangedArea = append(changeInfo.ChangedArea, extent)
+		}
+	}
+	return changeInfo, nil
+}
+
+func TestQueryChangedAreas(t *testing.T) {
+	ctx := context.Background()
+	manager := &fakeChangedAreasManager{extents: []vim25types.DiskChangeExtent{
+		{Start: 0, Length: 4096},
+		{Start: 8192, Length: 4096},
+		{Start: 2 * MbInBytes, Length: 65536},
+		{Start: 3*MbInBytes + 4096, Length: 4096},
+	}}
+
+	result, err := QueryChangedAreas(ctx, manager, "vol", "snap-2", "snap-1", 0, 0)
+	if err != nil {
+		t.Fatalf("failed to query changed areas. Error: %v", err)
+	}
+	if len(result.Areas) != 4 || result.ChangedBytes != 77824 || result.NextOffset != 0 ||
+		result.CapacityBytes != 4*MbInBytes {
+		t.Errorf("unexpected changed areas %+v", result)
+	}
+	if !reflect.DeepEqual(manager.changeIDs, []string{"change-snap-1", "change-snap-1", "change-snap-1",
+		"change-snap-1"}) {
+		t.Errorf("unexpected change IDs %v", manager.changeIDs)
+	}
+
+	// The areas are truncated, and the remaining areas are queried from
+	// NextOffset.
+	manager.changeIDs = nil
+	result, err = QueryChangedAreas(ctx, manager, "vol", "snap-2", "", 0, 3)
+	if err != nil {
+		t.Fatalf("failed to query allocated areas. Error: %v", err)
+	}
+	if len(result.Areas) != 3 || result.NextOffset != 3*MbInBytes+4096 ||
+		manager.changeIDs[0] != AllocatedAreasChangeID {
+		t.Errorf("unexpected truncated areas %+v, change IDs %v", result, manager.changeIDs)
+	}
+	result, err = QueryChangedAreas(ctx, manager, "vol", "snap-2", "", result.NextOffset, 3)
+	if err != nil {
+		t.Fatalf("failed to query allocated areas. Error: %v", err)
+	}
+	expected := []DiskArea{{Offset: 3*MbInBytes + 4096, Length: 4096}}
+	if !reflect.DeepEqual(result.Areas, expected) || result.NextOffset != 0 {
+		t.Errorf("expected areas %v, got %+v", expected, result)
+	}
+}
@@ -19,6 +19,7 @@ package commonco
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	cnstypes "github.com/vmware/govmomi/cns/types"
 	storagev1 "k8s.io/api/storage/v1"
@@ -31,9 +32,16 @@ import (
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
 )
 
-// ContainerOrchestratorUtility represents the singleton instance of
-// container orchestrator interface.
-var ContainerOrchestratorUtility COCommonInterface
+var (
+	// ContainerOrchestratorUtility represents the singleton instance of
+	// container orchestrator interface.
+	ContainerOrchestratorUtility COCommonInterface
+
+	// k8sOrchestratorInstance is the K8sOrchestrator shared by the callers of
+	// GetContainerOrchestratorInterface in this process.
+	k8sOrchestratorInstance     *k8sorchestrator.K8sOrchestrator
+	k8sOrchestratorInstanceLock = &sync.Mutex{}
+)
 
 // COCommonInterface provides functionality to define container orchestrator
 // related implementation to read resources/objects.
@@ -41,6 +49,9 @@ type COCommonInterface interface {
 	// IsFSSEnabled checks if feature state switch is enabled for the given feature indicated
 	// by featureName.
 	IsFSSEnabled(ctx context.Context, featureName string) bool
+	// GetFeatureStates returns the effective state of the feature state
+	// switches known to the orchestrator.
+	GetFeatureStates(ctx context.Context) map[string]bool
 	// IsFakeAttachAllowed checks if the passed volume can be fake attached.
 	IsFakeAttachAllowed(ctx context.Context, volumeID string, volumeManager cnsvolume.Manager) (bool, error)
 	// MarkFakeAttached marks the volume as fake attached.
@@ -86,19 +97,38 @@ type COCommonInterface interface {
 	GetPVNameFromCSIVolumeID(volumeID string) (string, bool)
 	// InitializeCSINodes creates CSINode instances for each K8s node with the appropriate topology keys.
 	InitializeCSINodes(ctx context.Context) error
+	// GetSnapshotVCenter returns the vCenter of the snapshot with the given CSI snapshot ID, if known.
+	GetSnapshotVCenter(ctx context.Context, csiSnapshotID string) (string, bool)
+	// SetSnapshotVCenter caches the vCenter of the snapshot with the given CSI snapshot ID.
+	SetSnapshotVCenter(ctx context.Context, csiSnapshotID string, vCenterHost string)
+	// ForgetSnapshotVCenter removes the cached vCenter of the snapshot with the given CSI snapshot ID.
+	ForgetSnapshotVCenter(ctx context.Context, csiSnapshotID string)
+	// GetSupervisorPVCForGuestPVC returns the namespace and name of the supervisor PVC
+	// backing the given guest PVC, if known. Used only in guest clusters.
+	GetSupervisorPVCForGuestPVC(ctx context.Context, namespace string, name string) (string, string, bool)
 }
 
 // GetContainerOrchestratorInterface returns orchestrator object for a given
-// container orchestrator type.
+// container orchestrator type. The orchestrator is created on the first call
+// and shared by all the callers in the process. Use
+// k8sorchestrator.NewK8sOrchestrator to create separately configured ones.
 func GetContainerOrchestratorInterface(ctx context.Context, orchestratorType int,
 	clusterFlavor cnstypes.CnsClusterFlavor, params interface{}) (COCommonInterface, error) {
 	log := logger.GetLogger(ctx)
 	switch orchestratorType {
 	case common.Kubernetes:
-		k8sOrchestratorInstance, err := k8sorchestrator.Newk8sOrchestrator(ctx, clusterFlavor, params)
-		if err != nil {
-			log.Errorf("creating k8sOrchestratorInstance failed. Err: %v", err)
-			return nil, err
+		k8sOrchestratorInstanceLock.Lock()
+		defer k8sOrchestratorInstanceLock.Unlock()
+		if k8sOrchestratorInstance == nil {
+			instance, err := k8sorchestrator.NewK8sOrchestrator(ctx, k8sorchestrator.K8sOrchestratorOptions{
+				ClusterFlavor: clusterFlavor,
+				InitParams:    params,
+			})
+			if err != nil {
+				log.Errorf("creating k8sOrchestratorInstance failed. Err: %v", err)
+				return nil, err
+			}
+			k8sOrchestratorInstance = instance
 		}
 		return k8sOrchestratorInstance, nil
 	default:
@@ -0,0 +1,306 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8sorchestrator
+
+import (
+	"context"
+	"strconv"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+// capabilitySource is a source of feature state switches, e.g. a feature
+// states ConfigMap, the map replicated from the cnscsisvfeaturestates CR or
+// the WCP cluster capabilities.
+type capabilitySource interface {
+	// name identifies the source in the logs.
+	name() string
+	// featureNames returns the names of the feature states defined by the
+	// source.
+	featureNames() []string
+	// lookup returns the state of the given feature and whether the source
+	// defines it. An error is returned if the source can't be read or defines
+	// the feature with a non boolean value.
+	lookup(ctx context.Context, featureName string) (state bool, found bool, err error)
+}
+
+// capabilityPrecedence defines how the states of a feature found in the
+// sources of a capabilityChain are combined.
+type capabilityPrecedence int
+
+const (
+	// firstFoundWins uses the state of the first source defining the feature.
+	// The sources are ordered by decreasing precedence.
+	firstFoundWins capabilityPrecedence = iota
+	// requireAllEnabled enables the feature only if every source defines it
+	// and enables it.
+	requireAllEnabled
+)
+
+// capabilityChain evaluates the feature state switches of a cluster flavor
+// from its ordered capability sources.
+type capabilityChain struct {
+	precedence capabilityPrecedence
+	sources    []capabilitySource
+}
+
+// fssChain returns the capability chain of the cluster flavor of the
+// orchestrator, or nil if the flavor is not recognised.
+//   - Vanilla: the released features, then the internal feature states
+//     ConfigMap, which allows toggling the unreleased features.
+//   - Workload: the WCP cluster capabilities for the WCP defined features,
+//     then the supervisor feature states ConfigMap.
+//   - Guest: the internal feature states ConfigMap and the supervisor feature
+//     states, read from the cnscsisvfeaturestates CR when it exists, which
+//     both have to enable the feature.
+func (c *K8sOrchestrator) fssChain() *capabilityChain {
+	switch c.clusterFlavor {
+	case cnstypes.CnsClusterFlavorVanilla:
+		return &capabilityChain{
+			precedence: firstFoundWins,
+			sources: []capabilitySource{
+				&releasedFSSSource{released: c.releasedVanillaFSS},
+				&fssMapSource{fss: &c.internalFSS},
+			},
+		}
+	case cnstypes.CnsClusterFlavorWorkload:
+		return &capabilityChain{
+			precedence: firstFoundWins,
+			sources: []capabilitySource{
+				&wcpCapabilitySource{orchestrator: c},
+				&fssMapSource{fss: &c.supervisorFSS},
+			},
+		}
+	case cnstypes.CnsClusterFlavorGuest:
+		return &capabilityChain{
+			precedence: requireAllEnabled,
+			sources: []capabilitySource{
+				&fssMapSource{fss: &c.internalFSS},
+				&fssMapSource{fss: &c.supervisorFSS, crFed: c.getSvFssCRAvailability},
+			},
+		}
+	}
+	return nil
+}
+
+// evaluate returns the state of the given feature, along with the outcome of
+// its evaluation, which is one of the prometheus.PrometheusFSS* constants.
+// onConflict is called when a source of lower precedence disagrees with the
+// source the state of the feature was taken from.
+func (chain *capabilityChain) evaluate(ctx context.Context, featureName string,
+	onConflict func(winner, loser capabilitySource, state bool)) (bool, string) {
+	log := logger.GetLogger(ctx)
+	for i, source := range chain.sources {
+		state, found, err := source.lookup(ctx, featureName)
+		if err != nil {
+			log.Errorf("Failed to evaluate the %s feature state in %s. Setting the feature state to false. "+
+				"Error: %v", featureName, source.name(), err)
+			return false, prometheus.PrometheusFSSError
+		}
+		if !found {
+			if chain.precedence == firstFoundWins {
+				continue
+			}
+			log.Infof("Could not find the %s feature state in %s. Setting the feature state to false",
+				featureName, source.name())
+			return false, prometheus.PrometheusFSSMissing
+		}
+		if chain.precedence == requireAllEnabled {
+			if !state {
+				log.Infof("%s feature state is set to false in %s", featureName, source.name())
+				return false, prometheus.PrometheusFSSDisabled
+			}
+			continue
+		}
+		for _, other := range chain.sources[i+1:] {
+			otherState, otherFound, err := other.lookup(ctx, featureName)
+			if err == nil && otherFound && otherState != state {
+				onConflict(source, other, state)
+			}
+		}
+		return state, fssOutcome(state)
+	}
+	if chain.precedence == requireAllEnabled {
+		return true, prometheus.PrometheusFSSEnabled
+	}
+	log.Infof("Could not find the %s feature state in %s. Setting the feature state to false",
+		featureName, chain.sourceNames())
+	return false, prometheus.PrometheusFSSMissing
+}
+
+// featureNames returns the names of the feature states defined by any of the
+// sources of the chain.
+func (chain *capabilityChain) featureNames() map[string]struct{} {
+	names := make(map[string]struct{})
+	for _, source := range chain.sources {
+		for _, name := range source.featureNames() {
+			names[name] = struct{}{}
+		}
+	}
+	return names
+}
+
+// sourceNames returns the names of the sources of the chain for the logs.
+func (chain *capabilityChain) sourceNames() string {
+	var names string
+	for i, source := range chain.sources {
+		if i > 0 {
+			names += ", "
+		}
+		names += source.name()
+	}
+	return names
+}
+
+// logFSSConflict logs, once per feature, that a source of lower precedence
+// disagrees with the source the state of the feature was taken from.
+func (c *K8sOrchestrator) logFSSConflict(ctx context.Context, featureName string) func(
+	winner, loser capabilitySource, state bool) {
+	return func(winner, loser capabilitySource, state bool) {
+		if _, logged := c.fssConflicts.LoadOrStore(featureName, struct{}{}); logged {
+			return
+		}
+		logger.GetLogger(ctx).Warnf("Feature state %s is set to %t in %s, which takes precedence over "+
+			"the conflicting value in %s", featureName, state, winner.name(), loser.name())
+	}
+}
+
+// releasedFSSSource is the capability source of the released features, which
+// are always enabled.
+type releasedFSSSource struct {
+	// released is read only after the orchestrator is initialised, so it
+	// doesn't need a lock.
+	released map[string]struct{}
+}
+
+func (s *releasedFSSSource) name() string {
+	return "the released features"
+}
+
+func (s *releasedFSSSource) featureNames() []string {
+	names := make([]string, 0, len(s.released))
+	for name := range s.released {
+		names = append(names, name)
+	}
+	return names
+}
+
+func (s *releasedFSSSource) lookup(ctx context.Context, featureName string) (bool, bool, error) {
+	_, isReleased := s.released[featureName]
+	return isReleased, isReleased, nil
+}
+
+// fssMapSource is the capability source of a feature states map kept up to
+// date from its ConfigMap or, in the guest cluster, from the
+// cnscsisvfeaturestates CR.
+type fssMapSource struct {
+	fss *FSSConfigMapInfo
+	// crFed returns true if the map is replicated from the
+	// cnscsisvfeaturestates CR instead of the ConfigMap.
+	crFed func() bool
+}
+
+func (s *fssMapSource) name() string {
+	if s.crFed != nil && s.crFed() {
+		return "the cnscsisvfeaturestates CR"
+	}
+	return "ConfigMap " + s.fss.configMapName
+}
+
+func (s *fssMapSource) featureNames() []string {
+	if s.fss.featureStatesLock == nil {
+		return nil
+	}
+	s.fss.featureStatesLock.RLock()
+	defer s.fss.featureStatesLock.RUnlock()
+	names := make([]string, 0, len(s.fss.featureStates))
+	for name := range s.fss.featureStates {
+		names = append(names, name)
+	}
+	return names
+}
+
+func (s *fssMapSource) lookup(ctx context.Context, featureName string) (bool, bool, error) {
+	if s.fss.featureStatesLock == nil {
+		return false, false, nil
+	}
+	s.fss.featureStatesLock.RLock()
+	value, ok := s.fss.featureStates[featureName]
+	s.fss.featureStatesLock.RUnlock()
+	if !ok {
+		return false, false, nil
+	}
+	state, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, true, err
+	}
+	return state, true, nil
+}
+
+// wcpCapabilitySource is the capability source of the WCP defined features,
+// read from the wcp-cluster-capabilities ConfigMap in the supervisor.
+type wcpCapabilitySource struct {
+	orchestrator *K8sOrchestrator
+}
+
+func (s *wcpCapabilitySource) name() string {
+	return "ConfigMap " + common.KubeSystemNamespace + "/" + common.WCPCapabilityConfigMapName
+}
+
+func (s *wcpCapabilitySource) featureNames() []string {
+	names := make([]string, 0, len(common.WCPFeatureStates))
+	for name := range common.WCPFeatureStates {
+		names = append(names, name)
+	}
+	return names
+}
+
+func (s *wcpCapabilitySource) lookup(ctx context.Context, featureName string) (bool, bool, error) {
+	if _, exists := common.WCPFeatureStates[featureName]; !exists {
+		return false, false, nil
+	}
+	c := s.orchestrator
+	log := logger.GetLogger(ctx)
+	// The ConfigMap is fetched on the first lookup of a WCP defined feature
+	// and cached.
+	if c.wcpCapabilityFssMap == nil {
+		log.Infof("Feature %q is a WCP defined feature state. Reading the %q configmap in %q namespace.",
+			featureName, common.WCPCapabilityConfigMapName, common.KubeSystemNamespace)
+		wcpCapabilityConfigMap, err := c.k8sClient.CoreV1().ConfigMaps(common.KubeSystemNamespace).Get(ctx,
+			common.WCPCapabilityConfigMapName, metav1.GetOptions{})
+		if err != nil {
+			return false, false, err
+		}
+		c.wcpCapabilityFssMap = wcpCapabilityConfigMap.Data
+		log.Infof("WCP cluster capabilities map - %+v", c.wcpCapabilityFssMap)
+	}
+	value, ok := c.wcpCapabilityFssMap[featureName]
+	if !ok {
+		return false, false, nil
+	}
+	state, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, true, err
+	}
+	log.Debugf("Supervisor feature state %q in WCP cluster capabilities is set to %t", featureName, state)
+	return state, true, nil
+}
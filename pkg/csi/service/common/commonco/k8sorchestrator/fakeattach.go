@@ -0,0 +1,132 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8sorchestrator
+
+import (
+	"context"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+)
+
+// fakeAttachIndex is the set of volumes whose PVCs carry the fake attach
+// annotations, maintained from PVC informer events so that the fake attach
+// checks don't need to look up PVCs. Volumes are keyed by the name of the PV
+// bound to the PVC, which is known from the PVC alone.
+type fakeAttachIndex struct {
+	lock sync.RWMutex
+	// fakeAttached is the set of volumes marked as fake attached.
+	fakeAttached map[string]struct{}
+	// ignoreInaccessible is the set of volumes allowed to be fake attached
+	// while inaccessible.
+	ignoreInaccessible map[string]struct{}
+}
+
+// newFakeAttachIndex returns an empty fakeAttachIndex.
+func newFakeAttachIndex() *fakeAttachIndex {
+	return &fakeAttachIndex{
+		fakeAttached:       make(map[string]struct{}),
+		ignoreInaccessible: make(map[string]struct{}),
+	}
+}
+
+// update records the fake attach annotations of the given PVC.
+func (i *fakeAttachIndex) update(pvc *v1.PersistentVolumeClaim) {
+	if pvc.Spec.VolumeName == "" {
+		return
+	}
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	setMember(i.fakeAttached, pvc.Spec.VolumeName, pvc.Annotations[common.AnnFakeAttached] == "yes")
+	setMember(i.ignoreInaccessible, pvc.Spec.VolumeName,
+		pvc.Annotations[common.AnnIgnoreInaccessiblePV] == "yes")
+}
+
+// remove forgets the volume bound to the given deleted PVC.
+func (i *fakeAttachIndex) remove(pvc *v1.PersistentVolumeClaim) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	delete(i.fakeAttached, pvc.Spec.VolumeName)
+	delete(i.ignoreInaccessible, pvc.Spec.VolumeName)
+}
+
+// setFakeAttached marks the volume as fake attached or not, ahead of the
+// PVC event of the corresponding annotation update.
+func (i *fakeAttachIndex) setFakeAttached(volumeName string, fakeAttached bool) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	setMember(i.fakeAttached, volumeName, fakeAttached)
+}
+
+// isFakeAttached returns true if the volume is marked as fake attached.
+func (i *fakeAttachIndex) isFakeAttached(volumeName string) bool {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	_, found := i.fakeAttached[volumeName]
+	return found
+}
+
+// ignoresInaccessible returns true if the volume is allowed to be fake
+// attached while inaccessible.
+func (i *fakeAttachIndex) ignoresInaccessible(volumeName string) bool {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	_, found := i.ignoreInaccessible[volumeName]
+	return found
+}
+
+// setMember adds the key to or removes it from the set.
+func setMember(set map[string]struct{}, key string, member bool) {
+	if member {
+		set[key] = struct{}{}
+	} else {
+		delete(set, key)
+	}
+}
+
+// pvcAdded records the fake attach annotations of an added PVC.
+func (c *K8sOrchestrator) pvcAdded(obj interface{}) {
+	if pvc, ok := obj.(*v1.PersistentVolumeClaim); ok {
+		c.fakeAttachIndex.update(pvc)
+	}
+}
+
+// pvcUpdated records the fake attach annotations of an updated PVC.
+func (c *K8sOrchestrator) pvcUpdated(oldObj, newObj interface{}) {
+	if pvc, ok := newObj.(*v1.PersistentVolumeClaim); ok {
+		c.fakeAttachIndex.update(pvc)
+	}
+}
+
+// pvcDeleted forgets the fake attach annotations of a deleted PVC.
+func (c *K8sOrchestrator) pvcDeleted(obj interface{}) {
+	if pvc, ok := obj.(*v1.PersistentVolumeClaim); ok {
+		c.fakeAttachIndex.remove(pvc)
+	}
+}
+
+// getFakeAttachVolumeName returns the name of the PV of the block volume with
+// the given volume ID, under which the volume is kept in the fake attach index.
+func (c *K8sOrchestrator) getFakeAttachVolumeName(ctx context.Context, volumeID string) (string, bool) {
+	pv := c.getPVByVolumeID(ctx, volumeID)
+	if pv == nil || pv.Spec.CSI == nil || isFileVolume(pv) {
+		return "", false
+	}
+	return pv.Name, true
+}
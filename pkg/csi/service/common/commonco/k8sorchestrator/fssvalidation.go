@@ -0,0 +1,96 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8sorchestrator
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+)
+
+// invalidFeatureStateReason is the reason of the events recorded on the
+// feature states ConfigMaps for their unknown feature state names and non
+// boolean values.
+const invalidFeatureStateReason = "InvalidFeatureState"
+
+var (
+	fssEventRecorderOnce sync.Once
+	fssEventRecorder     record.EventRecorder
+)
+
+// isFSSStrictModeEnabled returns true if the driver has to refuse to start
+// when its feature states ConfigMaps are invalid.
+func isFSSStrictModeEnabled() bool {
+	strictMode, err := strconv.ParseBool(os.Getenv(csitypes.EnvVarFSSStrictMode))
+	return err == nil && strictMode
+}
+
+// validateFSSConfigMap checks the given feature states ConfigMap against the
+// catalog of the known feature states, and warns about its unknown feature
+// state names and non boolean values in the logs and with events on the
+// ConfigMap, so that a typo silently disabling a feature gets noticed. In
+// strict mode, it returns an error if the ConfigMap is invalid.
+func (c *K8sOrchestrator) validateFSSConfigMap(ctx context.Context, configMap *v1.ConfigMap, strict bool) error {
+	log := logger.GetLogger(ctx)
+	problems := common.ValidateFeatureStates(configMap.Data)
+	if len(problems) == 0 {
+		return nil
+	}
+	for _, problem := range problems {
+		log.Warnf("Feature states ConfigMap %s/%s: %s", configMap.Namespace, configMap.Name, problem)
+		c.recordFSSConfigMapEvent(ctx, configMap, problem)
+	}
+	if strict {
+		return logger.LogNewErrorf(log, "feature states ConfigMap %s/%s is invalid and %s is set: %s",
+			configMap.Namespace, configMap.Name, csitypes.EnvVarFSSStrictMode, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// recordFSSConfigMapEvent records a warning event with the given message on
+// the given feature states ConfigMap. Events are only recorded by the
+// controller, the nodes aren't allowed to create them.
+func (c *K8sOrchestrator) recordFSSConfigMapEvent(ctx context.Context, configMap *v1.ConfigMap, message string) {
+	if c.serviceMode == "node" || c.k8sClient == nil {
+		return
+	}
+	log := logger.GetLogger(ctx)
+	fssEventRecorderOnce.Do(func() {
+		eventBroadcaster := record.NewBroadcaster()
+		eventBroadcaster.StartRecordingToSink(
+			&typedcorev1.EventSinkImpl{
+				Interface: c.k8sClient.CoreV1().Events(""),
+			},
+		)
+		fssEventRecorder = eventBroadcaster.NewRecorder(scheme.Scheme,
+			v1.EventSource{Component: csitypes.DriverName()})
+	})
+	log.Debugf("Recording %s event on ConfigMap %s/%s: %s", invalidFeatureStateReason,
+		configMap.Namespace, configMap.Name, message)
+	fssEventRecorder.Event(configMap, v1.EventTypeWarning, invalidFeatureStateReason, message)
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package k8sorchestrator
 
 import (
+	"container/list"
 	"context"
 	"crypto/sha256"
 	"fmt"
@@ -24,7 +25,6 @@ import (
 	"reflect"
 	"strconv"
 	"sync"
-	"sync/atomic"
 	"time"
 
 	snapshotterClientSet "github.com/kubernetes-csi/external-snapshotter/client/v6/clientset/versioned"
@@ -32,6 +32,7 @@ import (
 	pbmtypes "github.com/vmware/govmomi/pbm/types"
 	v1 "k8s.io/api/core/v1"
 	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	apiMeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -45,6 +46,7 @@ import (
 	cnsoperatorv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/cnsoperator"
 	cnsvolume "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
 	cnsconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
 	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
@@ -56,22 +58,17 @@ import (
 
 const informerCreateRetryInterval = 5 * time.Minute
 
-// operationModeWebHookServer indicates container running as webhook server
-const operationModeWebHookServer = "WEBHOOK_SERVER"
-
-var (
-	k8sOrchestratorInstance            *K8sOrchestrator
-	k8sOrchestratorInstanceInitialized uint32
-	// doesSvFssCRExist is set only in Guest cluster flavor if
-	// the cnscsisvfeaturestate CR exists in the supervisor namespace
-	// of the TKG cluster.
-	doesSvFssCRExist         bool
-	serviceMode              string
-	operationMode            string
-	svFssCRMutex             = &sync.RWMutex{}
-	k8sOrchestratorInitMutex = &sync.RWMutex{}
-	// wcpCapabilityFssMap is the cache variable which stores the data of wcp-cluster-capabilities configmap.
-	wcpCapabilityFssMap map[string]string
+const (
+	// volumeNameToNodesCacheName is the name of volumeNameToNodesMap in the
+	// orchestrator cache metrics.
+	volumeNameToNodesCacheName = "volume-name-to-nodes"
+	// pvVolumeIDIndex is the name of the PV informer index by volume ID.
+	pvVolumeIDIndex = "csi.vsphere.vmware.com/volume-id"
+	// pvClaimIndex is the name of the PV informer index by bound PVC.
+	pvClaimIndex = "csi.vsphere.vmware.com/claim"
+	// vaPVNodeIndex is the name of the volume attachment informer index by PV
+	// and node name.
+	vaPVNodeIndex = "csi.vsphere.vmware.com/pv-node"
 )
 
 // FSSConfigMapInfo contains details about the FSS configmap(s) present in
@@ -83,43 +80,38 @@ type FSSConfigMapInfo struct {
 	configMapNamespace string
 }
 
-// Map of volume handles to the pvc it is bound to.
-// Key is the volume handle ID and value is the namespaced name of the pvc.
+// Map of the volumeName which refers to the PVName, to the list of node names in the cluster.
+// Key is the volume name and value is the list of published nodes for the volume
 // The methods to add, remove and get entries from the map in a threadsafe
-// manner are defined.
-type volumeIDToPvcMap struct {
+// manner are defined. When maxEntries is set, the map holds at most maxEntries
+// entries and evicts the least recently used ones. Entries absent from a
+// bounded map are looked up from the API server.
+type volumeNameToNodesMap struct {
 	*sync.RWMutex
-	items map[string]string
-}
-
-// Adds an entry to volumeIDToPvcMap in a thread safe manner.
-func (m *volumeIDToPvcMap) add(volumeHandle, pvcName string) {
-	m.Lock()
-	defer m.Unlock()
-	m.items[volumeHandle] = pvcName
-}
-
-// Removes a volume handle from volumeIDToPvcMap in a thread safe manner.
-func (m *volumeIDToPvcMap) remove(volumeHandle string) {
-	m.Lock()
-	defer m.Unlock()
-	delete(m.items, volumeHandle)
+	items      map[string][]string
+	maxEntries int
+	lru        *list.List
+	lruEntries map[string]*list.Element
 }
 
-// Returns the namespaced pvc name corresponding to volumeHandle.
-func (m *volumeIDToPvcMap) get(volumeHandle string) string {
-	m.RLock()
-	defer m.RUnlock()
-	return m.items[volumeHandle]
+// newVolumeNameToNodesMap returns an empty volumeNameToNodesMap, bounded to
+// maxEntries entries if maxEntries is greater than 0.
+func newVolumeNameToNodesMap(maxEntries int) *volumeNameToNodesMap {
+	m := &volumeNameToNodesMap{
+		RWMutex: &sync.RWMutex{},
+		items:   make(map[string][]string),
+	}
+	if maxEntries > 0 {
+		m.maxEntries = maxEntries
+		m.lru = list.New()
+		m.lruEntries = make(map[string]*list.Element)
+	}
+	return m
 }
 
-// Map of the volumeName which refers to the PVName, to the list of node names in the cluster.
-// Key is the volume name and value is the list of published nodes for the volume
-// The methods to add, remove and get entries from the map in a threadsafe
-// manner are defined.
-type volumeNameToNodesMap struct {
-	*sync.RWMutex
-	items map[string][]string
+// isBounded returns true if the map evicts entries beyond maxEntries.
+func (m *volumeNameToNodesMap) isBounded() bool {
+	return m.maxEntries > 0
 }
 
 // Adds an entry to volumeNameToNodesMap in a thread safe manner.
@@ -127,6 +119,21 @@ func (m *volumeNameToNodesMap) add(volumeName string, nodes []string) {
 	m.Lock()
 	defer m.Unlock()
 	m.items[volumeName] = nodes
+	if m.isBounded() {
+		if elem, found := m.lruEntries[volumeName]; found {
+			m.lru.MoveToFront(elem)
+		} else {
+			m.lruEntries[volumeName] = m.lru.PushFront(volumeName)
+		}
+		for m.lru.Len() > m.maxEntries {
+			oldest := m.lru.Back()
+			m.lru.Remove(oldest)
+			delete(m.lruEntries, oldest.Value.(string))
+			delete(m.items, oldest.Value.(string))
+			prometheus.OrchestratorCacheEvictionsCounter.WithLabelValues(volumeNameToNodesCacheName).Inc()
+		}
+	}
+	prometheus.OrchestratorCacheEntriesGaugeVec.WithLabelValues(volumeNameToNodesCacheName).Set(float64(len(m.items)))
 }
 
 // Removes a volumeName from the volumeNameToNodesMap in a thread safe manner.
@@ -134,13 +141,43 @@ func (m *volumeNameToNodesMap) remove(volumeName string) {
 	m.Lock()
 	defer m.Unlock()
 	delete(m.items, volumeName)
+	if m.isBounded() {
+		if elem, found := m.lruEntries[volumeName]; found {
+			m.lru.Remove(elem)
+			delete(m.lruEntries, volumeName)
+		}
+	}
+	prometheus.OrchestratorCacheEntriesGaugeVec.WithLabelValues(volumeNameToNodesCacheName).Set(float64(len(m.items)))
 }
 
-// Returns the list of published nodes for the given pvName in a thread safe manner.
-func (m *volumeNameToNodesMap) get(volumeName string) []string {
-	m.RLock()
-	defer m.RUnlock()
-	return m.items[volumeName]
+// Returns the list of published nodes for the given pvName in a thread safe
+// manner, and whether the map holds an entry for it.
+func (m *volumeNameToNodesMap) get(volumeName string) ([]string, bool) {
+	if m.isBounded() {
+		// Lookups reorder the LRU list of a bounded map.
+		m.Lock()
+		defer m.Unlock()
+		if elem, found := m.lruEntries[volumeName]; found {
+			m.lru.MoveToFront(elem)
+		}
+	} else {
+		m.RLock()
+		defer m.RUnlock()
+	}
+	nodes, found := m.items[volumeName]
+	return nodes, found
+}
+
+// getForUpdate returns the list of published nodes for the given pvName to be
+// updated on a volume attachment event. It returns false if the update has to
+// be skipped as the entry was evicted from a bounded map, in which case the
+// entry is looked up from the API server on the next read.
+func (m *volumeNameToNodesMap) getForUpdate(volumeName string) ([]string, bool) {
+	nodes, found := m.get(volumeName)
+	if !found && m.isBounded() {
+		return nil, false
+	}
+	return nodes, true
 }
 
 // Map of nodeID to node names in the cluster. Key is the nodeID
@@ -165,35 +202,34 @@ func (m *nodeIDToNameMap) remove(nodeID string) {
 	delete(m.items, nodeID)
 }
 
-// Map of volume ID to volume name.
-// Key is the volume ID and value is the volume name.
-// The methods to add, remove and get entries from the map in a threadsafe
-// manner are defined.
-type volumeIDToNameMap struct {
-	*sync.RWMutex
+// Map of CNS snapshot IDs to the vCenter of the snapshot, for the snapshots
+// whose CSI snapshot ID is not scoped to a vCenter. The methods to add, get
+// and remove entries from the map in a threadsafe manner are defined.
+type snapshotIDToVCenterMap struct {
+	sync.RWMutex
 	items map[string]string
 }
 
-// Adds an entry to volumeNameToIDMap in a thread safe manner.
-func (m *volumeIDToNameMap) add(volumeID, volumeName string) {
-	m.Lock()
-	defer m.Unlock()
-	m.items[volumeID] = volumeName
-}
-
-// Removes a volume ID from volumeNameToIDMap in a thread safe manner.
-func (m *volumeIDToNameMap) remove(volumeID string) {
+// Adds an entry to snapshotIDToVCenterMap in a thread safe manner.
+func (m *snapshotIDToVCenterMap) add(snapshotID, vCenterHost string) {
 	m.Lock()
 	defer m.Unlock()
-	delete(m.items, volumeID)
+	m.items[snapshotID] = vCenterHost
 }
 
-// Returns the volume ID corresponding to volumeName.
-func (m *volumeIDToNameMap) get(volumeID string) (string, bool) {
+// Returns the vCenter of the given snapshot in a thread safe manner.
+func (m *snapshotIDToVCenterMap) get(snapshotID string) (string, bool) {
 	m.RLock()
 	defer m.RUnlock()
-	volumeName, found := m.items[volumeID]
-	return volumeName, found
+	vCenterHost, found := m.items[snapshotID]
+	return vCenterHost, found
+}
+
+// Removes an entry from snapshotIDToVCenterMap in a thread safe manner.
+func (m *snapshotIDToVCenterMap) remove(snapshotID string) {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.items, snapshotID)
 }
 
 // K8sOrchestrator defines set of properties specific to K8s.
@@ -203,12 +239,55 @@ type K8sOrchestrator struct {
 	releasedVanillaFSS   map[string]struct{}
 	informerManager      *k8s.InformerManager
 	clusterFlavor        cnstypes.CnsClusterFlavor
-	volumeIDToPvcMap     *volumeIDToPvcMap
 	nodeIDToNameMap      *nodeIDToNameMap
 	volumeNameToNodesMap *volumeNameToNodesMap // used when ListVolume FSS is enabled
-	volumeIDToNameMap    *volumeIDToNameMap    // used when ListVolume FSS is enabled
-	k8sClient            clientset.Interface
-	snapshotterClient    snapshotterClientSet.Interface
+	pvIndexer            cache.Indexer         // used when ListVolume FSS is enabled
+	vaIndexer            cache.Indexer         // used when ListVolume FSS is enabled
+	fakeAttachIndex      *fakeAttachIndex      // used when FakeAttach FSS is enabled
+	// supervisorPVCIndexer is the PV indexer by guest PVC, used in the guest
+	// cluster controller.
+	supervisorPVCIndexer cache.Indexer
+	// supervisorNamespace is the supervisor namespace of the guest cluster.
+	supervisorNamespace string
+	// snapshotIDToVCenterMap caches the vCenter of snapshots in multi vCenter
+	// deployments.
+	snapshotIDToVCenterMap *snapshotIDToVCenterMap
+	k8sClient              clientset.Interface
+	snapshotterClient      snapshotterClientSet.Interface
+	// serviceMode is the mode, "controller" or "node", of the container.
+	serviceMode string
+	// operationMode is the operation mode of the syncer container.
+	operationMode string
+	// doesSvFssCRExist is set only in Guest cluster flavor if
+	// the cnscsisvfeaturestate CR exists in the supervisor namespace
+	// of the TKG cluster.
+	doesSvFssCRExist bool
+	svFssCRMutex     sync.RWMutex
+	// wcpCapabilityFssMap caches the data of the wcp-cluster-capabilities
+	// configmap.
+	wcpCapabilityFssMap map[string]string
+	// fssConflicts holds the names of the features whose conflicting states
+	// in the capability sources have already been logged.
+	fssConflicts sync.Map
+}
+
+// K8sOrchestratorOptions lists the options of a K8sOrchestrator instance.
+type K8sOrchestratorOptions struct {
+	// ClusterFlavor is the flavor of the cluster the orchestrator runs in.
+	ClusterFlavor cnstypes.CnsClusterFlavor
+	// InitParams are the flavor specific init params, i.e. one of
+	// K8sVanillaInitParams, K8sSupervisorInitParams or K8sGuestInitParams.
+	InitParams interface{}
+	// K8sClient is the client of the cluster. A client based on the service
+	// account of the container is created if not set.
+	K8sClient clientset.Interface
+	// SnapshotterClient is the client of the snapshot APIs of the cluster.
+	// A client based on the service account of the container is created if
+	// not set.
+	SnapshotterClient snapshotterClientSet.Interface
+	// InformerManager is the informer manager used to watch the cluster.
+	// The in-cluster informer manager is used if not set.
+	InformerManager *k8s.InformerManager
 }
 
 // K8sGuestInitParams lists the set of parameters required to run the init for
@@ -236,109 +315,116 @@ type K8sVanillaInitParams struct {
 	OperationMode                   string
 }
 
-// Newk8sOrchestrator instantiates K8sOrchestrator object and returns this
-// object. NOTE: As Newk8sOrchestrator is created in the init of the driver and
-// syncer components, raise an error only if it is of utmost importance.
-func Newk8sOrchestrator(ctx context.Context, controllerClusterFlavor cnstypes.CnsClusterFlavor,
-	params interface{}) (*K8sOrchestrator, error) {
-	var (
-		coInstanceErr     error
-		k8sClient         clientset.Interface
-		snapshotterClient snapshotterClientSet.Interface
-	)
-	if atomic.LoadUint32(&k8sOrchestratorInstanceInitialized) == 0 {
-		k8sOrchestratorInitMutex.Lock()
-		defer k8sOrchestratorInitMutex.Unlock()
-		if k8sOrchestratorInstanceInitialized == 0 {
-			log := logger.GetLogger(ctx)
-			log.Info("Initializing k8sOrchestratorInstance")
-
-			// Create a K8s client
-			k8sClient, coInstanceErr = k8s.NewClient(ctx)
-			if coInstanceErr != nil {
-				log.Errorf("Creating Kubernetes client failed. Err: %v", coInstanceErr)
-				return nil, coInstanceErr
-			}
-
-			// Create a snapshotter client
-			snapshotterClient, coInstanceErr = k8s.NewSnapshotterClient(ctx)
-			if coInstanceErr != nil {
-				log.Errorf("Creating Snapshotter client failed. Err: %v", coInstanceErr)
-				return nil, coInstanceErr
-			}
-
-			k8sOrchestratorInstance = &K8sOrchestrator{}
-			k8sOrchestratorInstance.clusterFlavor = controllerClusterFlavor
-			k8sOrchestratorInstance.k8sClient = k8sClient
-			k8sOrchestratorInstance.snapshotterClient = snapshotterClient
-			k8sOrchestratorInstance.informerManager = k8s.NewInformer(ctx, k8sClient, true)
-			coInstanceErr = initFSS(ctx, k8sClient, controllerClusterFlavor, params)
-			if coInstanceErr != nil {
-				log.Errorf("Failed to initialize the orchestrator. Error: %v", coInstanceErr)
-				return nil, coInstanceErr
-			}
+// NewK8sOrchestrator instantiates a K8sOrchestrator configured with opts and
+// starts its informers. Each call returns a new instance, independent of the
+// ones created before. NOTE: As the orchestrator is created in the init of
+// the driver and syncer components, raise an error only if it is of utmost
+// importance.
+func NewK8sOrchestrator(ctx context.Context, opts K8sOrchestratorOptions) (*K8sOrchestrator, error) {
+	log := logger.GetLogger(ctx)
+	log.Info("Initializing K8sOrchestrator")
+	var err error
+	c := &K8sOrchestrator{
+		clusterFlavor:     opts.ClusterFlavor,
+		k8sClient:         opts.K8sClient,
+		snapshotterClient: opts.SnapshotterClient,
+		informerManager:   opts.InformerManager,
+		snapshotIDToVCenterMap: &snapshotIDToVCenterMap{
+			items: make(map[string]string),
+		},
+	}
+	if c.k8sClient == nil {
+		c.k8sClient, err = k8s.NewClient(ctx)
+		if err != nil {
+			log.Errorf("Creating Kubernetes client failed. Err: %v", err)
+			return nil, err
+		}
+	}
+	if c.snapshotterClient == nil {
+		c.snapshotterClient, err = k8s.NewSnapshotterClient(ctx)
+		if err != nil {
+			log.Errorf("Creating Snapshotter client failed. Err: %v", err)
+			return nil, err
+		}
+	}
+	if c.informerManager == nil {
+		c.informerManager = k8s.NewInformer(ctx, c.k8sClient, true)
+	}
+	err = c.initFSS(ctx, c.k8sClient, c.clusterFlavor, opts.InitParams)
+	if err != nil {
+		log.Errorf("Failed to initialize the orchestrator. Error: %v", err)
+		return nil, err
+	}
 
-			if controllerClusterFlavor == cnstypes.CnsClusterFlavorWorkload {
-				svInitParams, ok := params.(K8sSupervisorInitParams)
-				if !ok {
-					return nil, fmt.Errorf("expected orchestrator params of type K8sSupervisorInitParams, got %T instead", params)
-				}
-				operationMode = svInitParams.OperationMode
-			} else if controllerClusterFlavor == cnstypes.CnsClusterFlavorVanilla {
-				vanillaInitParams, ok := params.(K8sVanillaInitParams)
-				if !ok {
-					return nil, fmt.Errorf("expected orchestrator params of type K8sVanillaInitParams, got %T instead", params)
-				}
-				operationMode = vanillaInitParams.OperationMode
-				k8sOrchestratorInstance.releasedVanillaFSS = getReleasedVanillaFSS()
-			} else if controllerClusterFlavor == cnstypes.CnsClusterFlavorGuest {
-				guestInitParams, ok := params.(K8sGuestInitParams)
-				if !ok {
-					return nil, fmt.Errorf("expected orchestrator params of type K8sGuestInitParams, got %T instead", params)
-				}
-				operationMode = guestInitParams.OperationMode
-			} else {
-				return nil, fmt.Errorf("wrong orchestrator params type")
-			}
+	if c.clusterFlavor == cnstypes.CnsClusterFlavorWorkload {
+		svInitParams, ok := opts.InitParams.(K8sSupervisorInitParams)
+		if !ok {
+			return nil, fmt.Errorf("expected orchestrator params of type K8sSupervisorInitParams, got %T instead",
+				opts.InitParams)
+		}
+		c.operationMode = svInitParams.OperationMode
+	} else if c.clusterFlavor == cnstypes.CnsClusterFlavorVanilla {
+		vanillaInitParams, ok := opts.InitParams.(K8sVanillaInitParams)
+		if !ok {
+			return nil, fmt.Errorf("expected orchestrator params of type K8sVanillaInitParams, got %T instead",
+				opts.InitParams)
+		}
+		c.operationMode = vanillaInitParams.OperationMode
+		c.releasedVanillaFSS = getReleasedVanillaFSS()
+	} else if c.clusterFlavor == cnstypes.CnsClusterFlavorGuest {
+		guestInitParams, ok := opts.InitParams.(K8sGuestInitParams)
+		if !ok {
+			return nil, fmt.Errorf("expected orchestrator params of type K8sGuestInitParams, got %T instead",
+				opts.InitParams)
+		}
+		c.operationMode = guestInitParams.OperationMode
+	} else {
+		return nil, fmt.Errorf("wrong orchestrator params type")
+	}
+	subsystems, err := common.GetOperationModeSubsystems(c.operationMode, c.serviceMode)
+	if err != nil {
+		return nil, err
+	}
 
-			if ((controllerClusterFlavor == cnstypes.CnsClusterFlavorWorkload &&
-				k8sOrchestratorInstance.IsFSSEnabled(ctx, common.FakeAttach)) ||
-				(controllerClusterFlavor == cnstypes.CnsClusterFlavorVanilla &&
-					k8sOrchestratorInstance.IsFSSEnabled(ctx, common.ListVolumes))) &&
-				(operationMode != operationModeWebHookServer) {
-				err := initVolumeHandleToPvcMap(ctx, controllerClusterFlavor)
-				if err != nil {
-					return nil, fmt.Errorf("failed to create volume handle to PVC map. Error: %v", err)
-				}
-			}
+	if ((c.clusterFlavor == cnstypes.CnsClusterFlavorWorkload && c.IsFSSEnabled(ctx, common.FakeAttach)) ||
+		(c.clusterFlavor == cnstypes.CnsClusterFlavorVanilla && c.IsFSSEnabled(ctx, common.ListVolumes))) &&
+		subsystems.VolumeMaps {
+		err := c.initPVIndexers(ctx, c.clusterFlavor)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create PV indexers. Error: %v", err)
+		}
+	}
 
-			if (controllerClusterFlavor == cnstypes.CnsClusterFlavorWorkload) &&
-				(operationMode != operationModeWebHookServer) {
-				// Initialize the map for volumeName to nodes, as it is needed for WCP detach volume handling
-				err := initVolumeNameToNodesMap(ctx, controllerClusterFlavor)
-				if err != nil {
-					return nil, fmt.Errorf("failed to create PV name to node names map. Error: %v", err)
-				}
-				err = initNodeIDToNameMap(ctx)
-				if err != nil {
-					return nil, fmt.Errorf("failed to create node ID to name map. Error: %v", err)
-				}
-			} else if operationMode != operationModeWebHookServer {
-				// Initialize the map for volumeName to nodes, for non-WCP flavors and when ListVolume FSS is on
-				if k8sOrchestratorInstance.IsFSSEnabled(ctx, common.ListVolumes) {
-					err := initVolumeNameToNodesMap(ctx, controllerClusterFlavor)
-					if err != nil {
-						return nil, fmt.Errorf("failed to create PV name to node names map. Error: %v", err)
-					}
-				}
+	if c.clusterFlavor == cnstypes.CnsClusterFlavorWorkload && subsystems.VolumeMaps {
+		// Initialize the map for volumeName to nodes, as it is needed for WCP detach volume handling
+		err := c.initVolumeNameToNodesMap(ctx, c.clusterFlavor)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create PV name to node names map. Error: %v", err)
+		}
+		err = c.initNodeIDToNameMap(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create node ID to name map. Error: %v", err)
+		}
+	} else if subsystems.VolumeMaps {
+		// Initialize the map for volumeName to nodes, for non-WCP flavors and when ListVolume FSS is on
+		if c.IsFSSEnabled(ctx, common.ListVolumes) {
+			err := c.initVolumeNameToNodesMap(ctx, c.clusterFlavor)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create PV name to node names map. Error: %v", err)
 			}
+		}
+	}
 
-			k8sOrchestratorInstance.informerManager.Listen()
-			atomic.StoreUint32(&k8sOrchestratorInstanceInitialized, 1)
-			log.Info("k8sOrchestratorInstance initialized")
+	if c.clusterFlavor == cnstypes.CnsClusterFlavorGuest && subsystems.VolumeMaps {
+		err := c.initSupervisorPVCIndex(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create supervisor PVC index. Error: %v", err)
 		}
 	}
-	return k8sOrchestratorInstance, nil
+
+	c.informerManager.Listen()
+	log.Info("K8sOrchestrator initialized")
+	return c, nil
 }
 
 func getReleasedVanillaFSS() map[string]struct{} {
@@ -363,7 +449,7 @@ func getReleasedVanillaFSS() map[string]struct{} {
 // states map and keep a watch on it. NOTE: As initFSS is called during the
 // init of the driver and syncer components, raise an error only if the
 // containers need to crash.
-func initFSS(ctx context.Context, k8sClient clientset.Interface,
+func (c *K8sOrchestrator) initFSS(ctx context.Context, k8sClient clientset.Interface,
 	controllerClusterFlavor cnstypes.CnsClusterFlavor, params interface{}) error {
 	log := logger.GetLogger(ctx)
 	var (
@@ -373,83 +459,85 @@ func initFSS(ctx context.Context, k8sClient clientset.Interface,
 	)
 	// Store configmap info in global variables to access later.
 	if controllerClusterFlavor == cnstypes.CnsClusterFlavorWorkload {
-		k8sOrchestratorInstance.supervisorFSS.featureStatesLock = &sync.RWMutex{}
-		k8sOrchestratorInstance.supervisorFSS.featureStates = make(map[string]string)
+		c.supervisorFSS.featureStatesLock = &sync.RWMutex{}
+		c.supervisorFSS.featureStates = make(map[string]string)
 		// Validate init params
 		svInitParams, ok := params.(K8sSupervisorInitParams)
 		if !ok {
 			return fmt.Errorf("expected orchestrator params of type K8sSupervisorInitParams, got %T instead", params)
 		}
-		k8sOrchestratorInstance.supervisorFSS.configMapName = svInitParams.SupervisorFeatureStatesConfigInfo.Name
-		k8sOrchestratorInstance.supervisorFSS.configMapNamespace = svInitParams.SupervisorFeatureStatesConfigInfo.Namespace
-		configMapNamespaceToListen = k8sOrchestratorInstance.supervisorFSS.configMapNamespace
-		serviceMode = svInitParams.ServiceMode
+		c.supervisorFSS.configMapName = svInitParams.SupervisorFeatureStatesConfigInfo.Name
+		c.supervisorFSS.configMapNamespace = svInitParams.SupervisorFeatureStatesConfigInfo.Namespace
+		configMapNamespaceToListen = c.supervisorFSS.configMapNamespace
+		c.serviceMode = svInitParams.ServiceMode
 	}
 	if controllerClusterFlavor == cnstypes.CnsClusterFlavorVanilla {
-		k8sOrchestratorInstance.internalFSS.featureStatesLock = &sync.RWMutex{}
-		k8sOrchestratorInstance.internalFSS.featureStates = make(map[string]string)
+		c.internalFSS.featureStatesLock = &sync.RWMutex{}
+		c.internalFSS.featureStates = make(map[string]string)
 		// Validate init params.
 		vanillaInitParams, ok := params.(K8sVanillaInitParams)
 		if !ok {
 			return fmt.Errorf("expected orchestrator params of type K8sVanillaInitParams, got %T instead", params)
 		}
-		k8sOrchestratorInstance.internalFSS.configMapName = vanillaInitParams.InternalFeatureStatesConfigInfo.Name
-		k8sOrchestratorInstance.internalFSS.configMapNamespace = vanillaInitParams.InternalFeatureStatesConfigInfo.Namespace
-		configMapNamespaceToListen = k8sOrchestratorInstance.internalFSS.configMapNamespace
-		serviceMode = vanillaInitParams.ServiceMode
+		c.internalFSS.configMapName = vanillaInitParams.InternalFeatureStatesConfigInfo.Name
+		c.internalFSS.configMapNamespace = vanillaInitParams.InternalFeatureStatesConfigInfo.Namespace
+		configMapNamespaceToListen = c.internalFSS.configMapNamespace
+		c.serviceMode = vanillaInitParams.ServiceMode
 	}
 	if controllerClusterFlavor == cnstypes.CnsClusterFlavorGuest {
-		k8sOrchestratorInstance.supervisorFSS.featureStatesLock = &sync.RWMutex{}
-		k8sOrchestratorInstance.supervisorFSS.featureStates = make(map[string]string)
-		k8sOrchestratorInstance.internalFSS.featureStatesLock = &sync.RWMutex{}
-		k8sOrchestratorInstance.internalFSS.featureStates = make(map[string]string)
+		c.supervisorFSS.featureStatesLock = &sync.RWMutex{}
+		c.supervisorFSS.featureStates = make(map[string]string)
+		c.internalFSS.featureStatesLock = &sync.RWMutex{}
+		c.internalFSS.featureStates = make(map[string]string)
 		// Validate init params.
 		guestInitParams, ok := params.(K8sGuestInitParams)
 		if !ok {
 			return fmt.Errorf("expected orchestrator params of type K8sGuestInitParams, got %T instead", params)
 		}
-		k8sOrchestratorInstance.internalFSS.configMapName = guestInitParams.InternalFeatureStatesConfigInfo.Name
-		k8sOrchestratorInstance.internalFSS.configMapNamespace = guestInitParams.InternalFeatureStatesConfigInfo.Namespace
-		k8sOrchestratorInstance.supervisorFSS.configMapName = guestInitParams.SupervisorFeatureStatesConfigInfo.Name
-		k8sOrchestratorInstance.supervisorFSS.configMapNamespace = guestInitParams.SupervisorFeatureStatesConfigInfo.Namespace
-		// As of now, TKGS is having both supervisor FSS and internal FSS in the
-		// same namespace. If the configmap's namespaces change in future, we may
-		// need listeners on different namespaces. Until then, we will initialize
-		// configMapNamespaceToListen to internalFSS.configMapNamespace.
-		configMapNamespaceToListen = k8sOrchestratorInstance.internalFSS.configMapNamespace
-		serviceMode = guestInitParams.ServiceMode
+		c.internalFSS.configMapName = guestInitParams.InternalFeatureStatesConfigInfo.Name
+		c.internalFSS.configMapNamespace = guestInitParams.InternalFeatureStatesConfigInfo.Namespace
+		c.supervisorFSS.configMapName = guestInitParams.SupervisorFeatureStatesConfigInfo.Name
+		c.supervisorFSS.configMapNamespace = guestInitParams.SupervisorFeatureStatesConfigInfo.Namespace
+		// TKGS has both supervisor FSS and internal FSS in the same namespace by
+		// default. If the supervisor FSS configmap is in another namespace, a
+		// separate listener is set up on it below.
+		configMapNamespaceToListen = c.internalFSS.configMapNamespace
+		c.serviceMode = guestInitParams.ServiceMode
 	}
 
 	// Initialize internal FSS map values.
 	if controllerClusterFlavor == cnstypes.CnsClusterFlavorGuest ||
 		controllerClusterFlavor == cnstypes.CnsClusterFlavorVanilla {
-		if k8sOrchestratorInstance.internalFSS.configMapName != "" &&
-			k8sOrchestratorInstance.internalFSS.configMapNamespace != "" {
+		if c.internalFSS.configMapName != "" &&
+			c.internalFSS.configMapNamespace != "" {
 			// Retrieve configmap.
-			fssConfigMap, err = k8sClient.CoreV1().ConfigMaps(k8sOrchestratorInstance.internalFSS.configMapNamespace).Get(
-				ctx, k8sOrchestratorInstance.internalFSS.configMapName, metav1.GetOptions{})
+			fssConfigMap, err = k8sClient.CoreV1().ConfigMaps(c.internalFSS.configMapNamespace).Get(
+				ctx, c.internalFSS.configMapName, metav1.GetOptions{})
 			if err != nil {
 				// return error as we cannot init containers without this info.
 				log.Errorf("failed to fetch configmap %s from namespace %s. Error: %v",
-					k8sOrchestratorInstance.internalFSS.configMapName,
-					k8sOrchestratorInstance.internalFSS.configMapNamespace, err)
+					c.internalFSS.configMapName,
+					c.internalFSS.configMapNamespace, err)
+				return err
+			}
+			if err = c.validateFSSConfigMap(ctx, fssConfigMap, isFSSStrictModeEnabled()); err != nil {
 				return err
 			}
 			// Update values.
-			k8sOrchestratorInstance.internalFSS.featureStatesLock.Lock()
-			k8sOrchestratorInstance.internalFSS.featureStates = fssConfigMap.Data
+			c.internalFSS.featureStatesLock.Lock()
+			c.internalFSS.featureStates = fssConfigMap.Data
 			log.Infof("New internal feature states values stored successfully: %v",
-				k8sOrchestratorInstance.internalFSS.featureStates)
-			k8sOrchestratorInstance.internalFSS.featureStatesLock.Unlock()
+				c.internalFSS.featureStates)
+			c.internalFSS.featureStatesLock.Unlock()
 		}
 	}
 
-	if controllerClusterFlavor == cnstypes.CnsClusterFlavorGuest && serviceMode != "node" {
+	if controllerClusterFlavor == cnstypes.CnsClusterFlavorGuest && c.serviceMode != "node" {
 		var isFSSCREnabled bool
 		// Check if csi-sv-feature-states-replication FSS exists and is enabled.
-		k8sOrchestratorInstance.internalFSS.featureStatesLock.RLock()
-		if val, ok := k8sOrchestratorInstance.internalFSS.featureStates[common.CSISVFeatureStateReplication]; ok {
-			k8sOrchestratorInstance.internalFSS.featureStatesLock.RUnlock()
+		c.internalFSS.featureStatesLock.RLock()
+		if val, ok := c.internalFSS.featureStates[common.CSISVFeatureStateReplication]; ok {
+			c.internalFSS.featureStatesLock.RUnlock()
 			isFSSCREnabled, err = strconv.ParseBool(val)
 			if err != nil {
 				log.Errorf("unable to convert %v to bool. csi-sv-feature-states-replication FSS disabled. Error: %v",
@@ -457,7 +545,7 @@ func initFSS(ctx context.Context, k8sClient clientset.Interface,
 				return err
 			}
 		} else {
-			k8sOrchestratorInstance.internalFSS.featureStatesLock.RUnlock()
+			c.internalFSS.featureStatesLock.RUnlock()
 			return logger.LogNewError(log, "csi-sv-feature-states-replication FSS not present")
 		}
 
@@ -490,23 +578,23 @@ func initFSS(ctx context.Context, k8sClient clientset.Interface,
 				if ok {
 					log.Infof("%s CR not found in supervisor namespace. Defaulting to the %q FSS configmap "+
 						"in %q namespace. Error: %+v",
-						featurestates.CRDSingular, k8sOrchestratorInstance.supervisorFSS.configMapName,
-						k8sOrchestratorInstance.supervisorFSS.configMapNamespace, err)
+						featurestates.CRDSingular, c.supervisorFSS.configMapName,
+						c.supervisorFSS.configMapNamespace, err)
 				} else {
 					log.Errorf("failed to get %s CR from supervisor namespace %q. Error: %+v",
 						featurestates.CRDSingular, svNamespace, err)
 					return err
 				}
 			} else {
-				setSvFssCRAvailability(true)
+				c.setSvFssCRAvailability(true)
 				// Store supervisor FSS values in cache.
-				k8sOrchestratorInstance.supervisorFSS.featureStatesLock.Lock()
+				c.supervisorFSS.featureStatesLock.Lock()
 				for _, svFSS := range svFssCR.Spec.FeatureStates {
-					k8sOrchestratorInstance.supervisorFSS.featureStates[svFSS.Name] = strconv.FormatBool(svFSS.Enabled)
+					c.supervisorFSS.featureStates[svFSS.Name] = strconv.FormatBool(svFSS.Enabled)
 				}
 				log.Infof("New supervisor feature states values stored successfully from %s CR object: %v",
-					featurestates.SVFeatureStateCRName, k8sOrchestratorInstance.supervisorFSS.featureStates)
-				k8sOrchestratorInstance.supervisorFSS.featureStatesLock.Unlock()
+					featurestates.SVFeatureStateCRName, c.supervisorFSS.featureStates)
+				c.supervisorFSS.featureStatesLock.Unlock()
 			}
 
 			// Create an informer to watch on the cnscsisvfeaturestate CR.
@@ -537,20 +625,8 @@ func initFSS(ctx context.Context, k8sClient clientset.Interface,
 					break
 				}
 				// Set up namespaced listener for cnscsisvfeaturestate CR.
-				_, err = dynInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
-					// Add.
-					AddFunc: func(obj interface{}) {
-						fssCRAdded(obj)
-					},
-					// Update.
-					UpdateFunc: func(oldObj interface{}, newObj interface{}) {
-						fssCRUpdated(oldObj, newObj)
-					},
-					// Delete.
-					DeleteFunc: func(obj interface{}) {
-						fssCRDeleted(obj)
-					},
-				})
+				_, err = dynInformer.Informer().AddEventHandler(k8s.NewEventHandler(featurestates.CRDPlural,
+					c.fssCRAdded, c.fssCRUpdated, c.fssCRDeleted))
 				if err != nil {
 					log.Errorf("failed to add event handler for informer on %q CR. Error: %v",
 						featurestates.CRDPlural, err)
@@ -567,60 +643,74 @@ func initFSS(ctx context.Context, k8sClient clientset.Interface,
 	// CR is not registered yet.
 	if controllerClusterFlavor == cnstypes.CnsClusterFlavorWorkload ||
 		(controllerClusterFlavor == cnstypes.CnsClusterFlavorGuest &&
-			!getSvFssCRAvailability()) {
-		if k8sOrchestratorInstance.supervisorFSS.configMapName != "" &&
-			k8sOrchestratorInstance.supervisorFSS.configMapNamespace != "" {
+			!c.getSvFssCRAvailability()) {
+		if c.supervisorFSS.configMapName != "" &&
+			c.supervisorFSS.configMapNamespace != "" {
 			// Retrieve configmap.
-			fssConfigMap, err = k8sClient.CoreV1().ConfigMaps(k8sOrchestratorInstance.supervisorFSS.configMapNamespace).Get(
-				ctx, k8sOrchestratorInstance.supervisorFSS.configMapName, metav1.GetOptions{})
+			fssConfigMap, err = k8sClient.CoreV1().ConfigMaps(c.supervisorFSS.configMapNamespace).Get(
+				ctx, c.supervisorFSS.configMapName, metav1.GetOptions{})
 			if err != nil {
 				log.Errorf("failed to fetch configmap %s from namespace %s. Error: %v",
-					k8sOrchestratorInstance.supervisorFSS.configMapName,
-					k8sOrchestratorInstance.supervisorFSS.configMapNamespace, err)
+					c.supervisorFSS.configMapName,
+					c.supervisorFSS.configMapNamespace, err)
+				return err
+			}
+			if err = c.validateFSSConfigMap(ctx, fssConfigMap, isFSSStrictModeEnabled()); err != nil {
 				return err
 			}
 			// Update values.
-			k8sOrchestratorInstance.supervisorFSS.featureStatesLock.Lock()
-			k8sOrchestratorInstance.supervisorFSS.featureStates = fssConfigMap.Data
+			c.supervisorFSS.featureStatesLock.Lock()
+			c.supervisorFSS.featureStates = fssConfigMap.Data
 			log.Infof("New supervisor feature states values stored successfully: %v",
-				k8sOrchestratorInstance.supervisorFSS.featureStates)
-			k8sOrchestratorInstance.supervisorFSS.featureStatesLock.Unlock()
+				c.supervisorFSS.featureStates)
+			c.supervisorFSS.featureStatesLock.Unlock()
 		}
 	}
-	// Set up kubernetes configmap listener for CSI namespace.
-	err = k8sOrchestratorInstance.informerManager.AddConfigMapListener(
-		ctx,
-		k8sClient,
-		configMapNamespaceToListen,
-		// Add.
-		func(obj interface{}) {
-			configMapAdded(obj)
-		},
-		// Update.
-		func(oldObj interface{}, newObj interface{}) {
-			configMapUpdated(oldObj, newObj)
-		},
-		// Delete.
-		func(obj interface{}) {
-			configMapDeleted(obj)
-		})
-	if err != nil {
-		return logger.LogNewErrorf(log, "failed to listen on configmaps in namespace %q. Error: %v",
-			configMapNamespaceToListen, err)
+	configMapNamespacesToListen := []string{configMapNamespaceToListen}
+	// The controller in the guest cluster nodes doesn't depend on the
+	// supervisor FSS updates, so it doesn't need to listen on its namespace.
+	if controllerClusterFlavor == cnstypes.CnsClusterFlavorGuest && c.serviceMode != "node" &&
+		c.supervisorFSS.configMapNamespace != "" && c.supervisorFSS.configMapNamespace != configMapNamespaceToListen {
+		log.Infof("Supervisor FSS configmap namespace %q differs from internal FSS configmap namespace %q. "+
+			"Listening on both namespaces.", c.supervisorFSS.configMapNamespace, configMapNamespaceToListen)
+		configMapNamespacesToListen = append(configMapNamespacesToListen, c.supervisorFSS.configMapNamespace)
+	}
+	for _, namespace := range configMapNamespacesToListen {
+		// Set up kubernetes configmap listener for the FSS namespace.
+		err = c.informerManager.AddConfigMapListener(
+			ctx,
+			k8sClient,
+			namespace,
+			// Add.
+			func(obj interface{}) {
+				c.configMapAdded(obj)
+			},
+			// Update.
+			func(oldObj interface{}, newObj interface{}) {
+				c.configMapUpdated(oldObj, newObj)
+			},
+			// Delete.
+			func(obj interface{}) {
+				c.configMapDeleted(obj)
+			})
+		if err != nil {
+			return logger.LogNewErrorf(log, "failed to listen on configmaps in namespace %q. Error: %v",
+				namespace, err)
+		}
 	}
 	return nil
 }
 
-func setSvFssCRAvailability(exists bool) {
-	svFssCRMutex.Lock()
-	defer svFssCRMutex.Unlock()
-	doesSvFssCRExist = exists
+func (c *K8sOrchestrator) setSvFssCRAvailability(exists bool) {
+	c.svFssCRMutex.Lock()
+	defer c.svFssCRMutex.Unlock()
+	c.doesSvFssCRExist = exists
 }
 
-func getSvFssCRAvailability() bool {
-	svFssCRMutex.RLock()
-	defer svFssCRMutex.RUnlock()
-	return doesSvFssCRExist
+func (c *K8sOrchestrator) getSvFssCRAvailability() bool {
+	c.svFssCRMutex.RLock()
+	defer c.svFssCRMutex.RUnlock()
+	return c.doesSvFssCRExist
 }
 
 // getSVFssCR retrieves the cnscsisvfeaturestate CR from the supervisor
@@ -657,46 +747,48 @@ func getSVFssCR(ctx context.Context, restClientConfig *restclient.Config) (
 
 // configMapAdded adds feature state switch values from configmap that has been
 // created on K8s cluster.
-func configMapAdded(obj interface{}) {
-	_, log := logger.GetNewContextWithLogger()
+func (c *K8sOrchestrator) configMapAdded(obj interface{}) {
+	ctx, log := logger.GetNewContextWithLogger()
 	fssConfigMap, ok := obj.(*v1.ConfigMap)
 	if fssConfigMap == nil || !ok {
 		log.Warnf("configMapAdded: unrecognized object %+v", obj)
 		return
 	}
 
-	if fssConfigMap.Name == k8sOrchestratorInstance.supervisorFSS.configMapName &&
-		fssConfigMap.Namespace == k8sOrchestratorInstance.supervisorFSS.configMapNamespace {
-		if serviceMode == "node" {
+	if fssConfigMap.Name == c.supervisorFSS.configMapName &&
+		fssConfigMap.Namespace == c.supervisorFSS.configMapNamespace {
+		if c.serviceMode == "node" {
 			log.Debug("configMapAdded: Ignoring supervisor FSS configmap add event in the nodes")
 			return
 		}
-		if getSvFssCRAvailability() {
+		if c.getSvFssCRAvailability() {
 			log.Debugf("configMapAdded: Ignoring supervisor FSS configmap add event as %q CR is present",
 				featurestates.CRDSingular)
 			return
 		}
+		_ = c.validateFSSConfigMap(ctx, fssConfigMap, false)
 		// Update supervisor FSS.
-		k8sOrchestratorInstance.supervisorFSS.featureStatesLock.Lock()
-		k8sOrchestratorInstance.supervisorFSS.featureStates = fssConfigMap.Data
+		c.supervisorFSS.featureStatesLock.Lock()
+		c.supervisorFSS.featureStates = fssConfigMap.Data
 		log.Infof("configMapAdded: Supervisor feature state values from %q stored successfully: %v",
-			fssConfigMap.Name, k8sOrchestratorInstance.supervisorFSS.featureStates)
-		k8sOrchestratorInstance.supervisorFSS.featureStatesLock.Unlock()
-	} else if fssConfigMap.Name == k8sOrchestratorInstance.internalFSS.configMapName &&
-		fssConfigMap.Namespace == k8sOrchestratorInstance.internalFSS.configMapNamespace {
+			fssConfigMap.Name, c.supervisorFSS.featureStates)
+		c.supervisorFSS.featureStatesLock.Unlock()
+	} else if fssConfigMap.Name == c.internalFSS.configMapName &&
+		fssConfigMap.Namespace == c.internalFSS.configMapNamespace {
+		_ = c.validateFSSConfigMap(ctx, fssConfigMap, false)
 		// Update internal FSS.
-		k8sOrchestratorInstance.internalFSS.featureStatesLock.Lock()
-		k8sOrchestratorInstance.internalFSS.featureStates = fssConfigMap.Data
+		c.internalFSS.featureStatesLock.Lock()
+		c.internalFSS.featureStates = fssConfigMap.Data
 		log.Infof("configMapAdded: Internal feature state values from %q stored successfully: %v",
-			fssConfigMap.Name, k8sOrchestratorInstance.internalFSS.featureStates)
-		k8sOrchestratorInstance.internalFSS.featureStatesLock.Unlock()
+			fssConfigMap.Name, c.internalFSS.featureStates)
+		c.internalFSS.featureStatesLock.Unlock()
 	}
 }
 
 // configMapUpdated updates feature state switch values from configmap that
 // has been created on K8s cluster.
-func configMapUpdated(oldObj, newObj interface{}) {
-	_, log := logger.GetNewContextWithLogger()
+func (c *K8sOrchestrator) configMapUpdated(oldObj, newObj interface{}) {
+	ctx, log := logger.GetNewContextWithLogger()
 	oldFssConfigMap, ok := oldObj.(*v1.ConfigMap)
 	if oldFssConfigMap == nil || !ok {
 		log.Warnf("configMapUpdated: unrecognized old object %+v", oldObj)
@@ -713,40 +805,42 @@ func configMapUpdated(oldObj, newObj interface{}) {
 		return
 	}
 
-	if newFssConfigMap.Name == k8sOrchestratorInstance.supervisorFSS.configMapName &&
-		newFssConfigMap.Namespace == k8sOrchestratorInstance.supervisorFSS.configMapNamespace {
+	if newFssConfigMap.Name == c.supervisorFSS.configMapName &&
+		newFssConfigMap.Namespace == c.supervisorFSS.configMapNamespace {
 		// The controller in nodes is not dependent on the supervisor FSS updates.
-		if serviceMode == "node" {
+		if c.serviceMode == "node" {
 			log.Debug("configMapUpdated: Ignoring supervisor FSS configmap update event in the nodes")
 			return
 		}
 		// Ignore configmap updates if the cnscsisvfeaturestate CR is present in
 		// supervisor namespace.
-		if getSvFssCRAvailability() {
+		if c.getSvFssCRAvailability() {
 			log.Debugf("configMapUpdated: Ignoring supervisor FSS configmap update event as %q CR is present",
 				featurestates.CRDSingular)
 			return
 		}
+		_ = c.validateFSSConfigMap(ctx, newFssConfigMap, false)
 		// Update supervisor FSS.
-		k8sOrchestratorInstance.supervisorFSS.featureStatesLock.Lock()
-		k8sOrchestratorInstance.supervisorFSS.featureStates = newFssConfigMap.Data
+		c.supervisorFSS.featureStatesLock.Lock()
+		c.supervisorFSS.featureStates = newFssConfigMap.Data
 		log.Warnf("configMapUpdated: Supervisor feature state values from %q stored successfully: %v",
-			newFssConfigMap.Name, k8sOrchestratorInstance.supervisorFSS.featureStates)
-		k8sOrchestratorInstance.supervisorFSS.featureStatesLock.Unlock()
-	} else if newFssConfigMap.Name == k8sOrchestratorInstance.internalFSS.configMapName &&
-		newFssConfigMap.Namespace == k8sOrchestratorInstance.internalFSS.configMapNamespace {
+			newFssConfigMap.Name, c.supervisorFSS.featureStates)
+		c.supervisorFSS.featureStatesLock.Unlock()
+	} else if newFssConfigMap.Name == c.internalFSS.configMapName &&
+		newFssConfigMap.Namespace == c.internalFSS.configMapNamespace {
+		_ = c.validateFSSConfigMap(ctx, newFssConfigMap, false)
 		// Update internal FSS.
-		k8sOrchestratorInstance.internalFSS.featureStatesLock.Lock()
-		k8sOrchestratorInstance.internalFSS.featureStates = newFssConfigMap.Data
+		c.internalFSS.featureStatesLock.Lock()
+		c.internalFSS.featureStates = newFssConfigMap.Data
 		log.Warnf("configMapUpdated: Internal feature state values from %q stored successfully: %v",
-			newFssConfigMap.Name, k8sOrchestratorInstance.internalFSS.featureStates)
-		k8sOrchestratorInstance.internalFSS.featureStatesLock.Unlock()
+			newFssConfigMap.Name, c.internalFSS.featureStates)
+		c.internalFSS.featureStatesLock.Unlock()
 	}
 }
 
 // configMapDeleted clears the feature state switch values from the feature
 // states map.
-func configMapDeleted(obj interface{}) {
+func (c *K8sOrchestrator) configMapDeleted(obj interface{}) {
 	_, log := logger.GetNewContextWithLogger()
 	fssConfigMap, ok := obj.(*v1.ConfigMap)
 	if fssConfigMap == nil || !ok {
@@ -754,13 +848,13 @@ func configMapDeleted(obj interface{}) {
 		return
 	}
 	// Check if it is either internal or supervisor FSS configmap.
-	if fssConfigMap.Name == k8sOrchestratorInstance.supervisorFSS.configMapName &&
-		fssConfigMap.Namespace == k8sOrchestratorInstance.supervisorFSS.configMapNamespace {
-		if serviceMode == "node" {
+	if fssConfigMap.Name == c.supervisorFSS.configMapName &&
+		fssConfigMap.Namespace == c.supervisorFSS.configMapNamespace {
+		if c.serviceMode == "node" {
 			log.Debug("configMapDeleted: Ignoring supervisor FSS configmap delete event in the nodes")
 			return
 		}
-		if getSvFssCRAvailability() {
+		if c.getSvFssCRAvailability() {
 			log.Debugf("configMapDeleted: Ignoring supervisor FSS configmap delete event as %q CR is present",
 				featurestates.CRDSingular)
 			return
@@ -768,8 +862,8 @@ func configMapDeleted(obj interface{}) {
 		log.Errorf("configMapDeleted: configMap %q in namespace %q deleted. "+
 			"This is a system resource, kindly restore it.", fssConfigMap.Name, fssConfigMap.Namespace)
 		os.Exit(1)
-	} else if fssConfigMap.Name == k8sOrchestratorInstance.internalFSS.configMapName &&
-		fssConfigMap.Namespace == k8sOrchestratorInstance.internalFSS.configMapNamespace {
+	} else if fssConfigMap.Name == c.internalFSS.configMapName &&
+		fssConfigMap.Namespace == c.internalFSS.configMapNamespace {
 		log.Errorf("configMapDeleted: configMap %q in namespace %q deleted. "+
 			"This is a system resource, kindly restore it.", fssConfigMap.Name, fssConfigMap.Namespace)
 		os.Exit(1)
@@ -778,7 +872,7 @@ func configMapDeleted(obj interface{}) {
 
 // fssCRAdded adds supervisor feature state switch values from the
 // cnscsisvfeaturestate CR.
-func fssCRAdded(obj interface{}) {
+func (c *K8sOrchestrator) fssCRAdded(obj interface{}) {
 	_, log := logger.GetNewContextWithLogger()
 	var svFSSObject featurestatesv1alpha1.CnsCsiSvFeatureStates
 	err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.(*unstructured.Unstructured).Object, &svFSSObject)
@@ -790,19 +884,19 @@ func fssCRAdded(obj interface{}) {
 		log.Warnf("fssCRAdded: Ignoring %s CR object with name %q", featurestates.CRDSingular, svFSSObject.Name)
 		return
 	}
-	setSvFssCRAvailability(true)
-	k8sOrchestratorInstance.supervisorFSS.featureStatesLock.Lock()
+	c.setSvFssCRAvailability(true)
+	c.supervisorFSS.featureStatesLock.Lock()
 	for _, fss := range svFSSObject.Spec.FeatureStates {
-		k8sOrchestratorInstance.supervisorFSS.featureStates[fss.Name] = strconv.FormatBool(fss.Enabled)
+		c.supervisorFSS.featureStates[fss.Name] = strconv.FormatBool(fss.Enabled)
 	}
 	log.Infof("fssCRAdded: New supervisor feature states values stored successfully from %s CR object: %v",
-		featurestates.SVFeatureStateCRName, k8sOrchestratorInstance.supervisorFSS.featureStates)
-	k8sOrchestratorInstance.supervisorFSS.featureStatesLock.Unlock()
+		featurestates.SVFeatureStateCRName, c.supervisorFSS.featureStates)
+	c.supervisorFSS.featureStatesLock.Unlock()
 }
 
 // fssCRUpdated updates supervisor feature state switch values from the
 // cnscsisvfeaturestate CR.
-func fssCRUpdated(oldObj, newObj interface{}) {
+func (c *K8sOrchestrator) fssCRUpdated(oldObj, newObj interface{}) {
 	_, log := logger.GetNewContextWithLogger()
 	var (
 		newSvFSSObject featurestatesv1alpha1.CnsCsiSvFeatureStates
@@ -831,18 +925,18 @@ func fssCRUpdated(oldObj, newObj interface{}) {
 		log.Warnf("fssCRUpdated: Ignoring %s CR object with name %q", featurestates.CRDSingular, newSvFSSObject.Name)
 		return
 	}
-	k8sOrchestratorInstance.supervisorFSS.featureStatesLock.Lock()
+	c.supervisorFSS.featureStatesLock.Lock()
 	for _, fss := range newSvFSSObject.Spec.FeatureStates {
-		k8sOrchestratorInstance.supervisorFSS.featureStates[fss.Name] = strconv.FormatBool(fss.Enabled)
+		c.supervisorFSS.featureStates[fss.Name] = strconv.FormatBool(fss.Enabled)
 	}
 	log.Warnf("fssCRUpdated: New supervisor feature states values stored successfully from %s CR object: %v",
-		featurestates.SVFeatureStateCRName, k8sOrchestratorInstance.supervisorFSS.featureStates)
-	k8sOrchestratorInstance.supervisorFSS.featureStatesLock.Unlock()
+		featurestates.SVFeatureStateCRName, c.supervisorFSS.featureStates)
+	c.supervisorFSS.featureStatesLock.Unlock()
 }
 
 // fssCRDeleted crashes the container if the cnscsisvfeaturestate CR object
 // with name svfeaturestates is deleted.
-func fssCRDeleted(obj interface{}) {
+func (c *K8sOrchestrator) fssCRDeleted(obj interface{}) {
 	_, log := logger.GetNewContextWithLogger()
 	var svFSSObject featurestatesv1alpha1.CnsCsiSvFeatureStates
 	err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.(*unstructured.Unstructured).Object, &svFSSObject)
@@ -854,7 +948,7 @@ func fssCRDeleted(obj interface{}) {
 		log.Warnf("fssCRDeleted: Ignoring %s CR object with name %q", featurestates.CRDSingular, svFSSObject.Name)
 		return
 	}
-	setSvFssCRAvailability(false)
+	c.setSvFssCRAvailability(false)
 	// Logging an error here because cnscsisvfeaturestate CR should not be
 	// deleted.
 	log.Errorf("fssCRDeleted: %s CR object with name %q in namespace %q deleted. "+
@@ -863,49 +957,41 @@ func fssCRDeleted(obj interface{}) {
 	os.Exit(1)
 }
 
-// initVolumeHandleToPvcMap performs all the operations required to initialize
-// the volume id to PVC name map. It also watches for PV update & delete
-// operations, and updates the map accordingly.
-func initVolumeHandleToPvcMap(ctx context.Context, controllerClusterFlavor cnstypes.CnsClusterFlavor) error {
+// initPVIndexers adds the volume ID and PVC indexes on the PV informer and
+// keeps its indexer, so that PVs can be looked up from the informer store
+// without maintaining separate maps. It also maintains the fake attach index
+// from PVC events.
+func (c *K8sOrchestrator) initPVIndexers(ctx context.Context,
+	controllerClusterFlavor cnstypes.CnsClusterFlavor) error {
 	log := logger.GetLogger(ctx)
-	log.Debugf("Initializing volume ID to PVC name map")
-	k8sOrchestratorInstance.volumeIDToPvcMap = &volumeIDToPvcMap{
-		RWMutex: &sync.RWMutex{},
-		items:   make(map[string]string),
-	}
-
-	k8sOrchestratorInstance.volumeIDToNameMap = &volumeIDToNameMap{
-		RWMutex: &sync.RWMutex{},
-		items:   make(map[string]string),
-	}
+	log.Debugf("Initializing PV indexers")
 
 	// Set up kubernetes resource listener to listen events on PersistentVolumes
 	// and PersistentVolumeClaims.
-	if (controllerClusterFlavor == cnstypes.CnsClusterFlavorVanilla && serviceMode != "node") ||
+	if (controllerClusterFlavor == cnstypes.CnsClusterFlavorVanilla && c.serviceMode != "node") ||
 		(controllerClusterFlavor == cnstypes.CnsClusterFlavorWorkload) {
 
-		err := k8sOrchestratorInstance.informerManager.AddPVListener(
-			ctx,
-			func(obj interface{}) { // Add.
-				pvAdded(obj)
-			},
-			func(oldObj interface{}, newObj interface{}) { // Update.
-				pvUpdated(oldObj, newObj)
-			},
-			func(obj interface{}) { // Delete.
-				pvDeleted(obj)
-			})
+		err := c.informerManager.AddPVIndexers(ctx, cache.Indexers{
+			pvVolumeIDIndex: pvVolumeIDIndexFunc,
+			pvClaimIndex:    pvClaimIndexFunc,
+		})
 		if err != nil {
-			return logger.LogNewErrorf(log, "failed to listen on PVs. Error: %v", err)
+			return logger.LogNewErrorf(log, "failed to add indexers on PVs. Error: %v", err)
 		}
+		c.pvIndexer = c.informerManager.GetPVIndexer()
 
-		err = k8sOrchestratorInstance.informerManager.AddPVCListener(
+		c.fakeAttachIndex = newFakeAttachIndex()
+		err = c.informerManager.AddPVCListener(
 			ctx,
 			func(obj interface{}) { // Add.
-				pvcAdded(obj)
+				c.pvcAdded(obj)
+			},
+			func(oldObj, newObj interface{}) { // Update.
+				c.pvcUpdated(oldObj, newObj)
+			},
+			func(obj interface{}) { // Delete.
+				c.pvcDeleted(obj)
 			},
-			nil, // Update.
-			nil, // Delete.
 		)
 		if err != nil {
 			return logger.LogNewErrorf(log, "failed to listen on PVCs. Error: %v", err)
@@ -914,264 +1000,135 @@ func initVolumeHandleToPvcMap(ctx context.Context, controllerClusterFlavor cnsty
 	return nil
 }
 
-// Since informerManager's sharedInformerFactory is started with no resync
-// period, it never syncs the existing cluster objects to its Store when
-// it's started. pvcAdded provides no additional handling but it ensures that
-// existing PVCs in the cluster gets added to sharedInformerFactory's Store
-// before it's started. Then using informerManager's PVCLister should find
-// the existing PVCs as well.
-func pvcAdded(obj interface{}) {}
-
-// pvAdded adds a volume to the volumeIDToPvcMap if it's already in Bound phase.
-// This ensures that all existing PVs in the cluster are added to the map, even
-// across container restarts.
-func pvAdded(obj interface{}) {
-	_, log := logger.GetNewContextWithLogger()
+// pvVolumeIDIndexFunc indexes the PVs provisioned by the driver which are or
+// were bound by their volume handle, and VCP-CSI migrated PVs by their VMDK
+// path. Since cns query returns all the volumes including the migrated ones,
+// the index is a union of both. PVs stay indexed in every phase until they
+// are deleted, so that DeleteVolume can still look up the Released PV of a
+// volume.
+func pvVolumeIDIndexFunc(obj interface{}) ([]string, error) {
 	pv, ok := obj.(*v1.PersistentVolume)
-	if pv == nil || !ok {
-		log.Warnf("pvAdded: unrecognized object %+v", obj)
-		return
+	if !ok {
+		return nil, nil
 	}
-
-	if pv.Spec.CSI != nil && pv.Spec.CSI.Driver == csitypes.Name &&
-		pv.Spec.ClaimRef != nil && pv.Status.Phase == v1.VolumeBound {
-		if !isFileVolume(pv) { // We should not be caching file volumes to the map.
-
-			// Add volume handle to PVC mapping.
-			objKey := pv.Spec.CSI.VolumeHandle
-			objVal := pv.Spec.ClaimRef.Namespace + "/" + pv.Spec.ClaimRef.Name
-
-			k8sOrchestratorInstance.volumeIDToPvcMap.add(objKey, objVal)
-			log.Debugf("pvAdded: Added '%s -> %s' pair to volumeIDToPvcMap", objKey, objVal)
-		}
-		k8sOrchestratorInstance.volumeIDToNameMap.add(pv.Spec.CSI.VolumeHandle, pv.Name)
-		log.Debugf("pvAdded: Added '%s -> %s' pair to volumeIDToNameMap", pv.Spec.CSI.VolumeHandle, pv.Name)
-	}
-	// Add VCP-CSI migrated volumes to the volumeIDToNameMap map.
-	// Since cns query will return all the volumes including the migrated ones, the map would need to be a
-	// union of migrated VCP-CSI volumes and CSI volumes, as well.
-	if pv.Spec.VsphereVolume != nil &&
-		k8sOrchestratorInstance.IsFSSEnabled(context.Background(), common.CSIMigration) &&
-		isValidMigratedvSphereVolume(context.Background(), pv.ObjectMeta) {
-		if pv.Status.Phase == v1.VolumeBound {
-			k8sOrchestratorInstance.volumeIDToNameMap.add(pv.Spec.VsphereVolume.VolumePath, pv.Name)
-			log.Debugf("Migrated pvAdded: Added '%s -> %s' pair to volumeIDToNameMap", pv.Spec.VsphereVolume.VolumePath, pv.Name)
-		}
+	if pv.Spec.CSI != nil && pv.Spec.CSI.Driver == csitypes.DriverName() && pv.Spec.ClaimRef != nil {
+		return []string{pv.Spec.CSI.VolumeHandle}, nil
+	}
+	if pv.Spec.VsphereVolume != nil && isValidMigratedvSphereVolume(context.Background(), pv.ObjectMeta) {
+		return []string{pv.Spec.VsphereVolume.VolumePath}, nil
 	}
+	return nil, nil
 }
 
-// pvUpdated updates the volumeIDToPvcMap when a PV goes to Bound phase.
-func pvUpdated(oldObj, newObj interface{}) {
-	_, log := logger.GetNewContextWithLogger()
-	// Get old and new PV objects.
-	oldPv, ok := oldObj.(*v1.PersistentVolume)
-	if oldPv == nil || !ok {
-		log.Warnf("PVUpdated: unrecognized old object %+v", oldObj)
-		return
+// pvClaimIndexFunc indexes bound block PVs provisioned by the driver by the
+// namespaced name of the PVC they are bound to. File volumes are not indexed.
+func pvClaimIndexFunc(obj interface{}) ([]string, error) {
+	pv, ok := obj.(*v1.PersistentVolume)
+	if !ok || pv.Status.Phase != v1.VolumeBound {
+		return nil, nil
 	}
-
-	newPv, ok := newObj.(*v1.PersistentVolume)
-	if newPv == nil || !ok {
-		log.Warnf("PVUpdated: unrecognized new object %+v", newObj)
-		return
+	if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != csitypes.DriverName() || pv.Spec.ClaimRef == nil ||
+		isFileVolume(pv) {
+		return nil, nil
 	}
+	return []string{pv.Spec.ClaimRef.Namespace + "/" + pv.Spec.ClaimRef.Name}, nil
+}
 
-	// PV goes into Bound phase.
-	if oldPv.Status.Phase != v1.VolumeBound && newPv.Status.Phase == v1.VolumeBound {
-		if newPv.Spec.CSI != nil && newPv.Spec.CSI.Driver == csitypes.Name &&
-			newPv.Spec.ClaimRef != nil {
-			if !isFileVolume(newPv) {
-
-				log.Debugf("pvUpdated: PV %s went to Bound phase", newPv.Name)
-				// Add volume handle to PVC mapping.
-				objKey := newPv.Spec.CSI.VolumeHandle
-				objVal := newPv.Spec.ClaimRef.Namespace + "/" + newPv.Spec.ClaimRef.Name
-
-				k8sOrchestratorInstance.volumeIDToPvcMap.add(objKey, objVal)
-				log.Debugf("pvUpdated: Added '%s -> %s' pair to volumeIDToPvcMap", objKey, objVal)
-			}
-			k8sOrchestratorInstance.volumeIDToNameMap.add(newPv.Spec.CSI.VolumeHandle, newPv.Name)
-			log.Debugf("pvUpdated: Added '%s -> %s' pair to volumeIDToNameMap", newPv.Spec.CSI.VolumeHandle, newPv.Name)
-		}
+// getPVByVolumeID returns the PV indexed under the given volume ID.
+// VCP-CSI migrated PVs are returned only if CSIMigration FSS is enabled.
+func (c *K8sOrchestrator) getPVByVolumeID(ctx context.Context, volumeID string) *v1.PersistentVolume {
+	log := logger.GetLogger(ctx)
+	if c.pvIndexer == nil {
+		return nil
 	}
-
-	// Update VCP-CSI migrated volumes to the volumeIDToNameMap map.
-	// Since cns query will return all the volumes including the migrated ones, the map would need to be a
-	// union of migrated VCP-CSI volumes and CSI volumes, as well.
-	if newPv.Spec.VsphereVolume != nil &&
-		k8sOrchestratorInstance.IsFSSEnabled(context.Background(), common.CSIMigration) &&
-		isValidMigratedvSphereVolume(context.Background(), newPv.ObjectMeta) {
-		if oldPv.Status.Phase != v1.VolumeBound && newPv.Status.Phase == v1.VolumeBound {
-			k8sOrchestratorInstance.volumeIDToNameMap.add(newPv.Spec.VsphereVolume.VolumePath, newPv.Name)
-			log.Debugf("Migrated pvUpdated: Added '%s -> %s' pair to volumeIDToNameMap",
-				newPv.Spec.VsphereVolume.VolumePath, newPv.Name)
+	objs, err := c.pvIndexer.ByIndex(pvVolumeIDIndex, volumeID)
+	if err != nil {
+		log.Errorf("failed to look up PV for volume ID %q. Error: %v", volumeID, err)
+		return nil
+	}
+	for _, obj := range objs {
+		pv, ok := obj.(*v1.PersistentVolume)
+		if !ok {
+			continue
+		}
+		if pv.Spec.VsphereVolume != nil && !c.IsFSSEnabled(ctx, common.CSIMigration) {
+			continue
 		}
+		return pv
 	}
+	return nil
 }
 
-// pvDeleted deletes an entry from volumeIDToPvcMap when a PV gets deleted.
-func pvDeleted(obj interface{}) {
-	_, log := logger.GetNewContextWithLogger()
-	pv, ok := obj.(*v1.PersistentVolume)
-	if pv == nil || !ok {
-		log.Warnf("PVDeleted: unrecognized object %+v", obj)
-		return
+// getPVCNameByVolumeID returns the namespace and name of the PVC bound to the
+// block volume with the given volume ID.
+func (c *K8sOrchestrator) getPVCNameByVolumeID(ctx context.Context, volumeID string) (string, string, bool) {
+	pv := c.getPVByVolumeID(ctx, volumeID)
+	if pv == nil || pv.Spec.CSI == nil || isFileVolume(pv) {
+		return "", "", false
 	}
-	log.Debugf("PV: %s deleted. Removing entry from volumeIDToPvcMap", pv.Name)
-
-	if pv.Spec.CSI != nil && pv.Spec.CSI.Driver == csitypes.Name {
-		k8sOrchestratorInstance.volumeIDToPvcMap.remove(pv.Spec.CSI.VolumeHandle)
-		log.Debugf("k8sorchestrator: Deleted key %s from volumeIDToPvcMap", pv.Spec.CSI.VolumeHandle)
-		k8sOrchestratorInstance.volumeIDToNameMap.remove(pv.Spec.CSI.VolumeHandle)
-		log.Debugf("k8sorchestrator: Deleted key %s from volumeIDToNameMap", pv.Spec.CSI.VolumeHandle)
-
-	}
-	if pv.Spec.VsphereVolume != nil && k8sOrchestratorInstance.IsFSSEnabled(context.Background(), common.CSIMigration) {
-		k8sOrchestratorInstance.volumeIDToNameMap.remove(pv.Spec.VsphereVolume.VolumePath)
-		log.Debugf("k8sorchestrator migrated volume: Deleted key %s from volumeIDToNameMap",
-			pv.Spec.VsphereVolume.VolumePath)
-	}
-
+	return pv.Spec.ClaimRef.Namespace, pv.Spec.ClaimRef.Name, true
 }
 
-// GetAllK8sVolumes returns list of volumes in a bound state
+// GetAllK8sVolumes returns list of volumes in a bound state, or released and
+// not deleted yet
 // list Includes Migrated vSphere Volumes VMDK Paths for in-tree vSphere PVs and Volume IDs for CSI PVs
 func (c *K8sOrchestrator) GetAllK8sVolumes() []string {
+	ctx := context.Background()
 	volumeIDs := make([]string, 0)
-	for volumeID := range c.volumeIDToNameMap.items {
-		volumeIDs = append(volumeIDs, volumeID)
+	if c.pvIndexer == nil {
+		return volumeIDs
+	}
+	for _, volumeID := range c.pvIndexer.ListIndexFuncValues(pvVolumeIDIndex) {
+		if c.getPVByVolumeID(ctx, volumeID) != nil {
+			volumeIDs = append(volumeIDs, volumeID)
+		}
 	}
 	return volumeIDs
 }
 
-// IsFSSEnabled utilises the cluster flavor to check their corresponding FSS
-// maps and returns if the feature state switch is enabled for the given feature
-// indicated by featureName.
+// IsFSSEnabled evaluates the capability sources of the cluster flavor, in
+// their order of precedence, and returns if the feature state switch is
+// enabled for the given feature indicated by featureName.
 func (c *K8sOrchestrator) IsFSSEnabled(ctx context.Context, featureName string) bool {
-	log := logger.GetLogger(ctx)
-	var (
-		internalFeatureState   bool
-		supervisorFeatureState bool
-		err                    error
-	)
-	if c.clusterFlavor == cnstypes.CnsClusterFlavorVanilla {
-		// first check hard coded FSS map. these are GA'ed features
-		// we don't need a lock for this one as this is map is read only after init
-		if _, isReleased := c.releasedVanillaFSS[featureName]; isReleased {
-			return true
-		}
+	state, outcome := c.evaluateFSS(ctx, featureName)
+	prometheus.FSSEvaluationsCounter.WithLabelValues(featureName, outcome).Inc()
+	return state
+}
 
-		c.internalFSS.featureStatesLock.RLock()
-		// for testing, we still need to provide a way to toggle unreleased fss.
-		// so we can look in the live configmap for these
-		if state, ok := c.internalFSS.featureStates[featureName]; ok {
-			c.internalFSS.featureStatesLock.RUnlock()
-			internalFeatureState, err = strconv.ParseBool(state)
-			if err != nil {
-				log.Errorf("Error while converting %v feature state value: %v to boolean. "+
-					"Setting the feature state to false", featureName, internalFeatureState)
-				return false
-			}
-			return internalFeatureState
-		}
-		c.internalFSS.featureStatesLock.RUnlock()
-		log.Infof("Could not find the %s feature state in ConfigMap %s. "+
-			"Setting the feature state to false", featureName, c.internalFSS.configMapName)
-		return false
-	} else if c.clusterFlavor == cnstypes.CnsClusterFlavorWorkload {
-		// Check if it is WCP defined feature state.
-		if _, exists := common.WCPFeatureStates[featureName]; exists {
-			log.Infof("Feature %q is a WCP defined feature state. Reading the %q configmap in %q namespace.",
-				featureName, common.WCPCapabilityConfigMapName, common.KubeSystemNamespace)
-			// Check the `wcp-cluster-capabilities` configmap in supervisor for the FSS value.
-			if wcpCapabilityFssMap == nil {
-				wcpCapabilityConfigMap, err := c.k8sClient.CoreV1().ConfigMaps(common.KubeSystemNamespace).Get(ctx,
-					common.WCPCapabilityConfigMapName, metav1.GetOptions{})
-				if err != nil {
-					log.Errorf("failed to fetch WCP FSS configmap %q/%q. Setting the feature state "+
-						"to false. Error: %+v", common.KubeSystemNamespace, common.WCPCapabilityConfigMapName, err)
-					return false
-				}
-				wcpCapabilityFssMap = wcpCapabilityConfigMap.Data
-				log.Infof("WCP cluster capabilities map - %+v", wcpCapabilityFssMap)
-			}
-			if fssVal, exists := wcpCapabilityFssMap[featureName]; exists {
-				supervisorFeatureState, err = strconv.ParseBool(fssVal)
-				if err != nil {
-					log.Errorf("Error while converting %q feature state with value: %q in "+
-						"%q/%q configmap to boolean. Setting the feature state to false. Error: %+v", featureName,
-						fssVal, common.KubeSystemNamespace, common.WCPCapabilityConfigMapName, err)
-					return false
-				}
-				log.Debugf("Supervisor feature state %q in WCP cluster capabilities is set to %t", featureName,
-					supervisorFeatureState)
-				return supervisorFeatureState
-			}
-		}
+// evaluateFSS returns the state of the feature state switch of the given
+// feature, along with the outcome of its evaluation, which is one of the
+// prometheus.PrometheusFSS* constants.
+func (c *K8sOrchestrator) evaluateFSS(ctx context.Context, featureName string) (bool, string) {
+	chain := c.fssChain()
+	if chain == nil {
+		logger.GetLogger(ctx).Debugf("cluster flavor %q not recognised. Defaulting to false", c.clusterFlavor)
+		return false, prometheus.PrometheusFSSMissing
+	}
+	return chain.evaluate(ctx, featureName, c.logFSSConflict(ctx, featureName))
+}
 
-		// Check SV FSS map.
-		c.supervisorFSS.featureStatesLock.RLock()
-		if flag, ok := c.supervisorFSS.featureStates[featureName]; ok {
-			c.supervisorFSS.featureStatesLock.RUnlock()
-			supervisorFeatureState, err = strconv.ParseBool(flag)
-			if err != nil {
-				log.Errorf("Error while converting %v feature state value: %v to boolean. "+
-					"Setting the feature state to false", featureName, supervisorFeatureState)
-				return false
-			}
-			return supervisorFeatureState
-		}
-		c.supervisorFSS.featureStatesLock.RUnlock()
-		log.Infof("Could not find the %s feature state in ConfigMap %s. "+
-			"Setting the feature state to false", featureName, c.supervisorFSS.configMapName)
-		return false
-	} else if c.clusterFlavor == cnstypes.CnsClusterFlavorGuest {
-		// Check internal FSS map.
-		c.internalFSS.featureStatesLock.RLock()
-		if flag, ok := c.internalFSS.featureStates[featureName]; ok {
-			c.internalFSS.featureStatesLock.RUnlock()
-			internalFeatureState, err = strconv.ParseBool(flag)
-			if err != nil {
-				log.Errorf("Error while converting %v feature state value: %v to boolean. "+
-					"Setting the feature state to false", featureName, internalFeatureState)
-				return false
-			}
-			if !internalFeatureState {
-				// If FSS set to false, return.
-				log.Infof("%s feature state set to false in %s ConfigMap", featureName, c.internalFSS.configMapName)
-				return internalFeatureState
-			}
-		} else {
-			c.internalFSS.featureStatesLock.RUnlock()
-			log.Infof("Could not find the %s feature state in ConfigMap %s. Setting the feature state to false",
-				featureName, c.internalFSS.configMapName)
-			return false
-		}
-		// Check SV FSS map.
-		c.supervisorFSS.featureStatesLock.RLock()
-		if flag, ok := c.supervisorFSS.featureStates[featureName]; ok {
-			c.supervisorFSS.featureStatesLock.RUnlock()
-			supervisorFeatureState, err = strconv.ParseBool(flag)
-			if err != nil {
-				log.Errorf("Error while converting %v feature state value: %v to boolean. "+
-					"Setting the feature state to false", featureName, supervisorFeatureState)
-				return false
-			}
-			if !supervisorFeatureState {
-				// If FSS set to false, return.
-				log.Infof("%s feature state is set to false in %s ConfigMap", featureName, c.supervisorFSS.configMapName)
-				return supervisorFeatureState
-			}
-		} else {
-			c.supervisorFSS.featureStatesLock.RUnlock()
-			log.Infof("Could not find the %s feature state in ConfigMap %s. Setting the feature state to false",
-				featureName, c.supervisorFSS.configMapName)
-			return false
-		}
-		return true
+// fssOutcome returns the evaluation outcome of a feature state switch found
+// with the given state.
+func fssOutcome(state bool) string {
+	if state {
+		return prometheus.PrometheusFSSEnabled
 	}
-	log.Debugf("cluster flavor %q not recognised. Defaulting to false", c.clusterFlavor)
-	return false
+	return prometheus.PrometheusFSSDisabled
+}
+
+// GetFeatureStates returns the effective state of the feature state switches
+// known to the orchestrator for its cluster flavor.
+func (c *K8sOrchestrator) GetFeatureStates(ctx context.Context) map[string]bool {
+	chain := c.fssChain()
+	if chain == nil {
+		return map[string]bool{}
+	}
+	featureNames := chain.featureNames()
+	featureStates := make(map[string]bool, len(featureNames))
+	for name := range featureNames {
+		featureStates[name] = c.IsFSSEnabled(ctx, name)
+	}
+	return featureStates
 }
 
 // IsFakeAttachAllowed checks if the volume is eligible to be fake attached
@@ -1180,14 +1137,14 @@ func (c *K8sOrchestrator) IsFakeAttachAllowed(ctx context.Context, volumeID stri
 	volumeManager cnsvolume.Manager) (bool, error) {
 	log := logger.GetLogger(ctx)
 	// Check pvc annotations.
-	pvcAnn, err := c.getPVCAnnotations(ctx, volumeID)
-	if err != nil {
-		log.Errorf("IsFakeAttachAllowed: failed to get pvc annotations for volume ID %s "+
+	volumeName, found := c.getFakeAttachVolumeName(ctx, volumeID)
+	if !found {
+		log.Errorf("IsFakeAttachAllowed: failed to find pvc for volume ID %s "+
 			"while checking eligibility for fake attach", volumeID)
-		return false, err
+		return false, common.ErrNotFound
 	}
 
-	if val, found := pvcAnn[common.AnnIgnoreInaccessiblePV]; found && val == "yes" {
+	if c.fakeAttachIndex.ignoresInaccessible(volumeName) {
 		log.Debugf("Found %s annotation on pvc set to yes for volume: %s. Checking volume health on CNS volume.",
 			common.AnnIgnoreInaccessiblePV, volumeID)
 		// Check if volume is inaccessible.
@@ -1239,6 +1196,9 @@ func (c *K8sOrchestrator) MarkFakeAttached(ctx context.Context, volumeID string)
 		log.Errorf("failed to mark fake attach annotation on the pvc for volume %s. Error:%+v", volumeID, err)
 		return err
 	}
+	if volumeName, found := c.getFakeAttachVolumeName(ctx, volumeID); found {
+		c.fakeAttachIndex.setFakeAttached(volumeName, true)
+	}
 
 	return nil
 }
@@ -1248,18 +1208,12 @@ func (c *K8sOrchestrator) MarkFakeAttached(ctx context.Context, volumeID string)
 func (c *K8sOrchestrator) ClearFakeAttached(ctx context.Context, volumeID string) error {
 	log := logger.GetLogger(ctx)
 	// Check pvc annotations.
-	pvcAnn, err := c.getPVCAnnotations(ctx, volumeID)
-	if err != nil {
-		if err.Error() == common.ErrNotFound.Error() {
-			// PVC not found, which means PVC could have been deleted. No need to proceed.
-			return nil
-		}
-		log.Errorf("ClearFakeAttached: failed to get pvc annotations for volume ID %s "+
-			"while checking if it was fake attached", volumeID)
-		return err
+	volumeName, found := c.getFakeAttachVolumeName(ctx, volumeID)
+	if !found {
+		// PVC not found, which means PVC could have been deleted. No need to proceed.
+		return nil
 	}
-	val, found := pvcAnn[common.AnnFakeAttached]
-	if found && val == "yes" {
+	if c.fakeAttachIndex.isFakeAttached(volumeName) {
 		log.Debugf("Volume: %s was fake attached", volumeID)
 		// Clear the fake attach annotation.
 		annotations := make(map[string]string)
@@ -1272,54 +1226,72 @@ func (c *K8sOrchestrator) ClearFakeAttached(ctx context.Context, volumeID string
 			log.Errorf("failed to clear fake attach annotation on the pvc for volume %s. Error:%+v", volumeID, err)
 			return err
 		}
+		c.fakeAttachIndex.setFakeAttached(volumeName, false)
 	}
 	return nil
 }
 
 // initVolumeNameToNodesMap performs all the operations required to initialize
 // the PVName to node names map. It also watches for volume attachment add,
-// update & delete operations, and updates the map accordingly.
-func initVolumeNameToNodesMap(ctx context.Context, controllerClusterFlavor cnstypes.CnsClusterFlavor) error {
+// update & delete operations, and updates the map accordingly. Volume
+// attachments are indexed by PV and node name, so that they can be found
+// regardless of their name.
+func (c *K8sOrchestrator) initVolumeNameToNodesMap(ctx context.Context,
+	controllerClusterFlavor cnstypes.CnsClusterFlavor) error {
 	log := logger.GetLogger(ctx)
 	log.Debugf("Initializing volumeName/pvName to node name map")
-	k8sOrchestratorInstance.volumeNameToNodesMap = &volumeNameToNodesMap{
-		RWMutex: &sync.RWMutex{},
-		items:   make(map[string][]string),
-	}
+	c.volumeNameToNodesMap = newVolumeNameToNodesMap(getOrchestratorCacheMaxEntries(ctx))
 
 	// Set up kubernetes resource listener to listen events on volume attachments
-	if (controllerClusterFlavor == cnstypes.CnsClusterFlavorVanilla && serviceMode != "node") ||
+	if (controllerClusterFlavor == cnstypes.CnsClusterFlavorVanilla && c.serviceMode != "node") ||
 		(controllerClusterFlavor == cnstypes.CnsClusterFlavorWorkload) {
 
-		err := k8sOrchestratorInstance.informerManager.AddVolumeAttachmentListener(
+		err := c.informerManager.AddVolumeAttachmentListener(
 			ctx,
 			func(obj interface{}) { // Add.
-				volumeAttachmentAdded(obj)
+				c.volumeAttachmentAdded(obj)
 			},
 			func(oldObj interface{}, newObj interface{}) { // Update
-				volumeAttachmentUpdated(oldObj, newObj)
+				c.volumeAttachmentUpdated(oldObj, newObj)
 			},
 			func(obj interface{}) { // Delete.
-				volumeAttachmentDeleted(obj)
+				c.volumeAttachmentDeleted(obj)
 			})
 		if err != nil {
 			return logger.LogNewErrorf(log, "failed to listen on volume attachment instances. Error: %v", err)
 		}
+		err = c.informerManager.AddVolumeAttachmentIndexers(ctx, cache.Indexers{
+			vaPVNodeIndex: vaPVNodeIndexFunc,
+		})
+		if err != nil {
+			return logger.LogNewErrorf(log, "failed to add indexers on volume attachments. Error: %v", err)
+		}
+		c.vaIndexer = c.informerManager.GetVolumeAttachmentIndexer()
 	}
 	return nil
 }
 
+// vaPVNodeIndexFunc indexes the volume attachments of the driver by the name of
+// their PV and node.
+func vaPVNodeIndexFunc(obj interface{}) ([]string, error) {
+	va, ok := obj.(*storagev1.VolumeAttachment)
+	if !ok || va.Spec.Attacher != csitypes.DriverName() || va.Spec.Source.PersistentVolumeName == nil {
+		return nil, nil
+	}
+	return []string{*va.Spec.Source.PersistentVolumeName + "/" + va.Spec.NodeName}, nil
+}
+
 // volumeAttachmentAdded adds a new entry or updates an existing entry
 // in the volumeIDToNodeNames map if the volume attachment status is
 // true
-func volumeAttachmentAdded(obj interface{}) {
+func (c *K8sOrchestrator) volumeAttachmentAdded(obj interface{}) {
 	log := logger.GetLogger(context.Background())
 	volAttach, ok := obj.(*storagev1.VolumeAttachment)
 	if volAttach == nil || !ok {
 		log.Warnf("volumeAttachmentAdded: unrecognized object %+v", obj)
 		return
 	}
-	if volAttach.Spec.Attacher != csitypes.Name {
+	if volAttach.Spec.Attacher != csitypes.DriverName() {
 		return
 	}
 	log.Debugf("volumeAttachmentAdded: volume=%v", volAttach)
@@ -1330,7 +1302,10 @@ func volumeAttachmentAdded(obj interface{}) {
 		}
 		volumeName := *volAttach.Spec.Source.PersistentVolumeName
 		nodeName := volAttach.Spec.NodeName
-		nodes := k8sOrchestratorInstance.volumeNameToNodesMap.get(volumeName)
+		nodes, ok := c.volumeNameToNodesMap.getForUpdate(volumeName)
+		if !ok {
+			return
+		}
 		found := false
 		for _, node := range nodes {
 			if node == nodeName {
@@ -1341,14 +1316,14 @@ func volumeAttachmentAdded(obj interface{}) {
 		if !found {
 			nodes = append(nodes, nodeName)
 			log.Debugf("volumeAttachmentAdded: Adding nodeName %s to volumeID %s:%v map", nodeName, volumeName, nodes)
-			k8sOrchestratorInstance.volumeNameToNodesMap.add(volumeName, nodes)
+			c.volumeNameToNodesMap.add(volumeName, nodes)
 		}
 	}
 }
 
 // volumeAttachmentUpdated updates an existing entry in the volumeIDToNodeNames map
 // if the volume attachment status is true
-func volumeAttachmentUpdated(oldObj, newObj interface{}) {
+func (c *K8sOrchestrator) volumeAttachmentUpdated(oldObj, newObj interface{}) {
 	log := logger.GetLogger(context.Background())
 	oldVolAttach, ok := oldObj.(*storagev1.VolumeAttachment)
 	if oldVolAttach == nil || !ok {
@@ -1362,7 +1337,7 @@ func volumeAttachmentUpdated(oldObj, newObj interface{}) {
 		return
 	}
 
-	if newVolAttach.Spec.Attacher != csitypes.Name {
+	if newVolAttach.Spec.Attacher != csitypes.DriverName() {
 		return
 	}
 
@@ -1371,9 +1346,14 @@ func volumeAttachmentUpdated(oldObj, newObj interface{}) {
 			// return for inline volume
 			return
 		}
+		c.observeVolumeAttachmentLatency(newVolAttach, prometheus.PrometheusAttachVolumeOpType,
+			newVolAttach.CreationTimestamp.Time)
 		volumeName := *newVolAttach.Spec.Source.PersistentVolumeName
 		nodeName := newVolAttach.Spec.NodeName
-		nodes := k8sOrchestratorInstance.volumeNameToNodesMap.get(volumeName)
+		nodes, ok := c.volumeNameToNodesMap.getForUpdate(volumeName)
+		if !ok {
+			return
+		}
 		found := false
 		for _, node := range nodes {
 			if node == nodeName {
@@ -1385,34 +1365,42 @@ func volumeAttachmentUpdated(oldObj, newObj interface{}) {
 			nodes = append(nodes, nodeName)
 			log.Debugf("volumeAttachmentUpdated: Adding nodeName %s to volumeID %s:%v map",
 				nodeName, volumeName, nodes)
-			k8sOrchestratorInstance.volumeNameToNodesMap.add(volumeName, nodes)
+			c.volumeNameToNodesMap.add(volumeName, nodes)
 		}
 	}
 }
 
 // volumeAttachmentDeleted deletes an entry or removes node name form an
 // existing entry in the volumeIDToNodeNames map if the volume attachment
-// status is false
-func volumeAttachmentDeleted(obj interface{}) {
+// status is false, or if the volume attachment was deleted as its node was
+// deleted
+func (c *K8sOrchestrator) volumeAttachmentDeleted(obj interface{}) {
 	log := logger.GetLogger(context.Background())
 	volAttach, ok := obj.(*storagev1.VolumeAttachment)
 	if volAttach == nil || !ok {
 		log.Warnf("volumeAttachmentDeleted: unrecognized object %+v", obj)
 		return
 	}
-	if volAttach.Spec.Attacher != csitypes.Name {
+	if volAttach.Spec.Attacher != csitypes.DriverName() {
 		return
 	}
-	if !volAttach.Status.Attached {
+	if !volAttach.Status.Attached || volAttach.Annotations[common.AnnNodeDeleted] == "yes" {
 		log.Debugf("volumeAttachmentDeleted: volume attachment deleted: volume=%v", volAttach)
 		if volAttach.Spec.Source.PersistentVolumeName == nil {
 			// return for inline volume
 			return
 		}
+		if volAttach.DeletionTimestamp != nil {
+			c.observeVolumeAttachmentLatency(volAttach, prometheus.PrometheusDetachVolumeOpType,
+				volAttach.DeletionTimestamp.Time)
+		}
 		volumeName := *volAttach.Spec.Source.PersistentVolumeName
 
 		nodeName := volAttach.Spec.NodeName
-		nodes := k8sOrchestratorInstance.volumeNameToNodesMap.get(volumeName)
+		nodes, ok := c.volumeNameToNodesMap.getForUpdate(volumeName)
+		if !ok {
+			return
+		}
 		found := false
 		for i, node := range nodes {
 			if node == nodeName {
@@ -1425,51 +1413,89 @@ func volumeAttachmentDeleted(obj interface{}) {
 			log.Debugf("volumeAttachmentDeleted: Deleting nodeName %s to volumeName %s map",
 				nodeName, volumeName)
 			if len(nodes) == 0 {
-				k8sOrchestratorInstance.volumeNameToNodesMap.remove(volumeName)
+				c.volumeNameToNodesMap.remove(volumeName)
 			} else {
-				k8sOrchestratorInstance.volumeNameToNodesMap.add(volumeName, nodes)
+				c.volumeNameToNodesMap.add(volumeName, nodes)
 			}
 		}
 	}
 }
 
+// observeVolumeAttachmentLatency records the time since the given start of an
+// attach or detach for the volume of a VolumeAttachment, labelled with the
+// vCenter recorded by the controller for the volume.
+func (c *K8sOrchestrator) observeVolumeAttachmentLatency(volAttach *storagev1.VolumeAttachment,
+	opType string, start time.Time) {
+	vcHost := common.UnknownVCenter
+	if c.pvIndexer != nil {
+		obj, exists, err := c.pvIndexer.GetByKey(*volAttach.Spec.Source.PersistentVolumeName)
+		if pv, ok := obj.(*v1.PersistentVolume); err == nil && exists && ok && pv.Spec.CSI != nil {
+			vcHost = common.GetVolumeVCenter(pv.Spec.CSI.VolumeHandle,
+				opType == prometheus.PrometheusDetachVolumeOpType)
+		}
+	}
+	prometheus.AttachDetachLatencyHistVec.WithLabelValues(opType, vcHost,
+		prometheus.PrometheusVolumeAttachmentStage).Observe(time.Since(start).Seconds())
+}
+
 // GetNodesForVolumes returns a map containing the volumeID to node names map for the given
 // list of volumeIDs
 func (c *K8sOrchestrator) GetNodesForVolumes(ctx context.Context, volumeIDs []string) map[string][]string {
+	log := logger.GetLogger(ctx)
 	volumeIDToNodeNames := make(map[string][]string)
+	missedVolumeNameToID := make(map[string]string)
 	for _, volumeID := range volumeIDs {
-		volumeName, found := c.volumeIDToNameMap.get(volumeID)
-		if found {
-			volumeIDToNodeNames[volumeID] = c.volumeNameToNodesMap.get(volumeName)
+		if pv := c.getPVByVolumeID(ctx, volumeID); pv != nil {
+			nodes, found := c.volumeNameToNodesMap.get(pv.Name)
+			if !found && c.volumeNameToNodesMap.isBounded() {
+				prometheus.OrchestratorCacheLookupsCounter.WithLabelValues(volumeNameToNodesCacheName, "miss").Inc()
+				missedVolumeNameToID[pv.Name] = volumeID
+				continue
+			}
+			prometheus.OrchestratorCacheLookupsCounter.WithLabelValues(volumeNameToNodesCacheName, "hit").Inc()
+			volumeIDToNodeNames[volumeID] = nodes
 		}
 
 	}
+	if len(missedVolumeNameToID) != 0 {
+		volumeNameToNodes, err := c.getVolumeNameToNodesFromAPIServer(ctx, missedVolumeNameToID)
+		if err != nil {
+			log.Errorf("failed to get the nodes of %d volumes from API server. Error: %v",
+				len(missedVolumeNameToID), err)
+			return volumeIDToNodeNames
+		}
+		for volumeName, volumeID := range missedVolumeNameToID {
+			nodes := volumeNameToNodes[volumeName]
+			c.volumeNameToNodesMap.add(volumeName, nodes)
+			volumeIDToNodeNames[volumeID] = nodes
+		}
+	}
 	return volumeIDToNodeNames
 }
 
 // initNodeIDToNameMap performs all the operations required to initialize
 // the node ID to  name map. It also watches for node add, update & delete
 // operations, and updates the map accordingly.
-func initNodeIDToNameMap(ctx context.Context) error {
+func (c *K8sOrchestrator) initNodeIDToNameMap(ctx context.Context) error {
 	log := logger.GetLogger(ctx)
 
 	log.Debugf("Initializing node ID to node name map")
-	k8sOrchestratorInstance.nodeIDToNameMap = &nodeIDToNameMap{
+	c.nodeIDToNameMap = &nodeIDToNameMap{
 		RWMutex: &sync.RWMutex{},
 		items:   make(map[string]string),
 	}
 
 	// Set up kubernetes resource listener to listen events on Node
-	err := k8sOrchestratorInstance.informerManager.AddNodeListener(
+	err := c.informerManager.AddNodeListener(
 		ctx,
 		func(obj interface{}) { // Add.
-			nodeAdd(obj)
+			c.nodeAdd(obj)
 		},
 		func(oldObj interface{}, newObj interface{}) { // Update.
-			nodeUpdate(oldObj, newObj)
+			c.nodeUpdate(oldObj, newObj)
 		},
 		func(obj interface{}) { // Delete.
-			nodeRemove(obj)
+			c.nodeRemove(obj)
 		})
 	if err != nil {
 		return logger.LogNewErrorf(log, "failed to listen on nodes. Error: %v", err)
@@ -1479,7 +1505,7 @@ func initNodeIDToNameMap(ctx context.Context) error {
 
 // nodeAdd adds an entry into nodeIDToNameMap. The node MoID is retrieved from the
 // node annotation vmware-system-esxi-node-moid
-func nodeAdd(obj interface{}) {
+func (c *K8sOrchestrator) nodeAdd(obj interface{}) {
 	log := logger.GetLogger(context.Background())
 	node, ok := obj.(*v1.Node)
 	if node == nil || !ok {
@@ -1493,12 +1519,12 @@ func nodeAdd(obj interface{}) {
 		log.Debugf("nodeAdd: %s annotation not found on the node %s", common.HostMoidAnnotationKey, node.Name)
 		return
 	}
-	k8sOrchestratorInstance.nodeIDToNameMap.add(nodeMoID, node.Name)
+	c.nodeIDToNameMap.add(nodeMoID, node.Name)
 }
 
 // nodeUpdate updates an entry into nodeIDToNameMap. The node MoID is retrieved from the
 // node annotation vmware-system-esxi-node-moid
-func nodeUpdate(oldObject interface{}, newObject interface{}) {
+func (c *K8sOrchestrator) nodeUpdate(oldObject interface{}, newObject interface{}) {
 	log := logger.GetLogger(context.Background())
 	oldnode, ok := oldObject.(*v1.Node)
 	if oldnode == nil || !ok {
@@ -1518,13 +1544,13 @@ func nodeUpdate(oldObject interface{}, newObject interface{}) {
 	if !oldOk && newOk {
 		// If annotation is not found on the old node but found on the new one, add it to the map.
 		log.Debugf("Adding nodeMoid %s and node name %s to the map.", newNodeMoID, newnode.Name)
-		k8sOrchestratorInstance.nodeIDToNameMap.add(newNodeMoID, newnode.Name)
+		c.nodeIDToNameMap.add(newNodeMoID, newnode.Name)
 	}
 }
 
 // nodeRemove removes an entry from nodeIDToNameMap. The node MoID is retrieved from the
 // node annotation vmware-system-esxi-node-moid
-func nodeRemove(obj interface{}) {
+func (c *K8sOrchestrator) nodeRemove(obj interface{}) {
 	log := logger.GetLogger(context.Background())
 	node, ok := obj.(*v1.Node)
 	if node == nil || !ok {
@@ -1538,7 +1564,7 @@ func nodeRemove(obj interface{}) {
 		log.Debugf("nodeRemove: %s annotation not found on the node %s", common.HostMoidAnnotationKey, node.Name)
 		return
 	}
-	k8sOrchestratorInstance.nodeIDToNameMap.remove(nodeMoID)
+	c.nodeIDToNameMap.remove(nodeMoID)
 }
 
 // GetNodeIDtoNameMap returns a map containing the nodeID to node name
@@ -1546,54 +1572,121 @@ func (c *K8sOrchestrator) GetNodeIDtoNameMap(ctx context.Context) map[string]str
 	return c.nodeIDToNameMap.items
 }
 
+// GetSnapshotVCenter returns the vCenter of the snapshot with the given CSI
+// snapshot ID. The vCenter of scoped snapshot IDs is parsed from the ID,
+// while the vCenter of other snapshots is looked up in the cache.
+func (c *K8sOrchestrator) GetSnapshotVCenter(ctx context.Context, csiSnapshotID string) (string, bool) {
+	_, cnsSnapshotID, vCenterHost, err := common.ParseCSISnapshotIDWithVCenter(csiSnapshotID)
+	if err != nil {
+		return "", false
+	}
+	if vCenterHost != "" {
+		return vCenterHost, true
+	}
+	return c.snapshotIDToVCenterMap.get(cnsSnapshotID)
+}
+
+// SetSnapshotVCenter caches the vCenter of the snapshot with the given CSI
+// snapshot ID.
+func (c *K8sOrchestrator) SetSnapshotVCenter(ctx context.Context, csiSnapshotID string, vCenterHost string) {
+	_, cnsSnapshotID, scopedVCenterHost, err := common.ParseCSISnapshotIDWithVCenter(csiSnapshotID)
+	if err != nil || scopedVCenterHost != "" {
+		return
+	}
+	c.snapshotIDToVCenterMap.add(cnsSnapshotID, vCenterHost)
+}
+
+// ForgetSnapshotVCenter removes the vCenter of the snapshot with the given
+// CSI snapshot ID from the cache.
+func (c *K8sOrchestrator) ForgetSnapshotVCenter(ctx context.Context, csiSnapshotID string) {
+	_, cnsSnapshotID, _, err := common.ParseCSISnapshotIDWithVCenter(csiSnapshotID)
+	if err != nil {
+		return
+	}
+	c.snapshotIDToVCenterMap.remove(cnsSnapshotID)
+}
+
 // GetFakeAttachedVolumes returns a map of volumeIDs to a bool, which is set
 // to true if volumeID key is fake attached else false
 func (c *K8sOrchestrator) GetFakeAttachedVolumes(ctx context.Context, volumeIDs []string) map[string]bool {
-	log := logger.GetLogger(ctx)
 	volumeIDToFakeAttachedMap := make(map[string]bool)
 	for _, volumeID := range volumeIDs {
-		// Check pvc annotations.
-		pvcAnn, err := c.getPVCAnnotations(ctx, volumeID)
-		if err != nil {
-			if err.Error() == common.ErrNotFound.Error() {
-				// PVC not found, which means PVC could have been deleted. No need to proceed.
-				log.Debugf("PVC not found, which means PVC could have been deleted. No need to proceed.")
-				continue
-			}
-			log.Errorf("GetFakeAttachedVolumes: failed to get pvc annotations for volume ID %s "+
-				"while checking if it was fake attached", volumeID)
+		volumeName, found := c.getFakeAttachVolumeName(ctx, volumeID)
+		if !found {
+			// PVC not found, which means PVC could have been deleted. No need to proceed.
 			continue
 		}
-		val, found := pvcAnn[common.AnnFakeAttached]
-		if found && val == "yes" {
-			volumeIDToFakeAttachedMap[volumeID] = true
-		} else {
-			volumeIDToFakeAttachedMap[volumeID] = false
-		}
+		volumeIDToFakeAttachedMap[volumeID] = c.fakeAttachIndex.isFakeAttached(volumeName)
 	}
 	return volumeIDToFakeAttachedMap
 }
 
-// GetVolumeAttachment returns the VA object by using the given volumeId & nodeName
+// GetVolumeAttachment returns the VA object by using the given volumeId & nodeName.
+// VAs not named after the hash of the volume, driver and node, as the
+// external-attacher names them, are looked up by PV and node name in the VA
+// informer index.
 func (c *K8sOrchestrator) GetVolumeAttachment(ctx context.Context, volumeId string, nodeName string) (
 	*storagev1.VolumeAttachment, error) {
 	log := logger.GetLogger(ctx)
-	sha256Res := sha256.Sum256([]byte(fmt.Sprintf("%s%s%s", volumeId, common.VSphereCSIDriverName, nodeName)))
+	sha256Res := sha256.Sum256([]byte(fmt.Sprintf("%s%s%s", volumeId, csitypes.DriverName(), nodeName)))
 	sha256VaName := fmt.Sprintf("csi-%x", sha256Res)
 	volumeAttachment, err := c.k8sClient.StorageV1().VolumeAttachments().Get(ctx, sha256VaName, metav1.GetOptions{})
 	if err != nil {
+		if apierrors.IsNotFound(err) {
+			if volumeAttachment := c.getVolumeAttachmentByPV(ctx, volumeId, nodeName); volumeAttachment != nil {
+				log.Debugf("found volumeattachment %q for volume %q on node %q", volumeAttachment.Name,
+					volumeId, nodeName)
+				return volumeAttachment, nil
+			}
+		}
 		log.Errorf("failed to get the volumeattachment %q from API server Err: %v", sha256VaName, err)
 		return nil, err
 	}
 	return volumeAttachment, nil
 }
 
+// getVolumeAttachmentByPV returns the VA of the PV of the given volume on the
+// given node from the VA informer index, or nil if it isn't found.
+func (c *K8sOrchestrator) getVolumeAttachmentByPV(ctx context.Context, volumeID string,
+	nodeName string) *storagev1.VolumeAttachment {
+	log := logger.GetLogger(ctx)
+	if c.vaIndexer == nil {
+		return nil
+	}
+	pv := c.getPVByVolumeID(ctx, volumeID)
+	if pv == nil {
+		return nil
+	}
+	objs, err := c.vaIndexer.ByIndex(vaPVNodeIndex, pv.Name+"/"+nodeName)
+	if err != nil {
+		log.Errorf("failed to look up volumeattachment of PV %q on node %q. Error: %v", pv.Name, nodeName, err)
+		return nil
+	}
+	for _, obj := range objs {
+		if volumeAttachment, ok := obj.(*storagev1.VolumeAttachment); ok {
+			return volumeAttachment
+		}
+	}
+	return nil
+}
+
 // GetAllVolumes returns list of volumes in a bound state for wcp clusters.
 // This will not return VCP-CSI migrated volumes.
 func (c *K8sOrchestrator) GetAllVolumes() []string {
 	volumeIDs := make([]string, 0)
-	for volumeID := range c.volumeIDToPvcMap.items {
-		volumeIDs = append(volumeIDs, volumeID)
+	if c.pvIndexer == nil {
+		return volumeIDs
+	}
+	for _, pvcName := range c.pvIndexer.ListIndexFuncValues(pvClaimIndex) {
+		objs, err := c.pvIndexer.ByIndex(pvClaimIndex, pvcName)
+		if err != nil {
+			continue
+		}
+		for _, obj := range objs {
+			if pv, ok := obj.(*v1.PersistentVolume); ok {
+				volumeIDs = append(volumeIDs, pv.Spec.CSI.VolumeHandle)
+			}
+		}
 	}
 	return volumeIDs
 }
@@ -1647,7 +1740,11 @@ func (c *K8sOrchestrator) CreateConfigMap(ctx context.Context, name string, name
 	return nil
 }
 
-// GetPVNameFromCSIVolumeID retrieves the pv name from volumeID using volumeIDToNameMap.
+// GetPVNameFromCSIVolumeID retrieves the pv name from volumeID using the PV indexer.
 func (c *K8sOrchestrator) GetPVNameFromCSIVolumeID(volumeID string) (string, bool) {
-	return c.volumeIDToNameMap.get(volumeID)
+	pv := c.getPVByVolumeID(context.Background(), volumeID)
+	if pv == nil {
+		return "", false
+	}
+	return pv.Name, true
 }
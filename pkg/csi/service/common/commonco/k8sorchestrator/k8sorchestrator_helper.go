@@ -19,7 +19,8 @@ package k8sorchestrator
 import (
 	"context"
 	"encoding/json"
-	"strings"
+	"os"
+	"strconv"
 	"time"
 
 	k8stypes "k8s.io/apimachinery/pkg/types"
@@ -34,44 +35,12 @@ import (
 	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
 )
 
-// getPVCAnnotations fetches annotations from PVC bound to passed volumeID and
-// returns annotation key-value pairs as a map.
-func (c *K8sOrchestrator) getPVCAnnotations(ctx context.Context, volumeID string) (map[string]string, error) {
-	log := logger.GetLogger(ctx)
-	log.Debugf("Getting annotations on pvc corresponding to volume: %s", volumeID)
-	if pvc := c.volumeIDToPvcMap.get(volumeID); pvc != "" {
-		parts := strings.Split(pvc, "/")
-		pvcNamespace := parts[0]
-		pvcName := parts[1]
-
-		pvcObj, err := c.informerManager.GetPVCLister().PersistentVolumeClaims(pvcNamespace).Get(pvcName)
-		if err != nil {
-			if apierrors.IsNotFound(err) {
-				// PVC may have been deleted.
-				log.Debugf("PVC %s is not found in namespace %s using informer manager", pvcName, pvcNamespace)
-				return nil, common.ErrNotFound
-			}
-			log.Errorf("failed to get pvc: %s in namespace: %s. err=%v", pvcName, pvcNamespace, err)
-			return nil, err
-		}
-
-		return pvcObj.Annotations, nil
-	}
-
-	log.Debugf("could not find pvc for volumeID: %s", volumeID)
-	return nil, common.ErrNotFound
-}
-
 // updatePVCAnnotations updates annotations passed as key-value pairs
 // on PVC bound to passed volumeID.
 func (c *K8sOrchestrator) updatePVCAnnotations(ctx context.Context,
 	volumeID string, annotations map[string]string) error {
 	log := logger.GetLogger(ctx)
-	if pvc := c.volumeIDToPvcMap.get(volumeID); pvc != "" {
-		parts := strings.Split(pvc, "/")
-		pvcNamespace := parts[0]
-		pvcName := parts[1]
-
+	if pvcNamespace, pvcName, found := c.getPVCNameByVolumeID(ctx, volumeID); found {
 		pvcObj, err := c.informerManager.GetPVCLister().PersistentVolumeClaims(pvcNamespace).Get(pvcName)
 		if err != nil {
 			if apierrors.IsNotFound(err) {
@@ -104,18 +73,10 @@ func (c *K8sOrchestrator) updatePVCAnnotations(ctx context.Context,
 	return logger.LogNewErrorf(log, "could not find pvc for volumeID: %s", volumeID)
 }
 
-// isFileVolume checks if the Persistent Volume has ReadWriteMany or
-// ReadOnlyMany support.
+// isFileVolume checks if the Persistent Volume is a file volume. Only the
+// fields of the PV are used, as it is called by index functions.
 func isFileVolume(pv *v1.PersistentVolume) bool {
-	if len(pv.Spec.AccessModes) == 0 {
-		return false
-	}
-	for _, accessMode := range pv.Spec.AccessModes {
-		if accessMode == v1.ReadWriteMany || accessMode == v1.ReadOnlyMany {
-			return true
-		}
-	}
-	return false
+	return common.IsFileVolumePVSpec(pv)
 }
 
 // isValidvSphereVolume returns true if the given PV metadata of a vSphere
@@ -124,10 +85,10 @@ func isValidMigratedvSphereVolume(ctx context.Context, pvMetadata metav1.ObjectM
 	log := logger.GetLogger(ctx)
 	// Checking if the migrated-to annotation is found in the PV metadata.
 	if annotation, annMigratedToFound := pvMetadata.Annotations[common.AnnMigratedTo]; annMigratedToFound {
-		if annotation == csitypes.Name &&
+		if annotation == csitypes.DriverName() &&
 			pvMetadata.Annotations[common.AnnDynamicallyProvisioned] == common.InTreePluginName {
 			log.Debugf("%v annotation found with value %q for PV: %q",
-				common.AnnMigratedTo, csitypes.Name, pvMetadata.Name)
+				common.AnnMigratedTo, csitypes.DriverName(), pvMetadata.Name)
 			return true
 		}
 	}
@@ -191,3 +152,64 @@ func (c *K8sOrchestrator) updateVolumeSnapshotAnnotations(ctx context.Context,
 	}
 	return true, nil
 }
+
+// orchestratorCacheProfiles maps the deployment size profiles, which can be
+// set in the ORCHESTRATOR_CACHE_PROFILE environment variable, to the maximum
+// number of entries of the bounded orchestrator caches.
+var orchestratorCacheProfiles = map[string]int{
+	"unbounded": 0,
+	"small":     5000,
+	"medium":    20000,
+	"large":     50000,
+}
+
+// getOrchestratorCacheMaxEntries returns the maximum number of entries of the
+// orchestrator caches, with 0 meaning the caches are unbounded.
+// If environment variable ORCHESTRATOR_CACHE_MAX_ENTRIES is set and has a
+// valid value greater than or equal to 0, return the value read from it.
+// Otherwise, if environment variable ORCHESTRATOR_CACHE_PROFILE is set to one
+// of the profiles in orchestratorCacheProfiles, return the size of the
+// profile. By default the caches are unbounded.
+func getOrchestratorCacheMaxEntries(ctx context.Context) int {
+	log := logger.GetLogger(ctx)
+	if v := os.Getenv("ORCHESTRATOR_CACHE_MAX_ENTRIES"); v != "" {
+		if value, err := strconv.Atoi(v); err == nil && value >= 0 {
+			log.Infof("Orchestrator caches are limited to %d entries", value)
+			return value
+		}
+		log.Warnf("Maximum entries set in env variable ORCHESTRATOR_CACHE_MAX_ENTRIES %q is invalid, "+
+			"ignoring it", v)
+	}
+	if v := os.Getenv("ORCHESTRATOR_CACHE_PROFILE"); v != "" {
+		if value, found := orchestratorCacheProfiles[v]; found {
+			log.Infof("Orchestrator caches are limited to %d entries as per profile %q", value, v)
+			return value
+		}
+		log.Warnf("Profile set in env variable ORCHESTRATOR_CACHE_PROFILE %q is invalid, "+
+			"orchestrator caches will be unbounded", v)
+	}
+	return 0
+}
+
+// getVolumeNameToNodesFromAPIServer lists the volume attachments from API
+// server and returns the nodes the given volumes are attached to. It is used
+// on lookups of volumes absent from the bounded volumeNameToNodesMap.
+func (c *K8sOrchestrator) getVolumeNameToNodesFromAPIServer(ctx context.Context,
+	volumeNames map[string]string) (map[string][]string, error) {
+	volumeAttachments, err := c.k8sClient.StorageV1().VolumeAttachments().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	volumeNameToNodes := make(map[string][]string)
+	for _, volumeAttachment := range volumeAttachments.Items {
+		if volumeAttachment.Spec.Attacher != csitypes.DriverName() || !volumeAttachment.Status.Attached ||
+			volumeAttachment.Spec.Source.PersistentVolumeName == nil {
+			continue
+		}
+		volumeName := *volumeAttachment.Spec.Source.PersistentVolumeName
+		if _, found := volumeNames[volumeName]; found {
+			volumeNameToNodes[volumeName] = append(volumeNameToNodes[volumeName], volumeAttachment.Spec.NodeName)
+		}
+	}
+	return volumeNameToNodes, nil
+}
@@ -23,9 +23,18 @@ import (
 	"sync"
 	"testing"
 
+	snapshotclientfake "github.com/kubernetes-csi/external-snapshotter/client/v6/clientset/versioned/fake"
 	cnstypes "github.com/vmware/govmomi/cns/types"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
 
 	cnsconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
 )
 
 var (
@@ -213,6 +222,40 @@ func TestIsFSSEnabledInVanilla(t *testing.T) {
 	}
 }
 
+// TestIsFSSEnabledInVanillaPrecedence tests that a released feature stays
+// enabled in vanilla flavor even when the internal FSS ConfigMap disables it.
+func TestIsFSSEnabledInVanillaPrecedence(t *testing.T) {
+	releasedFSS := map[string]struct{}{
+		"volume-extend": {},
+	}
+	internalFSSConfigMapInfo := FSSConfigMapInfo{
+		configMapName:      cnsconfig.DefaultInternalFSSConfigMapName,
+		configMapNamespace: cnsconfig.DefaultCSINamespace,
+		featureStates: map[string]string{
+			"volume-extend": "false",
+			"volume-health": "true",
+		},
+		featureStatesLock: &sync.RWMutex{},
+	}
+	k8sOrchestrator := K8sOrchestrator{
+		clusterFlavor:      cnstypes.CnsClusterFlavorVanilla,
+		internalFSS:        internalFSSConfigMapInfo,
+		releasedVanillaFSS: releasedFSS,
+	}
+	isEnabled := k8sOrchestrator.IsFSSEnabled(ctx, "volume-extend")
+	if !isEnabled {
+		t.Errorf("released volume-extend feature state is disabled!")
+	}
+	isEnabled = k8sOrchestrator.IsFSSEnabled(ctx, "volume-health")
+	if !isEnabled {
+		t.Errorf("volume-health feature state is disabled!")
+	}
+	featureStates := k8sOrchestrator.GetFeatureStates(ctx)
+	if len(featureStates) != 2 || !featureStates["volume-extend"] || !featureStates["volume-health"] {
+		t.Errorf("unexpected feature states %v", featureStates)
+	}
+}
+
 // TestIsFSSEnabledWithWrongClusterFlavor tests IsFSSEnabled when cluster flavor is not supported
 func TestIsFSSEnabledWithWrongClusterFlavor(t *testing.T) {
 	k8sOrchestrator := K8sOrchestrator{
@@ -229,9 +272,24 @@ func TestGetNodesForVolumes(t *testing.T) {
 		RWMutex: &sync.RWMutex{},
 		items:   make(map[string][]string),
 	}
-	volumeIDToNameMap := &volumeIDToNameMap{
-		RWMutex: &sync.RWMutex{},
-		items:   make(map[string]string),
+	pvIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{
+		pvVolumeIDIndex: pvVolumeIDIndexFunc,
+		pvClaimIndex:    pvClaimIndexFunc,
+	})
+	addPV := func(volumeID, volumeName string) {
+		err := pvIndexer.Add(&v1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: volumeName},
+			Spec: v1.PersistentVolumeSpec{
+				PersistentVolumeSource: v1.PersistentVolumeSource{
+					CSI: &v1.CSIPersistentVolumeSource{Driver: csitypes.Name, VolumeHandle: volumeID},
+				},
+				ClaimRef: &v1.ObjectReference{Namespace: "default", Name: "pvc-" + volumeName},
+			},
+			Status: v1.PersistentVolumeStatus{Phase: v1.VolumeBound},
+		})
+		if err != nil {
+			t.Fatalf("failed to add PV %q to the indexer. Error: %v", volumeName, err)
+		}
 	}
 	volumeIDs := []string{"ec5c1a4f-0c54-4681-b350-cbb79b08b4d7", "1994e110-7f86-4d77-aaba-d615d8e182ae",
 		"364908d2-82a1-4095-a8c9-0bcd9d62bddf", "ec5c1a4f-0c54-4681-b350-d615d8e182ae"}
@@ -239,11 +297,11 @@ func TestGetNodesForVolumes(t *testing.T) {
 		volumeNameToNodesMap.items["volume-"+strconv.Itoa(i)] = []string{"node" + strconv.Itoa(i), "node" + strconv.Itoa(i+5)}
 	}
 	for i := 1; i <= 3; i += 1 {
-		volumeIDToNameMap.items[volumeIDs[i-1]] = "volume-" + strconv.Itoa(i)
+		addPV(volumeIDs[i-1], "volume-"+strconv.Itoa(i))
 	}
-	volumeIDToNameMap.items["ec5c1a4f-0c54-4681-b350-d615d8e182ae"] = "volume-6"
+	addPV("ec5c1a4f-0c54-4681-b350-d615d8e182ae", "volume-6")
 	k8sOrchestrator := K8sOrchestrator{
-		volumeIDToNameMap:    volumeIDToNameMap,
+		pvIndexer:            pvIndexer,
 		volumeNameToNodesMap: volumeNameToNodesMap,
 	}
 
@@ -255,3 +313,268 @@ func TestGetNodesForVolumes(t *testing.T) {
 		t.Errorf("Expected node names %v but got %v", expectedNodeNames, nodeNames)
 	}
 }
+
+func TestGetFakeAttachedVolumes(t *testing.T) {
+	pvIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{
+		pvVolumeIDIndex: pvVolumeIDIndexFunc,
+		pvClaimIndex:    pvClaimIndexFunc,
+	})
+	k8sOrchestrator := K8sOrchestrator{
+		pvIndexer:       pvIndexer,
+		fakeAttachIndex: newFakeAttachIndex(),
+	}
+	newPVC := func(volumeID string, annotations map[string]string) *v1.PersistentVolumeClaim {
+		return &v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pvc-" + volumeID, Annotations: annotations},
+			Spec:       v1.PersistentVolumeClaimSpec{VolumeName: "pv-" + volumeID},
+		}
+	}
+	addVolume := func(volumeID string, annotations map[string]string) {
+		err := pvIndexer.Add(&v1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "pv-" + volumeID},
+			Spec: v1.PersistentVolumeSpec{
+				PersistentVolumeSource: v1.PersistentVolumeSource{
+					CSI: &v1.CSIPersistentVolumeSource{Driver: csitypes.Name, VolumeHandle: volumeID},
+				},
+				ClaimRef: &v1.ObjectReference{Namespace: "default", Name: "pvc-" + volumeID},
+			},
+			Status: v1.PersistentVolumeStatus{Phase: v1.VolumeBound},
+		})
+		if err != nil {
+			t.Fatalf("failed to add PV for volume %q to the indexer. Error: %v", volumeID, err)
+		}
+		k8sOrchestrator.pvcAdded(newPVC(volumeID, annotations))
+	}
+	addVolume("volume-1", map[string]string{common.AnnFakeAttached: "yes"})
+	addVolume("volume-2", map[string]string{common.AnnFakeAttached: "yes"})
+	addVolume("volume-3", map[string]string{common.AnnIgnoreInaccessiblePV: "yes"})
+	k8sOrchestrator.pvcUpdated(nil, newPVC("volume-2", map[string]string{common.AnnFakeAttached: ""}))
+
+	fakeAttachedVolumes := k8sOrchestrator.GetFakeAttachedVolumes(ctx,
+		[]string{"volume-1", "volume-2", "volume-3", "volume-4"})
+	expected := map[string]bool{"volume-1": true, "volume-2": false, "volume-3": false}
+	if !reflect.DeepEqual(fakeAttachedVolumes, expected) {
+		t.Errorf("Expected fake attached volumes %v but got %v", expected, fakeAttachedVolumes)
+	}
+	if !k8sOrchestrator.fakeAttachIndex.ignoresInaccessible("pv-volume-3") {
+		t.Errorf("Expected volume-3 to allow fake attach while inaccessible")
+	}
+
+	k8sOrchestrator.pvcDeleted(newPVC("volume-1", map[string]string{common.AnnFakeAttached: "yes"}))
+	if k8sOrchestrator.fakeAttachIndex.isFakeAttached("pv-volume-1") {
+		t.Errorf("Expected volume-1 to be removed from the fake attach index")
+	}
+}
+
+// TestGetVolumeAttachmentByPV tests that VAs not named by the
+// external-attacher are found by PV and node name.
+func TestGetVolumeAttachmentByPV(t *testing.T) {
+	const volumeID = "ec5c1a4f-0c54-4681-b350-cbb79b08b4d7"
+	pvName := "pv-1"
+	pvIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{
+		pvVolumeIDIndex: pvVolumeIDIndexFunc,
+	})
+	err := pvIndexer.Add(&v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: pvName},
+		Spec: v1.PersistentVolumeSpec{
+			PersistentVolumeSource: v1.PersistentVolumeSource{
+				CSI: &v1.CSIPersistentVolumeSource{Driver: csitypes.Name, VolumeHandle: volumeID},
+			},
+			ClaimRef: &v1.ObjectReference{Namespace: "default", Name: "pvc-1"},
+		},
+		Status: v1.PersistentVolumeStatus{Phase: v1.VolumeBound},
+	})
+	if err != nil {
+		t.Fatalf("failed to add PV to the indexer. Error: %v", err)
+	}
+	va := &storagev1.VolumeAttachment{
+		ObjectMeta: metav1.ObjectMeta{Name: "custom-va"},
+		Spec: storagev1.VolumeAttachmentSpec{
+			Attacher: csitypes.Name,
+			NodeName: "node-1",
+			Source:   storagev1.VolumeAttachmentSource{PersistentVolumeName: &pvName},
+		},
+	}
+	vaIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{
+		vaPVNodeIndex: vaPVNodeIndexFunc,
+	})
+	if err := vaIndexer.Add(va); err != nil {
+		t.Fatalf("failed to add VA to the indexer. Error: %v", err)
+	}
+	k8sOrchestrator := K8sOrchestrator{
+		k8sClient: k8sfake.NewSimpleClientset(va),
+		pvIndexer: pvIndexer,
+		vaIndexer: vaIndexer,
+	}
+
+	volumeAttachment, err := k8sOrchestrator.GetVolumeAttachment(ctx, volumeID, "node-1")
+	if err != nil || volumeAttachment.Name != "custom-va" {
+		t.Errorf("Expected VA custom-va, got %v, error: %v", volumeAttachment, err)
+	}
+	if _, err := k8sOrchestrator.GetVolumeAttachment(ctx, volumeID, "node-2"); err == nil {
+		t.Errorf("Expected an error for a volume not attached to node-2")
+	}
+}
+
+// TestGetSupervisorPVCForGuestPVC tests that guest PVCs, both block and file,
+// are mapped to the supervisor PVCs backing them.
+func TestGetSupervisorPVCForGuestPVC(t *testing.T) {
+	supervisorPVCIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{
+		pvGuestClaimIndex: pvGuestClaimIndexFunc,
+	})
+	addPV := func(pvcName string, volumeHandle string, accessMode v1.PersistentVolumeAccessMode,
+		phase v1.PersistentVolumePhase) {
+		err := supervisorPVCIndexer.Add(&v1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "pv-" + pvcName},
+			Spec: v1.PersistentVolumeSpec{
+				PersistentVolumeSource: v1.PersistentVolumeSource{
+					CSI: &v1.CSIPersistentVolumeSource{Driver: csitypes.Name, VolumeHandle: volumeHandle},
+				},
+				AccessModes: []v1.PersistentVolumeAccessMode{accessMode},
+				ClaimRef:    &v1.ObjectReference{Namespace: "default", Name: pvcName},
+			},
+			Status: v1.PersistentVolumeStatus{Phase: phase},
+		})
+		if err != nil {
+			t.Fatalf("failed to add PV for PVC %q to the indexer. Error: %v", pvcName, err)
+		}
+	}
+	addPV("block-pvc", "tkc-uid-1", v1.ReadWriteOnce, v1.VolumeBound)
+	addPV("file-pvc", "tkc-uid-2", v1.ReadWriteMany, v1.VolumeBound)
+	addPV("released-pvc", "tkc-uid-3", v1.ReadWriteOnce, v1.VolumeReleased)
+	k8sOrchestrator := K8sOrchestrator{
+		supervisorPVCIndexer: supervisorPVCIndexer,
+		supervisorNamespace:  "sv-namespace",
+	}
+
+	for pvcName, expected := range map[string]string{
+		"block-pvc":    "tkc-uid-1",
+		"file-pvc":     "tkc-uid-2",
+		"released-pvc": "",
+		"unknown-pvc":  "",
+	} {
+		svNamespace, svPVCName, found := k8sOrchestrator.GetSupervisorPVCForGuestPVC(ctx, "default", pvcName)
+		if found != (expected != "") || svPVCName != expected {
+			t.Errorf("Expected supervisor PVC %q for PVC %q, got %q, found: %v", expected, pvcName,
+				svPVCName, found)
+		}
+		if found && svNamespace != "sv-namespace" {
+			t.Errorf("Expected supervisor namespace sv-namespace for PVC %q, got %q", pvcName, svNamespace)
+		}
+	}
+	if _, _, found := (&K8sOrchestrator{}).GetSupervisorPVCForGuestPVC(ctx, "default", "block-pvc"); found {
+		t.Errorf("Expected no supervisor PVC without the index")
+	}
+}
+
+// TestNewK8sOrchestratorInstances tests that orchestrators created with
+// different options don't share their feature states.
+func TestNewK8sOrchestratorInstances(t *testing.T) {
+	const namespace = "vmware-system-csi"
+	newFSSConfigMap := func(name string, enabled bool) *v1.ConfigMap {
+		return &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Data:       map[string]string{"test-feature": strconv.FormatBool(enabled)},
+		}
+	}
+	enabledClient := k8sfake.NewSimpleClientset(newFSSConfigMap("enabled-fss", true))
+	disabledClient := k8sfake.NewSimpleClientset(newFSSConfigMap("disabled-fss", false))
+	informerManager := k8s.NewInformer(ctx, enabledClient, true)
+
+	newOrchestrator := func(client *k8sfake.Clientset, fssName string) *K8sOrchestrator {
+		orchestrator, err := NewK8sOrchestrator(ctx, K8sOrchestratorOptions{
+			ClusterFlavor: cnstypes.CnsClusterFlavorVanilla,
+			InitParams: K8sVanillaInitParams{
+				InternalFeatureStatesConfigInfo: cnsconfig.FeatureStatesConfigInfo{
+					Name:      fssName,
+					Namespace: namespace,
+				},
+				ServiceMode: "controller",
+			},
+			K8sClient:         client,
+			SnapshotterClient: snapshotclientfake.NewSimpleClientset(),
+			InformerManager:   informerManager,
+		})
+		if err != nil {
+			t.Fatalf("failed to create orchestrator with %q FSS configmap. Error: %v", fssName, err)
+		}
+		return orchestrator
+	}
+	enabledOrchestrator := newOrchestrator(enabledClient, "enabled-fss")
+	disabledOrchestrator := newOrchestrator(disabledClient, "disabled-fss")
+
+	if !enabledOrchestrator.IsFSSEnabled(ctx, "test-feature") {
+		t.Errorf("test-feature disabled in the orchestrator using the enabled-fss configmap")
+	}
+	if disabledOrchestrator.IsFSSEnabled(ctx, "test-feature") {
+		t.Errorf("test-feature enabled in the orchestrator using the disabled-fss configmap")
+	}
+}
+
+func TestBoundedVolumeNameToNodesMap(t *testing.T) {
+	m := newVolumeNameToNodesMap(2)
+	m.add("volume-1", []string{"node-1"})
+	m.add("volume-2", []string{"node-2"})
+	// Look up volume-1 so that volume-2 is the least recently used entry.
+	if _, found := m.get("volume-1"); !found {
+		t.Fatalf("volume-1 not found in the map")
+	}
+	m.add("volume-3", []string{"node-3"})
+	if _, found := m.get("volume-2"); found {
+		t.Errorf("volume-2 was expected to be evicted from the map")
+	}
+	for _, volumeName := range []string{"volume-1", "volume-3"} {
+		if _, found := m.get(volumeName); !found {
+			t.Errorf("%s not found in the map", volumeName)
+		}
+	}
+	if _, ok := m.getForUpdate("volume-2"); ok {
+		t.Errorf("updates of evicted volume-2 were expected to be skipped")
+	}
+}
+
+func TestGetPVNameFromCSIVolumeIDReleasedPV(t *testing.T) {
+	pvIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{
+		pvVolumeIDIndex: pvVolumeIDIndexFunc,
+		pvClaimIndex:    pvClaimIndexFunc,
+	})
+	pv := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "pv-protected",
+			Annotations: map[string]string{common.AnnDeletionProtection: common.DeletionProtectionDeny},
+		},
+		Spec: v1.PersistentVolumeSpec{
+			PersistentVolumeSource: v1.PersistentVolumeSource{
+				CSI: &v1.CSIPersistentVolumeSource{Driver: csitypes.Name, VolumeHandle: "volume-protected"},
+			},
+			ClaimRef: &v1.ObjectReference{Namespace: "default", Name: "pvc-protected"},
+		},
+		Status: v1.PersistentVolumeStatus{Phase: v1.VolumeBound},
+	}
+	if err := pvIndexer.Add(pv); err != nil {
+		t.Fatalf("failed to add PV to the indexer. Error: %v", err)
+	}
+	k8sOrchestrator := K8sOrchestrator{pvIndexer: pvIndexer}
+
+	// DeleteVolume is called once the PVC is deleted and the PV is Released,
+	// and has to find the PV to honor its deletion protection.
+	released := pv.DeepCopy()
+	released.Status.Phase = v1.VolumeReleased
+	if err := pvIndexer.Update(released); err != nil {
+		t.Fatalf("failed to update PV in the indexer. Error: %v", err)
+	}
+	pvName, found := k8sOrchestrator.GetPVNameFromCSIVolumeID("volume-protected")
+	if !found || pvName != "pv-protected" {
+		t.Errorf("expected Released PV %q to be found, got %q (found: %t)", "pv-protected", pvName, found)
+	}
+	if volumeIDs := k8sOrchestrator.GetAllK8sVolumes(); !reflect.DeepEqual(volumeIDs, []string{"volume-protected"}) {
+		t.Errorf("expected Released PV volume in the volumes of the cluster, got %v", volumeIDs)
+	}
+
+	if err := pvIndexer.Delete(released); err != nil {
+		t.Fatalf("failed to delete PV from the indexer. Error: %v", err)
+	}
+	if _, found := k8sOrchestrator.GetPVNameFromCSIVolumeID("volume-protected"); found {
+		t.Errorf("expected deleted PV not to be found")
+	}
+}
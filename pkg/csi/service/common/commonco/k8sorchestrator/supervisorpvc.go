@@ -0,0 +1,91 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8sorchestrator
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	cnsconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+)
+
+// pvGuestClaimIndex is the name of the PV informer index by bound PVC in
+// guest clusters. Unlike pvClaimIndex, it covers both block and file volumes,
+// as the volume handle of both is the name of the supervisor PVC.
+const pvGuestClaimIndex = "csi.vsphere.vmware.com/guest-claim"
+
+// initSupervisorPVCIndex adds the guest claim index on the PV informer, so
+// that the supervisor PVC backing a guest PVC can be looked up from the
+// informer store.
+func (c *K8sOrchestrator) initSupervisorPVCIndex(ctx context.Context) error {
+	log := logger.GetLogger(ctx)
+	log.Debugf("Initializing supervisor PVC index")
+	svNamespace, err := cnsconfig.GetSupervisorNamespace(ctx)
+	if err != nil {
+		return logger.LogNewErrorf(log, "failed to retrieve supervisor cluster namespace from config. Error: %v",
+			err)
+	}
+	err = c.informerManager.AddPVIndexers(ctx, cache.Indexers{
+		pvGuestClaimIndex: pvGuestClaimIndexFunc,
+	})
+	if err != nil {
+		return logger.LogNewErrorf(log, "failed to add supervisor PVC index on PVs. Error: %v", err)
+	}
+	c.supervisorNamespace = svNamespace
+	c.supervisorPVCIndexer = c.informerManager.GetPVIndexer()
+	return nil
+}
+
+// pvGuestClaimIndexFunc indexes bound PVs provisioned by the driver by the
+// namespaced name of the PVC they are bound to.
+func pvGuestClaimIndexFunc(obj interface{}) ([]string, error) {
+	pv, ok := obj.(*v1.PersistentVolume)
+	if !ok || pv.Status.Phase != v1.VolumeBound {
+		return nil, nil
+	}
+	if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != csitypes.DriverName() || pv.Spec.ClaimRef == nil {
+		return nil, nil
+	}
+	return []string{pv.Spec.ClaimRef.Namespace + "/" + pv.Spec.ClaimRef.Name}, nil
+}
+
+// GetSupervisorPVCForGuestPVC returns the namespace and name of the
+// supervisor PVC backing the given guest PVC. It returns false if the
+// orchestrator doesn't run in a guest cluster controller, or if the guest
+// PVC isn't bound to a volume of the driver.
+func (c *K8sOrchestrator) GetSupervisorPVCForGuestPVC(ctx context.Context, namespace string,
+	name string) (string, string, bool) {
+	log := logger.GetLogger(ctx)
+	if c.supervisorPVCIndexer == nil {
+		return "", "", false
+	}
+	objs, err := c.supervisorPVCIndexer.ByIndex(pvGuestClaimIndex, namespace+"/"+name)
+	if err != nil {
+		log.Errorf("failed to look up PV for PVC %s/%s. Error: %v", namespace, name, err)
+		return "", "", false
+	}
+	for _, obj := range objs {
+		if pv, ok := obj.(*v1.PersistentVolume); ok {
+			return c.supervisorNamespace, pv.Spec.CSI.VolumeHandle, true
+		}
+	}
+	return "", "", false
+}
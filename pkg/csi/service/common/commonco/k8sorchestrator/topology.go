@@ -56,6 +56,8 @@ import (
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
 	commoncotypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco/types"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/csidriverconfig"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/csinodetopology"
 	csinodetopologyv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/csinodetopology/v1alpha1"
 	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
@@ -116,6 +118,9 @@ var (
 	// isPodVMOnStretchedSupervisorEnabled is set to true only when the podvm-on-stretched-supervisor FSS
 	// is enabled
 	isPodVMOnStretchedSupervisorEnabled bool
+	// isWorkloadDomainIsolationEnabled is set to true only when the supervisor
+	// has the Workload Domain Isolation capability.
+	isWorkloadDomainIsolationEnabled bool
 	// csiNodeTopologyInformer refers to a shared K8s informer listening on CSINodeTopology instances
 	// in the cluster.
 	csiNodeTopologyInformer *cache.SharedIndexInformer
@@ -210,6 +215,15 @@ func (c *K8sOrchestrator) InitTopologyServiceInController(ctx context.Context) (
 					return nil, logger.LogNewErrorf(log,
 						"failed to update cache with topology information. Error: %+v", err)
 				}
+				if c.IsFSSEnabled(ctx, common.CSIDriverConfig) {
+					// Refresh the cache when the topology categories of the CSIDriverConfig change.
+					csidriverconfig.AddTopologyCategoriesHandler(func(ctx context.Context) {
+						if err := common.DiscoverTagEntities(ctx); err != nil {
+							logger.GetLogger(ctx).Errorf("failed to update cache with topology information "+
+								"after the topology categories changed. Error: %+v", err)
+						}
+					})
+				}
 
 				controllerVolumeTopologyInstance = &controllerVolumeTopology{
 					k8sConfig:               config,
@@ -255,6 +269,7 @@ func (c *K8sOrchestrator) InitTopologyServiceInController(ctx context.Context) (
 	} else if c.clusterFlavor == cnstypes.CnsClusterFlavorWorkload {
 		// Set isPodVMOnStretchedSupervisorEnabled if podvm-on-stretched-supervisor fss is enabled
 		isPodVMOnStretchedSupervisorEnabled = c.IsFSSEnabled(ctx, common.PodVMOnStretchedSupervisor)
+		isWorkloadDomainIsolationEnabled = c.IsFSSEnabled(ctx, common.WorkloadDomainIsolation)
 		controllerVolumeTopologyInstanceLock.RLock()
 		if wcpControllerVolumeTopologyInstance == nil {
 			controllerVolumeTopologyInstanceLock.RUnlock()
@@ -298,7 +313,7 @@ func (c *K8sOrchestrator) InitTopologyServiceInController(ctx context.Context) (
 func refreshPreferentialDatastores(ctx context.Context) error {
 	log := logger.GetLogger(ctx)
 	// Get VC instance.
-	cnsCfg, err := cnsconfig.GetConfig(ctx)
+	cnsCfg, err := cnsconfig.GetConfigSnapshot(ctx)
 	if err != nil {
 		return logger.LogNewErrorf(log, "failed to fetch CNS config. Error: %+v", err)
 	}
@@ -374,7 +389,25 @@ func refreshPreferentialDatastores(ctx context.Context) error {
 // instances using the K8s informer cache store.
 func (c *K8sOrchestrator) GetCSINodeTopologyInstancesList() []interface{} {
 	nodeTopologyStore := (*csiNodeTopologyInformer).GetStore()
-	return nodeTopologyStore.List()
+	var instances []interface{}
+	for _, obj := range nodeTopologyStore.List() {
+		if isOwnCSINodeTopologyObject(obj) {
+			instances = append(instances, obj)
+		}
+	}
+	return instances
+}
+
+// isOwnCSINodeTopologyObject returns true if the given unstructured
+// CSINodeTopology instance was created by this driver.
+func isOwnCSINodeTopologyObject(obj interface{}) bool {
+	unstructuredObj, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return false
+	}
+	var nodeTopoObj csinodetopologyv1alpha1.CSINodeTopology
+	err := runtime.DefaultUnstructuredConverter.FromUnstructured(unstructuredObj.Object, &nodeTopoObj)
+	return err == nil && common.IsOwnCSINodeTopology(nodeTopoObj)
 }
 
 // GetCSINodeTopologyInstanceByName fetches the CSINodeTopology instance
@@ -382,7 +415,7 @@ func (c *K8sOrchestrator) GetCSINodeTopologyInstancesList() []interface{} {
 func (c *K8sOrchestrator) GetCSINodeTopologyInstanceByName(nodeName string) (
 	item interface{}, exists bool, err error) {
 	nodeTopologyStore := (*csiNodeTopologyInformer).GetStore()
-	return nodeTopologyStore.GetByKey(nodeName)
+	return nodeTopologyStore.GetByKey(csitypes.InstanceObjectName(nodeName))
 }
 
 // startAvailabilityZoneInformer listens on changes to AvailabilityZone instances and updates the azClusterMap cache.
@@ -413,15 +446,8 @@ func startAvailabilityZoneInformer(ctx context.Context, cfg *restclient.Config)
 		return nil, err
 	}
 	availabilityZoneInformer := dynInformer.Informer()
-	_, err = availabilityZoneInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc: func(obj interface{}) {
-			azCRAdded(obj)
-		},
-		UpdateFunc: nil,
-		DeleteFunc: func(obj interface{}) {
-			azCRDeleted(obj)
-		},
-	})
+	_, err = availabilityZoneInformer.AddEventHandler(k8s.NewEventHandler("availabilityzones",
+		azCRAdded, nil, azCRDeleted))
 	if err != nil {
 		return nil, logger.LogNewErrorf(log,
 			"failed to add event handler on informer for availabilityzones CR. Error: %v", err)
@@ -469,12 +495,19 @@ func azCRDeleted(obj interface{}) {
 	removeFromAZClusterMap(ctx, azName)
 }
 
+// zonesSpanMultipleClusters returns true if a supervisor zone can contain
+// more than one vSphere cluster, in which case the clusters of each zone are
+// tracked in azClustersMap instead of azClusterMap.
+func zonesSpanMultipleClusters() bool {
+	return isPodVMOnStretchedSupervisorEnabled || isWorkloadDomainIsolationEnabled
+}
+
 // Adds the CR instance name and cluster moref to the azClusterMap.
 func addToAZClusterMap(ctx context.Context, azName string, clusterMorefs []string) {
 	log := logger.GetLogger(ctx)
 	azClusterMapInstanceLock.Lock()
 	defer azClusterMapInstanceLock.Unlock()
-	if isPodVMOnStretchedSupervisorEnabled {
+	if zonesSpanMultipleClusters() {
 		azClustersMap[azName] = clusterMorefs
 		log.Infof("Added clusters %v to %q zone in azClustersMap", clusterMorefs, azName)
 	} else {
@@ -488,7 +521,7 @@ func removeFromAZClusterMap(ctx context.Context, azName string) {
 	log := logger.GetLogger(ctx)
 	azClusterMapInstanceLock.Lock()
 	defer azClusterMapInstanceLock.Unlock()
-	if isPodVMOnStretchedSupervisorEnabled {
+	if zonesSpanMultipleClusters() {
 		delete(azClustersMap, azName)
 		log.Infof("Removed %q zone from azClustersMap", azName)
 	} else {
@@ -519,24 +552,13 @@ func startTopologyCRInformer(ctx context.Context, cfg *restclient.Config) (*cach
 		return nil, err
 	}
 	topologyInformer := dynInformer.Informer()
-	_, err = topologyInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		// Typically when the CSINodeTopology instance is created, the
-		// topology labels are not populated till the reconcile loop runs.
-		// However, this Add function will take care of cases where the node
-		// daemonset is restarted on driver upgrades and the CSINodeTopology
-		// instances already exist.
-		AddFunc: func(obj interface{}) {
-			topoCRAdded(obj)
-		},
-		// Update handler.
-		UpdateFunc: func(oldObj interface{}, newObj interface{}) {
-			topoCRUpdated(oldObj, newObj)
-		},
-		// Delete handler.
-		DeleteFunc: func(obj interface{}) {
-			topoCRDeleted(obj)
-		},
-	})
+	// Typically when the CSINodeTopology instance is created, the
+	// topology labels are not populated till the reconcile loop runs.
+	// However, the Add handler will take care of cases where the node
+	// daemonset is restarted on driver upgrades and the CSINodeTopology
+	// instances already exist.
+	_, err = topologyInformer.AddEventHandler(k8s.NewEventHandler(csinodetopology.CRDPlural,
+		topoCRAdded, topoCRUpdated, topoCRDeleted))
 	if err != nil {
 		return nil, logger.LogNewErrorf(log, "failed to add event handler on informer for %q CR. Error: %v",
 			csinodetopology.CRDPlural, err)
@@ -562,6 +584,9 @@ func topoCRAdded(obj interface{}) {
 			csinodetopology.CRDSingular, err)
 		return
 	}
+	if !common.IsOwnCSINodeTopology(nodeTopoObj) {
+		return
+	}
 	// Check if Status is set to Success.
 	if nodeTopoObj.Status.Status != csinodetopologyv1alpha1.CSINodeTopologySuccess {
 		log.Infof("topoCRAdded: CSINodeTopology instance %q not yet ready. Status: %q",
@@ -598,6 +623,9 @@ func topoCRUpdated(oldObj interface{}, newObj interface{}) {
 			csinodetopology.CRDSingular, err)
 		return
 	}
+	if !common.IsOwnCSINodeTopology(newNodeTopoObj) {
+		return
+	}
 	oldTopoLabelsMap := make(map[string]string)
 	for _, label := range oldNodeTopoObj.Status.TopologyLabels {
 		oldTopoLabelsMap[label.Key] = label.Value
@@ -646,6 +674,9 @@ func topoCRDeleted(obj interface{}) {
 			obj, csinodetopology.CRDSingular, err)
 		return
 	}
+	if !common.IsOwnCSINodeTopology(nodeTopoObj) {
+		return
+	}
 	// Delete node name from domainNodeMap if the status of the CR was set to Success.
 	if nodeTopoObj.Status.Status == csinodetopologyv1alpha1.CSINodeTopologySuccess {
 		if isMultiVCSupportEnabled {
@@ -659,30 +690,32 @@ func topoCRDeleted(obj interface{}) {
 	}
 }
 
-// Adds the CR instance name in the domainNodeMap wherever appropriate.
+// Adds the node name of the CR instance in the domainNodeMap wherever appropriate.
 func addNodeToDomainNodeMap(ctx context.Context, nodeTopoObj csinodetopologyv1alpha1.CSINodeTopology) {
 	log := logger.GetLogger(ctx)
 	domainNodeMapInstanceLock.Lock()
 	defer domainNodeMapInstanceLock.Unlock()
+	nodeName := common.CSINodeTopologyNodeName(nodeTopoObj)
 	for _, label := range nodeTopoObj.Status.TopologyLabels {
 		if _, exists := domainNodeMap[label.Value]; !exists {
-			domainNodeMap[label.Value] = map[string]struct{}{nodeTopoObj.Name: {}}
+			domainNodeMap[label.Value] = map[string]struct{}{nodeName: {}}
 		} else {
-			domainNodeMap[label.Value][nodeTopoObj.Name] = struct{}{}
+			domainNodeMap[label.Value][nodeName] = struct{}{}
 		}
 	}
-	log.Infof("Added %q value to domainNodeMap", nodeTopoObj.Name)
+	log.Infof("Added %q value to domainNodeMap", nodeName)
 }
 
-// Removes the CR instance name from the domainNodeMap.
+// Removes the node name of the CR instance from the domainNodeMap.
 func removeNodeFromDomainNodeMap(ctx context.Context, nodeTopoObj csinodetopologyv1alpha1.CSINodeTopology) {
 	log := logger.GetLogger(ctx)
 	domainNodeMapInstanceLock.Lock()
 	defer domainNodeMapInstanceLock.Unlock()
+	nodeName := common.CSINodeTopologyNodeName(nodeTopoObj)
 	for _, label := range nodeTopoObj.Status.TopologyLabels {
-		delete(domainNodeMap[label.Value], nodeTopoObj.Name)
+		delete(domainNodeMap[label.Value], nodeName)
 	}
-	log.Infof("Removed %q value from domainNodeMap", nodeTopoObj.Name)
+	log.Infof("Removed %q value from domainNodeMap", nodeName)
 }
 
 // InitTopologyServiceInNode returns a singleton implementation of the commoncotypes.NodeTopologyService interface.
@@ -760,7 +793,7 @@ func (volTopology *nodeVolumeTopology) GetNodeTopologyLabels(ctx context.Context
 	} else {
 		csiNodeTopology := &csinodetopologyv1alpha1.CSINodeTopology{}
 		csiNodeTopologyKey := types.NamespacedName{
-			Name: nodeInfo.NodeName,
+			Name: csitypes.InstanceObjectName(nodeInfo.NodeName),
 		}
 		err = volTopology.csiNodeTopologyK8sClient.Get(ctx, csiNodeTopologyKey, csiNodeTopology)
 		csiNodeTopologyFound := true
@@ -796,7 +829,8 @@ func (volTopology *nodeVolumeTopology) GetNodeTopologyLabels(ctx context.Context
 	// Create a watcher for CSINodeTopology CRs.
 	timeoutSeconds := int64((time.Duration(getCSINodeTopologyWatchTimeoutInMin(ctx)) * time.Minute).Seconds())
 	watchCSINodeTopology, err := volTopology.csiNodeTopologyWatcher.Watch(metav1.ListOptions{
-		FieldSelector:  fields.OneTermEqualSelector("metadata.name", nodeInfo.NodeName).String(),
+		FieldSelector: fields.OneTermEqualSelector("metadata.name",
+			csitypes.InstanceObjectName(nodeInfo.NodeName)).String(),
 		TimeoutSeconds: &timeoutSeconds,
 		Watch:          true,
 	})
@@ -813,7 +847,7 @@ func (volTopology *nodeVolumeTopology) GetNodeTopologyLabels(ctx context.Context
 			log.Warnf("Received unidentified object - %+v", event.Object)
 			continue
 		}
-		if csiNodeTopologyInstance.Name != nodeInfo.NodeName {
+		if csiNodeTopologyInstance.Name != csitypes.InstanceObjectName(nodeInfo.NodeName) {
 			continue
 		}
 		switch csiNodeTopologyInstance.Status.Status {
@@ -943,7 +977,7 @@ func createCSINodeTopologyInstance(ctx context.Context,
 	// Create spec for CSINodeTopology.
 	csiNodeTopologySpec := &csinodetopologyv1alpha1.CSINodeTopology{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: nodeInfo.NodeName,
+			Name: csitypes.InstanceObjectName(nodeInfo.NodeName),
 			OwnerReferences: []metav1.OwnerReference{
 				{
 					APIVersion: "v1",
@@ -1552,7 +1586,7 @@ func (volTopology *wcpControllerVolumeTopology) GetSharedDatastoresInTopology(ct
 		}
 
 		// Call GetCandidateDatastores for each cluster moref. Ignore the vsanDirectDatastores for now.
-		if !isPodVMOnStretchedSupervisorEnabled {
+		if !zonesSpanMultipleClusters() {
 			// This code block assume we have 1 Cluster Per AZ
 			accessibleDs, _, err := cnsvsphere.GetCandidateDatastoresInCluster(ctx, params.Vc, clusterMorefs[0], false)
 			if err != nil {
@@ -1585,7 +1619,7 @@ func (volTopology *wcpControllerVolumeTopology) getClustersMatchingTopologySegme
 	[]string, error) {
 	log := logger.GetLogger(ctx)
 	var matchingClusterMorefs []string
-	if isPodVMOnStretchedSupervisorEnabled {
+	if zonesSpanMultipleClusters() {
 		clusterMorefs, exists := azClustersMap[zone]
 		if !exists || len(clusterMorefs) == 0 {
 			return nil, logger.LogNewErrorf(log, "could not find the cluster MoIDs for zone %q in "+
@@ -1616,7 +1650,7 @@ func (volTopology *wcpControllerVolumeTopology) GetTopologyInfoFromNodes(ctx con
 	case "zonal":
 		if params.TopologyRequirement == nil {
 			// This case is for static volume provisioning using CNSRegisterVolume API
-			if !isPodVMOnStretchedSupervisorEnabled {
+			if !zonesSpanMultipleClusters() {
 				return nil, logger.LogNewErrorf(log, "topology requirement should not be nil. invalid params: %v", params)
 			} else {
 				// If the topology requirement received is nil, then identify topology of the datastore by looking into
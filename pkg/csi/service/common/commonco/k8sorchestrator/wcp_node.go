@@ -28,6 +28,7 @@ import (
 
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
 )
 
 var clusterComputeResourceMoIds []string
@@ -73,8 +74,9 @@ func (c *K8sOrchestrator) createCSINode(obj interface{}) {
 	}
 
 	var topologyKeysList []string
-	if len(clusterComputeResourceMoIds) > 1 {
-		// Publish zone and host standard topology keys for stretch supervisor.
+	if len(clusterComputeResourceMoIds) > 1 || c.IsFSSEnabled(ctx, common.WorkloadDomainIsolation) {
+		// Publish zone and host standard topology keys for stretch supervisor
+		// and for supervisors deployed on vSphere Zones.
 		topologyKeysList = append(topologyKeysList, corev1.LabelTopologyZone, corev1.LabelHostname)
 	} else {
 		topologyKeysList = append(topologyKeysList, corev1.LabelHostname)
@@ -96,7 +98,7 @@ func (c *K8sOrchestrator) createCSINode(obj interface{}) {
 		Spec: storagev1.CSINodeSpec{
 			Drivers: []storagev1.CSINodeDriver{
 				{
-					Name:         common.VSphereCSIDriverName,
+					Name:         csitypes.DriverName(),
 					NodeID:       node.Name,
 					TopologyKeys: topologyKeysList,
 				},
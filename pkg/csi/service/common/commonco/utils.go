@@ -23,6 +23,7 @@ import (
 	cnstypes "github.com/vmware/govmomi/cns/types"
 
 	csiconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco/k8sorchestrator"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
 )
@@ -42,8 +43,8 @@ func SetInitParams(ctx context.Context, clusterFlavor cnstypes.CnsClusterFlavor,
 			supervisorFSSName = csiconfig.DefaultSupervisorFSSConfigMapName
 		}
 		if strings.TrimSpace(supervisorFSSNamespace) == "" {
-			log.Infof("Defaulting feature states configmap namespace to %q", csiconfig.DefaultCSINamespace)
-			supervisorFSSNamespace = csiconfig.DefaultCSINamespace
+			log.Infof("Defaulting feature states configmap namespace to %q", common.GetCSINamespace())
+			supervisorFSSNamespace = common.GetCSINamespace()
 		}
 		*initParams = k8sorchestrator.K8sSupervisorInitParams{
 			SupervisorFeatureStatesConfigInfo: csiconfig.FeatureStatesConfigInfo{
@@ -59,8 +60,8 @@ func SetInitParams(ctx context.Context, clusterFlavor cnstypes.CnsClusterFlavor,
 			internalFSSName = csiconfig.DefaultInternalFSSConfigMapName
 		}
 		if strings.TrimSpace(internalFSSNamespace) == "" {
-			log.Infof("Defaulting feature states configmap namespace to %q", csiconfig.DefaultCSINamespace)
-			internalFSSNamespace = csiconfig.DefaultCSINamespace
+			log.Infof("Defaulting feature states configmap namespace to %q", common.GetCSINamespace())
+			internalFSSNamespace = common.GetCSINamespace()
 		}
 		*initParams = k8sorchestrator.K8sVanillaInitParams{
 			InternalFeatureStatesConfigInfo: csiconfig.FeatureStatesConfigInfo{
@@ -85,8 +86,8 @@ func SetInitParams(ctx context.Context, clusterFlavor cnstypes.CnsClusterFlavor,
 			internalFSSName = csiconfig.DefaultInternalFSSConfigMapName
 		}
 		if strings.TrimSpace(internalFSSNamespace) == "" {
-			log.Infof("Defaulting internal feature states configmap namespace to %q", csiconfig.DefaultCSINamespace)
-			internalFSSNamespace = csiconfig.DefaultCSINamespace
+			log.Infof("Defaulting internal feature states configmap namespace to %q", common.GetCSINamespace())
+			internalFSSNamespace = common.GetCSINamespace()
 		}
 		*initParams = k8sorchestrator.K8sGuestInitParams{
 			InternalFeatureStatesConfigInfo: csiconfig.FeatureStatesConfigInfo{
@@ -25,6 +25,13 @@ const (
 	// GbInBytes is the number of bytes in one gibibyte.
 	GbInBytes = int64(1024 * 1024 * 1024)
 
+	// GbInMb is the number of mebibytes in one gibibyte.
+	GbInMb = int64(1024)
+
+	// MaxBlockVolumeSizeInMb is the maximum size of a block volume, 62 TiB
+	// being the maximum size of a virtual disk.
+	MaxBlockVolumeSizeInMb = int64(62 * 1024 * 1024)
+
 	// DefaultGbDiskSize is the default disk size in gibibytes.
 	// TODO: will make the DefaultGbDiskSize configurable in the future.
 	DefaultGbDiskSize = int64(10)
@@ -38,7 +45,8 @@ const (
 	// AttributeDiskType is a PersistentVolume's attribute.
 	AttributeDiskType = "type"
 
-	// AttributeDatastoreURL represents URL of the datastore in the StorageClass.
+	// AttributeDatastoreURL represents URL of the datastore in the StorageClass,
+	// and of the datastore holding the volume in the block volume attributes.
 	// For Example: DatastoreURL: "ds:///vmfs/volumes/5c9bb20e-009c1e46-4b85-0200483b2a97/".
 	AttributeDatastoreURL = "datastoreurl"
 
@@ -60,6 +68,77 @@ const (
 	// For example: StorageTopologyType: "zonal"
 	AttributeStorageTopologyType = "storagetopologytype"
 
+	// AttributeMultiWriter represents the Storage Class parameter which
+	// allows ReadWriteMany raw block volumes to be attached to multiple nodes
+	// with the FCD multi-writer flag, for clustered filesystems like OCFS2
+	// and GFS2.
+	AttributeMultiWriter = "multiwriter"
+
+	// AttributeNodeLocal represents the Storage Class parameter which places
+	// block volumes on datastores mounted by a single ESXi host, such as
+	// vSAN Direct or local VMFS datastores.
+	AttributeNodeLocal = "nodelocal"
+
+	// AttributeReclaimAction represents the Storage Class parameter which
+	// selects what DeleteVolume does with the backing disk. It can be set to
+	// ReclaimActionDelete (default) or ReclaimActionArchive.
+	AttributeReclaimAction = "reclaimaction"
+	// AttributeMaxVolumeSizeGb represents the Storage Class parameter which
+	// limits the size, in GiB, of the block volumes of the Storage Class.
+	AttributeMaxVolumeSizeGb = "maxvolumesizegb"
+	// AttributeDatastoreFolder represents the Storage Class parameter which
+	// places the disks of the block volumes of the Storage Class in a folder
+	// of the datastore given by AttributeDatastoreURL.
+	AttributeDatastoreFolder = "datastorefolder"
+	// AttributeProvisioningType represents the Storage Class parameter which
+	// sets the provisioning type, thin, lazyzeroedthick or eagerzeroedthick,
+	// of the block volumes restored from a snapshot of a volume of another
+	// provisioning type. Other volumes follow their storage policy.
+	AttributeProvisioningType = "provisioningtype"
+	// ReclaimActionDelete deletes the backing disk of the volume.
+	ReclaimActionDelete = "delete"
+	// ReclaimActionArchive keeps the backing disk of the volume for the
+	// configured retention period before deleting it.
+	ReclaimActionArchive = "archive"
+	// VStorageObjectMetadataReclaimAction is the FCD metadata key recording
+	// the reclaim action of the volume.
+	VStorageObjectMetadataReclaimAction = "cns.vmware.com/reclaim-action"
+	// VStorageObjectMetadataArchivedAt is the FCD metadata key recording,
+	// in RFC3339 format, when an archived volume was deleted by its user.
+	VStorageObjectMetadataArchivedAt = "cns.vmware.com/archived-at"
+	// VStorageObjectMetadataStoragePolicyID is the FCD metadata key recording
+	// the storage policy of an archived volume, reapplied when it is restored.
+	VStorageObjectMetadataStoragePolicyID = "cns.vmware.com/storage-policy-id"
+	// VStorageObjectMetadataFsType is the FCD metadata key recording the
+	// filesystem type of a volume with the archive reclaim action, reapplied
+	// when it is restored.
+	VStorageObjectMetadataFsType = "cns.vmware.com/fstype"
+	// VStorageObjectMetadataPVCLabels is the FCD metadata key recording the
+	// JSON encoded labels of the PVC of an archived volume, reapplied when it
+	// is restored.
+	VStorageObjectMetadataPVCLabels = "cns.vmware.com/pvc-labels"
+	// VStorageObjectMetadataRestoredBy is the FCD metadata key recording the
+	// namespace/name of the CnsVolumeRestore instance that restored the volume.
+	VStorageObjectMetadataRestoredBy = "cns.vmware.com/restored-by"
+	// VStorageObjectMetadataRetainedAt is the FCD metadata key recording, in
+	// RFC3339 format, when a volume protected by a safety snapshot was deleted
+	// by its user.
+	VStorageObjectMetadataRetainedAt = "cns.vmware.com/retained-at"
+	// VStorageObjectMetadataSafetySnapshotID is the FCD metadata key recording
+	// the CSI snapshot ID of the safety snapshot of a retained volume.
+	VStorageObjectMetadataSafetySnapshotID = "cns.vmware.com/safety-snapshot-id"
+	// VStorageObjectMetadataLastSnapshotAt is the FCD metadata key recording,
+	// in RFC3339 format, when the last snapshot of the volume was taken.
+	VStorageObjectMetadataLastSnapshotAt = "cns.vmware.com/last-snapshot-at"
+
+	// VolumeAllocationNamespace is the SPBM namespace of the volume allocation
+	// capability which controls the provisioning type of a disk.
+	VolumeAllocationNamespace = "com.vmware.storage.volumeallocation"
+
+	// VolumeAllocationFullyInitialized is the volume allocation type for
+	// eager-zeroed thick disks, which multi-writer volumes require.
+	VolumeAllocationFullyInitialized = "Fully initialized"
+
 	// AttributeFsType represents filesystem type in the Storage Classs.
 	// For Example: FsType: "ext4".
 	AttributeFsType = "fstype"
@@ -81,6 +160,10 @@ const (
 	// AttributePvcNamespace represents the namespace of the PVC
 	AttributePvcNamespace = "csi.storage.k8s.io/pvc/namespace"
 
+	// AttributeSnapshotHooks is the VolumeSnapshotClass parameter enabling
+	// the pre and post snapshot hooks annotated on the workload pods.
+	AttributeSnapshotHooks = "snapshothooks"
+
 	// AttributeStorageClassName represents name of the Storage Class.
 	AttributeStorageClassName = "csi.storage.k8s.io/sc/name"
 
@@ -197,6 +280,18 @@ const (
 	// is created.
 	AttributeInitialVolumeFilepath = "initialvolumefilepath"
 
+	// AttributeSnapshotOwnerUID represents the uid recorded on the
+	// VolumeSnapshotContent a volume is restored from.
+	AttributeSnapshotOwnerUID = "snapshotowneruid"
+
+	// AttributeSnapshotOwnerGID represents the gid recorded on the
+	// VolumeSnapshotContent a volume is restored from.
+	AttributeSnapshotOwnerGID = "snapshotownergid"
+
+	// AttributeSnapshotFSGroup represents the fsGroup recorded on the
+	// VolumeSnapshotContent a volume is restored from.
+	AttributeSnapshotFSGroup = "snapshotfsgroup"
+
 	// DatastoreMigrationParam is used to supply datastore name for Volume
 	// provisioning.
 	DatastoreMigrationParam = "datastore-migrationparam"
@@ -259,12 +354,51 @@ const (
 	// HostConfigStoragePriv is the privilege for file volumes.
 	HostConfigStoragePriv = "Host.Config.Storage"
 
+	// StorageProfileViewPriv is the privilege to view storage policies.
+	StorageProfileViewPriv = "StorageProfile.View"
+
+	// CnsSearchablePriv is the privilege to search volumes in CNS.
+	CnsSearchablePriv = "Cns.Searchable"
+
 	// AnnVolumeHealth is the key for HealthStatus annotation on volume claim.
 	AnnVolumeHealth = "volumehealth.storage.kubernetes.io/health"
 
 	// AnnFakeAttached is the key for fake attach annotation on volume claim.
 	AnnFakeAttached = "csi.vmware.com/fake-attached"
 
+	// AnnCascadeDeleteSnapshots is the annotation key on a PV allowing
+	// DeleteVolume to delete the CNS snapshots of the volume along with it.
+	AnnCascadeDeleteSnapshots = "cns.vmware.com/cascade-delete-snapshots"
+
+	// AnnDeletionProtection is the annotation key on a PV or PVC protecting
+	// the volume from deletion. It can be set to DeletionProtectionDeny or
+	// DeletionProtectionSnapshot.
+	AnnDeletionProtection = "cns.vmware.com/deletion-protection"
+	// DeletionProtectionDeny makes DeleteVolume refuse to delete the volume.
+	DeletionProtectionDeny = "deny"
+	// DeletionProtectionSnapshot makes DeleteVolume take a snapshot of the
+	// volume and retain it for the configured retention period instead of
+	// deleting it.
+	DeletionProtectionSnapshot = "snapshot"
+
+	// AnnRestoreDatastore is the annotation key on a PVC pinning the volume
+	// restored from a snapshot to a datastore. It can be set to
+	// RestoreDatastoreSource, or to the URL or the name of a datastore.
+	AnnRestoreDatastore = "cns.vmware.com/restore-datastore"
+	// RestoreDatastoreSource pins the restored volume to the datastore of the
+	// source snapshot.
+	RestoreDatastoreSource = "source"
+
+	// AnnProvisioningDryRun is the annotation key on a PVC requesting a dry
+	// run of the provisioning of its volume when set to "true". CreateVolume
+	// then validates the request and selects the datastores of the volume,
+	// reports the decision in the AnnProvisioningDryRunResult annotation and
+	// in an event on the PVC, and fails without creating the volume.
+	AnnProvisioningDryRun = "cns.vmware.com/provisioning-dry-run"
+	// AnnProvisioningDryRunResult is the annotation key on a PVC holding the
+	// decision of the dry run of the provisioning of its volume, in JSON.
+	AnnProvisioningDryRunResult = "cns.vmware.com/provisioning-dry-run-result"
+
 	// VolHealthStatusAccessible is volume health status for accessible volume.
 	VolHealthStatusAccessible = "accessible"
 
@@ -275,6 +409,25 @@ const (
 	// if inaccessible PV can be fake attached.
 	AnnIgnoreInaccessiblePV = "pv.attach.kubernetes.io/ignore-if-inaccessible"
 
+	// AnnNodeDeleted is the annotation key set on a VolumeAttachment before
+	// deleting it because its node no longer exists, so that the node is
+	// forgotten for the volume even though the VolumeAttachment is still
+	// reported as attached.
+	AnnNodeDeleted = "cns.vmware.com/node-deleted"
+
+	// AnnNodeAffinityMigrationPlan is the annotation key on a PV holding the
+	// steps to recreate the PV with the node affinity of the datastore its
+	// volume was relocated to, when its node affinity can't be updated.
+	AnnNodeAffinityMigrationPlan = "cns.vmware.com/node-affinity-migration-plan"
+
+	// EventReasonPolicyNoncompliant is the reason of the event recorded when
+	// a volume becomes noncompliant with its storage policy.
+	EventReasonPolicyNoncompliant = "PolicyNoncompliant"
+
+	// AnnEventVolumeID is the annotation key on the events recorded by the
+	// driver holding the ID of the CNS volume the event pertains to.
+	AnnEventVolumeID = "cns.vmware.com/volume-id"
+
 	// TriggerCsiFullSyncCRName is the instance name of TriggerCsiFullSync
 	// All other names will be rejected by TriggerCsiFullSync controller.
 	TriggerCsiFullSyncCRName = "csifullsync"
@@ -290,6 +443,14 @@ const (
 	// topology labels applied on the node by vSphere CSI driver.
 	TopologyLabelsDomain = "topology.csi.vmware.com"
 
+	// TopologyLabelEsxiHost is the topology label holding the name of the
+	// ESXi host on which the node VM is running.
+	TopologyLabelEsxiHost = TopologyLabelsDomain + "/esxi-host"
+
+	// TopologyLabelVSphereCluster is the topology label holding the name of
+	// the vSphere cluster to which the node VM belongs.
+	TopologyLabelVSphereCluster = TopologyLabelsDomain + "/vsphere-cluster"
+
 	//AnnGuestClusterRequestedTopology is the key for guest cluster requested topology
 	AnnGuestClusterRequestedTopology = "csi.vsphere.volume-requested-topology"
 
@@ -320,6 +481,10 @@ const (
 	// the request parameters
 	VolumeSnapshotNamespaceKey = "csi.storage.k8s.io/volumesnapshot/namespace"
 
+	// VolumeSnapshotContentNameKey represents the volumesnapshotcontent CR name
+	// within the request parameters
+	VolumeSnapshotContentNameKey = "csi.storage.k8s.io/volumesnapshotcontent/name"
+
 	// VolumeSnapshotInfoKey represents the annotation key of the fcd-id + snapshot-id
 	// on the VolumeSnapshot CR
 	VolumeSnapshotInfoKey = "csi.vsphere.volume/snapshot"
@@ -417,12 +582,204 @@ const (
 	// PodVMOnStretchedSupervisor is the WCP FSS which determines if PodVM
 	// support is available on stretched supervisor cluster.
 	PodVMOnStretchedSupervisor = "PodVM_On_Stretched_Supervisor_Supported"
+	// WorkloadDomainIsolation is the WCP FSS which determines if the supervisor
+	// is deployed with vSphere Zones, isolating workloads per zone.
+	WorkloadDomainIsolation = "Workload_Domain_Isolation_Supported"
 	// StorageQuotaM2 enables support for snapshot quota feature
 	StorageQuotaM2 = "storage-quota-m2"
 	// VdppOnStretchedSupervisor enables support for vDPp workloads on stretched SV clusters
 	VdppOnStretchedSupervisor = "vdpp-on-stretched-supervisor"
+	// VolumeIOStats enables collection of per-volume IO statistics from the
+	// vCenter performance manager.
+	VolumeIOStats = "volume-io-stats"
+	// StaticPVNodeAffinity enables generation of node affinity for statically
+	// provisioned PVs in topology aware deployments.
+	StaticPVNodeAffinity = "static-pv-node-affinity"
+	// NodeLocalVolumes enables provisioning of block volumes on host local
+	// datastores using the "nodelocal" storage class parameter.
+	NodeLocalVolumes = "node-local-volumes"
+	// PodVMAttachBatching batches CnsNodeVmAttachment requests for the same
+	// PodVM into a single CNS AttachVolume call.
+	PodVMAttachBatching = "podvm-attach-batching"
+	// DetachProtection enables the webhook check rejecting manual detaches
+	// of volumes which are in use.
+	DetachProtection = "detach-protection"
+	// ArchiveReclaim enables the "archive" reclaim action for block volumes.
+	ArchiveReclaim = "archive-reclaim"
+	// VolumeOperationRequestDebug exposes the persisted volume operation
+	// requests on the controller admin server for listing and purging.
+	VolumeOperationRequestDebug = "volume-operation-request-debug"
+	// SnapshotCascadeDelete allows DeleteVolume to delete the CNS snapshots of
+	// volumes whose PV is annotated with AnnCascadeDeleteSnapshots.
+	SnapshotCascadeDelete = "snapshot-cascade-delete"
+	// OutOfBandResizeSync enables full sync to update the capacity of PVs
+	// whose volumes were grown outside of kubernetes.
+	OutOfBandResizeSync = "out-of-band-resize-sync"
+	// DatastoreURLReconcile enables full sync to report datastores whose URL
+	// changed after a rename or remount on the affected PVs and StorageClasses.
+	DatastoreURLReconcile = "datastore-url-reconcile"
+	// DriverCapabilities enables the controller to publish the capabilities
+	// of the driver in the vsphere-csi-capabilities ConfigMap.
+	DriverCapabilities = "driver-capabilities"
+	// StoragePolicyStorageClassMapper enables the syncer to generate and keep
+	// in sync StorageClasses for the storage policies of vCenter marked for it.
+	StoragePolicyStorageClassMapper = "storage-policy-storageclass-mapper"
+	// StoragePolicyPrecheck enables CreateVolume to validate the storage
+	// policy of the volume against the storage policies cached by the
+	// authorization service, before calling CNS.
+	StoragePolicyPrecheck = "storage-policy-precheck"
+	// VCenterPrivilegeReport exposes, on the controller admin server, a
+	// report of the vCenter privileges missing to the CSI VC user.
+	VCenterPrivilegeReport = "vcenter-privilege-report"
+	// AuthRefreshOnPermissionChange enables the authorization service to
+	// refresh its datastore and cluster maps as soon as the permissions of
+	// vCenter change, in addition to the periodic refresh.
+	AuthRefreshOnPermissionChange = "auth-refresh-on-permission-change"
+	// FullSyncConflictResolution enables versioning of the volume metadata
+	// written to CNS by the syncer, so that full sync does not overwrite the
+	// metadata updated by the metadata syncer after full sync listed the
+	// Kubernetes objects.
+	FullSyncConflictResolution = "fullsync-conflict-resolution"
+	// VolumePolicyMigration enables the CnsVolumePolicyMigration CR to change
+	// the storage policy of existing block volumes.
+	VolumePolicyMigration = "volume-policy-migration"
+	// ProvisioningCancellation enables CreateVolume to stop provisioning a
+	// block volume, and to delete the volume created for it, when its PVC is
+	// deleted during provisioning.
+	ProvisioningCancellation = "provisioning-cancellation"
+	// VolumeDeletionProtection enables DeleteVolume to honor the
+	// AnnDeletionProtection annotation on PVs and PVCs.
+	VolumeDeletionProtection = "volume-deletion-protection"
+	// ProvisioningPolicyHook enables the review of CreateVolume and
+	// CreateSnapshot requests by the policy engine set in the config.
+	ProvisioningPolicyHook = "provisioning-policy-hook"
+	// SystemResourceProtection enables the webhook check rejecting the
+	// deletion of the feature state ConfigMaps and CRs used by the driver.
+	SystemResourceProtection = "system-resource-protection"
+	// RestoreDatastorePinning enables CreateVolume to honor the
+	// AnnRestoreDatastore annotation on PVCs restored from a snapshot.
+	RestoreDatastorePinning = "restore-datastore-pinning"
+	// CrossClassSnapshotRestore enables CreateVolume to restore a snapshot
+	// into a PVC of a StorageClass other than the one of the source volume,
+	// by converting the restored volume to the storage policy and datastore
+	// of the StorageClass.
+	CrossClassSnapshotRestore = "cross-class-snapshot-restore"
+	// SnapshotHooks enables CreateSnapshot to run the pre and post snapshot
+	// commands annotated on the pods using the volume.
+	SnapshotHooks = "snapshot-hooks"
+	// SnapshotExport enables the CnsSnapshotExport CR to upload the content of
+	// a snapshot to an S3-compatible bucket.
+	SnapshotExport = "snapshot-export"
+	// SnapshotChangedBlockTracking enables the CnsSnapshotDelta CR to query the
+	// areas of a snapshot changed since an earlier snapshot of the volume.
+	SnapshotChangedBlockTracking = "snapshot-changed-block-tracking"
+	// FakeAttachRecovery enables the syncer to attach again the fake attached
+	// volumes whose health returned to accessible.
+	FakeAttachRecovery = "fake-attach-recovery"
+	// NodePluginVersionCheck enables ControllerPublishVolume to reject the
+	// volumes needing a newer node plugin than the one running on the node.
+	NodePluginVersionCheck = "node-plugin-version-check"
+	// CnsNodeInfo enables the CnsNodeInfo CRs persisting the vCenter details
+	// of the node VMs, used to look up the node VMs without searching vCenter.
+	CnsNodeInfo = "cns-node-info"
+	// AdaptiveQueryBatchSize enables full sync to adapt the page size of its
+	// QueryVolume calls to the response times of vCenter.
+	AdaptiveQueryBatchSize = "adaptive-query-batch-size"
+	// ResumableFullSync enables full sync to checkpoint its progress in a
+	// CnsFullSyncCheckpoint CR, to resume where it left off after a restart,
+	// and to rate limit its CNS operations.
+	ResumableFullSync = "resumable-full-sync"
+	// CSIDriverConfig enables the cluster scoped CSIDriverConfig CR, whose
+	// tunables override the environment variables and the vSphere config
+	// secret of the driver while it runs.
+	CSIDriverConfig = "csi-driver-config"
+	// VCenterEventReporter enables posting the significant Kubernetes warning
+	// events of the volumes, like provisioning and attach failures, as events
+	// to vCenter.
+	VCenterEventReporter = "vcenter-event-reporter"
+	// DatastoreUsageAlarms enables watching the datastore usage alarms of
+	// vCenter, to deprioritize the datastores with a triggered alarm for new
+	// volumes.
+	DatastoreUsageAlarms = "datastore-usage-alarms"
+	// DiskSlotValidation enables checking the free disk slots of the disk
+	// controllers of a node VM before attaching a volume to it.
+	DiskSlotValidation = "disk-slot-validation"
+	// CrossVCenterClone enables creating a volume from a snapshot on a
+	// vCenter other than the vCenter of the snapshot in multi vCenter
+	// deployments, by copying the restored disk to the target vCenter.
+	CrossVCenterClone = "cross-vcenter-clone"
+	// ProvisioningSegmentBackoff enables deprioritizing the topology segments
+	// in which block volume provisioning recently failed repeatedly, with a
+	// decay, for new volumes.
+	ProvisioningSegmentBackoff = "provisioning-segment-backoff"
+	// PVAccessibilityReporting enables checking the node affinity of the
+	// bound PVs against the nodes of the driver, to report the PVs whose pods
+	// can't be scheduled on any node with a condition on their PVC.
+	PVAccessibilityReporting = "pv-accessibility-reporting"
+	// StaleVolumeAttachmentGC enables deleting the VolumeAttachments whose
+	// node was deleted and no longer runs pods using the volume, so that the
+	// volume can be attached to the surviving nodes.
+	StaleVolumeAttachmentGC = "stale-volume-attachment-gc"
+	// ProvisioningDryRun enables the dry run of the provisioning of the block
+	// volumes whose PVC is annotated with AnnProvisioningDryRun.
+	ProvisioningDryRun = "provisioning-dry-run"
+	// DiskFormatConversion enables converting the block volumes restored from
+	// a snapshot to the provisioning type of their StorageClass. It requires
+	// CrossClassSnapshotRestore.
+	DiskFormatConversion = "disk-format-conversion"
+	// RelocatedPVNodeAffinity enables updating the node affinity of the PVs
+	// whose volume was relocated to a datastore accessible from other zones.
+	RelocatedPVNodeAffinity = "relocated-pv-node-affinity"
+	// StructuredCSIErrors enables returning the errors of the CSI controller
+	// operations as fault.Error, with the gRPC code of their fault type and
+	// the fault type in their message and details.
+	StructuredCSIErrors = "structured-csi-errors"
+	// ProvisioningPrecheck enables the provisioning pre-check endpoint of the
+	// controller, answering whether a volume could be provisioned in a zone
+	// for cluster autoscaler and scheduler simulations.
+	ProvisioningPrecheck = "provisioning-precheck"
+	// ControllerReadOnlyMode lets VI admins switch the controller to a
+	// read-only mode through a ConfigMap, during which volume and snapshot
+	// provisioning, deletion and expansion fail with a retriable error.
+	ControllerReadOnlyMode = "controller-read-only-mode"
+	// DatastorePropertyCache enables caching the datastore properties used
+	// for volume placement, invalidated on the datastore changes reported by
+	// the property collector of vCenter.
+	DatastorePropertyCache = "datastore-property-cache"
+	// UsageReporting enables the opt-in reporting of anonymized usage counts
+	// of the driver in a CnsUsageReport CR, optionally posted to an
+	// endpoint.
+	UsageReporting = "usage-reporting"
+	// NodeIOThrottling enables limiting the IO of the pods on their published
+	// block volumes through cgroups, from the IOPS limits annotated on the
+	// PVCs.
+	NodeIOThrottling = "node-io-throttling"
+	// SELinuxMount enables mounting block volumes with the SELinux context of
+	// the pods, which kubelet passes in the "context" mount option instead of
+	// relabeling the volumes recursively once the CSIDriver object sets
+	// seLinuxMount.
+	SELinuxMount = "selinux-mount"
+	// ReadWriteOncePod enables the SINGLE_NODE_MULTI_WRITER capability, with
+	// which the ReadWriteOncePod access mode is supported.
+	ReadWriteOncePod = "read-write-once-pod"
+	// DelegateFSGroup enables the VOLUME_MOUNT_GROUP node capability, with
+	// which kubelet delegates the change of the ownership of the volumes to
+	// the fsGroup of the pods to the driver.
+	DelegateFSGroup = "delegate-fsgroup"
+	// SnapshotOwnershipRestore enables recording the ownership of the
+	// snapshotted volumes on their VolumeSnapshotContents, and applying it again on
+	// the first NodeStage of the volumes restored from them.
+	SnapshotOwnershipRestore = "snapshot-ownership-restore"
+	// MultiAttachDiagnostics enables reporting the nodes holding a volume,
+	// and whether they are detaching it, when the volume can't be attached
+	// as it is attached to another node.
+	MultiAttachDiagnostics = "multi-attach-diagnostics"
+	// RecentSnapshotProtection enables deferring the deletion of
+	// the volumes snapshotted within the recent snapshot window.
+	RecentSnapshotProtection = "recent-snapshot-protection"
 )
 
 var WCPFeatureStates = map[string]struct{}{
 	PodVMOnStretchedSupervisor: {},
+	WorkloadDomainIsolation:    {},
 }
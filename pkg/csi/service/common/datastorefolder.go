@@ -0,0 +1,178 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/task"
+	"github.com/vmware/govmomi/vim25/soap"
+	vim25types "github.com/vmware/govmomi/vim25/types"
+
+	cnsvolume "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	csifault "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/fault"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+// validateDatastoreFolder checks that the given datastore folder is a
+// relative path within the datastore.
+func validateDatastoreFolder(folder string) error {
+	if folder == "" || strings.HasPrefix(folder, "/") || strings.ContainsAny(folder, "[]\\") {
+		return fmt.Errorf("folder should be a relative path in the datastore")
+	}
+	for _, elem := range strings.Split(folder, "/") {
+		if elem == "" || elem == "." || elem == ".." {
+			return fmt.Errorf("folder should not contain empty, %q or %q path elements", ".", "..")
+		}
+	}
+	return nil
+}
+
+// prepareDatastoreFolderVolume creates the disk of the block volume of the
+// given spec in the datastore folder of its Storage Class, and updates the
+// CNS create spec to register the disk. If CNS already registered the volume,
+// in an earlier attempt, the volume is returned instead.
+func prepareDatastoreFolderVolume(ctx context.Context, vc *vsphere.VirtualCenter, volumeManager cnsvolume.Manager,
+	spec *CreateVolumeSpec, datastores []*vsphere.DatastoreInfo, clusterID string,
+	createSpec *cnstypes.CnsVolumeCreateSpec) (*cnsvolume.CnsVolumeInfo, string, error) {
+	log := logger.GetLogger(ctx)
+	if spec.ContentSourceSnapshotID != "" {
+		return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorf(log,
+			"param %q is not supported for volumes created from a snapshot", AttributeDatastoreFolder)
+	}
+	if len(datastores) == 0 {
+		return nil, csifault.CSIInternalFault, logger.LogNewErrorf(log,
+			"failed to find the datastore to place volume %q in folder %q", spec.Name, spec.ScParams.DatastoreFolder)
+	}
+	volumeInfo, err := GetVolumeByName(ctx, volumeManager, spec.Name, clusterID)
+	if err != nil {
+		return nil, csifault.CSIInternalFault, logger.LogNewErrorf(log,
+			"failed to query volume %q. Err: %v", spec.Name, err)
+	}
+	if volumeInfo != nil {
+		log.Infof("Volume %q is already registered with id %q", spec.Name, volumeInfo.VolumeID.Id)
+		return volumeInfo, "", nil
+	}
+	backingDiskURLPath, err := createDiskInDatastoreFolder(ctx, vc, datastores[0], spec.ScParams.DatastoreFolder,
+		spec.Name, spec.CapacityMB)
+	if err != nil {
+		return nil, csifault.CSIInternalFault, err
+	}
+	createSpec.Datastores = []vim25types.ManagedObjectReference{datastores[0].Reference()}
+	createSpec.BackingObjectDetails = &cnstypes.CnsBlockBackingDetails{
+		BackingDiskUrlPath: backingDiskURLPath,
+	}
+	return nil, "", nil
+}
+
+// GetVolumeByName returns the volume with the given name of the given
+// container cluster, if CNS already registered it. Volumes registered from an
+// existing disk, like the volumes placed in a datastore folder, are not
+// tracked by the CreateVolume idempotency of the volume manager.
+func GetVolumeByName(ctx context.Context, volumeManager cnsvolume.Manager,
+	name string, clusterID string) (*cnsvolume.CnsVolumeInfo, error) {
+	queryResult, err := volumeManager.QueryVolume(ctx, cnstypes.CnsQueryFilter{
+		Names:               []string{name},
+		ContainerClusterIds: []string{clusterID},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if queryResult == nil || len(queryResult.Volumes) == 0 {
+		return nil, nil
+	}
+	return &cnsvolume.CnsVolumeInfo{
+		DatastoreURL: queryResult.Volumes[0].DatastoreUrl,
+		VolumeID:     queryResult.Volumes[0].VolumeId,
+	}, nil
+}
+
+// createDiskInDatastoreFolder creates the virtual disk of a new block volume
+// in the given folder of the datastore, creating the folder if needed, and
+// returns the URL path of the disk to register it with CNS. The disk is named
+// after the volume, so a disk created by an earlier attempt is reused.
+func createDiskInDatastoreFolder(ctx context.Context, vc *vsphere.VirtualCenter, dsInfo *vsphere.DatastoreInfo,
+	folder string, name string, capacityMB int64) (string, error) {
+	log := logger.GetLogger(ctx)
+	if dsInfo.Datastore == nil || dsInfo.Datastore.Datacenter == nil {
+		return "", logger.LogNewErrorf(log, "failed to get the datacenter of datastore %q", dsInfo.Info.Url)
+	}
+	dc := dsInfo.Datastore.Datacenter.Datacenter
+	dsName := dsInfo.Info.Name
+	folderPath := fmt.Sprintf("[%s] %s", dsName, folder)
+	vmdkPath := path.Join(folder, name+".vmdk")
+
+	fileManager := object.NewFileManager(vc.Client.Client)
+	err := fileManager.MakeDirectory(ctx, folderPath, dc, true)
+	if err != nil && !IsFileAlreadyExistsError(err) {
+		return "", logger.LogNewErrorf(log, "failed to create folder %q. Err: %v", folderPath, err)
+	}
+
+	diskPath := fmt.Sprintf("[%s] %s", dsName, vmdkPath)
+	diskSpec := &vim25types.FileBackedVirtualDiskSpec{
+		VirtualDiskSpec: vim25types.VirtualDiskSpec{
+			AdapterType: string(vim25types.VirtualDiskAdapterTypeLsiLogic),
+			DiskType:    string(vim25types.VirtualDiskTypeThin),
+		},
+		CapacityKb: capacityMB * 1024,
+	}
+	virtualDiskManager := object.NewVirtualDiskManager(vc.Client.Client)
+	createTask, err := virtualDiskManager.CreateVirtualDisk(ctx, diskPath, dc, diskSpec)
+	if err == nil {
+		err = createTask.Wait(ctx)
+	}
+	if err != nil {
+		if !IsFileAlreadyExistsError(err) {
+			return "", logger.LogNewErrorf(log, "failed to create disk %q. Err: %v", diskPath, err)
+		}
+		log.Infof("Disk %q already exists, reusing it for volume %q", diskPath, name)
+	} else {
+		log.Infof("Created disk %q for volume %q", diskPath, name)
+	}
+
+	// Format:
+	// https://<vc_ip>/folder/<vmdk_path>?dcPath=<datacenter-path>&dsName=<datastoreName>
+	dcPath := strings.TrimPrefix(dc.InventoryPath, "/")
+	return "https://" + vc.Config.Host + "/folder/" + vmdkPath + "?dcPath=" + url.PathEscape(dcPath) +
+		"&dsName=" + url.PathEscape(dsName), nil
+}
+
+// IsFileAlreadyExistsError returns true if the given error is a
+// FileAlreadyExists fault.
+func IsFileAlreadyExistsError(err error) bool {
+	var fault vim25types.BaseMethodFault
+	if soap.IsSoapFault(err) {
+		fault, _ = soap.ToSoapFault(err).VimFault().(vim25types.BaseMethodFault)
+	} else if soap.IsVimFault(err) {
+		fault = soap.ToVimFault(err)
+	} else {
+		var taskErr task.Error
+		if errors.As(err, &taskErr) {
+			fault = taskErr.Fault()
+		}
+	}
+	_, ok := fault.(*vim25types.FileAlreadyExists)
+	return ok
+}
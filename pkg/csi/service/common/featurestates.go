@@ -0,0 +1,181 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// maxFeatureStateTypoDistance is the maximum edit distance between an
+// unknown feature state name and a known one for the unknown name to be
+// reported as a typo of the known one.
+const maxFeatureStateTypoDistance = 3
+
+// KnownFeatureStates is the catalog of the feature state switches which can
+// be set in the feature states ConfigMaps. New feature state switches must
+// be added to it, or they are reported as unknown when they are set.
+var KnownFeatureStates = map[string]struct{}{
+	VolumeHealth:                    {},
+	VolumeExtend:                    {},
+	OnlineVolumeExtend:              {},
+	CSIMigration:                    {},
+	AsyncQueryVolume:                {},
+	CSISVFeatureStateReplication:    {},
+	FileVolume:                      {},
+	FakeAttach:                      {},
+	TriggerCsiFullSync:              {},
+	CSIVolumeManagerIdempotency:     {},
+	BlockVolumeSnapshot:             {},
+	SiblingReplicaBoundPvcCheck:     {},
+	CSIWindowsSupport:               {},
+	TKGsHA:                          {},
+	ListVolumes:                     {},
+	PVtoBackingDiskObjectIdMapping:  {},
+	CnsMgrSuspendCreateVolume:       {},
+	TopologyPreferentialDatastores:  {},
+	MaxPVSCSITargetsPerVM:           {},
+	MultiVCenterCSITopology:         {},
+	CSIInternalGeneratedClusterID:   {},
+	ListViewPerf:                    {},
+	TopologyAwareFileVolume:         {},
+	StorageQuotaM2:                  {},
+	VdppOnStretchedSupervisor:       {},
+	VolumeIOStats:                   {},
+	StaticPVNodeAffinity:            {},
+	NodeLocalVolumes:                {},
+	PodVMAttachBatching:             {},
+	DetachProtection:                {},
+	ArchiveReclaim:                  {},
+	VolumeOperationRequestDebug:     {},
+	SnapshotCascadeDelete:           {},
+	OutOfBandResizeSync:             {},
+	DatastoreURLReconcile:           {},
+	DriverCapabilities:              {},
+	StoragePolicyStorageClassMapper: {},
+	StoragePolicyPrecheck:           {},
+	VCenterPrivilegeReport:          {},
+	AuthRefreshOnPermissionChange:   {},
+	FullSyncConflictResolution:      {},
+	VolumePolicyMigration:           {},
+	ProvisioningCancellation:        {},
+	VolumeDeletionProtection:        {},
+	ProvisioningPolicyHook:          {},
+	SystemResourceProtection:        {},
+	RestoreDatastorePinning:         {},
+	CrossClassSnapshotRestore:       {},
+	SnapshotHooks:                   {},
+	SnapshotExport:                  {},
+	SnapshotChangedBlockTracking:    {},
+	FakeAttachRecovery:              {},
+	NodePluginVersionCheck:          {},
+	CnsNodeInfo:                     {},
+	AdaptiveQueryBatchSize:          {},
+	ResumableFullSync:               {},
+	CSIDriverConfig:                 {},
+	VCenterEventReporter:            {},
+	DatastoreUsageAlarms:            {},
+	DiskSlotValidation:              {},
+	CrossVCenterClone:               {},
+	ProvisioningSegmentBackoff:      {},
+	PVAccessibilityReporting:        {},
+	StaleVolumeAttachmentGC:         {},
+	ProvisioningDryRun:              {},
+	DiskFormatConversion:            {},
+	RelocatedPVNodeAffinity:         {},
+	StructuredCSIErrors:             {},
+	ProvisioningPrecheck:            {},
+	ControllerReadOnlyMode:          {},
+	DatastorePropertyCache:          {},
+	UsageReporting:                  {},
+	NodeIOThrottling:                {},
+	SELinuxMount:                    {},
+	ReadWriteOncePod:                {},
+	DelegateFSGroup:                 {},
+	SnapshotOwnershipRestore:        {},
+	MultiAttachDiagnostics:          {},
+	RecentSnapshotProtection:        {},
+}
+
+// ValidateFeatureStates checks the given content of a feature states
+// ConfigMap against KnownFeatureStates, and returns a description of each
+// unknown feature state name and non boolean value, sorted by name.
+func ValidateFeatureStates(featureStates map[string]string) []string {
+	names := make([]string, 0, len(featureStates))
+	for name := range featureStates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var problems []string
+	for _, name := range names {
+		if _, known := KnownFeatureStates[name]; !known {
+			if suggestion := closestFeatureState(name); suggestion != "" {
+				problems = append(problems, fmt.Sprintf("unknown feature state %q, did you mean %q?",
+					name, suggestion))
+			} else {
+				problems = append(problems, fmt.Sprintf("unknown feature state %q", name))
+			}
+			continue
+		}
+		if _, err := strconv.ParseBool(featureStates[name]); err != nil {
+			problems = append(problems, fmt.Sprintf("feature state %q has the non boolean value %q, "+
+				"it is evaluated to false", name, featureStates[name]))
+		}
+	}
+	return problems
+}
+
+// closestFeatureState returns the known feature state name closest to the
+// given unknown name, or an empty string if none is close enough for the
+// unknown name to be a typo.
+func closestFeatureState(name string) string {
+	closest := ""
+	closestDistance := maxFeatureStateTypoDistance + 1
+	for known := range KnownFeatureStates {
+		distance := editDistance(name, known)
+		if distance < closestDistance || (distance == closestDistance && known < closest) {
+			closest = known
+			closestDistance = distance
+		}
+	}
+	if closestDistance > maxFeatureStateTypoDistance {
+		return ""
+	}
+	return closest
+}
+
+// editDistance returns the Levenshtein distance between the given strings.
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
@@ -0,0 +1,43 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateFeatureStates(t *testing.T) {
+	problems := ValidateFeatureStates(map[string]string{
+		BlockVolumeSnapshot:    "true",
+		ListVolumes:            "false",
+		"blck-volume-snapshot": "true",
+		"unrelated-feature":    "true",
+		VolumeHealth:           "yes",
+	})
+	expected := []string{
+		`unknown feature state "blck-volume-snapshot", did you mean "block-volume-snapshot"?`,
+		`unknown feature state "unrelated-feature"`,
+		`feature state "volume-health" has the non boolean value "yes", it is evaluated to false`,
+	}
+	if !reflect.DeepEqual(problems, expected) {
+		t.Errorf("ValidateFeatureStates returned %q, expected %q", problems, expected)
+	}
+	if problems := ValidateFeatureStates(map[string]string{FileVolume: "true"}); len(problems) != 0 {
+		t.Errorf("ValidateFeatureStates returned %q for valid feature states", problems)
+	}
+}
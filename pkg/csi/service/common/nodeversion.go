@@ -0,0 +1,47 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"github.com/hashicorp/go-version"
+
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+)
+
+// NodePluginVersionAnnotation returns the key of the annotation on the Node
+// objects holding the version of the node plugin of the driver running on
+// the node.
+func NodePluginVersionAnnotation() string {
+	return csitypes.DriverName() + "/node-plugin-version"
+}
+
+// IsNodePluginOlderThan returns true if the node plugin with the given
+// version doesn't provide the features introduced in minVersion. A node
+// plugin which doesn't publish its version predates the version checks and
+// is older than any version. Pre-release and build suffixes are ignored, and
+// a version which can't be parsed, as the one of a development build, is
+// assumed to be recent.
+func IsNodePluginOlderThan(nodePluginVersion, minVersion string) bool {
+	if nodePluginVersion == "" {
+		return true
+	}
+	nodeVersion, err := version.NewVersion(nodePluginVersion)
+	if err != nil {
+		return false
+	}
+	return nodeVersion.Core().LessThan(version.Must(version.NewVersion(minVersion)))
+}
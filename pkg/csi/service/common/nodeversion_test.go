@@ -0,0 +1,40 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import "testing"
+
+func TestIsNodePluginOlderThan(t *testing.T) {
+	tests := []struct {
+		nodePluginVersion string
+		minVersion        string
+		expected          bool
+	}{
+		{"", "v2.0.0", true},
+		{"v2.2.1", "v2.3.0", true},
+		{"v2.3.0", "v2.3.0", false},
+		{"v3.3.0-rc.1", "v3.3.0", false},
+		{"v3.2.0-12-g1a2b3c4", "v3.2.0", false},
+		{"dev", "v2.3.0", false},
+	}
+	for _, test := range tests {
+		if older := IsNodePluginOlderThan(test.nodePluginVersion, test.minVersion); older != test.expected {
+			t.Errorf("IsNodePluginOlderThan(%q, %q) = %v, expected %v", test.nodePluginVersion,
+				test.minVersion, older, test.expected)
+		}
+	}
+}
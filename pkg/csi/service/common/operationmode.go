@@ -0,0 +1,158 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// OperationMode is the mode a vSphere CSI driver or syncer container runs in.
+type OperationMode string
+
+const (
+	// OperationModeController runs the CSI controller service.
+	OperationModeController OperationMode = "CONTROLLER"
+	// OperationModeNode runs the CSI node service.
+	OperationModeNode OperationMode = "NODE"
+	// OperationModeWebHookServer runs the admission webhook server.
+	OperationModeWebHookServer OperationMode = "WEBHOOK_SERVER"
+	// OperationModeMetaDataSync runs the metadata syncer and the operators
+	// of the syncer container.
+	OperationModeMetaDataSync OperationMode = "METADATA_SYNC"
+	// OperationModeMetaDataSyncOnly only runs the metadata syncer, which
+	// includes the volume health monitoring. It allows to run the metadata
+	// sync of large clusters in a dedicated deployment, scaled independently
+	// of the controller deployment, whose syncer then runs in
+	// OperationModeCnsOperatorOnly.
+	OperationModeMetaDataSyncOnly OperationMode = "METADATA_SYNC_ONLY"
+	// OperationModeCnsOperatorOnly only runs the operators of the syncer
+	// container, leaving the metadata sync to a container running in
+	// OperationModeMetaDataSyncOnly.
+	OperationModeCnsOperatorOnly OperationMode = "CNS_OPERATOR_ONLY"
+	// OperationModeDataMover runs the data mover uploading the content of a
+	// volume snapshot to a bucket, and exits. It is the mode of the jobs
+	// created by the CnsSnapshotExport controller.
+	OperationModeDataMover OperationMode = "DATA_MOVER"
+	// OperationModeMultiVCMigration runs the migration of the volumes of a
+	// single vCenter cluster to a multi vCenter configuration, and exits.
+	OperationModeMultiVCMigration OperationMode = "MULTI_VC_MIGRATION"
+)
+
+// OperationModeSubsystems describes the subsystems an operation mode needs.
+// Subsystems which are not needed by the mode of a container are neither
+// initialized nor started in it.
+type OperationModeSubsystems struct {
+	// CSIServer serves the CSI gRPC services on the CSI endpoint.
+	CSIServer bool
+	// VolumeMaps builds the volume handle to PVC, volume name to nodes and
+	// node ID to node name maps, and the informers keeping them up to date.
+	VolumeMaps bool
+	// WebhookServer serves the admission webhooks.
+	WebhookServer bool
+	// MetadataSync runs the CNS metadata syncer.
+	MetadataSync bool
+	// Operators runs the CNS operator controllers and the storage pool
+	// service.
+	Operators bool
+	// DataMover runs the data mover of a CnsSnapshotExport job.
+	DataMover bool
+	// MultiVCMigration runs the migration to a multi vCenter configuration.
+	MultiVCMigration bool
+}
+
+var (
+	operationModes = map[OperationMode]OperationModeSubsystems{
+		OperationModeController: {
+			CSIServer:  true,
+			VolumeMaps: true,
+		},
+		OperationModeNode: {
+			CSIServer:  true,
+			VolumeMaps: true,
+		},
+		OperationModeWebHookServer: {
+			WebhookServer: true,
+		},
+		OperationModeMetaDataSync: {
+			VolumeMaps:   true,
+			MetadataSync: true,
+			Operators:    true,
+		},
+		OperationModeMetaDataSyncOnly: {
+			VolumeMaps:   true,
+			MetadataSync: true,
+		},
+		OperationModeCnsOperatorOnly: {
+			VolumeMaps: true,
+			Operators:  true,
+		},
+		OperationModeDataMover: {
+			DataMover: true,
+		},
+		OperationModeMultiVCMigration: {
+			MultiVCMigration: true,
+		},
+	}
+	operationModesLock sync.RWMutex
+)
+
+// RegisterOperationMode registers a new operation mode with the subsystems it
+// needs. It returns an error if the mode is already registered.
+func RegisterOperationMode(mode OperationMode, subsystems OperationModeSubsystems) error {
+	operationModesLock.Lock()
+	defer operationModesLock.Unlock()
+	if _, exists := operationModes[mode]; exists {
+		return fmt.Errorf("operation mode %q is already registered", mode)
+	}
+	operationModes[mode] = subsystems
+	return nil
+}
+
+// GetOperationModeSubsystems returns the subsystems needed by the given
+// operation mode. An empty mode is the mode of the CSI driver containers,
+// which is derived from the CSI service mode.
+func GetOperationModeSubsystems(mode string, serviceMode string) (OperationModeSubsystems, error) {
+	if mode == "" {
+		mode = string(OperationModeController)
+		if serviceMode == "node" {
+			mode = string(OperationModeNode)
+		}
+	}
+	operationModesLock.RLock()
+	defer operationModesLock.RUnlock()
+	subsystems, exists := operationModes[OperationMode(mode)]
+	if !exists {
+		return OperationModeSubsystems{}, fmt.Errorf("unsupported operation mode: %q", mode)
+	}
+	return subsystems, nil
+}
+
+// RegisteredOperationModes returns the sorted, comma separated list of the
+// registered operation modes.
+func RegisteredOperationModes() string {
+	operationModesLock.RLock()
+	defer operationModesLock.RUnlock()
+	modes := make([]string, 0, len(operationModes))
+	for mode := range operationModes {
+		modes = append(modes, string(mode))
+	}
+	sort.Strings(modes)
+	return strings.Join(modes, ", ")
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetOperationModeSubsystems(t *testing.T) {
+	subsystems, err := GetOperationModeSubsystems("", "node")
+	assert.NoError(t, err)
+	assert.Equal(t, operationModes[OperationModeNode], subsystems)
+
+	subsystems, err = GetOperationModeSubsystems("", "controller")
+	assert.NoError(t, err)
+	assert.True(t, subsystems.CSIServer)
+
+	subsystems, err = GetOperationModeSubsystems(string(OperationModeWebHookServer), "")
+	assert.NoError(t, err)
+	assert.True(t, subsystems.WebhookServer)
+	assert.False(t, subsystems.VolumeMaps)
+
+	_, err = GetOperationModeSubsystems("UNKNOWN", "")
+	assert.Error(t, err)
+}
+
+func TestRegisterOperationMode(t *testing.T) {
+	const mode OperationMode = "TEST_MODE"
+	defer func() {
+		operationModesLock.Lock()
+		delete(operationModes, mode)
+		operationModesLock.Unlock()
+	}()
+	assert.NoError(t, RegisterOperationMode(mode, OperationModeSubsystems{MetadataSync: true}))
+	assert.Error(t, RegisterOperationMode(mode, OperationModeSubsystems{}))
+	subsystems, err := GetOperationModeSubsystems(string(mode), "")
+	assert.NoError(t, err)
+	assert.True(t, subsystems.MetadataSync)
+	assert.Contains(t, RegisteredOperationModes(), string(mode))
+}
@@ -0,0 +1,288 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package placementengine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/vmware/govmomi/event"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/mo"
+	vimtypes "github.com/vmware/govmomi/vim25/types"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
+)
+
+const (
+	// datastoreUsageAlarmSystemName is the system name of the default
+	// "Datastore usage on disk" alarm of vCenter.
+	datastoreUsageAlarmSystemName = "alarm.DatastoreDiskUsageAlarm"
+	// datastoreAlarmRetryInterval is the delay before watching the alarms of
+	// vCenter again after the watch failed.
+	datastoreAlarmRetryInterval = time.Minute
+	// datastoreAlarmEventPageSize is the page size of the event collector.
+	datastoreAlarmEventPageSize = 100
+
+	eventReasonDatastoreDeprioritized = "DatastoreDeprioritized"
+	eventReasonDatastoreReprioritized = "DatastoreReprioritized"
+)
+
+var (
+	// datastoreAlarmsLock protects datastoreAlarms.
+	datastoreAlarmsLock sync.RWMutex
+	// datastoreAlarms holds the status, yellow or red, of the triggered usage
+	// alarms keyed by vCenter host and datastore MoRef value.
+	datastoreAlarms = make(map[string]map[string]vimtypes.ManagedEntityStatus)
+
+	datastoreAlarmRecorderOnce sync.Once
+	datastoreAlarmRecorder     record.EventRecorder
+)
+
+// WatchDatastoreUsageAlarms tracks the datastores of the given vCenter with
+// a triggered usage alarm, so that they are deprioritized for new volumes as
+// soon as they fill up instead of at the next refresh of the datastore
+// capacity.
+func WatchDatastoreUsageAlarms(vc *cnsvsphere.VirtualCenter) {
+	ctx, log := logger.GetNewContextWithLogger()
+	log.Infof("WatchDatastoreUsageAlarms entered for vCenter %q", vc.Config.Host)
+	for {
+		err := watchDatastoreUsageAlarms(ctx, vc)
+		log.Warnf("watch of datastore usage alarms for vCenter %q exited, restarting in %v. Err: %v",
+			vc.Config.Host, datastoreAlarmRetryInterval, err)
+		time.Sleep(datastoreAlarmRetryInterval)
+	}
+}
+
+// watchDatastoreUsageAlarms loads the currently triggered usage alarms of
+// the datastores and then tails the alarm status changes of vCenter, until
+// the watch fails.
+func watchDatastoreUsageAlarms(ctx context.Context, vc *cnsvsphere.VirtualCenter) error {
+	log := logger.GetLogger(ctx)
+	if err := vc.Connect(ctx); err != nil {
+		return err
+	}
+	since := time.Now()
+	if now, err := methods.GetCurrentTime(ctx, vc.Client.Client); err == nil && now != nil {
+		since = *now
+	}
+	// usageAlarms caches whether an alarm MoRef is the datastore usage alarm.
+	usageAlarms := make(map[vimtypes.ManagedObjectReference]bool)
+	isUsageAlarm := func(alarm vimtypes.ManagedObjectReference) (bool, error) {
+		if isUsage, ok := usageAlarms[alarm]; ok {
+			return isUsage, nil
+		}
+		var alarmMo mo.Alarm
+		err := property.DefaultCollector(vc.Client.Client).RetrieveOne(ctx, alarm, []string{"info"}, &alarmMo)
+		if err != nil {
+			return false, err
+		}
+		usageAlarms[alarm] = alarmMo.Info.SystemName == datastoreUsageAlarmSystemName
+		return usageAlarms[alarm], nil
+	}
+
+	// Seed the triggered alarms, as the alarm status changes collected below
+	// only start from now.
+	viewManager := view.NewManager(vc.Client.Client)
+	containerView, err := viewManager.CreateContainerView(ctx, vc.Client.ServiceContent.RootFolder,
+		[]string{"Datastore"}, true)
+	if err != nil {
+		return err
+	}
+	var dsMos []mo.Datastore
+	err = containerView.Retrieve(ctx, []string{"Datastore"}, []string{"name", "triggeredAlarmState"}, &dsMos)
+	if destroyErr := containerView.Destroy(ctx); destroyErr != nil {
+		log.Debugf("failed to destroy container view of vCenter %q. Err: %v", vc.Config.Host, destroyErr)
+	}
+	if err != nil {
+		return err
+	}
+	for _, dsMo := range dsMos {
+		status := vimtypes.ManagedEntityStatusGreen
+		for _, state := range dsMo.TriggeredAlarmState {
+			isUsage, err := isUsageAlarm(state.Alarm)
+			if err != nil {
+				return err
+			}
+			if isUsage && alarmSeverity(state.OverallStatus) > alarmSeverity(status) {
+				status = state.OverallStatus
+			}
+		}
+		setDatastoreAlarmStatus(ctx, vc.Config.Host, dsMo.Reference().Value, dsMo.Name, status)
+	}
+
+	eventManager := event.NewManager(vc.Client.Client)
+	return eventManager.Events(ctx, []vimtypes.ManagedObjectReference{vc.Client.ServiceContent.RootFolder},
+		datastoreAlarmEventPageSize, true, false,
+		func(_ vimtypes.ManagedObjectReference, events []vimtypes.BaseEvent) error {
+			for _, e := range events {
+				alarmEvent, ok := e.(*vimtypes.AlarmStatusChangedEvent)
+				if !ok || alarmEvent.CreatedTime.Before(since) {
+					continue
+				}
+				dsRef, dsName := alarmEvent.Source.Entity, alarmEvent.Source.Name
+				if dsRef.Type != "Datastore" {
+					if alarmEvent.Ds == nil {
+						continue
+					}
+					dsRef, dsName = alarmEvent.Ds.Datastore, alarmEvent.Ds.Name
+				}
+				isUsage, err := isUsageAlarm(alarmEvent.Alarm.Alarm)
+				if err != nil {
+					log.Warnf("failed to get alarm %v of vCenter %q. Err: %v",
+						alarmEvent.Alarm.Alarm, vc.Config.Host, err)
+					continue
+				}
+				if !isUsage {
+					continue
+				}
+				setDatastoreAlarmStatus(ctx, vc.Config.Host, dsRef.Value, dsName,
+					vimtypes.ManagedEntityStatus(alarmEvent.To))
+			}
+			return nil
+		}, "AlarmStatusChangedEvent")
+}
+
+// alarmSeverity orders the alarm statuses, gray and green meaning the alarm
+// is not triggered.
+func alarmSeverity(status vimtypes.ManagedEntityStatus) int {
+	switch status {
+	case vimtypes.ManagedEntityStatusYellow:
+		return 1
+	case vimtypes.ManagedEntityStatusRed:
+		return 2
+	}
+	return 0
+}
+
+// setDatastoreAlarmStatus records the usage alarm status of a datastore and
+// reports the datastores entering or leaving the deprioritized state.
+func setDatastoreAlarmStatus(ctx context.Context, vcHost, dsMoRef, dsName string,
+	status vimtypes.ManagedEntityStatus) {
+	log := logger.GetLogger(ctx)
+	severity := alarmSeverity(status)
+	datastoreAlarmsLock.Lock()
+	previous := alarmSeverity(datastoreAlarms[vcHost][dsMoRef])
+	if severity == 0 {
+		delete(datastoreAlarms[vcHost], dsMoRef)
+	} else {
+		if datastoreAlarms[vcHost] == nil {
+			datastoreAlarms[vcHost] = make(map[string]vimtypes.ManagedEntityStatus)
+		}
+		datastoreAlarms[vcHost][dsMoRef] = status
+	}
+	datastoreAlarmsLock.Unlock()
+	if severity == previous {
+		return
+	}
+	prometheus.DatastoreUsageAlarmGaugeVec.WithLabelValues(vcHost, dsName).Set(float64(severity))
+	if severity == 0 {
+		log.Infof("Usage alarm of datastore %q on vCenter %q cleared, using it again for new volumes",
+			dsName, vcHost)
+		recordDatastoreAlarmEvent(ctx, dsName, v1.EventTypeNormal, eventReasonDatastoreReprioritized,
+			fmt.Sprintf("usage alarm of datastore %q on vCenter %q cleared", dsName, vcHost))
+		return
+	}
+	log.Infof("Usage alarm of datastore %q on vCenter %q is %s, deprioritizing it for new volumes",
+		dsName, vcHost, status)
+	recordDatastoreAlarmEvent(ctx, dsName, v1.EventTypeWarning, eventReasonDatastoreDeprioritized,
+		fmt.Sprintf("usage alarm of datastore %q on vCenter %q is %s, deprioritizing it for new volumes",
+			dsName, vcHost, status))
+}
+
+// recordDatastoreAlarmEvent records an event about a datastore in the
+// namespace of the driver. Failures are only logged, as events are best
+// effort.
+func recordDatastoreAlarmEvent(ctx context.Context, dsName, eventType, reason, message string) {
+	log := logger.GetLogger(ctx)
+	datastoreAlarmRecorderOnce.Do(func() {
+		k8sClient, err := k8s.NewClient(ctx)
+		if err != nil {
+			log.Warnf("failed to create kubernetes client to record datastore events. Err: %v", err)
+			return
+		}
+		eventBroadcaster := record.NewBroadcaster()
+		eventBroadcaster.StartRecordingToSink(
+			&typedcorev1.EventSinkImpl{
+				Interface: k8sClient.CoreV1().Events(""),
+			},
+		)
+		datastoreAlarmRecorder = eventBroadcaster.NewRecorder(scheme.Scheme,
+			v1.EventSource{Component: csitypes.DriverName()})
+	})
+	if datastoreAlarmRecorder == nil {
+		return
+	}
+	datastoreAlarmRecorder.Event(&v1.ObjectReference{
+		Kind:      "Datastore",
+		Name:      dsName,
+		Namespace: common.GetCSINamespace(),
+	}, eventType, reason, message)
+}
+
+// deprioritizeAlarmedDatastores drops the datastores of the given vCenter
+// with a triggered usage alarm. Datastores with a yellow alarm are kept if
+// every datastore has an alarm, and all the datastores are kept if every
+// one of them has a red alarm, so that provisioning is never blocked by
+// alarms alone.
+func deprioritizeAlarmedDatastores(ctx context.Context, vcHost string,
+	datastores []*cnsvsphere.DatastoreInfo) []*cnsvsphere.DatastoreInfo {
+	log := logger.GetLogger(ctx)
+	datastoreAlarmsLock.RLock()
+	alarms := datastoreAlarms[vcHost]
+	severities := make([]int, len(datastores))
+	for i, ds := range datastores {
+		severities[i] = alarmSeverity(alarms[ds.Datastore.Reference().Value])
+	}
+	datastoreAlarmsLock.RUnlock()
+
+	// Only keep the datastores with the lowest alarm severity.
+	maxSeverity := alarmSeverity(vimtypes.ManagedEntityStatusRed)
+	for _, severity := range severities {
+		maxSeverity = min(maxSeverity, severity)
+	}
+	if maxSeverity == alarmSeverity(vimtypes.ManagedEntityStatusRed) {
+		if len(datastores) != 0 {
+			log.Infof("All datastores have a red usage alarm on vCenter %q, ignoring alarms for placement",
+				vcHost)
+		}
+		return datastores
+	}
+	var filtered []*cnsvsphere.DatastoreInfo
+	for i, ds := range datastores {
+		if severities[i] > maxSeverity {
+			log.Infof("Skipping datastore %q with a triggered usage alarm", ds.Info.Url)
+			prometheus.DatastoreUsageAlarmSkipsCounter.WithLabelValues(vcHost, ds.Info.Name).Inc()
+			continue
+		}
+		filtered = append(filtered, ds)
+	}
+	return filtered
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package placementengine
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/vmware/govmomi/performance"
+	vimtypes "github.com/vmware/govmomi/vim25/types"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+const (
+	// Host level datastore latency counters. The instance of each series is
+	// the datastore UUID, which is the last element of the datastore URL.
+	perfCounterDatastoreReadLatency  = "datastore.totalReadLatency.average"
+	perfCounterDatastoreWriteLatency = "datastore.totalWriteLatency.average"
+	// realtimePerfInterval is the sampling interval of realtime host statistics.
+	realtimePerfInterval = 20
+	// latencySamples is the number of realtime samples, i.e. the last 5 minutes,
+	// averaged to compute the recent latency of a datastore.
+	latencySamples = 15
+)
+
+// FilterDatastoresByLatency drops the datastores whose recent average read
+// or write latency, as seen by the hosts of any of the given node VMs, is
+// above thresholdInMs. It is used to apply the latency threshold when the
+// datastores aren't selected per topology segment by GetSharedDatastores.
+func FilterDatastoresByLatency(ctx context.Context, vc *cnsvsphere.VirtualCenter,
+	nodeVMs []*cnsvsphere.VirtualMachine, datastores []*cnsvsphere.DatastoreInfo,
+	thresholdInMs int) []*cnsvsphere.DatastoreInfo {
+	log := logger.GetLogger(ctx)
+	var hosts []*cnsvsphere.HostSystem
+	seen := make(map[string]bool)
+	for _, vm := range nodeVMs {
+		host, err := vm.GetHostSystem(ctx)
+		if err != nil {
+			log.Warnf("failed to get the host of node VM %v, ignoring it for latency based filtering. Err: %v",
+				vm, err)
+			continue
+		}
+		if seen[host.Reference().Value] {
+			continue
+		}
+		seen[host.Reference().Value] = true
+		hosts = append(hosts, &cnsvsphere.HostSystem{HostSystem: host})
+	}
+	return filterDatastoresByLatency(ctx, vc, hosts, datastores, thresholdInMs)
+}
+
+// filterDatastoresByLatency drops the datastores whose recent average read
+// or write latency, as seen by any of the given hosts, is above thresholdInMs.
+// Latency is only used to rank otherwise eligible datastores, so the input
+// list is returned as is if latency can't be fetched or if every datastore
+// is above the threshold.
+func filterDatastoresByLatency(ctx context.Context, vc *cnsvsphere.VirtualCenter, hosts []*cnsvsphere.HostSystem,
+	datastores []*cnsvsphere.DatastoreInfo, thresholdInMs int) []*cnsvsphere.DatastoreInfo {
+	log := logger.GetLogger(ctx)
+	if len(hosts) == 0 || len(datastores) == 0 {
+		return datastores
+	}
+	latencies, err := getDatastoreLatencies(ctx, vc, hosts)
+	if err != nil {
+		log.Warnf("failed to get datastore latencies from vCenter %q, skipping latency based filtering. Err: %v",
+			vc.Config.Host, err)
+		return datastores
+	}
+	return filterDatastoresByLatencies(ctx, datastores, latencies, thresholdInMs)
+}
+
+// filterDatastoresByLatencies drops the datastores whose latency in the
+// given latencies, keyed by datastore UUID, is above thresholdInMs. The
+// datastores without a latency are kept. The input list is returned as is if
+// every datastore is above the threshold.
+func filterDatastoresByLatencies(ctx context.Context, datastores []*cnsvsphere.DatastoreInfo,
+	latencies map[string]float64, thresholdInMs int) []*cnsvsphere.DatastoreInfo {
+	log := logger.GetLogger(ctx)
+	var filtered []*cnsvsphere.DatastoreInfo
+	for _, ds := range datastores {
+		latency, ok := latencies[datastoreUUIDFromURL(ds.Info.Url)]
+		if ok && latency > float64(thresholdInMs) {
+			log.Infof("Skipping datastore %q with recent latency %.1fms above threshold %dms",
+				ds.Info.Url, latency, thresholdInMs)
+			continue
+		}
+		filtered = append(filtered, ds)
+	}
+	if len(filtered) == 0 {
+		log.Infof("All datastores are above the latency threshold %dms, ignoring latency for placement",
+			thresholdInMs)
+		return datastores
+	}
+	return filtered
+}
+
+// getDatastoreLatencies returns the highest recent average latency in
+// milliseconds reported by the hosts for each datastore, keyed by datastore
+// UUID.
+func getDatastoreLatencies(ctx context.Context, vc *cnsvsphere.VirtualCenter,
+	hosts []*cnsvsphere.HostSystem) (map[string]float64, error) {
+	hostRefs := make([]vimtypes.ManagedObjectReference, 0, len(hosts))
+	for _, host := range hosts {
+		hostRefs = append(hostRefs, host.Reference())
+	}
+	perfManager := performance.NewManager(vc.Client.Client)
+	spec := vimtypes.PerfQuerySpec{
+		MaxSample:  latencySamples,
+		IntervalId: realtimePerfInterval,
+		MetricId:   []vimtypes.PerfMetricId{{Instance: "*"}},
+	}
+	sample, err := perfManager.SampleByName(ctx, spec,
+		[]string{perfCounterDatastoreReadLatency, perfCounterDatastoreWriteLatency}, hostRefs)
+	if err != nil {
+		return nil, err
+	}
+	result, err := perfManager.ToMetricSeries(ctx, sample)
+	if err != nil {
+		return nil, err
+	}
+	latencies := make(map[string]float64)
+	for _, entityMetric := range result {
+		for _, series := range entityMetric.Value {
+			if series.Instance == "" || len(series.Value) == 0 {
+				continue
+			}
+			var sum int64
+			for _, v := range series.Value {
+				sum += v
+			}
+			avg := float64(sum) / float64(len(series.Value))
+			if avg > latencies[series.Instance] {
+				latencies[series.Instance] = avg
+			}
+		}
+	}
+	return latencies, nil
+}
+
+// datastoreUUIDFromURL returns the datastore UUID from a datastore URL like
+// "ds:///vmfs/volumes/5f3a8e2c-1f2b3c4d-1234-005056a1b2c3/".
+func datastoreUUIDFromURL(url string) string {
+	return path.Base(strings.TrimSuffix(url, "/"))
+}
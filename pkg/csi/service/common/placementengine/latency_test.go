@@ -0,0 +1,101 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package placementengine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	vimtypes "github.com/vmware/govmomi/vim25/types"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+)
+
+func newLatencyTestDatastore(url string) *cnsvsphere.DatastoreInfo {
+	return &cnsvsphere.DatastoreInfo{Info: &vimtypes.DatastoreInfo{Url: url}}
+}
+
+func datastoreURLs(datastores []*cnsvsphere.DatastoreInfo) []string {
+	var urls []string
+	for _, ds := range datastores {
+		urls = append(urls, ds.Info.Url)
+	}
+	return urls
+}
+
+func TestDatastoreUUIDFromURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{url: "ds:///vmfs/volumes/5f3a8e2c-1f2b3c4d-1234-005056a1b2c3/", expected: "5f3a8e2c-1f2b3c4d-1234-005056a1b2c3"},
+		{url: "ds:///vmfs/volumes/vsan:52a1b2c3d4e5f607-8192a3b4c5d6e7f8/",
+			expected: "vsan:52a1b2c3d4e5f607-8192a3b4c5d6e7f8"},
+		{url: "ds:///vmfs/volumes/5f3a8e2c-1f2b3c4d", expected: "5f3a8e2c-1f2b3c4d"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, datastoreUUIDFromURL(test.url), test.url)
+	}
+}
+
+func TestFilterDatastoresByLatencies(t *testing.T) {
+	ctx := context.Background()
+	fast := newLatencyTestDatastore("ds:///vmfs/volumes/fast/")
+	slow := newLatencyTestDatastore("ds:///vmfs/volumes/slow/")
+	atThreshold := newLatencyTestDatastore("ds:///vmfs/volumes/at-threshold/")
+	unknown := newLatencyTestDatastore("ds:///vmfs/volumes/unknown/")
+	latencies := map[string]float64{
+		"fast":         2,
+		"slow":         45.5,
+		"at-threshold": 20,
+	}
+
+	tests := []struct {
+		name       string
+		datastores []*cnsvsphere.DatastoreInfo
+		expected   []string
+	}{
+		{
+			name:       "datastores above the threshold are skipped",
+			datastores: []*cnsvsphere.DatastoreInfo{fast, slow, atThreshold},
+			expected:   []string{fast.Info.Url, atThreshold.Info.Url},
+		},
+		{
+			name:       "datastores without latency are kept",
+			datastores: []*cnsvsphere.DatastoreInfo{slow, unknown},
+			expected:   []string{unknown.Info.Url},
+		},
+		{
+			name:       "all datastores above the threshold are kept",
+			datastores: []*cnsvsphere.DatastoreInfo{slow},
+			expected:   []string{slow.Info.Url},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected,
+				datastoreURLs(filterDatastoresByLatencies(ctx, test.datastores, latencies, 20)))
+		})
+	}
+}
+
+func TestFilterDatastoresByLatencyWithoutHosts(t *testing.T) {
+	datastores := []*cnsvsphere.DatastoreInfo{newLatencyTestDatastore("ds:///vmfs/volumes/slow/")}
+	assert.Equal(t, datastores, filterDatastoresByLatency(context.Background(), nil, nil, datastores, 20))
+	assert.Equal(t, datastores, FilterDatastoresByLatency(context.Background(), nil, nil, datastores, 20))
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package placementengine
+
+import (
+	"context"
+
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	vimtypes "github.com/vmware/govmomi/vim25/types"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+// filterNodeLocalDatastores returns the datastores which are mounted by
+// exactly one host, like vSAN Direct disks or local VMFS datastores.
+func filterNodeLocalDatastores(ctx context.Context, vc *cnsvsphere.VirtualCenter,
+	datastores []*cnsvsphere.DatastoreInfo) ([]*cnsvsphere.DatastoreInfo, error) {
+	log := logger.GetLogger(ctx)
+	if len(datastores) == 0 {
+		return nil, nil
+	}
+	dsRefs := make([]vimtypes.ManagedObjectReference, 0, len(datastores))
+	for _, ds := range datastores {
+		dsRefs = append(dsRefs, ds.Reference())
+	}
+	var dsMos []mo.Datastore
+	pc := property.DefaultCollector(vc.Client.Client)
+	if err := pc.Retrieve(ctx, dsRefs, []string{"host"}, &dsMos); err != nil {
+		return nil, err
+	}
+	localDatastores := make(map[string]struct{})
+	for _, dsMo := range dsMos {
+		if len(dsMo.Host) == 1 {
+			localDatastores[dsMo.Reference().Value] = struct{}{}
+		}
+	}
+	var filtered []*cnsvsphere.DatastoreInfo
+	for _, ds := range datastores {
+		if _, ok := localDatastores[ds.Reference().Value]; ok {
+			filtered = append(filtered, ds)
+		}
+	}
+	log.Debugf("Host local datastores: %+v", filtered)
+	return filtered, nil
+}
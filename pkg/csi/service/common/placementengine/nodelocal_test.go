@@ -0,0 +1,87 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package placementengine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	vimtypes "github.com/vmware/govmomi/vim25/types"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+)
+
+func TestFilterNodeLocalDatastores(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		vc := &cnsvsphere.VirtualCenter{
+			Config: &cnsvsphere.VirtualCenterConfig{Host: "vcsim"},
+			Client: &govmomi.Client{Client: c},
+		}
+		finder := find.NewFinder(c)
+		dc, err := finder.DefaultDatacenter(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		finder.SetDatacenter(dc)
+		// LocalDS_0 is mounted by all the hosts of the default inventory, but
+		// vcsim only reports the mount of the first one.
+		shared, err := finder.Datastore(ctx, "LocalDS_0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sharedDS := simulator.Map.Get(shared.Reference()).(*simulator.Datastore)
+		for _, host := range simulator.Map.All("HostSystem") {
+			if host.Reference() != sharedDS.Host[0].Key {
+				sharedDS.Host = append(sharedDS.Host, vimtypes.DatastoreHostMount{Key: host.Reference()})
+			}
+		}
+		host, err := finder.HostSystem(ctx, "DC0_H0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		dss, err := host.ConfigManager().DatastoreSystem(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		local, err := dss.CreateLocalDatastore(ctx, "host-local", t.TempDir())
+		if err != nil {
+			t.Fatalf("failed to create host local datastore. Error: %v", err)
+		}
+		newDatastoreInfo := func(ds *object.Datastore) *cnsvsphere.DatastoreInfo {
+			return &cnsvsphere.DatastoreInfo{Datastore: &cnsvsphere.Datastore{Datastore: ds}}
+		}
+
+		filtered, err := filterNodeLocalDatastores(ctx, vc,
+			[]*cnsvsphere.DatastoreInfo{newDatastoreInfo(shared), newDatastoreInfo(local)})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(filtered) != 1 || filtered[0].Reference() != local.Reference() {
+			t.Errorf("expected only host local datastore %v, got %v", local.Reference(), filtered)
+		}
+
+		filtered, err = filterNodeLocalDatastores(ctx, vc, nil)
+		if err != nil || filtered != nil {
+			t.Errorf("expected no datastores for no input, got %v. Error: %v", filtered, err)
+		}
+	})
+}
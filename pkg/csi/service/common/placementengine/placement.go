@@ -52,7 +52,19 @@ func GetSharedDatastores(ctx context.Context, reqParams interface{}) (
 		map[string]string{region:region1, zone:zone2}
 	]
 	*/
+	// Datastores of the requested segments in provisioning backoff are only
+	// used if no other requested segment has a datastore.
+	segmentBackoffEnabled := commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx,
+		common.ProvisioningSegmentBackoff)
+	var (
+		backedOffDatastores []*cnsvsphere.DatastoreInfo
+		backedOffSegments   []map[string]string
+	)
 	for _, reqSegment := range params.TopologySegmentsList {
+		segmentBackedOff := segmentBackoffEnabled && isSegmentBackedOff(params.Vcenter.Config.Host, reqSegment)
+		if segmentBackedOff {
+			backedOffSegments = append(backedOffSegments, reqSegment)
+		}
 		// Fetch the complete hierarchy of topology segments.
 		completeTopologySegments, err := getExpandedTopologySegments(ctx, reqSegment, nodeMgr)
 		if err != nil {
@@ -85,6 +97,26 @@ func GetSharedDatastores(ctx context.Context, reqParams interface{}) (
 			}
 			log.Infof("Obtained list of shared datastores %+v for hosts %+v", sharedDatastoresInTopologySegment,
 				hostMoRefs)
+			// Node local volumes can only be placed in segments made of a single host,
+			// on datastores which no other host mounts.
+			if params.NodeLocal {
+				if len(hostMoRefs) != 1 {
+					log.Infof("Skipping topology segment %+v with %d hosts for node local volume",
+						segment, len(hostMoRefs))
+					continue
+				}
+				sharedDatastoresInTopologySegment, err = filterNodeLocalDatastores(ctx, params.Vcenter,
+					sharedDatastoresInTopologySegment)
+				if err != nil {
+					return nil, logger.LogNewErrorf(log, "failed to find host local datastores "+
+						"in topology segment %+v. Error: %+v", segment, err)
+				}
+				if len(sharedDatastoresInTopologySegment) == 0 {
+					log.Warnf("no host local datastores found for host %+v belonging to topology segment: %+v",
+						hostMoRefs, segment)
+					continue
+				}
+			}
 
 			// 2. Check storage policy compatibility, if given.
 			// Storage policy compatibility is given a higher preference than
@@ -150,18 +182,33 @@ func GetSharedDatastores(ctx context.Context, reqParams interface{}) (
 					}
 				}
 			}
+			// 4. Skip datastores reporting a recent latency above the threshold, if configured.
+			if params.DatastoreLatencyThresholdInMs > 0 {
+				sharedDatastoresInTopologySegment = filterDatastoresByLatency(ctx, params.Vcenter, hostMoRefs,
+					sharedDatastoresInTopologySegment, params.DatastoreLatencyThresholdInMs)
+			}
+			// 5. Skip datastores with a triggered usage alarm in vCenter, if enabled.
+			if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.DatastoreUsageAlarms) {
+				sharedDatastoresInTopologySegment = deprioritizeAlarmedDatastores(ctx, params.Vcenter.Config.Host,
+					sharedDatastoresInTopologySegment)
+			}
 			// Add the datastore list to sharedDatastores without duplicates.
-			for _, ds := range sharedDatastoresInTopologySegment {
-				var found bool
-				for _, sharedDS := range sharedDatastores {
-					if ds.Info.Url == sharedDS.Info.Url {
-						found = true
-						break
-					}
-				}
-				if !found {
-					sharedDatastores = append(sharedDatastores, ds)
-				}
+			if segmentBackedOff {
+				backedOffDatastores = appendMissingDatastores(backedOffDatastores,
+					sharedDatastoresInTopologySegment)
+			} else {
+				sharedDatastores = appendMissingDatastores(sharedDatastores, sharedDatastoresInTopologySegment)
+			}
+		}
+	}
+	if len(backedOffDatastores) != 0 {
+		if len(sharedDatastores) == 0 {
+			log.Infof("All requested topology segments with datastores are in provisioning backoff "+
+				"on vCenter %q, ignoring backoff for placement", params.Vcenter.Config.Host)
+			sharedDatastores = backedOffDatastores
+		} else {
+			for _, segment := range backedOffSegments {
+				recordSegmentBackoffSkip(ctx, params.Vcenter.Config.Host, segment)
 			}
 		}
 	}
@@ -173,6 +220,25 @@ func GetSharedDatastores(ctx context.Context, reqParams interface{}) (
 	return sharedDatastores, nil
 }
 
+// appendMissingDatastores appends the datastores to the given list, skipping
+// the datastores already in it. Datastore comparison by URL.
+func appendMissingDatastores(datastores []*cnsvsphere.DatastoreInfo,
+	toAppend []*cnsvsphere.DatastoreInfo) []*cnsvsphere.DatastoreInfo {
+	for _, ds := range toAppend {
+		var found bool
+		for _, existingDS := range datastores {
+			if ds.Info.Url == existingDS.Info.Url {
+				found = true
+				break
+			}
+		}
+		if !found {
+			datastores = append(datastores, ds)
+		}
+	}
+	return datastores
+}
+
 // getExpandedTopologySegments expands the user given topology requirement to depict the complete hierarchy.
 // NOTE: If there is no nodeVM in an AZ, that AZ will be skipped in complete topology hierarchy.
 func getExpandedTopologySegments(ctx context.Context, requestedSegments map[string]string,
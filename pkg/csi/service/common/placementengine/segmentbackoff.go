@@ -0,0 +1,185 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package placementengine
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+const (
+	// segmentBackoffHalfLife is the time after which the provisioning failure
+	// score of a topology segment is halved.
+	segmentBackoffHalfLife = 5 * time.Minute
+	// segmentBackoffThreshold is the failure score from which a topology
+	// segment is deprioritized for new volumes.
+	segmentBackoffThreshold = 3.0
+	// segmentBackoffMinScore is the failure score below which a topology
+	// segment is forgotten.
+	segmentBackoffMinScore = 0.1
+)
+
+// segmentFailureScore is the decaying provisioning failure score of a
+// topology segment.
+type segmentFailureScore struct {
+	score   float64
+	updated time.Time
+}
+
+var (
+	// segmentBackoffLock protects segmentBackoffScores.
+	segmentBackoffLock sync.Mutex
+	// segmentBackoffScores holds the provisioning failure scores of the
+	// requested topology segments, keyed by vCenter host and segment.
+	segmentBackoffScores = make(map[string]*segmentFailureScore)
+)
+
+// RecordProvisioningFailure increments the failure score of the given
+// topology segments of a vCenter, after a volume failed to be provisioned
+// in them. Once the score of a segment reaches segmentBackoffThreshold, the
+// segment is deprioritized for new volumes until its score decays.
+func RecordProvisioningFailure(ctx context.Context, vcHost string, segments []map[string]string) {
+	log := logger.GetLogger(ctx)
+	now := time.Now()
+	segmentBackoffLock.Lock()
+	defer segmentBackoffLock.Unlock()
+	for _, segment := range segments {
+		key := segmentBackoffKey(vcHost, segment)
+		failures, ok := segmentBackoffScores[key]
+		if !ok {
+			failures = &segmentFailureScore{}
+			segmentBackoffScores[key] = failures
+		}
+		previous := failures.decayedScore(now)
+		failures.score = previous + 1
+		failures.updated = now
+		if previous < segmentBackoffThreshold && failures.score >= segmentBackoffThreshold {
+			log.Infof("Provisioning repeatedly failed in topology segment %+v of vCenter %q, "+
+				"deprioritizing it for new volumes", segment, vcHost)
+		}
+	}
+}
+
+// RecordProvisioningSuccess resets the failure score of the given topology
+// segments of a vCenter, after a volume was provisioned in them.
+func RecordProvisioningSuccess(ctx context.Context, vcHost string, segments []map[string]string) {
+	log := logger.GetLogger(ctx)
+	now := time.Now()
+	segmentBackoffLock.Lock()
+	defer segmentBackoffLock.Unlock()
+	for _, segment := range segments {
+		key := segmentBackoffKey(vcHost, segment)
+		failures, ok := segmentBackoffScores[key]
+		if !ok {
+			continue
+		}
+		if failures.decayedScore(now) >= segmentBackoffThreshold {
+			log.Infof("Provisioning succeeded in topology segment %+v of vCenter %q, "+
+				"using it again for new volumes", segment, vcHost)
+		}
+		delete(segmentBackoffScores, key)
+	}
+}
+
+// OrderVCentersBySegmentBackoff returns the vCenters of the given
+// accessibility requirements, with the vCenters whose requested topology
+// segments are all in provisioning backoff last. The vCenters are kept in
+// map iteration order otherwise, so that volumes remain spread across them.
+func OrderVCentersBySegmentBackoff(ctx context.Context,
+	vcTopologySegmentsMap map[string][]map[string]string) []string {
+	log := logger.GetLogger(ctx)
+	vcHosts := make([]string, 0, len(vcTopologySegmentsMap))
+	backedOff := make(map[string]bool, len(vcTopologySegmentsMap))
+	for vcHost, segments := range vcTopologySegmentsMap {
+		vcHosts = append(vcHosts, vcHost)
+		backedOff[vcHost] = len(segments) != 0
+		for _, segment := range segments {
+			if !isSegmentBackedOff(vcHost, segment) {
+				backedOff[vcHost] = false
+				break
+			}
+		}
+		if backedOff[vcHost] {
+			log.Infof("All requested topology segments of vCenter %q are in provisioning backoff, "+
+				"trying it last", vcHost)
+		}
+	}
+	sort.SliceStable(vcHosts, func(i, j int) bool {
+		return !backedOff[vcHosts[i]] && backedOff[vcHosts[j]]
+	})
+	return vcHosts
+}
+
+// isSegmentBackedOff returns whether the given topology segment of a vCenter
+// recently failed provisioning often enough to be deprioritized.
+func isSegmentBackedOff(vcHost string, segment map[string]string) bool {
+	key := segmentBackoffKey(vcHost, segment)
+	segmentBackoffLock.Lock()
+	defer segmentBackoffLock.Unlock()
+	failures, ok := segmentBackoffScores[key]
+	if !ok {
+		return false
+	}
+	score := failures.decayedScore(time.Now())
+	if score < segmentBackoffMinScore {
+		delete(segmentBackoffScores, key)
+		return false
+	}
+	return score >= segmentBackoffThreshold
+}
+
+// recordSegmentBackoffSkip reports a topology segment skipped for a new
+// volume because of its provisioning backoff.
+func recordSegmentBackoffSkip(ctx context.Context, vcHost string, segment map[string]string) {
+	log := logger.GetLogger(ctx)
+	log.Infof("Skipping datastores of topology segment %+v in provisioning backoff on vCenter %q",
+		segment, vcHost)
+	prometheus.ProvisioningSegmentBackoffSkipsCounter.WithLabelValues(vcHost, formatSegment(segment)).Inc()
+}
+
+// decayedScore returns the failure score decayed until now.
+func (f *segmentFailureScore) decayedScore(now time.Time) float64 {
+	elapsed := now.Sub(f.updated)
+	if elapsed <= 0 {
+		return f.score
+	}
+	return f.score * math.Exp2(-elapsed.Seconds()/segmentBackoffHalfLife.Seconds())
+}
+
+// segmentBackoffKey returns the key of a topology segment of a vCenter in
+// segmentBackoffScores.
+func segmentBackoffKey(vcHost string, segment map[string]string) string {
+	return vcHost + "/" + formatSegment(segment)
+}
+
+// formatSegment formats a topology segment as comma separated key=value
+// pairs sorted by key.
+func formatSegment(segment map[string]string) string {
+	pairs := make([]string, 0, len(segment))
+	for key, value := range segment {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
@@ -35,4 +35,10 @@ type VanillaSharedDatastoresParams struct {
 	// StoragePolicyID represents the unique ID of the storage policy
 	// name given in the Storage Class on the attempted VC.
 	StoragePolicyID string
+	// DatastoreLatencyThresholdInMs is the maximum recent IO latency a
+	// datastore may report to be considered. 0 disables the latency check.
+	DatastoreLatencyThresholdInMs int
+	// NodeLocal restricts placement to datastores mounted only by the single
+	// host each topology segment resolves to.
+	NodeLocal bool
 }
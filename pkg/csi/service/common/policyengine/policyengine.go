@@ -0,0 +1,171 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policyengine
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	cnsconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+// Operation is the provisioning operation being reviewed.
+type Operation string
+
+const (
+	// OperationCreateVolume is the review of a CreateVolume request.
+	OperationCreateVolume Operation = "CreateVolume"
+	// OperationCreateSnapshot is the review of a CreateSnapshot request.
+	OperationCreateSnapshot Operation = "CreateSnapshot"
+)
+
+// maxResponseSize is the maximum size of a review response read from the
+// policy service.
+const maxResponseSize = 1 << 20
+
+// ReviewRequest is the context of a provisioning request sent to the policy
+// service.
+type ReviewRequest struct {
+	// Operation is the CSI operation being reviewed.
+	Operation Operation `json:"operation"`
+	// Name is the name of the volume or snapshot being created.
+	Name string `json:"name"`
+	// Namespace is the namespace of the PVC or VolumeSnapshot, if known.
+	Namespace string `json:"namespace,omitempty"`
+	// ClaimName is the name of the PVC or VolumeSnapshot, if known.
+	ClaimName string `json:"claimName,omitempty"`
+	// CapacityBytes is the requested size of the volume.
+	CapacityBytes int64 `json:"capacityBytes,omitempty"`
+	// Parameters are the StorageClass or VolumeSnapshotClass parameters.
+	Parameters map[string]string `json:"parameters,omitempty"`
+	// Topology is the list of preferred topology segments of the volume.
+	Topology []map[string]string `json:"topology,omitempty"`
+	// SourceVolumeID is the volume being snapshotted or cloned.
+	SourceVolumeID string `json:"sourceVolumeID,omitempty"`
+	// SourceSnapshotID is the snapshot the volume is restored from.
+	SourceSnapshotID string `json:"sourceSnapshotID,omitempty"`
+}
+
+// ReviewResponse is the decision of the policy service.
+type ReviewResponse struct {
+	// Allowed is set if the request may proceed.
+	Allowed bool `json:"allowed"`
+	// Reason explains why the request was denied.
+	Reason string `json:"reason,omitempty"`
+	// Parameters, if set, replace the parameters of the request.
+	Parameters map[string]string `json:"parameters,omitempty"`
+}
+
+// Client reviews provisioning requests.
+type Client interface {
+	// Review returns the decision of the policy service for the request.
+	Review(ctx context.Context, req *ReviewRequest) (*ReviewResponse, error)
+}
+
+// httpClient posts review requests to an HTTP(S) endpoint. The payloads use
+// the OPA data API format, i.e. the request is sent as {"input": <request>}
+// and the decision is read from {"result": <response>}.
+type httpClient struct {
+	endpoint string
+	client   *http.Client
+}
+
+type reviewInput struct {
+	Input *ReviewRequest `json:"input"`
+}
+
+type reviewResult struct {
+	Result *ReviewResponse `json:"result"`
+}
+
+// NewClient returns the client of the policy service configured in cfg, or
+// nil if no endpoint is configured.
+func NewClient(ctx context.Context, cfg *cnsconfig.PolicyEngineConfig) (Client, error) {
+	log := logger.GetLogger(ctx)
+	if cfg == nil || cfg.Endpoint == "" {
+		return nil, nil
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.CAFile != "" {
+		caCert, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, logger.LogNewErrorf(log, "failed to read policy engine CA file %q. Error: %+v",
+				cfg.CAFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, logger.LogNewErrorf(log, "no certificate found in policy engine CA file %q", cfg.CAFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	timeout := cfg.TimeoutInSeconds
+	if timeout <= 0 {
+		timeout = cnsconfig.DefaultPolicyEngineTimeoutInSeconds
+	}
+	log.Infof("Provisioning requests will be reviewed by the policy engine at %q", cfg.Endpoint)
+	return &httpClient{
+		endpoint: cfg.Endpoint,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   time.Duration(timeout) * time.Second,
+		},
+	}, nil
+}
+
+// Review posts the request to the policy service and returns its decision.
+func (c *httpClient) Review(ctx context.Context, req *ReviewRequest) (*ReviewResponse, error) {
+	body, err := json.Marshal(&reviewInput{Input: req})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode review request: %v", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create review request: %v", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpResp, err := c.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send review request to %q: %v", c.endpoint, err)
+	}
+	defer httpResp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(httpResp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read review response from %q: %v", c.endpoint, err)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("policy engine %q returned status %d: %s", c.endpoint,
+			httpResp.StatusCode, string(respBody))
+	}
+	result := &reviewResult{}
+	if err := json.Unmarshal(respBody, result); err != nil {
+		return nil, fmt.Errorf("failed to decode review response from %q: %v", c.endpoint, err)
+	}
+	// OPA omits the result if the policy is not defined.
+	if result.Result == nil {
+		return nil, fmt.Errorf("policy engine %q returned no decision", c.endpoint)
+	}
+	return result.Result, nil
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policyengine
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	cnsconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+)
+
+func TestNewClientWithoutEndpoint(t *testing.T) {
+	client, err := NewClient(context.TODO(), &cnsconfig.PolicyEngineConfig{})
+	if err != nil || client != nil {
+		t.Fatalf("expected no client, got %v, %v", client, err)
+	}
+}
+
+func TestReview(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		input := &reviewInput{}
+		if err := json.NewDecoder(r.Body).Decode(input); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch input.Input.Namespace {
+		case "denied":
+			_, _ = w.Write([]byte(`{"result": {"allowed": false, "reason": "quota exceeded"}}`))
+		case "mutated":
+			_, _ = w.Write([]byte(`{"result": {"allowed": true, "parameters": {"storagepolicyname": "gold"}}}`))
+		case "undefined":
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.TODO()
+	client, err := NewClient(ctx, &cnsconfig.PolicyEngineConfig{Endpoint: server.URL})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	resp, err := client.Review(ctx, &ReviewRequest{Operation: OperationCreateVolume, Namespace: "denied"})
+	if err != nil || resp.Allowed || resp.Reason != "quota exceeded" {
+		t.Errorf("expected denied response, got %+v, %v", resp, err)
+	}
+	resp, err = client.Review(ctx, &ReviewRequest{Operation: OperationCreateVolume, Namespace: "mutated"})
+	if err != nil || !resp.Allowed || resp.Parameters["storagepolicyname"] != "gold" {
+		t.Errorf("expected mutated response, got %+v, %v", resp, err)
+	}
+	for _, namespace := range []string{"undefined", "failed"} {
+		if _, err = client.Review(ctx, &ReviewRequest{Namespace: namespace}); err == nil {
+			t.Errorf("expected error for namespace %q", namespace)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	vim25types "github.com/vmware/govmomi/vim25/types"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+// Types of the entities of a privilege report.
+const (
+	privilegeReportEntityRootFolder = "RootFolder"
+	privilegeReportEntityDatastore  = "Datastore"
+	privilegeReportEntityCluster    = "ClusterComputeResource"
+)
+
+// PrivilegeReport lists the vCenter privileges missing to the CSI VC user on
+// the entities used by the driver.
+type PrivilegeReport struct {
+	VCenter     string `json:"vCenter"`
+	User        string `json:"user"`
+	GeneratedAt string `json:"generatedAt"`
+	// Entities lists the entities the user is missing privileges on.
+	Entities []EntityPrivilegeReport `json:"entities"`
+	// Errors lists the checks which could not be completed.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// EntityPrivilegeReport lists the privileges missing on an entity.
+type EntityPrivilegeReport struct {
+	Type              string   `json:"type"`
+	Name              string   `json:"name"`
+	MoRef             string   `json:"moRef"`
+	MissingPrivileges []string `json:"missingPrivileges"`
+}
+
+// privilegeCheck is a set of privileges required on a set of entities of the
+// same type.
+type privilegeCheck struct {
+	entityType string
+	privileges []string
+	// names maps the managed object ID of each entity to check to its name.
+	names    map[string]string
+	entities []vim25types.ManagedObjectReference
+}
+
+// GenerateVCenterPrivilegeReport checks the privileges of the CSI VC user on
+// the root folder, the datastores and the clusters of the given vCenter, and
+// reports the privileges missing on each of them.
+func GenerateVCenterPrivilegeReport(ctx context.Context, vc *cnsvsphere.VirtualCenter) (*PrivilegeReport, error) {
+	log := logger.GetLogger(ctx)
+	if err := vc.Connect(ctx); err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to connect to vCenter %q. Err: %v", vc.Config.Host, err)
+	}
+	report := &PrivilegeReport{
+		VCenter:     vc.Config.Host,
+		User:        vc.Config.Username,
+		GeneratedAt: time.Now().UTC().Format(time.RFC3339),
+		Entities:    []EntityPrivilegeReport{},
+	}
+	rootFolder := vc.Client.ServiceContent.RootFolder
+	checks := []*privilegeCheck{{
+		entityType: privilegeReportEntityRootFolder,
+		privileges: []string{CnsSearchablePriv, StorageProfileViewPriv, SysReadPriv},
+		names:      map[string]string{rootFolder.Value: vc.Config.Host},
+		entities:   []vim25types.ManagedObjectReference{rootFolder},
+	}}
+	datastoreCheck := &privilegeCheck{
+		entityType: privilegeReportEntityDatastore,
+		privileges: []string{DsPriv, SysReadPriv},
+		names:      make(map[string]string),
+	}
+	clusterCheck := &privilegeCheck{
+		entityType: privilegeReportEntityCluster,
+		privileges: []string{HostConfigStoragePriv},
+		names:      make(map[string]string),
+	}
+	checks = append(checks, datastoreCheck, clusterCheck)
+
+	datacenters, err := vc.ListDatacenters(ctx)
+	if err != nil {
+		report.Errors = append(report.Errors, "failed to list datacenters: "+err.Error())
+	}
+	for _, dc := range datacenters {
+		dsURLTodsInfoMap, err := dc.GetAllDatastores(ctx)
+		if err != nil {
+			report.Errors = append(report.Errors, "failed to list datastores of datacenter "+
+				dc.InventoryPath+": "+err.Error())
+		}
+		for _, dsInfo := range dsURLTodsInfoMap {
+			dsMoRef := dsInfo.Reference()
+			datastoreCheck.names[dsMoRef.Value] = dsInfo.Info.Name
+			datastoreCheck.entities = append(datastoreCheck.entities, dsMoRef)
+		}
+		finder := find.NewFinder(dc.Datacenter.Client(), false)
+		finder.SetDatacenter(dc.Datacenter)
+		clusters, err := finder.ClusterComputeResourceList(ctx, "*")
+		if err != nil {
+			if _, ok := err.(*find.NotFoundError); !ok {
+				report.Errors = append(report.Errors, "failed to list clusters of datacenter "+
+					dc.InventoryPath+": "+err.Error())
+			}
+		}
+		for _, cluster := range clusters {
+			clusterCheck.names[cluster.Reference().Value] = cluster.Name()
+			clusterCheck.entities = append(clusterCheck.entities, cluster.Reference())
+		}
+	}
+
+	authMgr := object.NewAuthorizationManager(vc.Client.Client)
+	for _, check := range checks {
+		if len(check.entities) == 0 {
+			continue
+		}
+		result, err := authMgr.HasUserPrivilegeOnEntities(ctx, check.entities, vc.Config.Username, check.privileges)
+		if err != nil {
+			report.Errors = append(report.Errors, "failed to check privileges on "+check.entityType+
+				" entities: "+err.Error())
+			continue
+		}
+		report.Entities = append(report.Entities, getMissingPrivileges(check, result)...)
+	}
+	log.Infof("Generated privilege report for user %s on vCenter %q: %d entities with missing privileges",
+		report.User, report.VCenter, len(report.Entities))
+	return report, nil
+}
+
+// getMissingPrivileges returns the entities of the given check missing one
+// or more of its privileges, according to the result of
+// HasUserPrivilegeOnEntities.
+func getMissingPrivileges(check *privilegeCheck, result []vim25types.EntityPrivilege) []EntityPrivilegeReport {
+	var reports []EntityPrivilegeReport
+	for _, entityPriv := range result {
+		var missing []string
+		for _, privAvail := range entityPriv.PrivAvailability {
+			if !privAvail.IsGranted {
+				missing = append(missing, privAvail.PrivId)
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		sort.Strings(missing)
+		reports = append(reports, EntityPrivilegeReport{
+			Type:              check.entityType,
+			Name:              check.names[entityPriv.Entity.Value],
+			MoRef:             entityPriv.Entity.Value,
+			MissingPrivileges: missing,
+		})
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Name < reports[j].Name
+	})
+	return reports
+}
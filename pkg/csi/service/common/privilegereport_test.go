@@ -0,0 +1,69 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	vim25types "github.com/vmware/govmomi/vim25/types"
+)
+
+func TestGetMissingPrivileges(t *testing.T) {
+	check := &privilegeCheck{
+		entityType: privilegeReportEntityDatastore,
+		privileges: []string{DsPriv, SysReadPriv},
+		names:      map[string]string{"datastore-1": "ds1", "datastore-2": "ds2", "datastore-3": "ds3"},
+	}
+	result := []vim25types.EntityPrivilege{
+		{
+			Entity: vim25types.ManagedObjectReference{Type: "Datastore", Value: "datastore-3"},
+			PrivAvailability: []vim25types.PrivilegeAvailability{
+				{PrivId: SysReadPriv, IsGranted: false},
+				{PrivId: DsPriv, IsGranted: false},
+			},
+		},
+		{
+			Entity: vim25types.ManagedObjectReference{Type: "Datastore", Value: "datastore-1"},
+			PrivAvailability: []vim25types.PrivilegeAvailability{
+				{PrivId: DsPriv, IsGranted: true},
+				{PrivId: SysReadPriv, IsGranted: true},
+			},
+		},
+		{
+			Entity: vim25types.ManagedObjectReference{Type: "Datastore", Value: "datastore-2"},
+			PrivAvailability: []vim25types.PrivilegeAvailability{
+				{PrivId: DsPriv, IsGranted: false},
+				{PrivId: SysReadPriv, IsGranted: true},
+			},
+		},
+	}
+	assert.Equal(t, []EntityPrivilegeReport{
+		{
+			Type:              privilegeReportEntityDatastore,
+			Name:              "ds2",
+			MoRef:             "datastore-2",
+			MissingPrivileges: []string{DsPriv},
+		},
+		{
+			Type:              privilegeReportEntityDatastore,
+			Name:              "ds3",
+			MoRef:             "datastore-3",
+			MissingPrivileges: []string{DsPriv, SysReadPriv},
+		},
+	}, getMissingPrivileges(check, result))
+}
@@ -0,0 +1,243 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshothooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	restclient "k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+const (
+	// HookTypeExec is the value of the snapshot hooks VolumeSnapshotClass
+	// parameter running the hook commands annotated on the workload pods.
+	HookTypeExec = "exec"
+
+	// AnnPreSnapshotCommand is the annotation key on a pod holding the
+	// command, as a JSON array, run in the pod before the snapshot of one of
+	// its volumes is taken.
+	AnnPreSnapshotCommand = "snapshot.cns.vmware.com/pre-snapshot-command"
+	// AnnPostSnapshotCommand is the annotation key on a pod holding the
+	// command, as a JSON array, run in the pod after the snapshot of one of
+	// its volumes is taken, whether the snapshot succeeded or not.
+	AnnPostSnapshotCommand = "snapshot.cns.vmware.com/post-snapshot-command"
+	// AnnHookContainer is the annotation key on a pod holding the name of the
+	// container the hook commands are run in. The first container of the pod
+	// is used by default.
+	AnnHookContainer = "snapshot.cns.vmware.com/hook-container"
+	// AnnHookTimeout is the annotation key on a pod holding the timeout, as a
+	// duration, of each hook command. DefaultHookTimeout is used by default.
+	AnnHookTimeout = "snapshot.cns.vmware.com/hook-timeout"
+
+	// DefaultHookTimeout is the timeout of a hook command if the pod doesn't
+	// have the AnnHookTimeout annotation.
+	DefaultHookTimeout = 30 * time.Second
+)
+
+// Executor runs a command in a container of a pod.
+type Executor func(ctx context.Context, namespace, pod, container string, command []string) (
+	stdout string, stderr string, err error)
+
+// podHooks are the hooks annotated on a pod using the snapshotted volume.
+type podHooks struct {
+	namespace   string
+	name        string
+	container   string
+	timeout     time.Duration
+	preCommand  []string
+	postCommand []string
+	// frozen is set once the pre-snapshot command succeeded in the pod.
+	frozen bool
+}
+
+// Hooks runs the pre and post snapshot hooks of the pods using a volume.
+type Hooks struct {
+	executor Executor
+	pods     []*podHooks
+}
+
+// New returns the Hooks annotated on the running pods using the given PVC,
+// or nil if none of them has hooks.
+func New(ctx context.Context, k8sClient clientset.Interface, executor Executor,
+	pvcNamespace, pvcName string) (*Hooks, error) {
+	log := logger.GetLogger(ctx)
+	podList, err := k8sClient.CoreV1().Pods(pvcNamespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to list pods in namespace %q. Error: %v",
+			pvcNamespace, err)
+	}
+	hooks := &Hooks{executor: executor}
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.Status.Phase != v1.PodRunning || !usesPVC(pod, pvcName) {
+			continue
+		}
+		podHooks, err := parsePodHooks(pod)
+		if err != nil {
+			return nil, logger.LogNewErrorf(log, "invalid snapshot hooks on pod %s/%s. Error: %v",
+				pod.Namespace, pod.Name, err)
+		}
+		if podHooks != nil {
+			hooks.pods = append(hooks.pods, podHooks)
+		}
+	}
+	if len(hooks.pods) == 0 {
+		return nil, nil
+	}
+	return hooks, nil
+}
+
+// usesPVC returns true if the pod mounts the PVC with the given name.
+func usesPVC(pod *v1.Pod, pvcName string) bool {
+	for _, volume := range pod.Spec.Volumes {
+		if volume.PersistentVolumeClaim != nil && volume.PersistentVolumeClaim.ClaimName == pvcName {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePodHooks returns the hooks annotated on the pod, or nil if it has
+// none.
+func parsePodHooks(pod *v1.Pod) (*podHooks, error) {
+	hooks := &podHooks{
+		namespace: pod.Namespace,
+		name:      pod.Name,
+		timeout:   DefaultHookTimeout,
+	}
+	for annotation, command := range map[string]*[]string{
+		AnnPreSnapshotCommand:  &hooks.preCommand,
+		AnnPostSnapshotCommand: &hooks.postCommand,
+	} {
+		value, ok := pod.Annotations[annotation]
+		if !ok {
+			continue
+		}
+		if err := json.Unmarshal([]byte(value), command); err != nil || len(*command) == 0 {
+			return nil, fmt.Errorf("annotation %q must be a non-empty JSON array of strings", annotation)
+		}
+	}
+	if hooks.preCommand == nil && hooks.postCommand == nil {
+		return nil, nil
+	}
+	if len(pod.Spec.Containers) > 0 {
+		hooks.container = pod.Spec.Containers[0].Name
+	}
+	if container, ok := pod.Annotations[AnnHookContainer]; ok {
+		hooks.container = container
+	}
+	if value, ok := pod.Annotations[AnnHookTimeout]; ok {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("annotation %q must be a positive duration", AnnHookTimeout)
+		}
+		hooks.timeout = timeout
+	}
+	return hooks, nil
+}
+
+// RunPre runs the pre-snapshot commands in the pods. If a command fails, the
+// post-snapshot commands are run in the pods where the pre-snapshot command
+// succeeded, and an error is returned.
+func (h *Hooks) RunPre(ctx context.Context) error {
+	log := logger.GetLogger(ctx)
+	for _, pod := range h.pods {
+		if pod.preCommand != nil {
+			if err := h.run(ctx, pod, pod.preCommand); err != nil {
+				h.RunPost(ctx)
+				return logger.LogNewErrorf(log, "pre-snapshot command failed in pod %s/%s. Error: %v",
+					pod.namespace, pod.name, err)
+			}
+		}
+		pod.frozen = true
+	}
+	return nil
+}
+
+// RunPost runs the post-snapshot commands in the pods where the pre-snapshot
+// command succeeded. Failures are logged, as the snapshot is already taken.
+func (h *Hooks) RunPost(ctx context.Context) {
+	log := logger.GetLogger(ctx)
+	for _, pod := range h.pods {
+		if !pod.frozen {
+			continue
+		}
+		pod.frozen = false
+		if pod.postCommand == nil {
+			continue
+		}
+		if err := h.run(ctx, pod, pod.postCommand); err != nil {
+			log.Errorf("post-snapshot command failed in pod %s/%s. Error: %v", pod.namespace, pod.name, err)
+		}
+	}
+}
+
+// run runs the command in the hook container of the pod within the hook
+// timeout of the pod.
+func (h *Hooks) run(ctx context.Context, pod *podHooks, command []string) error {
+	log := logger.GetLogger(ctx)
+	ctx, cancel := context.WithTimeout(ctx, pod.timeout)
+	defer cancel()
+	log.Infof("Running snapshot hook %q in container %q of pod %s/%s", command, pod.container,
+		pod.namespace, pod.name)
+	stdout, stderr, err := h.executor(ctx, pod.namespace, pod.name, pod.container, command)
+	log.Debugf("Snapshot hook %q in pod %s/%s stdout: %q, stderr: %q", command, pod.namespace, pod.name,
+		stdout, stderr)
+	if err != nil {
+		return fmt.Errorf("%v, stderr: %q", err, stderr)
+	}
+	return nil
+}
+
+// NewPodExecutor returns an Executor running the commands through the exec
+// subresource of the pods.
+func NewPodExecutor(k8sClient clientset.Interface, config *restclient.Config) Executor {
+	return func(ctx context.Context, namespace, pod, container string, command []string) (string, string, error) {
+		req := k8sClient.CoreV1().RESTClient().Post().
+			Resource("pods").
+			Namespace(namespace).
+			Name(pod).
+			SubResource("exec").
+			VersionedParams(&v1.PodExecOptions{
+				Container: container,
+				Command:   command,
+				Stdout:    true,
+				Stderr:    true,
+			}, scheme.ParameterCodec)
+		exec, err := remotecommand.NewSPDYExecutor(config, "POST", req.URL())
+		if err != nil {
+			return "", "", err
+		}
+		var stdout, stderr bytes.Buffer
+		err = exec.StreamWithContext(ctx, remotecommand.StreamOptions{
+			Stdout: &stdout,
+			Stderr: &stderr,
+		})
+		return stdout.String(), stderr.String(), err
+	}
+}
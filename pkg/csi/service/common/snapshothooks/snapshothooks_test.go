@@ -0,0 +1,130 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshothooks
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newPod(name, pvcName string, annotations map[string]string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Annotations: annotations},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{Name: "app"}},
+			Volumes: []v1.Volume{{
+				Name: "data",
+				VolumeSource: v1.VolumeSource{
+					PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: pvcName},
+				},
+			}},
+		},
+		Status: v1.PodStatus{Phase: v1.PodRunning},
+	}
+}
+
+func TestHooks(t *testing.T) {
+	ctx := context.Background()
+	k8sClient := fake.NewSimpleClientset(
+		newPod("db-0", "data-db-0", map[string]string{
+			AnnPreSnapshotCommand:  `["fsfreeze", "--freeze", "/data"]`,
+			AnnPostSnapshotCommand: `["fsfreeze", "--unfreeze", "/data"]`,
+		}),
+		newPod("web-0", "data-db-0", nil),
+		newPod("db-1", "data-db-1", map[string]string{
+			AnnPreSnapshotCommand: `["sync"]`,
+		}),
+	)
+	var executed []string
+	executor := func(ctx context.Context, namespace, pod, container string, command []string) (
+		string, string, error) {
+		executed = append(executed, pod+"/"+container+": "+strings.Join(command, " "))
+		return "", "", nil
+	}
+
+	hooks, err := New(ctx, k8sClient, executor, "default", "data-db-0")
+	if err != nil {
+		t.Fatalf("failed to get hooks. Error: %v", err)
+	}
+	if err := hooks.RunPre(ctx); err != nil {
+		t.Fatalf("failed to run pre-snapshot hooks. Error: %v", err)
+	}
+	hooks.RunPost(ctx)
+	expected := []string{"db-0/app: fsfreeze --freeze /data", "db-0/app: fsfreeze --unfreeze /data"}
+	if !reflect.DeepEqual(executed, expected) {
+		t.Errorf("expected commands %v, got %v", expected, executed)
+	}
+
+	hooks, err = New(ctx, k8sClient, executor, "default", "data-db-2")
+	if err != nil || hooks != nil {
+		t.Errorf("expected no hooks for a PVC without pods, got %v, error: %v", hooks, err)
+	}
+}
+
+func TestHooksPreCommandFailure(t *testing.T) {
+	ctx := context.Background()
+	k8sClient := fake.NewSimpleClientset(
+		newPod("db-0", "data", map[string]string{
+			AnnPreSnapshotCommand:  `["freeze"]`,
+			AnnPostSnapshotCommand: `["unfreeze"]`,
+		}),
+		newPod("db-1", "data", map[string]string{
+			AnnPreSnapshotCommand:  `["freeze"]`,
+			AnnPostSnapshotCommand: `["unfreeze"]`,
+		}),
+	)
+	var executed []string
+	executor := func(ctx context.Context, namespace, pod, container string, command []string) (
+		string, string, error) {
+		executed = append(executed, pod+": "+strings.Join(command, " "))
+		if pod == "db-1" && command[0] == "freeze" {
+			return "", "read-only file system", errors.New("command terminated with exit code 1")
+		}
+		return "", "", nil
+	}
+
+	hooks, err := New(ctx, k8sClient, executor, "default", "data")
+	if err != nil {
+		t.Fatalf("failed to get hooks. Error: %v", err)
+	}
+	if err := hooks.RunPre(ctx); err == nil {
+		t.Fatalf("expected pre-snapshot hooks to fail")
+	}
+	hooks.RunPost(ctx)
+	// Only the pod frozen before the failure is unfrozen, and only once.
+	expected := []string{"db-0: freeze", "db-1: freeze", "db-0: unfreeze"}
+	if !reflect.DeepEqual(executed, expected) {
+		t.Errorf("expected commands %v, got %v", expected, executed)
+	}
+}
+
+func TestInvalidHookAnnotation(t *testing.T) {
+	ctx := context.Background()
+	k8sClient := fake.NewSimpleClientset(newPod("db-0", "data", map[string]string{
+		AnnPreSnapshotCommand: "fsfreeze --freeze /data",
+	}))
+	if _, err := New(ctx, k8sClient, nil, "default", "data"); err == nil {
+		t.Errorf("expected an error for a command which isn't a JSON array")
+	}
+}
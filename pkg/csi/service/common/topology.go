@@ -7,11 +7,15 @@ import (
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/view"
 	"github.com/vmware/govmomi/vim25/mo"
 
 	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/csidriverconfig"
 	csinodetopologyv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/csinodetopology/v1alpha1"
 )
 
@@ -45,7 +49,9 @@ var (
 )
 
 // DiscoverTagEntities populates tagVCEntityMoRefMap with tagName -> VC -> associated MoRefs mapping.
-// NOTE: Any edits to existing topology labels will require a restart of the controller.
+// The topology categories of the CSIDriverConfig take precedence over the vSphere config secret.
+// NOTE: Any edits to existing topology labels in the vSphere config secret will require a restart
+// of the controller.
 func DiscoverTagEntities(ctx context.Context) error {
 	log := logger.GetLogger(ctx)
 	// Get CNS config.
@@ -57,7 +63,11 @@ func DiscoverTagEntities(ctx context.Context) error {
 	var categories []string
 	zoneCat := strings.TrimSpace(cnsCfg.Labels.Zone)
 	regionCat := strings.TrimSpace(cnsCfg.Labels.Region)
-	if zoneCat != "" && regionCat != "" {
+	if crCategories := csidriverconfig.TopologyCategories(); len(crCategories) != 0 {
+		for _, category := range crCategories {
+			categories = append(categories, category.Name)
+		}
+	} else if zoneCat != "" && regionCat != "" {
 		categories = []string{zoneCat, regionCat}
 	} else if strings.TrimSpace(cnsCfg.Labels.TopologyCategories) != "" {
 		categories = strings.Split(cnsCfg.Labels.TopologyCategories, ",")
@@ -131,6 +141,16 @@ func GetHostsForSegment(ctx context.Context, topoSegment map[string]string, vCen
 	// Get the entity MoRefs for each tag.
 	for key, tag := range topoSegment {
 		var hostList []*cnsvsphere.HostSystem
+		if key == TopologyLabelEsxiHost || key == TopologyLabelVSphereCluster {
+			// Host and cluster level segments carry inventory names instead of tags.
+			hosts, err := fetchHostsByInventoryName(ctx, key, tag, vCenter)
+			if err != nil {
+				return nil, err
+			}
+			log.Infof("Hosts returned for topology key: %q and value: %q are %v", key, tag, hosts)
+			allhostSlices = append(allhostSlices, removeDuplicateHosts(hosts))
+			continue
+		}
 		entityMorefs, exists := areEntityMorefsPresentForTag(tag, vCenter.Config.Host)
 		if !exists {
 			// Refresh cache to see if the tag has been added recently.
@@ -319,6 +339,47 @@ func fetchHosts(ctx context.Context, entity mo.Reference, vCenter *cnsvsphere.Vi
 	return hosts, nil
 }
 
+// fetchHostsByInventoryName returns the hosts matching a host or cluster level
+// topology segment by looking up the inventory object with the given name.
+func fetchHostsByInventoryName(ctx context.Context, key, name string, vCenter *cnsvsphere.VirtualCenter) (
+	[]*cnsvsphere.HostSystem, error) {
+	log := logger.GetLogger(ctx)
+	kind := "HostSystem"
+	if key == TopologyLabelVSphereCluster {
+		kind = "ClusterComputeResource"
+	}
+	viewMgr := view.NewManager(vCenter.Client.Client)
+	containerView, err := viewMgr.CreateContainerView(ctx, vCenter.Client.ServiceContent.RootFolder,
+		[]string{kind}, true)
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to create container view on vCenter %q. Error: %+v",
+			vCenter.Config.Host, err)
+	}
+	defer func() {
+		if err := containerView.Destroy(ctx); err != nil {
+			log.Warnf("failed to destroy container view on vCenter %q. Error: %+v", vCenter.Config.Host, err)
+		}
+	}()
+	entities, err := containerView.Find(ctx, []string{kind}, property.Match{"name": name})
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to find %s %q in vCenter %q. Error: %+v",
+			kind, name, vCenter.Config.Host, err)
+	}
+	if len(entities) == 0 {
+		return nil, logger.LogNewErrorf(log, "failed to find %s %q in vCenter %q.", kind, name, vCenter.Config.Host)
+	}
+	var hosts []*cnsvsphere.HostSystem
+	for _, entity := range entities {
+		hostList, err := fetchHosts(ctx, entity, vCenter)
+		if err != nil {
+			return nil, logger.LogNewErrorf(log, "failed to fetch hosts from entity %+v. Error: %+v",
+				entity, err)
+		}
+		hosts = append(hosts, hostList...)
+	}
+	return hosts, nil
+}
+
 // areEntityMorefsPresentForTag retrieves the entities in given VC which have the
 // input tag associated with them.
 func areEntityMorefsPresentForTag(tag, vcHost string) ([]mo.Reference, bool) {
@@ -418,7 +479,7 @@ func getVCForTopologySegments(ctx context.Context, topologySegments map[string]s
 // with the latest information on the preferential datastores for each topology domain across all vCenter Servers
 func RefreshPreferentialDatastoresForMultiVCenter(ctx context.Context) error {
 	log := logger.GetLogger(ctx)
-	cnsCfg, err := config.GetConfig(ctx)
+	cnsCfg, err := config.GetConfigSnapshot(ctx)
 	if err != nil {
 		return logger.LogNewErrorf(log, "failed to fetch CNS config. Error: %+v", err)
 	}
@@ -532,30 +593,49 @@ func GetPreferredDatastoresInSegments(ctx context.Context, segments map[string]s
 	return allPreferredDSURLs
 }
 
-// AddNodeToDomainNodeMapNew adds the CR instance name in the domainNodeMap wherever appropriate.
+// IsOwnCSINodeTopology returns true if the CSINodeTopology instance was created
+// by this driver and not by a driver installed side by side. Instances of a
+// driver with a non-default name are named after the node with the driver
+// name as suffix.
+func IsOwnCSINodeTopology(nodeTopoObj csinodetopologyv1alpha1.CSINodeTopology) bool {
+	if nodeTopoObj.Spec.NodeID == "" {
+		return csitypes.InstanceObjectNameSuffix() == ""
+	}
+	return nodeTopoObj.Name == csitypes.InstanceObjectName(nodeTopoObj.Spec.NodeID)
+}
+
+// CSINodeTopologyNodeName returns the name of the node of a CSINodeTopology
+// instance of this driver.
+func CSINodeTopologyNodeName(nodeTopoObj csinodetopologyv1alpha1.CSINodeTopology) string {
+	return strings.TrimSuffix(nodeTopoObj.Name, csitypes.InstanceObjectNameSuffix())
+}
+
+// AddNodeToDomainNodeMapNew adds the node name of the CR instance in the domainNodeMap wherever appropriate.
 func AddNodeToDomainNodeMapNew(ctx context.Context, nodeTopoObj csinodetopologyv1alpha1.CSINodeTopology) {
 	log := logger.GetLogger(ctx)
 	domainNodeMapInstanceLock.Lock()
 	defer domainNodeMapInstanceLock.Unlock()
+	nodeName := CSINodeTopologyNodeName(nodeTopoObj)
 	for _, label := range nodeTopoObj.Status.TopologyLabels {
 		if _, exists := domainNodeMap[label.Value]; !exists {
-			domainNodeMap[label.Value] = map[string]struct{}{nodeTopoObj.Name: {}}
+			domainNodeMap[label.Value] = map[string]struct{}{nodeName: {}}
 		} else {
-			domainNodeMap[label.Value][nodeTopoObj.Name] = struct{}{}
+			domainNodeMap[label.Value][nodeName] = struct{}{}
 		}
 	}
-	log.Infof("Added %q value to domainNodeMap", nodeTopoObj.Name)
+	log.Infof("Added %q value to domainNodeMap", nodeName)
 }
 
-// RemoveNodeFromDomainNodeMapNew removes the CR instance name from the domainNodeMap.
+// RemoveNodeFromDomainNodeMapNew removes the node name of the CR instance from the domainNodeMap.
 func RemoveNodeFromDomainNodeMapNew(ctx context.Context, nodeTopoObj csinodetopologyv1alpha1.CSINodeTopology) {
 	log := logger.GetLogger(ctx)
 	domainNodeMapInstanceLock.Lock()
 	defer domainNodeMapInstanceLock.Unlock()
+	nodeName := CSINodeTopologyNodeName(nodeTopoObj)
 	for _, label := range nodeTopoObj.Status.TopologyLabels {
-		delete(domainNodeMap[label.Value], nodeTopoObj.Name)
+		delete(domainNodeMap[label.Value], nodeName)
 	}
-	log.Infof("Removed %q value from domainNodeMap", nodeTopoObj.Name)
+	log.Infof("Removed %q value from domainNodeMap", nodeName)
 }
 
 // VerifyAllNodesInTopologyAccessibleToDatastore verifies whether all the nodes present
@@ -0,0 +1,107 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+)
+
+func TestFetchHostsByInventoryName(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		vCenter := &cnsvsphere.VirtualCenter{
+			Config: &cnsvsphere.VirtualCenterConfig{Host: "vcsim"},
+			Client: &govmomi.Client{Client: c},
+		}
+		tests := []struct {
+			name          string
+			key           string
+			value         string
+			expectedHosts []string
+			expectErr     bool
+		}{
+			{
+				name:          "cluster",
+				key:           TopologyLabelVSphereCluster,
+				value:         "DC0_C0",
+				expectedHosts: []string{"DC0_C0_H0", "DC0_C0_H1", "DC0_C0_H2"},
+			},
+			{
+				name:          "cluster host",
+				key:           TopologyLabelEsxiHost,
+				value:         "DC0_C0_H1",
+				expectedHosts: []string{"DC0_C0_H1"},
+			},
+			{
+				name:          "standalone host",
+				key:           TopologyLabelEsxiHost,
+				value:         "DC0_H0",
+				expectedHosts: []string{"DC0_H0"},
+			},
+			{
+				name:      "unknown cluster",
+				key:       TopologyLabelVSphereCluster,
+				value:     "DC0_C9",
+				expectErr: true,
+			},
+			{
+				name:      "host name looked up as cluster",
+				key:       TopologyLabelVSphereCluster,
+				value:     "DC0_H0",
+				expectErr: true,
+			},
+		}
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				hosts, err := fetchHostsByInventoryName(ctx, test.key, test.value, vCenter)
+				if test.expectErr {
+					if err == nil {
+						t.Fatalf("expected an error, got hosts %v", hosts)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				var hostNames []string
+				for _, host := range hosts {
+					hostName, err := host.ObjectName(ctx)
+					if err != nil {
+						t.Fatal(err)
+					}
+					hostNames = append(hostNames, hostName)
+				}
+				sort.Strings(hostNames)
+				if len(hostNames) != len(test.expectedHosts) {
+					t.Fatalf("expected hosts %v, got %v", test.expectedHosts, hostNames)
+				}
+				for i := range hostNames {
+					if hostNames[i] != test.expectedHosts[i] {
+						t.Fatalf("expected hosts %v, got %v", test.expectedHosts, hostNames)
+					}
+				}
+			})
+		}
+	})
+}
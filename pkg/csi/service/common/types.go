@@ -27,12 +27,22 @@ import (
 
 var (
 	// BlockVolumeCaps represents how the block volume could be accessed.
-	// CNS block volumes support only SINGLE_NODE_WRITER where the volume is
-	// attached to a single node at any given time.
+	// CNS block volumes support only single node access modes where the
+	// volume is attached to a single node at any given time.
+	// SINGLE_NODE_SINGLE_WRITER and SINGLE_NODE_MULTI_WRITER are only
+	// requested once the driver advertises the SINGLE_NODE_MULTI_WRITER
+	// capability, for ReadWriteOncePod and ReadWriteOnce volumes
+	// respectively.
 	BlockVolumeCaps = []csi.VolumeCapability_AccessMode{
 		{
 			Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
 		},
+		{
+			Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_SINGLE_WRITER,
+		},
+		{
+			Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_MULTI_WRITER,
+		},
 	}
 
 	// FileVolumeCaps represents how the file volume could be accessed.
@@ -50,6 +60,16 @@ var (
 		},
 	}
 
+	// MultiWriterBlockVolumeCaps represents how a multi-writer block volume
+	// could be accessed. Multi-writer block volumes are only supported as raw
+	// block devices, the clustered filesystem on top of them is managed by the
+	// application.
+	MultiWriterBlockVolumeCaps = []csi.VolumeCapability_AccessMode{
+		{
+			Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+		},
+	}
+
 	// ErrNotFound represents not found error
 	ErrNotFound = errors.New("not found")
 )
@@ -95,4 +115,16 @@ type StorageClassParams struct {
 	StoragePolicyName string
 	CSIMigration      string
 	Datastore         string
+	MultiWriter       bool
+	NodeLocal         bool
+	ReclaimAction     string
+	MaxVolumeSizeGb   int64
+	DatastoreFolder   string
+	// ProvisioningType is one of the BaseConfigInfoDiskFileBackingInfo
+	// provisioning types.
+	ProvisioningType string
+	// PvcName and PvcNamespace are set by the external-provisioner when it
+	// runs with --extra-create-metadata.
+	PvcName      string
+	PvcNamespace string
 }
@@ -18,6 +18,8 @@ package common
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"os"
@@ -46,6 +48,14 @@ const (
 
 var ErrAvailabilityZoneCRNotRegistered = errors.New("AvailabilityZone custom resource not registered")
 
+// provisioningTypes maps the values of the AttributeProvisioningType
+// StorageClass parameter to the provisioning types of virtual disks.
+var provisioningTypes = map[string]string{
+	"thin":             string(types.BaseConfigInfoDiskFileBackingInfoProvisioningTypeThin),
+	"lazyzeroedthick":  string(types.BaseConfigInfoDiskFileBackingInfoProvisioningTypeLazyZeroedThick),
+	"eagerzeroedthick": string(types.BaseConfigInfoDiskFileBackingInfoProvisioningTypeEagerZeroedThick),
+}
+
 // GetVCenter returns VirtualCenter object from specified Manager object.
 // Before returning VirtualCenter object, vcenter connection is established if
 // session doesn't exist.
@@ -139,6 +149,9 @@ func GetLabelsMapFromKeyValue(labels []types.KeyValue) map[string]string {
 
 // IsFileVolumeRequest checks whether the request is to create a CNS file volume.
 func IsFileVolumeRequest(ctx context.Context, capabilities []*csi.VolumeCapability) bool {
+	if IsMultiWriterBlockVolumeRequest(ctx, capabilities) {
+		return false
+	}
 	for _, capability := range capabilities {
 		if capability.AccessMode.Mode == csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY ||
 			capability.AccessMode.Mode == csi.VolumeCapability_AccessMode_MULTI_NODE_SINGLE_WRITER ||
@@ -149,6 +162,21 @@ func IsFileVolumeRequest(ctx context.Context, capabilities []*csi.VolumeCapabili
 	return false
 }
 
+// IsMultiWriterBlockVolumeRequest checks whether the request is for a raw
+// block volume which is accessed by multiple nodes with MULTI_NODE_MULTI_WRITER.
+func IsMultiWriterBlockVolumeRequest(ctx context.Context, capabilities []*csi.VolumeCapability) bool {
+	if len(capabilities) == 0 {
+		return false
+	}
+	for _, capability := range capabilities {
+		if capability.GetBlock() == nil ||
+			capability.GetAccessMode().GetMode() != csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER {
+			return false
+		}
+	}
+	return true
+}
+
 // IsVolumeReadOnly checks the access mode in Volume Capability and decides
 // if volume is readonly or not.
 func IsVolumeReadOnly(capability *csi.VolumeCapability) bool {
@@ -179,8 +207,10 @@ func validateVolumeCapabilities(volCaps []*csi.VolumeCapability,
 				csi.VolumeCapability_AccessMode_Mode_name[int32(volCap.AccessMode.GetMode())], volumeType)
 		}
 
-		if volCap.AccessMode.Mode == csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER {
-			// For ReadWriteOnce access mode we only support following filesystems:
+		if volCap.AccessMode.Mode == csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER ||
+			volCap.AccessMode.Mode == csi.VolumeCapability_AccessMode_SINGLE_NODE_SINGLE_WRITER ||
+			volCap.AccessMode.Mode == csi.VolumeCapability_AccessMode_SINGLE_NODE_MULTI_WRITER {
+			// For ReadWriteOnce and ReadWriteOncePod access modes we only support following filesystems:
 			// ext3, ext4, xfs for Linux and ntfs for Windows.
 			if volCap.GetMount() != nil && !(volCap.GetMount().FsType == Ext4FsType ||
 				volCap.GetMount().FsType == Ext3FsType || volCap.GetMount().FsType == XFSType ||
@@ -199,8 +229,9 @@ func validateVolumeCapabilities(volCaps []*csi.VolumeCapability,
 				volCap.GetMount().FsType == "") {
 				return fmt.Errorf("fstype %s not supported for ReadWriteMany or ReadOnlyMany volume creation",
 					volCap.GetMount().FsType)
-			} else if volCap.GetBlock() != nil {
+			} else if volCap.GetBlock() != nil && volumeType != BlockVolumeType {
 				// Raw Block volumes are not supported with ReadWriteMany or ReadOnlyMany access modes,
+				// other than for multi-writer block volumes.
 				return fmt.Errorf("block volume mode is not supported for ReadWriteMany or ReadOnlyMany " +
 					"volume creation")
 			}
@@ -212,6 +243,9 @@ func validateVolumeCapabilities(volCaps []*csi.VolumeCapability,
 // IsValidVolumeCapabilities helps validate the given volume capabilities
 // based on volume type.
 func IsValidVolumeCapabilities(ctx context.Context, volCaps []*csi.VolumeCapability) error {
+	if IsMultiWriterBlockVolumeRequest(ctx, volCaps) {
+		return validateVolumeCapabilities(volCaps, MultiWriterBlockVolumeCaps, BlockVolumeType)
+	}
 	if IsFileVolumeRequest(ctx, volCaps) {
 		return validateVolumeCapabilities(volCaps, FileVolumeCaps, FileVolumeType)
 	}
@@ -236,6 +270,47 @@ func ParseStorageClassParams(ctx context.Context, params map[string]string,
 				scParams.StoragePolicyName = value
 			} else if param == AttributeFsType {
 				log.Warnf("param 'fstype' is deprecated, please use 'csi.storage.k8s.io/fstype' instead")
+			} else if param == AttributeMultiWriter {
+				multiWriter, err := strconv.ParseBool(value)
+				if err != nil {
+					return nil, fmt.Errorf("invalid value %q for param %q", value, param)
+				}
+				scParams.MultiWriter = multiWriter
+			} else if param == AttributeNodeLocal {
+				nodeLocal, err := strconv.ParseBool(value)
+				if err != nil {
+					return nil, fmt.Errorf("invalid value %q for param %q", value, param)
+				}
+				scParams.NodeLocal = nodeLocal
+			} else if param == AttributeReclaimAction {
+				value = strings.ToLower(value)
+				if value != ReclaimActionDelete && value != ReclaimActionArchive {
+					return nil, fmt.Errorf("invalid value %q for param %q", value, param)
+				}
+				scParams.ReclaimAction = value
+			} else if param == AttributeMaxVolumeSizeGb {
+				maxVolumeSizeGb, err := strconv.ParseInt(value, 10, 64)
+				if err != nil || maxVolumeSizeGb <= 0 {
+					return nil, fmt.Errorf("invalid value %q for param %q", value, param)
+				}
+				scParams.MaxVolumeSizeGb = maxVolumeSizeGb
+			} else if param == AttributeDatastoreFolder {
+				if err := validateDatastoreFolder(value); err != nil {
+					return nil, fmt.Errorf("invalid value %q for param %q: %v", value, param, err)
+				}
+				scParams.DatastoreFolder = value
+			} else if param == AttributeProvisioningType {
+				provisioningType, ok := provisioningTypes[strings.ToLower(value)]
+				if !ok {
+					return nil, fmt.Errorf("invalid value %q for param %q", value, param)
+				}
+				scParams.ProvisioningType = provisioningType
+			} else if param == AttributePvcName {
+				scParams.PvcName = value
+			} else if param == AttributePvcNamespace {
+				scParams.PvcNamespace = value
+			} else if param == AttributePvName {
+				continue
 			} else {
 				return nil, fmt.Errorf("invalid param: %q and value: %q", param, value)
 			}
@@ -250,6 +325,47 @@ func ParseStorageClassParams(ctx context.Context, params map[string]string,
 				scParams.StoragePolicyName = value
 			} else if param == AttributeFsType {
 				log.Warnf("param 'fstype' is deprecated, please use 'csi.storage.k8s.io/fstype' instead")
+			} else if param == AttributeMultiWriter {
+				multiWriter, err := strconv.ParseBool(value)
+				if err != nil {
+					return nil, fmt.Errorf("invalid value %q for param %q", value, param)
+				}
+				scParams.MultiWriter = multiWriter
+			} else if param == AttributeNodeLocal {
+				nodeLocal, err := strconv.ParseBool(value)
+				if err != nil {
+					return nil, fmt.Errorf("invalid value %q for param %q", value, param)
+				}
+				scParams.NodeLocal = nodeLocal
+			} else if param == AttributeReclaimAction {
+				value = strings.ToLower(value)
+				if value != ReclaimActionDelete && value != ReclaimActionArchive {
+					return nil, fmt.Errorf("invalid value %q for param %q", value, param)
+				}
+				scParams.ReclaimAction = value
+			} else if param == AttributeMaxVolumeSizeGb {
+				maxVolumeSizeGb, err := strconv.ParseInt(value, 10, 64)
+				if err != nil || maxVolumeSizeGb <= 0 {
+					return nil, fmt.Errorf("invalid value %q for param %q", value, param)
+				}
+				scParams.MaxVolumeSizeGb = maxVolumeSizeGb
+			} else if param == AttributeDatastoreFolder {
+				if err := validateDatastoreFolder(value); err != nil {
+					return nil, fmt.Errorf("invalid value %q for param %q: %v", value, param, err)
+				}
+				scParams.DatastoreFolder = value
+			} else if param == AttributeProvisioningType {
+				provisioningType, ok := provisioningTypes[strings.ToLower(value)]
+				if !ok {
+					return nil, fmt.Errorf("invalid value %q for param %q", value, param)
+				}
+				scParams.ProvisioningType = provisioningType
+			} else if param == AttributePvcName {
+				scParams.PvcName = value
+			} else if param == AttributePvcNamespace {
+				scParams.PvcNamespace = value
+			} else if param == AttributePvName {
+				continue
 			} else if param == CSIMigrationParams {
 				scParams.CSIMigration = value
 			} else {
@@ -280,9 +396,102 @@ func ParseStorageClassParams(ctx context.Context, params map[string]string,
 			}
 		}
 	}
+	if scParams.DatastoreFolder != "" && scParams.DatastoreURL == "" && scParams.Datastore == "" {
+		return nil, fmt.Errorf("param %q requires param %q", AttributeDatastoreFolder, AttributeDatastoreURL)
+	}
 	return scParams, nil
 }
 
+// GenerateVolumeName returns the name of the CNS volume of the PV with the
+// given name, generated from the volume name template of the driver config.
+// The name of the PV is returned when no template is configured or the
+// external-provisioner does not pass the name and namespace of the PVC.
+// The cluster ID, namespace and PVC name are shortened, longest first, to
+// keep the name within cnsconfig.MaxVolumeNameLength characters, while the
+// hash of the PV name keeps names unique.
+func GenerateVolumeName(template string, clusterID string, pvName string, scParams *StorageClassParams) string {
+	if template == "" || scParams.PvcName == "" || scParams.PvcNamespace == "" {
+		return pvName
+	}
+	sum := sha256.Sum256([]byte(pvName))
+	hash := hex.EncodeToString(sum[:])[:cnsconfig.VolumeNameHashLength]
+
+	placeholders := []string{cnsconfig.VolumeNameTemplateCluster, cnsconfig.VolumeNameTemplateNamespace,
+		cnsconfig.VolumeNameTemplatePVC}
+	values := []string{clusterID, scParams.PvcNamespace, scParams.PvcName}
+	counts := make([]int, len(placeholders))
+	fixed := strings.ReplaceAll(template, cnsconfig.VolumeNameTemplateHash, hash)
+	for i, placeholder := range placeholders {
+		counts[i] = strings.Count(fixed, placeholder)
+		fixed = strings.ReplaceAll(fixed, placeholder, "")
+	}
+	length := func() int {
+		total := len(fixed)
+		for i := range values {
+			total += counts[i] * len(values[i])
+		}
+		return total
+	}
+	for length() > cnsconfig.MaxVolumeNameLength {
+		longest := -1
+		for i := range values {
+			if counts[i] > 0 && len(values[i]) > 1 && (longest < 0 || len(values[i]) > len(values[longest])) {
+				longest = i
+			}
+		}
+		if longest < 0 {
+			break
+		}
+		values[longest] = values[longest][:len(values[longest])-1]
+	}
+
+	name := strings.ReplaceAll(template, cnsconfig.VolumeNameTemplateHash, hash)
+	for i, placeholder := range placeholders {
+		name = strings.ReplaceAll(name, placeholder, values[i])
+	}
+	return name
+}
+
+// GetMaxBlockVolumeSizeInMb returns the maximum size, in MiB, of a new block
+// volume of the given Storage Class, before the limits of the datastore types
+// are applied.
+func GetMaxBlockVolumeSizeInMb(limits cnsconfig.VolumeSizeLimitsConfig, scParams *StorageClassParams) int64 {
+	maxSizeMb := MaxBlockVolumeSizeInMb
+	if limits.GlobalMaxVolumeSizeInGb > 0 && limits.GlobalMaxVolumeSizeInGb*GbInMb < maxSizeMb {
+		maxSizeMb = limits.GlobalMaxVolumeSizeInGb * GbInMb
+	}
+	if scParams.MaxVolumeSizeGb > 0 && scParams.MaxVolumeSizeGb*GbInMb < maxSizeMb {
+		maxSizeMb = scParams.MaxVolumeSizeGb * GbInMb
+	}
+	return maxSizeMb
+}
+
+// FilterDatastoresByMaxVolumeSize returns the datastores whose datastore
+// type allows block volumes of the given size, in MiB.
+func FilterDatastoresByMaxVolumeSize(ctx context.Context, datastores []*cnsvsphere.DatastoreInfo,
+	limits cnsconfig.VolumeSizeLimitsConfig, volSizeMB int64) []*cnsvsphere.DatastoreInfo {
+	log := logger.GetLogger(ctx)
+	if limits.GranularMaxVolumeSizeInGbInVSAN == 0 && limits.GranularMaxVolumeSizeInGbInVVOL == 0 {
+		return datastores
+	}
+	var filteredDatastores []*cnsvsphere.DatastoreInfo
+	for _, datastore := range datastores {
+		var maxSizeGb int64
+		if strings.Contains(datastore.Info.Url, strings.ToLower(string(types.HostFileSystemVolumeFileSystemTypeVsan))) {
+			maxSizeGb = limits.GranularMaxVolumeSizeInGbInVSAN
+		} else if strings.Contains(datastore.Info.Url,
+			strings.ToLower(string(types.HostFileSystemVolumeFileSystemTypeVVOL))) {
+			maxSizeGb = limits.GranularMaxVolumeSizeInGbInVVOL
+		}
+		if maxSizeGb > 0 && volSizeMB > maxSizeGb*GbInMb {
+			log.Debugf("filter out datastore %q, its maximum volume size is %d GiB", datastore.Info.Url, maxSizeGb)
+			continue
+		}
+		filteredDatastores = append(filteredDatastores, datastore)
+	}
+	return filteredDatastores
+}
+
 // GetK8sCloudOperatorServicePort return the port to connect the
 // K8sCloudOperator gRPC service.
 // If environment variable POD_LISTENER_SERVICE_PORT is set and valid,
@@ -336,22 +545,61 @@ func ConvertVolumeHealthStatus(ctx context.Context, volID string, volHealthStatu
 // ParseCSISnapshotID parses the SnapshotID from CSI RPC such as DeleteSnapshot, CreateVolume from snapshot
 // into a pair of CNS VolumeID and CNS SnapshotID.
 func ParseCSISnapshotID(csiSnapshotID string) (string, string, error) {
+	cnsVolumeID, cnsSnapshotID, _, err := ParseCSISnapshotIDWithVCenter(csiSnapshotID)
+	return cnsVolumeID, cnsSnapshotID, err
+}
+
+// ParseCSISnapshotIDWithVCenter parses the SnapshotID from CSI RPC into the
+// CNS VolumeID, CNS SnapshotID and the vCenter of the snapshot. The vCenter is
+// empty for snapshot IDs that are not scoped to a vCenter.
+func ParseCSISnapshotIDWithVCenter(csiSnapshotID string) (string, string, string, error) {
 	if csiSnapshotID == "" {
-		return "", "", errors.New("csiSnapshotID from the input is empty")
+		return "", "", "", errors.New("csiSnapshotID from the input is empty")
 	}
 
 	// The expected format of the SnapshotId in the DeleteSnapshotRequest is,
 	// a combination of CNS VolumeID and CNS SnapshotID concatenated by the "+" sign.
-	// That is, a string of "<UUID>+<UUID>". Decompose csiSnapshotID based on the expected format.
+	// That is, a string of "<UUID>+<UUID>". In multi vCenter deployments, the
+	// vCenter of the snapshot is appended, i.e. "<UUID>+<UUID>+<vCenter>".
+	// Decompose csiSnapshotID based on the expected format.
 	IDs := strings.Split(csiSnapshotID, VSphereCSISnapshotIdDelimiter)
-	if len(IDs) != 2 {
-		return "", "", fmt.Errorf("unexpected format in csiSnapshotID: %v", csiSnapshotID)
+	if len(IDs) != 2 && len(IDs) != 3 {
+		return "", "", "", fmt.Errorf("unexpected format in csiSnapshotID: %v", csiSnapshotID)
 	}
 
 	cnsVolumeID := IDs[0]
 	cnsSnapshotID := IDs[1]
+	var vCenterHost string
+	if len(IDs) == 3 {
+		vCenterHost = IDs[2]
+		if vCenterHost == "" {
+			return "", "", "", fmt.Errorf("unexpected format in csiSnapshotID: %v", csiSnapshotID)
+		}
+	}
+
+	return cnsVolumeID, cnsSnapshotID, vCenterHost, nil
+}
+
+// ScopeCSISnapshotID returns the given SnapshotID scoped to the given
+// vCenter. SnapshotIDs that are already scoped are returned as is.
+func ScopeCSISnapshotID(csiSnapshotID string, vCenterHost string) string {
+	if strings.Count(csiSnapshotID, VSphereCSISnapshotIdDelimiter) != 1 || vCenterHost == "" {
+		return csiSnapshotID
+	}
+	return csiSnapshotID + VSphereCSISnapshotIdDelimiter + vCenterHost
+}
 
-	return cnsVolumeID, cnsSnapshotID, nil
+// ParseOwnershipID parses the given uid or gid of the owner of a volume,
+// which must be a non-negative 32-bit integer.
+func ParseOwnershipID(value string) (int, error) {
+	id, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	if id < 0 {
+		return 0, fmt.Errorf("negative id %d", id)
+	}
+	return int(id), nil
 }
 
 // Contains check if item exist in list
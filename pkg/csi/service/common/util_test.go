@@ -20,12 +20,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/vmware/govmomi/vim25/types"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	cnsconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
 )
 
 var (
@@ -168,6 +173,36 @@ func TestValidVolumeCapabilitiesForBlock(t *testing.T) {
 	if err := IsValidVolumeCapabilities(ctx, volCap); err != nil {
 		t.Errorf("Block VolCap = %+v failed validation!", volCap)
 	}
+	// fstype=ext4 and mode=SINGLE_NODE_SINGLE_WRITER
+	volCap = []*csi.VolumeCapability{
+		{
+			AccessType: &csi.VolumeCapability_Mount{
+				Mount: &csi.VolumeCapability_MountVolume{
+					FsType: "ext4",
+				},
+			},
+			AccessMode: &csi.VolumeCapability_AccessMode{
+				Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_SINGLE_WRITER,
+			},
+		},
+	}
+	if err := IsValidVolumeCapabilities(ctx, volCap); err != nil {
+		t.Errorf("Block VolCap = %+v failed validation!", volCap)
+	}
+	// volumeMode=block and accessMode=SINGLE_NODE_MULTI_WRITER
+	volCap = []*csi.VolumeCapability{
+		{
+			AccessType: &csi.VolumeCapability_Block{
+				Block: &csi.VolumeCapability_BlockVolume{},
+			},
+			AccessMode: &csi.VolumeCapability_AccessMode{
+				Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_MULTI_WRITER,
+			},
+		},
+	}
+	if err := IsValidVolumeCapabilities(ctx, volCap); err != nil {
+		t.Errorf("Block VolCap = %+v failed validation!", volCap)
+	}
 }
 
 func TestInvalidVolumeCapabilitiesForBlock(t *testing.T) {
@@ -326,37 +361,141 @@ func TestInvalidVolumeCapabilitiesForFile(t *testing.T) {
 		t.Errorf("Invalid file VolCap = %+v passed validation!", volCap)
 	}
 
-	// Invalid case: volumeMode=block and accessMode=MULTI_NODE_MULTI_WRITER
+	// Invalid case: volumeMode=block and accessMode=MULTI_NODE_READER_ONLY
 	volCap = []*csi.VolumeCapability{
 		{
 			AccessType: &csi.VolumeCapability_Block{
 				Block: &csi.VolumeCapability_BlockVolume{},
 			},
 			AccessMode: &csi.VolumeCapability_AccessMode{
-				Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+				Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY,
 			},
 		},
 	}
 	if err := IsValidVolumeCapabilities(ctx, volCap); err == nil {
 		t.Errorf("Invalid file VolCap = %+v passed validation!", volCap)
 	}
+}
 
-	// Invalid case: volumeMode=block and accessMode=MULTI_NODE_READER_ONLY
-	volCap = []*csi.VolumeCapability{
+func TestVolumeCapabilitiesForMultiWriterBlock(t *testing.T) {
+	// Valid case: volumeMode=block and accessMode=MULTI_NODE_MULTI_WRITER
+	volCap := []*csi.VolumeCapability{
 		{
 			AccessType: &csi.VolumeCapability_Block{
 				Block: &csi.VolumeCapability_BlockVolume{},
 			},
 			AccessMode: &csi.VolumeCapability_AccessMode{
-				Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY,
+				Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
 			},
 		},
 	}
+	if !IsMultiWriterBlockVolumeRequest(ctx, volCap) {
+		t.Errorf("VolCap = %+v is expected to be a multi-writer block volume request", volCap)
+	}
+	if IsFileVolumeRequest(ctx, volCap) {
+		t.Errorf("VolCap = %+v is not expected to be a file volume request", volCap)
+	}
+	if err := IsValidVolumeCapabilities(ctx, volCap); err != nil {
+		t.Errorf("Multi-writer block VolCap = %+v failed validation!", volCap)
+	}
+
+	// Invalid case: volumeMode=block with both MULTI_NODE_MULTI_WRITER and MULTI_NODE_READER_ONLY
+	volCap = append(volCap, &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Block{
+			Block: &csi.VolumeCapability_BlockVolume{},
+		},
+		AccessMode: &csi.VolumeCapability_AccessMode{
+			Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY,
+		},
+	})
+	if IsMultiWriterBlockVolumeRequest(ctx, volCap) {
+		t.Errorf("VolCap = %+v is not expected to be a multi-writer block volume request", volCap)
+	}
 	if err := IsValidVolumeCapabilities(ctx, volCap); err == nil {
-		t.Errorf("Invalid file VolCap = %+v passed validation!", volCap)
+		t.Errorf("Invalid VolCap = %+v passed validation!", volCap)
+	}
+}
+
+func TestParseStorageClassParamsWithMultiWriter(t *testing.T) {
+	params := map[string]string{
+		AttributeStoragePolicyName: "policy1",
+		AttributeMultiWriter:       "true",
+	}
+	actualScParams, err := ParseStorageClassParams(ctx, params, false)
+	if err != nil {
+		t.Errorf("failed to parse params: %+v", params)
+	}
+	if !actualScParams.MultiWriter {
+		t.Errorf("Expected MultiWriter to be set for params: %+v", params)
+	}
+
+	params[AttributeMultiWriter] = "yes-please"
+	if _, err = ParseStorageClassParams(ctx, params, true); err == nil {
+		t.Errorf("Expected error for invalid %q value in params: %+v", AttributeMultiWriter, params)
 	}
 }
 
+func TestParseStorageClassParamsWithMaxVolumeSize(t *testing.T) {
+	params := map[string]string{
+		AttributeStoragePolicyName: "policy1",
+		AttributeMaxVolumeSizeGb:   "1024",
+	}
+	actualScParams, err := ParseStorageClassParams(ctx, params, false)
+	if err != nil {
+		t.Errorf("failed to parse params: %+v", params)
+	} else if actualScParams.MaxVolumeSizeGb != 1024 {
+		t.Errorf("Expected MaxVolumeSizeGb 1024 for params: %+v, got: %d", params, actualScParams.MaxVolumeSizeGb)
+	}
+
+	for _, value := range []string{"0", "-1", "1Ti"} {
+		params[AttributeMaxVolumeSizeGb] = value
+		if _, err = ParseStorageClassParams(ctx, params, true); err == nil {
+			t.Errorf("Expected error for invalid %q value in params: %+v", AttributeMaxVolumeSizeGb, params)
+		}
+	}
+}
+
+func TestGetMaxBlockVolumeSizeInMb(t *testing.T) {
+	limits := cnsconfig.VolumeSizeLimitsConfig{}
+	scParams := &StorageClassParams{}
+	assert.Equal(t, MaxBlockVolumeSizeInMb, GetMaxBlockVolumeSizeInMb(limits, scParams))
+
+	limits.GlobalMaxVolumeSizeInGb = 2048
+	assert.Equal(t, 2048*GbInMb, GetMaxBlockVolumeSizeInMb(limits, scParams))
+
+	scParams.MaxVolumeSizeGb = 100
+	assert.Equal(t, 100*GbInMb, GetMaxBlockVolumeSizeInMb(limits, scParams))
+
+	// The limit of the storage class can't raise the global limit.
+	scParams.MaxVolumeSizeGb = 4096
+	assert.Equal(t, 2048*GbInMb, GetMaxBlockVolumeSizeInMb(limits, scParams))
+}
+
+func TestFilterDatastoresByMaxVolumeSize(t *testing.T) {
+	vsanDatastore := &cnsvsphere.DatastoreInfo{
+		Info: &types.DatastoreInfo{Url: "ds:///vmfs/volumes/vsan:52d6e6d5a8a9c4b7-8f1a2b3c4d5e6f70/"},
+	}
+	vvolDatastore := &cnsvsphere.DatastoreInfo{
+		Info: &types.DatastoreInfo{Url: "ds:///vmfs/volumes/vvol:5a3f2b1c9d8e7f60-a1b2c3d4e5f60718/"},
+	}
+	vmfsDatastore := &cnsvsphere.DatastoreInfo{
+		Info: &types.DatastoreInfo{Url: "ds:///vmfs/volumes/62d9f3a4-0b5c1e2d-7f3a-0050569b1c2d/"},
+	}
+	datastores := []*cnsvsphere.DatastoreInfo{vsanDatastore, vvolDatastore, vmfsDatastore}
+
+	limits := cnsconfig.VolumeSizeLimitsConfig{}
+	assert.Equal(t, datastores, FilterDatastoresByMaxVolumeSize(ctx, datastores, limits, 100*GbInMb))
+
+	limits.GranularMaxVolumeSizeInGbInVSAN = 50
+	assert.Equal(t, []*cnsvsphere.DatastoreInfo{vvolDatastore, vmfsDatastore},
+		FilterDatastoresByMaxVolumeSize(ctx, datastores, limits, 100*GbInMb))
+	assert.Equal(t, datastores, FilterDatastoresByMaxVolumeSize(ctx, datastores, limits, 50*GbInMb))
+
+	limits.GranularMaxVolumeSizeInGbInVVOL = 10
+	assert.Equal(t, []*cnsvsphere.DatastoreInfo{vmfsDatastore},
+		FilterDatastoresByMaxVolumeSize(ctx, datastores, limits, 100*GbInMb))
+}
+
 func isStorageClassParamsEqual(expected *StorageClassParams, actual *StorageClassParams) bool {
 	if expected.DatastoreURL != actual.DatastoreURL {
 		return false
@@ -523,6 +662,14 @@ func TestParseCSISnapshotID(t *testing.T) {
 			expectedCnsSnapshotID: "",
 			expectedErr:           fmt.Errorf("unexpected format in csiSnapshotID: %v", sampleCnsVolumeID),
 		},
+		{
+			name: "VCenterScopedCSISnapshotID",
+			args: args{ctx: context.TODO(),
+				csiSnapshotID: sampleCnsVolumeID + "+" + sampleCnsSnapshotID + "+vc1.example.com"},
+			expectedCnsVolumeID:   sampleCnsVolumeID,
+			expectedCnsSnapshotID: sampleCnsSnapshotID,
+			expectedErr:           nil,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -536,3 +683,83 @@ func TestParseCSISnapshotID(t *testing.T) {
 		})
 	}
 }
+
+func TestScopeCSISnapshotID(t *testing.T) {
+	csiSnapshotID := uuid.New().String() + "+" + uuid.New().String()
+	scopedID := ScopeCSISnapshotID(csiSnapshotID, "vc1.example.com")
+	assert.Equal(t, csiSnapshotID+"+vc1.example.com", scopedID)
+	// Scoped IDs and empty vCenters are left as is.
+	assert.Equal(t, scopedID, ScopeCSISnapshotID(scopedID, "vc2.example.com"))
+	assert.Equal(t, csiSnapshotID, ScopeCSISnapshotID(csiSnapshotID, ""))
+
+	volumeID, snapshotID, vCenterHost, err := ParseCSISnapshotIDWithVCenter(scopedID)
+	assert.NoError(t, err)
+	assert.Equal(t, csiSnapshotID, volumeID+"+"+snapshotID)
+	assert.Equal(t, "vc1.example.com", vCenterHost)
+
+	_, _, vCenterHost, err = ParseCSISnapshotIDWithVCenter(csiSnapshotID)
+	assert.NoError(t, err)
+	assert.Empty(t, vCenterHost)
+
+	_, _, _, err = ParseCSISnapshotIDWithVCenter(csiSnapshotID + "+")
+	assert.Error(t, err)
+}
+
+func TestGenerateVolumeName(t *testing.T) {
+	pvName := "pvc-2b6f1d2e-5a0b-4c0e-9a4e-3f1c2d7e8b90"
+	scParams := &StorageClassParams{PvcName: "data-postgres-0", PvcNamespace: "db"}
+
+	// The PV name is kept without template or PVC metadata.
+	assert.Equal(t, pvName, GenerateVolumeName("", "cluster1", pvName, scParams))
+	assert.Equal(t, pvName, GenerateVolumeName("{pvc}-{hash}", "cluster1", pvName, &StorageClassParams{}))
+
+	name := GenerateVolumeName("{cluster}-{namespace}-{pvc}-{hash}", "cluster1", pvName, scParams)
+	assert.Regexp(t, "^cluster1-db-data-postgres-0-[0-9a-f]{8}$", name)
+	assert.Equal(t, name, GenerateVolumeName("{cluster}-{namespace}-{pvc}-{hash}", "cluster1", pvName, scParams))
+	assert.NotEqual(t, name, GenerateVolumeName("{cluster}-{namespace}-{pvc}-{hash}", "cluster1",
+		"pvc-7c0e4a91-1d3b-4f2a-8e6c-5b9d0a2f4c13", scParams))
+
+	// Long values are shortened, keeping the hash.
+	longParams := &StorageClassParams{PvcName: strings.Repeat("p", 100), PvcNamespace: "db"}
+	name = GenerateVolumeName("{cluster}-{namespace}-{pvc}-{hash}", "cluster1", pvName, longParams)
+	assert.Len(t, name, cnsconfig.MaxVolumeNameLength)
+	assert.Regexp(t, "^cluster1-db-p+-[0-9a-f]{8}$", name)
+}
+
+func TestParseStorageClassParamsWithDatastoreFolder(t *testing.T) {
+	params := map[string]string{
+		AttributeDatastoreURL:    "ds:///vmfs/volumes/62d9f3a4-0b5c1e2d-7f3a-0050569b1c2d/",
+		AttributeDatastoreFolder: "kubernetes/team-a",
+	}
+	scParams, err := ParseStorageClassParams(ctx, params, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "kubernetes/team-a", scParams.DatastoreFolder)
+
+	for _, folder := range []string{"", "/kubernetes", "kubernetes/../team-a", "kubernetes//team-a", "[ds] team-a"} {
+		params[AttributeDatastoreFolder] = folder
+		_, err = ParseStorageClassParams(ctx, params, false)
+		assert.Error(t, err, "folder %q", folder)
+	}
+
+	// The datastore of the folder must be given.
+	_, err = ParseStorageClassParams(ctx, map[string]string{AttributeDatastoreFolder: "team-a"}, false)
+	assert.Error(t, err)
+}
+
+func TestParseStorageClassParamsWithProvisioningType(t *testing.T) {
+	for value, expected := range map[string]string{
+		"thin":             "thin",
+		"LazyZeroedThick":  "lazyZeroedThick",
+		"eagerzeroedthick": "eagerZeroedThick",
+	} {
+		for _, csiMigrationEnabled := range []bool{false, true} {
+			scParams, err := ParseStorageClassParams(ctx, map[string]string{AttributeProvisioningType: value},
+				csiMigrationEnabled)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, scParams.ProvisioningType)
+		}
+	}
+
+	_, err := ParseStorageClassParams(ctx, map[string]string{AttributeProvisioningType: "zeroedthick"}, false)
+	assert.Error(t, err)
+}
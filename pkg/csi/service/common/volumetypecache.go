@@ -0,0 +1,98 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/cache"
+
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+)
+
+const (
+	// volumeTypeCacheSize is the maximum number of volumes whose CNS volume
+	// type is cached. The least recently used entries are evicted first.
+	volumeTypeCacheSize = 10000
+	// volumeTypeCacheTTL is the time after which a cached CNS volume type
+	// expires, so that the entries of volumes deleted out of band don't
+	// stay forever.
+	volumeTypeCacheTTL = 6 * time.Hour
+)
+
+// volumeTypes caches the CNS volume type, BlockVolumeType or FileVolumeType,
+// of volumes by volume ID. It is filled from CNS query results and from the
+// volumes created by this process.
+var volumeTypes = cache.NewLRUExpireCache(volumeTypeCacheSize)
+
+// SetVolumeType records the CNS volume type of a volume.
+func SetVolumeType(volumeID, volumeType string) {
+	if volumeID == "" || (volumeType != BlockVolumeType && volumeType != FileVolumeType) {
+		return
+	}
+	volumeTypes.Add(volumeID, volumeType, volumeTypeCacheTTL)
+}
+
+// GetVolumeType returns the cached CNS volume type of a volume.
+func GetVolumeType(volumeID string) (string, bool) {
+	volumeType, ok := volumeTypes.Get(volumeID)
+	if !ok {
+		return "", false
+	}
+	return volumeType.(string), true
+}
+
+// ForgetVolumeType removes the cached CNS volume type of a deleted volume.
+func ForgetVolumeType(volumeID string) {
+	volumeTypes.Remove(volumeID)
+}
+
+// IsFileVolumePV returns true if the given PV is a file volume. The CNS
+// volume type cached for the volume handle of a PV of the driver is used
+// first, then the fields of the PV as in IsFileVolumePVSpec.
+func IsFileVolumePV(pv *v1.PersistentVolume) bool {
+	if pv.Spec.CSI != nil && pv.Spec.CSI.Driver == csitypes.DriverName() {
+		if volumeType, ok := GetVolumeType(pv.Spec.CSI.VolumeHandle); ok {
+			return volumeType == FileVolumeType
+		}
+	}
+	return IsFileVolumePVSpec(pv)
+}
+
+// IsFileVolumePVSpec returns true if the given PV is a file volume according
+// to its fields only, so that the result doesn't change for a given PV, as
+// index functions require. The disk type attribute set on a PV of the driver
+// at provisioning is used first. The access modes of the PV are only used as
+// a last resort, as statically provisioned PVs of block volumes may allow
+// ReadOnlyMany.
+func IsFileVolumePVSpec(pv *v1.PersistentVolume) bool {
+	if pv.Spec.CSI != nil && pv.Spec.CSI.Driver == csitypes.DriverName() {
+		switch pv.Spec.CSI.VolumeAttributes[AttributeDiskType] {
+		case DiskTypeFileVolume:
+			return true
+		case DiskTypeBlockVolume:
+			return false
+		}
+	}
+	for _, accessMode := range pv.Spec.AccessModes {
+		if accessMode == v1.ReadWriteMany || accessMode == v1.ReadOnlyMany {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+)
+
+func TestIsFileVolumePV(t *testing.T) {
+	newPV := func(volumeID string, attributes map[string]string,
+		accessModes ...v1.PersistentVolumeAccessMode) *v1.PersistentVolume {
+		return &v1.PersistentVolume{
+			Spec: v1.PersistentVolumeSpec{
+				AccessModes: accessModes,
+				PersistentVolumeSource: v1.PersistentVolumeSource{
+					CSI: &v1.CSIPersistentVolumeSource{
+						Driver:           csitypes.DriverName(),
+						VolumeHandle:     volumeID,
+						VolumeAttributes: attributes,
+					},
+				},
+			},
+		}
+	}
+
+	// Statically provisioned block volume allowing ReadOnlyMany.
+	staticBlockPV := newPV("static-block", nil, v1.ReadOnlyMany)
+	assert.True(t, IsFileVolumePV(staticBlockPV))
+	SetVolumeType("static-block", BlockVolumeType)
+	defer ForgetVolumeType("static-block")
+	assert.False(t, IsFileVolumePV(staticBlockPV))
+	// The cached type isn't used for the fields of the PV only.
+	assert.True(t, IsFileVolumePVSpec(staticBlockPV))
+
+	// The disk type attribute set at provisioning is used if the type isn't cached.
+	assert.True(t, IsFileVolumePV(newPV("file", map[string]string{AttributeDiskType: DiskTypeFileVolume},
+		v1.ReadWriteOnce)))
+	assert.False(t, IsFileVolumePV(newPV("block", map[string]string{AttributeDiskType: DiskTypeBlockVolume},
+		v1.ReadOnlyMany)))
+
+	// Access modes are used as a last resort.
+	assert.True(t, IsFileVolumePV(newPV("rwx", nil, v1.ReadWriteMany)))
+	assert.False(t, IsFileVolumePV(newPV("rwo", nil, v1.ReadWriteOnce)))
+}
+
+func TestVolumeTypeCacheIsBounded(t *testing.T) {
+	for i := 0; i <= volumeTypeCacheSize; i++ {
+		SetVolumeType(fmt.Sprintf("volume-%d", i), BlockVolumeType)
+	}
+	defer func() {
+		for i := 0; i <= volumeTypeCacheSize; i++ {
+			ForgetVolumeType(fmt.Sprintf("volume-%d", i))
+		}
+	}()
+	// The least recently used entry is evicted.
+	_, ok := GetVolumeType("volume-0")
+	assert.False(t, ok)
+	volumeType, ok := GetVolumeType(fmt.Sprintf("volume-%d", volumeTypeCacheSize))
+	assert.True(t, ok)
+	assert.Equal(t, BlockVolumeType, volumeType)
+}
@@ -72,9 +72,7 @@ func CreateBlockVolumeUtil(ctx context.Context, clusterFlavor cnstypes.CnsCluste
 		if err != nil {
 			log.Errorf("Error occurred while getting Profile Id from Profile Name: %s, err: %+v",
 				spec.ScParams.StoragePolicyName, err)
-			// TODO: need to extract fault from err returned by GetStoragePolicyIDByName.
-			// Currently, just return csi.fault.Internal.
-			return nil, csifault.CSIInternalFault, err
+			return nil, csifault.FaultTypeFromError(err, csifault.CSIInternalFault), err
 		}
 	}
 
@@ -302,6 +300,14 @@ func CreateBlockVolumeUtil(ctx context.Context, clusterFlavor cnstypes.CnsCluste
 		createSpec.Datastores = []vim25types.ManagedObjectReference{compatibleDatastore}
 	}
 
+	if spec.ScParams.DatastoreFolder != "" {
+		volumeInfo, faultType, err := prepareDatastoreFolderVolume(ctx, vc, manager.VolumeManager, spec,
+			datastoreInfoList, clusterID, createSpec)
+		if err != nil || volumeInfo != nil {
+			return volumeInfo, faultType, err
+		}
+	}
+
 	log.Debugf("vSphere CSI driver creating volume %s with create spec %+v", spec.Name, spew.Sdump(createSpec))
 	volumeInfo, faultType, err := manager.VolumeManager.CreateVolume(ctx, createSpec, extraParams)
 	if err != nil {
@@ -440,6 +446,14 @@ func CreateBlockVolumeUtilForMultiVC(ctx context.Context, reqParams interface{})
 		}
 	}
 
+	if params.Spec.ScParams.DatastoreFolder != "" {
+		volumeInfo, faultType, err := prepareDatastoreFolderVolume(ctx, params.Vcenter, params.VolumeManager,
+			params.Spec, params.SharedDatastores, clusterID, createSpec)
+		if err != nil || volumeInfo != nil {
+			return volumeInfo, faultType, err
+		}
+	}
+
 	log.Debugf("vSphere CSI driver creating volume %s with create spec %+v", params.Spec.Name, spew.Sdump(createSpec))
 	volumeInfo, faultType, err := params.VolumeManager.CreateVolume(ctx, createSpec, nil)
 	if err != nil {
@@ -464,9 +478,7 @@ func CreateFileVolumeUtil(ctx context.Context, clusterFlavor cnstypes.CnsCluster
 		if err != nil {
 			log.Errorf("Error occurred while getting Profile Id from Profile Name: %q, err: %+v",
 				spec.ScParams.StoragePolicyName, err)
-			// TODO: need to extract fault from err returned by GetStoragePolicyIDByName.
-			// Currently, just return csi.fault.Internal.
-			return "", csifault.CSIInternalFault, err
+			return "", csifault.FaultTypeFromError(err, csifault.CSIInternalFault), err
 		}
 	}
 
@@ -1073,6 +1085,10 @@ func DeleteSnapshotUtil(ctx context.Context, volumeManager cnsvolume.Manager, cs
 // GetCnsVolumeType is the helper function that determines the volume type based on the volume-id
 func GetCnsVolumeType(ctx context.Context, volumeManager cnsvolume.Manager, volumeId string) (string, error) {
 	log := logger.GetLogger(ctx)
+	if volumeType, ok := GetVolumeType(volumeId); ok {
+		log.Debugf("volume: %s is of cached type: %s", volumeId, volumeType)
+		return volumeType, nil
+	}
 	var volumeType string
 	queryFilter := cnstypes.CnsQueryFilter{
 		VolumeIds: []cnstypes.CnsVolumeId{{Id: volumeId}},
@@ -1094,6 +1110,7 @@ func GetCnsVolumeType(ctx context.Context, volumeManager cnsvolume.Manager, volu
 		return "", ErrNotFound
 	}
 	volumeType = queryResult.Volumes[0].VolumeType
+	SetVolumeType(volumeId, volumeType)
 	log.Infof("volume: %s is of type: %s", volumeId, volumeType)
 	return volumeType, nil
 }
@@ -1203,3 +1220,33 @@ func isDataStoreCompatible(ctx context.Context, vc *vsphere.VirtualCenter, spec
 	}
 	return "", nil
 }
+
+// ValidateMultiWriterStoragePolicy checks that the storage policy provisions
+// eager-zeroed thick disks, which is required for FCDs attached with the
+// multi-writer flag.
+func ValidateMultiWriterStoragePolicy(ctx context.Context, vc *vsphere.VirtualCenter,
+	storagePolicyID string) error {
+	log := logger.GetLogger(ctx)
+	if storagePolicyID == "" {
+		return logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"%q storage class parameter requires a storage policy which provisions eager-zeroed thick disks",
+			AttributeMultiWriter)
+	}
+	policies, err := vc.PbmRetrieveContent(ctx, []string{storagePolicyID})
+	if err != nil {
+		return logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to retrieve content of storage policy %q. Err: %v", storagePolicyID, err)
+	}
+	for _, policy := range policies {
+		for _, profile := range policy.Profiles {
+			for _, rule := range profile.Rules {
+				if rule.Ns == VolumeAllocationNamespace && rule.Value == VolumeAllocationFullyInitialized {
+					return nil
+				}
+			}
+		}
+	}
+	return logger.LogNewErrorCodef(log, codes.InvalidArgument,
+		"storage policy %q does not provision eager-zeroed thick disks required for multi-writer volumes",
+		storagePolicyID)
+}
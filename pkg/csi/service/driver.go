@@ -20,13 +20,16 @@ import (
 	"context"
 	"os"
 	"strings"
+	"sync/atomic"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/google/uuid"
 	cnstypes "github.com/vmware/govmomi/cns/types"
 
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/node"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
 	cnsconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/utils"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
@@ -58,6 +61,7 @@ type Driver interface {
 	GetController() csi.ControllerServer
 	BeforeServe(context.Context) error
 	Run(ctx context.Context, endpoint string)
+	Shutdown(ctx context.Context)
 }
 
 type vsphereCSIDriver struct {
@@ -67,6 +71,10 @@ type vsphereCSIDriver struct {
 	// A map storing all volumes with ongoing operations so that additional operations
 	// for that same volume (as defined by VolumeID) return an Aborted error
 	volumeLocks *node.VolumeLocks
+	// server is the gRPC server serving the CSI services.
+	server NonBlockingGRPCServer
+	// draining is true once the driver started draining on shutdown.
+	draining atomic.Bool
 }
 
 // If k8s node died unexpectedly in an earlier run, the unix socket is left
@@ -83,6 +91,7 @@ func init() {
 func NewDriver() Driver {
 	return &vsphereCSIDriver{
 		volumeLocks: node.NewVolumeLocks(),
+		server:      NewNonBlockingGRPCServer(),
 	}
 }
 
@@ -113,7 +122,7 @@ func (driver *vsphereCSIDriver) BeforeServe(ctx context.Context) error {
 	log := logger.GetLogger(ctx)
 	defer func() {
 		log.Infof("Configured: %q with clusterFlavor: %q and mode: %q",
-			csitypes.Name, clusterFlavor, driver.mode)
+			csitypes.DriverName(), clusterFlavor, driver.mode)
 	}()
 
 	var (
@@ -211,6 +220,36 @@ func (driver *vsphereCSIDriver) Run(ctx context.Context, endpoint string) {
 	}
 
 	//Start the nonblocking GRPC
-	grpc := NewNonBlockingGRPCServer()
-	grpc.Start(endpoint, driver, controllerServer, driver)
+	driver.server.Start(endpoint, driver, controllerServer, driver)
+	if driver.draining.Load() {
+		// The gRPC server stops serving as soon as draining starts. Block
+		// until Shutdown is done and the process exits.
+		select {}
+	}
+}
+
+// Shutdown drains the driver before it exits, so that rolling upgrades
+// don't strand half-finished operations. New RPCs are rejected right away,
+// then the driver waits, bounded by X_CSI_SHUTDOWN_DRAIN_TIMEOUT, for the
+// CNS tasks already invoked to be persisted in the operation store and for
+// the pending RPCs to finish. The registered caches are flushed last.
+func (driver *vsphereCSIDriver) Shutdown(ctx context.Context) {
+	log := logger.GetLogger(ctx)
+	timeout := utils.GetShutdownDrainTimeout(ctx)
+	log.Infof("Draining in-flight operations for up to %v", timeout)
+	driver.draining.Store(true)
+	drainCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	drained := make(chan struct{})
+	go func() {
+		driver.server.Drain(drainCtx)
+		close(drained)
+	}()
+	if err := volume.WaitForTaskRecords(drainCtx); err != nil {
+		log.Warnf("in-flight CNS tasks may be invoked again after the restart. Err: %v", err)
+	}
+	<-drained
+	utils.FlushCaches(drainCtx)
+	log.Info("Done draining in-flight operations")
 }
@@ -40,7 +40,7 @@ func (driver *vsphereCSIDriver) GetPluginInfo(
 	*csi.GetPluginInfoResponse, error) {
 
 	return &csi.GetPluginInfoResponse{
-		Name:          csitypes.Name,
+		Name:          csitypes.DriverName(),
 		VendorVersion: Version,
 	}, nil
 }
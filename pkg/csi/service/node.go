@@ -20,6 +20,7 @@ import (
 	"context"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	cnstypes "github.com/vmware/govmomi/cns/types"
@@ -97,6 +98,14 @@ func (driver *vsphereCSIDriver) NodeStageVolume(
 		if err != nil {
 			return nil, err
 		}
+		// Kubelet skips relabeling the volume when it sets the SELinux
+		// context mount option, so the option can't be ignored.
+		if hasSELinuxContextMountFlag(params.MntFlags) &&
+			!commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.SELinuxMount) {
+			return nil, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+				"NodeStageVolume failed: mounting volume %q with an SELinux context requires the %q "+
+					"feature state", volumeID, common.SELinuxMount)
+		}
 
 		// Check that staging path is created by CO and is a directory.
 		params.StagingTarget = req.GetStagingTargetPath()
@@ -104,7 +113,24 @@ func (driver *vsphereCSIDriver) NodeStageVolume(
 			return nil, err
 		}
 	}
-	return driver.osUtils.NodeStageBlockVolume(ctx, req, params)
+	resp, err := driver.osUtils.NodeStageBlockVolume(ctx, req, params)
+	if err != nil || params.StagingTarget == "" || params.Ro ||
+		!commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.SnapshotOwnershipRestore) {
+		return resp, err
+	}
+	// Apply the ownership recorded on the snapshot the volume is restored
+	// from, if any.
+	volumeContext := req.GetVolumeContext()
+	uid, gid, fsGroup := volumeContext[common.AttributeSnapshotOwnerUID],
+		volumeContext[common.AttributeSnapshotOwnerGID], volumeContext[common.AttributeSnapshotFSGroup]
+	if uid == "" && gid == "" && fsGroup == "" {
+		return resp, nil
+	}
+	if err := driver.osUtils.RestoreVolumeOwnership(ctx, params.StagingTarget, uid, gid, fsGroup); err != nil {
+		return nil, logger.LogNewErrorCodef(log, codes.Internal,
+			"NodeStageVolume failed to restore the ownership of volume %q. Err: %v", volumeID, err)
+	}
+	return resp, nil
 }
 
 func (driver *vsphereCSIDriver) NodeUnstageVolume(
@@ -228,16 +254,52 @@ func (driver *vsphereCSIDriver) NodePublishVolume(
 			return nil, err
 		}
 
+		var resp *csi.NodePublishVolumeResponse
 		// check for Block vs Mount.
 		if _, ok := volCap.GetAccessType().(*csi.VolumeCapability_Block); ok {
 			// bind mount device to target.
-			return driver.osUtils.PublishBlockVol(ctx, req, dev, params)
+			resp, err = driver.osUtils.PublishBlockVol(ctx, req, dev, params)
+		} else {
+			// Volume must be a mount volume.
+			resp, err = driver.osUtils.PublishMountVol(ctx, req, dev, params)
+			if err == nil {
+				err = driver.applyVolumeMountGroup(ctx, volCap, params)
+			}
+		}
+		if err == nil && commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.NodeIOThrottling) {
+			throttleVolumeIO(ctx, driver.osUtils, params.Target, dev)
 		}
-		// Volume must be a mount volume.
-		return driver.osUtils.PublishMountVol(ctx, req, dev, params)
+		return resp, err
 	}
 	// Volume must be a file share.
-	return driver.osUtils.PublishFileVol(ctx, req, params)
+	resp, err := driver.osUtils.PublishFileVol(ctx, req, params)
+	if err != nil {
+		return nil, err
+	}
+	if err = driver.applyVolumeMountGroup(ctx, volCap, params); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// applyVolumeMountGroup gives the ownership of the volume published at the
+// target path to the volume mount group of the request, which kubelet sets to
+// the fsGroup of the pod instead of applying it itself when the node has the
+// VOLUME_MOUNT_GROUP capability. Read-only volumes keep their ownership, as
+// kubelet does.
+func (driver *vsphereCSIDriver) applyVolumeMountGroup(ctx context.Context, volCap *csi.VolumeCapability,
+	params osutils.NodePublishParams) error {
+	log := logger.GetLogger(ctx)
+	group := volCap.GetMount().GetVolumeMountGroup()
+	if group == "" || params.Ro {
+		return nil
+	}
+	if err := driver.osUtils.SetVolumeMountGroup(ctx, params.Target, group); err != nil {
+		return logger.LogNewErrorCodef(log, codes.Internal,
+			"NodePublishVolume failed to apply volume mount group %q to volume %q. Err: %v",
+			group, params.VolID, err)
+	}
+	return nil
 }
 
 func (driver *vsphereCSIDriver) NodeUnpublishVolume(
@@ -332,12 +394,23 @@ func (driver *vsphereCSIDriver) NodeGetVolumeStats(
 	}, nil
 }
 
+// hasSELinuxContextMountFlag returns true if the mount flags contain the
+// "context" option, which kubelet sets to the SELinux context of the pod.
+func hasSELinuxContextMountFlag(mntFlags []string) bool {
+	for _, flag := range mntFlags {
+		if strings.HasPrefix(flag, "context=") {
+			return true
+		}
+	}
+	return false
+}
+
 func (driver *vsphereCSIDriver) NodeGetCapabilities(
 	ctx context.Context,
 	req *csi.NodeGetCapabilitiesRequest) (
 	*csi.NodeGetCapabilitiesResponse, error) {
 
-	return &csi.NodeGetCapabilitiesResponse{
+	resp := &csi.NodeGetCapabilitiesResponse{
 		Capabilities: []*csi.NodeServiceCapability{
 			{
 				Type: &csi.NodeServiceCapability_Rpc{
@@ -361,7 +434,26 @@ func (driver *vsphereCSIDriver) NodeGetCapabilities(
 				},
 			},
 		},
-	}, nil
+	}
+	if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.ReadWriteOncePod) {
+		resp.Capabilities = append(resp.Capabilities, &csi.NodeServiceCapability{
+			Type: &csi.NodeServiceCapability_Rpc{
+				Rpc: &csi.NodeServiceCapability_RPC{
+					Type: csi.NodeServiceCapability_RPC_SINGLE_NODE_MULTI_WRITER,
+				},
+			},
+		})
+	}
+	if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.DelegateFSGroup) {
+		resp.Capabilities = append(resp.Capabilities, &csi.NodeServiceCapability{
+			Type: &csi.NodeServiceCapability_Rpc{
+				Rpc: &csi.NodeServiceCapability_RPC{
+					Type: csi.NodeServiceCapability_RPC_VOLUME_MOUNT_GROUP,
+				},
+			},
+		})
+	}
+	return resp, nil
 }
 
 // NodeGetInfo RPC returns the NodeGetInfoResponse with mandatory fields
@@ -461,6 +553,7 @@ func (driver *vsphereCSIDriver) NodeGetInfo(
 		}
 		accessibleTopology, err = topologyService.GetNodeTopologyLabels(ctx, &nodeInfo)
 	} else if clusterFlavor == cnstypes.CnsClusterFlavorVanilla {
+		publishNodePluginVersion(ctx, nodeName)
 		// Initialize volume topology service.
 		if err = initVolumeTopologyService(ctx); err != nil {
 			return nil, err
@@ -0,0 +1,128 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/osutils"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
+)
+
+// readIOPSLimitAnnotation returns the key of the PVC annotation holding the
+// maximum number of read operations per second of the pods on the volume.
+func readIOPSLimitAnnotation() string {
+	return csitypes.DriverName() + "/read-iops-limit"
+}
+
+// writeIOPSLimitAnnotation returns the key of the PVC annotation holding
+// the maximum number of write operations per second of the pods on the
+// volume.
+func writeIOPSLimitAnnotation() string {
+	return csitypes.DriverName() + "/write-iops-limit"
+}
+
+// parsePublishTarget returns the name of the PV and the UID of the pod of
+// the given NodePublish target path, which kubelet sets to
+// ".../pods/<pod uid>/volumes/kubernetes.io~csi/<pv name>/mount" for mount
+// volumes and to ".../volumeDevices/publish/<pv name>/<pod uid>" for raw
+// block volumes.
+func parsePublishTarget(target string) (pvName string, podUID string, ok bool) {
+	elems := strings.Split(filepath.Clean(target), string(filepath.Separator))
+	n := len(elems)
+	if n >= 6 && elems[n-1] == "mount" && elems[n-3] == "kubernetes.io~csi" && elems[n-4] == "volumes" &&
+		elems[n-6] == "pods" {
+		return elems[n-2], elems[n-5], true
+	}
+	if n >= 4 && elems[n-3] == "publish" && elems[n-4] == "volumeDevices" {
+		return elems[n-2], elems[n-1], true
+	}
+	return "", "", false
+}
+
+// parseIOLimits returns the IO limits annotated on a PVC, and whether any
+// limit is annotated. Invalid limits are ignored.
+func parseIOLimits(ctx context.Context, annotations map[string]string) (osutils.IOLimits, bool) {
+	log := logger.GetLogger(ctx)
+	var limits osutils.IOLimits
+	annotated := false
+	for key, limit := range map[string]*int64{
+		readIOPSLimitAnnotation():  &limits.ReadIOPS,
+		writeIOPSLimitAnnotation(): &limits.WriteIOPS,
+	} {
+		value, ok := annotations[key]
+		if !ok {
+			continue
+		}
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed <= 0 {
+			log.Warnf("ignoring invalid IO limit %q of annotation %q", value, key)
+			continue
+		}
+		*limit = parsed
+		annotated = true
+	}
+	return limits, annotated
+}
+
+// throttleVolumeIO limits the IO of the pod of the given NodePublish target
+// on the given device to the limits annotated on the PVC of the volume.
+// Failures are only logged, as they don't prevent the pod from using the
+// volume.
+func throttleVolumeIO(ctx context.Context, osUtils *osutils.OsUtils, target string, dev *osutils.Device) {
+	log := logger.GetLogger(ctx)
+	pvName, podUID, ok := parsePublishTarget(target)
+	if !ok {
+		log.Warnf("throttleVolumeIO: failed to get the PV and pod of target path %q", target)
+		return
+	}
+	k8sClient, err := k8s.NewClient(ctx)
+	if err != nil {
+		log.Warnf("throttleVolumeIO: failed to create kubernetes client. Error: %v", err)
+		return
+	}
+	pv, err := k8sClient.CoreV1().PersistentVolumes().Get(ctx, pvName, metav1.GetOptions{})
+	if err != nil {
+		log.Warnf("throttleVolumeIO: failed to get PV %q. Error: %v", pvName, err)
+		return
+	}
+	if pv.Spec.ClaimRef == nil {
+		return
+	}
+	pvc, err := k8sClient.CoreV1().PersistentVolumeClaims(pv.Spec.ClaimRef.Namespace).Get(ctx,
+		pv.Spec.ClaimRef.Name, metav1.GetOptions{})
+	if err != nil {
+		log.Warnf("throttleVolumeIO: failed to get PVC %s/%s. Error: %v",
+			pv.Spec.ClaimRef.Namespace, pv.Spec.ClaimRef.Name, err)
+		return
+	}
+	limits, annotated := parseIOLimits(ctx, pvc.Annotations)
+	if !annotated {
+		return
+	}
+	if err := osUtils.ThrottleVolumeIO(ctx, podUID, dev, limits); err != nil {
+		log.Warnf("throttleVolumeIO: failed to limit the IO of pod %q on PV %q to %+v. Error: %v",
+			podUID, pvName, limits, err)
+	}
+}
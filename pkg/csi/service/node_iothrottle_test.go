@@ -0,0 +1,68 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"testing"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/osutils"
+)
+
+func TestParsePublishTarget(t *testing.T) {
+	tests := []struct {
+		target string
+		pvName string
+		podUID string
+		ok     bool
+	}{
+		{
+			target: "/var/lib/kubelet/pods/0f1e2d3c/volumes/kubernetes.io~csi/pvc-1234/mount",
+			pvName: "pvc-1234",
+			podUID: "0f1e2d3c",
+			ok:     true,
+		},
+		{
+			target: "/var/lib/kubelet/plugins/kubernetes.io/csi/volumeDevices/publish/pvc-1234/0f1e2d3c",
+			pvName: "pvc-1234",
+			podUID: "0f1e2d3c",
+			ok:     true,
+		},
+		{target: "/mnt/target"},
+	}
+	for _, test := range tests {
+		pvName, podUID, ok := parsePublishTarget(test.target)
+		if pvName != test.pvName || podUID != test.podUID || ok != test.ok {
+			t.Errorf("target %q: expected (%q, %q, %v), got (%q, %q, %v)", test.target,
+				test.pvName, test.podUID, test.ok, pvName, podUID, ok)
+		}
+	}
+}
+
+func TestParseIOLimits(t *testing.T) {
+	ctx := context.Background()
+	limits, annotated := parseIOLimits(ctx, map[string]string{
+		readIOPSLimitAnnotation():  "500",
+		writeIOPSLimitAnnotation(): "invalid",
+	})
+	if !annotated || limits != (osutils.IOLimits{ReadIOPS: 500}) {
+		t.Errorf("expected read limit only, got %+v, annotated: %v", limits, annotated)
+	}
+	if _, annotated := parseIOLimits(ctx, map[string]string{"other": "1"}); annotated {
+		t.Errorf("expected no limits without annotations")
+	}
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
+)
+
+// publishNodePluginVersion annotates the Node object with the version of the
+// node plugin, so that the controller can reject the volumes needing a newer
+// node plugin. Failures are only logged, as they don't prevent the node
+// plugin from serving volumes.
+func publishNodePluginVersion(ctx context.Context, nodeName string) {
+	log := logger.GetLogger(ctx)
+	if Version == "" {
+		log.Infof("Driver version is not set, not publishing the node plugin version on node %q", nodeName)
+		return
+	}
+	k8sClient, err := k8s.NewClient(ctx)
+	if err != nil {
+		log.Warnf("failed to create kubernetes client to publish the node plugin version. Error: %v", err)
+		return
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				common.NodePluginVersionAnnotation(): Version,
+			},
+		},
+	})
+	if err != nil {
+		log.Warnf("failed to marshal the node plugin version patch. Error: %v", err)
+		return
+	}
+	_, err = k8sClient.CoreV1().Nodes().Patch(ctx, nodeName, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		log.Warnf("failed to publish the node plugin version %q on node %q. Error: %v", Version, nodeName, err)
+		return
+	}
+	log.Infof("Published the node plugin version %q on node %q", Version, nodeName)
+}
@@ -0,0 +1,176 @@
+//go:build darwin || linux
+// +build darwin linux
+
+/*
+Copyright 2024 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package osutils
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+const (
+	// volumeMountGroupMode is the permission given to the volume mount group
+	// on the files of a volume, matching the one given by kubelet.
+	volumeMountGroupMode = 0660
+	// volumeMountGroupDirMode is the additional permission given to the
+	// volume mount group on the directories of a volume.
+	volumeMountGroupDirMode = os.ModeSetgid | 0110
+	// volumeOwnershipMarker is the file at the root of a volume recording the
+	// ownership restored from the snapshot the volume is restored from.
+	volumeOwnershipMarker = ".vsphere-csi-ownership-restored"
+)
+
+// SetVolumeMountGroup gives the ownership of the files of the volume
+// mounted at the given path to the given volume mount group, which kubelet
+// delegates to the driver instead of applying the fsGroup of the pod
+// itself. The walk of the volume is skipped when its root directory already
+// belongs to the group with the setgid bit set, as files created since are
+// owned by the group.
+func (osUtils *OsUtils) SetVolumeMountGroup(ctx context.Context, path string, volumeMountGroup string) error {
+	log := logger.GetLogger(ctx)
+	gid, err := strconv.Atoi(volumeMountGroup)
+	if err != nil {
+		return fmt.Errorf("invalid volume mount group %q. Err: %v", volumeMountGroup, err)
+	}
+	matches, err := volumeMountGroupMatches(path, gid)
+	if err != nil {
+		return err
+	}
+	if matches {
+		log.Infof("Volume mounted at %q is already owned by group %d, skipping the change of its ownership",
+			path, gid)
+		return nil
+	}
+	start := time.Now()
+	err = filepath.WalkDir(path, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		return setFileMountGroup(filePath, entry, gid)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to change the ownership of the volume mounted at %q to group %d. Err: %v",
+			path, gid, err)
+	}
+	log.Infof("Changed the ownership of the volume mounted at %q to group %d in %v", path, gid, time.Since(start))
+	return nil
+}
+
+// volumeMountGroupMatches returns true if the root directory of the volume
+// mounted at the given path belongs to the given group, which can read and
+// write it, with the setgid bit set.
+func volumeMountGroupMatches(path string, gid int) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false, fmt.Errorf("failed to get the owner of %q", path)
+	}
+	return int(stat.Gid) == gid && info.Mode()&os.ModeSetgid != 0 &&
+		info.Mode().Perm()&volumeMountGroupMode == volumeMountGroupMode, nil
+}
+
+// setFileMountGroup gives the ownership of the given file to the given
+// group, which can read and write it. Symbolic links are not followed.
+func setFileMountGroup(path string, entry fs.DirEntry, gid int) error {
+	if err := os.Lchown(path, -1, gid); err != nil {
+		return err
+	}
+	if entry.Type()&os.ModeSymlink != 0 {
+		return nil
+	}
+	info, err := entry.Info()
+	if err != nil {
+		return err
+	}
+	mode := info.Mode() | volumeMountGroupMode
+	if info.IsDir() {
+		mode |= volumeMountGroupDirMode
+	}
+	if mode == info.Mode() {
+		return nil
+	}
+	return os.Chmod(path, mode)
+}
+
+// RestoreVolumeOwnership gives the ownership of the files of the volume
+// mounted at the given path to the given uid and gid, then to the given
+// fsGroup, as recorded on the snapshot the volume is restored from. Empty
+// values are left as is. Once done, the restored ownership is recorded in a
+// marker file at the root of the volume, so that the walk of the volume is
+// only done on the first NodeStage of the volume.
+func (osUtils *OsUtils) RestoreVolumeOwnership(ctx context.Context, path string, uid, gid, fsGroup string) error {
+	log := logger.GetLogger(ctx)
+	ownerUID, ownerGID := -1, -1
+	for _, owner := range []struct {
+		value string
+		id    *int
+	}{{uid, &ownerUID}, {gid, &ownerGID}, {fsGroup, nil}} {
+		if owner.value == "" {
+			continue
+		}
+		parsed, err := common.ParseOwnershipID(owner.value)
+		if err != nil {
+			return fmt.Errorf("invalid owner %q of the volume mounted at %q. Err: %v", owner.value, path, err)
+		}
+		if owner.id != nil {
+			*owner.id = parsed
+		}
+	}
+	marker := filepath.Join(path, volumeOwnershipMarker)
+	ownership := strings.Join([]string{uid, gid, fsGroup}, ":")
+	if recorded, err := os.ReadFile(marker); err == nil && string(recorded) == ownership {
+		log.Debugf("Ownership %q of the volume mounted at %q is already restored", ownership, path)
+		return nil
+	} else if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read the ownership marker of the volume mounted at %q. Err: %v", path, err)
+	}
+	if ownerUID != -1 || ownerGID != -1 {
+		start := time.Now()
+		err := filepath.WalkDir(path, func(filePath string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			return os.Lchown(filePath, ownerUID, ownerGID)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to restore the ownership of the volume mounted at %q. Err: %v", path, err)
+		}
+		log.Infof("Restored the ownership of the volume mounted at %q to %d:%d in %v",
+			path, ownerUID, ownerGID, time.Since(start))
+	}
+	if fsGroup != "" {
+		if err := osUtils.SetVolumeMountGroup(ctx, path, fsGroup); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(marker, []byte(ownership), 0600); err != nil {
+		return fmt.Errorf("failed to write the ownership marker of the volume mounted at %q. Err: %v", path, err)
+	}
+	return nil
+}
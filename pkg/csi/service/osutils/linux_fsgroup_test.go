@@ -0,0 +1,113 @@
+//go:build darwin || linux
+// +build darwin linux
+
+package osutils
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestSetVolumeMountGroup(t *testing.T) {
+	ctx := context.Background()
+	osUtils := &OsUtils{}
+	root := t.TempDir()
+	dir := filepath.Join(root, "dir")
+	file := filepath.Join(dir, "file")
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	// The current group can always be set without privileges.
+	gid := os.Getgid()
+
+	matches, err := volumeMountGroupMatches(root, gid)
+	if err != nil || matches {
+		t.Fatalf("expected the volume not to match its mount group, got %v, err: %v", matches, err)
+	}
+	if err := osUtils.SetVolumeMountGroup(ctx, root, strconv.Itoa(gid)); err != nil {
+		t.Fatalf("failed to set the volume mount group. Err: %v", err)
+	}
+	matches, err = volumeMountGroupMatches(root, gid)
+	if err != nil || !matches {
+		t.Fatalf("expected the volume to match its mount group, got %v, err: %v", matches, err)
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSetgid == 0 || info.Mode().Perm() != 0770 {
+		t.Errorf("unexpected mode %v of directory %q", info.Mode(), dir)
+	}
+	info, err = os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0660 {
+		t.Errorf("unexpected mode %v of file %q", info.Mode(), file)
+	}
+
+	// Files of a volume already owned by its mount group are left as is.
+	if err := os.Chmod(file, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := osUtils.SetVolumeMountGroup(ctx, root, strconv.Itoa(gid)); err != nil {
+		t.Fatalf("failed to set the volume mount group. Err: %v", err)
+	}
+	if info, err = os.Stat(file); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected the mode of %q to be left as is, got %v, err: %v", file, info.Mode(), err)
+	}
+
+	if err := osUtils.SetVolumeMountGroup(ctx, root, "invalid"); err == nil {
+		t.Errorf("expected an error for an invalid volume mount group")
+	}
+}
+
+func TestRestoreVolumeOwnership(t *testing.T) {
+	ctx := context.Background()
+	osUtils := &OsUtils{}
+	root := t.TempDir()
+	file := filepath.Join(root, "file")
+	if err := os.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	// The current owner can always be set without privileges.
+	uid, gid := strconv.Itoa(os.Getuid()), strconv.Itoa(os.Getgid())
+
+	if err := osUtils.RestoreVolumeOwnership(ctx, root, uid, gid, gid); err != nil {
+		t.Fatalf("failed to restore the volume ownership. Err: %v", err)
+	}
+	if info, err := os.Stat(file); err != nil || info.Mode().Perm() != 0660 {
+		t.Errorf("expected the fsGroup to be applied to %q, got %v, err: %v", file, info.Mode(), err)
+	}
+	marker, err := os.ReadFile(filepath.Join(root, volumeOwnershipMarker))
+	if err != nil || string(marker) != uid+":"+gid+":"+gid {
+		t.Errorf("expected the restored ownership to be recorded, got %q, err: %v", marker, err)
+	}
+
+	// The ownership is only restored once, even if the volume no longer
+	// matches it.
+	if err := os.Chmod(file, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(root, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := osUtils.RestoreVolumeOwnership(ctx, root, uid, gid, gid); err != nil {
+		t.Fatalf("failed to restore the volume ownership. Err: %v", err)
+	}
+	if info, err := os.Stat(file); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected the mode of %q to be left as is, got %v, err: %v", file, info.Mode(), err)
+	}
+
+	for _, invalid := range []string{"invalid", "-1", "4294967296"} {
+		if err := osUtils.RestoreVolumeOwnership(ctx, root, invalid, "", ""); err == nil {
+			t.Errorf("expected an error for invalid owner %q", invalid)
+		}
+	}
+}
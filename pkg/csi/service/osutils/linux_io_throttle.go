@@ -0,0 +1,136 @@
+//go:build darwin || linux
+// +build darwin linux
+
+/*
+Copyright 2024 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package osutils
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+const (
+	// cgroupRoot is the mount point of the cgroup hierarchies of the node.
+	cgroupRoot = "/sys/fs/cgroup"
+	// sysBlockDir is the sysfs directory of the block devices of the node.
+	sysBlockDir = "/sys/block"
+	// podCgroupMaxDepth is the maximum depth below the cgroup root at which
+	// the cgroup of a pod is looked up.
+	podCgroupMaxDepth = 4
+)
+
+// ThrottleVolumeIO limits the IO of the pod with the given UID on the given
+// device, through the io controller of cgroup v2 or the blkio controller of
+// cgroup v1. The limits apply until the cgroup of the pod is removed.
+func (osUtils *OsUtils) ThrottleVolumeIO(ctx context.Context, podUID string, dev *Device,
+	limits IOLimits) error {
+	log := logger.GetLogger(ctx)
+	devNumber, err := getDeviceNumber(sysBlockDir, dev.RealDev)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err == nil {
+		podCgroup, err := findPodCgroup(cgroupRoot, podUID)
+		if err != nil {
+			return err
+		}
+		log.Infof("Limiting IO of pod %q on device %q (%s) in cgroup %q to %+v",
+			podUID, dev.RealDev, devNumber, podCgroup, limits)
+		return writeCgroupFile(filepath.Join(podCgroup, "io.max"), ioMaxLine(devNumber, limits))
+	}
+	podCgroup, err := findPodCgroup(filepath.Join(cgroupRoot, "blkio"), podUID)
+	if err != nil {
+		return err
+	}
+	log.Infof("Limiting IO of pod %q on device %q (%s) in cgroup %q to %+v",
+		podUID, dev.RealDev, devNumber, podCgroup, limits)
+	// A zero limit removes the limit of the device.
+	err = writeCgroupFile(filepath.Join(podCgroup, "blkio.throttle.read_iops_device"),
+		fmt.Sprintf("%s %d", devNumber, limits.ReadIOPS))
+	if err != nil {
+		return err
+	}
+	return writeCgroupFile(filepath.Join(podCgroup, "blkio.throttle.write_iops_device"),
+		fmt.Sprintf("%s %d", devNumber, limits.WriteIOPS))
+}
+
+// getDeviceNumber returns the "major:minor" number of the given block
+// device, read from the given sysfs block directory.
+func getDeviceNumber(sysBlock string, device string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(sysBlock, filepath.Base(device), "dev"))
+	if err != nil {
+		return "", fmt.Errorf("failed to get the device number of %q. Err: %v", device, err)
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// findPodCgroup returns the cgroup directory of the pod with the given UID
+// below the given cgroup root, named "pod<uid>" by the cgroupfs driver of
+// kubelet and "kubepods-<qos>-pod<uid>.slice", with the dashes of the UID
+// replaced by underscores, by the systemd driver.
+func findPodCgroup(root string, podUID string) (string, error) {
+	cgroupfsName := "pod" + podUID
+	systemdSuffix := "pod" + strings.ReplaceAll(podUID, "-", "_") + ".slice"
+	var podCgroup string
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		if entry.Name() == cgroupfsName || strings.HasSuffix(entry.Name(), systemdSuffix) {
+			podCgroup = path
+			return fs.SkipAll
+		}
+		if strings.Count(strings.TrimPrefix(path, root), string(filepath.Separator)) >= podCgroupMaxDepth {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to look up the cgroup of pod %q in %q. Err: %v", podUID, root, err)
+	}
+	if podCgroup == "" {
+		return "", fmt.Errorf("cgroup of pod %q not found in %q", podUID, root)
+	}
+	return podCgroup, nil
+}
+
+// ioMaxLine returns the line of the io.max file of cgroup v2 setting the
+// given limits on the device with the given number.
+func ioMaxLine(devNumber string, limits IOLimits) string {
+	limit := func(value int64) string {
+		if value <= 0 {
+			return "max"
+		}
+		return fmt.Sprintf("%d", value)
+	}
+	return fmt.Sprintf("%s riops=%s wiops=%s", devNumber, limit(limits.ReadIOPS), limit(limits.WriteIOPS))
+}
+
+// writeCgroupFile writes the given line to the given cgroup interface file.
+func writeCgroupFile(path string, line string) error {
+	if err := os.WriteFile(path, []byte(line), 0); err != nil {
+		return fmt.Errorf("failed to write %q to %q. Err: %v", line, path, err)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+//go:build darwin || linux
+// +build darwin linux
+
+package osutils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindPodCgroup(t *testing.T) {
+	root := t.TempDir()
+	podUID := "8f3c2b6a-1d2e-4f5a-9b8c-7d6e5f4a3b2c"
+	cgroupfsPod := filepath.Join(root, "kubepods", "burstable", "pod"+podUID)
+	if err := os.MkdirAll(cgroupfsPod, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if path, err := findPodCgroup(root, podUID); err != nil || path != cgroupfsPod {
+		t.Errorf("expected cgroup %q, got %q, err: %v", cgroupfsPod, path, err)
+	}
+
+	root = t.TempDir()
+	systemdPod := filepath.Join(root, "kubepods.slice", "kubepods-besteffort.slice",
+		"kubepods-besteffort-pod8f3c2b6a_1d2e_4f5a_9b8c_7d6e5f4a3b2c.slice")
+	if err := os.MkdirAll(systemdPod, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if path, err := findPodCgroup(root, podUID); err != nil || path != systemdPod {
+		t.Errorf("expected cgroup %q, got %q, err: %v", systemdPod, path, err)
+	}
+
+	if _, err := findPodCgroup(root, "unknown-pod"); err == nil {
+		t.Errorf("expected an error for a pod without cgroup")
+	}
+}
+
+func TestIOMaxLine(t *testing.T) {
+	tests := []struct {
+		limits   IOLimits
+		expected string
+	}{
+		{IOLimits{ReadIOPS: 100, WriteIOPS: 50}, "8:16 riops=100 wiops=50"},
+		{IOLimits{ReadIOPS: 100}, "8:16 riops=100 wiops=max"},
+		{IOLimits{}, "8:16 riops=max wiops=max"},
+	}
+	for _, test := range tests {
+		if line := ioMaxLine("8:16", test.limits); line != test.expected {
+			t.Errorf("expected %q for %+v, got %q", test.expected, test.limits, line)
+		}
+	}
+}
+
+func TestGetDeviceNumber(t *testing.T) {
+	sysBlock := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(sysBlock, "sdb"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sysBlock, "sdb", "dev"), []byte("8:16\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if devNumber, err := getDeviceNumber(sysBlock, "/dev/sdb"); err != nil || devNumber != "8:16" {
+		t.Errorf("expected device number 8:16, got %q, err: %v", devNumber, err)
+	}
+	if _, err := getDeviceNumber(sysBlock, "/dev/sdc"); err == nil {
+		t.Errorf("expected an error for an unknown device")
+	}
+}
@@ -446,7 +446,9 @@ func (osUtils *OsUtils) PublishMountVol(
 			"volume ID: %q does not appear staged to %q", req.GetVolumeId(), params.StagingTarget)
 	}
 
-	// Do the bind mount to publish the volume.
+	// Do the bind mount to publish the volume. The SELinux context of the
+	// pod is set on the staged mount, and can't be changed by a bind mount.
+	mntFlags = removeSELinuxContextOption(mntFlags)
 	mntFlags = append(mntFlags, "bind")
 	if params.Ro {
 		mntFlags = append(mntFlags, "ro")
@@ -1117,3 +1119,16 @@ func (osUtils *OsUtils) IsBlockDevice(ctx context.Context, volumePath string) (b
 	}
 	return deviceInfo.Mode()&os.ModeDevice == os.ModeDevice, nil
 }
+
+// removeSELinuxContextOption returns the given mount flags without the
+// "context" option, which kubelet sets to the SELinux context of the pod
+// when the driver supports SELinux mounts.
+func removeSELinuxContextOption(mntFlags []string) []string {
+	var flags []string
+	for _, flag := range mntFlags {
+		if !strings.HasPrefix(flag, "context=") {
+			flags = append(flags, flag)
+		}
+	}
+	return flags
+}
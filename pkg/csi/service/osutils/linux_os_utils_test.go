@@ -5,6 +5,7 @@ package osutils
 
 import (
 	"context"
+	"reflect"
 	"strconv"
 	"testing"
 )
@@ -52,3 +53,14 @@ func TestUnescape(t *testing.T) {
 		})
 	}
 }
+
+func TestRemoveSELinuxContextOption(t *testing.T) {
+	mntFlags := []string{"nouuid", `context="system_u:object_r:container_file_t:s0:c0,c1"`, "noatime"}
+	expected := []string{"nouuid", "noatime"}
+	if flags := removeSELinuxContextOption(mntFlags); !reflect.DeepEqual(flags, expected) {
+		t.Errorf("expected mount flags %v, got %v", expected, flags)
+	}
+	if flags := removeSELinuxContextOption(nil); flags != nil {
+		t.Errorf("expected no mount flags, got %v", flags)
+	}
+}
@@ -61,6 +61,15 @@ type NodePublishParams struct {
 	Ro bool
 }
 
+// IOLimits holds the IO limits applied to a pod on a published volume. A
+// zero limit leaves the corresponding IO unlimited.
+type IOLimits struct {
+	// ReadIOPS is the maximum number of read operations per second.
+	ReadIOPS int64
+	// WriteIOPS is the maximum number of write operations per second.
+	WriteIOPS int64
+}
+
 // Device is a struct for holding details about a block device.
 type Device struct {
 	FullPath string // full path where device is mounted
@@ -514,3 +514,19 @@ func (osUtils *OsUtils) ShouldContinue(ctx context.Context) {
 func (osUtils *OsUtils) IsBlockDevice(ctx context.Context, volumePath string) (bool, error) {
 	return false, nil
 }
+
+// ThrottleVolumeIO is not supported on windows nodes.
+func (osUtils *OsUtils) ThrottleVolumeIO(ctx context.Context, podUID string, dev *Device,
+	limits IOLimits) error {
+	return fmt.Errorf("IO throttling is not supported on windows nodes")
+}
+
+// SetVolumeMountGroup is not supported on windows nodes.
+func (osUtils *OsUtils) SetVolumeMountGroup(ctx context.Context, path string, volumeMountGroup string) error {
+	return fmt.Errorf("volume mount group is not supported on windows nodes")
+}
+
+// RestoreVolumeOwnership is not supported on windows nodes.
+func (osUtils *OsUtils) RestoreVolumeOwnership(ctx context.Context, path string, uid, gid, fsGroup string) error {
+	return fmt.Errorf("volume ownership restore is not supported on windows nodes")
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package service
 
 import (
+	"context"
 	"net"
 	"os"
 	"strings"
@@ -48,6 +49,11 @@ type NonBlockingGRPCServer interface {
 	// from accepting new connections and RPCs and blocks until all the
 	// pending RPCs are finished.
 	GracefulStop()
+
+	// Drain stops the gRPC server from accepting new connections and RPCs
+	// and waits for the pending RPCs to finish until ctx is done, after
+	// which the server is stopped and the remaining RPCs are cancelled.
+	Drain(ctx context.Context)
 }
 
 // NewNonBlockingGRPCServer returns an instance of nonBlockingGRPCServer.
@@ -78,6 +84,27 @@ func (s *nonBlockingGRPCServer) GracefulStop() {
 	})
 }
 
+func (s *nonBlockingGRPCServer) Drain(ctx context.Context) {
+	log := logger.GetLogger(ctx)
+	stopOnce.Do(func() {
+		if s.server == nil {
+			return
+		}
+		stopped := make(chan struct{})
+		go func() {
+			s.server.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+			log.Info("drained and gracefully stopped")
+		case <-ctx.Done():
+			log.Warnf("pending RPCs didn't finish while draining, cancelling them. Err: %v", ctx.Err())
+			s.server.Stop()
+		}
+	})
+}
+
 func (s *nonBlockingGRPCServer) Stop() {
 	log := logger.GetLoggerWithNoContext()
 	stopOnce.Do(func() {
@@ -113,7 +140,7 @@ func (s *nonBlockingGRPCServer) serve(endpoint string, ids csi.IdentityServer,
 		return logger.LogNewErrorf(log, "failed to listen: %v", err)
 	}
 
-	server := grpc.NewServer()
+	server := grpc.NewServer(grpc.UnaryInterceptor(sidecarMetricsInterceptor))
 	s.server = server
 
 	// Register the CSI services.
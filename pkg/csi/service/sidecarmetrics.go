@@ -0,0 +1,86 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"path"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
+)
+
+// sidecarsByMethod maps the CSI methods to the sidecars calling them. The
+// other methods are called by kubelet or by several sidecars.
+var sidecarsByMethod = map[string]string{
+	"CreateVolume":              "provisioner",
+	"DeleteVolume":              "provisioner",
+	"GetCapacity":               "provisioner",
+	"ControllerPublishVolume":   "attacher",
+	"ControllerUnpublishVolume": "attacher",
+	"ControllerExpandVolume":    "resizer",
+	"CreateSnapshot":            "snapshotter",
+	"DeleteSnapshot":            "snapshotter",
+	"ListSnapshots":             "snapshotter",
+}
+
+// getSidecar returns the sidecar calling the given gRPC method.
+func getSidecar(fullMethod string) string {
+	if sidecar, ok := sidecarsByMethod[path.Base(fullMethod)]; ok {
+		return sidecar
+	}
+	if path.Base(path.Dir(fullMethod)) == "csi.v1.Node" {
+		return "kubelet"
+	}
+	return "other"
+}
+
+// getBackoffStatus returns the backoff status of a response with the given
+// gRPC code, which tells operators whether the sidecars are retrying
+// requests because of timeouts or because the driver is busy.
+func getBackoffStatus(code codes.Code) string {
+	switch code {
+	case codes.OK:
+		return prometheus.PrometheusBackoffNone
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted:
+		return prometheus.PrometheusBackoffRetry
+	case codes.DeadlineExceeded, codes.Canceled:
+		return prometheus.PrometheusBackoffTimeout
+	default:
+		return prometheus.PrometheusBackoffFinal
+	}
+}
+
+// sidecarMetricsInterceptor records the in-flight requests of the sidecars
+// and the gRPC codes returned to them, so that the worker threads and the
+// timeouts of the sidecars can be tuned.
+func sidecarMetricsInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	sidecar := getSidecar(info.FullMethod)
+	method := path.Base(info.FullMethod)
+	inFlight := prometheus.SidecarInFlightRequestsGaugeVec.WithLabelValues(sidecar, method)
+	inFlight.Inc()
+	defer inFlight.Dec()
+	resp, err := handler(ctx, req)
+	code := status.Code(err)
+	prometheus.SidecarResponsesCounter.WithLabelValues(sidecar, method, code.String(),
+		getBackoffStatus(code)).Inc()
+	return resp, err
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
+)
+
+func TestGetSidecar(t *testing.T) {
+	tests := map[string]string{
+		"/csi.v1.Controller/CreateVolume":            "provisioner",
+		"/csi.v1.Controller/ControllerPublishVolume": "attacher",
+		"/csi.v1.Controller/ControllerExpandVolume":  "resizer",
+		"/csi.v1.Controller/ListSnapshots":           "snapshotter",
+		"/csi.v1.Node/NodeStageVolume":               "kubelet",
+		"/csi.v1.Identity/Probe":                     "other",
+	}
+	for fullMethod, expected := range tests {
+		if sidecar := getSidecar(fullMethod); sidecar != expected {
+			t.Errorf("expected sidecar %q for %q, got %q", expected, fullMethod, sidecar)
+		}
+	}
+}
+
+func TestGetBackoffStatus(t *testing.T) {
+	tests := map[codes.Code]string{
+		codes.OK:                 prometheus.PrometheusBackoffNone,
+		codes.ResourceExhausted:  prometheus.PrometheusBackoffRetry,
+		codes.Aborted:            prometheus.PrometheusBackoffRetry,
+		codes.DeadlineExceeded:   prometheus.PrometheusBackoffTimeout,
+		codes.FailedPrecondition: prometheus.PrometheusBackoffFinal,
+		codes.Internal:           prometheus.PrometheusBackoffFinal,
+	}
+	for code, expected := range tests {
+		if backoff := getBackoffStatus(code); backoff != expected {
+			t.Errorf("expected backoff status %q for %s, got %q", expected, code, backoff)
+		}
+	}
+}
+
+func TestSidecarMetricsInterceptor(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/csi.v1.Controller/DeleteVolume"}
+	handlerErr := status.Error(codes.Aborted, "an operation is already in progress")
+	_, err := sidecarMetricsInterceptor(context.Background(), nil, info,
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, handlerErr
+		})
+	if err != handlerErr {
+		t.Errorf("expected the error of the handler, got %v", err)
+	}
+}
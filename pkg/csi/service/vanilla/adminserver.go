@@ -0,0 +1,163 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vanilla
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+const (
+	// envAdminServerAddress is the environment variable overriding the
+	// address the admin server of the controller listens on.
+	envAdminServerAddress = "ADMIN_SERVER_ADDRESS"
+	// defaultAdminServerAddress is the default address of the admin server.
+	// It is only reachable from the controller pod, e.g. with kubectl
+	// port-forward.
+	defaultAdminServerAddress = "127.0.0.1:2114"
+	// adminServerRetryInterval is the delay before the admin server is
+	// started again after it exited.
+	adminServerRetryInterval = time.Minute
+)
+
+// adminServer serves the debug endpoints of the controller, separately from
+// the metrics server. Every request has to carry the bearer token of a
+// Kubernetes user, or service account, which is allowed to use the verb
+// matching the HTTP method on the non-resource URL of the endpoint, e.g.
+//
+//	rules:
+//	- nonResourceURLs: ["/debug/volume-operation-requests"]
+//	  verbs: ["get", "delete"]
+type adminServer struct {
+	k8sClient clientset.Interface
+	mux       *http.ServeMux
+	// handlers is the number of endpoints registered on mux.
+	handlers int
+}
+
+// newAdminServer returns an admin server authenticating and authorizing the
+// requests with the given client.
+func newAdminServer(k8sClient clientset.Interface) *adminServer {
+	return &adminServer{k8sClient: k8sClient, mux: http.NewServeMux()}
+}
+
+// handle registers the handler of the endpoint with the given path.
+func (s *adminServer) handle(path string, handler http.Handler) {
+	s.mux.Handle(path, s.authorize(handler))
+	s.handlers++
+}
+
+// start serves the registered endpoints in the background. Nothing is served
+// if no endpoint is registered.
+func (s *adminServer) start(ctx context.Context) {
+	log := logger.GetLogger(ctx)
+	if s.handlers == 0 {
+		return
+	}
+	address := os.Getenv(envAdminServerAddress)
+	if address == "" {
+		address = defaultAdminServerAddress
+	}
+	go func() {
+		for {
+			log.Infof("Starting the admin server on %s", address)
+			err := http.ListenAndServe(address, s.mux)
+			log.Warnf("Admin server exited with err: %v, restarting it in %v", err, adminServerRetryInterval)
+			time.Sleep(adminServerRetryInterval)
+		}
+	}()
+}
+
+// authorize returns a handler calling the given handler only for requests
+// whose bearer token is valid, and whose user is allowed by RBAC to access
+// the requested path.
+func (s *adminServer) authorize(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, log := logger.GetNewContextWithLogger()
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			http.Error(w, "missing bearer token", http.StatusUnauthorized)
+			return
+		}
+		tokenReview, err := s.k8sClient.AuthenticationV1().TokenReviews().Create(ctx,
+			&authenticationv1.TokenReview{Spec: authenticationv1.TokenReviewSpec{Token: token}},
+			metav1.CreateOptions{})
+		if err != nil {
+			log.Errorf("failed to review the token of an admin request. Error: %v", err)
+			http.Error(w, "failed to authenticate the request", http.StatusInternalServerError)
+			return
+		}
+		if !tokenReview.Status.Authenticated {
+			http.Error(w, "invalid bearer token", http.StatusUnauthorized)
+			return
+		}
+		user := tokenReview.Status.User
+		extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+		for key, value := range user.Extra {
+			extra[key] = authorizationv1.ExtraValue(value)
+		}
+		verb := adminRequestVerb(r.Method)
+		accessReview, err := s.k8sClient.AuthorizationV1().SubjectAccessReviews().Create(ctx,
+			&authorizationv1.SubjectAccessReview{Spec: authorizationv1.SubjectAccessReviewSpec{
+				User:   user.Username,
+				UID:    user.UID,
+				Groups: user.Groups,
+				Extra:  extra,
+				NonResourceAttributes: &authorizationv1.NonResourceAttributes{
+					Path: r.URL.Path,
+					Verb: verb,
+				},
+			}}, metav1.CreateOptions{})
+		if err != nil {
+			log.Errorf("failed to review the access of user %q to %q. Error: %v", user.Username, r.URL.Path, err)
+			http.Error(w, "failed to authorize the request", http.StatusInternalServerError)
+			return
+		}
+		if !accessReview.Status.Allowed {
+			log.Warnf("User %q is not allowed to %s %q", user.Username, verb, r.URL.Path)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		log.Infof("User %q requested %s %s", user.Username, r.Method, r.URL.String())
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// adminRequestVerb returns the RBAC verb of a request with the given HTTP
+// method.
+func adminRequestVerb(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		return "get"
+	case http.MethodPost:
+		return "create"
+	case http.MethodPut:
+		return "update"
+	default:
+		return strings.ToLower(method)
+	}
+}
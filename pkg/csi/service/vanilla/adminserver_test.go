@@ -0,0 +1,80 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vanilla
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestAdminServerAuthorize(t *testing.T) {
+	k8sClient := fake.NewSimpleClientset()
+	// Token "admin" belongs to user "admin", allowed to get /debug/test.
+	k8sClient.PrependReactor("create", "tokenreviews",
+		func(action k8stesting.Action) (bool, runtime.Object, error) {
+			review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+			switch review.Spec.Token {
+			case "admin", "user":
+				review.Status.Authenticated = true
+				review.Status.User.Username = review.Spec.Token
+			}
+			return true, review, nil
+		})
+	k8sClient.PrependReactor("create", "subjectaccessreviews",
+		func(action k8stesting.Action) (bool, runtime.Object, error) {
+			review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+			review.Status.Allowed = review.Spec.User == "admin" &&
+				review.Spec.NonResourceAttributes.Path == "/debug/test" &&
+				review.Spec.NonResourceAttributes.Verb == "get"
+			return true, review, nil
+		})
+	s := newAdminServer(k8sClient)
+	s.handle("/debug/test", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name     string
+		method   string
+		token    string
+		expected int
+	}{
+		{name: "no token", method: http.MethodGet, expected: http.StatusUnauthorized},
+		{name: "invalid token", method: http.MethodGet, token: "invalid", expected: http.StatusUnauthorized},
+		{name: "forbidden user", method: http.MethodGet, token: "user", expected: http.StatusForbidden},
+		{name: "forbidden verb", method: http.MethodDelete, token: "admin", expected: http.StatusForbidden},
+		{name: "allowed", method: http.MethodGet, token: "admin", expected: http.StatusOK},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, "/debug/test", nil)
+		if test.token != "" {
+			req.Header.Set("Authorization", "Bearer "+test.token)
+		}
+		rec := httptest.NewRecorder()
+		s.mux.ServeHTTP(rec, req)
+		if rec.Code != test.expected {
+			t.Errorf("%s: expected status %d, got %d", test.name, test.expected, rec.Code)
+		}
+	}
+}
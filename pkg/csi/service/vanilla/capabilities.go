@@ -0,0 +1,129 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vanilla
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+
+	cnsconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/csidriverconfig"
+)
+
+const (
+	// driverCapabilitiesConfigMapName is the name of the ConfigMap, in the
+	// namespace of the driver, advertising the capabilities of the driver.
+	driverCapabilitiesConfigMapName = "vsphere-csi-capabilities"
+	// driverCapabilitiesRefreshInterval is the interval at which the
+	// capabilities are published again, to pick up feature state changes.
+	driverCapabilitiesRefreshInterval = 5 * time.Minute
+
+	// Keys of the driver capabilities ConfigMap.
+	capabilityKeyVersion         = "version"
+	capabilityKeyFeatureStates   = "feature-states"
+	capabilityKeyVolumeTypes     = "volume-types"
+	capabilityKeyMaxVolumeSizeMb = "max-volume-size-mb"
+	capabilityKeyTopologyMode    = "topology-mode"
+
+	// Topology modes advertised in the driver capabilities.
+	topologyModeNone               = "none"
+	topologyModeZoneRegion         = "zone-region"
+	topologyModeTopologyCategories = "topology-categories"
+)
+
+// getDriverCapabilities returns the data of the driver capabilities
+// ConfigMap for the given driver version and configuration.
+func getDriverCapabilities(ctx context.Context, version string, cfg *cnsconfig.Config) (map[string]string, error) {
+	featureStates, err := json.Marshal(commonco.ContainerOrchestratorUtility.GetFeatureStates(ctx))
+	if err != nil {
+		return nil, err
+	}
+	topologyMode := topologyModeNone
+	if len(csidriverconfig.TopologyCategories()) != 0 || strings.TrimSpace(cfg.Labels.TopologyCategories) != "" {
+		topologyMode = topologyModeTopologyCategories
+	} else if strings.TrimSpace(cfg.Labels.Zone) != "" && strings.TrimSpace(cfg.Labels.Region) != "" {
+		topologyMode = topologyModeZoneRegion
+	}
+	maxVolumeSizeMb := common.GetMaxBlockVolumeSizeInMb(cfg.VolumeSizeLimits, &common.StorageClassParams{})
+	return map[string]string{
+		capabilityKeyVersion:         version,
+		capabilityKeyFeatureStates:   string(featureStates),
+		capabilityKeyVolumeTypes:     strings.Join([]string{common.BlockVolumeType, common.FileVolumeType}, ","),
+		capabilityKeyMaxVolumeSizeMb: strconv.FormatInt(maxVolumeSizeMb, 10),
+		capabilityKeyTopologyMode:    topologyMode,
+	}, nil
+}
+
+// publishDriverCapabilities creates or updates the driver capabilities
+// ConfigMap with the given data.
+func publishDriverCapabilities(ctx context.Context, k8sClient clientset.Interface, data map[string]string) error {
+	namespace := common.GetCSINamespace()
+	configMaps := k8sClient.CoreV1().ConfigMaps(namespace)
+	configMap, err := configMaps.Get(ctx, driverCapabilitiesConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		configMap = &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      driverCapabilitiesConfigMapName,
+				Namespace: namespace,
+			},
+			Data: data,
+		}
+		_, err = configMaps.Create(ctx, configMap, metav1.CreateOptions{})
+		return err
+	}
+	if reflect.DeepEqual(configMap.Data, data) {
+		return nil
+	}
+	configMap.Data = data
+	_, err = configMaps.Update(ctx, configMap, metav1.UpdateOptions{})
+	return err
+}
+
+// runDriverCapabilitiesPublisher periodically publishes the capabilities of
+// the driver for platform tooling to discover them.
+func (c *controller) runDriverCapabilitiesPublisher(ctx context.Context, k8sClient clientset.Interface,
+	version string) {
+	log := logger.GetLogger(ctx)
+	ticker := time.NewTicker(driverCapabilitiesRefreshInterval)
+	defer ticker.Stop()
+	for ; true; <-ticker.C {
+		data, err := getDriverCapabilities(ctx, version, c.managers.CnsConfig)
+		if err != nil {
+			log.Errorf("failed to get driver capabilities. Error: %v", err)
+			continue
+		}
+		if err := publishDriverCapabilities(ctx, k8sClient, data); err != nil {
+			log.Errorf("failed to publish driver capabilities in ConfigMap %q. Error: %v",
+				driverCapabilitiesConfigMapName, err)
+		}
+	}
+}
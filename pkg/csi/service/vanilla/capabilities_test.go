@@ -0,0 +1,48 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vanilla
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+)
+
+func TestPublishDriverCapabilities(t *testing.T) {
+	ctx := context.Background()
+	k8sClient := fake.NewSimpleClientset()
+	data := map[string]string{capabilityKeyVersion: "v1", capabilityKeyTopologyMode: topologyModeNone}
+	if err := publishDriverCapabilities(ctx, k8sClient, data); err != nil {
+		t.Fatalf("failed to create capabilities ConfigMap: %v", err)
+	}
+	data[capabilityKeyVersion] = "v2"
+	if err := publishDriverCapabilities(ctx, k8sClient, data); err != nil {
+		t.Fatalf("failed to update capabilities ConfigMap: %v", err)
+	}
+	configMap, err := k8sClient.CoreV1().ConfigMaps(common.GetCSINamespace()).Get(ctx,
+		driverCapabilitiesConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get capabilities ConfigMap: %v", err)
+	}
+	if configMap.Data[capabilityKeyVersion] != "v2" {
+		t.Errorf("expected version v2, got %q", configMap.Data[capabilityKeyVersion])
+	}
+}
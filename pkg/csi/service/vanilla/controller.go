@@ -29,14 +29,17 @@ import (
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/fsnotify/fsnotify"
+	snapshotterClientSet "github.com/kubernetes-csi/external-snapshotter/client/v6/clientset/versioned"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	cnstypes "github.com/vmware/govmomi/cns/types"
 	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/units"
 	"github.com/vmware/govmomi/vim25/types"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	clientset "k8s.io/client-go/kubernetes"
 
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/migration"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/node"
@@ -50,10 +53,14 @@ import (
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco"
 	commoncotypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco/types"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/placementengine"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/policyengine"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
 	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsnodeinfo"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsvolumeinfo"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsvolumeoperationrequest"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/csidriverconfig"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
 )
 
 // NodeManagerInterface provides functionality to manage (VM) nodes.
@@ -79,6 +86,19 @@ type controller struct {
 	authMgr     common.AuthorizationService
 	authMgrs    map[string]*common.AuthManager
 	topologyMgr commoncotypes.ControllerTopologyService
+	// policyEngine reviews CreateVolume and CreateSnapshot requests.
+	// It is nil if no policy engine is configured.
+	policyEngine policyengine.Client
+	// version is the version of the driver.
+	version string
+	// readOnly is the read-only mode of the controller. It is nil if the
+	// read-only mode can't be enabled.
+	readOnly *readOnlyMode
+	// k8sClient is the kubernetes client of the controller.
+	k8sClient clientset.Interface
+	// snapshotterClient is the snapshotter client of the controller. It is
+	// nil if the snapshot-cascade-delete FSS is disabled.
+	snapshotterClient snapshotterClientSet.Interface
 }
 
 var (
@@ -89,6 +109,10 @@ var (
 	// This will hold mapping for VolumeID to vCenter for multi vCenter CSI topology deployment
 	volumeInfoService cnsvolumeinfo.VolumeInfoService
 
+	// nodeInfoService holds the pointer to CnsNodeInfo service instance.
+	// It is nil if the cns-node-info feature is disabled.
+	nodeInfoService cnsnodeinfo.NodeInfoService
+
 	// The following variables hold feature states for multi-vcenter-csi-topology, CSI Migration
 	// and authorisation check.
 	multivCenterCSITopologyEnabled, csiMigrationEnabled, filterSuspendedDatastores,
@@ -117,10 +141,11 @@ func New() csitypes.CnsController {
 func (c *controller) Init(config *cnsconfig.Config, version string) error {
 	ctx, log := logger.GetNewContextWithLogger()
 	log.Infof("Initializing CNS controller")
+	c.version = version
 	var err error
 	var operationStore cnsvolumeoperationrequest.VolumeOperationRequest
 	operationStore, err = cnsvolumeoperationrequest.InitVolumeOperationRequestInterface(ctx,
-		config.Global.CnsVolumeOperationRequestCleanupIntervalInMin,
+		config.Global.CnsVolumeOperationRequestCleanupIntervalInMin, config.Global.VolumeOperationRequestStore,
 		func() bool {
 			return commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.BlockVolumeSnapshot)
 		}, false)
@@ -137,6 +162,12 @@ func (c *controller) Init(config *cnsconfig.Config, version string) error {
 		common.CnsMgrSuspendCreateVolume)
 	isTopologyAwareFileVolumeEnabled = commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx,
 		common.TopologyAwareFileVolume)
+	if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.ProvisioningPolicyHook) {
+		c.policyEngine, err = policyengine.NewClient(ctx, &config.PolicyEngine)
+		if err != nil {
+			return logger.LogNewErrorf(log, "failed to create policy engine client. err=%v", err)
+		}
+	}
 
 	vcManager := cnsvsphere.GetVirtualCenterManager(ctx)
 	if !multivCenterCSITopologyEnabled {
@@ -183,6 +214,10 @@ func (c *controller) Init(config *cnsconfig.Config, version string) error {
 		c.authMgr = authMgr
 		go common.ComputeDatastoreMapForBlockVolumes(authMgr.(*common.AuthManager),
 			config.Global.CSIAuthCheckIntervalInMin)
+		if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.StoragePolicyPrecheck) {
+			go common.ComputeStoragePolicyIDs(authMgr.(*common.AuthManager),
+				config.Global.CSIAuthCheckIntervalInMin)
+		}
 		isvSANFileServicesSupported, err := c.manager.VcenterManager.IsvSANFileServicesSupported(ctx,
 			c.manager.VcenterConfig.Host)
 		if err != nil {
@@ -193,6 +228,15 @@ func (c *controller) Init(config *cnsconfig.Config, version string) error {
 			go common.ComputeFSEnabledClustersToDsMap(authMgr.(*common.AuthManager),
 				config.Global.CSIAuthCheckIntervalInMin)
 		}
+		if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.AuthRefreshOnPermissionChange) {
+			go common.WatchPermissionEvents(authMgr.(*common.AuthManager), isvSANFileServicesSupported)
+		}
+		if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.DatastoreUsageAlarms) {
+			go placementengine.WatchDatastoreUsageAlarms(vc)
+		}
+		if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.DatastorePropertyCache) {
+			go cnsvsphere.WatchDatastoreProperties(vc)
+		}
 	} else {
 		// Multi vCenter feature enabled
 		c.managers = &common.Managers{
@@ -245,8 +289,13 @@ func (c *controller) Init(config *cnsconfig.Config, version string) error {
 			return logger.LogNewErrorf(log, "failed to initialize authMgr. err=%v", err)
 		}
 		c.authMgrs = authMgrs
+		storagePolicyPrecheckEnabled := commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx,
+			common.StoragePolicyPrecheck)
 		for _, authMgr := range authMgrs {
 			go common.ComputeDatastoreMapForBlockVolumes(authMgr, config.Global.CSIAuthCheckIntervalInMin)
+			if storagePolicyPrecheckEnabled {
+				go common.ComputeStoragePolicyIDs(authMgr, config.Global.CSIAuthCheckIntervalInMin)
+			}
 		}
 		var vsanFileServiceNotSupported bool
 		for _, vcconfig := range c.managers.VcenterConfigs {
@@ -264,8 +313,29 @@ func (c *controller) Init(config *cnsconfig.Config, version string) error {
 		if vsanFileServiceNotSupported {
 			return logger.LogNewErrorf(log, "vSAN file service is not supported in one or more vCenter(s)")
 		}
+		authRefreshOnPermissionChangeEnabled := commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx,
+			common.AuthRefreshOnPermissionChange)
+		datastoreUsageAlarmsEnabled := commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx,
+			common.DatastoreUsageAlarms)
+		datastorePropertyCacheEnabled := commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx,
+			common.DatastorePropertyCache)
 		for _, vcconfig := range c.managers.VcenterConfigs {
 			go common.ComputeFSEnabledClustersToDsMap(authMgrs[vcconfig.Host], config.Global.CSIAuthCheckIntervalInMin)
+			if authRefreshOnPermissionChangeEnabled {
+				go common.WatchPermissionEvents(authMgrs[vcconfig.Host], true)
+			}
+			if datastoreUsageAlarmsEnabled || datastorePropertyCacheEnabled {
+				vcenter, err := c.managers.VcenterManager.GetVirtualCenter(ctx, vcconfig.Host)
+				if err != nil {
+					return logger.LogNewErrorf(log, "failed to get vCenter %q. err=%v", vcconfig.Host, err)
+				}
+				if datastoreUsageAlarmsEnabled {
+					go placementengine.WatchDatastoreUsageAlarms(vcenter)
+				}
+				if datastorePropertyCacheEnabled {
+					go cnsvsphere.WatchDatastoreProperties(vcenter)
+				}
+			}
 		}
 		if multivCenterTopologyDeployment {
 			log.Info("Loading CnsVolumeInfo Service to persist mapping for VolumeID to vCenter")
@@ -279,6 +349,21 @@ func (c *controller) Init(config *cnsconfig.Config, version string) error {
 		}
 	}
 
+	if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.CnsNodeInfo) {
+		log.Info("Loading CnsNodeInfo Service to persist the vCenter details of the nodes")
+		nodeInfoService, err = cnsnodeinfo.InitNodeInfoService(ctx)
+		if err != nil {
+			return logger.LogNewErrorf(log, "failed to load nodeInfoService service. Err: %v", err)
+		}
+		node.GetManager(ctx).SetNodeInfoService(nodeInfoService)
+	}
+	if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.CSIDriverConfig) {
+		log.Info("Loading CSIDriverConfig Service to pick up the changes of the driver tunables")
+		err = csidriverconfig.StartCSIDriverConfigService(ctx)
+		if err != nil {
+			return logger.LogNewErrorf(log, "failed to load CSIDriverConfig service. Err: %v", err)
+		}
+	}
 	c.nodeMgr = &node.Nodes{}
 	err = c.nodeMgr.Initialize(ctx)
 	if err != nil {
@@ -360,6 +445,60 @@ func (c *controller) Init(config *cnsconfig.Config, version string) error {
 		return err
 	}
 
+	c.k8sClient, err = k8s.NewClient(ctx)
+	if err != nil {
+		log.Errorf("failed to create kubernetes client. Error: %+v", err)
+		return err
+	}
+	if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.SnapshotCascadeDelete) {
+		c.snapshotterClient, err = k8s.NewSnapshotterClient(ctx)
+		if err != nil {
+			log.Errorf("failed to create snapshotter client. Error: %+v", err)
+			return err
+		}
+	}
+	admin := newAdminServer(c.k8sClient)
+	if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.VolumeOperationRequestDebug) {
+		debugHandler, err := cnsvolumeoperationrequest.NewDebugHandler()
+		if err != nil {
+			log.Errorf("failed to create volume operation request debug handler. Error: %+v", err)
+			return err
+		}
+		admin.handle(cnsvolumeoperationrequest.DebugHandlerPath, debugHandler)
+	}
+
+	if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.VCenterPrivilegeReport) {
+		admin.handle(privilegeReportHandlerPath, newPrivilegeReportHandler(c))
+	}
+
+	if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.ProvisioningPrecheck) {
+		admin.handle(provisioningPrecheckHandlerPath, newProvisioningPrecheckHandler(c, c.k8sClient))
+	}
+
+	if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.ControllerReadOnlyMode) {
+		k8sClient, err := k8s.NewClient(ctx)
+		if err != nil {
+			log.Errorf("failed to create kubernetes client. Error: %+v", err)
+			return err
+		}
+		c.readOnly, err = watchReadOnlyMode(ctx, k8sClient)
+		if err != nil {
+			return err
+		}
+	}
+
+	if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.DriverCapabilities) {
+		k8sClient, err := k8s.NewClient(ctx)
+		if err != nil {
+			log.Errorf("failed to create kubernetes client. Error: %+v", err)
+			return err
+		}
+		capabilitiesCtx, _ := logger.GetNewContextWithLogger()
+		go c.runDriverCapabilitiesPublisher(capabilitiesCtx, k8sClient, version)
+	}
+
+	admin.start(ctx)
+
 	// Go module to keep the metrics http server running all the time.
 	go func() {
 		prometheus.CsiInfo.WithLabelValues(version).Set(1)
@@ -381,10 +520,14 @@ func (c *controller) Init(config *cnsconfig.Config, version string) error {
 func (c *controller) ReloadConfiguration() error {
 	ctx, log := logger.GetNewContextWithLogger()
 	log.Info("Reloading Configuration")
-	newCfg, err := cnsconfig.GetConfig(ctx)
+	cfgManager, err := cnsconfig.GetConfigManager(ctx)
 	if err != nil {
 		return logger.LogNewErrorf(log, "failed to read config. Error: %+v", err)
 	}
+	if err = cfgManager.Reload(ctx); err != nil {
+		return logger.LogNewErrorf(log, "failed to read config. Error: %+v", err)
+	}
+	newCfg := cfgManager.Snapshot()
 	if multivCenterCSITopologyEnabled {
 		newVcenterConfigs, err := cnsvsphere.GetVirtualCenterConfigs(ctx, newCfg)
 		if err != nil {
@@ -511,6 +654,71 @@ func (c *controller) filterDatastores(ctx context.Context, sharedDatastores []*c
 	return filteredDatastores, nil
 }
 
+// filterDatastoresByLatency skips the datastores reporting a recent latency
+// above thresholdInMs, as seen by the hosts of the node VMs in the given
+// vCenter. It applies the latency threshold to the datastores which aren't
+// selected by the placement engine.
+func (c *controller) filterDatastoresByLatency(ctx context.Context, vcenter *cnsvsphere.VirtualCenter,
+	datastores []*cnsvsphere.DatastoreInfo, thresholdInMs int) []*cnsvsphere.DatastoreInfo {
+	log := logger.GetLogger(ctx)
+	if thresholdInMs <= 0 {
+		return datastores
+	}
+	nodeVMs, err := c.nodeMgr.GetAllNodes(ctx)
+	if err != nil {
+		log.Warnf("failed to get node VMs, skipping latency based filtering. Err: %v", err)
+		return datastores
+	}
+	var vcNodeVMs []*cnsvsphere.VirtualMachine
+	for _, vm := range nodeVMs {
+		if vm.VirtualCenterHost == vcenter.Config.Host {
+			vcNodeVMs = append(vcNodeVMs, vm)
+		}
+	}
+	return placementengine.FilterDatastoresByLatency(ctx, vcenter, vcNodeVMs, datastores, thresholdInMs)
+}
+
+// validateBlockVolumeSize checks the requested size of a block volume against
+// the configured volume size limits and the limit of its Storage Class.
+func validateBlockVolumeSize(ctx context.Context, limits cnsconfig.VolumeSizeLimitsConfig,
+	scParams *common.StorageClassParams, volSizeMB int64) error {
+	log := logger.GetLogger(ctx)
+	maxSizeMB := common.GetMaxBlockVolumeSizeInMb(limits, scParams)
+	if volSizeMB > maxSizeMB {
+		return logger.LogNewErrorCodef(log, codes.OutOfRange,
+			"requested volume size %d MiB exceeds the maximum volume size of %d MiB", volSizeMB, maxSizeMB)
+	}
+	return nil
+}
+
+// filterDatastoresByMaxVolumeSize filters out of sharedDatastores the
+// datastores whose type does not allow volumes of the requested size.
+func filterDatastoresByMaxVolumeSize(ctx context.Context, sharedDatastores []*cnsvsphere.DatastoreInfo,
+	limits cnsconfig.VolumeSizeLimitsConfig, volSizeMB int64) ([]*cnsvsphere.DatastoreInfo, error) {
+	log := logger.GetLogger(ctx)
+	filteredDatastores := common.FilterDatastoresByMaxVolumeSize(ctx, sharedDatastores, limits, volSizeMB)
+	if len(filteredDatastores) == 0 {
+		return nil, logger.LogNewErrorCodef(log, codes.OutOfRange,
+			"requested volume size %d MiB exceeds the maximum volume size of all the compatible datastores",
+			volSizeMB)
+	}
+	return filteredDatastores, nil
+}
+
+// validateStoragePolicy checks the storage policy of a new volume against
+// the storage policies cached by the given authorization service, so that
+// missing storage policies and privileges fail the request before CNS is
+// called.
+func validateStoragePolicy(ctx context.Context, authMgr common.AuthorizationService,
+	scParams *common.StorageClassParams) error {
+	if scParams.StoragePolicyName == "" ||
+		!commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.StoragePolicyPrecheck) {
+		return nil
+	}
+	_, err := authMgr.ValidateStoragePolicy(ctx, scParams.StoragePolicyName)
+	return err
+}
+
 // createBlockVolume creates a block volume based on the CreateVolumeRequest.
 func (c *controller) createBlockVolume(ctx context.Context, req *csi.CreateVolumeRequest) (
 	*csi.CreateVolumeResponse, string, error) {
@@ -528,7 +736,7 @@ func (c *controller) createBlockVolume(ctx context.Context, req *csi.CreateVolum
 
 	// Check if requested volume size and source snapshot size matches
 	volumeSource := req.GetVolumeContentSource()
-	var contentSourceSnapshotID string
+	var contentSourceSnapshotID, snapshotDatastoreURL, snapshotSourceVolumeID string
 	if isBlockVolumeSnapshotEnabled && volumeSource != nil {
 		isCnsSnapshotSupported, err := c.manager.VcenterManager.IsCnsSnapshotSupported(ctx,
 			c.manager.VcenterConfig.Host)
@@ -571,6 +779,8 @@ func (c *controller) createBlockVolume(ctx context.Context, req *csi.CreateVolum
 					"Volume resizing while restoring from snapshot is currently unsupported.",
 				volSizeBytes, snapshotSizeInBytes)
 		}
+		snapshotDatastoreURL = cnsVolumeDetailsMap[cnsVolumeID].DatastoreUrl
+		snapshotSourceVolumeID = cnsVolumeID
 	}
 	// Fetching the feature state for csi-migration before parsing storage class
 	// params.
@@ -582,6 +792,30 @@ func (c *controller) createBlockVolume(ctx context.Context, req *csi.CreateVolum
 			"parsing storage class parameters failed with error: %+v", err)
 	}
 
+	isMultiWriterRequest := common.IsMultiWriterBlockVolumeRequest(ctx, req.GetVolumeCapabilities())
+	if isMultiWriterRequest != scParams.MultiWriter {
+		return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"ReadWriteMany block volumes must use a storage class with %q set to true, "+
+				"and such a storage class can only be used for ReadWriteMany block volumes", common.AttributeMultiWriter)
+	}
+	if scParams.NodeLocal {
+		return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"storage class parameter %q requires the %q feature", common.AttributeNodeLocal,
+			common.MultiVCenterCSITopology)
+	}
+	if scParams.ReclaimAction == common.ReclaimActionArchive &&
+		!commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.ArchiveReclaim) {
+		return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"storage class parameter %q set to %q is not supported as %q feature is disabled",
+			common.AttributeReclaimAction, common.ReclaimActionArchive, common.ArchiveReclaim)
+	}
+	if err := validateBlockVolumeSize(ctx, c.manager.CnsConfig.VolumeSizeLimits, scParams, volSizeMB); err != nil {
+		return nil, csifault.CSIInvalidArgumentFault, err
+	}
+	if err := validateStoragePolicy(ctx, c.authMgr, scParams); err != nil {
+		return nil, csifault.CSIInvalidArgumentFault, err
+	}
+
 	if csiMigrationFeatureState && scParams.CSIMigration == "true" {
 		if len(scParams.Datastore) != 0 {
 			log.Infof("Converting datastore name: %q to Datastore URL", scParams.Datastore)
@@ -629,10 +863,19 @@ func (c *controller) createBlockVolume(ctx context.Context, req *csi.CreateVolum
 			scParams.DatastoreURL = c.manager.VcenterConfig.MigrationDataStoreURL
 		}
 	}
+	if contentSourceSnapshotID != "" {
+		err := pinRestoreDatastore(ctx, c.manager.VcenterConfig.Host, scParams, snapshotDatastoreURL)
+		if err != nil {
+			return nil, csifault.CSIInvalidArgumentFault, err
+		}
+	}
 
+	// The name of the volume is also the key of its CreateVolume task details.
+	volumeName := common.GenerateVolumeName(c.manager.CnsConfig.Global.VolumeNameTemplate,
+		c.manager.CnsConfig.Global.ClusterID, req.Name, scParams)
 	var createVolumeSpec = common.CreateVolumeSpec{
 		CapacityMB:              volSizeMB,
-		Name:                    req.Name,
+		Name:                    volumeName,
 		ScParams:                scParams,
 		VolumeType:              common.BlockVolumeType,
 		ContentSourceSnapshotID: contentSourceSnapshotID,
@@ -653,6 +896,20 @@ func (c *controller) createBlockVolume(ctx context.Context, req *csi.CreateVolum
 		return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
 			"failed to get vCenter. Err: %v", err)
 	}
+	if scParams.MultiWriter {
+		var storagePolicyID string
+		if scParams.StoragePolicyName != "" {
+			storagePolicyID, err = vcenter.GetStoragePolicyIDByName(ctx, scParams.StoragePolicyName)
+			if err != nil {
+				return nil, csifault.FaultTypeFromError(err, csifault.CSIInvalidArgumentFault),
+					logger.LogNewErrorCodef(log, codes.InvalidArgument,
+						"failed to get storage policy ID for %q. Err: %v", scParams.StoragePolicyName, err)
+			}
+		}
+		if err = common.ValidateMultiWriterStoragePolicy(ctx, vcenter, storagePolicyID); err != nil {
+			return nil, csifault.CSIInvalidArgumentFault, err
+		}
+	}
 	// Get operation store
 	operationStore := c.manager.VolumeManager.GetOperationStore()
 	if operationStore == nil {
@@ -660,7 +917,7 @@ func (c *controller) createBlockVolume(ctx context.Context, req *csi.CreateVolum
 			"Operation store cannot be nil")
 	}
 
-	volumeOperationDetails, err := operationStore.GetRequestDetails(ctx, req.Name)
+	volumeOperationDetails, err := operationStore.GetRequestDetails(ctx, volumeName)
 	if err != nil {
 		if apierrors.IsNotFound(err) {
 			log.Debugf("CreateVolume task details for block volume %s are not found.", req.Name)
@@ -712,7 +969,7 @@ func (c *controller) createBlockVolume(ctx context.Context, req *csi.CreateVolum
 			}()
 
 			volumeInfo, faultType, err = c.manager.VolumeManager.MonitorCreateVolumeTask(ctx,
-				&volumeOperationDetails, task, req.Name, c.manager.CnsConfig.Global.ClusterID)
+				&volumeOperationDetails, task, volumeName, c.manager.CnsConfig.Global.ClusterID)
 			if err != nil {
 				return nil, faultType, logger.LogNewErrorCodef(log, codes.Internal,
 					"failed to monitor task for volume %s. Error: %+v", req.Name, err)
@@ -728,11 +985,13 @@ func (c *controller) createBlockVolume(ctx context.Context, req *csi.CreateVolum
 	// Get accessibility.
 	topologyRequirement = req.GetAccessibilityRequirements()
 	if !volTaskAlreadyRegistered {
+		if err := checkPVCBeforeCreateVolume(ctx, scParams, req.Name); err != nil {
+			return nil, csifault.CSIInternalFault, err
+		}
 		if topologyRequirement != nil {
-			// Check if topology domains have been provided in the vSphere CSI config secret.
+			// Check if topology domains have been provided in the CSIDriverConfig or the vSphere CSI config secret.
 			// NOTE: We do not support kubernetes.io/hostname as a topology label.
-			if c.manager.CnsConfig.Labels.TopologyCategories == "" && c.manager.CnsConfig.Labels.Zone == "" &&
-				c.manager.CnsConfig.Labels.Region == "" {
+			if !isTopologyConfigured(c.manager.CnsConfig) {
 				return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCode(log, codes.InvalidArgument,
 					"topology category names not specified in the vsphere config secret")
 			}
@@ -779,17 +1038,69 @@ func (c *controller) createBlockVolume(ctx context.Context, req *csi.CreateVolum
 			return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
 				"failed to create volume. Error: %+v", err)
 		}
+		sharedDatastores, err = filterDatastoresByMaxVolumeSize(ctx, sharedDatastores,
+			c.manager.CnsConfig.VolumeSizeLimits, volSizeMB)
+		if err != nil {
+			return nil, csifault.CSIInvalidArgumentFault, err
+		}
+		sharedDatastores = c.filterDatastoresByLatency(ctx, vcenter, sharedDatastores,
+			c.manager.CnsConfig.Placement.DatastoreLatencyThresholdInMs)
+		if faultType, err := runProvisioningDryRun(ctx, vcenter, scParams, volSizeMB, sharedDatastores); err != nil {
+			return nil, faultType, err
+		}
 
-		volumeInfo, faultType, err = common.CreateBlockVolumeUtil(ctx, cnstypes.CnsClusterFlavorVanilla,
-			c.manager, &createVolumeSpec, sharedDatastores, filterSuspendedDatastores, false, nil)
+		var restoreConversion *snapshotRestoreConversion
+		if contentSourceSnapshotID != "" {
+			restoreConversion, err = planSnapshotRestoreConversion(ctx, vcenter, c.manager.VolumeManager,
+				snapshotSourceVolumeID, snapshotDatastoreURL, scParams, sharedDatastores)
+			if err != nil {
+				return nil, csifault.CSIInvalidArgumentFault, err
+			}
+		}
+		if restoreConversion != nil {
+			volumeInfo, faultType, err = restoreConversion.restoreVolume(ctx, c.manager, operationStore,
+				&createVolumeSpec, filterSuspendedDatastores)
+		} else {
+			volumeInfo, faultType, err = common.CreateBlockVolumeUtil(ctx, cnstypes.CnsClusterFlavorVanilla,
+				c.manager, &createVolumeSpec, sharedDatastores, filterSuspendedDatastores, false, nil)
+		}
 		if err != nil {
 			return nil, faultType, logger.LogNewErrorCodef(log, codes.Internal,
 				"failed to create volume. Error: %+v", err)
 		}
 	}
 
+	cancelledDetails, err := cancelVolumeIfPVCDeleted(ctx, c.manager.VolumeManager, operationStore, scParams,
+		volumeName, req.Name, volumeInfo.VolumeID.Id)
+	if err != nil {
+		if cancelledDetails != nil {
+			// Do not let a pending task monitor persist the details of the
+			// deleted volume.
+			volumeOperationDetails = cancelledDetails
+		}
+		return nil, csifault.CSIInternalFault, err
+	}
+
+	if scParams.ReclaimAction == common.ReclaimActionArchive {
+		if err := markVolumeForArchival(ctx, c.manager.VolumeManager, volumeInfo.VolumeID.Id,
+			req.GetVolumeCapabilities()); err != nil {
+			return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+				"failed to set reclaim action on volume %q. Error: %+v", volumeInfo.VolumeID.Id, err)
+		}
+	}
+
+	common.SetVolumeType(volumeInfo.VolumeID.Id, common.BlockVolumeType)
 	attributes := make(map[string]string)
 	attributes[common.AttributeDiskType] = common.DiskTypeBlockVolume
+	if volumeInfo.DatastoreURL != "" {
+		attributes[common.AttributeDatastoreURL] = volumeInfo.DatastoreURL
+	}
+	if contentSourceSnapshotID != "" &&
+		commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.SnapshotOwnershipRestore) {
+		for attribute, value := range getSnapshotOwnershipAttributes(ctx, contentSourceSnapshotID) {
+			attributes[attribute] = value
+		}
+	}
 	if csiMigrationFeatureState && scParams.CSIMigration == "true" {
 		// In case if feature state switch is enabled after controller is
 		// deployed, we need to initialize the volumeMigrationService.
@@ -956,6 +1267,33 @@ func (c *controller) createBlockVolumeWithPlacementEngineForMultiVC(ctx context.
 		return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.InvalidArgument,
 			"parsing storage class parameters failed with error: %+v", err)
 	}
+	if err := validateBlockVolumeSize(ctx, c.managers.CnsConfig.VolumeSizeLimits, scParams, volSizeMB); err != nil {
+		return nil, csifault.CSIInvalidArgumentFault, err
+	}
+	if scParams.MultiWriter || common.IsMultiWriterBlockVolumeRequest(ctx, req.GetVolumeCapabilities()) {
+		return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCode(log, codes.InvalidArgument,
+			"ReadWriteMany block volumes are not supported on multi vCenter deployment")
+	}
+	if scParams.NodeLocal {
+		if !commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.NodeLocalVolumes) {
+			return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+				"storage class parameter %q is not supported as %q feature is disabled",
+				common.AttributeNodeLocal, common.NodeLocalVolumes)
+		}
+		// Node local volumes are only accessible from one host, so the host
+		// must be part of the published topology for pods to follow the volume.
+		if !c.managers.CnsConfig.Labels.HostTopology || req.GetAccessibilityRequirements() == nil {
+			return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+				"storage class parameter %q requires host-topology to be enabled in the vsphere config secret "+
+					"and a storage class with volumeBindingMode WaitForFirstConsumer", common.AttributeNodeLocal)
+		}
+	}
+	if scParams.ReclaimAction == common.ReclaimActionArchive &&
+		!commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.ArchiveReclaim) {
+		return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"storage class parameter %q set to %q is not supported as %q feature is disabled",
+			common.AttributeReclaimAction, common.ReclaimActionArchive, common.ArchiveReclaim)
+	}
 
 	if scParams.CSIMigration == "true" {
 		if len(c.managers.VcenterConfigs) > 1 {
@@ -1012,7 +1350,7 @@ func (c *controller) createBlockVolumeWithPlacementEngineForMultiVC(ctx context.
 
 	// Check if requested volume size and source snapshot size matches.
 	volumeSource := req.GetVolumeContentSource()
-	var contentSourceSnapshotID, snapshotDatastoreURL string
+	var contentSourceSnapshotID, snapshotDatastoreURL, snapshotVCHost string
 	if volumeSource != nil {
 		sourceSnapshot := volumeSource.GetSnapshot()
 		if sourceSnapshot == nil {
@@ -1025,12 +1363,12 @@ func (c *controller) createBlockVolumeWithPlacementEngineForMultiVC(ctx context.
 			return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCode(log,
 				codes.InvalidArgument, err.Error())
 		}
-		// Get VC, volumeManager for given volumeID.
-		vCenterHost, volumeManager, err := getVCenterAndVolumeManagerForVolumeID(ctx, c, cnsVolumeID,
+		// Get VC, volumeManager for given snapshot.
+		vCenterHost, volumeManager, err := getVCenterAndVolumeManagerForSnapshotID(ctx, c, contentSourceSnapshotID,
 			volumeInfoService)
 		if err != nil {
 			return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
-				"failed to get vCenter/volume manager for volumeID: %q. Error: %+v", cnsVolumeID, err)
+				"failed to get vCenter/volume manager for snapshot: %q. Error: %+v", contentSourceSnapshotID, err)
 		}
 		isCnsSnapshotSupported, err := c.managers.VcenterManager.IsCnsSnapshotSupported(ctx, vCenterHost)
 		if err != nil {
@@ -1060,8 +1398,13 @@ func (c *controller) createBlockVolumeWithPlacementEngineForMultiVC(ctx context.
 				"snapshot size mismatch, requested volume size: %d but source snapshot size: %d",
 				volSizeBytes, snapshotSizeInBytes)
 		}
-		// Store the datastoreURL of snapshot for future use.
+		// Store the datastoreURL and vCenter of snapshot for future use.
 		snapshotDatastoreURL = cnsVolumeDetailsMap[cnsVolumeID].DatastoreUrl
+		snapshotVCHost = vCenterHost
+		err = pinRestoreDatastore(ctx, vCenterHost, scParams, snapshotDatastoreURL)
+		if err != nil {
+			return nil, csifault.CSIInvalidArgumentFault, err
+		}
 		// If DatastoreURL parameter is given in StorageClass, check if
 		// snapshot datastore URL is same as DatastoreURL.
 		if scParams.DatastoreURL != "" {
@@ -1073,9 +1416,12 @@ func (c *controller) createBlockVolumeWithPlacementEngineForMultiVC(ctx context.
 		}
 	}
 
+	// The name of the volume is also the key of its CreateVolume task details.
+	volumeName := common.GenerateVolumeName(c.managers.CnsConfig.Global.VolumeNameTemplate,
+		c.managers.CnsConfig.Global.ClusterID, req.Name, scParams)
 	var createVolumeSpec = common.CreateVolumeSpec{
 		CapacityMB:              volSizeMB,
-		Name:                    req.Name,
+		Name:                    volumeName,
 		ScParams:                scParams,
 		VolumeType:              common.BlockVolumeType,
 		ContentSourceSnapshotID: contentSourceSnapshotID,
@@ -1104,7 +1450,7 @@ func (c *controller) createBlockVolumeWithPlacementEngineForMultiVC(ctx context.
 		break
 	}
 
-	volumeOperationDetails, err := operationStore.GetRequestDetails(ctx, req.Name)
+	volumeOperationDetails, err := operationStore.GetRequestDetails(ctx, volumeName)
 	if err != nil {
 		if apierrors.IsNotFound(err) {
 			log.Debugf("CreateVolume task details for block volume %s are not found.", req.Name)
@@ -1186,7 +1532,7 @@ func (c *controller) createBlockVolumeWithPlacementEngineForMultiVC(ctx context.
 				return nil, csifault.CSIInternalFault, logger.LogNewErrorCode(log, codes.Internal, err.Error())
 			}
 			volumeInfo, faultType, err = volumeMgr.MonitorCreateVolumeTask(ctx,
-				&volumeOperationDetails, task, req.Name, c.managers.CnsConfig.Global.ClusterID)
+				&volumeOperationDetails, task, volumeName, c.managers.CnsConfig.Global.ClusterID)
 			if err != nil {
 				return nil, faultType, logger.LogNewErrorCodef(log, codes.Internal,
 					"failed to monitor task for volume %s on VC %q. Error: %+v", req.Name, vcHost, err)
@@ -1234,21 +1580,40 @@ func (c *controller) createBlockVolumeWithPlacementEngineForMultiVC(ctx context.
 	}
 
 	if topologyRequirement != nil {
-		// Check if topology domains have been provided in the vSphere CSI config secret.
+		// Check if topology domains have been provided in the CSIDriverConfig or the vSphere CSI config secret.
 		// NOTE: We do not support kubernetes.io/hostname as a topology label.
-		if c.managers.CnsConfig.Labels.TopologyCategories == "" && c.managers.CnsConfig.Labels.Zone == "" &&
-			c.managers.CnsConfig.Labels.Region == "" {
+		if !isTopologyConfigured(c.managers.CnsConfig) {
 			return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCode(log, codes.InvalidArgument,
 				"topology category names not specified in the vsphere config secret")
 		}
 	}
 
 	if !volTaskAlreadyRegistered {
+		if err := checkPVCBeforeCreateVolume(ctx, scParams, req.Name); err != nil {
+			return nil, csifault.CSIInternalFault, err
+		}
+		// A volume is restored from a snapshot on the VC of the snapshot, unless none of the
+		// accessibility requirements belong to it. The disk is then copied to the VC of the volume.
+		_, snapshotVCRequested := vcTopologySegmentsMap[snapshotVCHost]
+		crossVCClone := contentSourceSnapshotID != "" && multivCenterTopologyDeployment && !snapshotVCRequested &&
+			commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.CrossVCenterClone)
 		// Iterate through each VC and its accessibility requirements to try and create a volume.
 		// If it fails for any reason, move unto the next VC in list.
 		if topologyRequirement != nil {
+			// VCs whose requested topology segments recently failed provisioning repeatedly are tried last.
+			segmentBackoff := commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx,
+				common.ProvisioningSegmentBackoff)
+			var vcHosts []string
+			if segmentBackoff {
+				vcHosts = placementengine.OrderVCentersBySegmentBackoff(ctx, vcTopologySegmentsMap)
+			} else {
+				for vcHost := range vcTopologySegmentsMap {
+					vcHosts = append(vcHosts, vcHost)
+				}
+			}
 			var topologySegmentsList []map[string]string
-			for vcHost, topologySegmentsList = range vcTopologySegmentsMap {
+			for _, vcHost = range vcHosts {
+				topologySegmentsList = vcTopologySegmentsMap[vcHost]
 				// Get VC instance.
 				vcenter, err = common.GetVCenterFromVCHost(ctx, c.managers.VcenterManager, vcHost)
 				if err != nil {
@@ -1284,11 +1649,16 @@ func (c *controller) createBlockVolumeWithPlacementEngineForMultiVC(ctx context.
 				// Get shared accessible datastores for topology segments associated with the vcHost.
 				sharedDatastores, err = placementengine.GetSharedDatastores(ctx,
 					placementengine.VanillaSharedDatastoresParams{
-						Vcenter:              vcenter,
-						TopologySegmentsList: topologySegmentsList,
-						StoragePolicyID:      storagePolicyID,
+						Vcenter:                       vcenter,
+						TopologySegmentsList:          topologySegmentsList,
+						StoragePolicyID:               storagePolicyID,
+						DatastoreLatencyThresholdInMs: c.managers.CnsConfig.Placement.DatastoreLatencyThresholdInMs,
+						NodeLocal:                     scParams.NodeLocal,
 					})
 				if err != nil {
+					if segmentBackoff {
+						placementengine.RecordProvisioningFailure(ctx, vcHost, topologySegmentsList)
+					}
 					return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
 						"failed to get shared datastores for topology segments %+v in vCenter %q. Error: %+v",
 						topologySegmentsList, vcHost, err)
@@ -1315,10 +1685,55 @@ func (c *controller) createBlockVolumeWithPlacementEngineForMultiVC(ctx context.
 						"failed to filter datastores based on authorisation check in vCenter %q. Error: %+v",
 						vcHost, err)
 				}
+				sharedDatastores = common.FilterDatastoresByMaxVolumeSize(ctx, sharedDatastores,
+					c.managers.CnsConfig.VolumeSizeLimits, volSizeMB)
+				if len(sharedDatastores) == 0 {
+					errMsg := fmt.Sprintf("requested volume size %d MiB exceeds the maximum volume size of "+
+						"all the compatible datastores found for accessibility requirements %+v associated "+
+						"with vCenter %q", volSizeMB, topologySegmentsList, vcHost)
+					log.Warn(errMsg)
+					combinedErrMssgs = append(combinedErrMssgs, errMsg)
+					continue
+				}
+				if faultType, err := runProvisioningDryRun(ctx, vcenter, scParams, volSizeMB, sharedDatastores); err != nil {
+					return nil, faultType, err
+				}
 				volumeMgr, err = GetVolumeManagerFromVCHost(ctx, c.managers, vcHost)
 				if err != nil {
 					return nil, csifault.CSIInternalFault, logger.LogNewErrorCode(log, codes.Internal, err.Error())
 				}
+				if crossVCClone {
+					sourceVcenter, err := common.GetVCenterFromVCHost(ctx, c.managers.VcenterManager, snapshotVCHost)
+					if err != nil {
+						return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+							"failed to get vCenter instance for host %q. Error: %+v", snapshotVCHost, err)
+					}
+					sourceVolumeMgr, err := GetVolumeManagerFromVCHost(ctx, c.managers, snapshotVCHost)
+					if err != nil {
+						return nil, csifault.CSIInternalFault, logger.LogNewErrorCode(log, codes.Internal, err.Error())
+					}
+					volumeInfo, faultType, err = createVolumeFromSnapshotAcrossVCenters(ctx, crossVCCloneParams{
+						SourceVcenter:        sourceVcenter,
+						SourceVolumeManager:  sourceVolumeMgr,
+						SnapshotDatastoreURL: snapshotDatastoreURL,
+						TargetVcenter:        vcenter,
+						TargetVolumeManager:  volumeMgr,
+						TargetDatastores:     sharedDatastores,
+						StoragePolicyID:      storagePolicyID,
+						CNSConfig:            c.managers.CnsConfig,
+						Spec:                 &createVolumeSpec,
+					})
+					if err != nil {
+						if status.Code(err) == codes.Aborted {
+							return nil, csifault.CSIInternalFault, err
+						}
+						combinedErrMssgs = append(combinedErrMssgs, err.Error())
+						continue
+					}
+					log.Infof("volume %q created in vCenter %q from snapshot %q of vCenter %q",
+						volumeInfo.VolumeID.Id, vcHost, contentSourceSnapshotID, snapshotVCHost)
+					break
+				}
 				// Call CreateVolume.
 				// TODO: Few errors encountered  in CreateBlockVolumeUtilForMultiVC can be
 				// retried instead of moving unto next VC. Need to throw a custom error for such scenarios.
@@ -1335,9 +1750,15 @@ func (c *controller) createBlockVolumeWithPlacementEngineForMultiVC(ctx context.
 					})
 				if err != nil {
 					log.Error(err)
+					if segmentBackoff {
+						placementengine.RecordProvisioningFailure(ctx, vcHost, topologySegmentsList)
+					}
 					combinedErrMssgs = append(combinedErrMssgs, err.Error())
 					continue
 				}
+				if segmentBackoff {
+					placementengine.RecordProvisioningSuccess(ctx, vcHost, topologySegmentsList)
+				}
 				log.Infof("volume %q created in vCenter %q. Proceeding to calculate "+
 					"accessible topology for the volume", volumeInfo.VolumeID.Id, vcHost)
 				break
@@ -1355,6 +1776,9 @@ func (c *controller) createBlockVolumeWithPlacementEngineForMultiVC(ctx context.
 			if err != nil {
 				return nil, csifault.CSIInternalFault, logger.LogNewErrorCode(log, codes.Internal, err.Error())
 			}
+			if err := validateStoragePolicy(ctx, c.authMgrs[vcHost], scParams); err != nil {
+				return nil, csifault.CSIInvalidArgumentFault, err
+			}
 
 			// If Storage policy is given, check if it exists in the VC.
 			// If not found, fail Volume Creation
@@ -1385,6 +1809,16 @@ func (c *controller) createBlockVolumeWithPlacementEngineForMultiVC(ctx context.
 				return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
 					"failed to create volume. Error: %+v", err)
 			}
+			sharedDatastores, err = filterDatastoresByMaxVolumeSize(ctx, sharedDatastores,
+				c.managers.CnsConfig.VolumeSizeLimits, volSizeMB)
+			if err != nil {
+				return nil, csifault.CSIInvalidArgumentFault, err
+			}
+			sharedDatastores = c.filterDatastoresByLatency(ctx, vcenter, sharedDatastores,
+				c.managers.CnsConfig.Placement.DatastoreLatencyThresholdInMs)
+			if faultType, err := runProvisioningDryRun(ctx, vcenter, scParams, volSizeMB, sharedDatastores); err != nil {
+				return nil, faultType, err
+			}
 
 			volumeInfo, faultType, err = common.CreateBlockVolumeUtilForMultiVC(ctx,
 				common.VanillaCreateBlockVolParamsForMultiVC{
@@ -1412,8 +1846,43 @@ func (c *controller) createBlockVolumeWithPlacementEngineForMultiVC(ctx context.
 			"failed to create volume. Errors encountered: %+v", combinedErrMssgs)
 	}
 
+	if volumeMgr == nil {
+		volumeMgr, err = GetVolumeManagerFromVCHost(ctx, c.managers, vcHost)
+		if err != nil {
+			return nil, csifault.CSIInternalFault, logger.LogNewErrorCode(log, codes.Internal, err.Error())
+		}
+	}
+	cancelledDetails, err := cancelVolumeIfPVCDeleted(ctx, volumeMgr, operationStore, scParams,
+		volumeName, req.Name, volumeInfo.VolumeID.Id)
+	if err != nil {
+		if cancelledDetails != nil {
+			// Do not let a pending task monitor persist the details of the
+			// deleted volume.
+			volumeOperationDetails = cancelledDetails
+		}
+		return nil, csifault.CSIInternalFault, err
+	}
+
+	if scParams.ReclaimAction == common.ReclaimActionArchive {
+		if err := markVolumeForArchival(ctx, volumeMgr, volumeInfo.VolumeID.Id,
+			req.GetVolumeCapabilities()); err != nil {
+			return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+				"failed to set reclaim action on volume %q. Error: %+v", volumeInfo.VolumeID.Id, err)
+		}
+	}
+
+	common.SetVolumeType(volumeInfo.VolumeID.Id, common.BlockVolumeType)
 	attributes := make(map[string]string)
 	attributes[common.AttributeDiskType] = common.DiskTypeBlockVolume
+	if volumeInfo.DatastoreURL != "" {
+		attributes[common.AttributeDatastoreURL] = volumeInfo.DatastoreURL
+	}
+	if contentSourceSnapshotID != "" &&
+		commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.SnapshotOwnershipRestore) {
+		for attribute, value := range getSnapshotOwnershipAttributes(ctx, contentSourceSnapshotID) {
+			attributes[attribute] = value
+		}
+	}
 
 	if scParams.CSIMigration == "true" {
 		volumePath, err := volumeMigrationService.GetVolumePath(ctx, volumeInfo.VolumeID.Id)
@@ -1592,6 +2061,27 @@ func (c *controller) createFileVolume(ctx context.Context, req *csi.CreateVolume
 		return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.InvalidArgument,
 			"parsing storage class parameters failed with error: %+v", err)
 	}
+	if scParams.MultiWriter {
+		return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"storage class parameter %q is only supported for ReadWriteMany block volumes", common.AttributeMultiWriter)
+	}
+	if scParams.NodeLocal {
+		return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"storage class parameter %q is only supported for block volumes", common.AttributeNodeLocal)
+	}
+	if scParams.ReclaimAction == common.ReclaimActionArchive {
+		return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"storage class parameter %q set to %q is only supported for block volumes",
+			common.AttributeReclaimAction, common.ReclaimActionArchive)
+	}
+	if scParams.MaxVolumeSizeGb != 0 {
+		return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"storage class parameter %q is only supported for block volumes", common.AttributeMaxVolumeSizeGb)
+	}
+	if scParams.DatastoreFolder != "" {
+		return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"storage class parameter %q is only supported for block volumes", common.AttributeDatastoreFolder)
+	}
 
 	var (
 		volTaskAlreadyRegistered bool
@@ -1699,10 +2189,9 @@ func (c *controller) createFileVolume(ctx context.Context, req *csi.CreateVolume
 		// Get accessibility requirements.
 		topologyRequirement := req.GetAccessibilityRequirements()
 		if topologyRequirement != nil {
-			// Check if topology domains have been provided in the vSphere CSI config secret.
+			// Check if topology domains have been provided in the CSIDriverConfig or the vSphere CSI config secret.
 			// NOTE: We do not support kubernetes.io/hostname as a topology label.
-			if c.managers.CnsConfig.Labels.TopologyCategories == "" && c.managers.CnsConfig.Labels.Zone == "" &&
-				c.managers.CnsConfig.Labels.Region == "" {
+			if !isTopologyConfigured(c.managers.CnsConfig) {
 				return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCode(log, codes.InvalidArgument,
 					"topology category names not specified in the vsphere config secret")
 			}
@@ -1928,6 +2417,7 @@ func (c *controller) createFileVolume(ctx context.Context, req *csi.CreateVolume
 		}
 	}
 
+	common.SetVolumeType(volumeID, common.FileVolumeType)
 	attributes := make(map[string]string)
 	attributes[common.AttributeDiskType] = common.DiskTypeFileVolume
 
@@ -1953,6 +2443,9 @@ func (c *controller) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequ
 	createVolumeInternal := func() (
 		*csi.CreateVolumeResponse, string, error) {
 		log.Infof("CreateVolume: called with args %+v", *req)
+		if err := c.readOnly.check(ctx, "CreateVolume"); err != nil {
+			return nil, csifault.CSIReadOnlyModeFault, err
+		}
 		// TODO: If the err is returned by invoking CNS API, then faultType should be
 		// populated by the underlying layer.
 		// If the request failed due to validate the request, "csi.fault.InvalidArgument" will be return.
@@ -1964,6 +2457,11 @@ func (c *controller) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequ
 			return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.InvalidArgument,
 				"volume capability not supported. Err: %+v", err)
 		}
+		if c.policyEngine != nil {
+			if faultType, err := c.reviewCreateVolumeRequest(ctx, req); err != nil {
+				return nil, faultType, err
+			}
+		}
 		if common.IsFileVolumeRequest(ctx, volumeCapabilities) {
 			// Error out if TopologyRequirement is provided during file volume provisioning
 			// as this is not supported yet.
@@ -2021,6 +2519,9 @@ func (c *controller) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequ
 		if csifault.IsNonStorageFault(faultType) {
 			faultType = csifault.AddCsiNonStoragePrefix(ctx, faultType)
 		}
+		if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.StructuredCSIErrors) {
+			err = csifault.WithFaultType(err, faultType)
+		}
 		log.Errorf("Operation failed, reporting failure status to Prometheus."+
 			" Operation Type: %q, Volume Type: %q, Fault Type: %q",
 			prometheus.PrometheusCreateVolumeOpType, volumeType, faultType)
@@ -2046,6 +2547,9 @@ func (c *controller) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequ
 	deleteVolumeInternal := func() (
 		*csi.DeleteVolumeResponse, string, error) {
 		log.Infof("DeleteVolume: called with args: %+v", *req)
+		if err := c.readOnly.check(ctx, "DeleteVolume"); err != nil {
+			return nil, csifault.CSIReadOnlyModeFault, err
+		}
 		// TODO: If the err is returned by invoking CNS API, then faultType should be
 		// populated by the underlying layer.
 		// If the request failed due to validate the request, "csi.fault.InvalidArgument" will be return.
@@ -2109,6 +2613,17 @@ func (c *controller) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequ
 			}
 			volumeType = convertCnsVolumeType(ctx, cnsVolumeType)
 		}
+		if cnsVolumeType == common.BlockVolumeType &&
+			commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.VolumeDeletionProtection) {
+			retained, faultType, err := handleVolumeDeletionProtection(ctx, c, vCenterHost, vCenterManager,
+				volumeManager, req.VolumeId)
+			if err != nil {
+				return nil, faultType, err
+			}
+			if retained {
+				return &csi.DeleteVolumeResponse{}, "", nil
+			}
+		}
 		// Check if the volume contains CNS snapshots only for block volumes.
 		if cnsVolumeType == common.BlockVolumeType &&
 			commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.BlockVolumeSnapshot) {
@@ -2124,17 +2639,37 @@ func (c *controller) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequ
 					return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
 						"failed to retrieve snapshots for volume: %s. Error: %+v", req.VolumeId, err)
 				}
+				if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.RecentSnapshotProtection) {
+					faultType, err = deferDeleteForRecentSnapshots(ctx, c, volumeManager, req.VolumeId, snapshots)
+					if err != nil {
+						return nil, faultType, err
+					}
+				}
 				if len(snapshots) == 0 {
 					log.Infof("no CNS snapshots found for volume: %s, the volume can be safely deleted",
 						req.VolumeId)
 				} else {
-					return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.FailedPrecondition,
-						"volume: %s with existing snapshots %v cannot be deleted, "+
-							"please delete snapshots before deleting the volume", req.VolumeId, snapshots)
+					faultType, err = handleVolumeSnapshotsOnDelete(ctx, c, volumeManager, req.VolumeId, snapshots)
+					if err != nil {
+						return nil, faultType, err
+					}
 				}
 			}
 		}
-		faultType, err = common.DeleteVolumeUtil(ctx, volumeManager, req.VolumeId, true)
+		archive := false
+		if cnsVolumeType == common.BlockVolumeType &&
+			commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.ArchiveReclaim) {
+			archive, err = isVolumeMarkedForArchival(ctx, volumeManager, req.VolumeId)
+			if err != nil {
+				return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+					"failed to retrieve reclaim action of volume: %q. Error: %+v", req.VolumeId, err)
+			}
+		}
+		if archive {
+			faultType, err = archiveVolume(ctx, c, vCenterHost, volumeManager, req.VolumeId)
+		} else {
+			faultType, err = common.DeleteVolumeUtil(ctx, volumeManager, req.VolumeId, true)
+		}
 		if err != nil {
 			return nil, faultType, logger.LogNewErrorCodef(log, codes.Internal,
 				"failed to delete volume: %q. Error: %+v", req.VolumeId, err)
@@ -2164,6 +2699,9 @@ func (c *controller) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequ
 		if csifault.IsNonStorageFault(faultType) {
 			faultType = csifault.AddCsiNonStoragePrefix(ctx, faultType)
 		}
+		if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.StructuredCSIErrors) {
+			err = csifault.WithFaultType(err, faultType)
+		}
 		log.Errorf("Operation failed, reporting failure status to Prometheus."+
 			" Operation Type: %q, Volume Type: %q, Fault Type: %q",
 			prometheus.PrometheusDeleteVolumeOpType, volumeType, faultType)
@@ -2171,6 +2709,7 @@ func (c *controller) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequ
 			prometheus.PrometheusFailStatus, faultType).Observe(time.Since(start).Seconds())
 	} else {
 		log.Infof("Volume %q deleted successfully.", req.VolumeId)
+		common.ForgetVolumeType(req.VolumeId)
 		prometheus.CsiControlOpsHistVec.WithLabelValues(volumeType, prometheus.PrometheusDeleteVolumeOpType,
 			prometheus.PrometheusPassStatus, faultType).Observe(time.Since(start).Seconds())
 	}
@@ -2185,6 +2724,7 @@ func (c *controller) ControllerPublishVolume(ctx context.Context, req *csi.Contr
 	ctx = logger.NewContextWithLogger(ctx)
 	log := logger.GetLogger(ctx)
 	volumeType := prometheus.PrometheusUnknownVolumeType
+	var vcenterHost string
 
 	controllerPublishVolumeInternal := func() (
 		*csi.ControllerPublishVolumeResponse, string, error) {
@@ -2201,8 +2741,15 @@ func (c *controller) ControllerPublishVolume(ctx context.Context, req *csi.Contr
 			return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log, codes.Internal,
 				"validation for PublishVolume Request: %+v has failed. Error: %v", *req, err)
 		}
+		if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.NodePluginVersionCheck) {
+			if err := c.checkNodePluginVersion(ctx, req); err != nil {
+				return nil, csifault.CSIInvalidArgumentFault, err
+			}
+		}
 		publishInfo := make(map[string]string)
-		_, volumeManager, err := getVCenterAndVolumeManagerForVolumeID(ctx, c, req.VolumeId, volumeInfoService)
+		var volumeManager cnsvolume.Manager
+		vcenterHost, volumeManager, err = getVCenterAndVolumeManagerForVolumeID(ctx, c, req.VolumeId,
+			volumeInfoService)
 		if err != nil {
 			return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
 				"failed to get volume manager for volume Id: %q. Error: %v", req.VolumeId, err)
@@ -2290,10 +2837,51 @@ func (c *controller) ControllerPublishVolume(ctx context.Context, req *csi.Contr
 					"failed to find VirtualMachine for node:%q. Error: %v", req.NodeId, err)
 			}
 			log.Debugf("Found VirtualMachine for node:%q.", req.NodeId)
+			if nodeInfoService != nil {
+				if err := checkDatastoreAccessibleFromNode(ctx, nodevm,
+					req.VolumeContext[common.AttributeDatastoreURL]); err != nil {
+					return nil, csifault.CSIInvalidArgumentFault, err
+				}
+			}
+			if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.DiskSlotValidation) {
+				var attachConfig cnsconfig.AttachConfig
+				if multivCenterCSITopologyEnabled {
+					attachConfig = c.managers.CnsConfig.Attach
+				} else {
+					attachConfig = c.manager.CnsConfig.Attach
+				}
+				err = nodevm.EnsureFreeDiskSlot(ctx, attachConfig.AddControllersOnDemand)
+				if errors.Is(err, cnsvsphere.ErrNoFreeDiskSlots) {
+					return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.ResourceExhausted,
+						"cannot attach volume %q to node %q: %v", req.VolumeId, req.NodeId, err)
+				}
+				if err != nil {
+					return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+						"failed to check the free disk slots of node %q: %v", req.NodeId, err)
+				}
+			}
+			if common.IsMultiWriterBlockVolumeRequest(ctx, []*csi.VolumeCapability{req.VolumeCapability}) {
+				// CNS attaches disks in the exclusive sharing mode, which
+				// prevents attaching them to other nodes.
+				diskUUID, faultType, err := attachMultiWriterVolume(ctx, volumeManager, nodevm, req.VolumeId)
+				if err != nil {
+					return nil, faultType, err
+				}
+				publishInfo[common.AttributeDiskType] = common.DiskTypeBlockVolume
+				publishInfo[common.AttributeFirstClassDiskUUID] = common.FormatDiskUUID(diskUUID)
+				log.Infof("ControllerPublishVolume successful with publish context: %v", publishInfo)
+				return &csi.ControllerPublishVolumeResponse{
+					PublishContext: publishInfo,
+				}, "", nil
+			}
 			// faultType is returned from manager.AttachVolume.
 			diskUUID, faultType, err := common.AttachVolumeUtil(ctx, volumeManager, nodevm, req.VolumeId,
 				false)
 			if err != nil {
+				if isMultiAttachFault(req.VolumeCapability, faultType) &&
+					commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.MultiAttachDiagnostics) {
+					return nil, faultType, c.diagnoseMultiAttach(ctx, req, err)
+				}
 				return nil, faultType, logger.LogNewErrorCodef(log, codes.Internal,
 					"failed to attach disk: %+q with node: %q err %+v", req.VolumeId, req.NodeId, err)
 			}
@@ -2311,6 +2899,9 @@ func (c *controller) ControllerPublishVolume(ctx context.Context, req *csi.Contr
 		if csifault.IsNonStorageFault(faultType) {
 			faultType = csifault.AddCsiNonStoragePrefix(ctx, faultType)
 		}
+		if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.StructuredCSIErrors) {
+			err = csifault.WithFaultType(err, faultType)
+		}
 		log.Errorf("Operation failed, reporting failure status to Prometheus."+
 			" Operation Type: %q, Volume Type: %q, Fault Type: %q",
 			prometheus.PrometheusAttachVolumeOpType, volumeType, faultType)
@@ -2320,6 +2911,9 @@ func (c *controller) ControllerPublishVolume(ctx context.Context, req *csi.Contr
 		log.Infof("Volume %q attached successfully to node %q.", req.VolumeId, req.NodeId)
 		prometheus.CsiControlOpsHistVec.WithLabelValues(volumeType, prometheus.PrometheusAttachVolumeOpType,
 			prometheus.PrometheusPassStatus, faultType).Observe(time.Since(start).Seconds())
+		prometheus.AttachDetachLatencyHistVec.WithLabelValues(prometheus.PrometheusAttachVolumeOpType,
+			vcenterHost, prometheus.PrometheusCSIRequestStage).Observe(time.Since(start).Seconds())
+		common.SetVolumeVCenter(req.VolumeId, vcenterHost)
 	}
 	return resp, err
 }
@@ -2332,6 +2926,7 @@ func (c *controller) ControllerUnpublishVolume(ctx context.Context, req *csi.Con
 	ctx = logger.NewContextWithLogger(ctx)
 	log := logger.GetLogger(ctx)
 	volumeType := prometheus.PrometheusUnknownVolumeType
+	var vcenterHost string
 
 	controllerUnpublishVolumeInternal := func() (
 		*csi.ControllerUnpublishVolumeResponse, string, error) {
@@ -2349,36 +2944,27 @@ func (c *controller) ControllerUnpublishVolume(ctx context.Context, req *csi.Con
 				"validation for UnpublishVolume Request: %+v has failed. Error: %v", *req, err)
 		}
 
-		_, volumeManager, err := getVCenterAndVolumeManagerForVolumeID(ctx, c, req.VolumeId, volumeInfoService)
+		var volumeManager cnsvolume.Manager
+		vcenterHost, volumeManager, err = getVCenterAndVolumeManagerForVolumeID(ctx, c, req.VolumeId,
+			volumeInfoService)
 		if err != nil {
 			return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
 				"failed to get volume manager for volume Id: %q. Error: %v", req.VolumeId, err)
 		}
 		if !strings.Contains(req.VolumeId, ".vmdk") {
 			// Check if volume is block or file, skip detach for file volume.
-			queryFilter := cnstypes.CnsQueryFilter{
-				VolumeIds: []cnstypes.CnsVolumeId{{Id: req.VolumeId}},
-			}
-			querySelection := cnstypes.CnsQuerySelection{
-				Names: []string{
-					string(cnstypes.QuerySelectionNameTypeVolumeType),
-				},
-			}
-			// Select only the volume type.
-			queryResult, err := volumeManager.QueryAllVolume(ctx, queryFilter, querySelection)
-			// TODO: QueryAllVolumeUtil need return faultType
-			//	and we should return the faultType.
-			// Currently, just return "csi.fault.Internal"
+			cnsVolumeType, err := common.GetCnsVolumeType(ctx, volumeManager, req.VolumeId)
 			if err != nil {
-				return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
-					"queryVolume failed for volumeID: %q with err=%+v", req.VolumeId, err)
-			}
-
-			if len(queryResult.Volumes) == 0 {
-				return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
-					"volumeID %q not found in QueryVolume", req.VolumeId)
+				if err.Error() == common.ErrNotFound.Error() {
+					return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+						"volumeID %q not found in QueryVolume", req.VolumeId)
+				}
+				// TODO: QueryAllVolumeUtil need return faultType
+				//	and we should return the faultType.
+				// Currently, just return "csi.fault.Internal"
+				return nil, csifault.CSIInternalFault, err
 			}
-			if queryResult.Volumes[0].VolumeType == common.FileVolumeType {
+			if cnsVolumeType == common.FileVolumeType {
 				volumeType = prometheus.PrometheusFileVolumeType
 				log.Infof("Skipping ControllerUnpublish for file volume %q", req.VolumeId)
 				return &csi.ControllerUnpublishVolumeResponse{}, "", nil
@@ -2448,6 +3034,9 @@ func (c *controller) ControllerUnpublishVolume(ctx context.Context, req *csi.Con
 		if csifault.IsNonStorageFault(faultType) {
 			faultType = csifault.AddCsiNonStoragePrefix(ctx, faultType)
 		}
+		if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.StructuredCSIErrors) {
+			err = csifault.WithFaultType(err, faultType)
+		}
 		log.Errorf("Operation failed, reporting failure status to Prometheus."+
 			" Operation Type: %q, Volume Type: %q, Fault Type: %q",
 			prometheus.PrometheusDetachVolumeOpType, volumeType, faultType)
@@ -2457,6 +3046,9 @@ func (c *controller) ControllerUnpublishVolume(ctx context.Context, req *csi.Con
 		log.Infof("Volume %q detached successfully from node %q.", req.VolumeId, req.NodeId)
 		prometheus.CsiControlOpsHistVec.WithLabelValues(volumeType, prometheus.PrometheusDetachVolumeOpType,
 			prometheus.PrometheusPassStatus, faultType).Observe(time.Since(start).Seconds())
+		prometheus.AttachDetachLatencyHistVec.WithLabelValues(prometheus.PrometheusDetachVolumeOpType,
+			vcenterHost, prometheus.PrometheusCSIRequestStage).Observe(time.Since(start).Seconds())
+		common.SetVolumeVCenter(req.VolumeId, vcenterHost)
 	}
 	return resp, err
 }
@@ -2480,6 +3072,9 @@ func (c *controller) ControllerExpandVolume(ctx context.Context, req *csi.Contro
 		)
 
 		log.Infof("ControllerExpandVolume: called with args %+v", *req)
+		if err := c.readOnly.check(ctx, "ControllerExpandVolume"); err != nil {
+			return nil, csifault.CSIReadOnlyModeFault, err
+		}
 		// TODO: If the err is returned by invoking CNS API, then faultType should be
 		// populated by the underlying layer.
 		// If the request failed due to validate the request, "csi.fault.InvalidArgument" will be return.
@@ -2583,6 +3178,9 @@ func (c *controller) ControllerExpandVolume(ctx context.Context, req *csi.Contro
 		if csifault.IsNonStorageFault(faultType) {
 			faultType = csifault.AddCsiNonStoragePrefix(ctx, faultType)
 		}
+		if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.StructuredCSIErrors) {
+			err = csifault.WithFaultType(err, faultType)
+		}
 		log.Errorf("Operation failed, reporting failure status to Prometheus."+
 			" Operation Type: %q, Volume Type: %q, Fault Type: %q",
 			prometheus.PrometheusExpandVolumeOpType, volumeType, faultType)
@@ -2621,13 +3219,13 @@ func (c *controller) ListVolumes(ctx context.Context, req *csi.ListVolumesReques
 	if !commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.ListVolumes) {
 		return nil, logger.LogNewErrorCode(log, codes.Unimplemented, "List Volumes")
 	}
-	cfg, err := cnsconfig.GetConfig(ctx)
+	cfg, err := cnsconfig.GetConfigSnapshot(ctx)
 	if err != nil {
 		return nil, logger.LogNewErrorf(log, "failed to read config. Error: %+v", err)
 	}
 
 	// Get the global query limit
-	maxEntries := cfg.Global.QueryLimit
+	maxEntries := csidriverconfig.QueryLimit(cfg.Global.QueryLimit)
 	if req.MaxEntries != 0 {
 		maxEntries = int(req.MaxEntries)
 	}
@@ -2702,10 +3300,11 @@ func (c *controller) ListVolumes(ctx context.Context, req *csi.ListVolumesReques
 
 		// Step 3: If the difference between number of K8s volumes and CNS volumes is greater than threshold,
 		// fail the operation, as it can result in too many attach calls.
-		if len(volIDsInK8s)-len(CNSVolumesforListVolume) > cfg.Global.ListVolumeThreshold {
+		listVolumeThreshold := csidriverconfig.ListVolumeThreshold(cfg.Global.ListVolumeThreshold)
+		if len(volIDsInK8s)-len(CNSVolumesforListVolume) > listVolumeThreshold {
 			log.Errorf("difference between number of K8s volumes: %d, and CNS volumes: %d, is greater than "+
 				"threshold: %d, and completely out of sync.", len(volIDsInK8s), len(CNSVolumesforListVolume),
-				cfg.Global.ListVolumeThreshold)
+				listVolumeThreshold)
 			return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.FailedPrecondition,
 				"difference between number of K8s volumes and CNS volumes is greater than threshold.")
 		}
@@ -2763,6 +3362,7 @@ func (c *controller) processQueryResultsListVolumes(ctx context.Context, startin
 	var entries []*csi.ListVolumesResponse_Entry
 
 	for i := startingToken; i < len(cnsVolumes); i++ {
+		common.SetVolumeType(cnsVolumes[i].VolumeId.Id, cnsVolumes[i].VolumeType)
 		if cnsVolumes[i].VolumeType == common.FileVolumeType {
 			// If this is multi-VC configuration, then
 			// skip processing query results for file volumes
@@ -2924,6 +3524,9 @@ func (c *controller) ControllerGetCapabilities(ctx context.Context, req *csi.Con
 		controllerCaps = append(controllerCaps, csi.ControllerServiceCapability_RPC_LIST_VOLUMES,
 			csi.ControllerServiceCapability_RPC_LIST_VOLUMES_PUBLISHED_NODES)
 	}
+	if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.ReadWriteOncePod) {
+		controllerCaps = append(controllerCaps, csi.ControllerServiceCapability_RPC_SINGLE_NODE_MULTI_WRITER)
+	}
 	var caps []*csi.ControllerServiceCapability
 	for _, cap := range controllerCaps {
 		c := &csi.ControllerServiceCapability{
@@ -2957,6 +3560,9 @@ func (c *controller) CreateSnapshot(ctx context.Context, req *csi.CreateSnapshot
 	if !isBlockVolumeSnapshotEnabled {
 		return nil, logger.LogNewErrorCode(log, codes.Unimplemented, "createSnapshot")
 	}
+	if err := c.readOnly.check(ctx, "CreateSnapshot"); err != nil {
+		return nil, err
+	}
 
 	volumeID := req.GetSourceVolumeId()
 	// Fetch vCenterHost, vCenterManager & volumeManager for given snapshot, based on VC configuration
@@ -3007,20 +3613,26 @@ func (c *controller) CreateSnapshot(ctx context.Context, req *csi.CreateSnapshot
 				"queried volume doesn't have the expected volume type. Expected VolumeType: %v. "+
 					"Queried VolumeType: %v", volumeType, cnsVolumeDetailsMap[volumeID].VolumeType)
 		}
+		if c.policyEngine != nil {
+			if err := c.reviewCreateSnapshotRequest(ctx, req, snapshotSizeInMB); err != nil {
+				return nil, err
+			}
+		}
 		// Check if snapshots number of this volume reaches the granular limit on VSAN/VVOL
+		var snapshotConfig cnsconfig.SnapshotConfig
 		if multivCenterCSITopologyEnabled {
-			maxSnapshotsPerBlockVolume = c.managers.CnsConfig.Snapshot.GlobalMaxSnapshotsPerBlockVolume
-			granularMaxSnapshotsPerBlockVolumeInVSAN =
-				c.managers.CnsConfig.Snapshot.GranularMaxSnapshotsPerBlockVolumeInVSAN
-			granularMaxSnapshotsPerBlockVolumeInVVOL =
-				c.managers.CnsConfig.Snapshot.GranularMaxSnapshotsPerBlockVolumeInVVOL
+			snapshotConfig = c.managers.CnsConfig.Snapshot
 		} else {
-			maxSnapshotsPerBlockVolume = c.manager.CnsConfig.Snapshot.GlobalMaxSnapshotsPerBlockVolume
-			granularMaxSnapshotsPerBlockVolumeInVSAN =
-				c.manager.CnsConfig.Snapshot.GranularMaxSnapshotsPerBlockVolumeInVSAN
-			granularMaxSnapshotsPerBlockVolumeInVVOL =
-				c.manager.CnsConfig.Snapshot.GranularMaxSnapshotsPerBlockVolumeInVVOL
-		}
+			snapshotConfig = c.manager.CnsConfig.Snapshot
+		}
+		// The limits set in the CSIDriverConfig take precedence over the
+		// vSphere config secret.
+		maxSnapshotsPerBlockVolume = csidriverconfig.GlobalMaxSnapshotsPerBlockVolume(
+			snapshotConfig.GlobalMaxSnapshotsPerBlockVolume)
+		granularMaxSnapshotsPerBlockVolumeInVSAN = csidriverconfig.GranularMaxSnapshotsPerBlockVolumeInVSAN(
+			snapshotConfig.GranularMaxSnapshotsPerBlockVolumeInVSAN)
+		granularMaxSnapshotsPerBlockVolumeInVVOL = csidriverconfig.GranularMaxSnapshotsPerBlockVolumeInVVOL(
+			snapshotConfig.GranularMaxSnapshotsPerBlockVolumeInVVOL)
 		log.Infof("The limit of the maximum number of snapshots per block volume is "+
 			"set to the global maximum (%v) by default.", maxSnapshotsPerBlockVolume)
 
@@ -3058,6 +3670,18 @@ func (c *controller) CreateSnapshot(ctx context.Context, req *csi.CreateSnapshot
 				volumeID, maxSnapshotsPerBlockVolume)
 		}
 
+		hooks, err := getSnapshotHooks(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		if hooks != nil {
+			if err := hooks.RunPre(ctx); err != nil {
+				return nil, logger.LogNewErrorCodef(log, codes.FailedPrecondition,
+					"failed to run pre-snapshot hooks for volume %q. Error: %v", volumeID, err)
+			}
+			defer hooks.RunPost(ctx)
+		}
+
 		// the returned snapshotID below is a combination of CNS VolumeID and CNS SnapshotID concatenated by the "+"
 		// sign. That is, a string of "<UUID>+<UUID>". Because, all other CNS snapshot APIs still require both
 		// VolumeID and SnapshotID as the input, while corresponding snapshot APIs in upstream CSI require SnapshotID.
@@ -3067,7 +3691,18 @@ func (c *controller) CreateSnapshot(ctx context.Context, req *csi.CreateSnapshot
 			return nil, logger.LogNewErrorCodef(log, codes.Internal,
 				"failed to create snapshot on volume %q: %v", volumeID, err)
 		}
+		if isMultiVCenterDeployment(c) {
+			// Scope the snapshot ID to the vCenter of the volume, so that the snapshot can be
+			// found without looking up the vCenter of its volume.
+			snapshotID = common.ScopeCSISnapshotID(snapshotID, vCenterHost)
+		}
 		snapshotCreateTimeInProto := timestamppb.New(*snapshotCreateTimePtr)
+		if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.SnapshotOwnershipRestore) {
+			recordSnapshotOwnership(ctx, req)
+		}
+		if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.RecentSnapshotProtection) {
+			recordLastSnapshotTime(ctx, volumeManager, volumeID, *snapshotCreateTimePtr)
+		}
 
 		createSnapshotResponse := &csi.CreateSnapshotResponse{
 			Snapshot: &csi.Snapshot{
@@ -3119,14 +3754,18 @@ func (c *controller) DeleteSnapshot(ctx context.Context, req *csi.DeleteSnapshot
 	if !isBlockVolumeSnapshotEnabled {
 		return nil, logger.LogNewErrorCode(log, codes.Unimplemented, "deleteSnapshot")
 	}
+	if err := c.readOnly.check(ctx, "DeleteSnapshot"); err != nil {
+		return nil, err
+	}
 
-	volumeID, _, err := common.ParseCSISnapshotID(req.SnapshotId)
+	_, _, err = common.ParseCSISnapshotID(req.SnapshotId)
 	if err != nil {
 		return nil, logger.LogNewErrorCode(log, codes.InvalidArgument, err.Error())
 	}
 	// Fetch vCenterHost, vCenterManager & volumeManager for given snapshot, based on VC configuration
 	vCenterManager = getVCenterManagerForVCenter(ctx, c)
-	vCenterHost, volumeManager, err = getVCenterAndVolumeManagerForVolumeID(ctx, c, volumeID, volumeInfoService)
+	vCenterHost, volumeManager, err = getVCenterAndVolumeManagerForSnapshotID(ctx, c, req.SnapshotId,
+		volumeInfoService)
 	if err != nil {
 		return nil, logger.LogNewErrorCodef(log, codes.Internal,
 			"failed to get vCenter/volume manager for snapshot Id: %q. Error: %v", req.SnapshotId, err)
@@ -3151,6 +3790,7 @@ func (c *controller) DeleteSnapshot(ctx context.Context, req *csi.DeleteSnapshot
 				csiSnapshotID, err)
 		}
 
+		commonco.ContainerOrchestratorUtility.ForgetSnapshotVCenter(ctx, csiSnapshotID)
 		log.Infof("DeleteSnapshot: successfully deleted snapshot %q", csiSnapshotID)
 		return &csi.DeleteSnapshotResponse{}, nil
 	}
@@ -3207,9 +3847,10 @@ func (c *controller) ListSnapshots(ctx context.Context, req *csi.ListSnapshotsRe
 				log.Errorf("Unable to determine the volume-id and snapshot-id")
 				return nil, err
 			}
-			// Fetch vCenterHost & volumeManager for source volume, based on VC configuration
+			// Fetch vCenterHost & volumeManager for the snapshot, based on VC configuration
 			vCenterManager = getVCenterManagerForVCenter(ctx, c)
-			vCenterHost, volManager, err = getVCenterAndVolumeManagerForVolumeID(ctx, c, volID, volumeInfoService)
+			vCenterHost, volManager, err = getVCenterAndVolumeManagerForSnapshotID(ctx, c, req.SnapshotId,
+				volumeInfoService)
 			if err != nil {
 				return nil, logger.LogNewErrorCodef(log, codes.Internal,
 					"failed to get vCenter/volume manager for volume Id: %q in VC %s. Error: %v", volID, vCenterHost, err)
@@ -3229,6 +3870,11 @@ func (c *controller) ListSnapshots(ctx context.Context, req *csi.ListSnapshotsRe
 			if err != nil {
 				return nil, logger.LogNewErrorCodef(log, codes.Internal, " failed to retrieve the snapshots, err: %+v", err)
 			}
+			if isMultiVCenterDeployment(c) {
+				for _, snapshot := range snapshots {
+					snapshot.SnapshotId = common.ScopeCSISnapshotID(snapshot.SnapshotId, vCenterHost)
+				}
+			}
 		} else if req.SourceVolumeId != "" {
 			// Fetch vCenterHost & volumeManager for source volume, based on VC configuration
 			vCenterManager = getVCenterManagerForVCenter(ctx, c)
@@ -3252,6 +3898,11 @@ func (c *controller) ListSnapshots(ctx context.Context, req *csi.ListSnapshotsRe
 			if err != nil {
 				return nil, logger.LogNewErrorCodef(log, codes.Internal, " failed to retrieve the snapshots, err: %+v", err)
 			}
+			if isMultiVCenterDeployment(c) {
+				for _, snapshot := range snapshots {
+					snapshot.SnapshotId = common.ScopeCSISnapshotID(snapshot.SnapshotId, vCenterHost)
+				}
+			}
 		} else {
 			snapshots, nextToken, err = queryAllVolumeSnapshotsForMultiVC(ctx, c, req.StartingToken, maxEntries)
 			if err != nil {
@@ -18,25 +18,56 @@ package vanilla
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v6/apis/volumesnapshot/v1"
+	snapshotterClientSet "github.com/kubernetes-csi/external-snapshotter/client/v6/clientset/versioned"
+	cnstypes "github.com/vmware/govmomi/cns/types"
 	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/property"
 	"github.com/vmware/govmomi/vim25/mo"
 	"github.com/vmware/govmomi/vim25/types"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
 
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/node"
 	cnsvolume "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	cnsconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+	csifault "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/fault"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/policyengine"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/snapshothooks"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsvolumeinfo"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsvolumeoperationrequest"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/csidriverconfig"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
+)
+
+var (
+	// eventRecorder records events on PVs and PVCs for volume operations.
+	eventRecorder record.EventRecorder
+	// eventRecorderOnce initializes eventRecorder.
+	eventRecorderOnce sync.Once
 )
 
 // validateVanillaDeleteVolumeRequest is the helper function to validate
@@ -107,6 +138,57 @@ func validateVanillaCreateSnapshotRequestRequest(ctx context.Context, req *csi.C
 	return nil
 }
 
+// getSnapshotHooks returns the snapshot hooks of the pods using the volume
+// to snapshot when the VolumeSnapshotClass sets the AttributeSnapshotHooks
+// parameter, or nil if there are no hooks to run.
+func getSnapshotHooks(ctx context.Context, req *csi.CreateSnapshotRequest) (*snapshothooks.Hooks, error) {
+	log := logger.GetLogger(ctx)
+	hookType, ok := req.Parameters[common.AttributeSnapshotHooks]
+	if !ok {
+		return nil, nil
+	}
+	if !commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.SnapshotHooks) {
+		return nil, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"volume snapshot class parameter %q is not supported as %q feature is disabled",
+			common.AttributeSnapshotHooks, common.SnapshotHooks)
+	}
+	if hookType != snapshothooks.HookTypeExec {
+		return nil, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"volume snapshot class parameter %q must be set to %q", common.AttributeSnapshotHooks,
+			snapshothooks.HookTypeExec)
+	}
+	volumeID := req.GetSourceVolumeId()
+	pvName, found := commonco.ContainerOrchestratorUtility.GetPVNameFromCSIVolumeID(volumeID)
+	if !found {
+		log.Infof("PV of volume %q not found, not running snapshot hooks", volumeID)
+		return nil, nil
+	}
+	k8sClient, err := k8s.NewClient(ctx)
+	if err != nil {
+		return nil, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to create kubernetes client. Error: %v", err)
+	}
+	restConfig, err := k8s.GetKubeConfig(ctx)
+	if err != nil {
+		return nil, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to get kubeconfig. Error: %v", err)
+	}
+	pv, err := k8sClient.CoreV1().PersistentVolumes().Get(ctx, pvName, metav1.GetOptions{})
+	if err != nil {
+		return nil, logger.LogNewErrorCodef(log, codes.Internal, "failed to get PV %q. Error: %v", pvName, err)
+	}
+	if pv.Spec.ClaimRef == nil {
+		return nil, nil
+	}
+	hooks, err := snapshothooks.New(ctx, k8sClient, snapshothooks.NewPodExecutor(k8sClient, restConfig),
+		pv.Spec.ClaimRef.Namespace, pv.Spec.ClaimRef.Name)
+	if err != nil {
+		return nil, logger.LogNewErrorCodef(log, codes.FailedPrecondition,
+			"failed to get the snapshot hooks of volume %q. Error: %v", volumeID, err)
+	}
+	return hooks, nil
+}
+
 func validateVanillaListSnapshotRequest(ctx context.Context, req *csi.ListSnapshotsRequest) error {
 	log := logger.GetLogger(ctx)
 	maxEntries := req.MaxEntries
@@ -269,6 +351,50 @@ func getVCenterAndVolumeManagerForVolumeID(ctx context.Context, controller *cont
 	return vCenter, volumeManager, nil
 }
 
+// isTopologyConfigured returns true if topology categories are set in the
+// CSIDriverConfig, or topology domains in the vSphere config secret.
+func isTopologyConfigured(cfg *cnsconfig.Config) bool {
+	return len(csidriverconfig.TopologyCategories()) != 0 || cfg.Labels.TopologyCategories != "" ||
+		cfg.Labels.Zone != "" || cfg.Labels.Region != ""
+}
+
+// isMultiVCenterDeployment returns true if the driver manages volumes on more
+// than one vCenter.
+func isMultiVCenterDeployment(controller *controller) bool {
+	return multivCenterCSITopologyEnabled && len(controller.managers.VcenterConfigs) > 1
+}
+
+// getVCenterAndVolumeManagerForSnapshotID returns the vCenter and volume
+// manager of the snapshot with the given CSI snapshot ID. In multi vCenter
+// deployments, the vCenter the snapshot ID is scoped to or the cached vCenter
+// of the snapshot is used, before looking up the vCenter of its volume.
+func getVCenterAndVolumeManagerForSnapshotID(ctx context.Context, controller *controller, csiSnapshotID string,
+	volumeInfoService cnsvolumeinfo.VolumeInfoService) (string, cnsvolume.Manager, error) {
+	log := logger.GetLogger(ctx)
+	volumeID, _, err := common.ParseCSISnapshotID(csiSnapshotID)
+	if err != nil {
+		return "", nil, logger.LogNewErrorCode(log, codes.InvalidArgument, err.Error())
+	}
+	if !isMultiVCenterDeployment(controller) {
+		return getVCenterAndVolumeManagerForVolumeID(ctx, controller, volumeID, volumeInfoService)
+	}
+	if vCenter, found := commonco.ContainerOrchestratorUtility.GetSnapshotVCenter(ctx, csiSnapshotID); found {
+		volumeManager, volumeManagerfound := controller.managers.VolumeManagers[vCenter]
+		if !volumeManagerfound {
+			return vCenter, nil, logger.LogNewErrorCodef(log, codes.Internal,
+				"could not get volume manager for the vCenter %q of snapshot %q", vCenter, csiSnapshotID)
+		}
+		return vCenter, volumeManager, nil
+	}
+	vCenter, volumeManager, err := getVCenterAndVolumeManagerForVolumeID(ctx, controller, volumeID,
+		volumeInfoService)
+	if err != nil {
+		return vCenter, nil, err
+	}
+	commonco.ContainerOrchestratorUtility.SetSnapshotVCenter(ctx, csiSnapshotID, vCenter)
+	return vCenter, volumeManager, nil
+}
+
 // getVCenterManagerForVCenter returns vCenter manager for the given volumeId.
 // If multi-vcenter-csi-topology feature is disabled, legacy vCenter manager is returned
 // from `controller.manager.VCenterManager`.
@@ -295,3 +421,1157 @@ func GetVolumeManagerFromVCHost(ctx context.Context, managers *common.Managers,
 	}
 	return volumeMgr, nil
 }
+
+// markVolumeForArchival records the archive reclaim action on the backing
+// disk of a new volume. DeleteVolume doesn't receive the storage class
+// parameters, so it reads this metadata to decide whether to archive the disk.
+// The filesystem type requested for the volume, if any, is recorded as well
+// for the volume to be restored with it.
+func markVolumeForArchival(ctx context.Context, volumeManager cnsvolume.Manager, volumeID string,
+	volCaps []*csi.VolumeCapability) error {
+	metadata := map[string]string{common.VStorageObjectMetadataReclaimAction: common.ReclaimActionArchive}
+	for _, volCap := range volCaps {
+		if fsType := strings.ToLower(volCap.GetMount().GetFsType()); fsType != "" {
+			metadata[common.VStorageObjectMetadataFsType] = fsType
+			break
+		}
+	}
+	return volumeManager.UpdateVStorageObjectMetadata(ctx, volumeID, metadata, nil)
+}
+
+// isVolumeMarkedForArchival returns true if the backing disk of the volume
+// was created with the archive reclaim action.
+func isVolumeMarkedForArchival(ctx context.Context, volumeManager cnsvolume.Manager, volumeID string) (bool, error) {
+	reclaimAction, err := volumeManager.RetrieveVStorageObjectMetadataValue(ctx, volumeID,
+		common.VStorageObjectMetadataReclaimAction)
+	if err != nil {
+		return false, err
+	}
+	return reclaimAction == common.ReclaimActionArchive, nil
+}
+
+// archiveVolume removes the volume from CNS while keeping its backing disk.
+// The disk is first relocated to the archive datastore, if one is configured,
+// and stamped with the archival time used by the syncer to purge it once the
+// retention period is over, along with the storage policy and the PVC labels
+// to reapply if the volume is restored.
+func archiveVolume(ctx context.Context, c *controller, vCenterHost string, volumeManager cnsvolume.Manager,
+	volumeID string) (string, error) {
+	log := logger.GetLogger(ctx)
+	archiveDatastoreURL := c.managers.CnsConfig.Archive.DatastoreURL
+	if archiveDatastoreURL != "" {
+		if err := relocateVolumeToDatastore(ctx, c, vCenterHost, volumeManager, volumeID,
+			archiveDatastoreURL); err != nil {
+			return csifault.CSIInternalFault, logger.LogNewErrorf(log,
+				"failed to relocate volume %q to archive datastore %q. Error: %+v", volumeID, archiveDatastoreURL, err)
+		}
+	}
+	// The PVC metadata is only queried without a selection.
+	volume, err := common.QueryVolumeByID(ctx, volumeManager, volumeID, nil)
+	if err != nil {
+		return csifault.CSIInternalFault, logger.LogNewErrorf(log,
+			"failed to query volume %q. Error: %+v", volumeID, err)
+	}
+	metadata := map[string]string{
+		common.VStorageObjectMetadataArchivedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	if volume.StoragePolicyId != "" {
+		metadata[common.VStorageObjectMetadataStoragePolicyID] = volume.StoragePolicyId
+	}
+	// Drop the restorer recorded by an earlier restore of the volume, so that
+	// the syncer purges it.
+	deleteKeys := []string{common.VStorageObjectMetadataRestoredBy}
+	pvcLabels, err := getPVCLabelsMetadata(volume)
+	if err != nil {
+		return csifault.CSIInternalFault, logger.LogNewErrorf(log,
+			"failed to encode PVC labels of volume %q. Error: %+v", volumeID, err)
+	}
+	if pvcLabels != "" {
+		metadata[common.VStorageObjectMetadataPVCLabels] = pvcLabels
+	} else {
+		// Drop the labels recorded by an earlier archival of the volume.
+		deleteKeys = append(deleteKeys, common.VStorageObjectMetadataPVCLabels)
+	}
+	err = volumeManager.UpdateVStorageObjectMetadata(ctx, volumeID, metadata, deleteKeys)
+	if err != nil {
+		return csifault.CSIInternalFault, logger.LogNewErrorf(log,
+			"failed to set archival time on volume %q. Error: %+v", volumeID, err)
+	}
+	faultType, err := common.DeleteVolumeUtil(ctx, volumeManager, volumeID, false)
+	if err != nil {
+		return faultType, err
+	}
+	log.Infof("Volume %q archived, its disk will be deleted after %d hours", volumeID,
+		c.managers.CnsConfig.Archive.RetentionInHours)
+	return "", nil
+}
+
+// getPVCLabelsMetadata returns the JSON encoded labels of the PVC of the
+// volume, as pushed to CNS by the syncer, or "" if the PVC has no labels.
+func getPVCLabelsMetadata(volume *cnstypes.CnsVolume) (string, error) {
+	for _, entityMetadata := range volume.Metadata.EntityMetadata {
+		k8sMetadata, ok := entityMetadata.(*cnstypes.CnsKubernetesEntityMetadata)
+		if !ok || k8sMetadata.EntityType != string(cnstypes.CnsKubernetesEntityTypePVC) ||
+			len(k8sMetadata.Labels) == 0 {
+			continue
+		}
+		labels := make(map[string]string, len(k8sMetadata.Labels))
+		for _, label := range k8sMetadata.Labels {
+			labels[label.Key] = label.Value
+		}
+		encoded, err := json.Marshal(labels)
+		if err != nil {
+			return "", err
+		}
+		return string(encoded), nil
+	}
+	return "", nil
+}
+
+// relocateVolumeToDatastore moves the backing disk of a block volume to the
+// datastore with the given URL.
+func relocateVolumeToDatastore(ctx context.Context, c *controller, vCenterHost string,
+	volumeManager cnsvolume.Manager, volumeID string, datastoreURL string) error {
+	log := logger.GetLogger(ctx)
+	vc, err := common.GetVCenterFromVCHost(ctx, getVCenterManagerForVCenter(ctx, c), vCenterHost)
+	if err != nil {
+		return err
+	}
+	dcList, err := vc.GetDatacenters(ctx)
+	if err != nil {
+		return err
+	}
+	var dsInfo *vsphere.DatastoreInfo
+	for _, dc := range dcList {
+		dsInfo, err = dc.GetDatastoreInfoByURL(ctx, datastoreURL)
+		if err == nil {
+			break
+		}
+	}
+	if dsInfo == nil {
+		return fmt.Errorf("datastore %q not found in vCenter %q", datastoreURL, vCenterHost)
+	}
+	if err := relocateVolume(ctx, volumeManager, volumeID, dsInfo.Reference()); err != nil {
+		return err
+	}
+	log.Infof("Relocated volume %q to datastore %q", volumeID, datastoreURL)
+	return nil
+}
+
+// relocateVolume moves the backing disk of a block volume to the given
+// datastore, applying the given storage policy profiles to it if any.
+func relocateVolume(ctx context.Context, volumeManager cnsvolume.Manager, volumeID string,
+	datastore types.ManagedObjectReference, profile ...types.BaseVirtualMachineProfileSpec) error {
+	task, err := volumeManager.RelocateVolume(ctx, cnstypes.NewCnsBlockVolumeRelocateSpec(volumeID,
+		datastore, profile...))
+	if err != nil {
+		return err
+	}
+	taskInfo, err := task.WaitForResultEx(ctx)
+	if err != nil {
+		return err
+	}
+	results := taskInfo.Result.(cnstypes.CnsVolumeOperationBatchResult)
+	for _, result := range results.VolumeResults {
+		fault := result.GetCnsVolumeOperationResult().Fault
+		if fault != nil {
+			return fmt.Errorf("fault %q encountered while relocating volume %q", fault.LocalizedMessage, volumeID)
+		}
+	}
+	return nil
+}
+
+// snapshotRestoreConversion describes how a volume restored from a snapshot is
+// converted to the storage policy, the datastore and the provisioning type of
+// a StorageClass other than the one of the snapshot's source volume. CNS restores a snapshot only
+// on the datastore of the snapshot, so the volume is restored there with the
+// storage policy of the source volume before being converted.
+type snapshotRestoreConversion struct {
+	// sourceStoragePolicyID is the storage policy of the snapshot's source
+	// volume.
+	sourceStoragePolicyID string
+	// sourceDatastore is the datastore of the snapshot.
+	sourceDatastore *vsphere.DatastoreInfo
+	// targetStoragePolicyID is the storage policy of the StorageClass.
+	targetStoragePolicyID string
+	// targetDatastore is the datastore the restored volume is relocated to,
+	// nil if it stays on the datastore of the snapshot.
+	targetDatastore *vsphere.DatastoreInfo
+	// targetProvisioningType is the provisioning type the disk of the restored
+	// volume is converted to, empty if it keeps the one of the source volume.
+	targetProvisioningType string
+}
+
+// planSnapshotRestoreConversion returns how the volume restored from the
+// snapshot of the given source volume is converted to the StorageClass of
+// scParams, or nil if the volume can be restored as is. The conversion is
+// validated up front: an InvalidArgument error is returned if no datastore
+// in sharedDatastores is compatible with the storage policy of scParams.
+func planSnapshotRestoreConversion(ctx context.Context, vc *vsphere.VirtualCenter,
+	volumeManager cnsvolume.Manager, sourceVolumeID string, snapshotDatastoreURL string,
+	scParams *common.StorageClassParams, sharedDatastores []*vsphere.DatastoreInfo) (
+	*snapshotRestoreConversion, error) {
+	log := logger.GetLogger(ctx)
+	if !commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.CrossClassSnapshotRestore) {
+		return nil, nil
+	}
+	conversion := &snapshotRestoreConversion{}
+	var err error
+	if scParams.StoragePolicyName != "" {
+		conversion.targetStoragePolicyID, err = vc.GetStoragePolicyIDByName(ctx, scParams.StoragePolicyName)
+		if err != nil {
+			return nil, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+				"failed to get storage policy ID for %q. Err: %v", scParams.StoragePolicyName, err)
+		}
+	}
+	querySelection := &cnstypes.CnsQuerySelection{
+		Names: []string{string(cnstypes.QuerySelectionNameTypePolicyId)},
+	}
+	sourceVolume, err := common.QueryVolumeByID(ctx, volumeManager, sourceVolumeID, querySelection)
+	if err != nil {
+		return nil, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to query the storage policy of volume %q. Error: %+v", sourceVolumeID, err)
+	}
+	conversion.sourceStoragePolicyID = sourceVolume.StoragePolicyId
+
+	snapshotDatastoreURL = strings.TrimSpace(snapshotDatastoreURL)
+	isSnapshotDatastoreShared := false
+	for _, dsInfo := range sharedDatastores {
+		if strings.TrimSpace(dsInfo.Info.Url) == snapshotDatastoreURL {
+			conversion.sourceDatastore = dsInfo
+			isSnapshotDatastoreShared = true
+			break
+		}
+	}
+	if conversion.sourceDatastore == nil {
+		dcList, err := vc.GetDatacenters(ctx)
+		if err != nil {
+			return nil, logger.LogNewErrorCodef(log, codes.Internal,
+				"failed to get datacenter list. err: %+v", err)
+		}
+		for _, dc := range dcList {
+			if dsInfo, err := dc.GetDatastoreInfoByURL(ctx, snapshotDatastoreURL); err == nil {
+				conversion.sourceDatastore = dsInfo
+				break
+			}
+		}
+		if conversion.sourceDatastore == nil {
+			return nil, logger.LogNewErrorCodef(log, codes.Internal,
+				"datastore %q of the snapshot of volume %q not found", snapshotDatastoreURL, sourceVolumeID)
+		}
+	}
+
+	switch {
+	case scParams.DatastoreURL != "" && strings.TrimSpace(scParams.DatastoreURL) != snapshotDatastoreURL:
+		for _, dsInfo := range sharedDatastores {
+			if strings.TrimSpace(dsInfo.Info.Url) == strings.TrimSpace(scParams.DatastoreURL) {
+				conversion.targetDatastore = dsInfo
+				break
+			}
+		}
+		if conversion.targetDatastore == nil {
+			return nil, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+				"datastore %q given in storage class is not accessible to all nodes", scParams.DatastoreURL)
+		}
+	case isSnapshotDatastoreShared:
+		compatible, err := isStoragePolicyCompatible(ctx, vc, conversion.targetStoragePolicyID,
+			conversion.sourceDatastore)
+		if err != nil {
+			return nil, err
+		}
+		if !compatible && scParams.DatastoreURL == "" {
+			for _, dsInfo := range sharedDatastores {
+				if compatible, err = isStoragePolicyCompatible(ctx, vc, conversion.targetStoragePolicyID,
+					dsInfo); err != nil {
+					return nil, err
+				} else if compatible {
+					conversion.targetDatastore = dsInfo
+					break
+				}
+			}
+		}
+	default:
+		for _, dsInfo := range sharedDatastores {
+			compatible, err := isStoragePolicyCompatible(ctx, vc, conversion.targetStoragePolicyID, dsInfo)
+			if err != nil {
+				return nil, err
+			}
+			if compatible {
+				conversion.targetDatastore = dsInfo
+				break
+			}
+		}
+		if conversion.targetDatastore == nil {
+			return nil, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+				"none of the datastores accessible to all nodes is compatible with storage policy %q",
+				scParams.StoragePolicyName)
+		}
+	}
+
+	finalDatastore := conversion.sourceDatastore
+	if conversion.targetDatastore != nil {
+		finalDatastore = conversion.targetDatastore
+	}
+	compatible, err := isStoragePolicyCompatible(ctx, vc, conversion.targetStoragePolicyID, finalDatastore)
+	if err != nil {
+		return nil, err
+	}
+	if !compatible {
+		return nil, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"datastore %q is not compatible with storage policy %q of the storage class",
+			finalDatastore.Info.Url, scParams.StoragePolicyName)
+	}
+	if scParams.ProvisioningType != "" &&
+		commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.DiskFormatConversion) {
+		sourceProvisioningType, err := getVolumeProvisioningType(ctx, volumeManager, sourceVolumeID)
+		if err != nil {
+			return nil, err
+		}
+		if sourceProvisioningType != scParams.ProvisioningType {
+			conversion.targetProvisioningType = scParams.ProvisioningType
+		}
+	}
+	if conversion.targetDatastore == nil && conversion.targetProvisioningType == "" &&
+		(conversion.targetStoragePolicyID == "" ||
+			conversion.targetStoragePolicyID == conversion.sourceStoragePolicyID) {
+		return nil, nil
+	}
+	log.Infof("Volume restored from the snapshot of volume %q on datastore %q with storage policy %q "+
+		"will be converted to datastore %q, storage policy %q and provisioning type %q", sourceVolumeID,
+		snapshotDatastoreURL, conversion.sourceStoragePolicyID, finalDatastore.Info.Url,
+		conversion.targetStoragePolicyID, conversion.targetProvisioningType)
+	return conversion, nil
+}
+
+// getVolumeProvisioningType returns the provisioning type of the virtual disk
+// of the given block volume.
+func getVolumeProvisioningType(ctx context.Context, volumeManager cnsvolume.Manager,
+	volumeID string) (string, error) {
+	log := logger.GetLogger(ctx)
+	vStorageObject, err := volumeManager.RetrieveVStorageObject(ctx, volumeID)
+	if err != nil {
+		return "", logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to retrieve the virtual disk of volume %q. Error: %+v", volumeID, err)
+	}
+	backing, ok := vStorageObject.Config.Backing.(*types.BaseConfigInfoDiskFileBackingInfo)
+	if !ok {
+		return "", logger.LogNewErrorCodef(log, codes.Internal,
+			"virtual disk of volume %q is not backed by a disk file", volumeID)
+	}
+	return backing.ProvisioningType, nil
+}
+
+// isStoragePolicyCompatible returns true if the given datastore is compatible
+// with the given storage policy. Any datastore is compatible with an empty
+// storage policy.
+func isStoragePolicyCompatible(ctx context.Context, vc *vsphere.VirtualCenter, storagePolicyID string,
+	dsInfo *vsphere.DatastoreInfo) (bool, error) {
+	log := logger.GetLogger(ctx)
+	if storagePolicyID == "" {
+		return true, nil
+	}
+	compat, err := vc.PbmCheckCompatibility(ctx, []types.ManagedObjectReference{dsInfo.Reference()},
+		storagePolicyID)
+	if err != nil {
+		return false, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to find datastore compatibility with storage policy ID %q. Error: %+v", storagePolicyID, err)
+	}
+	for _, ds := range compat.CompatibleDatastores() {
+		if ds.HubId == dsInfo.Reference().Value {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// restoreVolume restores the snapshot of spec on the datastore of the snapshot
+// with the storage policy of its source volume, then converts the volume. The
+// volume is deleted if it can't be converted, so that CreateVolume restores it
+// again when retried.
+func (conversion *snapshotRestoreConversion) restoreVolume(ctx context.Context, manager *common.Manager,
+	operationStore cnsvolumeoperationrequest.VolumeOperationRequest, spec *common.CreateVolumeSpec,
+	filterSuspendedDatastores bool) (*cnsvolume.CnsVolumeInfo, string, error) {
+	log := logger.GetLogger(ctx)
+	restoreScParams := *spec.ScParams
+	restoreScParams.StoragePolicyName = ""
+	restoreScParams.DatastoreURL = ""
+	restoreSpec := *spec
+	restoreSpec.ScParams = &restoreScParams
+	restoreSpec.StoragePolicyID = conversion.sourceStoragePolicyID
+	volumeInfo, faultType, err := common.CreateBlockVolumeUtil(ctx, cnstypes.CnsClusterFlavorVanilla, manager,
+		&restoreSpec, []*vsphere.DatastoreInfo{conversion.sourceDatastore}, filterSuspendedDatastores, false, nil)
+	if err != nil {
+		return nil, faultType, err
+	}
+	volumeID := volumeInfo.VolumeID.Id
+
+	finalDatastore := conversion.sourceDatastore
+	if conversion.targetDatastore != nil {
+		finalDatastore = conversion.targetDatastore
+		var profile []types.BaseVirtualMachineProfileSpec
+		if conversion.targetStoragePolicyID != "" {
+			profile = append(profile, &types.VirtualMachineDefinedProfileSpec{
+				ProfileId: conversion.targetStoragePolicyID,
+			})
+		}
+		err = relocateVolume(ctx, manager.VolumeManager, volumeID, conversion.targetDatastore.Reference(),
+			profile...)
+		if err == nil {
+			log.Infof("Relocated restored volume %q to datastore %q", volumeID, conversion.targetDatastore.Info.Url)
+			volumeInfo.DatastoreURL = conversion.targetDatastore.Info.Url
+		}
+	} else if conversion.targetStoragePolicyID != "" &&
+		conversion.targetStoragePolicyID != conversion.sourceStoragePolicyID {
+		err = manager.VolumeManager.ReconfigVolumePolicy(ctx, volumeID, conversion.targetStoragePolicyID)
+		if err == nil {
+			log.Infof("Changed storage policy of restored volume %q to %q", volumeID,
+				conversion.targetStoragePolicyID)
+		}
+	}
+	if err == nil && conversion.targetProvisioningType != "" {
+		err = manager.VolumeManager.ConvertVStorageObjectProvisioningType(ctx, volumeID,
+			finalDatastore.Reference(), conversion.targetProvisioningType)
+		if err == nil {
+			log.Infof("Converted restored volume %q to provisioning type %q", volumeID,
+				conversion.targetProvisioningType)
+		}
+	}
+	if err == nil {
+		return volumeInfo, "", nil
+	}
+
+	errMsg := fmt.Sprintf("failed to convert volume %q restored from snapshot %q to the storage class. "+
+		"Error: %+v", volumeID, spec.ContentSourceSnapshotID, err)
+	if _, delErr := common.DeleteVolumeUtil(ctx, manager.VolumeManager, volumeID, true); delErr != nil {
+		log.Errorf("failed to delete volume %q which couldn't be converted. Error: %+v", volumeID, delErr)
+		return nil, csifault.CSIInternalFault, logger.LogNewErrorCode(log, codes.Internal, errMsg)
+	}
+	if operationStore != nil {
+		volumeOperationDetails := cnsvolumeoperationrequest.CreateVolumeOperationRequestDetails(spec.Name,
+			"", "", 0, nil, metav1.Now(), "", "", "", cnsvolumeoperationrequest.TaskInvocationStatusError, errMsg)
+		if err := operationStore.StoreRequestDetails(ctx, volumeOperationDetails); err != nil {
+			log.Warnf("failed to store CreateVolume details with error: %v", err)
+		}
+	}
+	return nil, csifault.CSIInternalFault, logger.LogNewErrorCode(log, codes.Internal, errMsg)
+}
+
+// handleVolumeSnapshotsOnDelete is called by DeleteVolume for a block volume
+// which still has CNS snapshots. When the snapshot-cascade-delete FSS is
+// enabled and the PV of the volume is annotated with
+// AnnCascadeDeleteSnapshots, the snapshots are deleted so that the volume can
+// be deleted. Otherwise a warning event listing the snapshots is recorded on
+// the PV and a FailedPrecondition error is returned.
+func handleVolumeSnapshotsOnDelete(ctx context.Context, c *controller, volumeManager cnsvolume.Manager,
+	volumeID string, snapshots []*csi.Snapshot) (string, error) {
+	log := logger.GetLogger(ctx)
+	snapshotIDs := make([]string, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		snapshotIDs = append(snapshotIDs, snapshot.SnapshotId)
+	}
+
+	var pv *v1.PersistentVolume
+	if c.k8sClient == nil {
+		log.Warnf("no kubernetes client to look up the PV of volume %q", volumeID)
+	} else if pvName, found := commonco.ContainerOrchestratorUtility.GetPVNameFromCSIVolumeID(volumeID); found {
+		var err error
+		pv, err = c.k8sClient.CoreV1().PersistentVolumes().Get(ctx, pvName, metav1.GetOptions{})
+		if err != nil {
+			log.Warnf("failed to get PV %q of volume %q. Error: %v", pvName, volumeID, err)
+			pv = nil
+		}
+	}
+
+	if pv != nil && pv.Annotations[common.AnnCascadeDeleteSnapshots] == "true" &&
+		commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.SnapshotCascadeDelete) {
+		log.Infof("PV %q is annotated with %q, deleting snapshots %v of volume %q", pv.Name,
+			common.AnnCascadeDeleteSnapshots, snapshotIDs, volumeID)
+		return cascadeDeleteSnapshots(ctx, c, volumeManager, pv, volumeID, snapshotIDs)
+	}
+
+	if pv != nil {
+		recordPVEvent(ctx, c.k8sClient, pv, v1.EventTypeWarning, "VolumeHasSnapshots",
+			fmt.Sprintf("volume %q cannot be deleted as it has snapshots %v. Delete the VolumeSnapshots "+
+				"or annotate the PV with %s=true to delete them along with the volume",
+				volumeID, snapshotIDs, common.AnnCascadeDeleteSnapshots))
+	}
+	return csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.FailedPrecondition,
+		"volume: %s with existing snapshots %v cannot be deleted, "+
+			"please delete snapshots before deleting the volume", volumeID, snapshotIDs)
+}
+
+// cascadeDeleteSnapshots deletes the snapshots with the given IDs of the
+// volume of the given PV. Snapshots with a VolumeSnapshotContent are deleted
+// through their VolumeSnapshot, or the VolumeSnapshotContent if it is not
+// bound, so that the snapshot objects don't outlive the CNS snapshots. An
+// Unavailable error is returned until the external-snapshotter has deleted
+// them. Snapshots without a VolumeSnapshotContent are deleted directly. No
+// snapshot is deleted while a PVC is being restored from one of them.
+func cascadeDeleteSnapshots(ctx context.Context, c *controller, volumeManager cnsvolume.Manager,
+	pv *v1.PersistentVolume, volumeID string, snapshotIDs []string) (string, error) {
+	log := logger.GetLogger(ctx)
+	if c.snapshotterClient == nil {
+		return csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+			"no snapshotter client to delete snapshots %v of volume %q", snapshotIDs, volumeID)
+	}
+	contentList, err := c.snapshotterClient.SnapshotV1().VolumeSnapshotContents().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to list VolumeSnapshotContents of volume %q. Error: %+v", volumeID, err)
+	}
+	var contents []*snapv1.VolumeSnapshotContent
+	var orphanSnapshotIDs []string
+	for _, snapshotID := range snapshotIDs {
+		if content := findVolumeSnapshotContent(contentList.Items, snapshotID); content != nil {
+			contents = append(contents, content)
+		} else {
+			orphanSnapshotIDs = append(orphanSnapshotIDs, snapshotID)
+		}
+	}
+
+	restoringPVCs, err := getRestoringPVCs(ctx, c.k8sClient, contents)
+	if err != nil {
+		return csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to list the PVCs restored from the snapshots of volume %q. Error: %+v", volumeID, err)
+	}
+	if len(restoringPVCs) > 0 {
+		msg := fmt.Sprintf("snapshots %v of volume %q cannot be deleted as PVCs %v are being restored "+
+			"from them", snapshotIDs, volumeID, restoringPVCs)
+		recordPVEvent(ctx, c.k8sClient, pv, v1.EventTypeWarning, "SnapshotsInUse", msg)
+		return csifault.CSIInternalFault, logger.LogNewErrorCode(log, codes.FailedPrecondition, msg)
+	}
+
+	for _, snapshotID := range orphanSnapshotIDs {
+		if err := common.DeleteSnapshotUtil(ctx, volumeManager, snapshotID); err != nil {
+			recordPVEvent(ctx, c.k8sClient, pv, v1.EventTypeWarning, "SnapshotCascadeDeleteFailed",
+				fmt.Sprintf("failed to delete snapshot %q of volume %q: %v", snapshotID, volumeID, err))
+			return csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+				"failed to delete snapshot %q of volume %q. Error: %+v", snapshotID, volumeID, err)
+		}
+	}
+	contentNames := make([]string, 0, len(contents))
+	for _, content := range contents {
+		if err := deleteVolumeSnapshotContent(ctx, c.snapshotterClient, content); err != nil {
+			recordPVEvent(ctx, c.k8sClient, pv, v1.EventTypeWarning, "SnapshotCascadeDeleteFailed",
+				fmt.Sprintf("failed to delete VolumeSnapshotContent %q of volume %q: %v", content.Name,
+					volumeID, err))
+			return csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+				"failed to delete VolumeSnapshotContent %q of volume %q. Error: %+v", content.Name, volumeID, err)
+		}
+		contentNames = append(contentNames, content.Name)
+	}
+	if len(contents) == 0 {
+		recordPVEvent(ctx, c.k8sClient, pv, v1.EventTypeNormal, "SnapshotsCascadeDeleted",
+			fmt.Sprintf("deleted snapshots %v of volume %q", snapshotIDs, volumeID))
+		return "", nil
+	}
+	msg := fmt.Sprintf("deletion of volume %s is deferred until its VolumeSnapshotContents %v are deleted",
+		volumeID, contentNames)
+	recordPVEvent(ctx, c.k8sClient, pv, v1.EventTypeNormal, "SnapshotsCascadeDeleting", msg)
+	return csifault.CSIInternalFault, logger.LogNewErrorCode(log, codes.Unavailable, msg)
+}
+
+// deleteVolumeSnapshotContent deletes the VolumeSnapshot bound to the given
+// VolumeSnapshotContent, or the VolumeSnapshotContent itself if it is not
+// bound, after changing its deletion policy to Delete so that the
+// external-snapshotter deletes the CNS snapshot along with it.
+func deleteVolumeSnapshotContent(ctx context.Context, client snapshotterClientSet.Interface,
+	content *snapv1.VolumeSnapshotContent) error {
+	log := logger.GetLogger(ctx)
+	if content.DeletionTimestamp != nil {
+		return nil
+	}
+	if content.Spec.DeletionPolicy != snapv1.VolumeSnapshotContentDelete {
+		patch, err := json.Marshal(map[string]interface{}{
+			"spec": map[string]interface{}{
+				"deletionPolicy": snapv1.VolumeSnapshotContentDelete,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		_, err = client.SnapshotV1().VolumeSnapshotContents().Patch(ctx, content.Name, apitypes.MergePatchType,
+			patch, metav1.PatchOptions{})
+		if err != nil {
+			return err
+		}
+		log.Infof("Changed deletion policy of VolumeSnapshotContent %q to %q", content.Name,
+			snapv1.VolumeSnapshotContentDelete)
+	}
+	ref := content.Spec.VolumeSnapshotRef
+	var err error
+	if ref.Name != "" {
+		log.Infof("Deleting VolumeSnapshot %s/%s of VolumeSnapshotContent %q", ref.Namespace, ref.Name,
+			content.Name)
+		err = client.SnapshotV1().VolumeSnapshots(ref.Namespace).Delete(ctx, ref.Name, metav1.DeleteOptions{})
+	} else {
+		log.Infof("Deleting VolumeSnapshotContent %q", content.Name)
+		err = client.SnapshotV1().VolumeSnapshotContents().Delete(ctx, content.Name, metav1.DeleteOptions{})
+	}
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// getRestoringPVCs returns the namespaced names of the pending PVCs whose
+// data source is the VolumeSnapshot of one of the given
+// VolumeSnapshotContents.
+func getRestoringPVCs(ctx context.Context, k8sClient clientset.Interface,
+	contents []*snapv1.VolumeSnapshotContent) ([]string, error) {
+	if len(contents) == 0 {
+		return nil, nil
+	}
+	volumeSnapshots := make(map[string]bool)
+	for _, content := range contents {
+		ref := content.Spec.VolumeSnapshotRef
+		if ref.Name != "" {
+			volumeSnapshots[ref.Namespace+"/"+ref.Name] = true
+		}
+	}
+	pvcs, err := k8sClient.CoreV1().PersistentVolumeClaims("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var restoringPVCs []string
+	for i := range pvcs.Items {
+		pvc := &pvcs.Items[i]
+		if pvc.Status.Phase != v1.ClaimPending {
+			continue
+		}
+		if source := getPVCSnapshotSource(pvc); source != "" && volumeSnapshots[source] {
+			restoringPVCs = append(restoringPVCs, pvc.Namespace+"/"+pvc.Name)
+		}
+	}
+	return restoringPVCs, nil
+}
+
+// getPVCSnapshotSource returns the namespaced name of the VolumeSnapshot the
+// given PVC is restored from, or an empty string if it isn't restored from a
+// VolumeSnapshot.
+func getPVCSnapshotSource(pvc *v1.PersistentVolumeClaim) string {
+	if ref := pvc.Spec.DataSourceRef; ref != nil {
+		if ref.Kind != "VolumeSnapshot" || ref.APIGroup == nil || *ref.APIGroup != snapv1.GroupName {
+			return ""
+		}
+		namespace := pvc.Namespace
+		if ref.Namespace != nil && *ref.Namespace != "" {
+			namespace = *ref.Namespace
+		}
+		return namespace + "/" + ref.Name
+	}
+	if ref := pvc.Spec.DataSource; ref != nil && ref.Kind == "VolumeSnapshot" &&
+		ref.APIGroup != nil && *ref.APIGroup == snapv1.GroupName {
+		return pvc.Namespace + "/" + ref.Name
+	}
+	return ""
+}
+
+// recordPVEvent records an event with the given type, reason and message on
+// the given PV.
+func recordPVEvent(ctx context.Context, k8sClient clientset.Interface, pv *v1.PersistentVolume,
+	eventType, reason, message string) {
+	log := logger.GetLogger(ctx)
+	log.Debugf("Recording %s event %q on PV %q: %s", eventType, reason, pv.Name, message)
+	getEventRecorder(k8sClient).Event(pv, eventType, reason, message)
+}
+
+// recordPVCEvent records an event with the given type, reason and message on
+// the given PVC.
+func recordPVCEvent(ctx context.Context, k8sClient clientset.Interface, pvc *v1.PersistentVolumeClaim,
+	eventType, reason, message string) {
+	log := logger.GetLogger(ctx)
+	log.Debugf("Recording %s event %q on PVC %s/%s: %s", eventType, reason, pvc.Namespace, pvc.Name, message)
+	getEventRecorder(k8sClient).Event(pvc, eventType, reason, message)
+}
+
+// getEventRecorder returns the recorder of the events on PVs and PVCs,
+// creating it with the given client on the first call.
+func getEventRecorder(k8sClient clientset.Interface) record.EventRecorder {
+	eventRecorderOnce.Do(func() {
+		eventBroadcaster := record.NewBroadcaster()
+		eventBroadcaster.StartRecordingToSink(
+			&typedcorev1.EventSinkImpl{
+				Interface: k8sClient.CoreV1().Events(""),
+			},
+		)
+		eventRecorder = eventBroadcaster.NewRecorder(scheme.Scheme,
+			v1.EventSource{Component: csitypes.DriverName()})
+	})
+	return eventRecorder
+}
+
+// isProvisioningCancellationEnabled returns true if CreateVolume has to check
+// whether the PVC of the volume is deleted during provisioning. The PVC of the
+// volume is only known when the external-provisioner passes it in the
+// CreateVolume parameters, with its --extra-create-metadata flag.
+func isProvisioningCancellationEnabled(ctx context.Context, scParams *common.StorageClassParams) bool {
+	return scParams.PvcName != "" && scParams.PvcNamespace != "" &&
+		commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.ProvisioningCancellation)
+}
+
+// isPVCDeleted returns true if the PVC of the given PV is deleted or being
+// deleted. The external-provisioner names the PV of a PVC after the UID of
+// the PVC, so a PVC deleted and created again with the same name is also
+// considered deleted.
+func isPVCDeleted(ctx context.Context, k8sClient clientset.Interface, scParams *common.StorageClassParams,
+	pvName string) (bool, error) {
+	pvc, err := k8sClient.CoreV1().PersistentVolumeClaims(scParams.PvcNamespace).Get(ctx, scParams.PvcName,
+		metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	if pvc.DeletionTimestamp != nil {
+		return true, nil
+	}
+	if uid := strings.TrimPrefix(pvName, "pvc-"); uid != pvName && uid != string(pvc.UID) {
+		return true, nil
+	}
+	return false, nil
+}
+
+// checkPVCBeforeCreateVolume returns an Aborted error if the PVC of the given
+// PV is deleted, so that CNS CreateVolume is not invoked for it. Failures to
+// get the PVC don't fail the provisioning.
+func checkPVCBeforeCreateVolume(ctx context.Context, scParams *common.StorageClassParams, pvName string) error {
+	log := logger.GetLogger(ctx)
+	if !isProvisioningCancellationEnabled(ctx, scParams) {
+		return nil
+	}
+	k8sClient, err := k8s.NewClient(ctx)
+	if err != nil {
+		log.Warnf("failed to create kubernetes client to look up PVC %s/%s. Error: %v",
+			scParams.PvcNamespace, scParams.PvcName, err)
+		return nil
+	}
+	deleted, err := isPVCDeleted(ctx, k8sClient, scParams, pvName)
+	if err != nil {
+		log.Warnf("failed to get PVC %s/%s. Error: %v", scParams.PvcNamespace, scParams.PvcName, err)
+		return nil
+	}
+	if deleted {
+		return logger.LogNewErrorCodef(log, codes.Aborted,
+			"PVC %s/%s of volume %q is deleted, cancelling the provisioning",
+			scParams.PvcNamespace, scParams.PvcName, pvName)
+	}
+	return nil
+}
+
+// pinRestoreDatastore sets the DatastoreURL of scParams to the datastore the
+// PVC of a volume restored from a snapshot is pinned to with the
+// AnnRestoreDatastore annotation. The PVC of the volume is only known when the
+// external-provisioner passes it in the CreateVolume parameters, with its
+// --extra-create-metadata flag.
+func pinRestoreDatastore(ctx context.Context, vCenterHost string, scParams *common.StorageClassParams,
+	snapshotDatastoreURL string) error {
+	log := logger.GetLogger(ctx)
+	if scParams.PvcName == "" || scParams.PvcNamespace == "" ||
+		!commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.RestoreDatastorePinning) {
+		return nil
+	}
+	k8sClient, err := k8s.NewClient(ctx)
+	if err != nil {
+		return logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to create kubernetes client to look up PVC %s/%s. Error: %v",
+			scParams.PvcNamespace, scParams.PvcName, err)
+	}
+	pvc, err := k8sClient.CoreV1().PersistentVolumeClaims(scParams.PvcNamespace).Get(ctx, scParams.PvcName,
+		metav1.GetOptions{})
+	if err != nil {
+		return logger.LogNewErrorCodef(log, codes.Internal, "failed to get PVC %s/%s. Error: %v",
+			scParams.PvcNamespace, scParams.PvcName, err)
+	}
+	pinnedDatastore := strings.TrimSpace(pvc.Annotations[common.AnnRestoreDatastore])
+	var pinnedDatastoreURL string
+	switch {
+	case pinnedDatastore == "":
+		return nil
+	case pinnedDatastore == common.RestoreDatastoreSource:
+		pinnedDatastoreURL = strings.TrimSpace(snapshotDatastoreURL)
+	case strings.HasPrefix(pinnedDatastore, "ds:///"):
+		pinnedDatastoreURL = pinnedDatastore
+	default:
+		pinnedDatastoreURL, err = getDatastoreURLByName(ctx, vCenterHost, pinnedDatastore)
+		if err != nil {
+			return err
+		}
+	}
+	if scParams.DatastoreURL != "" && strings.TrimSpace(scParams.DatastoreURL) != pinnedDatastoreURL {
+		return logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"datastore URL %q given in storage class does not match the datastore %q PVC %s/%s is pinned to "+
+				"with the %q annotation", scParams.DatastoreURL, pinnedDatastoreURL, scParams.PvcNamespace,
+			scParams.PvcName, common.AnnRestoreDatastore)
+	}
+	log.Infof("Restoring the volume of PVC %s/%s on datastore %q as per the %q annotation",
+		scParams.PvcNamespace, scParams.PvcName, pinnedDatastoreURL, common.AnnRestoreDatastore)
+	scParams.DatastoreURL = pinnedDatastoreURL
+	return nil
+}
+
+// getDatastoreURLByName returns the URL of the datastore with the given name
+// in the datacenters of the given vCenter.
+func getDatastoreURLByName(ctx context.Context, vCenterHost string, datastoreName string) (string, error) {
+	log := logger.GetLogger(ctx)
+	vCenter, err := vsphere.GetVirtualCenterManager(ctx).GetVirtualCenter(ctx, vCenterHost)
+	if err != nil {
+		return "", logger.LogNewErrorCodef(log, codes.Internal, "failed to get vCenter %q. err: %+v",
+			vCenterHost, err)
+	}
+	dcList, err := vCenter.GetDatacenters(ctx)
+	if err != nil {
+		return "", logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to get datacenter list. err: %+v", err)
+	}
+	for _, dc := range dcList {
+		dsURLTodsInfoMap, err := dc.GetAllDatastores(ctx)
+		if err != nil {
+			return "", logger.LogNewErrorCodef(log, codes.Internal,
+				"failed to get dsURLTodsInfoMap. err: %+v", err)
+		}
+		for dsURL, dsInfo := range dsURLTodsInfoMap {
+			if dsInfo.Info.Name == datastoreName {
+				return dsURL, nil
+			}
+		}
+	}
+	return "", logger.LogNewErrorCodef(log, codes.InvalidArgument,
+		"failed to find datastoreURL for datastore name: %q", datastoreName)
+}
+
+// cancelVolumeIfPVCDeleted deletes the volume created for the given PV if its
+// PVC was deleted while the volume was being created, as the
+// external-provisioner would not delete it. The cancellation is recorded in
+// the CreateVolume task details of the volume, so that a later CreateVolume
+// call with the same name doesn't return the deleted volume. It returns the
+// updated task details and an Aborted error if the volume was deleted.
+func cancelVolumeIfPVCDeleted(ctx context.Context, volumeManager cnsvolume.Manager,
+	operationStore cnsvolumeoperationrequest.VolumeOperationRequest, scParams *common.StorageClassParams,
+	volumeName string, pvName string, volumeID string) (
+	*cnsvolumeoperationrequest.VolumeOperationRequestDetails, error) {
+	log := logger.GetLogger(ctx)
+	if !isProvisioningCancellationEnabled(ctx, scParams) {
+		return nil, nil
+	}
+	k8sClient, err := k8s.NewClient(ctx)
+	if err != nil {
+		log.Warnf("failed to create kubernetes client to look up PVC %s/%s. Error: %v",
+			scParams.PvcNamespace, scParams.PvcName, err)
+		return nil, nil
+	}
+	deleted, err := isPVCDeleted(ctx, k8sClient, scParams, pvName)
+	if err != nil {
+		log.Warnf("failed to get PVC %s/%s. Error: %v", scParams.PvcNamespace, scParams.PvcName, err)
+		return nil, nil
+	}
+	if !deleted {
+		return nil, nil
+	}
+	log.Infof("PVC %s/%s was deleted while volume %q was being created, deleting the volume",
+		scParams.PvcNamespace, scParams.PvcName, volumeID)
+	if _, err := common.DeleteVolumeUtil(ctx, volumeManager, volumeID, true); err != nil {
+		return nil, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to delete volume %q created for deleted PVC %s/%s. Error: %+v",
+			volumeID, scParams.PvcNamespace, scParams.PvcName, err)
+	}
+	errMsg := fmt.Sprintf("PVC %s/%s was deleted during provisioning, deleted volume %q",
+		scParams.PvcNamespace, scParams.PvcName, volumeID)
+	volumeOperationDetails := cnsvolumeoperationrequest.CreateVolumeOperationRequestDetails(volumeName,
+		"", "", 0, nil, metav1.Now(), "", "", "", cnsvolumeoperationrequest.TaskInvocationStatusError, errMsg)
+	if operationStore != nil {
+		if err := operationStore.StoreRequestDetails(ctx, volumeOperationDetails); err != nil {
+			log.Warnf("failed to store CreateVolume details with error: %v", err)
+		}
+	}
+	return volumeOperationDetails, logger.LogNewErrorCode(log, codes.Aborted, errMsg)
+}
+
+// getVolumeDeletionProtection returns the deletion protection of a volume
+// and its PV. The protection is read from the AnnDeletionProtection annotation
+// on the PV or, if the PV isn't annotated, on its PVC while it still exists.
+func getVolumeDeletionProtection(ctx context.Context, k8sClient clientset.Interface,
+	volumeID string) (string, *v1.PersistentVolume, error) {
+	pvName, found := commonco.ContainerOrchestratorUtility.GetPVNameFromCSIVolumeID(volumeID)
+	if !found {
+		return "", nil, nil
+	}
+	pv, err := k8sClient.CoreV1().PersistentVolumes().Get(ctx, pvName, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", nil, nil
+		}
+		return "", nil, err
+	}
+	if protection, ok := pv.Annotations[common.AnnDeletionProtection]; ok {
+		return protection, pv, nil
+	}
+	if pv.Spec.ClaimRef == nil {
+		return "", pv, nil
+	}
+	pvc, err := k8sClient.CoreV1().PersistentVolumeClaims(pv.Spec.ClaimRef.Namespace).Get(ctx,
+		pv.Spec.ClaimRef.Name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", pv, nil
+		}
+		return "", pv, err
+	}
+	if pvc.UID != pv.Spec.ClaimRef.UID {
+		return "", pv, nil
+	}
+	return pvc.Annotations[common.AnnDeletionProtection], pv, nil
+}
+
+// handleVolumeDeletionProtection is called by DeleteVolume for a block volume
+// when the volume-deletion-protection FSS is enabled. A volume protected with
+// DeletionProtectionSnapshot is retained with a safety snapshot, in which
+// case true is returned and the volume must not be deleted. A volume protected
+// with DeletionProtectionDeny, or with an unknown protection, is not deleted
+// and a FailedPrecondition error is returned.
+func handleVolumeDeletionProtection(ctx context.Context, c *controller, vCenterHost string,
+	vCenterManager vsphere.VirtualCenterManager, volumeManager cnsvolume.Manager,
+	volumeID string) (bool, string, error) {
+	log := logger.GetLogger(ctx)
+	retainedAt, err := volumeManager.RetrieveVStorageObjectMetadataValue(ctx, volumeID,
+		common.VStorageObjectMetadataRetainedAt)
+	if err != nil {
+		return false, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to retrieve retention time of volume %q. Error: %+v", volumeID, err)
+	}
+	if retainedAt != "" {
+		log.Infof("Volume %q is already retained since %s", volumeID, retainedAt)
+		return true, "", nil
+	}
+	k8sClient, err := k8s.NewClient(ctx)
+	if err != nil {
+		return false, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to create kubernetes client to look up deletion protection of volume %q. Error: %+v",
+			volumeID, err)
+	}
+	protection, pv, err := getVolumeDeletionProtection(ctx, k8sClient, volumeID)
+	if err != nil {
+		return false, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to look up deletion protection of volume %q. Error: %+v", volumeID, err)
+	}
+	switch protection {
+	case "":
+		return false, "", nil
+	case common.DeletionProtectionSnapshot:
+		faultType, err := retainVolumeWithSafetySnapshot(ctx, c, vCenterHost, vCenterManager, volumeManager,
+			volumeID)
+		if err != nil {
+			if pv != nil {
+				recordPVEvent(ctx, k8sClient, pv, v1.EventTypeWarning, "VolumeRetentionFailed", err.Error())
+			}
+			return false, faultType, err
+		}
+		if pv != nil {
+			recordPVEvent(ctx, k8sClient, pv, v1.EventTypeNormal, "VolumeRetained",
+				fmt.Sprintf("volume %s is retained with a safety snapshot for %d hours", volumeID,
+					c.managers.CnsConfig.DeletionProtection.SnapshotRetentionInHours))
+		}
+		return true, "", nil
+	default:
+		msg := fmt.Sprintf("volume %s is protected from deletion by annotation %s=%s, "+
+			"remove the annotation to delete it", volumeID, common.AnnDeletionProtection, protection)
+		if pv != nil {
+			recordPVEvent(ctx, k8sClient, pv, v1.EventTypeWarning, "VolumeDeletionProtected", msg)
+		}
+		return false, csifault.CSIInternalFault, logger.LogNewErrorCode(log, codes.FailedPrecondition, msg)
+	}
+}
+
+// retainVolumeWithSafetySnapshot takes a snapshot of the volume and stamps
+// its backing disk with the retention time, instead of deleting the volume.
+// The syncer deletes the volume along with its safety snapshot once the
+// retention period is over and the volume has no other snapshots.
+func retainVolumeWithSafetySnapshot(ctx context.Context, c *controller, vCenterHost string,
+	vCenterManager vsphere.VirtualCenterManager, volumeManager cnsvolume.Manager, volumeID string) (string, error) {
+	log := logger.GetLogger(ctx)
+	isCnsSnapshotSupported, err := vCenterManager.IsCnsSnapshotSupported(ctx, vCenterHost)
+	if err != nil {
+		return csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to check if cns snapshot operations are supported on VC due to error: %v", err)
+	}
+	if !isCnsSnapshotSupported {
+		return csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.FailedPrecondition,
+			"volume %q is protected by a safety snapshot, which is not supported on vCenter %q",
+			volumeID, vCenterHost)
+	}
+	snapshotID, _, err := common.CreateSnapshotUtil(ctx, volumeManager, volumeID, "safety-snapshot-"+volumeID, nil)
+	if err != nil {
+		return csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to create safety snapshot of volume %q. Error: %+v", volumeID, err)
+	}
+	err = volumeManager.UpdateVStorageObjectMetadata(ctx, volumeID, map[string]string{
+		common.VStorageObjectMetadataRetainedAt:       time.Now().UTC().Format(time.RFC3339),
+		common.VStorageObjectMetadataSafetySnapshotID: snapshotID,
+	}, nil)
+	if err != nil {
+		return csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to set retention time on volume %q. Error: %+v", volumeID, err)
+	}
+	log.Infof("Volume %q retained with safety snapshot %q, it will be deleted after %d hours", volumeID,
+		snapshotID, c.managers.CnsConfig.DeletionProtection.SnapshotRetentionInHours)
+	return "", nil
+}
+
+// reviewCreateVolumeRequest asks the policy engine whether the CreateVolume
+// request may proceed. The parameters of the request are replaced by the ones
+// returned by the policy engine, if any.
+func (c *controller) reviewCreateVolumeRequest(ctx context.Context, req *csi.CreateVolumeRequest) (string, error) {
+	reviewReq := &policyengine.ReviewRequest{
+		Operation:     policyengine.OperationCreateVolume,
+		Name:          req.Name,
+		Namespace:     req.Parameters[common.AttributePvcNamespace],
+		ClaimName:     req.Parameters[common.AttributePvcName],
+		CapacityBytes: req.GetCapacityRange().GetRequiredBytes(),
+		Parameters:    req.Parameters,
+	}
+	for _, topology := range req.GetAccessibilityRequirements().GetPreferred() {
+		reviewReq.Topology = append(reviewReq.Topology, topology.GetSegments())
+	}
+	if source := req.GetVolumeContentSource(); source != nil {
+		reviewReq.SourceVolumeID = source.GetVolume().GetVolumeId()
+		reviewReq.SourceSnapshotID = source.GetSnapshot().GetSnapshotId()
+	}
+	params, faultType, err := c.reviewProvisioningRequest(ctx, reviewReq)
+	if err != nil {
+		return faultType, err
+	}
+	if params != nil {
+		req.Parameters = params
+	}
+	return "", nil
+}
+
+// reviewCreateSnapshotRequest asks the policy engine whether the
+// CreateSnapshot request may proceed.
+func (c *controller) reviewCreateSnapshotRequest(ctx context.Context, req *csi.CreateSnapshotRequest,
+	sizeInMB int64) error {
+	params, _, err := c.reviewProvisioningRequest(ctx, &policyengine.ReviewRequest{
+		Operation:      policyengine.OperationCreateSnapshot,
+		Name:           req.Name,
+		Namespace:      req.Parameters[common.VolumeSnapshotNamespaceKey],
+		ClaimName:      req.Parameters[common.VolumeSnapshotNameKey],
+		CapacityBytes:  sizeInMB * common.MbInBytes,
+		Parameters:     req.Parameters,
+		SourceVolumeID: req.GetSourceVolumeId(),
+	})
+	if err != nil {
+		return err
+	}
+	if params != nil {
+		req.Parameters = params
+	}
+	return nil
+}
+
+// reviewProvisioningRequest sends the request to the policy engine and
+// returns the parameters set by the policy engine, if any. Requests are denied
+// when the policy engine can't be reached, unless fail-open is configured.
+func (c *controller) reviewProvisioningRequest(ctx context.Context,
+	reviewReq *policyengine.ReviewRequest) (map[string]string, string, error) {
+	log := logger.GetLogger(ctx)
+	resp, err := c.policyEngine.Review(ctx, reviewReq)
+	if err != nil {
+		if c.managers.CnsConfig.PolicyEngine.FailOpen {
+			log.Warnf("%s request %q is allowed as the policy engine could not review it. Error: %v",
+				reviewReq.Operation, reviewReq.Name, err)
+			return nil, "", nil
+		}
+		return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Unavailable,
+			"failed to review %s request %q with the policy engine. Error: %v",
+			reviewReq.Operation, reviewReq.Name, err)
+	}
+	if !resp.Allowed {
+		return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log, codes.PermissionDenied,
+			"%s request %q is denied by the policy engine: %s", reviewReq.Operation, reviewReq.Name, resp.Reason)
+	}
+	if len(resp.Parameters) > 0 {
+		log.Infof("Parameters of %s request %q are set by the policy engine to %+v",
+			reviewReq.Operation, reviewReq.Name, resp.Parameters)
+		return resp.Parameters, "", nil
+	}
+	return nil, "", nil
+}
+
+// checkDatastoreAccessibleFromNode checks, before attaching a volume to a node
+// VM, that the datastore of the volume is accessible from the host of the node
+// VM, so that the attach fails with a clear error rather than a generic
+// reconfigure failure from vCenter. The check uses the accessible datastores
+// in the CnsNodeInfo of the node, and only looks them up in vCenter if the
+// datastore isn't among them, as the node VM may have moved to another host
+// since they were recorded. The check is skipped if the datastore of the volume
+// or the CnsNodeInfo of the node is not known.
+func checkDatastoreAccessibleFromNode(ctx context.Context, nodeVM *vsphere.VirtualMachine,
+	datastoreURL string) error {
+	log := logger.GetLogger(ctx)
+	if datastoreURL == "" {
+		return nil
+	}
+	nodeInfo, err := nodeInfoService.GetNodeInfoByUUID(ctx, nodeVM.UUID)
+	if err != nil {
+		log.Debugf("No CnsNodeInfo found for node VM %v, skipping the datastore accessibility check", nodeVM)
+		return nil
+	}
+	for _, url := range nodeInfo.Spec.AccessibleDatastores {
+		if url == datastoreURL {
+			return nil
+		}
+	}
+	log.Infof("Datastore %q is not accessible from host %q of node %q in its CnsNodeInfo, "+
+		"checking the datastores accessible from the node VM in vCenter", datastoreURL, nodeInfo.Spec.Host,
+		nodeInfo.Spec.NodeName)
+	datastores, err := nodeVM.GetAllAccessibleDatastores(ctx)
+	if err != nil {
+		log.Warnf("failed to get accessible datastores of node VM %v, skipping the datastore accessibility "+
+			"check. Error: %v", nodeVM, err)
+		return nil
+	}
+	for _, datastore := range datastores {
+		if datastore.Info.Url == datastoreURL {
+			return nil
+		}
+	}
+	host := nodeInfo.Spec.Host
+	if hostSystem, err := nodeVM.HostSystem(ctx); err == nil {
+		host = hostSystem.Reference().Value
+	}
+	return logger.LogNewErrorCodef(log, codes.FailedPrecondition,
+		"volume on datastore %q cannot be attached to node %q: the datastore is not accessible from "+
+			"host %q running the node VM. Check the topology of the StorageClass of the volume",
+		datastoreURL, nodeInfo.Spec.NodeName, host)
+}
+
+// attachMultiWriterVolume attaches the disk of the multi-writer block volume
+// to the node VM with the multi-writer sharing mode set on its backing, for
+// the volume to be attached to other nodes at the same time. The disk UUID is
+// returned along with the fault type of the error.
+func attachMultiWriterVolume(ctx context.Context, volumeManager cnsvolume.Manager,
+	nodevm *vsphere.VirtualMachine, volumeID string) (string, string, error) {
+	log := logger.GetLogger(ctx)
+	vStorageObject, err := volumeManager.RetrieveVStorageObject(ctx, volumeID)
+	if err != nil {
+		return "", csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to retrieve virtual disk of volume %q. Err: %v", volumeID, err)
+	}
+	backing, ok := vStorageObject.Config.Backing.(types.BaseBaseConfigInfoFileBackingInfo)
+	if !ok {
+		return "", csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+			"volume %q is not backed by a disk file", volumeID)
+	}
+	fileBacking := backing.GetBaseConfigInfoFileBackingInfo()
+	diskUUID, err := nodevm.AttachMultiWriterDisk(ctx, fileBacking.Datastore, fileBacking.FilePath)
+	if err != nil {
+		if errors.Is(err, vsphere.ErrNoFreeDiskSlots) {
+			return "", csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.ResourceExhausted,
+				"cannot attach volume %q to VM %q: %v", volumeID, nodevm.String(), err)
+		}
+		return "", csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to attach multi-writer volume %q to VM %q. Err: %v", volumeID, nodevm.String(), err)
+	}
+	return diskUUID, "", nil
+}
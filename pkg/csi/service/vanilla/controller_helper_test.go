@@ -0,0 +1,283 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vanilla
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v6/apis/volumesnapshot/v1"
+	snapshotclientfake "github.com/kubernetes-csi/external-snapshotter/client/v6/clientset/versioned/fake"
+	"github.com/stretchr/testify/assert"
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	vimtypes "github.com/vmware/govmomi/vim25/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	cnsconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/unittestcommon"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+)
+
+// pvNameOrchestrator is a container orchestrator which knows the PV names of
+// the given volumes and overrides the state of the given features.
+type pvNameOrchestrator struct {
+	commonco.COCommonInterface
+	pvNames  map[string]string
+	features map[string]bool
+}
+
+func (o *pvNameOrchestrator) GetPVNameFromCSIVolumeID(volumeID string) (string, bool) {
+	pvName, found := o.pvNames[volumeID]
+	return pvName, found
+}
+
+func (o *pvNameOrchestrator) IsFSSEnabled(ctx context.Context, featureName string) bool {
+	if enabled, ok := o.features[featureName]; ok {
+		return enabled
+	}
+	return o.COCommonInterface.IsFSSEnabled(ctx, featureName)
+}
+
+func TestGetVolumeDeletionProtectionReleasedPV(t *testing.T) {
+	fakeOrchestrator, err := unittestcommon.GetFakeContainerOrchestratorInterface(common.Kubernetes)
+	if err != nil {
+		t.Fatalf("failed to create fake container orchestrator. Error: %v", err)
+	}
+	savedOrchestrator := commonco.ContainerOrchestratorUtility
+	commonco.ContainerOrchestratorUtility = &pvNameOrchestrator{
+		COCommonInterface: fakeOrchestrator,
+		pvNames:           map[string]string{"volume-1": "pv-1"},
+	}
+	defer func() {
+		commonco.ContainerOrchestratorUtility = savedOrchestrator
+	}()
+
+	// The PVC of the volume is deleted and its PV is Released when
+	// DeleteVolume is called.
+	k8sClient := fake.NewSimpleClientset(&v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "pv-1",
+			Annotations: map[string]string{common.AnnDeletionProtection: common.DeletionProtectionDeny},
+		},
+		Spec: v1.PersistentVolumeSpec{
+			ClaimRef: &v1.ObjectReference{Namespace: "default", Name: "pvc-1"},
+		},
+		Status: v1.PersistentVolumeStatus{Phase: v1.VolumeReleased},
+	})
+	protection, pv, err := getVolumeDeletionProtection(context.Background(), k8sClient, "volume-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if protection != common.DeletionProtectionDeny || pv == nil || pv.Name != "pv-1" {
+		t.Errorf("expected protection %q of PV %q, got %q of %v", common.DeletionProtectionDeny, "pv-1",
+			protection, pv)
+	}
+}
+
+func TestHandleVolumeSnapshotsOnDelete(t *testing.T) {
+	fakeOrchestrator, err := unittestcommon.GetFakeContainerOrchestratorInterface(common.Kubernetes)
+	if err != nil {
+		t.Fatalf("failed to create fake container orchestrator. Error: %v", err)
+	}
+	savedOrchestrator := commonco.ContainerOrchestratorUtility
+	defer func() {
+		commonco.ContainerOrchestratorUtility = savedOrchestrator
+	}()
+
+	const (
+		volumeID          = "volume-1"
+		boundSnapshotID   = volumeID + common.VSphereCSISnapshotIdDelimiter + "snapshot-1"
+		orphanSnapshotID  = volumeID + common.VSphereCSISnapshotIdDelimiter + "snapshot-2"
+		volumeSnapshot    = "snap-1"
+		volumeSnapshotNs  = "default"
+		cascadeAnnotation = "true"
+	)
+	newPV := func(annotation string) *v1.PersistentVolume {
+		pv := &v1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: "pv-1"}}
+		if annotation != "" {
+			pv.Annotations = map[string]string{common.AnnCascadeDeleteSnapshots: annotation}
+		}
+		return pv
+	}
+	handle := boundSnapshotID
+	content := &snapv1.VolumeSnapshotContent{
+		ObjectMeta: metav1.ObjectMeta{Name: "snapcontent-1"},
+		Spec: snapv1.VolumeSnapshotContentSpec{
+			Driver:            csitypes.DriverName(),
+			DeletionPolicy:    snapv1.VolumeSnapshotContentRetain,
+			VolumeSnapshotRef: v1.ObjectReference{Namespace: volumeSnapshotNs, Name: volumeSnapshot},
+		},
+		Status: &snapv1.VolumeSnapshotContentStatus{SnapshotHandle: &handle},
+	}
+	snapshot := &snapv1.VolumeSnapshot{
+		ObjectMeta: metav1.ObjectMeta{Namespace: volumeSnapshotNs, Name: volumeSnapshot},
+	}
+	snapshotGroup := snapv1.GroupName
+	restoringPVC := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Namespace: volumeSnapshotNs, Name: "restored"},
+		Spec: v1.PersistentVolumeClaimSpec{
+			DataSource: &v1.TypedLocalObjectReference{
+				APIGroup: &snapshotGroup, Kind: "VolumeSnapshot", Name: volumeSnapshot,
+			},
+		},
+		Status: v1.PersistentVolumeClaimStatus{Phase: v1.ClaimPending},
+	}
+
+	tests := []struct {
+		name               string
+		cascadeFSS         bool
+		annotation         string
+		pvcs               []runtime.Object
+		expectedCode       codes.Code
+		expectedSnapshots  []string
+		expectVSDeleted    bool
+		expectDeletePolicy snapv1.DeletionPolicy
+	}{
+		{
+			name:              "FSS disabled",
+			annotation:        cascadeAnnotation,
+			expectedCode:      codes.FailedPrecondition,
+			expectedSnapshots: []string{"snapshot-1", "snapshot-2"},
+		},
+		{
+			name:              "PV not annotated",
+			cascadeFSS:        true,
+			expectedCode:      codes.FailedPrecondition,
+			expectedSnapshots: []string{"snapshot-1", "snapshot-2"},
+		},
+		{
+			name:              "annotation not true",
+			cascadeFSS:        true,
+			annotation:        "false",
+			expectedCode:      codes.FailedPrecondition,
+			expectedSnapshots: []string{"snapshot-1", "snapshot-2"},
+		},
+		{
+			name:              "PVC being restored from a snapshot",
+			cascadeFSS:        true,
+			annotation:        cascadeAnnotation,
+			pvcs:              []runtime.Object{restoringPVC},
+			expectedCode:      codes.FailedPrecondition,
+			expectedSnapshots: []string{"snapshot-1", "snapshot-2"},
+		},
+		{
+			name:               "cascade delete",
+			cascadeFSS:         true,
+			annotation:         cascadeAnnotation,
+			expectedCode:       codes.Unavailable,
+			expectedSnapshots:  []string{"snapshot-1"},
+			expectVSDeleted:    true,
+			expectDeletePolicy: snapv1.VolumeSnapshotContentDelete,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			commonco.ContainerOrchestratorUtility = &pvNameOrchestrator{
+				COCommonInterface: fakeOrchestrator,
+				pvNames:           map[string]string{volumeID: "pv-1"},
+				features:          map[string]bool{common.SnapshotCascadeDelete: test.cascadeFSS},
+			}
+			c := &controller{
+				k8sClient:         fake.NewSimpleClientset(append(test.pvcs, newPV(test.annotation))...),
+				snapshotterClient: snapshotclientfake.NewSimpleClientset(content.DeepCopy(), snapshot.DeepCopy()),
+			}
+			volumeManager := unittestcommon.NewFakeVolumeManager(nil)
+			volumeManager.Snapshots[volumeID] = []string{"snapshot-1", "snapshot-2"}
+
+			_, err := handleVolumeSnapshotsOnDelete(ctx, c, volumeManager, volumeID, []*csi.Snapshot{
+				{SnapshotId: boundSnapshotID, SourceVolumeId: volumeID},
+				{SnapshotId: orphanSnapshotID, SourceVolumeId: volumeID},
+			})
+			assert.Equal(t, test.expectedCode, status.Code(err))
+			assert.Equal(t, test.expectedSnapshots, volumeManager.Snapshots[volumeID])
+
+			_, err = c.snapshotterClient.SnapshotV1().VolumeSnapshots(volumeSnapshotNs).Get(ctx, volumeSnapshot,
+				metav1.GetOptions{})
+			assert.Equal(t, test.expectVSDeleted, apierrors.IsNotFound(err))
+			gotContent, err := c.snapshotterClient.SnapshotV1().VolumeSnapshotContents().Get(ctx, content.Name,
+				metav1.GetOptions{})
+			assert.NoError(t, err)
+			if test.expectDeletePolicy != "" {
+				assert.Equal(t, test.expectDeletePolicy, gotContent.Spec.DeletionPolicy)
+			}
+		})
+	}
+}
+
+func TestVolumeArchival(t *testing.T) {
+	ctx := context.Background()
+	volumeManager := unittestcommon.NewFakeVolumeManager(map[string]map[string]string{
+		// The volume was restored from an earlier archival.
+		"volume-1": {common.VStorageObjectMetadataRestoredBy: "default/restore-1"},
+	})
+	volumeManager.Volumes["volume-1"] = &cnstypes.CnsVolume{
+		VolumeId:        cnstypes.CnsVolumeId{Id: "volume-1"},
+		StoragePolicyId: "policy-1",
+		Metadata: cnstypes.CnsVolumeMetadata{
+			EntityMetadata: []cnstypes.BaseCnsEntityMetadata{
+				&cnstypes.CnsKubernetesEntityMetadata{
+					CnsEntityMetadata: cnstypes.CnsEntityMetadata{
+						EntityName: "pvc-1",
+						Labels:     []vimtypes.KeyValue{{Key: "app", Value: "db"}},
+					},
+					EntityType: string(cnstypes.CnsKubernetesEntityTypePVC),
+				},
+			},
+		},
+	}
+	volCaps := []*csi.VolumeCapability{{
+		AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{FsType: "XFS"}},
+	}}
+
+	assert.NoError(t, markVolumeForArchival(ctx, volumeManager, "volume-1", volCaps))
+	assert.Equal(t, "xfs", volumeManager.Metadata["volume-1"][common.VStorageObjectMetadataFsType])
+	marked, err := isVolumeMarkedForArchival(ctx, volumeManager, "volume-1")
+	assert.NoError(t, err)
+	assert.True(t, marked)
+	marked, err = isVolumeMarkedForArchival(ctx, volumeManager, "volume-2")
+	assert.NoError(t, err)
+	assert.False(t, marked)
+
+	c := &controller{
+		managers: &common.Managers{
+			CnsConfig: &cnsconfig.Config{Archive: cnsconfig.ArchiveConfig{RetentionInHours: 24}},
+		},
+	}
+	_, err = archiveVolume(ctx, c, "vc-1", volumeManager, "volume-1")
+	assert.NoError(t, err)
+	// The volume is removed from CNS while its disk is kept with the metadata
+	// to restore it.
+	assert.NotContains(t, volumeManager.Volumes, "volume-1")
+	assert.Empty(t, volumeManager.DeletedVolumes)
+	metadata := volumeManager.Metadata["volume-1"]
+	_, err = time.Parse(time.RFC3339, metadata[common.VStorageObjectMetadataArchivedAt])
+	assert.NoError(t, err)
+	assert.Equal(t, "policy-1", metadata[common.VStorageObjectMetadataStoragePolicyID])
+	assert.Equal(t, `{"app":"db"}`, metadata[common.VStorageObjectMetadataPVCLabels])
+	assert.NotContains(t, metadata, common.VStorageObjectMetadataRestoredBy)
+}
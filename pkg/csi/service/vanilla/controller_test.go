@@ -39,6 +39,7 @@ import (
 	clientset "k8s.io/client-go/kubernetes"
 	testclient "k8s.io/client-go/kubernetes/fake"
 
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/node"
@@ -177,6 +178,10 @@ func (f *FakeAuthManager) ResetvCenterInstance(ctx context.Context, vCenter *cns
 	f.vcenter = vCenter
 }
 
+func (f *FakeAuthManager) ValidateStoragePolicy(ctx context.Context, storagePolicyName string) (string, error) {
+	return "", nil
+}
+
 var vcsimParams = unittestcommon.VcsimParams{
 	Datacenters:     1,
 	Clusters:        1,
@@ -2552,3 +2557,33 @@ func TestCreateSnapshotWithCnsSnapshotCreatedFault(t *testing.T) {
 		t.Fatal("expected error was not received for create snapshot operation.")
 	}
 }
+
+func TestIsPVCDeleted(t *testing.T) {
+	ctx := context.Background()
+	scParams := &common.StorageClassParams{PvcName: "test-pvc", PvcNamespace: "test-ns"}
+	pvName := "pvc-00000000-0000-0000-0000-000000000001"
+	k8sClient := testclient.NewSimpleClientset()
+	deleted, err := isPVCDeleted(ctx, k8sClient, scParams, pvName)
+	if err != nil || !deleted {
+		t.Fatalf("expected missing PVC to be deleted, got deleted: %v, err: %v", deleted, err)
+	}
+
+	pvc := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      scParams.PvcName,
+			Namespace: scParams.PvcNamespace,
+			UID:       "00000000-0000-0000-0000-000000000001",
+		},
+	}
+	k8sClient = testclient.NewSimpleClientset(pvc)
+	deleted, err = isPVCDeleted(ctx, k8sClient, scParams, pvName)
+	if err != nil || deleted {
+		t.Fatalf("expected PVC not to be deleted, got deleted: %v, err: %v", deleted, err)
+	}
+
+	// A PVC created again with the same name has a new UID.
+	deleted, err = isPVCDeleted(ctx, k8sClient, scParams, "pvc-00000000-0000-0000-0000-000000000002")
+	if err != nil || !deleted {
+		t.Fatalf("expected recreated PVC to be deleted, got deleted: %v, err: %v", deleted, err)
+	}
+}
@@ -0,0 +1,377 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vanilla
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+	"google.golang.org/grpc/codes"
+
+	cnsvolume "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	cnsconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+	csifault "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/fault"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+const (
+	// crossVCCloneSourceSuffix is appended to the name of a volume to name
+	// the volume restored from the snapshot on the vCenter of the snapshot.
+	crossVCCloneSourceSuffix = "-xvc-source"
+	// crossVCCloneFolder is the folder of the target datastore the disks
+	// copied across vCenters are placed in.
+	crossVCCloneFolder = "cns-cross-vc"
+
+	crossVCCloneStageRestore  = "restoring snapshot on the source vCenter"
+	crossVCCloneStageCopy     = "copying disk to the target vCenter"
+	crossVCCloneStageRegister = "registering volume on the target vCenter"
+)
+
+// crossVCCloneJobs holds the cross vCenter clone jobs by the name of the
+// volume being created.
+var crossVCCloneJobs sync.Map
+
+// crossVCCloneParams are the parameters to create a volume from a snapshot
+// on a vCenter other than the vCenter of the snapshot.
+type crossVCCloneParams struct {
+	SourceVcenter        *cnsvsphere.VirtualCenter
+	SourceVolumeManager  cnsvolume.Manager
+	SnapshotDatastoreURL string
+	TargetVcenter        *cnsvsphere.VirtualCenter
+	TargetVolumeManager  cnsvolume.Manager
+	TargetDatastores     []*cnsvsphere.DatastoreInfo
+	StoragePolicyID      string
+	CNSConfig            *cnsconfig.Config
+	Spec                 *common.CreateVolumeSpec
+}
+
+// crossVCCloneJob tracks the progress of a cross vCenter clone.
+type crossVCCloneJob struct {
+	params      crossVCCloneParams
+	copiedBytes atomic.Int64
+	totalBytes  atomic.Int64
+
+	lock       sync.Mutex
+	stage      string
+	done       bool
+	volumeInfo *cnsvolume.CnsVolumeInfo
+	faultType  string
+	err        error
+}
+
+// createVolumeFromSnapshotAcrossVCenters creates the volume of the given spec
+// on the target vCenter from a snapshot on the source vCenter. The snapshot
+// is restored to a temporary volume on the source vCenter, whose disk is
+// copied to a datastore of the target vCenter and registered with CNS there.
+// As the copy takes longer than a CreateVolume call, it runs in the
+// background and an Aborted error with its progress is returned until it is
+// done, for the external-provisioner to retry the call. A job is bound to the
+// target vCenter it was started for.
+func createVolumeFromSnapshotAcrossVCenters(ctx context.Context, params crossVCCloneParams) (
+	*cnsvolume.CnsVolumeInfo, string, error) {
+	log := logger.GetLogger(ctx)
+	value, loaded := crossVCCloneJobs.LoadOrStore(params.Spec.Name, &crossVCCloneJob{params: params})
+	job := value.(*crossVCCloneJob)
+	if !loaded {
+		log.Infof("Starting to create volume %q on vCenter %q from snapshot %q on vCenter %q",
+			params.Spec.Name, params.TargetVcenter.Config.Host, params.Spec.ContentSourceSnapshotID,
+			params.SourceVcenter.Config.Host)
+		go job.run()
+		return nil, "", logger.LogNewErrorCodef(log, codes.Aborted,
+			"creating volume %q from snapshot %q on another vCenter has started",
+			params.Spec.Name, params.Spec.ContentSourceSnapshotID)
+	}
+	if targetHost := job.params.TargetVcenter.Config.Host; targetHost != params.TargetVcenter.Config.Host {
+		return nil, csifault.CSIInternalFault, logger.LogNewErrorf(log,
+			"volume %q is being created from snapshot %q on vCenter %q", params.Spec.Name,
+			params.Spec.ContentSourceSnapshotID, targetHost)
+	}
+	job.lock.Lock()
+	defer job.lock.Unlock()
+	if !job.done {
+		return nil, "", logger.LogNewErrorCodef(log, codes.Aborted,
+			"creating volume %q from snapshot %q on another vCenter is in progress: %s",
+			params.Spec.Name, params.Spec.ContentSourceSnapshotID, job.progress())
+	}
+	crossVCCloneJobs.Delete(params.Spec.Name)
+	if job.err != nil {
+		return nil, job.faultType, logger.LogNewErrorf(log,
+			"failed to create volume %q from snapshot %q on another vCenter. Error: %+v",
+			params.Spec.Name, params.Spec.ContentSourceSnapshotID, job.err)
+	}
+	return job.volumeInfo, "", nil
+}
+
+// progress returns the current stage of the job, with the percentage of the
+// disk copied while copying it. The caller must hold the job lock.
+func (job *crossVCCloneJob) progress() string {
+	if job.stage != crossVCCloneStageCopy || job.totalBytes.Load() == 0 {
+		return job.stage
+	}
+	return fmt.Sprintf("%s (%d%%)", job.stage, job.copiedBytes.Load()*100/job.totalBytes.Load())
+}
+
+func (job *crossVCCloneJob) setStage(stage string) {
+	job.lock.Lock()
+	defer job.lock.Unlock()
+	job.stage = stage
+}
+
+// run runs the job to completion, recording its result.
+func (job *crossVCCloneJob) run() {
+	ctx, log := logger.GetNewContextWithLogger()
+	volumeInfo, faultType, err := job.clone(ctx)
+	if err != nil {
+		log.Errorf("failed to create volume %q across vCenters. Error: %+v", job.params.Spec.Name, err)
+		if faultType == "" {
+			faultType = csifault.CSIInternalFault
+		}
+	}
+	job.lock.Lock()
+	defer job.lock.Unlock()
+	job.done = true
+	job.volumeInfo, job.faultType, job.err = volumeInfo, faultType, err
+}
+
+func (job *crossVCCloneJob) clone(ctx context.Context) (*cnsvolume.CnsVolumeInfo, string, error) {
+	log := logger.GetLogger(ctx)
+	params := job.params
+	targetDatastore, err := getCrossVCCloneTargetDatastore(ctx, params.TargetDatastores)
+	if err != nil {
+		return nil, csifault.CSIInternalFault, err
+	}
+	// A volume registered in an earlier attempt is returned as is.
+	volumeInfo, err := common.GetVolumeByName(ctx, params.TargetVolumeManager, params.Spec.Name,
+		params.CNSConfig.Global.ClusterID)
+	if err != nil {
+		return nil, csifault.CSIInternalFault, logger.LogNewErrorf(log,
+			"failed to query volume %q. Err: %v", params.Spec.Name, err)
+	}
+	if volumeInfo != nil {
+		log.Infof("Volume %q is already registered with id %q", params.Spec.Name, volumeInfo.VolumeID.Id)
+		return volumeInfo, "", nil
+	}
+
+	job.setStage(crossVCCloneStageRestore)
+	sourceDatastore, err := getDatastoreInfoByURL(ctx, params.SourceVcenter, params.SnapshotDatastoreURL)
+	if err != nil {
+		return nil, csifault.CSIInternalFault, err
+	}
+	if _, dsType, err := sourceDatastore.GetDatastoreURLAndType(ctx); err != nil {
+		return nil, csifault.CSIInternalFault, err
+	} else if dsType == common.VsanDatastoreType {
+		return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorf(log,
+			"snapshot %q is on vSAN datastore %q, whose disks cannot be copied across vCenters",
+			params.Spec.ContentSourceSnapshotID, params.SnapshotDatastoreURL)
+	}
+	sourceSpec := *params.Spec
+	sourceSpec.Name = params.Spec.Name + crossVCCloneSourceSuffix
+	sourceVolume, faultType, err := common.CreateBlockVolumeUtilForMultiVC(ctx,
+		common.VanillaCreateBlockVolParamsForMultiVC{
+			Vcenter:              params.SourceVcenter,
+			VolumeManager:        params.SourceVolumeManager,
+			CNSConfig:            params.CNSConfig,
+			Spec:                 &sourceSpec,
+			SharedDatastores:     []*cnsvsphere.DatastoreInfo{sourceDatastore},
+			SnapshotDatastoreURL: params.SnapshotDatastoreURL,
+			ClusterFlavor:        cnstypes.CnsClusterFlavorVanilla,
+		})
+	if err != nil {
+		return nil, faultType, err
+	}
+	defer func() {
+		// The disk of the restored volume is not needed once it is copied.
+		if _, err := common.DeleteVolumeUtil(ctx, params.SourceVolumeManager, sourceVolume.VolumeID.Id,
+			true); err != nil {
+			log.Warnf("failed to delete volume %q restored on vCenter %q for volume %q. Error: %+v",
+				sourceVolume.VolumeID.Id, params.SourceVcenter.Config.Host, params.Spec.Name, err)
+		}
+	}()
+
+	job.setStage(crossVCCloneStageCopy)
+	sourceDiskPath, err := getVolumeDiskPath(ctx, params.SourceVolumeManager, sourceVolume.VolumeID.Id)
+	if err != nil {
+		return nil, csifault.CSIInternalFault, err
+	}
+	targetDiskPath := path.Join(crossVCCloneFolder, params.Spec.Name, path.Base(sourceDiskPath))
+	err = job.copyDisk(ctx, sourceDatastore, sourceDiskPath, targetDatastore, targetDiskPath)
+	if err != nil {
+		return nil, csifault.CSIInternalFault, err
+	}
+
+	job.setStage(crossVCCloneStageRegister)
+	clusterID := params.CNSConfig.Global.ClusterID
+	containerCluster := cnsvsphere.GetContainerCluster(clusterID,
+		params.CNSConfig.VirtualCenter[params.TargetVcenter.Config.Host].User, cnstypes.CnsClusterFlavorVanilla,
+		params.CNSConfig.Global.ClusterDistribution)
+	createSpec := &cnstypes.CnsVolumeCreateSpec{
+		Name:       params.Spec.Name,
+		VolumeType: params.Spec.VolumeType,
+		Datastores: []types.ManagedObjectReference{targetDatastore.Reference()},
+		BackingObjectDetails: &cnstypes.CnsBlockBackingDetails{
+			BackingDiskUrlPath: getDiskURLPath(params.TargetVcenter, targetDatastore, targetDiskPath),
+		},
+		Metadata: cnstypes.CnsVolumeMetadata{
+			ContainerCluster:      containerCluster,
+			ContainerClusterArray: []cnstypes.CnsContainerCluster{containerCluster},
+		},
+	}
+	if params.StoragePolicyID != "" {
+		createSpec.Profile = append(createSpec.Profile, &types.VirtualMachineDefinedProfileSpec{
+			ProfileId: params.StoragePolicyID,
+		})
+	}
+	volumeInfo, faultType, err = params.TargetVolumeManager.CreateVolume(ctx, createSpec, nil)
+	if err != nil {
+		return nil, faultType, logger.LogNewErrorf(log, "failed to register disk %q as volume %q on vCenter %q. "+
+			"Error: %+v", targetDiskPath, params.Spec.Name, params.TargetVcenter.Config.Host, err)
+	}
+	log.Infof("Created volume %q on vCenter %q from snapshot %q on vCenter %q", volumeInfo.VolumeID.Id,
+		params.TargetVcenter.Config.Host, params.Spec.ContentSourceSnapshotID, params.SourceVcenter.Config.Host)
+	return volumeInfo, "", nil
+}
+
+// copyDisk copies the descriptor and the extent of a disk from the source
+// datastore to the target datastore, counting the copied bytes.
+func (job *crossVCCloneJob) copyDisk(ctx context.Context, source *cnsvsphere.DatastoreInfo, sourcePath string,
+	target *cnsvsphere.DatastoreInfo, targetPath string) error {
+	log := logger.GetLogger(ctx)
+	fileManager := object.NewFileManager(job.params.TargetVcenter.Client.Client)
+	folderPath := target.Path(path.Dir(targetPath))
+	err := fileManager.MakeDirectory(ctx, folderPath, target.Datastore.Datacenter.Datacenter, true)
+	if err != nil && !common.IsFileAlreadyExistsError(err) {
+		return logger.LogNewErrorf(log, "failed to create folder %q. Err: %v", folderPath, err)
+	}
+	// The extent is copied first, so that a partially copied disk has no
+	// descriptor.
+	extentSuffix := "-flat.vmdk"
+	files := [][2]string{
+		{strings.TrimSuffix(sourcePath, ".vmdk") + extentSuffix,
+			strings.TrimSuffix(targetPath, ".vmdk") + extentSuffix},
+		{sourcePath, targetPath},
+	}
+	for _, file := range files {
+		reader, size, err := source.Download(ctx, file[0], nil)
+		if err != nil {
+			return logger.LogNewErrorf(log, "failed to download %q from datastore %q. Err: %v",
+				file[0], source.Info.Url, err)
+		}
+		job.totalBytes.Add(size)
+		err = target.Upload(ctx, &countingReader{reader: reader, count: &job.copiedBytes}, file[1],
+			&soap.Upload{ContentLength: size})
+		reader.Close()
+		if err != nil {
+			return logger.LogNewErrorf(log, "failed to upload %q to datastore %q. Err: %v",
+				file[1], target.Info.Url, err)
+		}
+		log.Infof("Copied %q of datastore %q to %q of datastore %q", file[0], source.Info.Url,
+			file[1], target.Info.Url)
+	}
+	return nil
+}
+
+// countingReader counts the bytes read from a reader.
+type countingReader struct {
+	reader io.Reader
+	count  *atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count.Add(int64(n))
+	return n, err
+}
+
+// getCrossVCCloneTargetDatastore returns the first datastore of the given
+// datastores a disk can be copied to, skipping vSAN datastores.
+func getCrossVCCloneTargetDatastore(ctx context.Context, datastores []*cnsvsphere.DatastoreInfo) (
+	*cnsvsphere.DatastoreInfo, error) {
+	log := logger.GetLogger(ctx)
+	for _, ds := range datastores {
+		if ds.Datastore == nil || ds.Datastore.Datacenter == nil {
+			continue
+		}
+		_, dsType, err := ds.GetDatastoreURLAndType(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if dsType != common.VsanDatastoreType {
+			return ds, nil
+		}
+	}
+	return nil, logger.LogNewErrorf(log, "no datastore other than vSAN datastores found to copy the disk to")
+}
+
+// getDatastoreInfoByURL returns the datastore with the given URL in the
+// datacenters of the given vCenter.
+func getDatastoreInfoByURL(ctx context.Context, vc *cnsvsphere.VirtualCenter, datastoreURL string) (
+	*cnsvsphere.DatastoreInfo, error) {
+	log := logger.GetLogger(ctx)
+	datacenters, err := vc.GetDatacenters(ctx)
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to get datacenters of vCenter %q. Err: %v",
+			vc.Config.Host, err)
+	}
+	for _, dc := range datacenters {
+		dsInfo, err := dc.GetDatastoreInfoByURL(ctx, datastoreURL)
+		if err == nil {
+			return dsInfo, nil
+		}
+	}
+	return nil, logger.LogNewErrorf(log, "failed to find datastore %q in vCenter %q", datastoreURL, vc.Config.Host)
+}
+
+// getVolumeDiskPath returns the path of the disk of a block volume relative
+// to its datastore.
+func getVolumeDiskPath(ctx context.Context, volumeManager cnsvolume.Manager, volumeID string) (string, error) {
+	log := logger.GetLogger(ctx)
+	vStorageObject, err := volumeManager.RetrieveVStorageObject(ctx, volumeID)
+	if err != nil {
+		return "", logger.LogNewErrorf(log, "failed to retrieve virtual disk of volume %q. Err: %v", volumeID, err)
+	}
+	backing, ok := vStorageObject.Config.Backing.(types.BaseBaseConfigInfoFileBackingInfo)
+	if !ok {
+		return "", logger.LogNewErrorf(log, "volume %q is not backed by a disk file", volumeID)
+	}
+	var dsPath object.DatastorePath
+	if !dsPath.FromString(backing.GetBaseConfigInfoFileBackingInfo().FilePath) {
+		return "", logger.LogNewErrorf(log, "failed to parse the disk path %q of volume %q",
+			backing.GetBaseConfigInfoFileBackingInfo().FilePath, volumeID)
+	}
+	return dsPath.Path, nil
+}
+
+// getDiskURLPath returns the URL path of a disk to register it with CNS.
+func getDiskURLPath(vc *cnsvsphere.VirtualCenter, dsInfo *cnsvsphere.DatastoreInfo, diskPath string) string {
+	// Format:
+	// https://<vc_ip>/folder/<vmdk_path>?dcPath=<datacenter-path>&dsName=<datastoreName>
+	dcPath := strings.TrimPrefix(dsInfo.Datastore.Datacenter.InventoryPath, "/")
+	return "https://" + vc.Config.Host + "/folder/" + diskPath + "?dcPath=" + url.PathEscape(dcPath) +
+		"&dsName=" + url.PathEscape(dsInfo.Info.Name)
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vanilla
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	csifault "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/fault"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
+)
+
+// vimFaultResourceInUse is the fault type returned by AttachVolume when the
+// volume is attached to another node VM.
+const vimFaultResourceInUse = csifault.VimFaultPrefix + "ResourceInUse"
+
+// volumeHolder is a node the volume is attached to.
+type volumeHolder struct {
+	nodeName string
+	// detaching is true if the VolumeAttachment of the volume on the node is
+	// being deleted.
+	detaching bool
+}
+
+// isMultiAttachFault returns true if the attach of a volume published with
+// the given capability failed with faultType as the volume is attached to
+// another node, and the access mode of the volume doesn't allow it.
+func isMultiAttachFault(volCap *csi.VolumeCapability, faultType string) bool {
+	if faultType != vimFaultResourceInUse {
+		return false
+	}
+	switch volCap.GetAccessMode().GetMode() {
+	case csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+		csi.VolumeCapability_AccessMode_SINGLE_NODE_READER_ONLY,
+		csi.VolumeCapability_AccessMode_SINGLE_NODE_SINGLE_WRITER,
+		csi.VolumeCapability_AccessMode_SINGLE_NODE_MULTI_WRITER:
+		return true
+	}
+	return false
+}
+
+// getVolumeHolders returns the nodes other than nodeName holding the volume
+// of PV pvName. knownNodes are the nodes of the volume known to the container
+// orchestrator, which are completed with the nodes of the VolumeAttachments
+// of the PV, as the container orchestrator only tracks them when the
+// list-volumes FSS is enabled.
+func getVolumeHolders(vas []storagev1.VolumeAttachment, pvName, nodeName string,
+	knownNodes []string) []volumeHolder {
+	var holders []volumeHolder
+	holderIndex := make(map[string]int)
+	addHolder := func(name string) *volumeHolder {
+		if i, ok := holderIndex[name]; ok {
+			return &holders[i]
+		}
+		holderIndex[name] = len(holders)
+		holders = append(holders, volumeHolder{nodeName: name})
+		return &holders[len(holders)-1]
+	}
+	for _, name := range knownNodes {
+		if name != nodeName {
+			addHolder(name)
+		}
+	}
+	for _, va := range vas {
+		if va.Spec.Source.PersistentVolumeName == nil || *va.Spec.Source.PersistentVolumeName != pvName ||
+			va.Spec.NodeName == nodeName {
+			continue
+		}
+		// VolumeAttachments still being attached don't hold the volume.
+		if !va.Status.Attached && va.DeletionTimestamp == nil {
+			continue
+		}
+		addHolder(va.Spec.NodeName).detaching = va.DeletionTimestamp != nil
+	}
+	return holders
+}
+
+// multiAttachMessage describes why the volume can't be attached to nodeName
+// while it is held by the given nodes.
+func multiAttachMessage(volumeID, nodeName string, holders []volumeHolder) string {
+	if len(holders) == 0 {
+		return fmt.Sprintf("volume %q can't be attached to node %q as it is attached to another node",
+			volumeID, nodeName)
+	}
+	var nodes []string
+	detaching := true
+	for _, holder := range holders {
+		if holder.detaching {
+			nodes = append(nodes, holder.nodeName+" (detach in progress)")
+		} else {
+			nodes = append(nodes, holder.nodeName)
+			detaching = false
+		}
+	}
+	msg := fmt.Sprintf("volume %q can't be attached to node %q as it is attached to node(s) %s",
+		volumeID, nodeName, strings.Join(nodes, ", "))
+	if detaching {
+		return msg + ". The attach is retried once the volume is detached"
+	}
+	return msg + ". Delete the pods using the volume on these nodes to release it"
+}
+
+// diagnoseMultiAttach is called by ControllerPublishVolume when the attach of
+// a volume failed as it is attached to another node. It returns a
+// FailedPrecondition error naming the nodes holding the volume and whether
+// they are detaching it, and records it as a warning event on the PVC of the
+// volume.
+func (c *controller) diagnoseMultiAttach(ctx context.Context, req *csi.ControllerPublishVolumeRequest,
+	attachErr error) error {
+	log := logger.GetLogger(ctx)
+	nodeName, err := c.nodeMgr.GetNodeNameByUUID(ctx, req.NodeId)
+	if err != nil {
+		log.Warnf("failed to get the name of node %q. Error: %v", req.NodeId, err)
+		nodeName = req.NodeId
+	}
+	var holders []volumeHolder
+	var pvc *v1.PersistentVolumeClaim
+	k8sClient, err := k8s.NewClient(ctx)
+	if err != nil {
+		log.Warnf("failed to create kubernetes client to look up the nodes of volume %q. Error: %v",
+			req.VolumeId, err)
+	} else if pvName, found := commonco.ContainerOrchestratorUtility.GetPVNameFromCSIVolumeID(req.VolumeId); found {
+		knownNodes := commonco.ContainerOrchestratorUtility.GetNodesForVolumes(ctx,
+			[]string{req.VolumeId})[req.VolumeId]
+		var vas []storagev1.VolumeAttachment
+		vaList, err := k8sClient.StorageV1().VolumeAttachments().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			log.Warnf("failed to list VolumeAttachments to look up the nodes of volume %q. Error: %v",
+				req.VolumeId, err)
+		} else {
+			vas = vaList.Items
+		}
+		holders = getVolumeHolders(vas, pvName, nodeName, knownNodes)
+
+		pv, err := k8sClient.CoreV1().PersistentVolumes().Get(ctx, pvName, metav1.GetOptions{})
+		if err != nil {
+			log.Warnf("failed to get PV %q of volume %q. Error: %v", pvName, req.VolumeId, err)
+		} else if pv.Spec.ClaimRef != nil {
+			pvc, err = k8sClient.CoreV1().PersistentVolumeClaims(pv.Spec.ClaimRef.Namespace).Get(ctx,
+				pv.Spec.ClaimRef.Name, metav1.GetOptions{})
+			if err != nil {
+				log.Warnf("failed to get PVC %s/%s of volume %q. Error: %v", pv.Spec.ClaimRef.Namespace,
+					pv.Spec.ClaimRef.Name, req.VolumeId, err)
+				pvc = nil
+			}
+		}
+	}
+	msg := multiAttachMessage(req.VolumeId, nodeName, holders)
+	if pvc != nil {
+		recordPVCEvent(ctx, k8sClient, pvc, v1.EventTypeWarning, "VolumeAttachedElsewhere", msg)
+	}
+	return logger.LogNewErrorCodef(log, codes.FailedPrecondition, "%s. Error: %v", msg, attachErr)
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vanilla
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIsMultiAttachFault(t *testing.T) {
+	newVolCap := func(mode csi.VolumeCapability_AccessMode_Mode) *csi.VolumeCapability {
+		return &csi.VolumeCapability{AccessMode: &csi.VolumeCapability_AccessMode{Mode: mode}}
+	}
+	if !isMultiAttachFault(newVolCap(csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER), vimFaultResourceInUse) {
+		t.Errorf("expected ResourceInUse to be a multi-attach fault for a single node volume")
+	}
+	if isMultiAttachFault(newVolCap(csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER), vimFaultResourceInUse) {
+		t.Errorf("expected ResourceInUse not to be a multi-attach fault for a multi node volume")
+	}
+	if isMultiAttachFault(newVolCap(csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER), "vim.fault.NotFound") {
+		t.Errorf("expected NotFound not to be a multi-attach fault")
+	}
+}
+
+func TestGetVolumeHolders(t *testing.T) {
+	newVA := func(pvName, nodeName string, attached, deleted bool) storagev1.VolumeAttachment {
+		va := storagev1.VolumeAttachment{
+			Spec: storagev1.VolumeAttachmentSpec{
+				NodeName: nodeName,
+				Source:   storagev1.VolumeAttachmentSource{PersistentVolumeName: &pvName},
+			},
+			Status: storagev1.VolumeAttachmentStatus{Attached: attached},
+		}
+		if deleted {
+			va.DeletionTimestamp = &metav1.Time{}
+		}
+		return va
+	}
+	vas := []storagev1.VolumeAttachment{
+		newVA("pv-1", "node-1", true, true),
+		newVA("pv-1", "node-2", false, false),
+		newVA("pv-1", "node-3", true, false),
+		newVA("pv-1", "node-4", true, false),
+		newVA("pv-2", "node-5", true, false),
+	}
+	holders := getVolumeHolders(vas, "pv-1", "node-4", []string{"node-1", "node-4"})
+	expected := []volumeHolder{
+		{nodeName: "node-1", detaching: true},
+		{nodeName: "node-3"},
+	}
+	if !reflect.DeepEqual(holders, expected) {
+		t.Errorf("expected holders %v, got %v", expected, holders)
+	}
+}
+
+func TestMultiAttachMessage(t *testing.T) {
+	msg := multiAttachMessage("vol-1", "node-2", nil)
+	if !strings.Contains(msg, "attached to another node") {
+		t.Errorf("unexpected message without known holders: %s", msg)
+	}
+	msg = multiAttachMessage("vol-1", "node-2", []volumeHolder{{nodeName: "node-1", detaching: true}})
+	if !strings.Contains(msg, "node-1 (detach in progress)") || !strings.Contains(msg, "retried once") {
+		t.Errorf("unexpected message for a detaching holder: %s", msg)
+	}
+	msg = multiAttachMessage("vol-1", "node-2", []volumeHolder{
+		{nodeName: "node-1", detaching: true},
+		{nodeName: "node-3"},
+	})
+	if !strings.Contains(msg, "node-1 (detach in progress), node-3") || !strings.Contains(msg, "Delete the pods") {
+		t.Errorf("unexpected message for an attached holder: %s", msg)
+	}
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vanilla
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
+)
+
+// nodePluginRequirement is a feature of the node plugin needed to use some
+// volumes on a node.
+type nodePluginRequirement struct {
+	// feature describes the feature in the errors and events.
+	feature string
+	// minVersion is the first node plugin version providing the feature.
+	minVersion string
+	// requiredBy returns true if the published volume needs the feature.
+	requiredBy func(ctx context.Context, req *csi.ControllerPublishVolumeRequest) bool
+}
+
+// nodePluginRequirements are the features checked by checkNodePluginVersion.
+var nodePluginRequirements = []nodePluginRequirement{
+	{
+		feature:    "file volumes",
+		minVersion: "v2.0.0",
+		requiredBy: func(ctx context.Context, req *csi.ControllerPublishVolumeRequest) bool {
+			return common.IsFileVolumeRequest(ctx, []*csi.VolumeCapability{req.GetVolumeCapability()})
+		},
+	},
+	{
+		feature:    "raw block volumes",
+		minVersion: "v2.3.0",
+		requiredBy: func(ctx context.Context, req *csi.ControllerPublishVolumeRequest) bool {
+			return req.GetVolumeCapability().GetBlock() != nil
+		},
+	},
+}
+
+// checkNodePluginVersion compares the version of the node plugin, published
+// on the Node object, with the versions needed by the published volume. It
+// returns a FailedPrecondition error, and records a warning event on the PV,
+// if the node plugin is too old, instead of letting the node plugin fail to
+// mount the volume. The check is skipped if the node can't be looked up.
+func (c *controller) checkNodePluginVersion(ctx context.Context, req *csi.ControllerPublishVolumeRequest) error {
+	log := logger.GetLogger(ctx)
+	nodeName, err := c.nodeMgr.GetNodeNameByUUID(ctx, req.NodeId)
+	if err != nil {
+		log.Warnf("failed to get the name of node %q, skipping the node plugin version check. Error: %v",
+			req.NodeId, err)
+		return nil
+	}
+	k8sClient, err := k8s.NewClient(ctx)
+	if err != nil {
+		log.Warnf("failed to create kubernetes client, skipping the node plugin version check. Error: %v", err)
+		return nil
+	}
+	node, err := k8sClient.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	if err != nil {
+		log.Warnf("failed to get node %q, skipping the node plugin version check. Error: %v", nodeName, err)
+		return nil
+	}
+	nodePluginVersion := node.Annotations[common.NodePluginVersionAnnotation()]
+	if c.version != "" && nodePluginVersion != c.version {
+		log.Infof("Node plugin version %q on node %q differs from the controller version %q",
+			nodePluginVersion, nodeName, c.version)
+	}
+	for _, requirement := range nodePluginRequirements {
+		if !requirement.requiredBy(ctx, req) || !common.IsNodePluginOlderThan(nodePluginVersion, requirement.minVersion) {
+			continue
+		}
+		if nodePluginVersion == "" {
+			nodePluginVersion = "unknown"
+		}
+		msg := fmt.Sprintf("volume %q can't be published to node %q: %s need node plugin %s or later, "+
+			"but the node runs node plugin version %s. Update the node plugin on the node",
+			req.VolumeId, nodeName, requirement.feature, requirement.minVersion, nodePluginVersion)
+		if pvName, found := commonco.ContainerOrchestratorUtility.GetPVNameFromCSIVolumeID(req.VolumeId); found {
+			pv, err := k8sClient.CoreV1().PersistentVolumes().Get(ctx, pvName, metav1.GetOptions{})
+			if err != nil {
+				log.Warnf("failed to get PV %q of volume %q. Error: %v", pvName, req.VolumeId, err)
+			} else {
+				recordPVEvent(ctx, k8sClient, pv, v1.EventTypeWarning, "NodePluginTooOld", msg)
+			}
+		}
+		return logger.LogNewErrorCode(log, codes.FailedPrecondition, msg)
+	}
+	return nil
+}
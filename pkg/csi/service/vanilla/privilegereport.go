@@ -0,0 +1,153 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vanilla
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+const (
+	// privilegeReportHandlerPath is the path of the vCenter privilege report
+	// on the admin server of the controller.
+	privilegeReportHandlerPath = "/debug/vcenter-privileges"
+	// privilegeReportParamVCenter selects the vCenter to report on.
+	privilegeReportParamVCenter = "vcenter"
+	// privilegeReportCacheTTL is how long a generated privilege report is
+	// served before it is generated again.
+	privilegeReportCacheTTL = 10 * time.Minute
+)
+
+// privilegeReportHandler serves the report of the vCenter privileges missing
+// to the CSI VC user.
+//
+// GET returns a report per vCenter, listing the datastores, clusters and
+// root folder the user is missing privileges on. The optional "vcenter"
+// parameter restricts the report to a single vCenter. The reports are cached
+// for privilegeReportCacheTTL, as generating them queries the privileges of
+// the user on every datastore and cluster of vCenter.
+type privilegeReportHandler struct {
+	c *controller
+	// group shares the generation of the report of a vCenter between
+	// concurrent requests.
+	group singleflight.Group
+	// mu guards reports.
+	mu sync.Mutex
+	// reports holds the last generated reports, keyed by vCenter host.
+	reports map[string]cachedPrivilegeReport
+}
+
+// cachedPrivilegeReport is a privilege report along with its generation
+// time.
+type cachedPrivilegeReport struct {
+	report      *common.PrivilegeReport
+	generatedAt time.Time
+}
+
+// newPrivilegeReportHandler returns the privilege report handler of the given
+// controller.
+func newPrivilegeReportHandler(c *controller) *privilegeReportHandler {
+	return &privilegeReportHandler{c: c, reports: make(map[string]cachedPrivilegeReport)}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *privilegeReportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx, log := logger.GetNewContextWithLogger()
+	var vcenters []*cnsvsphere.VirtualCenter
+	if multivCenterCSITopologyEnabled {
+		var err error
+		vcenters, err = common.GetVCenters(ctx, h.c.managers)
+		if err != nil {
+			log.Errorf("failed to get vCenters. Error: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	} else {
+		vcenter, err := h.c.manager.VcenterManager.GetVirtualCenter(ctx, h.c.manager.VcenterConfig.Host)
+		if err != nil {
+			log.Errorf("failed to get vCenter. Error: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		vcenters = append(vcenters, vcenter)
+	}
+
+	vcHost := r.URL.Query().Get(privilegeReportParamVCenter)
+	reports := make([]*common.PrivilegeReport, 0, len(vcenters))
+	for _, vcenter := range vcenters {
+		if vcHost != "" && vcenter.Config.Host != vcHost {
+			continue
+		}
+		report, err := h.getReport(ctx, vcenter, func(ctx context.Context) (*common.PrivilegeReport, error) {
+			return common.GenerateVCenterPrivilegeReport(ctx, vcenter)
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		reports = append(reports, report)
+	}
+	if vcHost != "" && len(reports) == 0 {
+		http.Error(w, "unknown vCenter "+vcHost, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(reports); err != nil {
+		log.Errorf("failed to write privilege report with error: %v", err)
+	}
+}
+
+// getReport returns the cached privilege report of the given vCenter, or the
+// report returned by generate if the cached one expired.
+func (h *privilegeReportHandler) getReport(ctx context.Context, vcenter *cnsvsphere.VirtualCenter,
+	generate func(ctx context.Context) (*common.PrivilegeReport, error)) (*common.PrivilegeReport, error) {
+	vcHost := vcenter.Config.Host
+	h.mu.Lock()
+	cached, ok := h.reports[vcHost]
+	h.mu.Unlock()
+	if ok && time.Since(cached.generatedAt) < privilegeReportCacheTTL {
+		return cached.report, nil
+	}
+	report, err, _ := h.group.Do(vcHost, func() (interface{}, error) {
+		report, err := generate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		h.mu.Lock()
+		h.reports[vcHost] = cachedPrivilegeReport{report: report, generatedAt: time.Now()}
+		h.mu.Unlock()
+		return report, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report.(*common.PrivilegeReport), nil
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vanilla
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+)
+
+func TestPrivilegeReportCache(t *testing.T) {
+	ctx := context.Background()
+	h := newPrivilegeReportHandler(&controller{})
+	vcenter := &cnsvsphere.VirtualCenter{Config: &cnsvsphere.VirtualCenterConfig{Host: "vc-1"}}
+	var generations int
+	generate := func(ctx context.Context) (*common.PrivilegeReport, error) {
+		generations++
+		return &common.PrivilegeReport{VCenter: "vc-1"}, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		report, err := h.getReport(ctx, vcenter, generate)
+		if err != nil || report.VCenter != "vc-1" {
+			t.Fatalf("unexpected report %v, error: %v", report, err)
+		}
+	}
+	if generations != 1 {
+		t.Errorf("expected the report to be generated once, got %d generations", generations)
+	}
+
+	// Expired reports are generated again, and failed generations aren't
+	// cached.
+	h.reports["vc-1"] = cachedPrivilegeReport{
+		report:      h.reports["vc-1"].report,
+		generatedAt: time.Now().Add(-privilegeReportCacheTTL),
+	}
+	if _, err := h.getReport(ctx, vcenter, func(ctx context.Context) (*common.PrivilegeReport, error) {
+		return nil, errors.New("vCenter is unreachable")
+	}); err == nil {
+		t.Errorf("expected the generation error of an expired report")
+	}
+	if _, err := h.getReport(ctx, vcenter, generate); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if generations != 2 {
+		t.Errorf("expected the expired report to be generated again, got %d generations", generations)
+	}
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vanilla
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/vmware/govmomi/vim25/types"
+	"google.golang.org/grpc/codes"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apitypes "k8s.io/apimachinery/pkg/types"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	csifault "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/fault"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
+)
+
+// provisioningDryRunResult is the decision of the dry run of the provisioning
+// of a block volume, reported in the AnnProvisioningDryRunResult annotation
+// of its PVC.
+type provisioningDryRunResult struct {
+	VCenter         string `json:"vCenter"`
+	CapacityMB      int64  `json:"capacityMB"`
+	StoragePolicyID string `json:"storagePolicyID,omitempty"`
+	// CandidateDatastores are the URLs of the datastores accessible from the
+	// nodes of the topology requirement of the volume, and not suspended.
+	CandidateDatastores []string `json:"candidateDatastores"`
+	// CompatibleDatastores are the URLs of the candidate datastores
+	// compatible with the storage policy and with enough free space.
+	CompatibleDatastores []string `json:"compatibleDatastores"`
+	// SelectedDatastore is the URL of the compatible datastore with the
+	// most free space.
+	SelectedDatastore string `json:"selectedDatastore,omitempty"`
+}
+
+// runProvisioningDryRun runs the dry run of the provisioning of a block volume
+// on the given shared datastores if its PVC is annotated with
+// AnnProvisioningDryRun. The decision is reported on the PVC, and the fault
+// type and error to fail CreateVolume with are returned. No error is returned
+// if no dry run is requested. The PVC of the volume is only known when the
+// external-provisioner passes it in the CreateVolume parameters, with its
+// --extra-create-metadata flag.
+func runProvisioningDryRun(ctx context.Context, vc *cnsvsphere.VirtualCenter, scParams *common.StorageClassParams,
+	volSizeMB int64, sharedDatastores []*cnsvsphere.DatastoreInfo) (string, error) {
+	log := logger.GetLogger(ctx)
+	if scParams.PvcName == "" || scParams.PvcNamespace == "" ||
+		!commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.ProvisioningDryRun) {
+		return "", nil
+	}
+	k8sClient, err := k8s.NewClient(ctx)
+	if err != nil {
+		return csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to create kubernetes client to look up PVC %s/%s. Error: %v",
+			scParams.PvcNamespace, scParams.PvcName, err)
+	}
+	pvc, err := k8sClient.CoreV1().PersistentVolumeClaims(scParams.PvcNamespace).Get(ctx, scParams.PvcName,
+		metav1.GetOptions{})
+	if err != nil {
+		return csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal, "failed to get PVC %s/%s. Error: %v",
+			scParams.PvcNamespace, scParams.PvcName, err)
+	}
+	if strings.TrimSpace(pvc.Annotations[common.AnnProvisioningDryRun]) != "true" {
+		return "", nil
+	}
+
+	result, err := planProvisioningDryRun(ctx, vc, scParams, volSizeMB, sharedDatastores)
+	if err != nil {
+		recordPVCEvent(ctx, k8sClient, pvc, v1.EventTypeWarning, "ProvisioningDryRunFailed", err.Error())
+		return csifault.CSIProvisioningDryRunFault, err
+	}
+	resultBytes, err := json.Marshal(result)
+	if err != nil {
+		return csifault.CSIProvisioningDryRunFault, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to marshal the dry run result of PVC %s/%s. Error: %v", pvc.Namespace, pvc.Name, err)
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{common.AnnProvisioningDryRunResult: string(resultBytes)},
+		},
+	})
+	if err != nil {
+		return csifault.CSIProvisioningDryRunFault, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to create patch for PVC %s/%s. Error: %v", pvc.Namespace, pvc.Name, err)
+	}
+	_, err = k8sClient.CoreV1().PersistentVolumeClaims(pvc.Namespace).Patch(ctx, pvc.Name,
+		apitypes.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return csifault.CSIProvisioningDryRunFault, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to report the dry run result on PVC %s/%s. Error: %v", pvc.Namespace, pvc.Name, err)
+	}
+
+	if result.SelectedDatastore == "" {
+		err = logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"dry run of the provisioning of PVC %s/%s found none of the candidate datastores %v compatible "+
+				"with storage policy %q and with %d MB of free space", pvc.Namespace, pvc.Name,
+			result.CandidateDatastores, result.StoragePolicyID, volSizeMB)
+		recordPVCEvent(ctx, k8sClient, pvc, v1.EventTypeWarning, "ProvisioningDryRunFailed", err.Error())
+		return csifault.CSIProvisioningDryRunFault, err
+	}
+	err = logger.LogNewErrorCodef(log, codes.FailedPrecondition,
+		"dry run of the provisioning of PVC %s/%s selected datastore %q out of the compatible datastores %v, "+
+			"remove the %q annotation to provision the volume", pvc.Namespace, pvc.Name,
+		result.SelectedDatastore, result.CompatibleDatastores, common.AnnProvisioningDryRun)
+	recordPVCEvent(ctx, k8sClient, pvc, v1.EventTypeNormal, "ProvisioningDryRun", err.Error())
+	return csifault.CSIProvisioningDryRunFault, err
+}
+
+// planProvisioningDryRun selects the datastores on which a block volume of
+// the given size and storage class parameters would be created among the
+// given shared datastores.
+func planProvisioningDryRun(ctx context.Context, vc *cnsvsphere.VirtualCenter, scParams *common.StorageClassParams,
+	volSizeMB int64, sharedDatastores []*cnsvsphere.DatastoreInfo) (*provisioningDryRunResult, error) {
+	log := logger.GetLogger(ctx)
+	result := &provisioningDryRunResult{
+		VCenter:              vc.Config.Host,
+		CapacityMB:           volSizeMB,
+		CandidateDatastores:  make([]string, 0),
+		CompatibleDatastores: make([]string, 0),
+	}
+	var candidates []*cnsvsphere.DatastoreInfo
+	for _, ds := range sharedDatastores {
+		if cnsvsphere.IsVolumeCreationSuspended(ctx, ds) {
+			continue
+		}
+		if scParams.DatastoreURL != "" && strings.TrimSpace(ds.Info.Url) != strings.TrimSpace(scParams.DatastoreURL) {
+			continue
+		}
+		candidates = append(candidates, ds)
+		result.CandidateDatastores = append(result.CandidateDatastores, ds.Info.Url)
+	}
+	if len(candidates) == 0 {
+		return result, nil
+	}
+
+	var compatibleDsMoids map[string]struct{}
+	if scParams.StoragePolicyName != "" {
+		var err error
+		result.StoragePolicyID, err = vc.GetStoragePolicyIDByName(ctx, scParams.StoragePolicyName)
+		if err != nil {
+			return nil, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+				"failed to get storage policy ID for %q. Error: %v", scParams.StoragePolicyName, err)
+		}
+		var moRefs []types.ManagedObjectReference
+		for _, ds := range candidates {
+			moRefs = append(moRefs, ds.Reference())
+		}
+		compat, err := vc.PbmCheckCompatibility(ctx, moRefs, result.StoragePolicyID)
+		if err != nil {
+			return nil, logger.LogNewErrorCodef(log, codes.Internal,
+				"failed to find datastore compatibility with storage policy ID %q. Error: %v",
+				result.StoragePolicyID, err)
+		}
+		compatibleDsMoids = make(map[string]struct{})
+		for _, ds := range compat.CompatibleDatastores() {
+			compatibleDsMoids[ds.HubId] = struct{}{}
+		}
+	}
+
+	var selected *cnsvsphere.DatastoreInfo
+	for _, ds := range candidates {
+		if compatibleDsMoids != nil {
+			if _, ok := compatibleDsMoids[ds.Reference().Value]; !ok {
+				continue
+			}
+		}
+		if ds.Info.FreeSpace < volSizeMB*common.MbInBytes {
+			continue
+		}
+		result.CompatibleDatastores = append(result.CompatibleDatastores, ds.Info.Url)
+		if selected == nil || ds.Info.FreeSpace > selected.Info.FreeSpace {
+			selected = ds
+		}
+	}
+	if selected != nil {
+		result.SelectedDatastore = selected.Info.Url
+	}
+	return result, nil
+}
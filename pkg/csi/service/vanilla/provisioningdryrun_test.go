@@ -0,0 +1,76 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vanilla
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+)
+
+func TestPlanProvisioningDryRun(t *testing.T) {
+	ctx := context.Background()
+	vc := &cnsvsphere.VirtualCenter{Config: &cnsvsphere.VirtualCenterConfig{Host: "vc1"}}
+	newDatastore := func(name string, freeSpaceMB int64,
+		customValues ...types.BaseCustomFieldValue) *cnsvsphere.DatastoreInfo {
+		return &cnsvsphere.DatastoreInfo{
+			Datastore: &cnsvsphere.Datastore{
+				Datastore: object.NewDatastore(nil, types.ManagedObjectReference{Type: "Datastore", Value: name}),
+			},
+			Info: &types.DatastoreInfo{Name: name, Url: "ds:///" + name,
+				FreeSpace: freeSpaceMB * common.MbInBytes},
+			CustomValues: customValues,
+		}
+	}
+	datastores := []*cnsvsphere.DatastoreInfo{
+		newDatastore("ds-1", 2048),
+		newDatastore("ds-2", 4096),
+		newDatastore("ds-full", 512),
+		newDatastore("ds-suspended", 8192,
+			&types.CustomFieldStringValue{Value: "cns.vmware.com/datastoreSuspended"}),
+	}
+
+	result, err := planProvisioningDryRun(ctx, vc, &common.StorageClassParams{}, 1024, datastores)
+	if err != nil {
+		t.Fatalf("failed to plan the dry run. Error: %v", err)
+	}
+	expected := &provisioningDryRunResult{
+		VCenter:              "vc1",
+		CapacityMB:           1024,
+		CandidateDatastores:  []string{"ds:///ds-1", "ds:///ds-2", "ds:///ds-full"},
+		CompatibleDatastores: []string{"ds:///ds-1", "ds:///ds-2"},
+		SelectedDatastore:    "ds:///ds-2",
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected dry run result %+v, got %+v", expected, result)
+	}
+
+	result, err = planProvisioningDryRun(ctx, vc, &common.StorageClassParams{DatastoreURL: "ds:///ds-full"}, 1024,
+		datastores)
+	if err != nil {
+		t.Fatalf("failed to plan the dry run. Error: %v", err)
+	}
+	if result.SelectedDatastore != "" || len(result.CompatibleDatastores) != 0 {
+		t.Errorf("expected no datastore to be selected, got %+v", result)
+	}
+}
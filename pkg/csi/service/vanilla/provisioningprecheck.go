@@ -0,0 +1,375 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vanilla
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"golang.org/x/sync/singleflight"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco"
+	commoncotypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco/types"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/placementengine"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+)
+
+const (
+	// provisioningPrecheckHandlerPath is the path of the provisioning
+	// pre-check on the admin server of the controller.
+	provisioningPrecheckHandlerPath = "/debug/provisioning-precheck"
+	// Parameters of the provisioning pre-check.
+	provisioningPrecheckParamStorageClass = "storageClass"
+	provisioningPrecheckParamSize         = "size"
+	provisioningPrecheckParamZone         = "zone"
+	provisioningPrecheckParamTopologyKey  = "topologyKey"
+	// defaultPrecheckTopologyKey is the topology key of the zone parameter
+	// when the topologyKey parameter is not given.
+	defaultPrecheckTopologyKey = "topology.csi.vmware.com/k8s-zone"
+	// provisioningPrecheckCacheTTL is how long the datastores on which the
+	// volumes of a StorageClass can be provisioned in a zone are cached.
+	provisioningPrecheckCacheTTL = time.Minute
+	// storageClassProvisionerParamPrefix is the prefix of the StorageClass
+	// parameters consumed by the external-provisioner, which are not passed
+	// to CreateVolume.
+	storageClassProvisionerParamPrefix = "csi.storage.k8s.io/"
+)
+
+// provisioningPrecheckResult is the answer of the provisioning pre-check.
+type provisioningPrecheckResult struct {
+	Provisionable bool   `json:"provisionable"`
+	Reason        string `json:"reason,omitempty"`
+	StorageClass  string `json:"storageClass"`
+	Zone          string `json:"zone,omitempty"`
+	CapacityMB    int64  `json:"capacityMB"`
+	// Datastores are the URLs of the datastores compatible with the
+	// StorageClass, accessible from the zone and with enough free space.
+	Datastores []string `json:"datastores"`
+	// CachedAt is when the datastores and their free space were retrieved.
+	CachedAt time.Time `json:"cachedAt"`
+}
+
+// precheckDatastore is a datastore on which the volumes of a StorageClass
+// can be provisioned.
+type precheckDatastore struct {
+	url       string
+	freeSpace int64
+}
+
+// precheckCacheEntry holds the datastores on which the volumes of a
+// StorageClass can be provisioned in a zone.
+type precheckCacheEntry struct {
+	datastores []precheckDatastore
+	cachedAt   time.Time
+}
+
+// provisioningPrecheckHandler answers whether a block volume of a given size
+// and StorageClass could be provisioned in a zone, without provisioning it,
+// for cluster autoscaler and scheduler simulations.
+//
+// GET takes the "storageClass" and "size" parameters, the size being a
+// resource quantity such as "10Gi", and the optional "zone" and
+// "topologyKey" parameters. The zone is required on the multi vCenter setup.
+// The datastores compatible with the storage policy of the StorageClass and
+// accessible from the zone are cached for a minute, so that repeated
+// simulations don't query vCenter.
+type provisioningPrecheckHandler struct {
+	c         *controller
+	k8sClient clientset.Interface
+	// loadDatastores returns the datastores on which the volumes with the
+	// given StorageClass parameters can be provisioned in the given topology
+	// segment, or anywhere if the segment is nil.
+	loadDatastores func(ctx context.Context, scParams *common.StorageClassParams,
+		segment map[string]string) ([]precheckDatastore, error)
+	// group shares the loading of the datastores of a cache key between
+	// concurrent requests.
+	group singleflight.Group
+	// mu guards cache.
+	mu    sync.Mutex
+	cache map[string]*precheckCacheEntry
+}
+
+// newProvisioningPrecheckHandler returns a provisioning pre-check handler
+// loading the datastores through the given controller.
+func newProvisioningPrecheckHandler(c *controller, k8sClient clientset.Interface) *provisioningPrecheckHandler {
+	h := &provisioningPrecheckHandler{
+		c:         c,
+		k8sClient: k8sClient,
+		cache:     make(map[string]*precheckCacheEntry),
+	}
+	h.loadDatastores = h.loadControllerDatastores
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *provisioningPrecheckHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx, log := logger.GetNewContextWithLogger()
+	query := r.URL.Query()
+	scName := query.Get(provisioningPrecheckParamStorageClass)
+	if scName == "" {
+		http.Error(w, "missing parameter "+provisioningPrecheckParamStorageClass, http.StatusBadRequest)
+		return
+	}
+	size, err := resource.ParseQuantity(query.Get(provisioningPrecheckParamSize))
+	if err != nil || size.Sign() <= 0 {
+		http.Error(w, "invalid parameter "+provisioningPrecheckParamSize, http.StatusBadRequest)
+		return
+	}
+	zone := query.Get(provisioningPrecheckParamZone)
+	topologyKey := query.Get(provisioningPrecheckParamTopologyKey)
+	if topologyKey == "" {
+		topologyKey = defaultPrecheckTopologyKey
+	}
+
+	result, status, err := h.precheck(ctx, scName, size.Value(), topologyKey, zone)
+	if err != nil {
+		log.Errorf("provisioning pre-check of StorageClass %q failed with error: %v", scName, err)
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Errorf("failed to write provisioning pre-check result with error: %v", err)
+	}
+}
+
+// precheck answers the provisioning pre-check of a volume of the given size
+// in bytes. The HTTP status of the error is returned along with it.
+func (h *provisioningPrecheckHandler) precheck(ctx context.Context, scName string, sizeBytes int64,
+	topologyKey string, zone string) (*provisioningPrecheckResult, int, error) {
+	sc, err := h.k8sClient.StorageV1().StorageClasses().Get(ctx, scName, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, http.StatusNotFound, fmt.Errorf("StorageClass %q not found", scName)
+		}
+		return nil, http.StatusInternalServerError, err
+	}
+	if sc.Provisioner != csitypes.DriverName() {
+		return nil, http.StatusBadRequest, fmt.Errorf("StorageClass %q is not provisioned by %s", scName,
+			csitypes.DriverName())
+	}
+	params := make(map[string]string)
+	for key, value := range sc.Parameters {
+		if !strings.HasPrefix(strings.ToLower(key), storageClassProvisionerParamPrefix) {
+			params[key] = value
+		}
+	}
+	scParams, err := common.ParseStorageClassParams(ctx, params,
+		commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.CSIMigration))
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid parameters of StorageClass %q: %v", scName, err)
+	}
+
+	volSizeMB := common.RoundUpSize(sizeBytes, common.MbInBytes)
+	result := &provisioningPrecheckResult{
+		StorageClass: scName,
+		Zone:         zone,
+		CapacityMB:   volSizeMB,
+		Datastores:   make([]string, 0),
+	}
+	if err := validateBlockVolumeSize(ctx, h.c.managers.CnsConfig.VolumeSizeLimits, scParams,
+		volSizeMB); err != nil {
+		result.Reason = err.Error()
+		return result, http.StatusOK, nil
+	}
+
+	var segment map[string]string
+	if zone != "" {
+		segment = map[string]string{topologyKey: zone}
+	} else if len(h.c.managers.VcenterConfigs) > 1 {
+		return nil, http.StatusBadRequest, fmt.Errorf("parameter %s is required on the multi vCenter setup",
+			provisioningPrecheckParamZone)
+	}
+	cacheKey := strings.Join([]string{sc.Name, sc.ResourceVersion, topologyKey, zone}, "/")
+	entry, err := h.getDatastores(ctx, cacheKey, scParams, segment)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	result.CachedAt = entry.cachedAt
+	for _, ds := range entry.datastores {
+		if ds.freeSpace >= volSizeMB*common.MbInBytes {
+			result.Datastores = append(result.Datastores, ds.url)
+		}
+	}
+	result.Provisionable = len(result.Datastores) != 0
+	if !result.Provisionable {
+		if len(entry.datastores) == 0 {
+			result.Reason = "no datastore accessible from the zone is compatible with the StorageClass"
+		} else {
+			result.Reason = fmt.Sprintf("none of the compatible datastores has %d MB of free space", volSizeMB)
+		}
+	}
+	return result, http.StatusOK, nil
+}
+
+// getDatastores returns the cached datastores of the given cache key, or
+// loads them if the cached ones expired. vCenter is queried without holding
+// the cache lock.
+func (h *provisioningPrecheckHandler) getDatastores(ctx context.Context, cacheKey string,
+	scParams *common.StorageClassParams, segment map[string]string) (*precheckCacheEntry, error) {
+	h.mu.Lock()
+	entry, ok := h.cache[cacheKey]
+	h.mu.Unlock()
+	if ok && time.Since(entry.cachedAt) <= provisioningPrecheckCacheTTL {
+		return entry, nil
+	}
+	loaded, err, _ := h.group.Do(cacheKey, func() (interface{}, error) {
+		datastores, err := h.loadDatastores(ctx, scParams, segment)
+		if err != nil {
+			return nil, err
+		}
+		entry := &precheckCacheEntry{datastores: datastores, cachedAt: time.Now()}
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.cache[cacheKey] = entry
+		for key, cached := range h.cache {
+			if time.Since(cached.cachedAt) > provisioningPrecheckCacheTTL {
+				delete(h.cache, key)
+			}
+		}
+		return entry, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loaded.(*precheckCacheEntry), nil
+}
+
+// loadControllerDatastores returns the datastores on which CreateVolume would
+// provision the volumes with the given StorageClass parameters in the given
+// topology segment, along with their free space.
+func (h *provisioningPrecheckHandler) loadControllerDatastores(ctx context.Context,
+	scParams *common.StorageClassParams, segment map[string]string) ([]precheckDatastore, error) {
+	if multivCenterCSITopologyEnabled && len(h.c.managers.VcenterConfigs) > 1 {
+		return h.loadMultiVCenterDatastores(ctx, scParams, segment)
+	}
+	vcenter, err := common.GetVCenter(ctx, h.c.manager)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get vCenter. Error: %v", err)
+	}
+	var sharedDatastores []*cnsvsphere.DatastoreInfo
+	if segment != nil {
+		if !isTopologyConfigured(h.c.manager.CnsConfig) {
+			return nil, fmt.Errorf("topology category names not specified in the vsphere config secret")
+		}
+		topologies := []*csi.Topology{{Segments: segment}}
+		sharedDatastores, err = h.c.topologyMgr.GetSharedDatastoresInTopology(ctx,
+			commoncotypes.VanillaTopologyFetchDSParams{
+				TopologyRequirement: &csi.TopologyRequirement{Requisite: topologies, Preferred: topologies},
+			})
+	} else {
+		sharedDatastores, err = h.c.nodeMgr.GetSharedDatastoresInK8SCluster(ctx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shared datastores. Error: %v", err)
+	}
+	sharedDatastores, err = h.c.filterDatastores(ctx, sharedDatastores, h.c.manager.VcenterConfig.Host)
+	if err != nil {
+		return nil, err
+	}
+	return compatiblePrecheckDatastores(ctx, vcenter, scParams, sharedDatastores)
+}
+
+// loadMultiVCenterDatastores returns the datastores on which CreateVolume
+// would provision the volumes with the given StorageClass parameters in the
+// given topology segment, in every vCenter the segment belongs to.
+func (h *provisioningPrecheckHandler) loadMultiVCenterDatastores(ctx context.Context,
+	scParams *common.StorageClassParams, segment map[string]string) ([]precheckDatastore, error) {
+	if segment == nil {
+		return nil, fmt.Errorf("a zone is required on the multi vCenter setup")
+	}
+	topologies := []*csi.Topology{{Segments: segment}}
+	vcTopologySegmentsMap, err := common.GetAccessibilityRequirementsByVC(ctx,
+		&csi.TopologyRequirement{Requisite: topologies, Preferred: topologies})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the vCenters of topology segment %v. Error: %v", segment, err)
+	}
+	var datastores []precheckDatastore
+	for vcHost, topologySegmentsList := range vcTopologySegmentsMap {
+		vcenter, err := common.GetVCenterFromVCHost(ctx, h.c.managers.VcenterManager, vcHost)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get vCenter %q. Error: %v", vcHost, err)
+		}
+		sharedDatastores, err := placementengine.GetSharedDatastores(ctx,
+			placementengine.VanillaSharedDatastoresParams{
+				Vcenter:                       vcenter,
+				TopologySegmentsList:          topologySegmentsList,
+				DatastoreLatencyThresholdInMs: h.c.managers.CnsConfig.Placement.DatastoreLatencyThresholdInMs,
+				NodeLocal:                     scParams.NodeLocal,
+			})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get shared datastores in vCenter %q. Error: %v", vcHost, err)
+		}
+		if len(sharedDatastores) == 0 {
+			continue
+		}
+		sharedDatastores, err = h.c.filterDatastores(ctx, sharedDatastores, vcHost)
+		if err != nil {
+			if err == errAllDSFilteredOut {
+				continue
+			}
+			return nil, err
+		}
+		vcDatastores, err := compatiblePrecheckDatastores(ctx, vcenter, scParams, sharedDatastores)
+		if err != nil {
+			return nil, err
+		}
+		datastores = append(datastores, vcDatastores...)
+	}
+	return datastores, nil
+}
+
+// compatiblePrecheckDatastores returns the given datastores of vCenter which
+// are compatible with the given StorageClass parameters.
+func compatiblePrecheckDatastores(ctx context.Context, vcenter *cnsvsphere.VirtualCenter,
+	scParams *common.StorageClassParams, sharedDatastores []*cnsvsphere.DatastoreInfo) (
+	[]precheckDatastore, error) {
+	plan, err := planProvisioningDryRun(ctx, vcenter, scParams, 0, sharedDatastores)
+	if err != nil {
+		return nil, err
+	}
+	compatible := make(map[string]bool)
+	for _, url := range plan.CompatibleDatastores {
+		compatible[url] = true
+	}
+	var datastores []precheckDatastore
+	for _, ds := range sharedDatastores {
+		if compatible[ds.Info.Url] {
+			datastores = append(datastores, precheckDatastore{url: ds.Info.Url, freeSpace: ds.Info.FreeSpace})
+		}
+	}
+	return datastores, nil
+}
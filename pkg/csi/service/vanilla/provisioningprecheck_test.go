@@ -0,0 +1,135 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vanilla
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	cnsconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/unittestcommon"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+)
+
+func TestProvisioningPrecheckHandler(t *testing.T) {
+	if commonco.ContainerOrchestratorUtility == nil {
+		var err error
+		commonco.ContainerOrchestratorUtility, err =
+			unittestcommon.GetFakeContainerOrchestratorInterface(common.Kubernetes)
+		if err != nil {
+			t.Fatalf("failed to create fake container orchestrator. Error: %v", err)
+		}
+	}
+	k8sClient := fake.NewSimpleClientset(
+		&storagev1.StorageClass{
+			ObjectMeta:  metav1.ObjectMeta{Name: "gold"},
+			Provisioner: csitypes.Name,
+			Parameters:  map[string]string{"storagepolicyname": "gold", "csi.storage.k8s.io/fstype": "ext4"},
+		},
+		&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "other"}, Provisioner: "example.com/other"},
+	)
+	c := &controller{managers: &common.Managers{CnsConfig: &cnsconfig.Config{}}}
+	h := newProvisioningPrecheckHandler(c, k8sClient)
+	var loads int
+	h.loadDatastores = func(ctx context.Context, scParams *common.StorageClassParams,
+		segment map[string]string) ([]precheckDatastore, error) {
+		loads++
+		// vCenter is queried without holding the cache lock.
+		if !h.mu.TryLock() {
+			t.Errorf("expected the datastores to be loaded without holding the cache lock")
+		} else {
+			h.mu.Unlock()
+		}
+		if scParams.StoragePolicyName != "gold" {
+			t.Errorf("expected storage policy gold, got %q", scParams.StoragePolicyName)
+		}
+		if !reflect.DeepEqual(segment, map[string]string{defaultPrecheckTopologyKey: "zone-a"}) {
+			t.Errorf("unexpected topology segment %v", segment)
+		}
+		return []precheckDatastore{
+			{url: "ds:///ds-1", freeSpace: 2048 * common.MbInBytes},
+			{url: "ds:///ds-2", freeSpace: 512 * common.MbInBytes},
+		}, nil
+	}
+	get := func(query string) (int, *provisioningPrecheckResult) {
+		recorder := httptest.NewRecorder()
+		h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, provisioningPrecheckHandlerPath+"?"+query, nil))
+		if recorder.Code != http.StatusOK {
+			return recorder.Code, nil
+		}
+		result := &provisioningPrecheckResult{}
+		if err := json.Unmarshal(recorder.Body.Bytes(), result); err != nil {
+			t.Fatalf("failed to decode pre-check result. Error: %v", err)
+		}
+		return recorder.Code, result
+	}
+
+	code, result := get("storageClass=gold&size=1Gi&zone=zone-a")
+	if code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", code)
+	}
+	if !result.Provisionable || result.CapacityMB != 1024 ||
+		!reflect.DeepEqual(result.Datastores, []string{"ds:///ds-1"}) {
+		t.Errorf("unexpected pre-check result %+v", result)
+	}
+
+	// The datastores are served from the cache.
+	code, result = get("storageClass=gold&size=4Gi&zone=zone-a")
+	if code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", code)
+	}
+	if result.Provisionable || result.Reason == "" || len(result.Datastores) != 0 {
+		t.Errorf("unexpected pre-check result %+v", result)
+	}
+	if loads != 1 {
+		t.Errorf("expected the datastores to be loaded once, got %d", loads)
+	}
+
+	for query, expected := range map[string]int{
+		"storageClass=other&size=1Gi":   http.StatusBadRequest,
+		"storageClass=missing&size=1Gi": http.StatusNotFound,
+		"storageClass=gold&size=large":  http.StatusBadRequest,
+		"size=1Gi":                      http.StatusBadRequest,
+	} {
+		if code, _ = get(query); code != expected {
+			t.Errorf("expected status %d for query %q, got %d", expected, query, code)
+		}
+	}
+
+	// The zone is required on the multi vCenter setup.
+	c.managers.VcenterConfigs = map[string]*cnsvsphere.VirtualCenterConfig{"vc-1": {}, "vc-2": {}}
+	if code, _ = get("storageClass=gold&size=1Gi"); code != http.StatusBadRequest {
+		t.Errorf("expected status 400 without zone on the multi vCenter setup, got %d", code)
+	}
+
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, provisioningPrecheckHandlerPath, nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405 for POST, got %d", recorder.Code)
+	}
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vanilla
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	clientset "k8s.io/client-go/kubernetes"
+
+	csifault "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/fault"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
+)
+
+const (
+	// maintenanceConfigMapName is the name of the ConfigMap, in the namespace
+	// of the driver, switching the controller to read-only mode.
+	maintenanceConfigMapName = "vsphere-csi-maintenance"
+
+	// Keys of the maintenance ConfigMap.
+	maintenanceKeyReadOnly = "read-only"
+	maintenanceKeyReason   = "reason"
+)
+
+// readOnlyMode tracks whether the controller is in read-only mode. In
+// read-only mode, requests changing storage, i.e. creating, deleting or
+// expanding volumes and snapshots, are rejected with a retriable error,
+// while attach, detach and health monitoring keep working. It lets VI admins
+// freeze storage changes during maintenance windows such as vCenter upgrades.
+type readOnlyMode struct {
+	// mu guards enabled and reason.
+	mu sync.RWMutex
+	// enabled is true if the controller is in read-only mode.
+	enabled bool
+	// reason is the reason given by the admin for the read-only mode.
+	reason string
+}
+
+// watchReadOnlyMode returns the read-only mode of the controller, kept in
+// sync with the maintenance ConfigMap.
+func watchReadOnlyMode(ctx context.Context, k8sClient clientset.Interface) (*readOnlyMode, error) {
+	log := logger.GetLogger(ctx)
+	mode := &readOnlyMode{}
+	informer := k8s.NewInformer(ctx, k8sClient, true)
+	err := informer.AddConfigMapListener(ctx, k8sClient, common.GetCSINamespace(),
+		// Add.
+		func(obj interface{}) {
+			mode.update(ctx, obj)
+		},
+		// Update.
+		func(oldObj interface{}, newObj interface{}) {
+			mode.update(ctx, newObj)
+		},
+		// Delete.
+		func(obj interface{}) {
+			if configMap, ok := obj.(*v1.ConfigMap); ok && configMap.Name == maintenanceConfigMapName {
+				mode.set(ctx, false, "")
+			}
+		})
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to listen on ConfigMap %q. Error: %v",
+			maintenanceConfigMapName, err)
+	}
+	return mode, nil
+}
+
+// update sets the read-only mode from the given maintenance ConfigMap.
+// Other ConfigMaps are ignored.
+func (m *readOnlyMode) update(ctx context.Context, obj interface{}) {
+	log := logger.GetLogger(ctx)
+	configMap, ok := obj.(*v1.ConfigMap)
+	if !ok || configMap == nil || configMap.Name != maintenanceConfigMapName {
+		return
+	}
+	enabled := false
+	if value, ok := configMap.Data[maintenanceKeyReadOnly]; ok {
+		var err error
+		enabled, err = strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			log.Errorf("invalid value %q for %q in ConfigMap %q, keeping read-only mode %t. Err: %v",
+				value, maintenanceKeyReadOnly, maintenanceConfigMapName, m.isEnabled(), err)
+			return
+		}
+	}
+	m.set(ctx, enabled, configMap.Data[maintenanceKeyReason])
+}
+
+// set enables or disables the read-only mode.
+func (m *readOnlyMode) set(ctx context.Context, enabled bool, reason string) {
+	log := logger.GetLogger(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.enabled != enabled {
+		if enabled {
+			log.Warnf("Controller switched to read-only mode. Reason: %q", reason)
+		} else {
+			log.Infof("Controller switched out of read-only mode")
+		}
+	}
+	m.enabled = enabled
+	m.reason = reason
+}
+
+// isEnabled returns true if the controller is in read-only mode.
+func (m *readOnlyMode) isEnabled() bool {
+	if m == nil {
+		return false
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.enabled
+}
+
+// check returns a retriable error with the CSIReadOnlyModeFault fault type
+// if the controller is in read-only mode, and nil otherwise.
+func (m *readOnlyMode) check(ctx context.Context, operation string) error {
+	if m == nil {
+		return nil
+	}
+	m.mu.RLock()
+	enabled, reason := m.enabled, m.reason
+	m.mu.RUnlock()
+	if !enabled {
+		return nil
+	}
+	if reason == "" {
+		reason = "maintenance"
+	}
+	return csifault.LogNewErrorf(logger.GetLogger(ctx), csifault.CSIReadOnlyModeFault,
+		"%s is rejected as the controller is in read-only mode (%s); it will be retried once ConfigMap %q "+
+			"disables the read-only mode", operation, reason, maintenanceConfigMapName)
+}
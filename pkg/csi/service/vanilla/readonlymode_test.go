@@ -0,0 +1,64 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vanilla
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReadOnlyMode(t *testing.T) {
+	ctx := context.Background()
+	var unset *readOnlyMode
+	if err := unset.check(ctx, "CreateVolume"); err != nil {
+		t.Errorf("expected no error without read-only mode, got %v", err)
+	}
+
+	mode := &readOnlyMode{}
+	maintenanceConfigMap := func(data map[string]string) *v1.ConfigMap {
+		return &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: maintenanceConfigMapName}, Data: data}
+	}
+	mode.update(ctx, maintenanceConfigMap(map[string]string{
+		maintenanceKeyReadOnly: "true",
+		maintenanceKeyReason:   "vCenter upgrade",
+	}))
+	err := mode.check(ctx, "CreateVolume")
+	if err == nil {
+		t.Fatalf("expected CreateVolume to be rejected in read-only mode")
+	}
+	if code := status.Code(err); code != codes.Unavailable {
+		t.Errorf("expected code %v, got %v", codes.Unavailable, code)
+	}
+
+	// Other ConfigMaps and invalid values don't change the mode.
+	mode.update(ctx, &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "other"},
+		Data: map[string]string{maintenanceKeyReadOnly: "false"}})
+	mode.update(ctx, maintenanceConfigMap(map[string]string{maintenanceKeyReadOnly: "maybe"}))
+	if !mode.isEnabled() {
+		t.Errorf("expected read-only mode to stay enabled")
+	}
+
+	mode.update(ctx, maintenanceConfigMap(map[string]string{maintenanceKeyReadOnly: "false"}))
+	if err := mode.check(ctx, "CreateVolume"); err != nil {
+		t.Errorf("expected no error after disabling read-only mode, got %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vanilla
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	cnsvolume "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
+	csifault "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/fault"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
+)
+
+// recordLastSnapshotTime stamps the backing disk of the volume with the time
+// of its new snapshot, so that the deletion of the volume is deferred even if
+// the snapshot is deleted, e.g. once it is exported by a backup job.
+func recordLastSnapshotTime(ctx context.Context, volumeManager cnsvolume.Manager, volumeID string,
+	snapshotTime time.Time) {
+	log := logger.GetLogger(ctx)
+	err := volumeManager.UpdateVStorageObjectMetadata(ctx, volumeID, map[string]string{
+		common.VStorageObjectMetadataLastSnapshotAt: snapshotTime.UTC().Format(time.RFC3339),
+	}, nil)
+	if err != nil {
+		log.Warnf("failed to record the time of the last snapshot of volume %q. Error: %+v", volumeID, err)
+	}
+}
+
+// getLastSnapshotTime returns the time of the last snapshot of a volume,
+// which is the latest of the creation times of its snapshots and of the
+// time recorded on its backing disk. The zero time is returned if the volume
+// was never snapshotted.
+func getLastSnapshotTime(lastSnapshotAt string, snapshots []*csi.Snapshot) time.Time {
+	var lastSnapshotTime time.Time
+	if lastSnapshotAt != "" {
+		if recordedTime, err := time.Parse(time.RFC3339, lastSnapshotAt); err == nil {
+			lastSnapshotTime = recordedTime
+		}
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.GetCreationTime() == nil {
+			continue
+		}
+		if creationTime := snapshot.GetCreationTime().AsTime(); creationTime.After(lastSnapshotTime) {
+			lastSnapshotTime = creationTime
+		}
+	}
+	return lastSnapshotTime
+}
+
+// deferDeleteForRecentSnapshots is called by DeleteVolume for a block volume
+// when the recent-snapshot-protection FSS is enabled. If the volume was
+// snapshotted within the recent snapshot window, a warning event is recorded
+// on the PV and an Unavailable error is returned, so that the
+// external-provisioner retries the deletion until the window is over.
+func deferDeleteForRecentSnapshots(ctx context.Context, c *controller, volumeManager cnsvolume.Manager,
+	volumeID string, snapshots []*csi.Snapshot) (string, error) {
+	log := logger.GetLogger(ctx)
+	lastSnapshotAt, err := volumeManager.RetrieveVStorageObjectMetadataValue(ctx, volumeID,
+		common.VStorageObjectMetadataLastSnapshotAt)
+	if err != nil {
+		return csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to retrieve the time of the last snapshot of volume %q. Error: %+v", volumeID, err)
+	}
+	lastSnapshotTime := getLastSnapshotTime(lastSnapshotAt, snapshots)
+	windowInHours := c.managers.CnsConfig.DeletionProtection.RecentSnapshotWindowInHours
+	deleteAfter := lastSnapshotTime.Add(time.Duration(windowInHours) * time.Hour)
+	if lastSnapshotTime.IsZero() || !time.Now().Before(deleteAfter) {
+		return "", nil
+	}
+	msg := fmt.Sprintf("deletion of volume %s is deferred until %s, as it was snapshotted at %s, "+
+		"less than %d hours ago", volumeID, deleteAfter.UTC().Format(time.RFC3339),
+		lastSnapshotTime.UTC().Format(time.RFC3339), windowInHours)
+	if pvName, found := commonco.ContainerOrchestratorUtility.GetPVNameFromCSIVolumeID(volumeID); found {
+		k8sClient, err := k8s.NewClient(ctx)
+		if err != nil {
+			log.Warnf("failed to create kubernetes client to record the deferred deletion of volume %q. "+
+				"Error: %v", volumeID, err)
+		} else if pv, err := k8sClient.CoreV1().PersistentVolumes().Get(ctx, pvName, metav1.GetOptions{}); err != nil {
+			log.Warnf("failed to get PV %q of volume %q. Error: %v", pvName, volumeID, err)
+		} else {
+			recordPVEvent(ctx, k8sClient, pv, v1.EventTypeWarning, "VolumeDeletionDeferred", msg)
+		}
+	}
+	return csifault.CSIInternalFault, logger.LogNewErrorCode(log, codes.Unavailable, msg)
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vanilla
+
+import (
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestGetLastSnapshotTime(t *testing.T) {
+	recorded := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	older := &csi.Snapshot{SnapshotId: "older", CreationTime: timestamppb.New(recorded.Add(-time.Hour))}
+	newer := &csi.Snapshot{SnapshotId: "newer", CreationTime: timestamppb.New(recorded.Add(time.Hour))}
+	tests := []struct {
+		name           string
+		lastSnapshotAt string
+		snapshots      []*csi.Snapshot
+		expected       time.Time
+	}{
+		{name: "never snapshotted"},
+		{name: "recorded only", lastSnapshotAt: recorded.Format(time.RFC3339), expected: recorded},
+		{name: "invalid recorded time", lastSnapshotAt: "yesterday", snapshots: []*csi.Snapshot{older},
+			expected: recorded.Add(-time.Hour)},
+		{name: "older snapshot", lastSnapshotAt: recorded.Format(time.RFC3339),
+			snapshots: []*csi.Snapshot{older, {SnapshotId: "no-creation-time"}}, expected: recorded},
+		{name: "newer snapshot", lastSnapshotAt: recorded.Format(time.RFC3339),
+			snapshots: []*csi.Snapshot{older, newer}, expected: recorded.Add(time.Hour)},
+	}
+	for _, test := range tests {
+		lastSnapshotTime := getLastSnapshotTime(test.lastSnapshotAt, test.snapshots)
+		if !lastSnapshotTime.Equal(test.expected) {
+			t.Errorf("%s: expected last snapshot time %v, got %v", test.name, test.expected, lastSnapshotTime)
+		}
+	}
+}
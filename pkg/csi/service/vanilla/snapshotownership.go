@@ -0,0 +1,211 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vanilla
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v6/apis/volumesnapshot/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
+)
+
+// snapshotOwnershipAnnotations maps the keys of the VolumeSnapshotContent
+// annotations recording the ownership of the snapshotted volume to the keys
+// of the volume context attributes of the volumes restored from it.
+func snapshotOwnershipAnnotations() map[string]string {
+	return map[string]string{
+		csitypes.DriverName() + "/snapshot-owner-uid": common.AttributeSnapshotOwnerUID,
+		csitypes.DriverName() + "/snapshot-owner-gid": common.AttributeSnapshotOwnerGID,
+		csitypes.DriverName() + "/snapshot-fsgroup":   common.AttributeSnapshotFSGroup,
+	}
+}
+
+// recordSnapshotOwnership annotates the VolumeSnapshotContent of the given
+// request with the uid, gid and fsGroup of the running pods using the
+// snapshotted volume, so that they can be applied again to the volumes
+// restored from it. The ownership is recorded on the cluster-scoped
+// VolumeSnapshotContent rather than on the VolumeSnapshot, which users can
+// edit. The VolumeSnapshotContent is only known when csi-snapshotter passes
+// its name in the parameters. Failures are only logged, as they don't affect
+// the snapshot.
+func recordSnapshotOwnership(ctx context.Context, req *csi.CreateSnapshotRequest) {
+	log := logger.GetLogger(ctx)
+	contentName := req.Parameters[common.VolumeSnapshotContentNameKey]
+	if contentName == "" {
+		log.Debugf("VolumeSnapshotContent of snapshot %q is unknown, not recording its ownership", req.Name)
+		return
+	}
+	pvName, found := commonco.ContainerOrchestratorUtility.GetPVNameFromCSIVolumeID(req.GetSourceVolumeId())
+	if !found {
+		return
+	}
+	k8sClient, err := k8s.NewClient(ctx)
+	if err != nil {
+		log.Warnf("failed to create kubernetes client to record the ownership of snapshot %q. Error: %v",
+			req.Name, err)
+		return
+	}
+	pv, err := k8sClient.CoreV1().PersistentVolumes().Get(ctx, pvName, metav1.GetOptions{})
+	if err != nil || pv.Spec.ClaimRef == nil {
+		log.Warnf("failed to get the claim of PV %q to record the ownership of snapshot %q. Error: %v",
+			pvName, req.Name, err)
+		return
+	}
+	pods, err := k8sClient.CoreV1().Pods(pv.Spec.ClaimRef.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Warnf("failed to list pods to record the ownership of snapshot %q. Error: %v", req.Name, err)
+		return
+	}
+	annotations := getPodOwnershipAnnotations(pods.Items, pv.Spec.ClaimRef.Name)
+	if len(annotations) == 0 {
+		return
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": annotations,
+		},
+	})
+	if err != nil {
+		log.Warnf("failed to marshal the ownership of snapshot %q. Error: %v", req.Name, err)
+		return
+	}
+	snapshotterClient, err := k8s.NewSnapshotterClient(ctx)
+	if err != nil {
+		log.Warnf("failed to create snapshotter client to record the ownership of snapshot %q. Error: %v",
+			req.Name, err)
+		return
+	}
+	_, err = snapshotterClient.SnapshotV1().VolumeSnapshotContents().Patch(ctx, contentName,
+		types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		log.Warnf("failed to record the ownership on VolumeSnapshotContent %q. Error: %v", contentName, err)
+		return
+	}
+	log.Infof("Recorded ownership %v on VolumeSnapshotContent %q", annotations, contentName)
+}
+
+// getPodOwnershipAnnotations returns the ownership annotations of the uid,
+// gid and fsGroup of the first running pod using the PVC with the given name
+// which sets any of them.
+func getPodOwnershipAnnotations(pods []v1.Pod, pvcName string) map[string]string {
+	keys := make(map[string]string)
+	for key, attribute := range snapshotOwnershipAnnotations() {
+		keys[attribute] = key
+	}
+	for i := range pods {
+		pod := &pods[i]
+		if pod.Status.Phase != v1.PodRunning || !podUsesPVC(pod, pvcName) || pod.Spec.SecurityContext == nil {
+			continue
+		}
+		annotations := make(map[string]string)
+		for attribute, value := range map[string]*int64{
+			common.AttributeSnapshotOwnerUID: pod.Spec.SecurityContext.RunAsUser,
+			common.AttributeSnapshotOwnerGID: pod.Spec.SecurityContext.RunAsGroup,
+			common.AttributeSnapshotFSGroup:  pod.Spec.SecurityContext.FSGroup,
+		} {
+			if value != nil {
+				annotations[keys[attribute]] = strconv.FormatInt(*value, 10)
+			}
+		}
+		if len(annotations) > 0 {
+			return annotations
+		}
+	}
+	return nil
+}
+
+// podUsesPVC returns true if the pod mounts the PVC with the given name.
+func podUsesPVC(pod *v1.Pod, pvcName string) bool {
+	for _, volume := range pod.Spec.Volumes {
+		if volume.PersistentVolumeClaim != nil && volume.PersistentVolumeClaim.ClaimName == pvcName {
+			return true
+		}
+	}
+	return false
+}
+
+// getSnapshotOwnershipAttributes returns the volume context attributes of
+// the ownership recorded on the VolumeSnapshotContent of the snapshot with
+// the given ID, which the node plugin applies on the first NodeStage of the
+// volumes restored from it. Failures are only logged, as they don't prevent
+// the restore.
+func getSnapshotOwnershipAttributes(ctx context.Context, snapshotID string) map[string]string {
+	log := logger.GetLogger(ctx)
+	snapshotterClient, err := k8s.NewSnapshotterClient(ctx)
+	if err != nil {
+		log.Warnf("failed to create snapshotter client to get the ownership of snapshot %q. Error: %v",
+			snapshotID, err)
+		return nil
+	}
+	contents, err := snapshotterClient.SnapshotV1().VolumeSnapshotContents().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Warnf("failed to list VolumeSnapshotContents to get the ownership of snapshot %q. Error: %v",
+			snapshotID, err)
+		return nil
+	}
+	content := findVolumeSnapshotContent(contents.Items, snapshotID)
+	if content == nil {
+		return nil
+	}
+	return getContentOwnershipAttributes(ctx, content)
+}
+
+// getContentOwnershipAttributes returns the volume context attributes of the
+// ownership recorded on the given VolumeSnapshotContent. Invalid values are
+// ignored.
+func getContentOwnershipAttributes(ctx context.Context, content *snapv1.VolumeSnapshotContent) map[string]string {
+	log := logger.GetLogger(ctx)
+	attributes := make(map[string]string)
+	for key, attribute := range snapshotOwnershipAnnotations() {
+		value, ok := content.Annotations[key]
+		if !ok {
+			continue
+		}
+		if _, err := common.ParseOwnershipID(value); err != nil {
+			log.Warnf("ignoring invalid annotation %s=%q on VolumeSnapshotContent %q. Error: %v",
+				key, value, content.Name, err)
+			continue
+		}
+		attributes[attribute] = value
+	}
+	return attributes
+}
+
+// findVolumeSnapshotContent returns the VolumeSnapshotContent of the snapshot
+// with the given ID, or nil if there is none.
+func findVolumeSnapshotContent(contents []snapv1.VolumeSnapshotContent,
+	snapshotID string) *snapv1.VolumeSnapshotContent {
+	for i := range contents {
+		content := &contents[i]
+		if content.Spec.Driver == csitypes.DriverName() && content.Status != nil &&
+			content.Status.SnapshotHandle != nil && *content.Status.SnapshotHandle == snapshotID {
+			return content
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vanilla
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v6/apis/volumesnapshot/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+)
+
+func TestGetPodOwnershipAnnotations(t *testing.T) {
+	id := func(value int64) *int64 { return &value }
+	newPod := func(name, pvcName string, phase v1.PodPhase, securityContext *v1.PodSecurityContext) v1.Pod {
+		return v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: v1.PodSpec{
+				SecurityContext: securityContext,
+				Volumes: []v1.Volume{{Name: "data", VolumeSource: v1.VolumeSource{
+					PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: pvcName},
+				}}},
+			},
+			Status: v1.PodStatus{Phase: phase},
+		}
+	}
+	pods := []v1.Pod{
+		newPod("pending", "data", v1.PodPending, &v1.PodSecurityContext{RunAsUser: id(1)}),
+		newPod("other-pvc", "other", v1.PodRunning, &v1.PodSecurityContext{RunAsUser: id(2)}),
+		newPod("no-security-context", "data", v1.PodRunning, nil),
+		newPod("running", "data", v1.PodRunning, &v1.PodSecurityContext{RunAsUser: id(1000), FSGroup: id(2000)}),
+	}
+	expected := map[string]string{
+		csitypes.DriverName() + "/snapshot-owner-uid": "1000",
+		csitypes.DriverName() + "/snapshot-fsgroup":   "2000",
+	}
+	if annotations := getPodOwnershipAnnotations(pods, "data"); !reflect.DeepEqual(annotations, expected) {
+		t.Errorf("expected annotations %v, got %v", expected, annotations)
+	}
+	if annotations := getPodOwnershipAnnotations(pods, "unused"); annotations != nil {
+		t.Errorf("expected no annotations for an unused PVC, got %v", annotations)
+	}
+}
+
+func TestGetContentOwnershipAttributes(t *testing.T) {
+	newContent := func(driver, handle string, annotations map[string]string) snapv1.VolumeSnapshotContent {
+		return snapv1.VolumeSnapshotContent{
+			ObjectMeta: metav1.ObjectMeta{Name: "content-" + handle, Annotations: annotations},
+			Spec:       snapv1.VolumeSnapshotContentSpec{Driver: driver},
+			Status:     &snapv1.VolumeSnapshotContentStatus{SnapshotHandle: &handle},
+		}
+	}
+	contents := []snapv1.VolumeSnapshotContent{
+		newContent("other.csi.example.com", "volume-1+snapshot-1", nil),
+		newContent(csitypes.Name, "volume-1+snapshot-1", map[string]string{
+			csitypes.DriverName() + "/snapshot-owner-uid": "1000",
+			csitypes.DriverName() + "/snapshot-owner-gid": "-1",
+			csitypes.DriverName() + "/snapshot-fsgroup":   "2000; rm -rf /",
+		}),
+	}
+	content := findVolumeSnapshotContent(contents, "volume-1+snapshot-1")
+	if content == nil || content.Spec.Driver != csitypes.Name {
+		t.Fatalf("expected the VolumeSnapshotContent of the driver, got %+v", content)
+	}
+	// Invalid values are ignored.
+	expected := map[string]string{common.AttributeSnapshotOwnerUID: "1000"}
+	if attributes := getContentOwnershipAttributes(context.Background(), content); !reflect.DeepEqual(attributes,
+		expected) {
+		t.Errorf("expected attributes %v, got %v", expected, attributes)
+	}
+	if content := findVolumeSnapshotContent(contents, "volume-1+snapshot-2"); content != nil {
+		t.Errorf("expected no VolumeSnapshotContent for an unknown snapshot, got %+v", content)
+	}
+}
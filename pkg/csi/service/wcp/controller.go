@@ -56,6 +56,8 @@ import (
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsvolumeinfo"
 	cnsvolumeinfov1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsvolumeinfo/v1alpha1"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsvolumeoperationrequest"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/csidriverconfig"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
 )
 
 const (
@@ -91,6 +93,8 @@ var (
 	expectedStartingIndex             = 0
 	cnsVolumeIDs                      = make([]string, 0)
 	vmMoidToHostMoid, volumeIDToVMMap map[string]string
+	vmUUIDToMoID                      map[string]string
+	volumeIDToNodeVMs                 map[string][]string
 )
 
 type controller struct {
@@ -153,7 +157,7 @@ func (c *controller) Init(config *cnsconfig.Config, version string) error {
 	if idempotencyHandlingEnabled {
 		log.Info("CSI Volume manager idempotency handling feature flag is enabled.")
 		operationStore, err = cnsvolumeoperationrequest.InitVolumeOperationRequestInterface(ctx,
-			config.Global.CnsVolumeOperationRequestCleanupIntervalInMin,
+			config.Global.CnsVolumeOperationRequestCleanupIntervalInMin, config.Global.VolumeOperationRequestStore,
 			func() bool {
 				return commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.BlockVolumeSnapshot)
 			}, isPodVMOnStretchSupervisorFSSEnabled)
@@ -163,6 +167,14 @@ func (c *controller) Init(config *cnsconfig.Config, version string) error {
 		}
 	}
 
+	if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.CSIDriverConfig) {
+		log.Info("Loading CSIDriverConfig Service to pick up the changes of the driver tunables")
+		err = csidriverconfig.StartCSIDriverConfigService(ctx)
+		if err != nil {
+			return logger.LogNewErrorf(log, "failed to load CSIDriverConfig service. Err: %v", err)
+		}
+	}
+
 	tasksListViewEnabled := commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx,
 		common.ListViewPerf)
 	volumeManager, err := cnsvolume.GetManager(ctx, vcenter, operationStore, idempotencyHandlingEnabled, false, false,
@@ -212,7 +224,8 @@ func (c *controller) Init(config *cnsconfig.Config, version string) error {
 	go common.ComputeFSEnabledClustersToDsMap(authMgr.(*common.AuthManager), config.Global.CSIAuthCheckIntervalInMin)
 	// Create dynamic informer for AvailabilityZone instance if FSS is enabled
 	// and CR is present in environment.
-	if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.TKGsHA) {
+	if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.TKGsHA) ||
+		commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.WorkloadDomainIsolation) {
 		// Initialize volume topology service.
 		c.topologyMgr, err = commonco.ContainerOrchestratorUtility.InitTopologyServiceInController(ctx)
 		if err != nil {
@@ -220,6 +233,17 @@ func (c *controller) Init(config *cnsconfig.Config, version string) error {
 			return err
 		}
 	}
+	if !commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.WorkloadDomainIsolation) {
+		// Watch for the Workload Domain Isolation capability being enabled
+		// after the controller started.
+		k8sClient, err := k8s.NewClient(ctx)
+		if err != nil {
+			log.Errorf("failed to create kubernetes client. Error: %+v", err)
+			return err
+		}
+		capabilityCtx, _ := logger.GetNewContextWithLogger()
+		go handleEnablementOfWLDICapability(capabilityCtx, k8sClient)
+	}
 	if isPodVMOnStretchSupervisorFSSEnabled {
 		log.Info("Loading CnsVolumeInfo Service to persist mapping for VolumeID to storage policy info")
 		volumeInfoService, err = cnsvolumeinfo.InitVolumeInfoService(ctx)
@@ -377,6 +401,7 @@ func (c *controller) ReloadConfiguration(reconnectToVCFromNewConfig bool) error
 			log.Info("CSI Volume manager idempotency handling feature flag is enabled.")
 			operationStore, err = cnsvolumeoperationrequest.InitVolumeOperationRequestInterface(ctx,
 				c.manager.CnsConfig.Global.CnsVolumeOperationRequestCleanupIntervalInMin,
+				c.manager.CnsConfig.Global.VolumeOperationRequestStore,
 				func() bool {
 					return commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.BlockVolumeSnapshot)
 				}, isPodVMOnStretchSupervisorFSSEnabled)
@@ -462,7 +487,11 @@ func (c *controller) createBlockVolume(ctx context.Context, req *csi.CreateVolum
 	// Fetch the accessibility requirements from the request.
 	topologyRequirement = req.GetAccessibilityRequirements()
 	filterSuspendedDatastores := commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.CnsMgrSuspendCreateVolume)
-	isTKGSHAEnabled := commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.TKGsHA)
+	isWorkloadDomainIsolationEnabled := commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx,
+		common.WorkloadDomainIsolation)
+	// Supervisors deployed on vSphere Zones always provision volumes zone aware.
+	isTKGSHAEnabled := commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.TKGsHA) ||
+		isWorkloadDomainIsolationEnabled
 	topoSegToDatastoresMap := make(map[string][]*cnsvsphere.DatastoreInfo)
 	if isTKGSHAEnabled {
 		// TKGS-HA feature is enabled
@@ -485,6 +514,13 @@ func (c *controller) createBlockVolume(ctx context.Context, req *csi.CreateVolum
 				return nil, csifault.CSIInternalFault, logger.LogNewErrorCode(log, codes.Internal,
 					"topology manager not initialized.")
 			}
+			if isWorkloadDomainIsolationEnabled {
+				err = validateRequestedZones(topologyRequirement, c.topologyMgr.GetAZClustersMap(ctx))
+				if err != nil {
+					return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log,
+						codes.InvalidArgument, "invalid topology requirement. Error: %v", err)
+				}
+			}
 			// Initiate TKGs HA workflow when the topology requirement contains zone labels only.
 			log.Infof("Topology aware environment detected with requirement: %+v", topologyRequirement)
 			sharedDatastores, err = c.topologyMgr.GetSharedDatastoresInTopology(ctx,
@@ -496,8 +532,19 @@ func (c *controller) createBlockVolume(ctx context.Context, req *csi.CreateVolum
 				return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
 					"failed to find shared datastores for given topology requirement. Error: %v", err)
 			}
+			if isWorkloadDomainIsolationEnabled && len(sharedDatastores) == 0 {
+				return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log,
+					codes.FailedPrecondition, "no datastore is accessible from all the clusters of the "+
+						"requested zones in topology requirement: %+v", topologyRequirement)
+			}
 		} else {
 			// zone labels not Present in the topologyRequirement
+			if isWorkloadDomainIsolationEnabled && c.topologyMgr != nil &&
+				len(c.topologyMgr.GetAZClustersMap(ctx)) > 1 {
+				return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCode(log, codes.InvalidArgument,
+					"supervisor with multiple vSphere Zones does not support creating volumes "+
+						"without zone keys in the topologyRequirement")
+			}
 			if len(clusterComputeResourceMoIds) > 1 {
 				return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.FailedPrecondition,
 					"stretched supervisor cluster does not support creating volumes "+
@@ -644,6 +691,7 @@ func (c *controller) createBlockVolume(ctx context.Context, req *csi.CreateVolum
 			"failed to create volume. Error: %+v", err)
 	}
 
+	common.SetVolumeType(volumeInfo.VolumeID.Id, common.BlockVolumeType)
 	// CreateVolume response.
 	attributes := make(map[string]string)
 	attributes[common.AttributeDiskType] = common.DiskTypeBlockVolume
@@ -879,6 +927,7 @@ func (c *controller) createFileVolume(ctx context.Context, req *csi.CreateVolume
 			"failed to create volume. Error: %+v", err)
 	}
 
+	common.SetVolumeType(volumeID, common.FileVolumeType)
 	attributes := make(map[string]string)
 	attributes[common.AttributeDiskType] = common.DiskTypeFileVolume
 
@@ -1037,6 +1086,7 @@ func (c *controller) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequ
 			prometheus.PrometheusFailStatus, faultType).Observe(time.Since(start).Seconds())
 	} else {
 		log.Infof("Volume %q deleted successfully.", req.VolumeId)
+		common.ForgetVolumeType(req.VolumeId)
 		prometheus.CsiControlOpsHistVec.WithLabelValues(volumeType, prometheus.PrometheusDeleteVolumeOpType,
 			prometheus.PrometheusPassStatus, faultType).Observe(time.Since(start).Seconds())
 	}
@@ -1470,17 +1520,23 @@ func (c *controller) ListVolumes(ctx context.Context, req *csi.ListVolumesReques
 			}
 
 			// Get volume ID to VMMap and vmMoidToHostMoid map
-			vmMoidToHostMoid, volumeIDToVMMap, err = c.GetVolumeToHostMapping(ctx)
+			vmMoidToHostMoid, volumeIDToVMMap, vmUUIDToMoID, err = c.GetVolumeToHostMapping(ctx)
 			if err != nil {
 				log.Errorf("failed to get VM MoID to Host MoID map, err:%v", err)
 				return nil, csifault.CSIInternalFault, status.Error(codes.Internal, "failed to get VM MoID to Host MoID map")
 			}
+			// Volumes attached through CnsNodeVmAttachments are only reported on the
+			// hosts found above, so a failure to list them is not fatal.
+			volumeIDToNodeVMs, err = getVolumeIDToNodeVMsMap(ctx)
+			if err != nil {
+				log.Warnf("failed to get the node VMs of volumes from CnsNodeVmAttachments, err: %v", err)
+			}
 		}
 
 		// If the difference between the volumes reported by Kubernetes and CNS
 		// is greater than the listVolumeThreshold, it might mean that the CNS
 		// cache is stale. So, fail the request and return an error
-		listVolumeThreshold := c.manager.VcenterConfig.ListVolumeThreshold
+		listVolumeThreshold := csidriverconfig.ListVolumeThreshold(c.manager.VcenterConfig.ListVolumeThreshold)
 		if len(k8sVolumeIDs)-len(cnsVolumeIDs) > listVolumeThreshold {
 			log.Errorf("Kubernetes and CNS volumes completely out of sync and exceeds the threshold: %d-%d=%d",
 				len(k8sVolumeIDs), len(cnsVolumeIDs), listVolumeThreshold)
@@ -1488,7 +1544,7 @@ func (c *controller) ListVolumes(ctx context.Context, req *csi.ListVolumesReques
 				"Kubernetes and CNS volumes completely out of sync")
 		}
 
-		queryLimit := c.manager.VcenterConfig.QueryLimit
+		queryLimit := csidriverconfig.QueryLimit(c.manager.VcenterConfig.QueryLimit)
 		if req.MaxEntries != 0 {
 			queryLimit = int(req.MaxEntries)
 		}
@@ -1507,7 +1563,8 @@ func (c *controller) ListVolumes(ctx context.Context, req *csi.ListVolumesReques
 			volumeIDs = append(volumeIDs, cnsVolumeIDs[i])
 		}
 
-		response, err := getVolumeIDToVMMap(ctx, volumeIDs, vmMoidToHostMoid, volumeIDToVMMap)
+		response, err := getVolumeIDToVMMap(ctx, volumeIDs, vmMoidToHostMoid, volumeIDToVMMap,
+			vmUUIDToMoID, volumeIDToNodeVMs)
 		if err != nil {
 			log.Errorf("Error while generating ListVolume response, err:%v", err)
 			return nil, csifault.CSIInternalFault, status.Error(codes.Internal, "Error while generating ListVolume response")
@@ -20,6 +20,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -39,6 +40,8 @@ import (
 	"k8s.io/client-go/dynamic"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 
+	cnsoperatorv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/cnsoperator"
+	cnsnodevmattachmentv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/cnsoperator/cnsnodevmattachment/v1alpha1"
 	spv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/storagepool/cns/v1alpha1"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
 	cnsconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
@@ -88,6 +91,10 @@ func validateCreateFileReqParam(paramName, value string) bool {
 // TODO: Need to remove AttributeHostLocal after external provisioner stops
 // sending this parameter.
 func validateWCPCreateVolumeRequest(ctx context.Context, req *csi.CreateVolumeRequest, isBlockRequest bool) error {
+	if common.IsMultiWriterBlockVolumeRequest(ctx, req.GetVolumeCapabilities()) {
+		return status.Error(codes.InvalidArgument,
+			"ReadWriteMany block volumes are not supported in Supervisor cluster.")
+	}
 	// Get create params.
 	params := req.GetParameters()
 	for paramName, value := range params {
@@ -541,32 +548,61 @@ func checkTopologyKeysFromAccessibilityReqs(topologyRequirement *csi.TopologyReq
 	return hostnameLabelPresent, zoneLabelPresent
 }
 
-// GetVolumeToHostMapping returns a map containing VM MoID to host MoID and VolumeID
-// and VM MoID. This map is constructed by fetching all virtual machines belonging to each host.
-func (c *controller) GetVolumeToHostMapping(ctx context.Context) (map[string]string, map[string]string, error) {
+// validateRequestedZones returns an error if the topology requirement refers
+// to a zone which is not an availability zone of the supervisor.
+func validateRequestedZones(topologyRequirement *csi.TopologyRequirement, azClustersMap map[string][]string) error {
+	var unknownZones []string
+	for _, topology := range topologyRequirement.GetPreferred() {
+		zone, exists := topology.GetSegments()[v1.LabelTopologyZone]
+		if !exists {
+			continue
+		}
+		if _, known := azClustersMap[zone]; !known {
+			unknownZones = append(unknownZones, zone)
+		}
+	}
+	if len(unknownZones) != 0 {
+		knownZones := make([]string, 0, len(azClustersMap))
+		for zone := range azClustersMap {
+			knownZones = append(knownZones, zone)
+		}
+		sort.Strings(knownZones)
+		return fmt.Errorf("requested zones %v are not availability zones of the supervisor, available zones: %v",
+			unknownZones, knownZones)
+	}
+	return nil
+}
+
+// GetVolumeToHostMapping returns a map containing VM MoID to host MoID, a map
+// containing VolumeID to VM MoID and a map containing VM BIOS UUID to VM MoID.
+// These maps are constructed by fetching all virtual machines belonging to each host.
+func (c *controller) GetVolumeToHostMapping(ctx context.Context) (map[string]string, map[string]string,
+	map[string]string, error) {
 	log := logger.GetLogger(ctx)
 	vmMoIDToHostMoID := make(map[string]string)
 	volumeIDVMMap := make(map[string]string)
+	vmUUIDToMoID := make(map[string]string)
 
 	// Get VirtualCenter object
 	vc, err := common.GetVCenter(ctx, c.manager)
 	if err != nil {
 		log.Errorf("GetVcenter error %v", err)
-		return nil, nil, fmt.Errorf("failed to get vCenter from Manager, err: %v", err)
+		return nil, nil, nil, fmt.Errorf("failed to get vCenter from Manager, err: %v", err)
 	}
 
 	// Get all the hosts belonging to the cluster
 	hostSystems, err := vc.GetHostsByCluster(ctx, c.manager.CnsConfig.Global.ClusterID)
 	if err != nil {
 		log.Errorf("failed to get hosts for cluster %v, err:%v", c.manager.CnsConfig.Global.ClusterID, err)
-		return nil, nil, fmt.Errorf("failed to get hosts for cluster %v, err:%v", c.manager.CnsConfig.Global.ClusterID, err)
+		return nil, nil, nil, fmt.Errorf("failed to get hosts for cluster %v, err:%v",
+			c.manager.CnsConfig.Global.ClusterID, err)
 	}
 
 	// Get all the virtual machines belonging to all the hosts
 	vms, err := vc.GetAllVirtualMachines(ctx, hostSystems)
 	if err != nil {
 		log.Errorf("failed to get VM MoID err: %v", err)
-		return nil, nil, fmt.Errorf("failed to get VM MoID err: %v", err)
+		return nil, nil, nil, fmt.Errorf("failed to get VM MoID err: %v", err)
 	}
 
 	var vmRefs []vimtypes.ManagedObjectReference
@@ -575,17 +611,17 @@ func (c *controller) GetVolumeToHostMapping(ctx context.Context) (map[string]str
 	for _, vm := range vms {
 		vmRefs = append(vmRefs, vm.Reference())
 	}
-	properties := []string{"runtime.host", "config.hardware"}
+	properties := []string{"runtime.host", "config.hardware", "config.uuid"}
 	pc := property.DefaultCollector(vc.Client.Client)
 	// Obtain host MoID and virtual disk ID
 	err = pc.Retrieve(ctx, vmRefs, properties, &vmMoList)
 	if err != nil {
 		log.Errorf("Error while retrieving host properties, err: %v", err)
-		return vmMoIDToHostMoID, volumeIDVMMap, err
+		return vmMoIDToHostMoID, volumeIDVMMap, vmUUIDToMoID, err
 	}
 
-	// Iterate through all the VMs and build the vmMoIDToHostMoID map
-	// and the volumeID to VMMoiD map
+	// Iterate through all the VMs and build the vmMoIDToHostMoID map,
+	// the volumeID to VMMoiD map and the VM UUID to VM MoID map
 	for _, info := range vmMoList {
 		vmMoID := info.Reference().Value
 
@@ -595,6 +631,9 @@ func (c *controller) GetVolumeToHostMapping(ctx context.Context) (map[string]str
 		if info.Config == nil {
 			continue
 		}
+		if info.Config.Uuid != "" {
+			vmUUIDToMoID[info.Config.Uuid] = vmMoID
+		}
 		devices := info.Config.Hardware.Device
 		vmDevices := object.VirtualDeviceList(devices)
 		for _, device := range vmDevices {
@@ -607,13 +646,50 @@ func (c *controller) GetVolumeToHostMapping(ctx context.Context) (map[string]str
 			}
 		}
 	}
-	return vmMoIDToHostMoID, volumeIDVMMap, nil
+	return vmMoIDToHostMoID, volumeIDVMMap, vmUUIDToMoID, nil
+}
+
+// getVolumeIDToNodeVMsMap returns a map containing VolumeID to the BIOS UUIDs
+// of the node VMs it is attached to, built from the attached
+// CnsNodeVmAttachment instances. Volumes of guest cluster nodes are attached
+// through CnsNodeVmAttachments, without any VolumeAttachment in the
+// supervisor cluster.
+func getVolumeIDToNodeVMsMap(ctx context.Context) (map[string][]string, error) {
+	log := logger.GetLogger(ctx)
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to get config with error: %+v", err)
+	}
+	cnsOperatorClient, err := k8s.NewClientForGroup(ctx, cfg, cnsoperatorv1alpha1.GroupName)
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to get client for group %s with error: %+v",
+			cnsoperatorv1alpha1.GroupName, err)
+	}
+	attachmentList := &cnsnodevmattachmentv1alpha1.CnsNodeVmAttachmentList{}
+	err = cnsOperatorClient.List(ctx, attachmentList)
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to list CnsNodeVmAttachments with error: %+v", err)
+	}
+	volumeIDToNodeVMs := make(map[string][]string)
+	for _, attachment := range attachmentList.Items {
+		if !attachment.Status.Attached {
+			continue
+		}
+		volumeID := attachment.Status.AttachmentMetadata[cnsnodevmattachmentv1alpha1.AttributeCnsVolumeID]
+		if volumeID == "" || attachment.Spec.NodeUUID == "" {
+			continue
+		}
+		volumeIDToNodeVMs[volumeID] = append(volumeIDToNodeVMs[volumeID], attachment.Spec.NodeUUID)
+	}
+	return volumeIDToNodeVMs, nil
 }
 
 // getVolumeIDToVMMap returns the csi list volume response by computing the volumeID to nodeNames map for
-// fake attached volumes and non-fake attached volumes.
+// fake attached volumes and non-fake attached volumes. The nodes of non-fake attached volumes are the hosts
+// of the VMs the volume disk is found on and of the node VMs of its CnsNodeVmAttachments.
 func getVolumeIDToVMMap(ctx context.Context, volumeIDs []string, vmMoidToHostMoid,
-	volumeIDToVMMap map[string]string) (*csi.ListVolumesResponse, error) {
+	volumeIDToVMMap, vmUUIDToMoID map[string]string, volumeIDToNodeVMs map[string][]string) (
+	*csi.ListVolumesResponse, error) {
 	log := logger.GetLogger(ctx)
 	response := &csi.ListVolumesResponse{}
 
@@ -648,7 +724,7 @@ func getVolumeIDToVMMap(ctx context.Context, volumeIDs []string, vmMoidToHostMoi
 		return nil, fmt.Errorf("no hostnames found in the NodeIDtoName map")
 	}
 
-	for volumeID, VMMoID := range volumeIDToVMMap {
+	for _, volumeID := range volumeIDs {
 		isFakeAttached, exists := allFakeAttachMarkedVolumes[volumeID]
 		// If we do not find this entry in the input list obtained from CNS
 		//, then we do not bother adding it to the result since, CNS is not aware
@@ -658,17 +734,40 @@ func getVolumeIDToVMMap(ctx context.Context, volumeIDs []string, vmMoidToHostMoi
 			continue
 		}
 
-		hostMoID, ok := vmMoidToHostMoid[VMMoID]
-		if !ok {
-			continue
+		var vmMoIDs []string
+		if vmMoID, ok := volumeIDToVMMap[volumeID]; ok {
+			vmMoIDs = append(vmMoIDs, vmMoID)
+		}
+		for _, nodeUUID := range volumeIDToNodeVMs[volumeID] {
+			if vmMoID, ok := vmUUIDToMoID[nodeUUID]; ok {
+				vmMoIDs = append(vmMoIDs, vmMoID)
+			}
 		}
 
-		hostName, ok := hostNames[hostMoID]
-		if !ok {
+		publishedNodeIDs := make([]string, 0)
+		for _, vmMoID := range vmMoIDs {
+			hostMoID, ok := vmMoidToHostMoid[vmMoID]
+			if !ok {
+				continue
+			}
+			hostName, ok := hostNames[hostMoID]
+			if !ok {
+				continue
+			}
+			found := false
+			for _, nodeID := range publishedNodeIDs {
+				if nodeID == hostName {
+					found = true
+					break
+				}
+			}
+			if !found {
+				publishedNodeIDs = append(publishedNodeIDs, hostName)
+			}
+		}
+		if len(publishedNodeIDs) == 0 {
 			continue
 		}
-		publishedNodeIDs := make([]string, 0)
-		publishedNodeIDs = append(publishedNodeIDs, hostName)
 		volume := &csi.Volume{
 			VolumeId: volumeID,
 		}
@@ -0,0 +1,113 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wcp
+
+import (
+	"context"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientset "k8s.io/client-go/kubernetes"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+const (
+	// wldiCapabilityPollInterval is the interval at which the WCP cluster
+	// capabilities are checked while the supervisor is healthy.
+	wldiCapabilityPollInterval = 5 * time.Minute
+	// wldiCapabilityPollJitter is the jitter factor applied to the intervals
+	// between two checks, so that the replicas do not poll in lockstep.
+	wldiCapabilityPollJitter = 0.2
+	// wldiCapabilityInitialBackoff and wldiCapabilityMaxBackoff bound the
+	// delay before retrying a check which failed.
+	wldiCapabilityInitialBackoff = 10 * time.Second
+	wldiCapabilityMaxBackoff     = wldiCapabilityPollInterval
+)
+
+// capabilityExit is called once the capability being watched flips. It is a
+// variable so that unit tests can replace it.
+var capabilityExit = func() { os.Exit(1) }
+
+// newWLDICapabilityBackoff returns the backoff used to retry the checks of
+// the WCP cluster capabilities which failed.
+func newWLDICapabilityBackoff() wait.Backoff {
+	return wait.Backoff{
+		Duration: wldiCapabilityInitialBackoff,
+		Factor:   2,
+		Jitter:   wldiCapabilityPollJitter,
+		Steps:    math.MaxInt32,
+		Cap:      wldiCapabilityMaxBackoff,
+	}
+}
+
+// handleEnablementOfWLDICapability watches the Workload Domain Isolation
+// capability in the WCP cluster capabilities configmap. The capability is
+// read once by the container orchestrator, so the container is restarted once
+// the capability is found enabled, for the controller to be initialized with
+// it. Failures to read the configmap are retried with an exponential backoff
+// and never restart the container.
+func handleEnablementOfWLDICapability(ctx context.Context, k8sClient clientset.Interface) {
+	log := logger.GetLogger(ctx)
+	backoff := newWLDICapabilityBackoff()
+	for {
+		delay := wait.Jitter(wldiCapabilityPollInterval, wldiCapabilityPollJitter)
+		enabled, err := getWCPCapability(ctx, k8sClient, common.WorkloadDomainIsolation)
+		if err != nil {
+			prometheus.WCPCapabilityCheckFailuresCounter.WithLabelValues(common.WorkloadDomainIsolation).Inc()
+			delay = backoff.Step()
+			log.Warnf("failed to check capability %q. Retrying in %v. Error: %v",
+				common.WorkloadDomainIsolation, delay, err)
+		} else {
+			backoff = newWLDICapabilityBackoff()
+			if enabled {
+				log.Infof("Capability %q has been enabled in %q/%q configmap. Restarting the container "+
+					"to enable it.", common.WorkloadDomainIsolation, common.KubeSystemNamespace,
+					common.WCPCapabilityConfigMapName)
+				capabilityExit()
+				return
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// getWCPCapability returns the value of the given capability in the WCP
+// cluster capabilities configmap. A capability missing from the configmap
+// is disabled.
+func getWCPCapability(ctx context.Context, k8sClient clientset.Interface, capability string) (bool, error) {
+	configMap, err := k8sClient.CoreV1().ConfigMaps(common.KubeSystemNamespace).Get(ctx,
+		common.WCPCapabilityConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+	value, ok := configMap.Data[capability]
+	if !ok {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wcp
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+)
+
+func TestGetWCPCapability(t *testing.T) {
+	ctx := context.Background()
+	k8sClient := fake.NewSimpleClientset()
+	if _, err := getWCPCapability(ctx, k8sClient, common.WorkloadDomainIsolation); err == nil {
+		t.Fatal("expected an error when the capabilities configmap is missing")
+	}
+
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      common.WCPCapabilityConfigMapName,
+			Namespace: common.KubeSystemNamespace,
+		},
+		Data: map[string]string{common.WorkloadDomainIsolation: "true"},
+	}
+	if _, err := k8sClient.CoreV1().ConfigMaps(common.KubeSystemNamespace).Create(ctx, configMap,
+		metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	enabled, err := getWCPCapability(ctx, k8sClient, common.WorkloadDomainIsolation)
+	if err != nil || !enabled {
+		t.Fatalf("expected capability to be enabled, got %t, err: %v", enabled, err)
+	}
+	enabled, err = getWCPCapability(ctx, k8sClient, "missing-capability")
+	if err != nil || enabled {
+		t.Fatalf("expected missing capability to be disabled, got %t, err: %v", enabled, err)
+	}
+
+	exited := false
+	capabilityExit = func() { exited = true }
+	handleEnablementOfWLDICapability(ctx, k8sClient)
+	if !exited {
+		t.Fatal("expected the container to be restarted once the capability is enabled")
+	}
+}
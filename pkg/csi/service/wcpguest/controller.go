@@ -1227,6 +1227,22 @@ func (c *controller) ControllerExpandVolume(ctx context.Context, req *csi.Contro
 		// Check if GC PVC request size is greater than SV PVC request size
 		switch (gcPvcRequestSize).Cmp(svPvcRequestSize) {
 		case 1:
+			// Fail fast with the quota reason instead of leaving the supervisor
+			// PVC resize pending on an exceeded storage quota.
+			additionalSize := gcPvcRequestSize.DeepCopy()
+			additionalSize.Sub(svPvcRequestSize)
+			var svStorageClassName string
+			if svPVC.Spec.StorageClassName != nil {
+				svStorageClassName = *svPVC.Spec.StorageClassName
+			}
+			err = checkSupervisorStorageQuota(ctx, c.supervisorClient, c.supervisorNamespace,
+				svStorageClassName, additionalSize)
+			if err != nil {
+				msg := fmt.Sprintf("failed to expand volume %q to %s. Error: %v", volumeID,
+					gcPvcRequestSize.String(), err)
+				log.Error(msg)
+				return nil, csifault.CSIInvalidArgumentFault, status.Error(codes.ResourceExhausted, msg)
+			}
 			// Update requested storage in SV PVC spec
 			svPvcClone := svPVC.DeepCopy()
 			svPvcClone.Spec.Resources.Requests[corev1.ResourceName(corev1.ResourceStorage)] = *gcPvcRequestSize
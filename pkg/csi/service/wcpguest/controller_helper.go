@@ -67,6 +67,10 @@ func validateGuestClusterCreateVolumeRequest(ctx context.Context, req *csi.Creat
 	if len(req.Name) <= 4 {
 		return logger.LogNewErrorCodef(log, codes.InvalidArgument, "Volume name %s is not valid", req.Name)
 	}
+	if common.IsMultiWriterBlockVolumeRequest(ctx, req.GetVolumeCapabilities()) {
+		return logger.LogNewErrorCode(log, codes.InvalidArgument,
+			"ReadWriteMany block volumes are not supported in guest cluster.")
+	}
 	tkgsHAEnabled := commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.TKGsHA)
 	// Get create params
 	for param, val := range req.GetParameters() {
@@ -450,3 +454,46 @@ func constructListSnapshotEntry(vs snap.VolumeSnapshot) *csi.ListSnapshotsRespon
 	}
 	return entry
 }
+
+// storageClassStorageQuotaKey returns the ResourceQuota key limiting the
+// storage requested by PVCs of the given StorageClass.
+func storageClassStorageQuotaKey(storageClassName string) v1.ResourceName {
+	return v1.ResourceName(storageClassName + ".storageclass.storage.k8s.io/" + string(v1.ResourceRequestsStorage))
+}
+
+// checkSupervisorStorageQuota returns an error describing the exceeded quota
+// if requesting additional storage for a PVC of the given StorageClass in the
+// supervisor namespace would exceed one of the ResourceQuotas of the namespace.
+// Failures to read the ResourceQuotas are logged and ignored, leaving the
+// enforcement to the supervisor.
+func checkSupervisorStorageQuota(ctx context.Context, supervisorClient clientset.Interface, namespace string,
+	storageClassName string, additional resource.Quantity) error {
+	log := logger.GetLogger(ctx)
+	quotaList, err := supervisorClient.CoreV1().ResourceQuotas(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Warnf("failed to list ResourceQuotas in supervisor namespace %q, skipping storage quota check. "+
+			"Error: %v", namespace, err)
+		return nil
+	}
+	keys := []v1.ResourceName{v1.ResourceRequestsStorage}
+	if storageClassName != "" {
+		keys = append(keys, storageClassStorageQuotaKey(storageClassName))
+	}
+	for _, quota := range quotaList.Items {
+		for _, key := range keys {
+			hard, ok := quota.Status.Hard[key]
+			if !ok {
+				continue
+			}
+			used := quota.Status.Used[key]
+			requested := used.DeepCopy()
+			requested.Add(additional)
+			if requested.Cmp(hard) > 0 {
+				return fmt.Errorf("expanding by %s exceeds quota %q in supervisor namespace %q: "+
+					"%s limited to %s, %s already used", additional.String(), quota.Name, namespace, key,
+					hard.String(), used.String())
+			}
+		}
+	}
+	return nil
+}
@@ -27,6 +27,7 @@ import (
 	v1 "k8s.io/api/core/v1"
 
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	clientset "k8s.io/client-go/kubernetes"
 	testclient "k8s.io/client-go/kubernetes/fake"
@@ -416,3 +417,37 @@ func TestGenerateGuestClusterRequestedTopologyJSON(t *testing.T) {
 	t.Logf("volumeAccessibleTopologyJSON %v match with expectedVolumeAccessibleTopologyJSON: %v",
 		volumeAccessibleTopologyJSON, expectedVolumeAccessibleTopologyJSON)
 }
+
+// TestCheckSupervisorStorageQuota helps unit test checkSupervisorStorageQuota function
+func TestCheckSupervisorStorageQuota(t *testing.T) {
+	ctx := context.Background()
+	quota := &v1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "storage-quota", Namespace: testNamespace},
+		Status: v1.ResourceQuotaStatus{
+			Hard: v1.ResourceList{
+				storageClassStorageQuotaKey(testStorageClass): resource.MustParse("10Gi"),
+			},
+			Used: v1.ResourceList{
+				storageClassStorageQuotaKey(testStorageClass): resource.MustParse("8Gi"),
+			},
+		},
+	}
+	supervisorClient := testclient.NewSimpleClientset(quota)
+
+	err := checkSupervisorStorageQuota(ctx, supervisorClient, testNamespace, testStorageClass,
+		resource.MustParse("2Gi"))
+	if err != nil {
+		t.Fatalf("expected expansion within the quota to be allowed. Err: %v", err)
+	}
+	err = checkSupervisorStorageQuota(ctx, supervisorClient, testNamespace, testStorageClass,
+		resource.MustParse("3Gi"))
+	if err == nil {
+		t.Fatalf("expected expansion exceeding the quota to be rejected")
+	}
+	t.Logf("expansion exceeding the quota rejected with: %v", err)
+	err = checkSupervisorStorageQuota(ctx, supervisorClient, testNamespace, "other-storageclass",
+		resource.MustParse("3Gi"))
+	if err != nil {
+		t.Fatalf("expected quota of another StorageClass to be ignored. Err: %v", err)
+	}
+}
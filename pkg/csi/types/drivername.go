@@ -0,0 +1,48 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package types
+
+import (
+	"os"
+)
+
+// DriverName returns the name the driver is registered with. It is the value
+// of the CSI_DRIVER_NAME environment variable if set, otherwise Name. Objects
+// of the driver, like PVs and VolumeAttachments, are recognized by this name.
+func DriverName() string {
+	if name := os.Getenv(EnvVarDriverName); name != "" {
+		return name
+	}
+	return Name
+}
+
+// InstanceObjectNameSuffix returns the suffix of the names of the cluster
+// scoped objects created by the driver, so that drivers installed side by side
+// don't share them. It's empty for the default driver name, and the driver
+// name prefixed with a dot otherwise.
+func InstanceObjectNameSuffix() string {
+	if DriverName() == Name {
+		return ""
+	}
+	return "." + DriverName()
+}
+
+// InstanceObjectName returns the name of the cluster scoped object created by
+// the driver for the given name, e.g. the name of a node.
+func InstanceObjectName(name string) string {
+	return name + InstanceObjectNameSuffix()
+}
@@ -43,4 +43,21 @@ const (
 	// Depending on the value, either controller and node service will be
 	// activated (The identity service is always activated).
 	EnvVarMode = "X_CSI_MODE"
+
+	// EnvVarDriverName specifies the name the driver is registered with, when
+	// it is not the default Name, e.g. for rebranded deployments or for
+	// several drivers installed side by side.
+	EnvVarDriverName = "CSI_DRIVER_NAME"
+
+	// EnvVarFSSStrictMode, when set to true, makes the driver refuse to start
+	// if its feature states ConfigMaps contain unknown feature state names or
+	// non boolean values.
+	EnvVarFSSStrictMode = "FSS_STRICT_MODE"
+
+	// EnvVarShutdownDrainTimeout is the maximum duration, e.g. "25s", the
+	// driver and the syncer wait on SIGTERM for in-flight operations to be
+	// persisted, for pending RPCs to finish and for caches to be flushed
+	// before exiting. It should be lower than the termination grace period
+	// of the pod.
+	EnvVarShutdownDrainTimeout = "X_CSI_SHUTDOWN_DRAIN_TIMEOUT"
 )
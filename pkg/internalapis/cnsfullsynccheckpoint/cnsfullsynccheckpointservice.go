@@ -0,0 +1,177 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cnsfullsynccheckpoint
+
+import (
+	"context"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	checkpointconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsfullsynccheckpoint/config"
+	checkpointv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsfullsynccheckpoint/v1alpha1"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
+)
+
+type checkpoint struct {
+	// k8sClient helps operate on CnsFullSyncCheckpoint custom resource.
+	k8sClient client.Client
+}
+
+var (
+	// checkpointServiceInstance is instance of checkpoint and implements
+	// interface for CheckpointService.
+	checkpointServiceInstance *checkpoint
+
+	// csiNamespace is the namespace on which vSphere CSI Driver is running.
+	csiNamespace = common.GetCSINamespace()
+)
+
+const (
+	// CRDGroupName represent the group of cnsfullsynccheckpoint CRD.
+	CRDGroupName = "cns.vmware.com"
+)
+
+// CheckpointService exposes interfaces to operate on the
+// CnsFullSyncCheckpoint CRs, which persist the progress of the full sync of
+// each vCenter so that it can resume where it left off after a restart.
+type CheckpointService interface {
+	// GetCheckpoint returns the CnsFullSyncCheckpoint of the given vCenter,
+	// or nil if there is none.
+	GetCheckpoint(ctx context.Context, vCenter string) (*checkpointv1alpha1.CnsFullSyncCheckpoint, error)
+
+	// SaveCheckpoint creates or updates the CnsFullSyncCheckpoint of the
+	// vCenter of the given spec.
+	SaveCheckpoint(ctx context.Context, spec checkpointv1alpha1.CnsFullSyncCheckpointSpec) error
+
+	// DeleteCheckpoint deletes the CnsFullSyncCheckpoint of the given vCenter.
+	DeleteCheckpoint(ctx context.Context, vCenter string) error
+}
+
+// InitCheckpointService returns the singleton CheckpointService.
+func InitCheckpointService(ctx context.Context) (CheckpointService, error) {
+	log := logger.GetLogger(ctx)
+	if checkpointServiceInstance == nil {
+		log.Info("Initializing full sync checkpoint service...")
+		// This is idempotent if CRD is pre-created then we continue with
+		// initialization of checkpointServiceInstance.
+		err := k8s.CreateCustomResourceDefinitionFromManifest(ctx,
+			checkpointconfig.EmbedCnsFullSyncCheckpointFile, checkpointconfig.EmbedCnsFullSyncCheckpointFileName)
+		if err != nil {
+			return nil, logger.LogNewErrorf(log, "failed to create full sync checkpoint CRD. Error: %v", err)
+		}
+		config, err := k8s.GetKubeConfig(ctx)
+		if err != nil {
+			return nil, logger.LogNewErrorf(log, "failed to get kubeconfig. err: %v", err)
+		}
+		k8sClient, err := k8s.NewClientForGroup(ctx, config, CRDGroupName)
+		if err != nil {
+			return nil, logger.LogNewErrorf(log, "failed to create k8sClient for full sync checkpoint service. "+
+				"Err: %v", err)
+		}
+		checkpointServiceInstance = &checkpoint{
+			k8sClient: k8sClient,
+		}
+		log.Info("full sync checkpoint service initialized")
+	}
+	return checkpointServiceInstance, nil
+}
+
+// checkpointName returns the name of the CnsFullSyncCheckpoint of the given
+// vCenter, which may contain a port.
+func checkpointName(vCenter string) string {
+	return strings.ReplaceAll(strings.ToLower(vCenter), ":", "-")
+}
+
+// GetCheckpoint returns the CnsFullSyncCheckpoint of the given vCenter,
+// or nil if there is none.
+func (checkpoint *checkpoint) GetCheckpoint(ctx context.Context, vCenter string) (
+	*checkpointv1alpha1.CnsFullSyncCheckpoint, error) {
+	log := logger.GetLogger(ctx)
+	instance := &checkpointv1alpha1.CnsFullSyncCheckpoint{}
+	err := checkpoint.k8sClient.Get(ctx, client.ObjectKey{Namespace: csiNamespace,
+		Name: checkpointName(vCenter)}, instance)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, logger.LogNewErrorf(log, "failed to get CnsFullSyncCheckpoint for vCenter: %q. Error: %v",
+			vCenter, err)
+	}
+	return instance, nil
+}
+
+// SaveCheckpoint creates or updates the CnsFullSyncCheckpoint of the vCenter
+// of the given spec.
+func (checkpoint *checkpoint) SaveCheckpoint(ctx context.Context,
+	spec checkpointv1alpha1.CnsFullSyncCheckpointSpec) error {
+	log := logger.GetLogger(ctx)
+	name := checkpointName(spec.VCenterServer)
+	instance := &checkpointv1alpha1.CnsFullSyncCheckpoint{}
+	err := checkpoint.k8sClient.Get(ctx, client.ObjectKey{Namespace: csiNamespace, Name: name}, instance)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return logger.LogNewErrorf(log, "failed to get CnsFullSyncCheckpoint for vCenter: %q. Error: %v",
+				spec.VCenterServer, err)
+		}
+		instance = &checkpointv1alpha1.CnsFullSyncCheckpoint{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: csiNamespace,
+			},
+			Spec: spec,
+		}
+		if err := checkpoint.k8sClient.Create(ctx, instance); err != nil {
+			return logger.LogNewErrorf(log, "failed to create CnsFullSyncCheckpoint for vCenter: %q in the "+
+				"namespace: %q. Error: %v", spec.VCenterServer, csiNamespace, err)
+		}
+		log.Infof("Successfully created CnsFullSyncCheckpoint %q for vCenter: %q", name, spec.VCenterServer)
+		return nil
+	}
+	instance.Spec = spec
+	if err := checkpoint.k8sClient.Update(ctx, instance); err != nil {
+		return logger.LogNewErrorf(log, "failed to update CnsFullSyncCheckpoint for vCenter: %q in the "+
+			"namespace: %q. Error: %v", spec.VCenterServer, csiNamespace, err)
+	}
+	log.Debugf("Successfully updated CnsFullSyncCheckpoint %q with phases %v", name, spec.Phases)
+	return nil
+}
+
+// DeleteCheckpoint deletes the CnsFullSyncCheckpoint of the given vCenter.
+func (checkpoint *checkpoint) DeleteCheckpoint(ctx context.Context, vCenter string) error {
+	log := logger.GetLogger(ctx)
+	object := &checkpointv1alpha1.CnsFullSyncCheckpoint{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      checkpointName(vCenter),
+			Namespace: csiNamespace,
+		},
+	}
+	err := checkpoint.k8sClient.Delete(ctx, object)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return logger.LogNewErrorf(log, "failed to delete CnsFullSyncCheckpoint for vCenter: %q from "+
+			"namespace: %q. Error: %v", vCenter, csiNamespace, err)
+	}
+	log.Infof("Successfully deleted CnsFullSyncCheckpoint for vCenter: %q", vCenter)
+	return nil
+}
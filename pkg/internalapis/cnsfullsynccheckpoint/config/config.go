@@ -0,0 +1,8 @@
+package config
+
+import "embed"
+
+//go:embed cns.vmware.com_cnsfullsynccheckpoints.yaml
+var EmbedCnsFullSyncCheckpointFile embed.FS
+
+const EmbedCnsFullSyncCheckpointFileName = "cns.vmware.com_cnsfullsynccheckpoints.yaml"
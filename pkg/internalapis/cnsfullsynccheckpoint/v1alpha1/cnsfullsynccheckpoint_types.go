@@ -0,0 +1,70 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// CRDSingular represents the singular name of CnsFullSyncCheckpoint CRD.
+	CRDSingular = "cnsfullsynccheckpoint"
+	// CRDPlural represents the plural name of CnsFullSyncCheckpoint CRD.
+	CRDPlural = "cnsfullsynccheckpoints"
+)
+
+// CnsFullSyncCheckpointSpec defines the progress of an ongoing full sync
+// of a vCenter.
+type CnsFullSyncCheckpointSpec struct {
+	// VCenterServer is the IP/FQDN of the vCenter host being synced.
+	VCenterServer string `json:"vCenterServer"`
+
+	// StartTime is the time at which the full sync started.
+	StartTime metav1.Time `json:"startTime"`
+
+	// Phases are the progress of the create, update and delete phases of the
+	// full sync, keyed by phase.
+	Phases map[string]CnsFullSyncPhaseCheckpoint `json:"phases,omitempty"`
+}
+
+// CnsFullSyncPhaseCheckpoint defines the progress of a phase of an ongoing
+// full sync. The phases process the volumes in the order of their IDs.
+type CnsFullSyncPhaseCheckpoint struct {
+	// LastProcessedVolumeID is the cursor of the phase, i.e. the ID of the
+	// last volume processed by the phase. The volumes with IDs up to it are
+	// skipped when the phase is resumed.
+	LastProcessedVolumeID string `json:"lastProcessedVolumeID"`
+}
+
+//+kubebuilder:object:root=true
+
+// CnsFullSyncCheckpoint is the Schema for the cnsfullsynccheckpoints API
+type CnsFullSyncCheckpoint struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec CnsFullSyncCheckpointSpec `json:"spec"`
+}
+
+//+kubebuilder:object:root=true
+
+// CnsFullSyncCheckpointList contains a list of CnsFullSyncCheckpoint
+type CnsFullSyncCheckpointList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CnsFullSyncCheckpoint `json:"items"`
+}
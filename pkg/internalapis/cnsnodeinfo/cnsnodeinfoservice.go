@@ -0,0 +1,230 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cnsnodeinfo
+
+import (
+	"context"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	cnsnodeinfoconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsnodeinfo/config"
+	cnsnodeinfov1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsnodeinfo/v1alpha1"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
+)
+
+type nodeInfo struct {
+	// nodeInfoInformer is the informer for CnsNodeInfo CRs.
+	nodeInfoInformer cache.SharedIndexInformer
+	// k8sClient helps operate on CnsNodeInfo custom resource.
+	k8sClient client.Client
+}
+
+var (
+	// nodeInfoServiceInstance is instance of nodeInfo and implements
+	// interface for NodeInfoService.
+	nodeInfoServiceInstance *nodeInfo
+
+	// csiNamespace is the namespace on which vSphere CSI Driver is running.
+	csiNamespace = common.GetCSINamespace()
+)
+
+const (
+	// CRDGroupName represent the group of cnsnodeinfo CRD.
+	CRDGroupName = "cns.vmware.com"
+
+	// nodeUUIDIndex is the name of the informer index of the CnsNodeInfo
+	// instances by node UUID.
+	nodeUUIDIndex = "nodeUUID"
+)
+
+// NodeInfoService exposes interfaces to operate on the CnsNodeInfo CRs,
+// which persist the vCenter details of the node VMs so that they don't have
+// to be looked up in vCenter on every attach.
+type NodeInfoService interface {
+	// GetNodeInfo returns the CnsNodeInfo of the node with the given name.
+	GetNodeInfo(ctx context.Context, nodeName string) (*cnsnodeinfov1alpha1.CnsNodeInfo, error)
+
+	// GetNodeInfoByUUID returns the CnsNodeInfo of the node VM with the given UUID.
+	GetNodeInfoByUUID(ctx context.Context, nodeUUID string) (*cnsnodeinfov1alpha1.CnsNodeInfo, error)
+
+	// UpdateNodeInfo creates or updates the CnsNodeInfo of the node of the given spec.
+	UpdateNodeInfo(ctx context.Context, spec cnsnodeinfov1alpha1.CnsNodeInfoSpec) error
+
+	// DeleteNodeInfo deletes the CnsNodeInfo of the node with the given name.
+	DeleteNodeInfo(ctx context.Context, nodeName string) error
+}
+
+// InitNodeInfoService returns the singleton NodeInfoService.
+func InitNodeInfoService(ctx context.Context) (NodeInfoService, error) {
+	log := logger.GetLogger(ctx)
+	if nodeInfoServiceInstance == nil {
+		log.Info("Initializing nodeInfo service...")
+		// This is idempotent if CRD is pre-created then we continue with
+		// initialization of nodeInfoServiceInstance.
+		err := k8s.CreateCustomResourceDefinitionFromManifest(ctx,
+			cnsnodeinfoconfig.EmbedCnsNodeInfoFile, cnsnodeinfoconfig.EmbedCnsNodeInfoFileName)
+		if err != nil {
+			return nil, logger.LogNewErrorf(log, "failed to create node info CRD. Error: %v", err)
+		}
+		config, err := k8s.GetKubeConfig(ctx)
+		if err != nil {
+			return nil, logger.LogNewErrorf(log, "failed to get kubeconfig. err: %v", err)
+		}
+		k8sClient, err := k8s.NewClientForGroup(ctx, config, CRDGroupName)
+		if err != nil {
+			return nil, logger.LogNewErrorf(log, "failed to create k8sClient for nodeinfo service. "+
+				"Err: %v", err)
+		}
+		log.Infof("Starting Informer for cnsnodeinfoes")
+		informer, err := k8s.GetDynamicInformer(ctx, cnsnodeinfov1alpha1.SchemeGroupVersion.Group,
+			cnsnodeinfov1alpha1.SchemeGroupVersion.Version, cnsnodeinfov1alpha1.CRDPlural,
+			csiNamespace, config, true)
+		if err != nil {
+			return nil, logger.LogNewErrorf(log, "failed to create dynamic informer for cnsnodeinfoes "+
+				"CRD. Err: %v", err)
+		}
+		err = informer.Informer().AddIndexers(cache.Indexers{nodeUUIDIndex: nodeUUIDIndexFunc})
+		if err != nil {
+			return nil, logger.LogNewErrorf(log, "failed to add node UUID index to cnsnodeinfoes informer. "+
+				"Err: %v", err)
+		}
+		go func() {
+			stopCh := make(chan struct{})
+			informer.Informer().Run(stopCh)
+		}()
+		nodeInfoServiceInstance = &nodeInfo{
+			nodeInfoInformer: informer.Informer(),
+			k8sClient:        k8sClient,
+		}
+		log.Info("nodeInfo service initialized")
+	}
+	return nodeInfoServiceInstance, nil
+}
+
+// nodeUUIDIndexFunc indexes the CnsNodeInfo instances by the lower case
+// UUID of their node VM.
+func nodeUUIDIndexFunc(obj interface{}) ([]string, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, nil
+	}
+	nodeUUID, found, err := unstructured.NestedString(u.Object, "spec", "nodeUUID")
+	if err != nil || !found || nodeUUID == "" {
+		return nil, nil
+	}
+	return []string{strings.ToLower(nodeUUID)}, nil
+}
+
+// GetNodeInfo returns the CnsNodeInfo of the node with the given name.
+func (nodeInfo *nodeInfo) GetNodeInfo(ctx context.Context, nodeName string) (
+	*cnsnodeinfov1alpha1.CnsNodeInfo, error) {
+	log := logger.GetLogger(ctx)
+	info, found, err := nodeInfo.nodeInfoInformer.GetStore().GetByKey(csiNamespace + "/" + nodeName)
+	if err != nil || !found {
+		return nil, logger.LogNewErrorf(log, "could not find CnsNodeInfo instance for node: %q", nodeName)
+	}
+	return toCnsNodeInfo(ctx, info)
+}
+
+// GetNodeInfoByUUID returns the CnsNodeInfo of the node VM with the given UUID.
+func (nodeInfo *nodeInfo) GetNodeInfoByUUID(ctx context.Context, nodeUUID string) (
+	*cnsnodeinfov1alpha1.CnsNodeInfo, error) {
+	log := logger.GetLogger(ctx)
+	infos, err := nodeInfo.nodeInfoInformer.GetIndexer().ByIndex(nodeUUIDIndex, strings.ToLower(nodeUUID))
+	if err != nil || len(infos) == 0 {
+		return nil, logger.LogNewErrorf(log, "could not find CnsNodeInfo instance for node UUID: %q", nodeUUID)
+	}
+	return toCnsNodeInfo(ctx, infos[0])
+}
+
+// UpdateNodeInfo creates or updates the CnsNodeInfo of the node of the given spec.
+func (nodeInfo *nodeInfo) UpdateNodeInfo(ctx context.Context, spec cnsnodeinfov1alpha1.CnsNodeInfoSpec) error {
+	log := logger.GetLogger(ctx)
+	instance := &cnsnodeinfov1alpha1.CnsNodeInfo{}
+	err := nodeInfo.k8sClient.Get(ctx, client.ObjectKey{Namespace: csiNamespace, Name: spec.NodeName}, instance)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return logger.LogNewErrorf(log, "failed to get CnsNodeInfo for node: %q. Error: %v",
+				spec.NodeName, err)
+		}
+		instance = &cnsnodeinfov1alpha1.CnsNodeInfo{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      spec.NodeName,
+				Namespace: csiNamespace,
+			},
+			Spec: spec,
+		}
+		if err := nodeInfo.k8sClient.Create(ctx, instance); err != nil {
+			return logger.LogNewErrorf(log, "failed to create CnsNodeInfo for node: %q in the namespace: %q. "+
+				"Error: %v", spec.NodeName, csiNamespace, err)
+		}
+		log.Infof("Successfully created CnsNodeInfo for node: %q with spec: %+v", spec.NodeName, spec)
+		return nil
+	}
+	instance.Spec = spec
+	if err := nodeInfo.k8sClient.Update(ctx, instance); err != nil {
+		return logger.LogNewErrorf(log, "failed to update CnsNodeInfo for node: %q in the namespace: %q. "+
+			"Error: %v", spec.NodeName, csiNamespace, err)
+	}
+	log.Debugf("Successfully updated CnsNodeInfo for node: %q with spec: %+v", spec.NodeName, spec)
+	return nil
+}
+
+// DeleteNodeInfo deletes the CnsNodeInfo of the node with the given name.
+func (nodeInfo *nodeInfo) DeleteNodeInfo(ctx context.Context, nodeName string) error {
+	log := logger.GetLogger(ctx)
+	object := &cnsnodeinfov1alpha1.CnsNodeInfo{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      nodeName,
+			Namespace: csiNamespace,
+		},
+	}
+	err := nodeInfo.k8sClient.Delete(ctx, object)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Infof("CnsNodeInfo is already deleted for node: %q", nodeName)
+			return nil
+		}
+		return logger.LogNewErrorf(log, "failed to delete CnsNodeInfo for node: %q from namespace: %q. "+
+			"Error: %v", nodeName, csiNamespace, err)
+	}
+	log.Infof("Successfully deleted CnsNodeInfo for node: %q from namespace: %q", nodeName, csiNamespace)
+	return nil
+}
+
+// toCnsNodeInfo converts an object of the informer store to a CnsNodeInfo.
+func toCnsNodeInfo(ctx context.Context, obj interface{}) (*cnsnodeinfov1alpha1.CnsNodeInfo, error) {
+	log := logger.GetLogger(ctx)
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, logger.LogNewErrorf(log, "unexpected object %T in the cnsnodeinfoes informer", obj)
+	}
+	cnsNodeInfo := &cnsnodeinfov1alpha1.CnsNodeInfo{}
+	err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, cnsNodeInfo)
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to parse cnsNodeInfo object: %v, err: %v", obj, err)
+	}
+	return cnsNodeInfo, nil
+}
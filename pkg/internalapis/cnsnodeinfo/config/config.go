@@ -0,0 +1,8 @@
+package config
+
+import "embed"
+
+//go:embed cns.vmware.com_cnsnodeinfoes.yaml
+var EmbedCnsNodeInfoFile embed.FS
+
+const EmbedCnsNodeInfoFileName = "cns.vmware.com_cnsnodeinfoes.yaml"
@@ -0,0 +1,75 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// CRDSingular represents the singular name of CnsNodeInfo CRD.
+	CRDSingular = "cnsnodeinfo"
+	// CRDPlural represents the plural name of CnsNodeInfo CRD.
+	CRDPlural = "cnsnodeinfoes"
+)
+
+// CnsNodeInfoSpec defines the vCenter details of a node VM.
+type CnsNodeInfoSpec struct {
+	// NodeName is the name of the Kubernetes node.
+	NodeName string `json:"nodeName"`
+
+	// NodeUUID is the BIOS UUID of the node VM.
+	NodeUUID string `json:"nodeUUID"`
+
+	// VCenterServer is the IP/FQDN of the vCenter host managing the node VM.
+	VCenterServer string `json:"vCenterServer"`
+
+	// Datacenter is the managed object ID of the datacenter of the node VM.
+	Datacenter string `json:"datacenter"`
+
+	// VirtualMachine is the managed object ID of the node VM.
+	VirtualMachine string `json:"virtualMachine"`
+
+	// Host is the managed object ID of the ESXi host running the node VM.
+	Host string `json:"host,omitempty"`
+
+	// AccessibleDatastores are the URLs of the datastores accessible from Host.
+	AccessibleDatastores []string `json:"accessibleDatastores,omitempty"`
+
+	// LastUpdateTime is the time at which the host and the accessible
+	// datastores of the node VM were last retrieved from vCenter.
+	LastUpdateTime *metav1.Time `json:"lastUpdateTime,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// CnsNodeInfo is the Schema for the cnsnodeinfoes API
+type CnsNodeInfo struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec CnsNodeInfoSpec `json:"spec"`
+}
+
+//+kubebuilder:object:root=true
+
+// CnsNodeInfoList contains a list of CnsNodeInfo
+type CnsNodeInfoList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CnsNodeInfo `json:"items"`
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DeltaPhase is the phase of a CnsSnapshotDelta.
+type DeltaPhase string
+
+const (
+	// DeltaPhasePending indicates the changed areas are not queried yet.
+	DeltaPhasePending DeltaPhase = "Pending"
+	// DeltaPhaseCompleted indicates the changed areas are set in the status.
+	DeltaPhaseCompleted DeltaPhase = "Completed"
+	// DeltaPhaseFailed indicates the query of the changed areas failed and is
+	// not retried.
+	DeltaPhaseFailed DeltaPhase = "Failed"
+)
+
+// CnsSnapshotDeltaSpec defines the desired state of CnsSnapshotDelta
+type CnsSnapshotDeltaSpec struct {
+	// VolumeSnapshotName is the name of the VolumeSnapshot, in the namespace
+	// of the CnsSnapshotDelta instance, whose changed areas are queried.
+	VolumeSnapshotName string `json:"volumeSnapshotName"`
+
+	// BaseVolumeSnapshotName is the name of an earlier VolumeSnapshot of the
+	// same volume, in the namespace of the CnsSnapshotDelta instance. The
+	// areas changed since this snapshot are queried. If it is not set, the
+	// allocated areas of the snapshot are queried.
+	BaseVolumeSnapshotName string `json:"baseVolumeSnapshotName,omitempty"`
+
+	// StartOffset is the offset, in bytes, from which the changed areas are
+	// queried. It is set to the NextOffset of a previous CnsSnapshotDelta to
+	// query the areas it didn't return.
+	StartOffset int64 `json:"startOffset,omitempty"`
+
+	// MaxAreas is the maximum number of changed areas returned in the status.
+	// Defaults to 10000.
+	MaxAreas int `json:"maxAreas,omitempty"`
+}
+
+// DiskArea is an area of a volume.
+type DiskArea struct {
+	// Offset is the offset, in bytes, of the area.
+	Offset int64 `json:"offset"`
+	// Length is the length, in bytes, of the area.
+	Length int64 `json:"length"`
+}
+
+// CnsSnapshotDeltaStatus defines the observed state of CnsSnapshotDelta
+type CnsSnapshotDeltaStatus struct {
+	// Phase is the current phase of the query.
+	Phase DeltaPhase `json:"phase,omitempty"`
+
+	// VolumeID is the ID of the CNS volume of the snapshots.
+	VolumeID string `json:"volumeID,omitempty"`
+
+	// CapacityBytes is the capacity of the volume.
+	CapacityBytes int64 `json:"capacityBytes,omitempty"`
+
+	// ChangedAreas are the changed areas of the snapshot, sorted by offset.
+	ChangedAreas []DiskArea `json:"changedAreas,omitempty"`
+
+	// ChangedBytes is the total length of the changed areas.
+	ChangedBytes int64 `json:"changedBytes,omitempty"`
+
+	// NextOffset is set if the changed areas were truncated to MaxAreas. The
+	// remaining changed areas are queried by a CnsSnapshotDelta with this
+	// StartOffset.
+	NextOffset int64 `json:"nextOffset,omitempty"`
+
+	// The last error encountered during the query, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CnsSnapshotDelta is the Schema for the cnssnapshotdeltas API
+type CnsSnapshotDelta struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CnsSnapshotDeltaSpec   `json:"spec,omitempty"`
+	Status CnsSnapshotDeltaStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CnsSnapshotDeltaList contains a list of CnsSnapshotDelta
+type CnsSnapshotDeltaList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CnsSnapshotDelta `json:"items"`
+}
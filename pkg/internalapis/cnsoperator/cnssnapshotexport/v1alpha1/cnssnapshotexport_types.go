@@ -0,0 +1,119 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ExportPhase is the phase of a CnsSnapshotExport.
+type ExportPhase string
+
+const (
+	// ExportPhasePending indicates the export has not started yet.
+	ExportPhasePending ExportPhase = "Pending"
+	// ExportPhaseUploading indicates the temporary clone of the snapshot is
+	// created, and the data mover job streams its content to the bucket.
+	ExportPhaseUploading ExportPhase = "Uploading"
+	// ExportPhaseCompleted indicates the content of the snapshot is uploaded.
+	ExportPhaseCompleted ExportPhase = "Completed"
+	// ExportPhaseFailed indicates the export failed and is not retried.
+	ExportPhaseFailed ExportPhase = "Failed"
+)
+
+// CnsSnapshotExportSpec defines the desired state of CnsSnapshotExport
+type CnsSnapshotExportSpec struct {
+	// VolumeSnapshotName is the name of the VolumeSnapshot, in the namespace
+	// of the CnsSnapshotExport instance, whose content is exported.
+	VolumeSnapshotName string `json:"volumeSnapshotName"`
+
+	// StorageClassName is the name of the StorageClass of the temporary clone
+	// of the snapshot. Defaults to the StorageClass of the source PVC of the
+	// snapshot.
+	StorageClassName string `json:"storageClassName,omitempty"`
+
+	// Endpoint is the URL of the S3-compatible endpoint, e.g.
+	// https://s3.us-west-2.amazonaws.com. Objects are addressed path-style.
+	Endpoint string `json:"endpoint"`
+
+	// Region is the region of the bucket used to sign the requests. Defaults
+	// to us-east-1.
+	Region string `json:"region,omitempty"`
+
+	// Bucket is the name of the bucket the content is uploaded to.
+	Bucket string `json:"bucket"`
+
+	// Key is the key of the object the content is uploaded to. Defaults to
+	// <namespace>/<volumeSnapshotName>.img.
+	Key string `json:"key,omitempty"`
+
+	// CredentialsSecretName is the name of the secret, in the namespace of the
+	// CnsSnapshotExport instance, holding the accessKeyID and secretAccessKey
+	// keys used to access the bucket.
+	CredentialsSecretName string `json:"credentialsSecretName"`
+}
+
+// CnsSnapshotExportStatus defines the observed state of CnsSnapshotExport
+type CnsSnapshotExportStatus struct {
+	// Phase is the current phase of the export.
+	Phase ExportPhase `json:"phase,omitempty"`
+
+	// ClonePvcName is the name of the temporary PVC cloned from the snapshot.
+	ClonePvcName string `json:"clonePvcName,omitempty"`
+
+	// JobName is the name of the data mover job uploading the content of the
+	// temporary clone.
+	JobName string `json:"jobName,omitempty"`
+
+	// Key is the key of the object the content is uploaded to.
+	Key string `json:"key,omitempty"`
+
+	// SizeBytes is the size of the uploaded content.
+	SizeBytes int64 `json:"sizeBytes,omitempty"`
+
+	// Checksum is the hex encoded SHA-256 checksum of the uploaded content. It
+	// is also uploaded to the <key>.sha256 object of the bucket.
+	Checksum string `json:"checksum,omitempty"`
+
+	// ResumedParts is the number of parts of the upload which were already
+	// uploaded by a previous attempt of the data mover, and were not
+	// uploaded again.
+	ResumedParts int `json:"resumedParts,omitempty"`
+
+	// The last error encountered during the export, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CnsSnapshotExport is the Schema for the cnssnapshotexports API
+type CnsSnapshotExport struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CnsSnapshotExportSpec   `json:"spec,omitempty"`
+	Status CnsSnapshotExportStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CnsSnapshotExportList contains a list of CnsSnapshotExport
+type CnsSnapshotExportList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CnsSnapshotExport `json:"items"`
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MigrationPhase is the phase of a CnsVolumePolicyMigration.
+type MigrationPhase string
+
+const (
+	// MigrationPhasePending indicates the migration has not started yet.
+	MigrationPhasePending MigrationPhase = "Pending"
+	// MigrationPhaseReconfiguring indicates the storage policy of the volume
+	// is being changed.
+	MigrationPhaseReconfiguring MigrationPhase = "Reconfiguring"
+	// MigrationPhaseWaitingForCompliance indicates the storage policy of the
+	// volume was changed, and the volume is being resynced to comply with it.
+	MigrationPhaseWaitingForCompliance MigrationPhase = "WaitingForCompliance"
+	// MigrationPhaseCompleted indicates the volume complies with the new
+	// storage policy.
+	MigrationPhaseCompleted MigrationPhase = "Completed"
+	// MigrationPhaseFailed indicates the migration failed and is not retried.
+	MigrationPhaseFailed MigrationPhase = "Failed"
+)
+
+// CnsVolumePolicyMigrationSpec defines the desired state of CnsVolumePolicyMigration
+type CnsVolumePolicyMigrationSpec struct {
+	// PvcName is the name of the PVC, in the namespace of the
+	// CnsVolumePolicyMigration instance, whose volume is migrated.
+	PvcName string `json:"pvcName"`
+
+	// StoragePolicyName is the name of the storage policy to apply to the
+	// volume.
+	StoragePolicyName string `json:"storagePolicyName"`
+}
+
+// CnsVolumePolicyMigrationStatus defines the observed state of CnsVolumePolicyMigration
+type CnsVolumePolicyMigrationStatus struct {
+	// Phase is the current phase of the migration.
+	Phase MigrationPhase `json:"phase,omitempty"`
+
+	// VolumeID is the ID of the CNS volume bound to the PVC.
+	VolumeID string `json:"volumeID,omitempty"`
+
+	// SourceStoragePolicyID is the ID of the storage policy of the volume
+	// before the migration.
+	SourceStoragePolicyID string `json:"sourceStoragePolicyID,omitempty"`
+
+	// TargetStoragePolicyID is the ID of the storage policy to apply.
+	TargetStoragePolicyID string `json:"targetStoragePolicyID,omitempty"`
+
+	// ComplianceStatus is the last compliance status of the volume with the
+	// target storage policy reported by CNS.
+	ComplianceStatus string `json:"complianceStatus,omitempty"`
+
+	// The last error encountered during the migration, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CnsVolumePolicyMigration is the Schema for the cnsvolumepolicymigrations API
+type CnsVolumePolicyMigration struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CnsVolumePolicyMigrationSpec   `json:"spec,omitempty"`
+	Status CnsVolumePolicyMigrationStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CnsVolumePolicyMigrationList contains a list of CnsVolumePolicyMigration
+type CnsVolumePolicyMigrationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CnsVolumePolicyMigration `json:"items"`
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CnsVolumeRestoreSpec defines the desired state of CnsVolumeRestore
+type CnsVolumeRestoreSpec struct {
+	// VolumeID is the ID of the archived volume to restore.
+	VolumeID string `json:"volumeID"`
+
+	// PvcName is the name of the PVC to create in the namespace of the
+	// CnsVolumeRestore instance, bound to the restored volume.
+	PvcName string `json:"pvcName"`
+
+	// StorageClassName is the storage class of the restored PV and PVC.
+	StorageClassName string `json:"storageClassName"`
+
+	// AccessMode is the access mode of the restored PV and PVC.
+	// Defaults to ReadWriteOnce.
+	AccessMode v1.PersistentVolumeAccessMode `json:"accessMode,omitempty"`
+}
+
+// CnsVolumeRestoreStatus defines the observed state of CnsVolumeRestore
+type CnsVolumeRestoreStatus struct {
+	// Restored indicates whether the volume was restored and bound to the PVC.
+	Restored bool `json:"restored"`
+
+	// PvName is the name of the PV created for the restored volume.
+	PvName string `json:"pvName,omitempty"`
+
+	// The last error encountered while restoring the volume, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CnsVolumeRestore is the Schema for the cnsvolumerestores API
+type CnsVolumeRestore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CnsVolumeRestoreSpec   `json:"spec,omitempty"`
+	Status CnsVolumeRestoreStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CnsVolumeRestoreList contains a list of CnsVolumeRestore
+type CnsVolumeRestoreList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CnsVolumeRestore `json:"items"`
+}
@@ -11,3 +11,23 @@ const EmbedCnsFileVolumeClientFileName = "cnsfilevolumeclient_crd.yaml"
 var EmbedTriggerCsiFullSync embed.FS
 
 const EmbedTriggerCsiFullSyncName = "triggercsifullsync_crd.yaml"
+
+//go:embed cnsvolumerestore_crd.yaml
+var EmbedCnsVolumeRestoreFile embed.FS
+
+const EmbedCnsVolumeRestoreFileName = "cnsvolumerestore_crd.yaml"
+
+//go:embed cnsvolumepolicymigration_crd.yaml
+var EmbedCnsVolumePolicyMigrationFile embed.FS
+
+const EmbedCnsVolumePolicyMigrationFileName = "cnsvolumepolicymigration_crd.yaml"
+
+//go:embed cnssnapshotexport_crd.yaml
+var EmbedCnsSnapshotExportFile embed.FS
+
+const EmbedCnsSnapshotExportFileName = "cnssnapshotexport_crd.yaml"
+
+//go:embed cnssnapshotdelta_crd.yaml
+var EmbedCnsSnapshotDeltaFile embed.FS
+
+const EmbedCnsSnapshotDeltaFileName = "cnssnapshotdelta_crd.yaml"
@@ -0,0 +1,126 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cnsusagereport
+
+import (
+	"context"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	usagereportconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsusagereport/config"
+	usagereportv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsusagereport/v1alpha1"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
+)
+
+type usageReport struct {
+	// k8sClient helps operate on CnsUsageReport custom resource.
+	k8sClient client.Client
+}
+
+var (
+	// usageReportServiceInstance is instance of usageReport and implements
+	// interface for UsageReportService.
+	usageReportServiceInstance *usageReport
+
+	// csiNamespace is the namespace on which vSphere CSI Driver is running.
+	csiNamespace = common.GetCSINamespace()
+)
+
+const (
+	// CRDGroupName represent the group of cnsusagereport CRD.
+	CRDGroupName = "cns.vmware.com"
+
+	// usageReportName is the name of the CnsUsageReport of the cluster.
+	usageReportName = "vsphere-csi-usage-report"
+)
+
+// UsageReportService exposes interfaces to operate on the CnsUsageReport CR,
+// which holds the latest anonymized usage counts of the driver in the
+// cluster.
+type UsageReportService interface {
+	// SaveUsageReport creates or updates the CnsUsageReport of the cluster
+	// with the given spec.
+	SaveUsageReport(ctx context.Context, spec usagereportv1alpha1.CnsUsageReportSpec) error
+}
+
+// InitUsageReportService returns the singleton UsageReportService.
+func InitUsageReportService(ctx context.Context) (UsageReportService, error) {
+	log := logger.GetLogger(ctx)
+	if usageReportServiceInstance == nil {
+		log.Info("Initializing usage report service...")
+		// This is idempotent if CRD is pre-created then we continue with
+		// initialization of usageReportServiceInstance.
+		err := k8s.CreateCustomResourceDefinitionFromManifest(ctx,
+			usagereportconfig.EmbedCnsUsageReportFile, usagereportconfig.EmbedCnsUsageReportFileName)
+		if err != nil {
+			return nil, logger.LogNewErrorf(log, "failed to create usage report CRD. Error: %v", err)
+		}
+		config, err := k8s.GetKubeConfig(ctx)
+		if err != nil {
+			return nil, logger.LogNewErrorf(log, "failed to get kubeconfig. err: %v", err)
+		}
+		k8sClient, err := k8s.NewClientForGroup(ctx, config, CRDGroupName)
+		if err != nil {
+			return nil, logger.LogNewErrorf(log, "failed to create k8sClient for usage report service. "+
+				"Err: %v", err)
+		}
+		usageReportServiceInstance = &usageReport{
+			k8sClient: k8sClient,
+		}
+		log.Info("usage report service initialized")
+	}
+	return usageReportServiceInstance, nil
+}
+
+// SaveUsageReport creates or updates the CnsUsageReport of the cluster with
+// the given spec.
+func (usageReport *usageReport) SaveUsageReport(ctx context.Context,
+	spec usagereportv1alpha1.CnsUsageReportSpec) error {
+	log := logger.GetLogger(ctx)
+	instance := &usagereportv1alpha1.CnsUsageReport{}
+	err := usageReport.k8sClient.Get(ctx, client.ObjectKey{Namespace: csiNamespace, Name: usageReportName},
+		instance)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return logger.LogNewErrorf(log, "failed to get CnsUsageReport %q. Error: %v", usageReportName, err)
+		}
+		instance = &usagereportv1alpha1.CnsUsageReport{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      usageReportName,
+				Namespace: csiNamespace,
+			},
+			Spec: spec,
+		}
+		if err := usageReport.k8sClient.Create(ctx, instance); err != nil {
+			return logger.LogNewErrorf(log, "failed to create CnsUsageReport %q in the namespace: %q. Error: %v",
+				usageReportName, csiNamespace, err)
+		}
+		log.Infof("Successfully created CnsUsageReport %q", usageReportName)
+		return nil
+	}
+	instance.Spec = spec
+	if err := usageReport.k8sClient.Update(ctx, instance); err != nil {
+		return logger.LogNewErrorf(log, "failed to update CnsUsageReport %q in the namespace: %q. Error: %v",
+			usageReportName, csiNamespace, err)
+	}
+	log.Debugf("Successfully updated CnsUsageReport %q", usageReportName)
+	return nil
+}
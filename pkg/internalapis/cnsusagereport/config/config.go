@@ -0,0 +1,8 @@
+package config
+
+import "embed"
+
+//go:embed cns.vmware.com_cnsusagereports.yaml
+var EmbedCnsUsageReportFile embed.FS
+
+const EmbedCnsUsageReportFileName = "cns.vmware.com_cnsusagereports.yaml"
@@ -0,0 +1,76 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// CRDSingular represents the singular name of CnsUsageReport CRD.
+	CRDSingular = "cnsusagereport"
+	// CRDPlural represents the plural name of CnsUsageReport CRD.
+	CRDPlural = "cnsusagereports"
+)
+
+// CnsUsageReportSpec defines the anonymized usage counts of the driver in
+// the cluster. It holds no names or identifiers of volumes, workloads or
+// infrastructure.
+type CnsUsageReportSpec struct {
+	// ReportTime is the time at which the counts were aggregated.
+	ReportTime metav1.Time `json:"reportTime"`
+
+	// BlockVolumes is the number of block volumes of the driver.
+	BlockVolumes int64 `json:"blockVolumes"`
+
+	// FileVolumes is the number of file volumes of the driver.
+	FileVolumes int64 `json:"fileVolumes"`
+
+	// TotalCapacityGiB is the total capacity of the volumes of the driver,
+	// in GiB.
+	TotalCapacityGiB int64 `json:"totalCapacityGiB"`
+
+	// VolumeSizes counts the volumes of the driver by capacity range, e.g.
+	// "1Gi-10Gi".
+	VolumeSizes map[string]int64 `json:"volumeSizes,omitempty"`
+
+	// Snapshots is the number of volume snapshots of the driver.
+	Snapshots int64 `json:"snapshots"`
+
+	// EnabledFeatures are the names of the feature states enabled in the
+	// driver.
+	EnabledFeatures []string `json:"enabledFeatures,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// CnsUsageReport is the Schema for the cnsusagereports API
+type CnsUsageReport struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec CnsUsageReportSpec `json:"spec"`
+}
+
+//+kubebuilder:object:root=true
+
+// CnsUsageReportList contains a list of CnsUsageReport
+type CnsUsageReportList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CnsUsageReport `json:"items"`
+}
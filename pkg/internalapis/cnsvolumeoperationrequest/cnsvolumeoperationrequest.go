@@ -47,6 +47,15 @@ const (
 	EnvCSINamespace = "CSI_NAMESPACE"
 )
 
+// requestDetailsLister is implemented by the VolumeOperationRequest stores
+// to migrate the persisted operation details from one store to another.
+type requestDetailsLister interface {
+	VolumeOperationRequest
+	// listRequestDetails returns the details of the latest operation of
+	// every request persisted in the store.
+	listRequestDetails(ctx context.Context) ([]*VolumeOperationRequestDetails, error)
+}
+
 // VolumeOperationRequest is an interface that supports handling idempotency
 // in CSI volume manager. This interface persists operation details invoked
 // on CNS and returns the persisted information to callers whenever it is requested.
@@ -76,16 +85,19 @@ type operationRequestStore struct {
 
 var (
 	csiNamespace                         string
-	operationRequestStoreInstance        *operationRequestStore
+	operationRequestStoreInstance        VolumeOperationRequest
 	operationStoreInitLock               = &sync.Mutex{}
 	isPodVMOnStretchSupervisorFSSEnabled bool
 )
 
 // InitVolumeOperationRequestInterface creates the CnsVolumeOperationRequest
 // definition on the API server and returns an implementation of
-// VolumeOperationRequest interface. Clients are unaware of the implementation
+// VolumeOperationRequest interface backed by the given store type, which is
+// either one CnsVolumeOperationRequest instance per request or a fixed set of
+// ConfigMaps. Operation details persisted by the other store type are
+// migrated to the selected one. Clients are unaware of the implementation
 // details to read and persist volume operation details.
-func InitVolumeOperationRequestInterface(ctx context.Context, cleanupInterval int,
+func InitVolumeOperationRequestInterface(ctx context.Context, cleanupInterval int, storeType string,
 	isBlockVolumeSnapshotEnabled func() bool, isPodVMOnStretchSupervisorEnabled bool) (
 	VolumeOperationRequest, error) {
 	log := logger.GetLogger(ctx)
@@ -120,14 +132,33 @@ func InitVolumeOperationRequestInterface(ctx context.Context, cleanupInterval in
 			return nil, err
 		}
 
-		// Initialize the operationRequestStoreOnETCD implementation of
-		// VolumeOperationRequest interface.
-		// NOTE: Currently there is only a single implementation of this
-		// interface. Future implementations will need modify this step.
-		operationRequestStoreInstance = &operationRequestStore{
+		coreClient, err := k8s.NewClient(ctx)
+		if err != nil {
+			log.Errorf("failed to create k8sClient with error: %v", err)
+			return nil, err
+		}
+		// Store PodVMOnStretchedSupervisor FSS value before migrating the
+		// operation details, which carry quota details when enabled.
+		isPodVMOnStretchSupervisorFSSEnabled = isPodVMOnStretchSupervisorEnabled
+
+		crStore := &operationRequestStore{
 			k8sclient: k8sclient,
 		}
-		go operationRequestStoreInstance.cleanupStaleInstances(cleanupInterval, isBlockVolumeSnapshotEnabled)
+		configMapStore := newConfigMapRequestStore(coreClient)
+		if storeType == csiconfig.VolumeOperationRequestStoreConfigMap {
+			log.Info("Persisting volume operation details in ConfigMaps")
+			if err := migrateRequestDetails(ctx, crStore, configMapStore); err != nil {
+				return nil, err
+			}
+			operationRequestStoreInstance = configMapStore
+			go configMapStore.cleanupStaleInstances(cleanupInterval, isBlockVolumeSnapshotEnabled)
+		} else {
+			if err := migrateRequestDetails(ctx, configMapStore, crStore); err != nil {
+				return nil, err
+			}
+			operationRequestStoreInstance = crStore
+			go crStore.cleanupStaleInstances(cleanupInterval, isBlockVolumeSnapshotEnabled)
+		}
 	}
 	// Store PodVMOnStretchedSupervisor FSS value for later use.
 	isPodVMOnStretchSupervisorFSSEnabled = isPodVMOnStretchSupervisorEnabled
@@ -156,7 +187,35 @@ func (or *operationRequestStore) GetRequestDetails(
 		return nil, err
 	}
 	log.Debugf("Found CnsVolumeOperationRequest instance %v", spew.Sdump(instance))
+	return convertFromCnsVolumeOperationRequest(instance)
+}
+
+// listRequestDetails returns the details of the latest operation of every
+// CnsVolumeOperationRequest instance.
+func (or *operationRequestStore) listRequestDetails(ctx context.Context) ([]*VolumeOperationRequestDetails, error) {
+	log := logger.GetLogger(ctx)
+	cnsVolumeOperationRequestList := &cnsvolumeoprequestv1alpha1.CnsVolumeOperationRequestList{}
+	err := or.k8sclient.List(ctx, cnsVolumeOperationRequestList, client.InNamespace(csiNamespace))
+	if err != nil {
+		return nil, err
+	}
+	var detailsList []*VolumeOperationRequestDetails
+	for i := range cnsVolumeOperationRequestList.Items {
+		details, err := convertFromCnsVolumeOperationRequest(&cnsVolumeOperationRequestList.Items[i])
+		if err != nil {
+			log.Warnf("skipping CnsVolumeOperationRequest instance %q. Error: %v",
+				cnsVolumeOperationRequestList.Items[i].Name, err)
+			continue
+		}
+		detailsList = append(detailsList, details)
+	}
+	return detailsList, nil
+}
 
+// convertFromCnsVolumeOperationRequest returns the details of the latest
+// operation persisted in the CnsVolumeOperationRequest instance.
+func convertFromCnsVolumeOperationRequest(
+	instance *cnsvolumeoprequestv1alpha1.CnsVolumeOperationRequest) (*VolumeOperationRequestDetails, error) {
 	if len(instance.Status.LatestOperationDetails) == 0 {
 		return nil, fmt.Errorf("length of LatestOperationDetails expected to be greater than 1 if the instance exists")
 	}
@@ -181,6 +240,32 @@ func (or *operationRequestStore) GetRequestDetails(
 		nil
 }
 
+// migrateRequestDetails moves the operation details persisted in one store to
+// another, so that pending operations are still found when the store type
+// is changed.
+func migrateRequestDetails(ctx context.Context, from requestDetailsLister, to VolumeOperationRequest) error {
+	log := logger.GetLogger(ctx)
+	detailsList, err := from.listRequestDetails(ctx)
+	if err != nil {
+		return logger.LogNewErrorf(log, "failed to list volume operation details to migrate with error: %v", err)
+	}
+	if len(detailsList) == 0 {
+		return nil
+	}
+	log.Infof("Migrating %d volume operation details to the %T store", len(detailsList), to)
+	for _, details := range detailsList {
+		if err := to.StoreRequestDetails(ctx, details); err != nil {
+			return logger.LogNewErrorf(log, "failed to migrate volume operation details %q with error: %v",
+				details.Name, err)
+		}
+		if err := from.DeleteRequestDetails(ctx, details.Name); err != nil {
+			return logger.LogNewErrorf(log, "failed to delete migrated volume operation details %q with error: %v",
+				details.Name, err)
+		}
+	}
+	return nil
+}
+
 // StoreRequestDetails persists the details of the operation taking
 // place on the volume by storing it on the API server.
 // Returns an error if any error is encountered. Clients must assume
@@ -356,8 +441,6 @@ func (or *operationRequestStore) cleanupStaleInstances(cleanupInterval int, isBl
 	for ; true; <-ticker.C {
 		log.Infof("Cleaning up stale CnsVolumeOperationRequest instances.")
 
-		instanceMap := make(map[string]bool)
-
 		cnsVolumeOperationRequestList := &cnsvolumeoprequestv1alpha1.CnsVolumeOperationRequestList{}
 		err := or.k8sclient.List(ctx, cnsVolumeOperationRequestList)
 		if err != nil {
@@ -366,64 +449,13 @@ func (or *operationRequestStore) cleanupStaleInstances(cleanupInterval int, isBl
 			continue
 		}
 
-		k8sclient, err := k8s.NewClient(ctx)
-		if err != nil {
-			log.Errorf("failed to get k8sclient with error: %v. Abandoning CnsVolumeOperationRequests "+
-				"clean up ...", err)
-			continue
-		}
-		pvList, err := k8sclient.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+		blockVolumeSnapshotEnabled := isBlockVolumeSnapshotEnabled()
+		instanceMap, err := getLiveInstanceNames(ctx, blockVolumeSnapshotEnabled)
 		if err != nil {
-			log.Errorf("failed to list PersistentVolumes with error %v. Abandoning "+
-				"CnsVolumeOperationRequests clean up ...", err)
+			log.Errorf("%v. Abandoning CnsVolumeOperationRequests clean up ...", err)
 			continue
 		}
 
-		for _, pv := range pvList.Items {
-			if pv.Spec.CSI != nil && pv.Spec.CSI.Driver == csitypes.Name {
-				instanceMap[pv.Name] = true
-				volumeHandle := pv.Spec.CSI.VolumeHandle
-				if strings.Contains(volumeHandle, "file") {
-					volumeHandle = strings.ReplaceAll(volumeHandle, ":", "-")
-				}
-				instanceMap[volumeHandle] = true
-			}
-		}
-		blockVolumeSnapshotEnabled := isBlockVolumeSnapshotEnabled()
-		// skip cleaning up of snapshot related CnsVolumeOperationRequests if FSS is not enabled.
-		if blockVolumeSnapshotEnabled {
-			snapshotterClient, err := k8s.NewSnapshotterClient(ctx)
-			if err != nil {
-				log.Errorf("failed to get snapshotterClient with error: %v. Abandoning "+
-					"CnsVolumeOperationRequests clean up ...", err)
-				return
-			}
-
-			// the List API below ensures VolumeSnapshotContent CRD is installed and lists the existing
-			// VolumeSnapshotContent CRs in cluster.
-			vscList, err := snapshotterClient.SnapshotV1().VolumeSnapshotContents().List(ctx, metav1.ListOptions{})
-			if err != nil {
-				log.Errorf("failed to list VolumeSnapshotContents with error %v. Abandoning "+
-					"CnsVolumeOperationRequests clean up ...", err)
-				return
-			}
-
-			for _, vsc := range vscList.Items {
-				if vsc.Spec.Driver != csitypes.Name {
-					continue
-				}
-				volumeHandle := vsc.Spec.Source.VolumeHandle
-				if volumeHandle != nil {
-					// CnsVolumeOperation instance for CreateSnapshot
-					instanceMap[strings.TrimPrefix(vsc.Name, "snapcontent-")+"-"+*volumeHandle] = true
-				}
-				if vsc.Status != nil && vsc.Status.SnapshotHandle != nil {
-					// CnsVolumeOperation instance for DeleteSnapshot
-					instanceMap[strings.Replace(*vsc.Status.SnapshotHandle, "+", "-", 1)] = true
-				}
-			}
-		}
-
 		for _, instance := range cnsVolumeOperationRequestList.Items {
 			latestOperationDetailsLength := len(instance.Status.LatestOperationDetails)
 			if latestOperationDetailsLength != 0 &&
@@ -431,20 +463,7 @@ func (or *operationRequestStore) cleanupStaleInstances(cleanupInterval int, isBl
 					TaskInvocationStatusInProgress {
 				continue
 			}
-			var trimmedName string
-			switch {
-			case strings.HasPrefix(instance.Name, "pvc"):
-				trimmedName = instance.Name
-			case strings.HasPrefix(instance.Name, "delete"):
-				trimmedName = strings.TrimPrefix(instance.Name, "delete-")
-			case strings.HasPrefix(instance.Name, "expand"):
-				trimmedName = strings.TrimPrefix(instance.Name, "expand-")
-			case blockVolumeSnapshotEnabled && strings.HasPrefix(instance.Name, "snapshot"):
-				trimmedName = strings.TrimPrefix(instance.Name, "snapshot-")
-			case blockVolumeSnapshotEnabled && strings.HasPrefix(instance.Name, "deletesnapshot"):
-				trimmedName = strings.TrimPrefix(instance.Name, "deletesnapshot-")
-			}
-			if _, ok := instanceMap[trimmedName]; !ok {
+			if _, ok := instanceMap[trimInstanceName(instance.Name, blockVolumeSnapshotEnabled)]; !ok {
 				err = or.DeleteRequestDetails(ctx, instance.Name)
 				if err != nil {
 					log.Errorf("failed to delete CnsVolumeOperationRequest instance %s with error %v",
@@ -456,6 +475,82 @@ func (or *operationRequestStore) cleanupStaleInstances(cleanupInterval int, isBl
 	}
 }
 
+// getLiveInstanceNames returns the names, as used by the operation
+// request stores, of the CSI PVs and, if snapshots are enabled, of the CSI
+// VolumeSnapshotContents present in the kubernetes cluster.
+func getLiveInstanceNames(ctx context.Context, blockVolumeSnapshotEnabled bool) (map[string]bool, error) {
+	instanceMap := make(map[string]bool)
+	k8sclient, err := k8s.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8sclient with error: %v", err)
+	}
+	pvList, err := k8sclient.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PersistentVolumes with error %v", err)
+	}
+
+	for _, pv := range pvList.Items {
+		if pv.Spec.CSI != nil && pv.Spec.CSI.Driver == csitypes.DriverName() {
+			instanceMap[pv.Name] = true
+			volumeHandle := pv.Spec.CSI.VolumeHandle
+			if strings.Contains(volumeHandle, "file") {
+				volumeHandle = strings.ReplaceAll(volumeHandle, ":", "-")
+			}
+			instanceMap[volumeHandle] = true
+		}
+	}
+	// skip cleaning up of snapshot related CnsVolumeOperationRequests if FSS is not enabled.
+	if !blockVolumeSnapshotEnabled {
+		return instanceMap, nil
+	}
+	snapshotterClient, err := k8s.NewSnapshotterClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get snapshotterClient with error: %v", err)
+	}
+
+	// the List API below ensures VolumeSnapshotContent CRD is installed and lists the existing
+	// VolumeSnapshotContent CRs in cluster.
+	vscList, err := snapshotterClient.SnapshotV1().VolumeSnapshotContents().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list VolumeSnapshotContents with error %v", err)
+	}
+
+	for _, vsc := range vscList.Items {
+		if vsc.Spec.Driver != csitypes.DriverName() {
+			continue
+		}
+		volumeHandle := vsc.Spec.Source.VolumeHandle
+		if volumeHandle != nil {
+			// CnsVolumeOperation instance for CreateSnapshot
+			instanceMap[strings.TrimPrefix(vsc.Name, "snapcontent-")+"-"+*volumeHandle] = true
+		}
+		if vsc.Status != nil && vsc.Status.SnapshotHandle != nil {
+			// CnsVolumeOperation instance for DeleteSnapshot
+			instanceMap[strings.Replace(*vsc.Status.SnapshotHandle, "+", "-", 1)] = true
+		}
+	}
+	return instanceMap, nil
+}
+
+// trimInstanceName returns the name of the PV or VolumeSnapshotContent the
+// given operation request instance was stored for.
+func trimInstanceName(name string, blockVolumeSnapshotEnabled bool) string {
+	var trimmedName string
+	switch {
+	case strings.HasPrefix(name, "pvc"):
+		trimmedName = name
+	case strings.HasPrefix(name, "delete"):
+		trimmedName = strings.TrimPrefix(name, "delete-")
+	case strings.HasPrefix(name, "expand"):
+		trimmedName = strings.TrimPrefix(name, "expand-")
+	case blockVolumeSnapshotEnabled && strings.HasPrefix(name, "snapshot"):
+		trimmedName = strings.TrimPrefix(name, "snapshot-")
+	case blockVolumeSnapshotEnabled && strings.HasPrefix(name, "deletesnapshot"):
+		trimmedName = strings.TrimPrefix(name, "deletesnapshot-")
+	}
+	return trimmedName
+}
+
 func getCSINamespace() string {
 	csiNamespace := os.Getenv(EnvCSINamespace)
 	if strings.TrimSpace(csiNamespace) == "" {
@@ -0,0 +1,328 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cnsvolumeoperationrequest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	cnsvolumeoprequestv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsvolumeoperationrequest/v1alpha1"
+)
+
+const (
+	// configMapNamePrefix is the name prefix of the ConfigMaps persisting
+	// operation details in the ConfigMap backed store.
+	configMapNamePrefix = "cns-volume-operation-requests-"
+	// numConfigMaps is the number of ConfigMaps the operation details are
+	// sharded across, to stay well below the size limit of a ConfigMap.
+	numConfigMaps = 16
+	// configMapStoreLabel labels the ConfigMaps of the ConfigMap backed store.
+	configMapStoreLabel = "cns.vmware.com/volume-operation-requests"
+)
+
+// compactRequestDetails is the record persisted for each operation request
+// in the ConfigMap backed store. Only the latest operation is kept, which is
+// all callers of VolumeOperationRequest need for idempotency.
+type compactRequestDetails struct {
+	VolumeID         string                                      `json:"volumeID,omitempty"`
+	SnapshotID       string                                      `json:"snapshotID,omitempty"`
+	Capacity         int64                                       `json:"capacity,omitempty"`
+	QuotaDetails     *cnsvolumeoprequestv1alpha1.QuotaDetails    `json:"quotaDetails,omitempty"`
+	OperationDetails cnsvolumeoprequestv1alpha1.OperationDetails `json:"operationDetails"`
+}
+
+// configMapRequestStore implements the VolumeOperationRequest interface.
+// This implementation persists the operation details as entries of a fixed
+// set of ConfigMaps in the CSI namespace instead of one CR per request,
+// which keeps the number of objects in etcd constant on clusters with high
+// volume churn.
+type configMapRequestStore struct {
+	k8sclient clientset.Interface
+	// locks serialize the read-modify-write updates of each ConfigMap within
+	// this process, so that concurrent updates of different ConfigMaps don't
+	// wait on each other. Updates from other processes are detected with the
+	// resourceVersion of the ConfigMaps and retried.
+	locks [numConfigMaps]sync.Mutex
+}
+
+// newConfigMapRequestStore returns a ConfigMap backed VolumeOperationRequest.
+func newConfigMapRequestStore(k8sclient clientset.Interface) *configMapRequestStore {
+	return &configMapRequestStore{k8sclient: k8sclient}
+}
+
+// GetRequestDetails returns the details of the latest operation persisted
+// with the given name. A NotFound error is returned if there are none.
+func (cs *configMapRequestStore) GetRequestDetails(ctx context.Context,
+	name string) (*VolumeOperationRequestDetails, error) {
+	log := logger.GetLogger(ctx)
+	configMap, err := cs.k8sclient.CoreV1().ConfigMaps(csiNamespace).Get(ctx,
+		getConfigMapName(getConfigMapShard(name)), metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, newNotFoundError(name)
+		}
+		return nil, err
+	}
+	data, ok := configMap.Data[name]
+	if !ok {
+		return nil, newNotFoundError(name)
+	}
+	log.Debugf("Found operation details %s for %q in ConfigMap %s/%s", data, name, csiNamespace, configMap.Name)
+	return decodeRequestDetails(name, data)
+}
+
+// StoreRequestDetails persists the details of the operation, replacing the
+// details of any previous operation with the same name.
+func (cs *configMapRequestStore) StoreRequestDetails(ctx context.Context,
+	operationToStore *VolumeOperationRequestDetails) error {
+	log := logger.GetLogger(ctx)
+	if operationToStore == nil || operationToStore.OperationDetails == nil {
+		return logger.LogNewError(log, "cannot store empty operation")
+	}
+	if errs := validation.IsConfigMapKey(operationToStore.Name); len(errs) != 0 {
+		return logger.LogNewErrorf(log, "cannot store operation with name %q: %s", operationToStore.Name,
+			strings.Join(errs, ", "))
+	}
+	data, err := encodeRequestDetails(operationToStore)
+	if err != nil {
+		return logger.LogNewErrorf(log, "failed to encode operation %q with error: %v", operationToStore.Name, err)
+	}
+	err = cs.updateConfigMap(ctx, getConfigMapShard(operationToStore.Name), func(configMapData map[string]string) bool {
+		configMapData[operationToStore.Name] = data
+		return true
+	})
+	if err != nil {
+		log.Errorf("failed to store operation details for %q with error: %v", operationToStore.Name, err)
+		return err
+	}
+	log.Debugf("Stored operation details for %q with task ID: %s", operationToStore.Name,
+		operationToStore.OperationDetails.TaskID)
+	return nil
+}
+
+// DeleteRequestDetails deletes the operation details persisted with the
+// given name.
+func (cs *configMapRequestStore) DeleteRequestDetails(ctx context.Context, name string) error {
+	return cs.deleteRequestDetails(ctx, getConfigMapShard(name), []string{name})
+}
+
+// deleteRequestDetails deletes the operation details persisted with the
+// given names in a single update of the ConfigMap of the given shard.
+func (cs *configMapRequestStore) deleteRequestDetails(ctx context.Context, shard uint32,
+	names []string) error {
+	log := logger.GetLogger(ctx)
+	err := cs.updateConfigMap(ctx, shard, func(configMapData map[string]string) bool {
+		updated := false
+		for _, name := range names {
+			if _, ok := configMapData[name]; ok {
+				delete(configMapData, name)
+				updated = true
+			}
+		}
+		return updated
+	})
+	if err != nil {
+		log.Errorf("failed to delete operation details %v from ConfigMap %s/%s with error: %v",
+			names, csiNamespace, getConfigMapName(shard), err)
+	}
+	return err
+}
+
+// updateConfigMap applies the mutation to the data of the ConfigMap of the
+// given shard, creating the ConfigMap if required. The ConfigMap is only
+// written if the mutation returns true. The mutation is applied again to the
+// latest ConfigMap if it was updated concurrently.
+func (cs *configMapRequestStore) updateConfigMap(ctx context.Context, shard uint32,
+	mutate func(map[string]string) bool) error {
+	configMapName := getConfigMapName(shard)
+	cs.locks[shard].Lock()
+	defer cs.locks[shard].Unlock()
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMap, err := cs.k8sclient.CoreV1().ConfigMaps(csiNamespace).Get(ctx, configMapName,
+			metav1.GetOptions{})
+		if err != nil {
+			if !apierrors.IsNotFound(err) {
+				return err
+			}
+			configMap = &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      configMapName,
+					Namespace: csiNamespace,
+					Labels:    map[string]string{configMapStoreLabel: "true"},
+				},
+				Data: make(map[string]string),
+			}
+			if !mutate(configMap.Data) {
+				return nil
+			}
+			_, err = cs.k8sclient.CoreV1().ConfigMaps(csiNamespace).Create(ctx, configMap, metav1.CreateOptions{})
+			if apierrors.IsAlreadyExists(err) {
+				// Created concurrently, retry the update.
+				return apierrors.NewConflict(v1.Resource("configmaps"), configMapName, err)
+			}
+			return err
+		}
+		if configMap.Data == nil {
+			configMap.Data = make(map[string]string)
+		}
+		if !mutate(configMap.Data) {
+			return nil
+		}
+		_, err = cs.k8sclient.CoreV1().ConfigMaps(csiNamespace).Update(ctx, configMap, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// listRequestDetails returns the operation details persisted in all the
+// ConfigMaps of the store.
+func (cs *configMapRequestStore) listRequestDetails(ctx context.Context) ([]*VolumeOperationRequestDetails, error) {
+	log := logger.GetLogger(ctx)
+	configMapList, err := cs.k8sclient.CoreV1().ConfigMaps(csiNamespace).List(ctx, metav1.ListOptions{
+		LabelSelector: configMapStoreLabel + "=true",
+	})
+	if err != nil {
+		return nil, err
+	}
+	var detailsList []*VolumeOperationRequestDetails
+	for _, configMap := range configMapList.Items {
+		for name, data := range configMap.Data {
+			details, err := decodeRequestDetails(name, data)
+			if err != nil {
+				log.Warnf("skipping invalid operation details for %q in ConfigMap %s/%s. Error: %v",
+					name, configMap.Namespace, configMap.Name, err)
+				continue
+			}
+			detailsList = append(detailsList, details)
+		}
+	}
+	return detailsList, nil
+}
+
+// cleanupStaleInstances deletes the operation details of volumes and
+// snapshots that are no longer present in the kubernetes cluster.
+func (cs *configMapRequestStore) cleanupStaleInstances(cleanupInterval int,
+	isBlockVolumeSnapshotEnabled func() bool) {
+	ticker := time.NewTicker(time.Duration(cleanupInterval) * time.Minute)
+	ctx, log := logger.GetNewContextWithLogger()
+	log.Infof("Volume operation details clean up interval is set to %d minutes", cleanupInterval)
+	for ; true; <-ticker.C {
+		log.Infof("Cleaning up stale volume operation details.")
+		detailsList, err := cs.listRequestDetails(ctx)
+		if err != nil {
+			log.Errorf("failed to list volume operation details with error %v. Abandoning clean up ...", err)
+			continue
+		}
+		blockVolumeSnapshotEnabled := isBlockVolumeSnapshotEnabled()
+		instanceMap, err := getLiveInstanceNames(ctx, blockVolumeSnapshotEnabled)
+		if err != nil {
+			log.Errorf("%v. Abandoning volume operation details clean up ...", err)
+			continue
+		}
+		staleNames := make(map[uint32][]string)
+		for _, details := range detailsList {
+			if details.OperationDetails.TaskStatus == TaskInvocationStatusInProgress {
+				continue
+			}
+			if _, ok := instanceMap[trimInstanceName(details.Name, blockVolumeSnapshotEnabled)]; !ok {
+				shard := getConfigMapShard(details.Name)
+				staleNames[shard] = append(staleNames[shard], details.Name)
+			}
+		}
+		for shard, names := range staleNames {
+			_ = cs.deleteRequestDetails(ctx, shard, names)
+		}
+		log.Infof("Clean up of stale volume operation details complete.")
+	}
+}
+
+// getConfigMapShard returns the shard of the ConfigMap persisting the
+// operation details with the given name.
+func getConfigMapShard(name string) uint32 {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(name))
+	return hash.Sum32() % numConfigMaps
+}
+
+// getConfigMapName returns the name of the ConfigMap of the given shard.
+func getConfigMapName(shard uint32) string {
+	return fmt.Sprintf("%s%d", configMapNamePrefix, shard)
+}
+
+// newNotFoundError returns the error returned by the CR backed store for
+// missing operation details, so that callers can handle both stores alike.
+func newNotFoundError(name string) error {
+	return apierrors.NewNotFound(cnsvolumeoprequestv1alpha1.SchemeGroupVersion.WithResource(CRDPlural).GroupResource(),
+		name)
+}
+
+// encodeRequestDetails returns the record persisted for the operation.
+func encodeRequestDetails(details *VolumeOperationRequestDetails) (string, error) {
+	record := compactRequestDetails{
+		VolumeID:         details.VolumeID,
+		SnapshotID:       details.SnapshotID,
+		Capacity:         details.Capacity,
+		OperationDetails: *convertToCnsVolumeOperationRequestDetails(*details.OperationDetails),
+	}
+	if isPodVMOnStretchSupervisorFSSEnabled && details.QuotaDetails != nil {
+		record.QuotaDetails = &cnsvolumeoprequestv1alpha1.QuotaDetails{
+			Reserved:         details.QuotaDetails.Reserved,
+			StoragePolicyId:  details.QuotaDetails.StoragePolicyId,
+			StorageClassName: details.QuotaDetails.StorageClassName,
+			Namespace:        details.QuotaDetails.Namespace,
+		}
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// decodeRequestDetails returns the operation details from a persisted record.
+func decodeRequestDetails(name string, data string) (*VolumeOperationRequestDetails, error) {
+	record := compactRequestDetails{}
+	if err := json.Unmarshal([]byte(data), &record); err != nil {
+		return nil, err
+	}
+	var quotaDetails *QuotaDetails
+	if isPodVMOnStretchSupervisorFSSEnabled && record.QuotaDetails != nil {
+		quotaDetails = &QuotaDetails{
+			Reserved:         record.QuotaDetails.Reserved,
+			StorageClassName: record.QuotaDetails.StorageClassName,
+			StoragePolicyId:  record.QuotaDetails.StoragePolicyId,
+			Namespace:        record.QuotaDetails.Namespace,
+		}
+	}
+	operationDetails := record.OperationDetails
+	return CreateVolumeOperationRequestDetails(name, record.VolumeID, record.SnapshotID, record.Capacity,
+		quotaDetails, operationDetails.TaskInvocationTimestamp, operationDetails.TaskID,
+		operationDetails.VCenterServer, operationDetails.OpID, operationDetails.TaskStatus,
+		operationDetails.Error), nil
+}
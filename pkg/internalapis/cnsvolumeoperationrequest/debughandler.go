@@ -0,0 +1,271 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cnsvolumeoperationrequest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+const (
+	// DebugHandlerPath is the path on which the handler returned by
+	// NewDebugHandler is expected to be registered.
+	DebugHandlerPath = "/debug/volume-operation-requests"
+
+	// Query parameters accepted by the debug handler.
+	debugParamName      = "name"
+	debugParamOperation = "operation"
+	debugParamOlderThan = "olderThan"
+	debugParamForce     = "force"
+)
+
+// RequestRecord is the representation of a persisted volume operation
+// request returned by the debug handler.
+type RequestRecord struct {
+	Name          string `json:"name"`
+	Operation     string `json:"operation"`
+	VolumeName    string `json:"volumeName"`
+	VolumeID      string `json:"volumeID,omitempty"`
+	SnapshotID    string `json:"snapshotID,omitempty"`
+	TaskID        string `json:"taskID,omitempty"`
+	TaskStatus    string `json:"taskStatus,omitempty"`
+	VCenterServer string `json:"vCenterServer,omitempty"`
+	InvokedAt     string `json:"invokedAt,omitempty"`
+	Age           string `json:"age,omitempty"`
+}
+
+// PurgeResult is returned by the debug handler for purge requests.
+type PurgeResult struct {
+	Purged  []string          `json:"purged"`
+	Skipped map[string]string `json:"skipped,omitempty"`
+}
+
+// debugHandler lists and purges the volume operation requests persisted by
+// a VolumeOperationRequest store.
+type debugHandler struct {
+	store requestDetailsLister
+}
+
+// NewDebugHandler returns an http.Handler exposing the volume operation
+// requests persisted by the VolumeOperationRequest instance initialized by
+// InitVolumeOperationRequestInterface.
+//
+// GET lists the persisted requests. The optional "operation" (create,
+// delete, expand, snapshot, deletesnapshot) and "olderThan" (a duration
+// such as 24h) parameters filter the records.
+// DELETE purges the requests selected by one or more "name" parameters, or
+// by the "operation" and "olderThan" filters. At least one of "name" or
+// "olderThan" must be given. Requests whose task is still in progress are
+// skipped unless "force=true" is specified.
+func NewDebugHandler() (http.Handler, error) {
+	operationStoreInitLock.Lock()
+	defer operationStoreInitLock.Unlock()
+	if operationRequestStoreInstance == nil {
+		return nil, logger.LogNewErrorf(logger.GetLoggerWithNoContext(),
+			"volume operation request store is not initialized")
+	}
+	lister, ok := operationRequestStoreInstance.(requestDetailsLister)
+	if !ok {
+		return nil, logger.LogNewErrorf(logger.GetLoggerWithNoContext(),
+			"volume operation request store %T does not support listing requests", operationRequestStoreInstance)
+	}
+	return &debugHandler{store: lister}, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (h *debugHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.list(w, r)
+	case http.MethodDelete:
+		h.purge(w, r)
+	default:
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodDelete)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *debugHandler) list(w http.ResponseWriter, r *http.Request) {
+	ctx, log := logger.GetNewContextWithLogger()
+	filter, err := parseRecordFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	detailsList, err := h.store.listRequestDetails(ctx)
+	if err != nil {
+		log.Errorf("failed to list volume operation requests with error: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	now := time.Now()
+	records := make([]RequestRecord, 0, len(detailsList))
+	for _, details := range detailsList {
+		if filter.matches(details, now) {
+			records = append(records, newRequestRecord(details, now))
+		}
+	}
+	writeJSON(w, records)
+}
+
+func (h *debugHandler) purge(w http.ResponseWriter, r *http.Request) {
+	ctx, log := logger.GetNewContextWithLogger()
+	filter, err := parseRecordFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	names := r.URL.Query()[debugParamName]
+	if len(names) == 0 && filter.olderThan == 0 {
+		http.Error(w, "purge requires at least one \"name\" or an \"olderThan\" parameter",
+			http.StatusBadRequest)
+		return
+	}
+	force, _ := strconv.ParseBool(r.URL.Query().Get(debugParamForce))
+
+	detailsList, err := h.store.listRequestDetails(ctx)
+	if err != nil {
+		log.Errorf("failed to list volume operation requests with error: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	selected := make(map[string]bool, len(names))
+	for _, name := range names {
+		selected[name] = true
+	}
+	result := PurgeResult{Purged: []string{}, Skipped: make(map[string]string)}
+	now := time.Now()
+	for _, details := range detailsList {
+		if len(selected) != 0 {
+			if !selected[details.Name] {
+				continue
+			}
+			delete(selected, details.Name)
+		}
+		if !filter.matches(details, now) {
+			continue
+		}
+		if !force && details.OperationDetails != nil &&
+			details.OperationDetails.TaskStatus == TaskInvocationStatusInProgress {
+			result.Skipped[details.Name] = "task is in progress"
+			continue
+		}
+		if err := h.store.DeleteRequestDetails(ctx, details.Name); err != nil {
+			result.Skipped[details.Name] = err.Error()
+			continue
+		}
+		log.Infof("Purged volume operation request %q", details.Name)
+		result.Purged = append(result.Purged, details.Name)
+	}
+	for name := range selected {
+		result.Skipped[name] = "not found"
+	}
+	writeJSON(w, result)
+}
+
+// recordFilter selects the persisted requests a debug request applies to.
+type recordFilter struct {
+	operation string
+	olderThan time.Duration
+}
+
+func parseRecordFilter(r *http.Request) (recordFilter, error) {
+	var filter recordFilter
+	query := r.URL.Query()
+	filter.operation = query.Get(debugParamOperation)
+	if v := query.Get(debugParamOlderThan); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return filter, fmt.Errorf("invalid %q parameter %q, expected a positive duration",
+				debugParamOlderThan, v)
+		}
+		filter.olderThan = d
+	}
+	return filter, nil
+}
+
+func (f recordFilter) matches(details *VolumeOperationRequestDetails, now time.Time) bool {
+	if f.operation != "" && operationFromInstanceName(details.Name) != f.operation {
+		return false
+	}
+	if f.olderThan != 0 {
+		if details.OperationDetails == nil || details.OperationDetails.TaskInvocationTimestamp.IsZero() {
+			return false
+		}
+		if now.Sub(details.OperationDetails.TaskInvocationTimestamp.Time) < f.olderThan {
+			return false
+		}
+	}
+	return true
+}
+
+// operationFromInstanceName returns the CSI operation a volume operation
+// request was persisted for, derived from the prefix of its name.
+func operationFromInstanceName(name string) string {
+	switch {
+	case strings.HasPrefix(name, "pvc"):
+		return "create"
+	case strings.HasPrefix(name, "deletesnapshot"):
+		return "deletesnapshot"
+	case strings.HasPrefix(name, "delete"):
+		return "delete"
+	case strings.HasPrefix(name, "expand"):
+		return "expand"
+	case strings.HasPrefix(name, "snapshot"):
+		return "snapshot"
+	}
+	return "unknown"
+}
+
+func newRequestRecord(details *VolumeOperationRequestDetails, now time.Time) RequestRecord {
+	operation := operationFromInstanceName(details.Name)
+	volumeName := details.Name
+	if operation != "create" {
+		volumeName = strings.TrimPrefix(details.Name, operation+"-")
+	}
+	record := RequestRecord{
+		Name:       details.Name,
+		Operation:  operation,
+		VolumeName: volumeName,
+		VolumeID:   details.VolumeID,
+		SnapshotID: details.SnapshotID,
+	}
+	if op := details.OperationDetails; op != nil {
+		record.TaskID = op.TaskID
+		record.TaskStatus = op.TaskStatus
+		record.VCenterServer = op.VCenterServer
+		if !op.TaskInvocationTimestamp.IsZero() {
+			record.InvokedAt = op.TaskInvocationTimestamp.UTC().Format(time.RFC3339)
+			record.Age = now.Sub(op.TaskInvocationTimestamp.Time).Round(time.Second).String()
+		}
+	}
+	return record
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log := logger.GetLoggerWithNoContext()
+		log.Errorf("failed to write debug response with error: %v", err)
+	}
+}
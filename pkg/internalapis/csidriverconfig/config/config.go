@@ -0,0 +1,8 @@
+package config
+
+import "embed"
+
+//go:embed cns.vmware.com_csidriverconfigs.yaml
+var EmbedCSIDriverConfigFile embed.FS
+
+const EmbedCSIDriverConfigFileName = "cns.vmware.com_csidriverconfigs.yaml"
@@ -0,0 +1,388 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package csidriverconfig
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cnsconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	csidriverconfigconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/csidriverconfig/config"
+	csidriverconfigv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/csidriverconfig/v1alpha1"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
+)
+
+const (
+	// CRDGroupName represent the group of csidriverconfig CRD.
+	CRDGroupName = "cns.vmware.com"
+	// CSIDriverConfigName is the name of the CSIDriverConfig instance read by
+	// the driver. The instances with other names are ignored.
+	CSIDriverConfigName = "csi-driver-config"
+	// maxSnapshotsPerBlockVolume is the maximum number of snapshots per block
+	// volume supported by vSphere.
+	maxSnapshotsPerBlockVolume = 32
+	// maxFullSyncIntervalInMin is the maximum interval of the periodic full
+	// sync.
+	maxFullSyncIntervalInMin = 30
+)
+
+var (
+	// specLock protects spec.
+	specLock sync.RWMutex
+	// spec is the last valid spec of the CSIDriverConfig instance, which is
+	// empty when there is none.
+	spec csidriverconfigv1alpha1.CSIDriverConfigSpec
+	// startLock protects started.
+	startLock sync.Mutex
+	// started is set once the service is started.
+	started bool
+	// k8sClient helps update the status of the CSIDriverConfig instance.
+	k8sClient client.Client
+	// topologyCategoriesHandlersLock protects topologyCategoriesHandlers.
+	topologyCategoriesHandlersLock sync.Mutex
+	// topologyCategoriesHandlers are called when the topology categories of
+	// the CSIDriverConfig change.
+	topologyCategoriesHandlers []func(ctx context.Context)
+)
+
+// StartCSIDriverConfigService creates the CSIDriverConfig CRD and watches
+// the CSIDriverConfig instance, so that the changes of its tunables are
+// picked up by the running components. It is a no-op if the service is
+// already started. It returns once the CSIDriverConfig instance, if any, is
+// applied.
+func StartCSIDriverConfigService(ctx context.Context) error {
+	log := logger.GetLogger(ctx)
+	startLock.Lock()
+	defer startLock.Unlock()
+	if started {
+		return nil
+	}
+	log.Info("Starting CSIDriverConfig service...")
+	// This is idempotent if CRD is pre-created then we continue with
+	// initialization of the service.
+	err := k8s.CreateCustomResourceDefinitionFromManifest(ctx,
+		csidriverconfigconfig.EmbedCSIDriverConfigFile, csidriverconfigconfig.EmbedCSIDriverConfigFileName)
+	if err != nil {
+		return logger.LogNewErrorf(log, "failed to create CSIDriverConfig CRD. Error: %v", err)
+	}
+	config, err := k8s.GetKubeConfig(ctx)
+	if err != nil {
+		return logger.LogNewErrorf(log, "failed to get kubeconfig. err: %v", err)
+	}
+	k8sClient, err = k8s.NewClientForGroup(ctx, config, CRDGroupName)
+	if err != nil {
+		return logger.LogNewErrorf(log, "failed to create k8sClient for CSIDriverConfig service. Err: %v", err)
+	}
+	informer, err := k8s.GetDynamicInformer(ctx, csidriverconfigv1alpha1.SchemeGroupVersion.Group,
+		csidriverconfigv1alpha1.SchemeGroupVersion.Version, csidriverconfigv1alpha1.CRDPlural,
+		"", config, true)
+	if err != nil {
+		return logger.LogNewErrorf(log, "failed to create dynamic informer for csidriverconfigs CRD. Err: %v", err)
+	}
+	_, err = informer.Informer().AddEventHandler(k8s.NewEventHandler(csidriverconfigv1alpha1.CRDPlural,
+		csiDriverConfigChanged, func(oldObj, newObj interface{}) { csiDriverConfigChanged(newObj) },
+		csiDriverConfigDeleted))
+	if err != nil {
+		return logger.LogNewErrorf(log, "failed to add event handler to csidriverconfigs informer. Err: %v", err)
+	}
+	stopCh := make(chan struct{})
+	go informer.Informer().Run(stopCh)
+	if !cache.WaitForCacheSync(stopCh, informer.Informer().HasSynced) {
+		return logger.LogNewError(log, "failed to sync csidriverconfigs informer cache")
+	}
+	started = true
+	log.Info("CSIDriverConfig service started")
+	return nil
+}
+
+// csiDriverConfigChanged applies the spec of the added or updated
+// CSIDriverConfig instance if it is valid, and reports the outcome in its
+// status.
+func csiDriverConfigChanged(obj interface{}) {
+	ctx, log := logger.GetNewContextWithLogger()
+	instance, err := toCSIDriverConfig(ctx, obj)
+	if err != nil {
+		return
+	}
+	if instance.Name != CSIDriverConfigName {
+		log.Warnf("Ignoring CSIDriverConfig %q. Only the CSIDriverConfig named %q is read by the driver",
+			instance.Name, CSIDriverConfigName)
+		return
+	}
+	newSpec := instance.Spec.DeepCopy()
+	Default(newSpec)
+	validationErr := Validate(newSpec)
+	if validationErr != nil {
+		log.Errorf("Rejecting generation %d of CSIDriverConfig %q and keeping the last valid config. Error: %v",
+			instance.Generation, instance.Name, validationErr)
+	} else {
+		setSpec(ctx, *newSpec)
+		log.Infof("Applied generation %d of CSIDriverConfig %q", instance.Generation, instance.Name)
+	}
+	updateStatus(ctx, instance, validationErr)
+}
+
+// csiDriverConfigDeleted resets the tunables to the values from the
+// environment variables and the vSphere config secret when the
+// CSIDriverConfig instance is deleted.
+func csiDriverConfigDeleted(obj interface{}) {
+	ctx, log := logger.GetNewContextWithLogger()
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok || u.GetName() != CSIDriverConfigName {
+		return
+	}
+	setSpec(ctx, csidriverconfigv1alpha1.CSIDriverConfigSpec{})
+	log.Infof("CSIDriverConfig %q deleted. Using the default config", CSIDriverConfigName)
+}
+
+// setSpec replaces the last valid spec, and calls the topology categories
+// handlers if the topology categories changed.
+func setSpec(ctx context.Context, newSpec csidriverconfigv1alpha1.CSIDriverConfigSpec) {
+	log := logger.GetLogger(ctx)
+	specLock.Lock()
+	categoriesChanged := !slices.Equal(spec.TopologyCategories, newSpec.TopologyCategories)
+	spec = newSpec
+	specLock.Unlock()
+	if !categoriesChanged {
+		return
+	}
+	log.Infof("Topology categories of CSIDriverConfig %q changed to %+v", CSIDriverConfigName,
+		newSpec.TopologyCategories)
+	topologyCategoriesHandlersLock.Lock()
+	handlers := slices.Clone(topologyCategoriesHandlers)
+	topologyCategoriesHandlersLock.Unlock()
+	for _, handler := range handlers {
+		handler(ctx)
+	}
+}
+
+// AddTopologyCategoriesHandler registers a handler called whenever the
+// topology categories of the CSIDriverConfig change, e.g. to refresh the
+// caches built from the topology categories.
+func AddTopologyCategoriesHandler(handler func(ctx context.Context)) {
+	topologyCategoriesHandlersLock.Lock()
+	defer topologyCategoriesHandlersLock.Unlock()
+	topologyCategoriesHandlers = append(topologyCategoriesHandlers, handler)
+}
+
+// updateStatus records in the status of the given CSIDriverConfig instance
+// that its generation was observed, along with the validation error if it
+// was rejected.
+func updateStatus(ctx context.Context, instance *csidriverconfigv1alpha1.CSIDriverConfig, validationErr error) {
+	log := logger.GetLogger(ctx)
+	status := csidriverconfigv1alpha1.CSIDriverConfigStatus{ObservedGeneration: instance.Generation}
+	if validationErr != nil {
+		status.Error = validationErr.Error()
+	}
+	if instance.Status == status {
+		return
+	}
+	instance.Status = status
+	if err := k8sClient.Status().Update(ctx, instance); err != nil {
+		log.Warnf("failed to update the status of CSIDriverConfig %q. Error: %v", instance.Name, err)
+	}
+}
+
+// toCSIDriverConfig converts the given object of the csidriverconfigs
+// informer to a CSIDriverConfig.
+func toCSIDriverConfig(ctx context.Context, obj interface{}) (*csidriverconfigv1alpha1.CSIDriverConfig, error) {
+	log := logger.GetLogger(ctx)
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, logger.LogNewErrorf(log, "unexpected object %T in the csidriverconfigs informer", obj)
+	}
+	instance := &csidriverconfigv1alpha1.CSIDriverConfig{}
+	err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, instance)
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to parse CSIDriverConfig object: %v, err: %v", obj, err)
+	}
+	return instance, nil
+}
+
+// Default sets the default values of the given spec, i.e. trims the names
+// and labels of the topology categories and sets the label of the topology
+// categories without one.
+func Default(spec *csidriverconfigv1alpha1.CSIDriverConfigSpec) {
+	for i := range spec.TopologyCategories {
+		category := &spec.TopologyCategories[i]
+		category.Name = strings.TrimSpace(category.Name)
+		category.Label = strings.TrimSpace(category.Label)
+		if category.Label == "" && category.Name != "" {
+			category.Label = cnsconfig.TopologyLabelsDomain + "/" + category.Name
+		}
+	}
+}
+
+// Validate returns an error listing the tunables of the given spec which are
+// out of their range, and the invalid topology categories.
+func Validate(spec *csidriverconfigv1alpha1.CSIDriverConfigSpec) error {
+	var problems []string
+	checkRange := func(name string, value *int, minValue, maxValue int) {
+		if value == nil {
+			return
+		}
+		if *value < minValue || (maxValue > 0 && *value > maxValue) {
+			if maxValue > 0 {
+				problems = append(problems, fmt.Sprintf("%s %d is not between %d and %d",
+					name, *value, minValue, maxValue))
+			} else {
+				problems = append(problems, fmt.Sprintf("%s %d is less than %d", name, *value, minValue))
+			}
+		}
+	}
+	checkRange("queryLimit", spec.QueryLimit, 1, 0)
+	checkRange("listVolumeThreshold", spec.ListVolumeThreshold, 0, 0)
+	checkRange("fullSyncIntervalMinutes", spec.FullSyncIntervalMinutes, 1, maxFullSyncIntervalInMin)
+	checkRange("volumeHealthIntervalMinutes", spec.VolumeHealthIntervalMinutes, 1, 0)
+	checkRange("globalMaxSnapshotsPerBlockVolume", spec.GlobalMaxSnapshotsPerBlockVolume,
+		1, maxSnapshotsPerBlockVolume)
+	checkRange("granularMaxSnapshotsPerBlockVolumeInVSAN", spec.GranularMaxSnapshotsPerBlockVolumeInVSAN,
+		0, maxSnapshotsPerBlockVolume)
+	checkRange("granularMaxSnapshotsPerBlockVolumeInVVOL", spec.GranularMaxSnapshotsPerBlockVolumeInVVOL,
+		0, maxSnapshotsPerBlockVolume)
+	problems = append(problems, validateTopologyCategories(spec.TopologyCategories)...)
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid CSIDriverConfig spec: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// validateTopologyCategories returns the problems of the given topology
+// categories. The labels follow the rules of the TopologyCategory sections
+// of the vSphere config secret.
+func validateTopologyCategories(categories []csidriverconfigv1alpha1.TopologyCategory) []string {
+	var problems []string
+	if len(categories) > cnsconfig.MaxNumberOfTopologyCategories {
+		problems = append(problems, fmt.Sprintf("%d topologyCategories exceed the maximum of %d",
+			len(categories), cnsconfig.MaxNumberOfTopologyCategories))
+	}
+	allowedDomains := []string{
+		strings.Split(corev1.LabelFailureDomainBetaZone, "/")[0],
+		strings.Split(corev1.LabelTopologyZone, "/")[0],
+		cnsconfig.TopologyLabelsDomain,
+	}
+	names := make(map[string]bool)
+	labels := make(map[string]bool)
+	for _, category := range categories {
+		if category.Name == "" {
+			problems = append(problems, "topologyCategories contain a category without name")
+			continue
+		}
+		if strings.Contains(category.Name, ",") {
+			problems = append(problems, fmt.Sprintf("topology category name %q contains a comma", category.Name))
+		}
+		if names[category.Name] {
+			problems = append(problems, fmt.Sprintf("topology category %q is duplicated", category.Name))
+		}
+		names[category.Name] = true
+		domain, key, found := strings.Cut(category.Label, "/")
+		if !found || key == "" || !slices.Contains(allowedDomains, domain) {
+			problems = append(problems, fmt.Sprintf("unrecognised topology label %q used for topology category %q",
+				category.Label, category.Name))
+		}
+		if labels[category.Label] {
+			problems = append(problems, fmt.Sprintf("topology label %q is used by several topology categories",
+				category.Label))
+		}
+		labels[category.Label] = true
+	}
+	return problems
+}
+
+// TopologyCategories returns the topology categories of the last valid spec,
+// with their defaults set, or nil if the CSIDriverConfig doesn't set them.
+func TopologyCategories() []csidriverconfigv1alpha1.TopologyCategory {
+	specLock.RLock()
+	defer specLock.RUnlock()
+	return slices.Clone(spec.TopologyCategories)
+}
+
+// intValue returns the value of the tunable selected from the last valid
+// spec, or defaultValue if the tunable is not set.
+func intValue(tunable func(*csidriverconfigv1alpha1.CSIDriverConfigSpec) *int, defaultValue int) int {
+	specLock.RLock()
+	defer specLock.RUnlock()
+	if value := tunable(&spec); value != nil {
+		return *value
+	}
+	return defaultValue
+}
+
+// QueryLimit returns the query limit of ListVolumes, or defaultValue if the
+// CSIDriverConfig doesn't set it.
+func QueryLimit(defaultValue int) int {
+	return intValue(func(s *csidriverconfigv1alpha1.CSIDriverConfigSpec) *int { return s.QueryLimit },
+		defaultValue)
+}
+
+// ListVolumeThreshold returns the list volume threshold of ListVolumes, or
+// defaultValue if the CSIDriverConfig doesn't set it.
+func ListVolumeThreshold(defaultValue int) int {
+	return intValue(func(s *csidriverconfigv1alpha1.CSIDriverConfigSpec) *int { return s.ListVolumeThreshold },
+		defaultValue)
+}
+
+// FullSyncIntervalInMin returns the full sync interval in minutes, or
+// defaultValue if the CSIDriverConfig doesn't set it.
+func FullSyncIntervalInMin(defaultValue int) int {
+	return intValue(func(s *csidriverconfigv1alpha1.CSIDriverConfigSpec) *int { return s.FullSyncIntervalMinutes },
+		defaultValue)
+}
+
+// VolumeHealthIntervalInMin returns the volume health status check interval
+// in minutes, or defaultValue if the CSIDriverConfig doesn't set it.
+func VolumeHealthIntervalInMin(defaultValue int) int {
+	return intValue(func(s *csidriverconfigv1alpha1.CSIDriverConfigSpec) *int {
+		return s.VolumeHealthIntervalMinutes
+	}, defaultValue)
+}
+
+// GlobalMaxSnapshotsPerBlockVolume returns the maximum number of snapshots
+// per block volume, or defaultValue if the CSIDriverConfig doesn't set it.
+func GlobalMaxSnapshotsPerBlockVolume(defaultValue int) int {
+	return intValue(func(s *csidriverconfigv1alpha1.CSIDriverConfigSpec) *int {
+		return s.GlobalMaxSnapshotsPerBlockVolume
+	}, defaultValue)
+}
+
+// GranularMaxSnapshotsPerBlockVolumeInVSAN returns the maximum number of
+// snapshots per block volume on vSAN, or defaultValue if the CSIDriverConfig
+// doesn't set it.
+func GranularMaxSnapshotsPerBlockVolumeInVSAN(defaultValue int) int {
+	return intValue(func(s *csidriverconfigv1alpha1.CSIDriverConfigSpec) *int {
+		return s.GranularMaxSnapshotsPerBlockVolumeInVSAN
+	}, defaultValue)
+}
+
+// GranularMaxSnapshotsPerBlockVolumeInVVOL returns the maximum number of
+// snapshots per block volume on vVol, or defaultValue if the CSIDriverConfig
+// doesn't set it.
+func GranularMaxSnapshotsPerBlockVolumeInVVOL(defaultValue int) int {
+	return intValue(func(s *csidriverconfigv1alpha1.CSIDriverConfigSpec) *int {
+		return s.GranularMaxSnapshotsPerBlockVolumeInVVOL
+	}, defaultValue)
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// CRDSingular represents the singular name of CSIDriverConfig CRD.
+	CRDSingular = "csidriverconfig"
+	// CRDPlural represents the plural name of CSIDriverConfig CRD.
+	CRDPlural = "csidriverconfigs"
+)
+
+// CSIDriverConfigSpec defines the tunables of the driver which can be changed
+// while it runs. A tunable which is not set keeps the value from the
+// environment variables and the vSphere config secret of the driver.
+type CSIDriverConfigSpec struct {
+	// QueryLimit is the number of volumes fetched from CNS at a time by
+	// ListVolumes.
+	QueryLimit *int `json:"queryLimit,omitempty"`
+
+	// ListVolumeThreshold is the maximum number of differences in volumes
+	// between CNS and Kubernetes tolerated by ListVolumes.
+	ListVolumeThreshold *int `json:"listVolumeThreshold,omitempty"`
+
+	// FullSyncIntervalMinutes is the interval of the periodic full sync.
+	FullSyncIntervalMinutes *int `json:"fullSyncIntervalMinutes,omitempty"`
+
+	// VolumeHealthIntervalMinutes is the interval of the periodic volume
+	// health status check.
+	VolumeHealthIntervalMinutes *int `json:"volumeHealthIntervalMinutes,omitempty"`
+
+	// GlobalMaxSnapshotsPerBlockVolume is the maximum number of snapshots per
+	// block volume.
+	GlobalMaxSnapshotsPerBlockVolume *int `json:"globalMaxSnapshotsPerBlockVolume,omitempty"`
+
+	// GranularMaxSnapshotsPerBlockVolumeInVSAN is the maximum number of
+	// snapshots per block volume on vSAN, overriding the global maximum.
+	GranularMaxSnapshotsPerBlockVolumeInVSAN *int `json:"granularMaxSnapshotsPerBlockVolumeInVSAN,omitempty"`
+
+	// GranularMaxSnapshotsPerBlockVolumeInVVOL is the maximum number of
+	// snapshots per block volume on vVol, overriding the global maximum.
+	GranularMaxSnapshotsPerBlockVolumeInVVOL *int `json:"granularMaxSnapshotsPerBlockVolumeInVVOL,omitempty"`
+
+	// TopologyCategories are the vCenter tag categories published as the
+	// topology of the nodes, overriding the topology-categories of the
+	// Labels section of the vSphere config secret.
+	TopologyCategories []TopologyCategory `json:"topologyCategories,omitempty"`
+}
+
+// TopologyCategory is a vCenter tag category published as a topology label
+// of the nodes.
+type TopologyCategory struct {
+	// Name is the name of the vCenter tag category.
+	Name string `json:"name"`
+
+	// Label is the key of the topology label carrying the tag of the
+	// category. It defaults to topology.csi.vmware.com/<name>.
+	Label string `json:"label,omitempty"`
+}
+
+// CSIDriverConfigStatus defines the observed state of CSIDriverConfig.
+type CSIDriverConfigStatus struct {
+	// ObservedGeneration is the generation of the spec last validated by the
+	// driver.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Error is the reason why the spec of the observed generation was
+	// rejected. The driver keeps using the last valid spec meanwhile.
+	Error string `json:"error,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+
+// CSIDriverConfig is the Schema for the csidriverconfigs API
+type CSIDriverConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CSIDriverConfigSpec   `json:"spec,omitempty"`
+	Status CSIDriverConfigStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// CSIDriverConfigList contains a list of CSIDriverConfig
+type CSIDriverConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CSIDriverConfig `json:"items"`
+}
@@ -30,7 +30,6 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientset "k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	featurestatesconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/featurestates/config"
 
@@ -57,6 +56,13 @@ const (
 	// crUpdateRetryInterval is the interval at which pending CR update/create
 	// tasks are executed.
 	crUpdateRetryInterval = 30 * time.Second
+	// crUpdateMaxRetryInterval is the maximum interval between two attempts to
+	// replicate the feature states to the CR of a namespace, which grows
+	// exponentially with the failed attempts.
+	crUpdateMaxRetryInterval = 10 * time.Minute
+	// crReplicationLagThreshold is the duration after which a CR whose feature
+	// states still fail to be replicated is reported as lagging.
+	crReplicationLagThreshold = 10 * time.Minute
 )
 
 // pendingCRUpdates holds latest states of the namespaces to push CR update,
@@ -70,6 +76,22 @@ type pendingCRUpdates struct {
 	namespaceUpdateMap map[string]bool
 	// Latest featureStates.
 	latestFeatureStates []featurestatesv1alpha1.FeatureState
+	// Holds namespace name and the attempts to replicate the latest feature
+	// states to the CR in the namespace, for the namespaces with a pending
+	// update.
+	namespaceAttemptsMap map[string]*replicationAttempts
+}
+
+// replicationAttempts tracks the attempts to replicate the latest feature
+// states to the CR of a namespace, so that a failing namespace is retried
+// individually with an exponential backoff.
+type replicationAttempts struct {
+	// pendingSince is the time at which the update of the CR was enqueued.
+	pendingSince time.Time
+	// failures is the number of consecutive failed attempts.
+	failures int
+	// nextAttempt is the time before which the update is not attempted again.
+	nextAttempt time.Time
 }
 
 var (
@@ -89,9 +111,10 @@ func StartSvFSSReplicationService(ctx context.Context, svFeatureStatConfigMapNam
 	supervisorFeatureStatConfigMapName = svFeatureStatConfigMapName
 	supervisorFeatureStateConfigMapNamespace = svFeatureStateConfigMapNamespace
 	pendingCRUpdatesObj = &pendingCRUpdates{
-		lock:                &sync.RWMutex{},
-		namespaceUpdateMap:  make(map[string]bool),
-		latestFeatureStates: make([]featurestatesv1alpha1.FeatureState, 0),
+		lock:                 &sync.RWMutex{},
+		namespaceUpdateMap:   make(map[string]bool),
+		latestFeatureStates:  make([]featurestatesv1alpha1.FeatureState, 0),
+		namespaceAttemptsMap: make(map[string]*replicationAttempts),
 	}
 	var err error
 	// This is idempotent if CRD is pre-created then we continue with
@@ -174,16 +197,7 @@ func StartSvFSSReplicationService(ctx context.Context, svFeatureStatConfigMapNam
 		log.Errorf("failed to create dynamic informer for %s CR. Error: %+v", CRDPlural, err)
 		return err
 	}
-	_, err = dynInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
-		// Add.
-		AddFunc: nil,
-		// Update.
-		UpdateFunc: nil,
-		// Delete.
-		DeleteFunc: func(obj interface{}) {
-			fssCRDeleted(obj)
-		},
-	})
+	_, err = dynInformer.Informer().AddEventHandler(k8s.NewEventHandler(CRDPlural, nil, nil, fssCRDeleted))
 	if err != nil {
 		return logger.LogNewErrorf(log, "failed to add event handler for informer on %q CR. Error: %v",
 			CRDPlural, err)
@@ -226,52 +240,123 @@ func (pendingCRUpdatesObj *pendingCRUpdates) processPendingCRUpdates() {
 					log.Debugf("CR update is not required for the namespace: %q", namespace)
 					continue
 				}
+				attempts := pendingCRUpdatesObj.getReplicationAttempts(namespace)
+				if time.Now().Before(attempts.nextAttempt) {
+					log.Debugf("Deferring CR update for the namespace: %q to %v after %d failed attempts",
+						namespace, attempts.nextAttempt, attempts.failures)
+					continue
+				}
 				log.Infof("Feature state update is required for namespace: %q", namespace)
-				// Check if CR is present on the namespace.
-				featurestateCR := &featurestatesv1alpha1.CnsCsiSvFeatureStates{}
-				err := controllerRuntimeClient.Get(ctx, client.ObjectKey{Name: SVFeatureStateCRName,
-					Namespace: namespace}, featurestateCR)
-				if err == nil {
-					// Attempt to Update CR.
-					featurestateCR.Spec.FeatureStates = pendingCRUpdatesObj.latestFeatureStates
-					err = controllerRuntimeClient.Update(ctx, featurestateCR)
-					if err != nil {
-						log.Errorf("failed to update cnsCsiSvFeatureStates CR instance in the namespace: %q, Err: %v",
-							namespace, err)
-						continue
-					}
-					pendingCRUpdatesObj.namespaceUpdateMap[namespace] = false
-					log.Infof("Updated cnsCsiSvFeatureStates CR instance in the namespace: %q", namespace)
-				} else {
-					if apierrors.IsNotFound(err) {
-						log.Infof("cnsCsiSvFeatureStates CR instance is not present in the namespace: %q. "+
-							"Creating CR with latest feature switch state, Err: %v", namespace, err)
-						// Attempt to Create the CR.
-						cnsCsiSvFeatureStates := &featurestatesv1alpha1.CnsCsiSvFeatureStates{
-							ObjectMeta: metav1.ObjectMeta{Name: SVFeatureStateCRName, Namespace: namespace},
-							Spec: featurestatesv1alpha1.CnsCsiSvFeatureStatesSpec{
-								FeatureStates: pendingCRUpdatesObj.latestFeatureStates,
-							},
-						}
-						err = controllerRuntimeClient.Create(ctx, cnsCsiSvFeatureStates)
-						if err != nil {
-							log.Errorf("failed to create cnsCsiSvFeatureStates CR instance in the "+
-								"namespace: %q. Continuing the FSS replication to other namespaces.., Err: %v",
-								namespace, err)
-							continue
-						}
-						pendingCRUpdatesObj.namespaceUpdateMap[namespace] = false
-						log.Infof("Created cnsCsiSvFeatureStates CR instance in the namespace: %q", namespace)
-					} else {
-						log.Errorf("failed to check if cnsCsiSvFeatureStates CR is present in the namespace :%q, err: %v",
-							namespace, err)
+				err := pendingCRUpdatesObj.replicateFeatureStates(ctx, namespace)
+				if err != nil {
+					attempts.failures++
+					backoff := crUpdateRetryInterval
+					for i := 1; i < attempts.failures && backoff < crUpdateMaxRetryInterval; i++ {
+						backoff *= 2
 					}
+					backoff = min(backoff, crUpdateMaxRetryInterval)
+					attempts.nextAttempt = time.Now().Add(backoff)
+					reportReplicationFailure(ctx, namespace, attempts, err)
+					continue
 				}
+				pendingCRUpdatesObj.namespaceUpdateMap[namespace] = false
+				delete(pendingCRUpdatesObj.namespaceAttemptsMap, namespace)
 			}
 		}()
 	}
 }
 
+// getReplicationAttempts returns the attempts to replicate the latest feature
+// states to the CR of the given namespace. It must be called with the lock held.
+func (pendingCRUpdatesObj *pendingCRUpdates) getReplicationAttempts(namespace string) *replicationAttempts {
+	attempts, ok := pendingCRUpdatesObj.namespaceAttemptsMap[namespace]
+	if !ok {
+		attempts = &replicationAttempts{pendingSince: time.Now()}
+		pendingCRUpdatesObj.namespaceAttemptsMap[namespace] = attempts
+	}
+	return attempts
+}
+
+// replicateFeatureStates creates or updates the cnsCsiSvFeatureStates CR in
+// the given namespace with the latest feature states, and marks it synced.
+// It must be called with the lock held.
+func (pendingCRUpdatesObj *pendingCRUpdates) replicateFeatureStates(ctx context.Context, namespace string) error {
+	log := logger.GetLogger(ctx)
+	syncedStatus := featurestatesv1alpha1.CnsCsiSvFeatureStatesStatus{
+		ReplicationState: featurestatesv1alpha1.ReplicationStateSynced,
+		LastSyncTime:     &metav1.Time{Time: time.Now()},
+	}
+	// Check if CR is present on the namespace.
+	featurestateCR := &featurestatesv1alpha1.CnsCsiSvFeatureStates{}
+	err := controllerRuntimeClient.Get(ctx, client.ObjectKey{Name: SVFeatureStateCRName,
+		Namespace: namespace}, featurestateCR)
+	if err == nil {
+		// Attempt to Update CR.
+		featurestateCR.Spec.FeatureStates = pendingCRUpdatesObj.latestFeatureStates
+		featurestateCR.Status = syncedStatus
+		err = controllerRuntimeClient.Update(ctx, featurestateCR)
+		if err != nil {
+			return logger.LogNewErrorf(log, "failed to update cnsCsiSvFeatureStates CR instance in the "+
+				"namespace: %q, Err: %v", namespace, err)
+		}
+		log.Infof("Updated cnsCsiSvFeatureStates CR instance in the namespace: %q", namespace)
+		return nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return logger.LogNewErrorf(log, "failed to check if cnsCsiSvFeatureStates CR is present in the "+
+			"namespace :%q, err: %v", namespace, err)
+	}
+	log.Infof("cnsCsiSvFeatureStates CR instance is not present in the namespace: %q. "+
+		"Creating CR with latest feature switch state, Err: %v", namespace, err)
+	// Attempt to Create the CR.
+	cnsCsiSvFeatureStates := &featurestatesv1alpha1.CnsCsiSvFeatureStates{
+		ObjectMeta: metav1.ObjectMeta{Name: SVFeatureStateCRName, Namespace: namespace},
+		Spec: featurestatesv1alpha1.CnsCsiSvFeatureStatesSpec{
+			FeatureStates: pendingCRUpdatesObj.latestFeatureStates,
+		},
+		Status: syncedStatus,
+	}
+	err = controllerRuntimeClient.Create(ctx, cnsCsiSvFeatureStates)
+	if err != nil {
+		return logger.LogNewErrorf(log, "failed to create cnsCsiSvFeatureStates CR instance in the "+
+			"namespace: %q. Continuing the FSS replication to other namespaces.., Err: %v", namespace, err)
+	}
+	log.Infof("Created cnsCsiSvFeatureStates CR instance in the namespace: %q", namespace)
+	return nil
+}
+
+// reportReplicationFailure reports the given failed attempt to replicate the
+// latest feature states in the status of the cnsCsiSvFeatureStates CR of the
+// given namespace, as failed, or as lagging if the update has been pending
+// for longer than crReplicationLagThreshold. Nothing is reported if the CR
+// can't be retrieved.
+func reportReplicationFailure(ctx context.Context, namespace string, attempts *replicationAttempts,
+	replicationErr error) {
+	log := logger.GetLogger(ctx)
+	featurestateCR := &featurestatesv1alpha1.CnsCsiSvFeatureStates{}
+	err := controllerRuntimeClient.Get(ctx, client.ObjectKey{Name: SVFeatureStateCRName,
+		Namespace: namespace}, featurestateCR)
+	if err != nil {
+		log.Debugf("failed to get cnsCsiSvFeatureStates CR instance in the namespace: %q to report the "+
+			"replication failure. Err: %v", namespace, err)
+		return
+	}
+	featurestateCR.Status.ReplicationState = featurestatesv1alpha1.ReplicationStateFailed
+	if time.Since(attempts.pendingSince) > crReplicationLagThreshold {
+		featurestateCR.Status.ReplicationState = featurestatesv1alpha1.ReplicationStateLagging
+	}
+	featurestateCR.Status.FailedAttempts = attempts.failures
+	featurestateCR.Status.Error = replicationErr.Error()
+	err = controllerRuntimeClient.Update(ctx, featurestateCR)
+	if err != nil {
+		log.Warnf("failed to report the replication failure in the cnsCsiSvFeatureStates CR instance in "+
+			"the namespace: %q. Err: %v", namespace, err)
+		return
+	}
+	log.Infof("Reported cnsCsiSvFeatureStates CR instance in the namespace: %q as %s after %d failed attempts",
+		namespace, featurestateCR.Status.ReplicationState, attempts.failures)
+}
+
 // enqueueFeatureStateUpdatesForAllWorkloadNamespaces helps enqueue
 // featurestates updates for all workload namespaces.
 func (pendingCRUpdatesObj *pendingCRUpdates) enqueueFeatureStateUpdatesForAllWorkloadNamespaces(
@@ -283,6 +368,10 @@ func (pendingCRUpdatesObj *pendingCRUpdates) enqueueFeatureStateUpdatesForAllWor
 	pendingCRUpdatesObj.latestFeatureStates = featurestates
 	for namespace := range pendingCRUpdatesObj.namespaceUpdateMap {
 		pendingCRUpdatesObj.namespaceUpdateMap[namespace] = true
+		// Attempt to replicate the new feature states right away.
+		if attempts, ok := pendingCRUpdatesObj.namespaceAttemptsMap[namespace]; ok {
+			attempts.nextAttempt = time.Time{}
+		}
 	}
 	log.Infof("Enqueued CR updates for all workload namespaces")
 }
@@ -296,6 +385,9 @@ func (pendingCRUpdatesObj *pendingCRUpdates) enqueueFeatureStateUpdatesForWorklo
 
 	log := logger.GetLogger(ctx)
 	pendingCRUpdatesObj.namespaceUpdateMap[namespace] = true
+	if attempts, ok := pendingCRUpdatesObj.namespaceAttemptsMap[namespace]; ok {
+		attempts.nextAttempt = time.Time{}
+	}
 	log.Infof("Enqueued CR updates for workload namespace: %q", namespace)
 }
 
@@ -421,6 +513,7 @@ func namespaceUpdated(oldObj, newObj interface{}) {
 		pendingCRUpdatesObj.lock.Lock()
 		defer pendingCRUpdatesObj.lock.Unlock()
 		delete(pendingCRUpdatesObj.namespaceUpdateMap, newNamespace.Name)
+		delete(pendingCRUpdatesObj.namespaceAttemptsMap, newNamespace.Name)
 	}
 }
 
@@ -437,6 +530,7 @@ func namespaceDeleted(obj interface{}) {
 	pendingCRUpdatesObj.lock.Lock()
 	defer pendingCRUpdatesObj.lock.Unlock()
 	delete(pendingCRUpdatesObj.namespaceUpdateMap, namespace.Name)
+	delete(pendingCRUpdatesObj.namespaceAttemptsMap, namespace.Name)
 }
 
 // getFeatureStates returns latest feature states from supervisor config-map
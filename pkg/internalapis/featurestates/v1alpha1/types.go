@@ -33,6 +33,37 @@ type FeatureState struct {
 	Enabled bool `json:"enabled"`
 }
 
+// ReplicationState is the state of the replication of the supervisor feature
+// states to a CnsCsiSvFeatureStates instance.
+type ReplicationState string
+
+const (
+	// ReplicationStateSynced means the instance holds the latest supervisor
+	// feature states.
+	ReplicationStateSynced ReplicationState = "Synced"
+	// ReplicationStateFailed means the last attempt to replicate the latest
+	// supervisor feature states to the instance failed, it is retried.
+	ReplicationStateFailed ReplicationState = "Failed"
+	// ReplicationStateLagging means the latest supervisor feature states have
+	// failed to be replicated to the instance for a long time.
+	ReplicationStateLagging ReplicationState = "Lagging"
+)
+
+// CnsCsiSvFeatureStatesStatus defines the observed state of CnsCsiSvFeatureStates
+type CnsCsiSvFeatureStatesStatus struct {
+	// ReplicationState is the state of the replication of the latest
+	// supervisor feature states to the instance.
+	ReplicationState ReplicationState `json:"replicationState,omitempty"`
+	// LastSyncTime is the time at which the supervisor feature states were
+	// last replicated to the instance.
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+	// FailedAttempts is the number of consecutive failed attempts to replicate
+	// the latest supervisor feature states to the instance.
+	FailedAttempts int `json:"failedAttempts,omitempty"`
+	// Error is the error of the last failed attempt.
+	Error string `json:"error,omitempty"`
+}
+
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // CnsCsiSvFeatureStates is the Schema for the cnscsisvfeaturestates API
@@ -42,7 +73,8 @@ type CnsCsiSvFeatureStates struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
-	Spec CnsCsiSvFeatureStatesSpec `json:"spec,omitempty"`
+	Spec   CnsCsiSvFeatureStatesSpec   `json:"spec,omitempty"`
+	Status CnsCsiSvFeatureStatesStatus `json:"status,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -32,6 +32,7 @@ func (in *CnsCsiSvFeatureStates) DeepCopyInto(out *CnsCsiSvFeatureStates) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -107,6 +108,26 @@ func (in *CnsCsiSvFeatureStatesSpec) DeepCopy() *CnsCsiSvFeatureStatesSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CnsCsiSvFeatureStatesStatus) DeepCopyInto(out *CnsCsiSvFeatureStatesStatus) {
+	*out = *in
+	if in.LastSyncTime != nil {
+		in, out := &in.LastSyncTime, &out.LastSyncTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CnsCsiSvFeatureStatesStatus.
+func (in *CnsCsiSvFeatureStatesStatus) DeepCopy() *CnsCsiSvFeatureStatesStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CnsCsiSvFeatureStatesStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FeatureState) DeepCopyInto(out *FeatureState) {
 	*out = *in
@@ -27,6 +27,10 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 
 	cnsfilevolclientv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsoperator/cnsfilevolumeclient/v1alpha1"
+	cnssnapshotdeltav1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsoperator/cnssnapshotdelta/v1alpha1"
+	cnssnapshotexportv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsoperator/cnssnapshotexport/v1alpha1"
+	migrationv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsoperator/cnsvolumepolicymigration/v1alpha1"
+	cnsvolumerestorev1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsoperator/cnsvolumerestore/v1alpha1"
 	triggercsifullsyncv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsoperator/triggercsifullsync/v1alpha1"
 	cnscsisvfeaturestatesv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/featurestates/v1alpha1"
 )
@@ -46,6 +50,14 @@ var (
 
 	// TriggerCsiFullSyncPlural is plural of TriggerCsiFullSyncPlural
 	TriggerCsiFullSyncPlural = "triggercsifullsyncs"
+	// CnsVolumeRestorePlural is plural of CnsVolumeRestore
+	CnsVolumeRestorePlural = "cnsvolumerestores"
+	// CnsVolumePolicyMigrationPlural is plural of CnsVolumePolicyMigration
+	CnsVolumePolicyMigrationPlural = "cnsvolumepolicymigrations"
+	// CnsSnapshotExportPlural is plural of CnsSnapshotExport
+	CnsSnapshotExportPlural = "cnssnapshotexports"
+	// CnsSnapshotDeltaPlural is plural of CnsSnapshotDelta
+	CnsSnapshotDeltaPlural = "cnssnapshotdeltas"
 )
 
 var (
@@ -80,6 +92,26 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		&triggercsifullsyncv1alpha1.TriggerCsiFullSyncList{},
 	)
 
+	scheme.AddKnownTypes(
+		SchemeGroupVersion,
+		&cnsvolumerestorev1alpha1.CnsVolumeRestore{},
+		&cnsvolumerestorev1alpha1.CnsVolumeRestoreList{},
+	)
+	scheme.AddKnownTypes(
+		SchemeGroupVersion,
+		&migrationv1alpha1.CnsVolumePolicyMigration{},
+		&migrationv1alpha1.CnsVolumePolicyMigrationList{},
+	)
+	scheme.AddKnownTypes(
+		SchemeGroupVersion,
+		&cnssnapshotexportv1alpha1.CnsSnapshotExport{},
+		&cnssnapshotexportv1alpha1.CnsSnapshotExportList{},
+	)
+	scheme.AddKnownTypes(
+		SchemeGroupVersion,
+		&cnssnapshotdeltav1alpha1.CnsSnapshotDelta{},
+		&cnssnapshotdeltav1alpha1.CnsSnapshotDeltaList{},
+	)
 	scheme.AddKnownTypes(
 		SchemeGroupVersion,
 		&cnscsisvfeaturestatesv1alpha1.CnsCsiSvFeatureStates{},
@@ -0,0 +1,102 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"k8s.io/client-go/tools/cache"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+const (
+	eventAdd    = "add"
+	eventUpdate = "update"
+	eventDelete = "delete"
+
+	eventFailurePanic          = "panic"
+	eventFailureNilObject      = "nil-object"
+	eventFailureUnexpectedType = "unexpected-type"
+)
+
+// NewEventHandler returns the informer callbacks of the named listener for
+// objects of type T. Objects of another type are logged and counted instead
+// of being passed to the callbacks, and so is a panic in a callback instead
+// of crashing the goroutine of the shared informer, which would stop the
+// delivery of events to all its listeners. Delete callbacks get the last
+// known state of objects deleted while the informer was disconnected,
+// instead of a tombstone. Nil callbacks are not registered.
+func NewEventHandler[T any](listener string, add func(obj T), update func(oldObj, newObj T),
+	remove func(obj T)) cache.ResourceEventHandlerFuncs {
+	handler := cache.ResourceEventHandlerFuncs{}
+	if add != nil {
+		handler.AddFunc = func(obj interface{}) {
+			handleEvent(listener, eventAdd, func(objs []T) { add(objs[0]) }, obj)
+		}
+	}
+	if update != nil {
+		handler.UpdateFunc = func(oldObj, newObj interface{}) {
+			handleEvent(listener, eventUpdate, func(objs []T) { update(objs[0], objs[1]) }, oldObj, newObj)
+		}
+	}
+	if remove != nil {
+		handler.DeleteFunc = func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			handleEvent(listener, eventDelete, func(objs []T) { remove(objs[0]) }, obj)
+		}
+	}
+	return handler
+}
+
+// handleEvent invokes the callback of the listener for the event on the
+// given objects converted to type T, recovering from any panic in the
+// callback.
+func handleEvent[T any](listener string, event string, callback func(objs []T), objs ...interface{}) {
+	typedObjs := make([]T, 0, len(objs))
+	for _, obj := range objs {
+		if obj == nil {
+			logger.GetLoggerWithNoContext().Warnw("Ignoring informer event with nil object",
+				"listener", listener, "event", event)
+			prometheus.InformerEventHandlerFailuresCounter.WithLabelValues(listener, event,
+				eventFailureNilObject).Inc()
+			return
+		}
+		typedObj, ok := obj.(T)
+		if !ok {
+			logger.GetLoggerWithNoContext().Warnw("Ignoring informer event with object of unexpected type",
+				"listener", listener, "event", event, "type", fmt.Sprintf("%T", obj))
+			prometheus.InformerEventHandlerFailuresCounter.WithLabelValues(listener, event,
+				eventFailureUnexpectedType).Inc()
+			return
+		}
+		typedObjs = append(typedObjs, typedObj)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			logger.GetLoggerWithNoContext().Errorw("Recovered from panic in informer event handler",
+				"listener", listener, "event", event, "panic", r, "stack", string(debug.Stack()))
+			prometheus.InformerEventHandlerFailuresCounter.WithLabelValues(listener, event,
+				eventFailurePanic).Inc()
+		}
+	}()
+	callback(typedObjs)
+}
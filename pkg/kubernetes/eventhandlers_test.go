@@ -0,0 +1,58 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestNewEventHandler(t *testing.T) {
+	pv := &v1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: "pv-1"}}
+	var deleted *v1.PersistentVolume
+	handler := NewEventHandler("pv",
+		func(obj *v1.PersistentVolume) {
+			panic("unexpected object")
+		},
+		nil,
+		func(obj *v1.PersistentVolume) {
+			deleted = obj
+		})
+
+	if handler.UpdateFunc != nil {
+		t.Errorf("expected no update callback")
+	}
+	// A panic in a callback must not escape the handler.
+	handler.OnAdd(pv, false)
+
+	handler.OnDelete(cache.DeletedFinalStateUnknown{Key: "pv-1", Obj: pv})
+	if deleted != pv {
+		t.Errorf("expected delete callback to get the PV from the tombstone, got %+v", deleted)
+	}
+	deleted = nil
+	handler.OnDelete(nil)
+	if deleted != nil {
+		t.Errorf("expected delete callback not to be called for a nil object, got %+v", deleted)
+	}
+	handler.OnDelete(&v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "pvc-1"}})
+	if deleted != nil {
+		t.Errorf("expected delete callback not to be called for a PVC, got %+v", deleted)
+	}
+}
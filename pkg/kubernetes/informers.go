@@ -101,11 +101,7 @@ func (im *InformerManager) AddNodeListener(ctx context.Context, add func(obj int
 		im.nodeInformer = im.informerFactory.Core().V1().Nodes().Informer()
 	}
 
-	_, err := im.nodeInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc:    add,
-		UpdateFunc: update,
-		DeleteFunc: remove,
-	})
+	_, err := im.nodeInformer.AddEventHandler(NewEventHandler("node", add, update, remove))
 	if err != nil {
 		return logger.LogNewErrorf(log, "failed to add event handler on node listener. Error: %v", err)
 	}
@@ -120,11 +116,7 @@ func (im *InformerManager) AddCSINodeListener(ctx context.Context, add func(obj
 		im.nodeInformer = im.informerFactory.Storage().V1().CSINodes().Informer()
 	}
 
-	_, err := im.nodeInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc:    add,
-		UpdateFunc: update,
-		DeleteFunc: remove,
-	})
+	_, err := im.nodeInformer.AddEventHandler(NewEventHandler("csinode", add, update, remove))
 	if err != nil {
 		return logger.LogNewErrorf(log, "failed to add event handler on CSINode listener. Error: %v", err)
 	}
@@ -140,11 +132,7 @@ func (im *InformerManager) AddPVCListener(ctx context.Context, add func(obj inte
 	}
 	im.pvcSynced = im.pvcInformer.HasSynced
 
-	_, err := im.pvcInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc:    add,
-		UpdateFunc: update,
-		DeleteFunc: remove,
-	})
+	_, err := im.pvcInformer.AddEventHandler(NewEventHandler("pvc", add, update, remove))
 	if err != nil {
 		return logger.LogNewErrorf(log, "failed to add event handler on PVC listener. Error: %v", err)
 	}
@@ -160,17 +148,41 @@ func (im *InformerManager) AddPVListener(ctx context.Context, add func(obj inter
 	}
 	im.pvSynced = im.pvInformer.HasSynced
 
-	_, err := im.pvInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc:    add,
-		UpdateFunc: update,
-		DeleteFunc: remove,
-	})
+	_, err := im.pvInformer.AddEventHandler(NewEventHandler("pv", add, update, remove))
 	if err != nil {
 		return logger.LogNewErrorf(log, "failed to add event handler on PV listener. Error: %v", err)
 	}
 	return nil
 }
 
+// AddPVIndexers adds the given indexers to the PV informer. Indexers already
+// present on the informer are skipped, so that every user of a shared informer
+// manager can register the indexers it relies on. Indexers have to be added
+// before the informer manager starts listening.
+func (im *InformerManager) AddPVIndexers(ctx context.Context, indexers cache.Indexers) error {
+	log := logger.GetLogger(ctx)
+	if im.pvInformer == nil {
+		im.pvInformer = im.informerFactory.Core().V1().PersistentVolumes().Informer()
+	}
+	im.pvSynced = im.pvInformer.HasSynced
+
+	existingIndexers := im.pvInformer.GetIndexer().GetIndexers()
+	newIndexers := cache.Indexers{}
+	for name, indexFunc := range indexers {
+		if _, exists := existingIndexers[name]; !exists {
+			newIndexers[name] = indexFunc
+		}
+	}
+	if len(newIndexers) == 0 {
+		return nil
+	}
+	err := im.pvInformer.AddIndexers(newIndexers)
+	if err != nil {
+		return logger.LogNewErrorf(log, "failed to add indexers on PV informer. Error: %v", err)
+	}
+	return nil
+}
+
 // AddNamespaceListener hooks up add, update, delete callbacks.
 func (im *InformerManager) AddNamespaceListener(ctx context.Context, add func(obj interface{}),
 	update func(oldObj, newObj interface{}), remove func(obj interface{})) error {
@@ -180,42 +192,53 @@ func (im *InformerManager) AddNamespaceListener(ctx context.Context, add func(ob
 	}
 	im.namespaceSynced = im.namespaceInformer.HasSynced
 
-	_, err := im.namespaceInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc:    add,
-		UpdateFunc: update,
-		DeleteFunc: remove,
-	})
+	_, err := im.namespaceInformer.AddEventHandler(NewEventHandler("namespace", add, update, remove))
 	if err != nil {
 		return logger.LogNewErrorf(log, "failed to add event handler on namespace listener. Error: %v", err)
 	}
 	return nil
 }
 
-// AddConfigMapListener hooks up add, update, delete callbacks.
+// AddConfigMapListener hooks up add, update, delete callbacks on the
+// ConfigMaps of the given namespace. Each namespace has its own informer.
 func (im *InformerManager) AddConfigMapListener(ctx context.Context, client clientset.Interface, namespace string,
 	add func(obj interface{}), update func(oldObj, newObj interface{}), remove func(obj interface{})) error {
 	log := logger.GetLogger(ctx)
-	if im.configMapInformer == nil {
-		im.configMapInformer = v1.NewFilteredConfigMapInformer(client, namespace,
+	if im.configMapInformers == nil {
+		im.configMapInformers = make(map[string]cache.SharedInformer)
+	}
+	configMapInformer, exists := im.configMapInformers[namespace]
+	if !exists {
+		configMapInformer = v1.NewFilteredConfigMapInformer(client, namespace,
 			resyncPeriodConfigMapInformer, cache.Indexers{}, nil)
+		im.configMapInformers[namespace] = configMapInformer
 	}
-	im.configMapSynced = im.configMapInformer.HasSynced
+	im.configMapSynced = im.configMapInformersSynced
 
-	_, err := im.configMapInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc:    add,
-		UpdateFunc: update,
-		DeleteFunc: remove,
-	})
+	_, err := configMapInformer.AddEventHandler(NewEventHandler("configmap", add, update, remove))
 	if err != nil {
 		return logger.LogNewErrorf(log, "failed to add event handler on configmap listener. Error: %v", err)
 	}
-	stopCh := make(chan struct{})
-	// Since NewFilteredConfigMapInformer is not part of the informer factory,
-	// we need to invoke the Run() explicitly to start the shared informer.
-	go im.configMapInformer.Run(stopCh)
+	if !exists {
+		stopCh := make(chan struct{})
+		// Since NewFilteredConfigMapInformer is not part of the informer factory,
+		// we need to invoke the Run() explicitly to start the shared informer.
+		go configMapInformer.Run(stopCh)
+	}
 	return nil
 }
 
+// configMapInformersSynced returns true if the ConfigMap informers of all the
+// namespaces have been synced.
+func (im *InformerManager) configMapInformersSynced() bool {
+	for _, configMapInformer := range im.configMapInformers {
+		if !configMapInformer.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
 // AddPodListener hooks up add, update, delete callbacks.
 func (im *InformerManager) AddPodListener(ctx context.Context, add func(obj interface{}),
 	update func(oldObj, newObj interface{}), remove func(obj interface{})) error {
@@ -225,11 +248,7 @@ func (im *InformerManager) AddPodListener(ctx context.Context, add func(obj inte
 	}
 	im.podSynced = im.podInformer.HasSynced
 
-	_, err := im.podInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc:    add,
-		UpdateFunc: update,
-		DeleteFunc: remove,
-	})
+	_, err := im.podInformer.AddEventHandler(NewEventHandler("pod", add, update, remove))
 	if err != nil {
 		return logger.LogNewErrorf(log, "failed to add event handler on Pod listener. Error: %v", err)
 	}
@@ -244,11 +263,7 @@ func (im *InformerManager) AddVolumeAttachmentListener(ctx context.Context, add
 		im.volumeAttachmentInformer = im.informerFactory.Storage().V1().VolumeAttachments().Informer()
 	}
 
-	_, err := im.volumeAttachmentInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc:    add,
-		UpdateFunc: update,
-		DeleteFunc: remove,
-	})
+	_, err := im.volumeAttachmentInformer.AddEventHandler(NewEventHandler("volumeattachment", add, update, remove))
 	if err != nil {
 		return logger.LogNewErrorf(log, "failed to add event handler on volume attachment listener. Error: %v",
 			err)
@@ -256,11 +271,49 @@ func (im *InformerManager) AddVolumeAttachmentListener(ctx context.Context, add
 	return nil
 }
 
+// AddVolumeAttachmentIndexers adds the given indexers to the volume attachment
+// informer. Indexers already present on the informer are skipped. Indexers
+// have to be added before the informer manager starts listening.
+func (im *InformerManager) AddVolumeAttachmentIndexers(ctx context.Context, indexers cache.Indexers) error {
+	log := logger.GetLogger(ctx)
+	if im.volumeAttachmentInformer == nil {
+		im.volumeAttachmentInformer = im.informerFactory.Storage().V1().VolumeAttachments().Informer()
+	}
+
+	existingIndexers := im.volumeAttachmentInformer.GetIndexer().GetIndexers()
+	newIndexers := cache.Indexers{}
+	for name, indexFunc := range indexers {
+		if _, exists := existingIndexers[name]; !exists {
+			newIndexers[name] = indexFunc
+		}
+	}
+	if len(newIndexers) == 0 {
+		return nil
+	}
+	err := im.volumeAttachmentInformer.AddIndexers(newIndexers)
+	if err != nil {
+		return logger.LogNewErrorf(log, "failed to add indexers on volume attachment informer. Error: %v", err)
+	}
+	return nil
+}
+
 // GetPVLister returns PV Lister for the calling informer manager.
 func (im *InformerManager) GetPVLister() corelisters.PersistentVolumeLister {
 	return im.informerFactory.Core().V1().PersistentVolumes().Lister()
 }
 
+// GetPVIndexer returns the indexer backing the PV informer of the calling
+// informer manager.
+func (im *InformerManager) GetPVIndexer() cache.Indexer {
+	return im.informerFactory.Core().V1().PersistentVolumes().Informer().GetIndexer()
+}
+
+// GetVolumeAttachmentIndexer returns the indexer backing the volume
+// attachment informer of the calling informer manager.
+func (im *InformerManager) GetVolumeAttachmentIndexer() cache.Indexer {
+	return im.informerFactory.Storage().V1().VolumeAttachments().Informer().GetIndexer()
+}
+
 // GetPVCLister returns PVC Lister for the calling informer manager.
 func (im *InformerManager) GetPVCLister() corelisters.PersistentVolumeClaimLister {
 	return im.informerFactory.Core().V1().PersistentVolumeClaims().Lister()
@@ -55,8 +55,12 @@ import (
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis"
+	cnsfullsynccheckpointv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsfullsynccheckpoint/v1alpha1"
+	cnsnodeinfov1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsnodeinfo/v1alpha1"
+	cnsusagereportv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsusagereport/v1alpha1"
 	cnsvolumeinfov1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsvolumeinfo/v1alpha1"
 	cnsvolumeoprequestv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsvolumeoperationrequest/v1alpha1"
+	csidriverconfigv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/csidriverconfig/v1alpha1"
 	csinodetopologyv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/csinodetopology/v1alpha1"
 )
 
@@ -147,15 +151,13 @@ func NewSnapshotterClient(ctx context.Context) (snapshotterClientSet.Interface,
 }
 
 // GetRestClientConfigForSupervisor returns restclient config for given
-// endpoint, port, certificate and token.
+// endpoint, port, certificate and token. The token is cached and refreshed
+// by a token manager shared by all the supervisor clients of the process.
 func GetRestClientConfigForSupervisor(ctx context.Context, endpoint string, port string) *restclient.Config {
 	log := logger.GetLogger(ctx)
 	var config *restclient.Config
-	const (
-		tokenFile  = cnsconfig.DefaultpvCSIProviderPath + "/token"
-		rootCAFile = cnsconfig.DefaultpvCSIProviderPath + "/ca.crt"
-	)
-	token, err := os.ReadFile(tokenFile)
+	const rootCAFile = cnsconfig.DefaultpvCSIProviderPath + "/ca.crt"
+	tokenManager, err := getSupervisorTokenManager(ctx)
 	if err != nil {
 		return nil
 	}
@@ -168,8 +170,11 @@ func GetRestClientConfigForSupervisor(ctx context.Context, endpoint string, port
 		TLSClientConfig: restclient.TLSClientConfig{
 			CAFile: rootCAFile,
 		},
-		BearerToken: string(token),
+		// The token is set on every request by the token manager, which
+		// refreshes it before it expires.
+		WrapTransport: tokenManager.wrapTransport,
 	}
+	tokenManager.startRefresher()
 	config.QPS, config.Burst = getClientThroughput(ctx, true)
 	return config
 }
@@ -248,6 +253,26 @@ func NewClientForGroup(ctx context.Context, config *restclient.Config, groupName
 			log.Errorf("failed to add CNSVolumeInfo to scheme with error: %+v", err)
 			return nil, err
 		}
+		err = cnsnodeinfov1alpha1.AddToScheme(scheme)
+		if err != nil {
+			log.Errorf("failed to add CnsNodeInfo to scheme with error: %+v", err)
+			return nil, err
+		}
+		err = cnsfullsynccheckpointv1alpha1.AddToScheme(scheme)
+		if err != nil {
+			log.Errorf("failed to add CnsFullSyncCheckpoint to scheme with error: %+v", err)
+			return nil, err
+		}
+		err = cnsusagereportv1alpha1.AddToScheme(scheme)
+		if err != nil {
+			log.Errorf("failed to add CnsUsageReport to scheme with error: %+v", err)
+			return nil, err
+		}
+		err = csidriverconfigv1alpha1.AddToScheme(scheme)
+		if err != nil {
+			log.Errorf("failed to add CSIDriverConfig to scheme with error: %+v", err)
+			return nil, err
+		}
 	}
 	client, err := client.New(config, client.Options{
 		Scheme: scheme,
@@ -478,7 +503,7 @@ func CreateCustomResourceDefinitionFromManifest(ctx context.Context, embedFiles
 func GetNodeIdFromCSINode(csiNode *storagev1.CSINode) string {
 	drivers := csiNode.Spec.Drivers
 	for _, driver := range drivers {
-		if driver.Name == types.Name {
+		if driver.Name == types.DriverName() {
 			return driver.NodeID
 		}
 	}
@@ -0,0 +1,262 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	cnsconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+const (
+	// supervisorTokenFile is the file holding the token used by pvCSI to
+	// authenticate against the supervisor cluster.
+	supervisorTokenFile = cnsconfig.DefaultpvCSIProviderPath + "/token"
+	// supervisorTokenRefreshWindow is how long before its expiry the cached
+	// token is refreshed.
+	supervisorTokenRefreshWindow = 5 * time.Minute
+	// supervisorTokenRecheckInterval is the maximum time a token is served
+	// from the cache before the token file is read again. The token file can
+	// be rotated without the expiry of the current token being reached.
+	supervisorTokenRecheckInterval = time.Minute
+	// supervisorTokenMinReloadInterval rate limits the reloads of the token
+	// triggered by 401 responses of the supervisor cluster.
+	supervisorTokenMinReloadInterval = 5 * time.Second
+)
+
+var (
+	supervisorTokenManagerInstance *supervisorTokenManager
+	supervisorTokenManagerLock     sync.Mutex
+)
+
+// supervisorTokenManager caches the token used to authenticate against the
+// supervisor cluster and refreshes it from the token file before it expires,
+// so that requests are not failing with 401 once the token is rotated.
+type supervisorTokenManager struct {
+	tokenFile string
+	lock      sync.RWMutex
+	token     string
+	// issuedAt and expiry are read from the claims of the token, if any.
+	issuedAt time.Time
+	expiry   time.Time
+	// loadedAt is the time the token was last read from the token file.
+	loadedAt      time.Time
+	refresherOnce sync.Once
+	now           func() time.Time
+}
+
+// getSupervisorTokenManager returns the supervisorTokenManager instance,
+// loading the token for the first time if needed.
+func getSupervisorTokenManager(ctx context.Context) (*supervisorTokenManager, error) {
+	supervisorTokenManagerLock.Lock()
+	defer supervisorTokenManagerLock.Unlock()
+	if supervisorTokenManagerInstance != nil {
+		return supervisorTokenManagerInstance, nil
+	}
+	manager := newSupervisorTokenManager(supervisorTokenFile)
+	if err := manager.reload(ctx); err != nil {
+		return nil, err
+	}
+	supervisorTokenManagerInstance = manager
+	return supervisorTokenManagerInstance, nil
+}
+
+func newSupervisorTokenManager(tokenFile string) *supervisorTokenManager {
+	return &supervisorTokenManager{
+		tokenFile: tokenFile,
+		now:       time.Now,
+	}
+}
+
+// getToken returns the cached token, reloading it from the token file when
+// it is about to expire or has not been checked for too long.
+func (m *supervisorTokenManager) getToken(ctx context.Context) (string, error) {
+	m.lock.RLock()
+	token, stale := m.token, m.isStale()
+	m.lock.RUnlock()
+	if !stale {
+		return token, nil
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if !m.isStale() {
+		return m.token, nil
+	}
+	if err := m.reloadLocked(ctx); err != nil {
+		if m.token != "" && (m.expiry.IsZero() || m.now().Before(m.expiry)) {
+			// The cached token is still valid, keep using it until the
+			// token file can be read again.
+			return m.token, nil
+		}
+		return "", err
+	}
+	return m.token, nil
+}
+
+// invalidate reloads the token from the token file after the supervisor
+// cluster rejected the given token. Reloads are rate limited, so that
+// concurrent requests failing with the same token trigger a single reload.
+func (m *supervisorTokenManager) invalidate(ctx context.Context, rejected string) {
+	log := logger.GetLogger(ctx)
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if rejected != m.token || m.now().Sub(m.loadedAt) < supervisorTokenMinReloadInterval {
+		return
+	}
+	log.Infof("Supervisor cluster rejected the token loaded at %v, reloading it from %q",
+		m.loadedAt, m.tokenFile)
+	if err := m.reloadLocked(ctx); err != nil {
+		log.Errorf("failed to reload supervisor token after authentication failure. Err: %v", err)
+	}
+}
+
+// reload reads the token from the token file.
+func (m *supervisorTokenManager) reload(ctx context.Context) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.reloadLocked(ctx)
+}
+
+func (m *supervisorTokenManager) reloadLocked(ctx context.Context) error {
+	log := logger.GetLogger(ctx)
+	data, err := os.ReadFile(m.tokenFile)
+	if err != nil {
+		prometheus.SupervisorTokenRefreshFailuresCounter.Inc()
+		return logger.LogNewErrorf(log, "failed to read supervisor token from %q. Err: %v", m.tokenFile, err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		prometheus.SupervisorTokenRefreshFailuresCounter.Inc()
+		return logger.LogNewErrorf(log, "supervisor token file %q is empty", m.tokenFile)
+	}
+	if token != m.token {
+		m.issuedAt, m.expiry = parseTokenTimes(token)
+		if m.token != "" {
+			log.Infof("Supervisor token refreshed from %q, expiry: %v", m.tokenFile, m.expiry)
+		}
+		m.token = token
+	}
+	m.loadedAt = m.now()
+	m.updateAgeMetric()
+	return nil
+}
+
+// isStale returns true if the cached token needs to be reloaded. Must be
+// called with the lock held.
+func (m *supervisorTokenManager) isStale() bool {
+	now := m.now()
+	if m.token == "" || now.Sub(m.loadedAt) >= supervisorTokenRecheckInterval {
+		return true
+	}
+	return !m.expiry.IsZero() && now.Add(supervisorTokenRefreshWindow).After(m.expiry)
+}
+
+// updateAgeMetric must be called with the lock held.
+func (m *supervisorTokenManager) updateAgeMetric() {
+	since := m.issuedAt
+	if since.IsZero() {
+		since = m.loadedAt
+	}
+	prometheus.SupervisorTokenAgeGauge.Set(m.now().Sub(since).Seconds())
+}
+
+// startRefresher starts, once, a goroutine proactively reloading the token
+// so that requests seldom have to wait on the token file being read.
+func (m *supervisorTokenManager) startRefresher() {
+	m.refresherOnce.Do(func() {
+		go func() {
+			ctx, log := logger.GetNewContextWithLogger()
+			ticker := time.NewTicker(supervisorTokenRecheckInterval / 2)
+			defer ticker.Stop()
+			for range ticker.C {
+				if _, err := m.getToken(ctx); err != nil {
+					log.Errorf("failed to refresh supervisor token. Err: %v", err)
+				}
+				m.lock.RLock()
+				m.updateAgeMetric()
+				m.lock.RUnlock()
+			}
+		}()
+	})
+}
+
+// wrapTransport returns a RoundTripper setting the cached token on the
+// requests sent to the supervisor cluster.
+func (m *supervisorTokenManager) wrapTransport(rt http.RoundTripper) http.RoundTripper {
+	return &supervisorTokenRoundTripper{manager: m, rt: rt}
+}
+
+type supervisorTokenRoundTripper struct {
+	manager *supervisorTokenManager
+	rt      http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *supervisorTokenRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Authorization") != "" {
+		return t.rt.RoundTrip(req)
+	}
+	ctx := logger.NewContextWithLogger(req.Context())
+	token, err := t.manager.getToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := t.rt.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		t.manager.invalidate(ctx, token)
+	}
+	return resp, err
+}
+
+// parseTokenTimes returns the "iat" and "exp" claims of the given JWT. Zero
+// times are returned for the claims which are missing or can't be parsed.
+func parseTokenTimes(token string) (time.Time, time.Time) {
+	var issuedAt, expiry time.Time
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return issuedAt, expiry
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return issuedAt, expiry
+	}
+	var claims struct {
+		IssuedAt int64 `json:"iat"`
+		Expiry   int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return issuedAt, expiry
+	}
+	if claims.IssuedAt > 0 {
+		issuedAt = time.Unix(claims.IssuedAt, 0)
+	}
+	if claims.Expiry > 0 {
+		expiry = time.Unix(claims.Expiry, 0)
+	}
+	return issuedAt, expiry
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestJWT(issuedAt, expiry time.Time) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256"}`))
+	payload := base64.RawURLEncoding.EncodeToString(
+		[]byte(fmt.Sprintf(`{"iat":%d,"exp":%d}`, issuedAt.Unix(), expiry.Unix())))
+	return header + "." + payload + ".signature"
+}
+
+func TestSupervisorTokenManagerRefresh(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	first := newTestJWT(now, now.Add(time.Hour))
+	assert.NoError(t, os.WriteFile(tokenFile, []byte(first+"\n"), 0600))
+
+	m := newSupervisorTokenManager(tokenFile)
+	m.now = func() time.Time { return now }
+	token, err := m.getToken(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, first, token)
+	assert.Equal(t, now.Add(time.Hour), m.expiry)
+
+	// The rotated token is not picked up while the cached one is fresh.
+	second := newTestJWT(now.Add(50*time.Minute), now.Add(2*time.Hour))
+	assert.NoError(t, os.WriteFile(tokenFile, []byte(second), 0600))
+	now = now.Add(30 * time.Second)
+	token, err = m.getToken(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, first, token)
+
+	// Close to its expiry, the token is reloaded.
+	now = now.Add(56 * time.Minute)
+	token, err = m.getToken(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, second, token)
+
+	// A cached token which is still valid is kept if the file can't be read.
+	assert.NoError(t, os.Remove(tokenFile))
+	now = now.Add(2 * time.Minute)
+	token, err = m.getToken(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, second, token)
+}
+
+func TestParseTokenTimes(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	issuedAt, expiry := parseTokenTimes(newTestJWT(now, now.Add(time.Hour)))
+	assert.Equal(t, now, issuedAt)
+	assert.Equal(t, now.Add(time.Hour), expiry)
+
+	issuedAt, expiry = parseTokenTimes("opaque-token")
+	assert.True(t, issuedAt.IsZero())
+	assert.True(t, expiry.IsZero())
+}
@@ -56,13 +56,13 @@ type InformerManager struct {
 	// node informer
 	nodeInformer cache.SharedInformer
 
-	// ConfigMap informer
-	configMapInformer cache.SharedInformer
-	// Function to determine if configMapInformer has been synced
+	// ConfigMap informers, by namespace
+	configMapInformers map[string]cache.SharedInformer
+	// Function to determine if configMapInformers have been synced
 	configMapSynced cache.InformerSynced
 
 	// PV informer
-	pvInformer cache.SharedInformer
+	pvInformer cache.SharedIndexInformer
 	// Function to determine if pvInformer has been synced
 	pvSynced cache.InformerSynced
 
@@ -82,5 +82,5 @@ type InformerManager struct {
 	podSynced cache.InformerSynced
 
 	// volume attachment informer
-	volumeAttachmentInformer cache.SharedInformer
+	volumeAttachmentInformer cache.SharedIndexInformer
 }
@@ -54,13 +54,16 @@ var (
 	cfg    *config
 	// COInitParams stores the input params required for initiating the
 	// CO agnostic orchestrator in the admission handler package.
-	COInitParams                              *interface{}
-	featureGateCsiMigrationEnabled            bool
-	featureGateBlockVolumeSnapshotEnabled     bool
-	featureGateTKGSHaEnabled                  bool
-	featureGateVolumeHealthEnabled            bool
-	featureGateTopologyAwareFileVolumeEnabled bool
-	featureGateStorageQuotaM2Enabled          bool
+	COInitParams                               *interface{}
+	featureGateCsiMigrationEnabled             bool
+	featureGateBlockVolumeSnapshotEnabled      bool
+	featureGateTKGSHaEnabled                   bool
+	featureGateVolumeHealthEnabled             bool
+	featureGateTopologyAwareFileVolumeEnabled  bool
+	featureGateStorageQuotaM2Enabled           bool
+	featureGateDetachProtectionEnabled         bool
+	featureGateSystemResourceProtectionEnabled bool
+	featureGateCSIDriverConfigEnabled          bool
 )
 
 // watchConfigChange watches on the webhook configuration directory for changes
@@ -144,6 +147,10 @@ func StartWebhookServer(ctx context.Context) error {
 		featureGateVolumeHealthEnabled = containerOrchestratorUtility.IsFSSEnabled(ctx, common.VolumeHealth)
 		featureGateBlockVolumeSnapshotEnabled = containerOrchestratorUtility.IsFSSEnabled(ctx, common.BlockVolumeSnapshot)
 		featureGateStorageQuotaM2Enabled = containerOrchestratorUtility.IsFSSEnabled(ctx, common.StorageQuotaM2)
+		featureGateDetachProtectionEnabled = containerOrchestratorUtility.IsFSSEnabled(ctx, common.DetachProtection)
+		featureGateSystemResourceProtectionEnabled = containerOrchestratorUtility.IsFSSEnabled(ctx,
+			common.SystemResourceProtection)
+		protectedSystemResources = getProtectedSystemResources(*COInitParams)
 		startCNSCSIWebhookManager(ctx)
 	} else if clusterFlavor == cnstypes.CnsClusterFlavorGuest {
 		featureGateBlockVolumeSnapshotEnabled = containerOrchestratorUtility.IsFSSEnabled(ctx, common.BlockVolumeSnapshot)
@@ -161,8 +168,15 @@ func StartWebhookServer(ctx context.Context) error {
 		featureGateBlockVolumeSnapshotEnabled = containerOrchestratorUtility.IsFSSEnabled(ctx, common.BlockVolumeSnapshot)
 		featureGateTopologyAwareFileVolumeEnabled = containerOrchestratorUtility.IsFSSEnabled(ctx,
 			common.TopologyAwareFileVolume)
+		featureGateDetachProtectionEnabled = containerOrchestratorUtility.IsFSSEnabled(ctx, common.DetachProtection)
+		featureGateSystemResourceProtectionEnabled = containerOrchestratorUtility.IsFSSEnabled(ctx,
+			common.SystemResourceProtection)
+		featureGateCSIDriverConfigEnabled = containerOrchestratorUtility.IsFSSEnabled(ctx, common.CSIDriverConfig)
+		protectedSystemResources = getProtectedSystemResources(*COInitParams)
 
-		if featureGateCsiMigrationEnabled || featureGateBlockVolumeSnapshotEnabled {
+		if featureGateCsiMigrationEnabled || featureGateBlockVolumeSnapshotEnabled ||
+			featureGateDetachProtectionEnabled || featureGateSystemResourceProtectionEnabled ||
+			featureGateCSIDriverConfigEnabled {
 			certs, err := tls.LoadX509KeyPair(cfg.WebHookConfig.CertFile, cfg.WebHookConfig.KeyFile)
 			if err != nil {
 				log.Errorf("failed to load key pair. certFile: %q, keyFile: %q err: %v",
@@ -276,6 +290,12 @@ func validationHandler(w http.ResponseWriter, r *http.Request) {
 				admissionResponse = validatePVC(ctx, ar.Request)
 			case "PersistentVolume":
 				admissionResponse = validatePv(ctx, ar.Request)
+			case "VolumeAttachment":
+				admissionResponse = validateVolumeAttachment(ctx, ar.Request)
+			case "ConfigMap":
+				admissionResponse = validateSystemResourceDeletion(ctx, ar.Request)
+			case csiDriverConfigKind:
+				admissionResponse = validateCSIDriverConfig(ctx, ar.Request)
 			default:
 				log.Infof("Skipping validation for resource type: %q", ar.Request.Kind.Kind)
 				admissionResponse = &admissionv1.AdmissionResponse{
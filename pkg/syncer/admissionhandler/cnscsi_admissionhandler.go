@@ -117,6 +117,15 @@ func (h *CSISupervisorWebhook) Handle(ctx context.Context, req admission.Request
 		if featureGateBlockVolumeSnapshotEnabled {
 			resp = validateSnapshotOperationSupervisorRequest(ctx, req)
 		}
+	} else if req.Kind.Kind == "CnsNodeVmAttachment" {
+		if featureGateDetachProtectionEnabled {
+			resp = validateCnsNodeVmAttachment(ctx, req)
+		}
+	} else if req.Kind.Kind == "ConfigMap" || req.Kind.Kind == featureStatesCRKind {
+		if featureGateSystemResourceProtectionEnabled {
+			admissionResp := validateSystemResourceDeletion(ctx, &req.AdmissionRequest)
+			resp.AdmissionResponse = *admissionResp.DeepCopy()
+		}
 	}
 	return
 }
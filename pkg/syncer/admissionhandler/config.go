@@ -35,6 +35,9 @@ const (
 type config struct {
 	// WebHookConfig contains the detail about webhook - certfile, keyfile, port etc.
 	WebHookConfig webHookConfig
+	// SystemResourceProtection contains the users allowed to delete the
+	// system resources of the driver.
+	SystemResourceProtection systemResourceProtectionConfig
 }
 
 // webHookConfig holds webhook configuration using which webhook http server will be created
@@ -47,6 +50,15 @@ type webHookConfig struct {
 	Port string `gcfg:"port"`
 }
 
+// systemResourceProtectionConfig holds the configuration of the protection of
+// the system resources from deletion
+type systemResourceProtectionConfig struct {
+	// AllowedServiceAccounts are the users, such as
+	// "system:serviceaccount:<namespace>:<name>", allowed to delete the
+	// system resources. The option can be repeated.
+	AllowedServiceAccounts []string `gcfg:"allowed-service-account"`
+}
+
 // getWebHookConfig returns webhook config
 func getWebHookConfig(ctx context.Context) (*config, error) {
 	log := logger.GetLogger(ctx)
@@ -0,0 +1,72 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admissionhandler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/csidriverconfig"
+	csidriverconfigv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/csidriverconfig/v1alpha1"
+)
+
+const (
+	// csiDriverConfigKind is the kind of the CSIDriverConfig CR.
+	csiDriverConfigKind = "CSIDriverConfig"
+
+	InvalidCSIDriverConfigError = "CSIDriverConfig %q is invalid: %v"
+)
+
+// validateCSIDriverConfig rejects the creation or update of the
+// CSIDriverConfig read by the driver with a spec the driver would reject,
+// after setting its defaults.
+func validateCSIDriverConfig(ctx context.Context,
+	req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	log := logger.GetLogger(ctx)
+	if !featureGateCSIDriverConfigEnabled ||
+		(req.Operation != admissionv1.Create && req.Operation != admissionv1.Update) {
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	}
+	instance := csidriverconfigv1alpha1.CSIDriverConfig{}
+	if err := json.Unmarshal(req.Object.Raw, &instance); err != nil {
+		log.Errorf("error deserializing CSIDriverConfig: %v", err)
+		return &admissionv1.AdmissionResponse{
+			Result: &metav1.Status{
+				Message: err.Error(),
+			},
+		}
+	}
+	if instance.Name != csidriverconfig.CSIDriverConfigName {
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	}
+	csidriverconfig.Default(&instance.Spec)
+	if err := csidriverconfig.Validate(&instance.Spec); err != nil {
+		log.Infof("Denying %s of CSIDriverConfig %q. Error: %v", req.Operation, instance.Name, err)
+		return &admissionv1.AdmissionResponse{
+			Allowed: false,
+			Result: &metav1.Status{
+				Message: fmt.Sprintf(InvalidCSIDriverConfigError, instance.Name, err),
+			},
+		}
+	}
+	return &admissionv1.AdmissionResponse{Allowed: true}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admissionhandler
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	v1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/csidriverconfig"
+	csidriverconfigv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/csidriverconfig/v1alpha1"
+)
+
+// TestValidateCSIDriverConfig is the unit test for the validation of the
+// topology categories of the CSIDriverConfig.
+func TestValidateCSIDriverConfig(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	featureGateCSIDriverConfigEnabled = true
+	defer func() {
+		featureGateCSIDriverConfigEnabled = false
+	}()
+
+	tests := []struct {
+		name       string
+		crName     string
+		categories []csidriverconfigv1alpha1.TopologyCategory
+		allowed    bool
+	}{
+		{"categories with default labels", csidriverconfig.CSIDriverConfigName,
+			[]csidriverconfigv1alpha1.TopologyCategory{{Name: "k8s-region"}, {Name: " k8s-zone "}}, true},
+		{"category with GA zone label", csidriverconfig.CSIDriverConfigName,
+			[]csidriverconfigv1alpha1.TopologyCategory{{Name: "k8s-zone", Label: "topology.kubernetes.io/zone"}},
+			true},
+		{"category with unrecognised label", csidriverconfig.CSIDriverConfigName,
+			[]csidriverconfigv1alpha1.TopologyCategory{{Name: "k8s-zone", Label: "example.com/zone"}}, false},
+		{"category without name", csidriverconfig.CSIDriverConfigName,
+			[]csidriverconfigv1alpha1.TopologyCategory{{Name: " "}}, false},
+		{"duplicated category", csidriverconfig.CSIDriverConfigName,
+			[]csidriverconfigv1alpha1.TopologyCategory{{Name: "k8s-zone"}, {Name: "k8s-zone"}}, false},
+		{"categories with the same label", csidriverconfig.CSIDriverConfigName,
+			[]csidriverconfigv1alpha1.TopologyCategory{
+				{Name: "k8s-zone", Label: "topology.kubernetes.io/zone"},
+				{Name: "k8s-rack", Label: "topology.kubernetes.io/zone"},
+			}, false},
+		{"too many categories", csidriverconfig.CSIDriverConfigName,
+			[]csidriverconfigv1alpha1.TopologyCategory{{Name: "c1"}, {Name: "c2"}, {Name: "c3"}, {Name: "c4"},
+				{Name: "c5"}, {Name: "c6"}}, false},
+		{"invalid categories of ignored CSIDriverConfig", "other",
+			[]csidriverconfigv1alpha1.TopologyCategory{{Name: "k8s-zone"}, {Name: "k8s-zone"}}, true},
+	}
+	for _, test := range tests {
+		instance := csidriverconfigv1alpha1.CSIDriverConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: test.crName},
+			Spec:       csidriverconfigv1alpha1.CSIDriverConfigSpec{TopologyCategories: test.categories},
+		}
+		raw, err := json.Marshal(instance)
+		if err != nil {
+			t.Fatalf("%s: failed to marshal CSIDriverConfig. Error: %v", test.name, err)
+		}
+		resp := validateCSIDriverConfig(ctx, &v1.AdmissionRequest{
+			Kind:      metav1.GroupVersionKind{Kind: csiDriverConfigKind},
+			Operation: v1.Update,
+			Name:      test.crName,
+			Object:    runtime.RawExtension{Raw: raw},
+		})
+		if resp.Allowed != test.allowed {
+			t.Errorf("%s: expected allowed %v, got %v. Response: %+v", test.name, test.allowed, resp.Allowed,
+				resp.Result)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admissionhandler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	cnsnodevmattachmentv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/cnsoperator/cnsnodevmattachment/v1alpha1"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
+)
+
+const (
+	// AnnForceDetach can be set to "true" on a VolumeAttachment or
+	// CnsNodeVmAttachment to allow deleting it while the volume is in use.
+	AnnForceDetach = "cns.vmware.com/force-detach"
+
+	DetachOfVolumeInUseError = "Volume %q is in use by running pod %s/%s. Deleting the %s detaches the volume " +
+		"from a running workload and can corrupt its data. Set the annotation %q to \"true\" to force the detach."
+)
+
+var (
+	// detachClient is the Kubernetes client shared by the detach validations.
+	// It is created on first use.
+	detachClient     kubernetes.Interface
+	detachClientLock sync.Mutex
+)
+
+// getDetachClient returns the Kubernetes client shared by the detach
+// validations, creating it if needed.
+func getDetachClient(ctx context.Context) (kubernetes.Interface, error) {
+	detachClientLock.Lock()
+	defer detachClientLock.Unlock()
+	if detachClient == nil {
+		kubeClient, err := k8s.NewClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		detachClient = kubeClient
+	}
+	return detachClient, nil
+}
+
+// isManualRequest returns true if the request is made by a user, as opposed
+// to a controller or a node. Kubernetes components and in-cluster service
+// accounts all authenticate with a "system:" prefixed user name, and they
+// detach volumes as part of the regular pod lifecycle.
+func isManualRequest(userInfo authenticationv1.UserInfo) bool {
+	return !strings.HasPrefix(userInfo.Username, "system:")
+}
+
+// validateVolumeAttachment rejects manual deletions of VolumeAttachments for
+// vSphere CSI volumes used by a running pod on the attached node.
+func validateVolumeAttachment(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	log := logger.GetLogger(ctx)
+	if !featureGateDetachProtectionEnabled || req.Operation != admissionv1.Delete || !isManualRequest(req.UserInfo) {
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	}
+	va := storagev1.VolumeAttachment{}
+	if err := json.Unmarshal(req.OldObject.Raw, &va); err != nil {
+		log.Errorf("error deserializing VolumeAttachment: %v. skipping validation.", err)
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	}
+	if va.Spec.Attacher != csitypes.DriverName() || va.Spec.Source.PersistentVolumeName == nil ||
+		va.Annotations[AnnForceDetach] == "true" {
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	}
+	pod, err := getRunningPodUsingPV(ctx, *va.Spec.Source.PersistentVolumeName, va.Spec.NodeName)
+	if err != nil {
+		// Never block a detach because the check itself failed.
+		log.Warnf("failed to find pods using VolumeAttachment %q, allowing the deletion. Err: %v", va.Name, err)
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	}
+	if pod == nil {
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	}
+	log.Infof("Denying deletion of VolumeAttachment %q by user %q as pod %s/%s is running",
+		va.Name, req.UserInfo.Username, pod.Namespace, pod.Name)
+	return &admissionv1.AdmissionResponse{
+		Allowed: false,
+		Result: &metav1.Status{
+			Message: fmt.Sprintf(DetachOfVolumeInUseError, *va.Spec.Source.PersistentVolumeName,
+				pod.Namespace, pod.Name, "VolumeAttachment", AnnForceDetach),
+		},
+	}
+}
+
+// getRunningPodUsingPV returns a running pod on the given node which uses the
+// claim bound to the PV, or nil if there is none.
+func getRunningPodUsingPV(ctx context.Context, pvName string, nodeName string) (*corev1.Pod, error) {
+	kubeClient, err := getDetachClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pv, err := kubeClient.CoreV1().PersistentVolumes().Get(ctx, pvName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if pv.Spec.ClaimRef == nil {
+		return nil, nil
+	}
+	return getRunningPodUsingPVC(ctx, kubeClient, pv.Spec.ClaimRef.Namespace, pv.Spec.ClaimRef.Name, nodeName)
+}
+
+// getRunningPodUsingPVC returns a running pod in the namespace which uses the
+// claim, or nil if there is none. If nodeName is set, only the pods on that
+// node are considered.
+func getRunningPodUsingPVC(ctx context.Context, kubeClient kubernetes.Interface, namespace string,
+	claimName string, nodeName string) (*corev1.Pod, error) {
+	listOptions := metav1.ListOptions{}
+	if nodeName != "" {
+		listOptions.FieldSelector = fields.OneTermEqualSelector("spec.nodeName", nodeName).String()
+	}
+	pods, err := kubeClient.CoreV1().Pods(namespace).List(ctx, listOptions)
+	if err != nil {
+		return nil, err
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		for _, volume := range pod.Spec.Volumes {
+			if volume.PersistentVolumeClaim != nil && volume.PersistentVolumeClaim.ClaimName == claimName {
+				return pod, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// validateCnsNodeVmAttachment rejects manual deletions of attached
+// CnsNodeVmAttachments whose volume is used by a running pod. Guest cluster
+// detaches go through pvCSI, which authenticates as a service account and is
+// therefore not affected.
+func validateCnsNodeVmAttachment(ctx context.Context, req admission.Request) admission.Response {
+	log := logger.GetLogger(ctx)
+	if req.Operation != admissionv1.Delete || !isManualRequest(req.UserInfo) {
+		return admission.Allowed("")
+	}
+	instance := cnsnodevmattachmentv1alpha1.CnsNodeVmAttachment{}
+	if err := json.Unmarshal(req.OldObject.Raw, &instance); err != nil {
+		log.Errorf("error deserializing CnsNodeVmAttachment: %v. skipping validation.", err)
+		return admission.Allowed("")
+	}
+	if !instance.Status.Attached || instance.Annotations[AnnForceDetach] == "true" {
+		return admission.Allowed("")
+	}
+	kubeClient, err := getDetachClient(ctx)
+	if err != nil {
+		log.Warnf("failed to create Kubernetes client, allowing the deletion of CnsNodeVmAttachment %s/%s. Err: %v",
+			instance.Namespace, instance.Name, err)
+		return admission.Allowed("")
+	}
+	pod, err := getRunningPodUsingPVC(ctx, kubeClient, instance.Namespace, instance.Spec.VolumeName, "")
+	if err != nil {
+		// Never block a detach because the check itself failed.
+		log.Warnf("failed to find pods using CnsNodeVmAttachment %s/%s, allowing the deletion. Err: %v",
+			instance.Namespace, instance.Name, err)
+		return admission.Allowed("")
+	}
+	if pod == nil {
+		return admission.Allowed("")
+	}
+	log.Infof("Denying deletion of CnsNodeVmAttachment %s/%s by user %q as pod %s/%s is running",
+		instance.Namespace, instance.Name, req.UserInfo.Username, pod.Namespace, pod.Name)
+	return admission.Denied(fmt.Sprintf(DetachOfVolumeInUseError, instance.Spec.VolumeName, pod.Namespace,
+		pod.Name, "CnsNodeVmAttachment", AnnForceDetach))
+}
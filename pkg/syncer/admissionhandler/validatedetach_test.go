@@ -0,0 +1,180 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admissionhandler
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func newCnsNodeVmAttachmentDeleteRequest(username string, raw string) admission.Request {
+	return admission.Request{
+		AdmissionRequest: v1.AdmissionRequest{
+			Kind:      metav1.GroupVersionKind{Kind: "CnsNodeVmAttachment"},
+			Operation: v1.Delete,
+			UserInfo:  authenticationv1.UserInfo{Username: username},
+			OldObject: runtime.RawExtension{Raw: []byte(raw)},
+		},
+	}
+}
+
+func newVolumeAttachmentDeleteRequest(username string, raw string) *v1.AdmissionRequest {
+	return &v1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Group: "storage.k8s.io", Version: "v1", Kind: "VolumeAttachment"},
+		Operation: v1.Delete,
+		UserInfo:  authenticationv1.UserInfo{Username: username},
+		OldObject: runtime.RawExtension{Raw: []byte(raw)},
+	}
+}
+
+func newPVBoundToPVC(name string, claimName string) *corev1.PersistentVolume {
+	return &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: corev1.PersistentVolumeSpec{
+			ClaimRef: &corev1.ObjectReference{Namespace: "ns1", Name: claimName},
+		},
+	}
+}
+
+func newPodUsingPVC(name string, claimName string, phase corev1.PodPhase) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns1"},
+		Spec: corev1.PodSpec{
+			Volumes: []corev1.Volume{{
+				Name: "data",
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claimName},
+				},
+			}},
+		},
+		Status: corev1.PodStatus{Phase: phase},
+	}
+}
+
+// TestValidateCnsNodeVmAttachmentDeletion is the unit test for detach
+// protection on CnsNodeVmAttachment deletions.
+func TestValidateCnsNodeVmAttachmentDeletion(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// pvc-1 is used by a running pod, pvc-2 only by a completed one.
+	detachClient = fake.NewSimpleClientset(
+		newPodUsingPVC("pod-1", "pvc-1", corev1.PodRunning),
+		newPodUsingPVC("pod-2", "pvc-2", corev1.PodSucceeded),
+	)
+	defer func() { detachClient = nil }()
+	attached := `{"metadata": {"name": "vm-1-pvc-1", "namespace": "ns1"},
+		"spec": {"nodeuuid": "4237e0b3-4ade-4bd1-bd2d-f9e0c0e8d1c2", "volumename": "pvc-1"},
+		"status": {"attached": true}}`
+	forced := `{"metadata": {"name": "vm-1-pvc-1", "namespace": "ns1",
+		"annotations": {"cns.vmware.com/force-detach": "true"}},
+		"spec": {"nodeuuid": "4237e0b3-4ade-4bd1-bd2d-f9e0c0e8d1c2", "volumename": "pvc-1"},
+		"status": {"attached": true}}`
+	detached := `{"metadata": {"name": "vm-1-pvc-1", "namespace": "ns1"},
+		"spec": {"nodeuuid": "4237e0b3-4ade-4bd1-bd2d-f9e0c0e8d1c2", "volumename": "pvc-1"},
+		"status": {"attached": false}}`
+
+	notInUse := `{"metadata": {"name": "vm-1-pvc-2", "namespace": "ns1"},
+		"spec": {"nodeuuid": "4237e0b3-4ade-4bd1-bd2d-f9e0c0e8d1c2", "volumename": "pvc-2"},
+		"status": {"attached": true}}`
+
+	tests := []struct {
+		name     string
+		username string
+		raw      string
+		allowed  bool
+	}{
+		{"manual delete of attached instance", "admin@vsphere.local", attached, false},
+		{"manual delete of attached instance not used by a running pod", "admin@vsphere.local", notInUse, true},
+		{"forced manual delete of attached instance", "admin@vsphere.local", forced, true},
+		{"manual delete of detached instance", "admin@vsphere.local", detached, true},
+		{"service account delete of attached instance",
+			"system:serviceaccount:ns1:tkc-1-pvcsi", attached, true},
+	}
+	for _, test := range tests {
+		resp := validateCnsNodeVmAttachment(ctx, newCnsNodeVmAttachmentDeleteRequest(test.username, test.raw))
+		if resp.Allowed != test.allowed {
+			t.Errorf("%s: expected allowed to be %v, got %v. Response: %+v", test.name, test.allowed,
+				resp.Allowed, resp)
+		}
+	}
+}
+
+// TestValidateVolumeAttachmentDeletion is the unit test for detach protection
+// on VolumeAttachment deletions.
+func TestValidateVolumeAttachmentDeletion(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// pv-1 is bound to pvc-1 which is used by a running pod, pv-2 to pvc-2
+	// which is only used by a completed one.
+	detachClient = fake.NewSimpleClientset(
+		newPVBoundToPVC("pv-1", "pvc-1"),
+		newPVBoundToPVC("pv-2", "pvc-2"),
+		newPodUsingPVC("pod-1", "pvc-1", corev1.PodRunning),
+		newPodUsingPVC("pod-2", "pvc-2", corev1.PodSucceeded),
+	)
+	defer func() { detachClient = nil }()
+	defer func(enabled bool) { featureGateDetachProtectionEnabled = enabled }(featureGateDetachProtectionEnabled)
+	inUse := `{"metadata": {"name": "csi-1"},
+		"spec": {"attacher": "csi.vsphere.vmware.com", "nodeName": "node-1",
+		"source": {"persistentVolumeName": "pv-1"}}}`
+	forced := `{"metadata": {"name": "csi-1", "annotations": {"cns.vmware.com/force-detach": "true"}},
+		"spec": {"attacher": "csi.vsphere.vmware.com", "nodeName": "node-1",
+		"source": {"persistentVolumeName": "pv-1"}}}`
+	notInUse := `{"metadata": {"name": "csi-2"},
+		"spec": {"attacher": "csi.vsphere.vmware.com", "nodeName": "node-1",
+		"source": {"persistentVolumeName": "pv-2"}}}`
+	otherDriver := `{"metadata": {"name": "csi-3"},
+		"spec": {"attacher": "other.csi.driver", "nodeName": "node-1",
+		"source": {"persistentVolumeName": "pv-1"}}}`
+
+	tests := []struct {
+		name     string
+		enabled  bool
+		username string
+		raw      string
+		allowed  bool
+	}{
+		{"manual delete of volume in use", true, "kubernetes-admin", inUse, false},
+		{"manual delete of volume not used by a running pod", true, "kubernetes-admin", notInUse, true},
+		{"forced manual delete of volume in use", true, "kubernetes-admin", forced, true},
+		{"manual delete of volume of another driver", true, "kubernetes-admin", otherDriver, true},
+		{"attach detach controller delete of volume in use", true,
+			"system:serviceaccount:kube-system:attachdetach-controller", inUse, true},
+		{"manual delete of volume in use with detach protection disabled", false, "kubernetes-admin",
+			inUse, true},
+	}
+	for _, test := range tests {
+		featureGateDetachProtectionEnabled = test.enabled
+		resp := validateVolumeAttachment(ctx, newVolumeAttachmentDeleteRequest(test.username, test.raw))
+		if resp.Allowed != test.allowed {
+			t.Errorf("%s: expected allowed to be %v, got %v. Response: %+v", test.name, test.allowed,
+				resp.Allowed, resp)
+		}
+		if !resp.Allowed && (resp.Result == nil || !strings.Contains(resp.Result.Message, "ns1/pod-1")) {
+			t.Errorf("%s: expected the denial to name pod ns1/pod-1. Response: %+v", test.name, resp)
+		}
+	}
+}
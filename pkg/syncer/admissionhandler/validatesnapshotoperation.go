@@ -11,6 +11,7 @@ import (
 
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
 	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
 
 	snap "github.com/kubernetes-csi/external-snapshotter/client/v6/apis/volumesnapshot/v1"
@@ -65,7 +66,7 @@ func validateSnapshotOperationGuestRequest(ctx context.Context, req *admissionv1
 			return admission.Denied(reason)
 		}
 		log.Debugf("Validating VolumeSnapshotClass: %q", vsclass.Name)
-		if vsclass.Driver == "csi.vsphere.vmware.com" && !featureGateBlockVolumeSnapshotEnabled {
+		if vsclass.Driver == csitypes.DriverName() && !featureGateBlockVolumeSnapshotEnabled {
 			// Disallow any operation on VolumeSnapshotClass object if block-volume-snapshot feature is not enabled
 			return admission.Denied(SnapshotFeatureNotEnabled)
 		}
@@ -78,7 +79,7 @@ func validateSnapshotOperationGuestRequest(ctx context.Context, req *admissionv1
 			return admission.Denied(reason)
 		}
 		log.Debugf("Validating VolumeSnapshotContent: %q", vsc.Name)
-		if vsc.Spec.Driver == "csi.vsphere.vmware.com" && !featureGateBlockVolumeSnapshotEnabled {
+		if vsc.Spec.Driver == csitypes.DriverName() && !featureGateBlockVolumeSnapshotEnabled {
 			// Disallow any operation on VolumeSnapshotContent object if block-volume-snapshot feature is not enabled
 			return admission.Denied(SnapshotFeatureNotEnabled)
 		}
@@ -110,7 +111,7 @@ func validateSnapshotOperationGuestRequest(ctx context.Context, req *admissionv1
 				log.Warn(reason)
 				return admission.Denied(reason)
 			}
-			if vsclass.Driver == "csi.vsphere.vmware.com" && !featureGateBlockVolumeSnapshotEnabled {
+			if vsclass.Driver == csitypes.DriverName() && !featureGateBlockVolumeSnapshotEnabled {
 				return admission.Denied(SnapshotFeatureNotEnabled)
 			}
 		}
@@ -26,6 +26,7 @@ import (
 
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
 )
 
 var (
@@ -74,7 +75,7 @@ func validateStorageClass(ctx context.Context, ar *admissionv1.AdmissionReview)
 			}
 		}
 		log.Infof("Validating StorageClass: %q", sc.Name)
-		if sc.Provisioner == "csi.vsphere.vmware.com" {
+		if sc.Provisioner == csitypes.DriverName() {
 			// Migration parameters check for csi.vsphere.vmware.com provisioner.
 			for param := range sc.Parameters {
 				if unSupportedParameters.Has(param) {
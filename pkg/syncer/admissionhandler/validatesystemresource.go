@@ -0,0 +1,120 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admissionhandler
+
+import (
+	"context"
+	"fmt"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco/k8sorchestrator"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/featurestates"
+)
+
+const (
+	DeleteSystemResourceError = "%s %q is a system resource of the vSphere CSI driver and can't be deleted " +
+		"by user %q. Deleting it stops the driver."
+
+	// featureStatesCRKind is the kind of the supervisor feature states CR.
+	featureStatesCRKind = "CnsCsiSvFeatureStates"
+)
+
+// defaultAllowedSystemResourceDeleters are the users allowed to delete the
+// protected resources in addition to the configured service accounts. The
+// namespace and garbage collector controllers delete them along with their
+// namespace or owner.
+var defaultAllowedSystemResourceDeleters = []string{
+	"system:serviceaccount:kube-system:namespace-controller",
+	"system:serviceaccount:kube-system:generic-garbage-collector",
+}
+
+// systemResource identifies a resource the driver depends on. An empty
+// namespace matches the resource in any namespace.
+type systemResource struct {
+	kind      string
+	namespace string
+	name      string
+}
+
+// protectedSystemResources holds the resources protected from deletion. It
+// is set when the webhook server starts.
+var protectedSystemResources []systemResource
+
+// getProtectedSystemResources returns the feature state ConfigMaps and CRs
+// the driver reads, based on the orchestrator init params.
+func getProtectedSystemResources(params interface{}) []systemResource {
+	var resources []systemResource
+	switch p := params.(type) {
+	case k8sorchestrator.K8sVanillaInitParams:
+		resources = append(resources, systemResource{kind: "ConfigMap",
+			namespace: p.InternalFeatureStatesConfigInfo.Namespace, name: p.InternalFeatureStatesConfigInfo.Name})
+	case k8sorchestrator.K8sSupervisorInitParams:
+		resources = append(resources, systemResource{kind: "ConfigMap",
+			namespace: p.SupervisorFeatureStatesConfigInfo.Namespace, name: p.SupervisorFeatureStatesConfigInfo.Name},
+			systemResource{kind: featureStatesCRKind, name: featurestates.SVFeatureStateCRName})
+	}
+	return resources
+}
+
+// isSystemResource returns true if the request targets a protected resource.
+func isSystemResource(req *admissionv1.AdmissionRequest) bool {
+	for _, resource := range protectedSystemResources {
+		if resource.kind == req.Kind.Kind && resource.name == req.Name &&
+			(resource.namespace == "" || resource.namespace == req.Namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+// isAllowedSystemResourceDeleter returns true if the user may delete the
+// protected resources.
+func isAllowedSystemResourceDeleter(username string) bool {
+	allowed := defaultAllowedSystemResourceDeleters
+	if cfg != nil {
+		allowed = append(allowed, cfg.SystemResourceProtection.AllowedServiceAccounts...)
+	}
+	for _, user := range allowed {
+		if user == username {
+			return true
+		}
+	}
+	return false
+}
+
+// validateSystemResourceDeletion rejects the deletion of the feature state
+// ConfigMaps and CRs used by the driver, unless it is made by one of the
+// allowed service accounts.
+func validateSystemResourceDeletion(ctx context.Context,
+	req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	log := logger.GetLogger(ctx)
+	if !featureGateSystemResourceProtectionEnabled || req.Operation != admissionv1.Delete ||
+		!isSystemResource(req) || isAllowedSystemResourceDeleter(req.UserInfo.Username) {
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	}
+	log.Infof("Denying deletion of %s %s/%s by user %q", req.Kind.Kind, req.Namespace, req.Name,
+		req.UserInfo.Username)
+	return &admissionv1.AdmissionResponse{
+		Allowed: false,
+		Result: &metav1.Status{
+			Message: fmt.Sprintf(DeleteSystemResourceError, req.Kind.Kind, req.Name, req.UserInfo.Username),
+		},
+	}
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admissionhandler
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	cnsconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco/k8sorchestrator"
+)
+
+// TestValidateSystemResourceDeletion is the unit test for the protection of
+// the feature states ConfigMap from deletion.
+func TestValidateSystemResourceDeletion(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	featureGateSystemResourceProtectionEnabled = true
+	defer func() {
+		featureGateSystemResourceProtectionEnabled = false
+		protectedSystemResources = nil
+		cfg = nil
+	}()
+	protectedSystemResources = getProtectedSystemResources(k8sorchestrator.K8sVanillaInitParams{
+		InternalFeatureStatesConfigInfo: cnsconfig.FeatureStatesConfigInfo{
+			Name:      "internal-feature-states.csi.vsphere.vmware.com",
+			Namespace: "vmware-system-csi",
+		},
+	})
+	cfg = &config{SystemResourceProtection: systemResourceProtectionConfig{
+		AllowedServiceAccounts: []string{"system:serviceaccount:vmware-system-csi:csi-operator"},
+	}}
+
+	tests := []struct {
+		name      string
+		operation v1.Operation
+		namespace string
+		cmName    string
+		username  string
+		allowed   bool
+	}{
+		{"user delete of FSS ConfigMap", v1.Delete, "vmware-system-csi",
+			"internal-feature-states.csi.vsphere.vmware.com", "kubernetes-admin", false},
+		{"namespace controller delete of FSS ConfigMap", v1.Delete, "vmware-system-csi",
+			"internal-feature-states.csi.vsphere.vmware.com",
+			"system:serviceaccount:kube-system:namespace-controller", true},
+		{"allowed service account delete of FSS ConfigMap", v1.Delete, "vmware-system-csi",
+			"internal-feature-states.csi.vsphere.vmware.com",
+			"system:serviceaccount:vmware-system-csi:csi-operator", true},
+		{"user update of FSS ConfigMap", v1.Update, "vmware-system-csi",
+			"internal-feature-states.csi.vsphere.vmware.com", "kubernetes-admin", true},
+		{"user delete of other ConfigMap", v1.Delete, "vmware-system-csi", "other", "kubernetes-admin", true},
+		{"user delete of ConfigMap in other namespace", v1.Delete, "default",
+			"internal-feature-states.csi.vsphere.vmware.com", "kubernetes-admin", true},
+	}
+	for _, test := range tests {
+		resp := validateSystemResourceDeletion(ctx, &v1.AdmissionRequest{
+			Kind:      metav1.GroupVersionKind{Kind: "ConfigMap"},
+			Operation: test.operation,
+			Namespace: test.namespace,
+			Name:      test.cmName,
+			UserInfo:  authenticationv1.UserInfo{Username: test.username},
+		})
+		if resp.Allowed != test.allowed {
+			t.Errorf("%s: expected allowed to be %v, got %v. Response: %+v", test.name, test.allowed,
+				resp.Allowed, resp)
+		}
+	}
+}
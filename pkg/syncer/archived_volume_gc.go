@@ -0,0 +1,110 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+
+	volumes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+// getArchivedVolumeGCIntervalInMin returns the interval at which archived
+// volumes are checked for expiry. If environment variable
+// ARCHIVED_VOLUME_GC_INTERVAL_MINUTES is set and valid, return the interval
+// value read from environment variable. Otherwise, use the default value 60
+// minutes.
+func getArchivedVolumeGCIntervalInMin(ctx context.Context) int {
+	log := logger.GetLogger(ctx)
+	archivedVolumeGCIntervalInMin := defaultArchivedVolumeGCIntervalInMin
+	if v := os.Getenv("ARCHIVED_VOLUME_GC_INTERVAL_MINUTES"); v != "" {
+		if value, err := strconv.Atoi(v); err == nil && value > 0 {
+			archivedVolumeGCIntervalInMin = value
+			log.Infof("ArchivedVolumeGC: interval is set to %d minutes", archivedVolumeGCIntervalInMin)
+		} else {
+			log.Warnf("ArchivedVolumeGC: interval set in env variable ARCHIVED_VOLUME_GC_INTERVAL_MINUTES %s "+
+				"is invalid, will use the default interval", v)
+		}
+	}
+	return archivedVolumeGCIntervalInMin
+}
+
+// csiPurgeArchivedVolumes deletes the backing disks of archived volumes
+// whose retention period has expired.
+func csiPurgeArchivedVolumes(ctx context.Context, metadataSyncer *metadataSyncInformer) {
+	log := logger.GetLogger(ctx)
+	log.Debugf("csiPurgeArchivedVolumes: start")
+	retention := time.Duration(metadataSyncer.configInfo.Cfg.Archive.RetentionInHours) * time.Hour
+	if isMultiVCenterFssEnabled && len(metadataSyncer.volumeManagers) > 0 {
+		for vcHost, volumeManager := range metadataSyncer.volumeManagers {
+			purgeArchivedVolumes(ctx, vcHost, volumeManager, retention)
+		}
+	} else {
+		purgeArchivedVolumes(ctx, metadataSyncer.host, metadataSyncer.volumeManager, retention)
+	}
+	log.Debugf("csiPurgeArchivedVolumes: end")
+}
+
+// purgeArchivedVolumes deletes the expired archived disks on a single vCenter.
+func purgeArchivedVolumes(ctx context.Context, vcHost string, volumeManager volumes.Manager,
+	retention time.Duration) {
+	log := logger.GetLogger(ctx)
+	volumeIDs, err := volumeManager.ListVStorageObjectsWithMetadataKey(ctx, common.VStorageObjectMetadataArchivedAt)
+	if err != nil {
+		log.Errorf("purgeArchivedVolumes: failed to list archived volumes on vCenter %q. Err: %v", vcHost, err)
+		return
+	}
+	for _, volumeID := range volumeIDs {
+		archivedAt, err := volumeManager.RetrieveVStorageObjectMetadataValue(ctx, volumeID,
+			common.VStorageObjectMetadataArchivedAt)
+		if err != nil {
+			log.Warnf("purgeArchivedVolumes: failed to get archival time of volume %q. Err: %v", volumeID, err)
+			continue
+		}
+		archivedTime, err := time.Parse(time.RFC3339, archivedAt)
+		if err != nil {
+			log.Warnf("purgeArchivedVolumes: invalid archival time %q on volume %q. Err: %v",
+				archivedAt, volumeID, err)
+			continue
+		}
+		if time.Since(archivedTime) < retention {
+			continue
+		}
+		// Restoring a volume replaces its archival time with the instance
+		// restoring it, a volume listed before being restored is in use.
+		restoredBy, err := volumeManager.RetrieveVStorageObjectMetadataValue(ctx, volumeID,
+			common.VStorageObjectMetadataRestoredBy)
+		if err != nil {
+			log.Warnf("purgeArchivedVolumes: failed to get restorer of volume %q. Err: %v", volumeID, err)
+			continue
+		}
+		if restoredBy != "" {
+			log.Infof("purgeArchivedVolumes: skipping volume %q restored by %s", volumeID, restoredBy)
+			continue
+		}
+		if err := volumeManager.DeleteVStorageObject(ctx, volumeID); err != nil {
+			log.Errorf("purgeArchivedVolumes: failed to delete archived volume %q on vCenter %q. Err: %v",
+				volumeID, vcHost, err)
+			continue
+		}
+		log.Infof("purgeArchivedVolumes: deleted volume %q archived at %s", volumeID, archivedAt)
+	}
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/unittestcommon"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+)
+
+func TestPurgeArchivedVolumes(t *testing.T) {
+	ctx := context.Background()
+	retention := 24 * time.Hour
+	expired := time.Now().Add(-2 * retention).UTC().Format(time.RFC3339)
+	recent := time.Now().UTC().Format(time.RFC3339)
+	volumeManager := unittestcommon.NewFakeVolumeManager(map[string]map[string]string{
+		"volume-expired": {common.VStorageObjectMetadataArchivedAt: expired},
+		"volume-recent":  {common.VStorageObjectMetadataArchivedAt: recent},
+		"volume-invalid": {common.VStorageObjectMetadataArchivedAt: "yesterday"},
+		"volume-restored": {
+			common.VStorageObjectMetadataArchivedAt: expired,
+			common.VStorageObjectMetadataRestoredBy: "default/restore-1",
+		},
+		"volume-not-archived": {common.VStorageObjectMetadataRestoredBy: "default/restore-2"},
+	})
+
+	purgeArchivedVolumes(ctx, "vc-1", volumeManager, retention)
+	// Only the expired volume is deleted. The volume with an invalid archival
+	// time and the volume being restored are kept.
+	assert.Equal(t, []string{"volume-expired"}, volumeManager.DeletedVolumes)
+	for _, volumeID := range []string{"volume-recent", "volume-invalid", "volume-restored", "volume-not-archived"} {
+		assert.Contains(t, volumeManager.Metadata, volumeID)
+	}
+
+	// The recent volume is deleted once its retention period is over.
+	purgeArchivedVolumes(ctx, "vc-1", volumeManager, 0)
+	assert.Equal(t, []string{"volume-expired", "volume-recent"}, volumeManager.DeletedVolumes)
+}
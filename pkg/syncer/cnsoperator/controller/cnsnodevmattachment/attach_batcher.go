@@ -0,0 +1,178 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cnsnodevmattachment
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	volumes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	csifault "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/fault"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+const (
+	// defaultAttachBatchWindow is how long the first attach request for a
+	// PodVM waits for other requests to the same PodVM before the batch is
+	// sent to CNS.
+	defaultAttachBatchWindow = 500 * time.Millisecond
+)
+
+// attachRequest is a volume waiting to be attached as part of a batch.
+type attachRequest struct {
+	volumeID string
+	result   chan *volumes.BatchAttachResult
+}
+
+// attachBatch holds the pending attach requests for one PodVM.
+type attachBatch struct {
+	vm       *cnsvsphere.VirtualMachine
+	requests []*attachRequest
+}
+
+// podVMAttachBatcher groups the attach requests for the same PodVM received
+// within the batch window, so that multi-volume pods get all their volumes
+// attached with a single VM reconfigure.
+type podVMAttachBatcher struct {
+	volumeManager volumes.Manager
+	batchWindow   time.Duration
+	lock          sync.Mutex
+	// pending maps the VM UUID to the batch being collected for it.
+	pending map[string]*attachBatch
+}
+
+func newPodVMAttachBatcher(volumeManager volumes.Manager) *podVMAttachBatcher {
+	return &podVMAttachBatcher{
+		volumeManager: volumeManager,
+		batchWindow:   defaultAttachBatchWindow,
+		pending:       make(map[string]*attachBatch),
+	}
+}
+
+// attach adds the volume to the batch of the given PodVM and waits for the
+// batch to be attached. It returns the disk UUID, fault type and error for
+// the volume, like volumes.Manager.AttachVolume does.
+func (b *podVMAttachBatcher) attach(ctx context.Context, nodeUUID string, vm *cnsvsphere.VirtualMachine,
+	volumeID string) (string, string, error) {
+	log := logger.GetLogger(ctx)
+	req := &attachRequest{volumeID: volumeID, result: make(chan *volumes.BatchAttachResult, 1)}
+	b.lock.Lock()
+	batch, exists := b.pending[nodeUUID]
+	if !exists {
+		batch = &attachBatch{vm: vm}
+		b.pending[nodeUUID] = batch
+		time.AfterFunc(b.batchWindow, func() { b.flush(nodeUUID) })
+	}
+	batch.requests = append(batch.requests, req)
+	b.lock.Unlock()
+	log.Debugf("Queued volume %q for batched attach to PodVM %q", volumeID, nodeUUID)
+
+	select {
+	case res := <-req.result:
+		return res.DiskUUID, res.FaultType, res.Err
+	case <-ctx.Done():
+		return "", csifault.CSIInternalFault, ctx.Err()
+	}
+}
+
+// flushAll attaches the volumes of all the pending batches right away,
+// instead of at the end of their batch window, and waits for the attaches to
+// complete.
+func (b *podVMAttachBatcher) flushAll(ctx context.Context) {
+	log := logger.GetLogger(ctx)
+	b.lock.Lock()
+	nodeUUIDs := make([]string, 0, len(b.pending))
+	for nodeUUID := range b.pending {
+		nodeUUIDs = append(nodeUUIDs, nodeUUID)
+	}
+	b.lock.Unlock()
+	log.Infof("Attaching the volumes of %d pending PodVM batches", len(nodeUUIDs))
+	var wg sync.WaitGroup
+	for _, nodeUUID := range nodeUUIDs {
+		wg.Add(1)
+		go func(nodeUUID string) {
+			defer wg.Done()
+			b.flush(nodeUUID)
+		}(nodeUUID)
+	}
+	wg.Wait()
+}
+
+// flush attaches all the volumes collected for the PodVM and hands each
+// requester its result.
+func (b *podVMAttachBatcher) flush(nodeUUID string) {
+	ctx, log := logger.GetNewContextWithLogger()
+	b.lock.Lock()
+	batch := b.pending[nodeUUID]
+	delete(b.pending, nodeUUID)
+	b.lock.Unlock()
+	if batch == nil || len(batch.requests) == 0 {
+		return
+	}
+	var volumeIDs []string
+	seen := make(map[string]struct{})
+	for _, req := range batch.requests {
+		if _, ok := seen[req.volumeID]; !ok {
+			seen[req.volumeID] = struct{}{}
+			volumeIDs = append(volumeIDs, req.volumeID)
+		}
+	}
+	deliver := func(resultFor func(volumeID string) *volumes.BatchAttachResult) {
+		for _, req := range batch.requests {
+			req.result <- resultFor(req.volumeID)
+		}
+	}
+
+	if err := validateFreeDiskSlots(ctx, batch.vm, len(volumeIDs)); err != nil {
+		log.Errorf("Pre-attach validation failed for PodVM %q. Err: %v", nodeUUID, err)
+		deliver(func(string) *volumes.BatchAttachResult {
+			return &volumes.BatchAttachResult{FaultType: csifault.CSIInternalFault, Err: err}
+		})
+		return
+	}
+	if len(volumeIDs) == 1 {
+		diskUUID, faultType, err := b.volumeManager.AttachVolume(ctx, batch.vm, volumeIDs[0], false)
+		deliver(func(string) *volumes.BatchAttachResult {
+			return &volumes.BatchAttachResult{DiskUUID: diskUUID, FaultType: faultType, Err: err}
+		})
+		return
+	}
+	log.Infof("Attaching volumes %v to PodVM %q in a single batch", volumeIDs, nodeUUID)
+	results, faultType, err := b.volumeManager.BatchAttachVolumes(ctx, batch.vm, volumeIDs)
+	deliver(func(volumeID string) *volumes.BatchAttachResult {
+		if err != nil {
+			return &volumes.BatchAttachResult{FaultType: faultType, Err: err}
+		}
+		return results[volumeID]
+	})
+}
+
+// validateFreeDiskSlots checks that the VM can take count more disks on its
+// SCSI controllers, including the PCI slots of the controllers to be added.
+func validateFreeDiskSlots(ctx context.Context, vm *cnsvsphere.VirtualMachine, count int) error {
+	devices, err := vm.Device(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get devices of VM %q. Err: %v", vm.String(), err)
+	}
+	if err := cnsvsphere.CheckFreeSCSIDiskSlots(devices, count); err != nil {
+		return fmt.Errorf("cannot attach %d volumes to VM %q. Err: %w", count, vm.String(), err)
+	}
+	return nil
+}
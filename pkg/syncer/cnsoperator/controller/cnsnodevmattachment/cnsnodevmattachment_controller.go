@@ -54,6 +54,9 @@ import (
 	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/utils"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
 	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
 	cnsoperatortypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/syncer/cnsoperator/types"
@@ -123,10 +126,12 @@ func newReconciler(mgr manager.Manager, configInfo *config.ConfigurationInfo,
 	volumeManager volumes.Manager, vmOperatorClient client.Client,
 	recorder record.EventRecorder) reconcile.Reconciler {
 	ctx, _ := logger.GetNewContextWithLogger()
+	attachBatcher := newPodVMAttachBatcher(volumeManager)
+	utils.RegisterCacheFlusher("batched PodVM attaches", attachBatcher.flushAll)
 	return &ReconcileCnsNodeVMAttachment{client: mgr.GetClient(), scheme: mgr.GetScheme(),
 		configInfo: configInfo, volumeManager: volumeManager,
 		vmOperatorClient: vmOperatorClient, nodeManager: cnsnode.GetManager(ctx),
-		recorder: recorder}
+		recorder: recorder, attachBatcher: attachBatcher}
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler.
@@ -168,6 +173,7 @@ type ReconcileCnsNodeVMAttachment struct {
 	vmOperatorClient client.Client
 	nodeManager      cnsnode.Manager
 	recorder         record.EventRecorder
+	attachBatcher    *podVMAttachBatcher
 }
 
 // Reconcile reads that state of the cluster for a CnsNodeVMAttachment object
@@ -375,7 +381,16 @@ func (r *ReconcileCnsNodeVMAttachment) Reconcile(ctx context.Context,
 			log.Infof("vSphere CSI driver is attaching volume: %q to nodevm: %+v for "+
 				"CnsNodeVmAttachment request with name: %q on namespace: %q",
 				volumeID, nodeVM, request.Name, request.Namespace)
-			diskUUID, faulttype, attachErr := r.volumeManager.AttachVolume(ctx, nodeVM, volumeID, false)
+			var (
+				diskUUID  string
+				faulttype string
+				attachErr error
+			)
+			if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.PodVMAttachBatching) {
+				diskUUID, faulttype, attachErr = r.attachBatcher.attach(ctx, nodeUUID, nodeVM, volumeID)
+			} else {
+				diskUUID, faulttype, attachErr = r.volumeManager.AttachVolume(ctx, nodeVM, volumeID, false)
+			}
 
 			if attachErr != nil {
 				log.Errorf("failed to attach disk: %q to nodevm: %+v for CnsNodeVmAttachment "+
@@ -0,0 +1,371 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cnssnapshotdelta
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	snapshotterClientSet "github.com/kubernetes-csi/external-snapshotter/client/v6/clientset/versioned"
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	apis "sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/cnsoperator"
+	volumes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	deltav1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsoperator/cnssnapshotdelta/v1alpha1"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/syncer"
+)
+
+const (
+	defaultMaxWorkerThreadsForSnapshotDelta = 4
+	// maxChangedAreas is the maximum number of changed areas returned in the
+	// status of a CnsSnapshotDelta, for the instance to stay within the size
+	// limit of the objects stored in etcd.
+	maxChangedAreas = 20000
+)
+
+// backOffDuration is a map of cnssnapshotdelta name's to the time after which
+// a request for this instance will be requeued. Initialized to 1 second for
+// new instances and for instances whose latest reconcile operation succeeded.
+// If the reconcile fails, backoff is incremented exponentially.
+var (
+	backOffDuration         map[string]time.Duration
+	backOffDurationMapMutex = sync.Mutex{}
+)
+
+// Add creates a new CnsSnapshotDelta Controller and adds it to the Manager,
+// ConfigurationInfo and VirtualCenterTypes. The Manager will set fields on the
+// Controller and start it when the Manager is Started.
+func Add(mgr manager.Manager, clusterFlavor cnstypes.CnsClusterFlavor,
+	configInfo *config.ConfigurationInfo, volumeManager volumes.Manager) error {
+	ctx, log := logger.GetNewContextWithLogger()
+	if clusterFlavor != cnstypes.CnsClusterFlavorVanilla {
+		log.Debug("Not initializing the CnsSnapshotDelta Controller as its a non-Vanilla CSI deployment")
+		return nil
+	}
+
+	coCommonInterface, err := commonco.GetContainerOrchestratorInterface(ctx,
+		common.Kubernetes, clusterFlavor, &syncer.COInitParams)
+	if err != nil {
+		log.Errorf("failed to create CO agnostic interface. Err: %v", err)
+		return err
+	}
+	if !coCommonInterface.IsFSSEnabled(ctx, common.SnapshotChangedBlockTracking) {
+		log.Infof("Not initializing the CnsSnapshotDelta Controller as %q feature is disabled on the cluster",
+			common.SnapshotChangedBlockTracking)
+		return nil
+	}
+	if coCommonInterface.IsFSSEnabled(ctx, common.MultiVCenterCSITopology) && len(configInfo.Cfg.VirtualCenter) > 1 {
+		log.Infof("Not initializing the CnsSnapshotDelta Controller as it is a multi VC deployment.")
+		return nil
+	}
+
+	// Initializes kubernetes client.
+	k8sclient, err := k8s.NewClient(ctx)
+	if err != nil {
+		log.Errorf("Creating Kubernetes client failed. Err: %v", err)
+		return err
+	}
+	snapshotterClient, err := k8s.NewSnapshotterClient(ctx)
+	if err != nil {
+		log.Errorf("Creating snapshotter client failed. Err: %v", err)
+		return err
+	}
+
+	// eventBroadcaster broadcasts events on cnssnapshotdelta instances to the
+	// event sink.
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartRecordingToSink(
+		&typedcorev1.EventSinkImpl{
+			Interface: k8sclient.CoreV1().Events(""),
+		},
+	)
+	recorder := eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: apis.GroupName})
+	return add(mgr, newReconciler(mgr, volumeManager, snapshotterClient, recorder))
+}
+
+// newReconciler returns a new reconcile.Reconciler.
+func newReconciler(mgr manager.Manager, volumeManager volumes.Manager,
+	snapshotterClient snapshotterClientSet.Interface, recorder record.EventRecorder) reconcile.Reconciler {
+	return &ReconcileCnsSnapshotDelta{client: mgr.GetClient(), scheme: mgr.GetScheme(),
+		volumeManager: volumeManager, snapshotterClient: snapshotterClient, recorder: recorder}
+}
+
+// add adds a new Controller to mgr with r as the reconcile.Reconciler.
+func add(mgr manager.Manager, r reconcile.Reconciler) error {
+	ctx, log := logger.GetNewContextWithLogger()
+
+	// Create a new controller.
+	c, err := controller.New("cnssnapshotdelta-controller", mgr,
+		controller.Options{Reconciler: r, MaxConcurrentReconciles: defaultMaxWorkerThreadsForSnapshotDelta})
+	if err != nil {
+		log.Errorf("Failed to create new CnsSnapshotDelta controller with error: %+v", err)
+		return err
+	}
+
+	backOffDuration = make(map[string]time.Duration)
+
+	// Watch for changes to primary resource CnsSnapshotDelta.
+	err = c.Watch(source.Kind(mgr.GetCache(), &deltav1alpha1.CnsSnapshotDelta{}),
+		&handler.EnqueueRequestForObject{})
+	if err != nil {
+		log.Errorf("Failed to watch for changes to CnsSnapshotDelta resource with error: %+v", err)
+		return err
+	}
+	return nil
+}
+
+// blank assignment to verify that ReconcileCnsSnapshotDelta implements
+// reconcile.Reconciler.
+var _ reconcile.Reconciler = &ReconcileCnsSnapshotDelta{}
+
+// ReconcileCnsSnapshotDelta reconciles a CnsSnapshotDelta object.
+type ReconcileCnsSnapshotDelta struct {
+	// This client, initialized using mgr.Client() above, is a split client
+	// that reads objects from the cache and writes to the apiserver.
+	client            client.Client
+	scheme            *runtime.Scheme
+	volumeManager     volumes.Manager
+	snapshotterClient snapshotterClientSet.Interface
+	recorder          record.EventRecorder
+}
+
+// Reconcile reads that state of the cluster for a CnsSnapshotDelta object and
+// sets in its status the areas of the VolumeSnapshot in CnsSnapshotDelta.Spec
+// changed since the base VolumeSnapshot, as tracked by the changed block
+// tracking of the volume. Incremental backup tools read only these areas of
+// the snapshot.
+// Note:
+// The Controller will requeue the Request to be processed again if the returned
+// error is non-nil or Result.Requeue is true. Otherwise, upon completion it
+// will remove the work from the queue.
+func (r *ReconcileCnsSnapshotDelta) Reconcile(ctx context.Context,
+	request reconcile.Request) (reconcile.Result, error) {
+	log := logger.GetLogger(ctx)
+	// Fetch the CnsSnapshotDelta instance.
+	instance := &deltav1alpha1.CnsSnapshotDelta{}
+	err := r.client.Get(ctx, request.NamespacedName, instance)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Infof("CnsSnapshotDelta resource not found. Ignoring since object must be deleted.")
+			return reconcile.Result{}, nil
+		}
+		log.Errorf("Error reading the CnsSnapshotDelta with name: %q on namespace: %q. Err: %+v",
+			request.Name, request.Namespace, err)
+		// Error reading the object - return with err.
+		return reconcile.Result{}, err
+	}
+	if instance.Status.Phase == deltav1alpha1.DeltaPhaseCompleted ||
+		instance.Status.Phase == deltav1alpha1.DeltaPhaseFailed {
+		backOffDurationMapMutex.Lock()
+		delete(backOffDuration, instance.Name)
+		backOffDurationMapMutex.Unlock()
+		return reconcile.Result{}, nil
+	}
+	// Initialize backOffDuration for the instance, if required.
+	backOffDurationMapMutex.Lock()
+	var timeout time.Duration
+	if _, exists := backOffDuration[instance.Name]; !exists {
+		backOffDuration[instance.Name] = time.Second
+	}
+	timeout = backOffDuration[instance.Name]
+	backOffDurationMapMutex.Unlock()
+
+	log.Infof("Reconciling CnsSnapshotDelta with instance: %q from namespace: %q",
+		instance.Name, instance.Namespace)
+	if err := validateCnsSnapshotDeltaSpec(instance); err != nil {
+		log.Error(err.Error())
+		setInstanceFailed(ctx, r, instance, err.Error())
+		return reconcile.Result{}, nil
+	}
+	if instance.Status.Phase == "" {
+		instance.Status.Phase = deltav1alpha1.DeltaPhasePending
+	}
+
+	volumeID, snapshotID, err := r.getSnapshotIDs(ctx, instance.Namespace, instance.Spec.VolumeSnapshotName)
+	if err != nil {
+		log.Error(err.Error())
+		setInstanceError(ctx, r, instance, err.Error())
+		return reconcile.Result{RequeueAfter: timeout}, nil
+	}
+	var baseSnapshotID string
+	if instance.Spec.BaseVolumeSnapshotName != "" {
+		var baseVolumeID string
+		baseVolumeID, baseSnapshotID, err = r.getSnapshotIDs(ctx, instance.Namespace,
+			instance.Spec.BaseVolumeSnapshotName)
+		if err != nil {
+			log.Error(err.Error())
+			setInstanceError(ctx, r, instance, err.Error())
+			return reconcile.Result{RequeueAfter: timeout}, nil
+		}
+		if baseVolumeID != volumeID {
+			msg := fmt.Sprintf("VolumeSnapshot %q and base VolumeSnapshot %q are snapshots of different "+
+				"volumes %q and %q", instance.Spec.VolumeSnapshotName, instance.Spec.BaseVolumeSnapshotName,
+				volumeID, baseVolumeID)
+			log.Error(msg)
+			setInstanceFailed(ctx, r, instance, msg)
+			return reconcile.Result{}, nil
+		}
+	}
+	instance.Status.VolumeID = volumeID
+
+	changedAreas, err := common.QueryChangedAreas(ctx, r.volumeManager, volumeID, snapshotID, baseSnapshotID,
+		instance.Spec.StartOffset, instance.Spec.MaxAreas)
+	if err != nil {
+		log.Error(err.Error())
+		setInstanceError(ctx, r, instance, err.Error())
+		return reconcile.Result{RequeueAfter: timeout}, nil
+	}
+	instance.Status.CapacityBytes = changedAreas.CapacityBytes
+	instance.Status.ChangedBytes = changedAreas.ChangedBytes
+	instance.Status.NextOffset = changedAreas.NextOffset
+	instance.Status.ChangedAreas = make([]deltav1alpha1.DiskArea, 0, len(changedAreas.Areas))
+	for _, area := range changedAreas.Areas {
+		instance.Status.ChangedAreas = append(instance.Status.ChangedAreas,
+			deltav1alpha1.DiskArea{Offset: area.Offset, Length: area.Length})
+	}
+	instance.Status.Phase = deltav1alpha1.DeltaPhaseCompleted
+	instance.Status.Error = ""
+	if err := updateCnsSnapshotDelta(ctx, r.client, instance); err != nil {
+		return reconcile.Result{RequeueAfter: timeout}, nil
+	}
+	msg := fmt.Sprintf("Found %d changed areas, %d bytes, in VolumeSnapshot %q", len(changedAreas.Areas),
+		changedAreas.ChangedBytes, instance.Spec.VolumeSnapshotName)
+	log.Info(msg)
+	recordEvent(ctx, r, instance, v1.EventTypeNormal, "CnsSnapshotDeltaSucceeded", msg)
+	backOffDurationMapMutex.Lock()
+	delete(backOffDuration, instance.Name)
+	backOffDurationMapMutex.Unlock()
+	return reconcile.Result{}, nil
+}
+
+// getSnapshotIDs returns the CNS volume ID and snapshot ID of the
+// VolumeSnapshot.
+func (r *ReconcileCnsSnapshotDelta) getSnapshotIDs(ctx context.Context, namespace, name string) (
+	string, string, error) {
+	snapshot, err := r.snapshotterClient.SnapshotV1().VolumeSnapshots(namespace).Get(ctx, name,
+		metav1.GetOptions{})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get VolumeSnapshot: %s on namespace: %s. Error: %+v",
+			name, namespace, err)
+	}
+	if snapshot.Status == nil || snapshot.Status.ReadyToUse == nil || !*snapshot.Status.ReadyToUse ||
+		snapshot.Status.BoundVolumeSnapshotContentName == nil {
+		return "", "", fmt.Errorf("VolumeSnapshot: %s on namespace: %s is not ready to use", name, namespace)
+	}
+	content, err := r.snapshotterClient.SnapshotV1().VolumeSnapshotContents().Get(ctx,
+		*snapshot.Status.BoundVolumeSnapshotContentName, metav1.GetOptions{})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get VolumeSnapshotContent: %s. Error: %+v",
+			*snapshot.Status.BoundVolumeSnapshotContentName, err)
+	}
+	if content.Status == nil || content.Status.SnapshotHandle == nil {
+		return "", "", fmt.Errorf("VolumeSnapshotContent: %s has no snapshot handle", content.Name)
+	}
+	return common.ParseCSISnapshotID(*content.Status.SnapshotHandle)
+}
+
+// validateCnsSnapshotDeltaSpec validates the input params of a
+// CnsSnapshotDelta instance.
+func validateCnsSnapshotDeltaSpec(instance *deltav1alpha1.CnsSnapshotDelta) error {
+	if instance.Spec.VolumeSnapshotName == "" {
+		return errors.New("VolumeSnapshotName must be specified")
+	}
+	if instance.Spec.StartOffset < 0 {
+		return errors.New("StartOffset must not be negative")
+	}
+	if instance.Spec.MaxAreas < 0 || instance.Spec.MaxAreas > maxChangedAreas {
+		return fmt.Errorf("MaxAreas must be between 0 and %d", maxChangedAreas)
+	}
+	return nil
+}
+
+// setInstanceError sets error and records an event on the CnsSnapshotDelta
+// instance.
+func setInstanceError(ctx context.Context, r *ReconcileCnsSnapshotDelta,
+	instance *deltav1alpha1.CnsSnapshotDelta, errMsg string) {
+	log := logger.GetLogger(ctx)
+	instance.Status.Error = errMsg
+	err := updateCnsSnapshotDelta(ctx, r.client, instance)
+	if err != nil {
+		log.Errorf("updateCnsSnapshotDelta failed. err: %v", err)
+	}
+	recordEvent(ctx, r, instance, v1.EventTypeWarning, "CnsSnapshotDeltaFailed", errMsg)
+}
+
+// setInstanceFailed marks the CnsSnapshotDelta instance as failed, so that it
+// is not retried.
+func setInstanceFailed(ctx context.Context, r *ReconcileCnsSnapshotDelta,
+	instance *deltav1alpha1.CnsSnapshotDelta, errMsg string) {
+	instance.Status.Phase = deltav1alpha1.DeltaPhaseFailed
+	setInstanceError(ctx, r, instance, errMsg)
+}
+
+// recordEvent records the event, sets the backOffDuration for the instance
+// appropriately and logs the message.
+// backOffDuration is reset to 1 second on success and doubled on failure.
+func recordEvent(ctx context.Context, r *ReconcileCnsSnapshotDelta,
+	instance *deltav1alpha1.CnsSnapshotDelta, eventtype string, reason string, msg string) {
+	log := logger.GetLogger(ctx)
+	log.Debugf("Event type is %s", eventtype)
+	switch eventtype {
+	case v1.EventTypeWarning:
+		// Double backOff duration.
+		backOffDurationMapMutex.Lock()
+		backOffDuration[instance.Name] = backOffDuration[instance.Name] * 2
+		r.recorder.Event(instance, v1.EventTypeWarning, reason, msg)
+		backOffDurationMapMutex.Unlock()
+	case v1.EventTypeNormal:
+		// Reset backOff duration to one second.
+		backOffDurationMapMutex.Lock()
+		backOffDuration[instance.Name] = time.Second
+		r.recorder.Event(instance, v1.EventTypeNormal, reason, msg)
+		backOffDurationMapMutex.Unlock()
+	}
+}
+
+// updateCnsSnapshotDelta updates the CnsSnapshotDelta instance in K8S.
+func updateCnsSnapshotDelta(ctx context.Context, client client.Client,
+	instance *deltav1alpha1.CnsSnapshotDelta) error {
+	log := logger.GetLogger(ctx)
+	err := client.Update(ctx, instance)
+	if err != nil {
+		log.Errorf("Failed to update CnsSnapshotDelta instance: %q on namespace: %q. Error: %+v",
+			instance.Name, instance.Namespace, err)
+	}
+	return err
+}
@@ -0,0 +1,667 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cnssnapshotexport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	snapshotterClientSet "github.com/kubernetes-csi/external-snapshotter/client/v6/clientset/versioned"
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	apis "sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/cnsoperator"
+	volumes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/datamover"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis"
+	exportv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsoperator/cnssnapshotexport/v1alpha1"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/syncer"
+)
+
+const (
+	defaultMaxWorkerThreadsForSnapshotExport = 4
+	// jobStatusCheckInterval is the interval at which the status of the data
+	// mover job is checked, while it uploads the content of the snapshot.
+	jobStatusCheckInterval = 30 * time.Second
+	// maxInstanceNameLength is the maximum length of the name of a
+	// CnsSnapshotExport instance, for the name of its data mover job to be a
+	// valid label value.
+	maxInstanceNameLength = 56
+
+	// envDataMoverImage is the environment variable of the syncer holding the
+	// image of the data mover jobs, the image of the syncer itself.
+	envDataMoverImage = "DATA_MOVER_IMAGE"
+	// jobNameSuffix and clonePvcNameSuffix are appended to the name of the
+	// instance to get the name of its data mover job and temporary clone.
+	jobNameSuffix      = "-export"
+	clonePvcNameSuffix = "-export-clone"
+	// dataMoverContainerName is the name of the container of the data mover
+	// job.
+	dataMoverContainerName = "data-mover"
+	// dataMoverDevicePath is the path of the temporary clone in the data mover
+	// container.
+	dataMoverDevicePath = "/dev/cns-snapshot-export"
+	// secretAccessKeyIDKey and secretSecretAccessKeyKey are the keys of the
+	// credentials secret of an instance.
+	secretAccessKeyIDKey     = "accessKeyID"
+	secretSecretAccessKeyKey = "secretAccessKey"
+	// annAllowVolumeModeChange allows to restore a snapshot of a Filesystem
+	// volume into the Block PVC read by the data mover. It has to be set on
+	// the VolumeSnapshotContent by a cluster admin, as the driver doesn't
+	// lift the volume mode conversion protection on behalf of the user.
+	annAllowVolumeModeChange = "snapshot.storage.kubernetes.io/allow-volume-mode-change"
+	// labelAllowSnapshotExport has to be set to "true" on a namespace by a
+	// cluster admin for the data mover jobs, which run as root, to be created
+	// in it.
+	labelAllowSnapshotExport = "cns.vmware.com/allow-snapshot-export"
+)
+
+// backOffDuration is a map of cnssnapshotexport name's to the time after
+// which a request for this instance will be requeued. Initialized to 1 second
+// for new instances and for instances whose latest reconcile operation
+// succeeded. If the reconcile fails, backoff is incremented exponentially.
+var (
+	backOffDuration         map[string]time.Duration
+	backOffDurationMapMutex = sync.Mutex{}
+)
+
+// Add creates a new CnsSnapshotExport Controller and adds it to the Manager,
+// ConfigurationInfo and VirtualCenterTypes. The Manager will set fields on the
+// Controller and start it when the Manager is Started.
+func Add(mgr manager.Manager, clusterFlavor cnstypes.CnsClusterFlavor,
+	configInfo *config.ConfigurationInfo, volumeManager volumes.Manager) error {
+	ctx, log := logger.GetNewContextWithLogger()
+	if clusterFlavor != cnstypes.CnsClusterFlavorVanilla {
+		log.Debug("Not initializing the CnsSnapshotExport Controller as its a non-Vanilla CSI deployment")
+		return nil
+	}
+
+	coCommonInterface, err := commonco.GetContainerOrchestratorInterface(ctx,
+		common.Kubernetes, clusterFlavor, &syncer.COInitParams)
+	if err != nil {
+		log.Errorf("failed to create CO agnostic interface. Err: %v", err)
+		return err
+	}
+	if !coCommonInterface.IsFSSEnabled(ctx, common.SnapshotExport) {
+		log.Infof("Not initializing the CnsSnapshotExport Controller as %q feature is disabled on the cluster",
+			common.SnapshotExport)
+		return nil
+	}
+
+	// Initializes kubernetes client.
+	k8sclient, err := k8s.NewClient(ctx)
+	if err != nil {
+		log.Errorf("Creating Kubernetes client failed. Err: %v", err)
+		return err
+	}
+	snapshotterClient, err := k8s.NewSnapshotterClient(ctx)
+	if err != nil {
+		log.Errorf("Creating snapshotter client failed. Err: %v", err)
+		return err
+	}
+
+	// eventBroadcaster broadcasts events on cnssnapshotexport instances to the
+	// event sink.
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartRecordingToSink(
+		&typedcorev1.EventSinkImpl{
+			Interface: k8sclient.CoreV1().Events(""),
+		},
+	)
+	recorder := eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: apis.GroupName})
+	return add(mgr, newReconciler(mgr, k8sclient, snapshotterClient, recorder, os.Getenv(envDataMoverImage)))
+}
+
+// newReconciler returns a new reconcile.Reconciler.
+func newReconciler(mgr manager.Manager, k8sclient clientset.Interface,
+	snapshotterClient snapshotterClientSet.Interface, recorder record.EventRecorder,
+	dataMoverImage string) reconcile.Reconciler {
+	return &ReconcileCnsSnapshotExport{client: mgr.GetClient(), scheme: mgr.GetScheme(), k8sclient: k8sclient,
+		snapshotterClient: snapshotterClient, recorder: recorder, dataMoverImage: dataMoverImage}
+}
+
+// add adds a new Controller to mgr with r as the reconcile.Reconciler.
+func add(mgr manager.Manager, r reconcile.Reconciler) error {
+	ctx, log := logger.GetNewContextWithLogger()
+
+	// Create a new controller.
+	c, err := controller.New("cnssnapshotexport-controller", mgr,
+		controller.Options{Reconciler: r, MaxConcurrentReconciles: defaultMaxWorkerThreadsForSnapshotExport})
+	if err != nil {
+		log.Errorf("Failed to create new CnsSnapshotExport controller with error: %+v", err)
+		return err
+	}
+
+	backOffDuration = make(map[string]time.Duration)
+
+	// Watch for changes to primary resource CnsSnapshotExport.
+	err = c.Watch(source.Kind(mgr.GetCache(), &exportv1alpha1.CnsSnapshotExport{}),
+		&handler.EnqueueRequestForObject{})
+	if err != nil {
+		log.Errorf("Failed to watch for changes to CnsSnapshotExport resource with error: %+v", err)
+		return err
+	}
+	return nil
+}
+
+// blank assignment to verify that ReconcileCnsSnapshotExport implements
+// reconcile.Reconciler.
+var _ reconcile.Reconciler = &ReconcileCnsSnapshotExport{}
+
+// ReconcileCnsSnapshotExport reconciles a CnsSnapshotExport object.
+type ReconcileCnsSnapshotExport struct {
+	// This client, initialized using mgr.Client() above, is a split client
+	// that reads objects from the cache and writes to the apiserver.
+	client            client.Client
+	scheme            *runtime.Scheme
+	k8sclient         clientset.Interface
+	snapshotterClient snapshotterClientSet.Interface
+	recorder          record.EventRecorder
+	// dataMoverImage is the image of the data mover jobs.
+	dataMoverImage string
+}
+
+// Reconcile reads that state of the cluster for a CnsSnapshotExport object and
+// uploads the content of the VolumeSnapshot in CnsSnapshotExport.Spec to the
+// bucket. The snapshot is restored into a temporary Block PVC, which is read
+// by a data mover job running the syncer image in the DATA_MOVER operation
+// mode. The instance is requeued until the job completes, and the job and the
+// temporary PVC are then deleted.
+// Note:
+// The Controller will requeue the Request to be processed again if the returned
+// error is non-nil or Result.Requeue is true. Otherwise, upon completion it
+// will remove the work from the queue.
+func (r *ReconcileCnsSnapshotExport) Reconcile(ctx context.Context,
+	request reconcile.Request) (reconcile.Result, error) {
+	log := logger.GetLogger(ctx)
+	// Fetch the CnsSnapshotExport instance.
+	instance := &exportv1alpha1.CnsSnapshotExport{}
+	err := r.client.Get(ctx, request.NamespacedName, instance)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Infof("CnsSnapshotExport resource not found. Ignoring since object must be deleted.")
+			return reconcile.Result{}, nil
+		}
+		log.Errorf("Error reading the CnsSnapshotExport with name: %q on namespace: %q. Err: %+v",
+			request.Name, request.Namespace, err)
+		// Error reading the object - return with err.
+		return reconcile.Result{}, err
+	}
+	if instance.Status.Phase == exportv1alpha1.ExportPhaseCompleted ||
+		instance.Status.Phase == exportv1alpha1.ExportPhaseFailed {
+		backOffDurationMapMutex.Lock()
+		delete(backOffDuration, instance.Name)
+		backOffDurationMapMutex.Unlock()
+		return reconcile.Result{}, nil
+	}
+	// Initialize backOffDuration for the instance, if required.
+	backOffDurationMapMutex.Lock()
+	var timeout time.Duration
+	if _, exists := backOffDuration[instance.Name]; !exists {
+		backOffDuration[instance.Name] = time.Second
+	}
+	timeout = backOffDuration[instance.Name]
+	backOffDurationMapMutex.Unlock()
+
+	log.Infof("Reconciling CnsSnapshotExport with instance: %q from namespace: %q",
+		instance.Name, instance.Namespace)
+	if err := r.validateCnsSnapshotExportSpec(instance); err != nil {
+		log.Error(err.Error())
+		setInstanceFailed(ctx, r, instance, err.Error())
+		return reconcile.Result{}, nil
+	}
+	if instance.Status.Phase == "" {
+		instance.Status.Phase = exportv1alpha1.ExportPhasePending
+	}
+	if instance.Status.Key == "" {
+		instance.Status.Key = instance.Spec.Key
+		if instance.Status.Key == "" {
+			instance.Status.Key = instance.Namespace + "/" + instance.Spec.VolumeSnapshotName + ".img"
+		}
+	}
+
+	var job *batchv1.Job
+	jobExists := false
+	if instance.Status.JobName != "" {
+		job, err = r.k8sclient.BatchV1().Jobs(instance.Namespace).Get(ctx, instance.Status.JobName,
+			metav1.GetOptions{})
+		if err == nil {
+			jobExists = true
+		} else if !apierrors.IsNotFound(err) {
+			msg := fmt.Sprintf("Failed to get data mover job %q. Error: %+v", instance.Status.JobName, err)
+			log.Error(msg)
+			setInstanceError(ctx, r, instance, msg)
+			return reconcile.Result{RequeueAfter: timeout}, nil
+		}
+	}
+	if !jobExists {
+		// The data mover job is created on the first reconcile, and created
+		// again if it was deleted. A new job resumes the upload in progress.
+		if err := r.startExport(ctx, instance); err != nil {
+			log.Error(err.Error())
+			setInstanceError(ctx, r, instance, err.Error())
+			return reconcile.Result{RequeueAfter: timeout}, nil
+		}
+		instance.Status.Phase = exportv1alpha1.ExportPhaseUploading
+		instance.Status.Error = ""
+		if err := updateCnsSnapshotExport(ctx, r.client, instance); err != nil {
+			return reconcile.Result{RequeueAfter: timeout}, nil
+		}
+		recordEvent(ctx, r, instance, v1.EventTypeNormal, "ExportStarted",
+			fmt.Sprintf("Started data mover job %q uploading VolumeSnapshot %q to %q in bucket %q",
+				instance.Status.JobName, instance.Spec.VolumeSnapshotName, instance.Status.Key,
+				instance.Spec.Bucket))
+		return reconcile.Result{RequeueAfter: jobStatusCheckInterval}, nil
+	}
+
+	if job.Status.Succeeded == 0 && !isJobFailed(job) {
+		log.Infof("Data mover job %s/%s is running, checking again in %v", job.Namespace, job.Name,
+			jobStatusCheckInterval)
+		return reconcile.Result{RequeueAfter: jobStatusCheckInterval}, nil
+	}
+	message, err := r.getJobTerminationMessage(ctx, job)
+	if err != nil {
+		log.Error(err.Error())
+		setInstanceError(ctx, r, instance, err.Error())
+		return reconcile.Result{RequeueAfter: timeout}, nil
+	}
+	if isJobFailed(job) {
+		msg := fmt.Sprintf("Data mover job %q failed. Last error: %s", job.Name, message)
+		log.Error(msg)
+		r.cleanup(ctx, instance)
+		setInstanceFailed(ctx, r, instance, msg)
+		return reconcile.Result{}, nil
+	}
+	var result datamover.Result
+	if err := json.Unmarshal([]byte(message), &result); err != nil {
+		msg := fmt.Sprintf("Failed to decode the result %q of data mover job %q. Error: %+v", message, job.Name, err)
+		log.Error(msg)
+		r.cleanup(ctx, instance)
+		setInstanceFailed(ctx, r, instance, msg)
+		return reconcile.Result{}, nil
+	}
+	r.cleanup(ctx, instance)
+	instance.Status.Phase = exportv1alpha1.ExportPhaseCompleted
+	instance.Status.SizeBytes = result.SizeBytes
+	instance.Status.Checksum = result.Checksum
+	instance.Status.ResumedParts = result.ResumedParts
+	instance.Status.Error = ""
+	if err := updateCnsSnapshotExport(ctx, r.client, instance); err != nil {
+		return reconcile.Result{RequeueAfter: timeout}, nil
+	}
+	msg := fmt.Sprintf("Uploaded %d bytes of VolumeSnapshot %s/%s to %q in bucket %q, SHA-256: %s",
+		result.SizeBytes, instance.Namespace, instance.Spec.VolumeSnapshotName, instance.Status.Key,
+		instance.Spec.Bucket, result.Checksum)
+	log.Info(msg)
+	recordEvent(ctx, r, instance, v1.EventTypeNormal, "CnsSnapshotExportSucceeded", msg)
+	backOffDurationMapMutex.Lock()
+	delete(backOffDuration, instance.Name)
+	backOffDurationMapMutex.Unlock()
+	return reconcile.Result{}, nil
+}
+
+// startExport creates the temporary clone of the snapshot and the data mover
+// job reading it, unless they already exist, and sets their names in the
+// status of the instance.
+func (r *ReconcileCnsSnapshotExport) startExport(ctx context.Context,
+	instance *exportv1alpha1.CnsSnapshotExport) error {
+	log := logger.GetLogger(ctx)
+	snapshot, err := r.snapshotterClient.SnapshotV1().VolumeSnapshots(instance.Namespace).Get(ctx,
+		instance.Spec.VolumeSnapshotName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get VolumeSnapshot: %s on namespace: %s. Error: %+v",
+			instance.Spec.VolumeSnapshotName, instance.Namespace, err)
+	}
+	if snapshot.Status == nil || snapshot.Status.ReadyToUse == nil || !*snapshot.Status.ReadyToUse ||
+		snapshot.Status.RestoreSize == nil || snapshot.Status.BoundVolumeSnapshotContentName == nil {
+		return fmt.Errorf("VolumeSnapshot: %s on namespace: %s is not ready to use", snapshot.Name,
+			snapshot.Namespace)
+	}
+	storageClassName := instance.Spec.StorageClassName
+	if storageClassName == "" {
+		if snapshot.Spec.Source.PersistentVolumeClaimName == nil {
+			return fmt.Errorf("VolumeSnapshot: %s on namespace: %s has no source PVC, StorageClassName "+
+				"must be specified", snapshot.Name, snapshot.Namespace)
+		}
+		pvc, err := r.k8sclient.CoreV1().PersistentVolumeClaims(instance.Namespace).Get(ctx,
+			*snapshot.Spec.Source.PersistentVolumeClaimName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get the source PVC of VolumeSnapshot: %s on namespace: %s, "+
+				"StorageClassName must be specified. Error: %+v", snapshot.Name, snapshot.Namespace, err)
+		}
+		if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName == "" {
+			return fmt.Errorf("source PVC: %s on namespace: %s has no StorageClass, StorageClassName "+
+				"must be specified", pvc.Name, pvc.Namespace)
+		}
+		storageClassName = *pvc.Spec.StorageClassName
+	}
+
+	// The data mover runs as root to read the block device, in namespaces
+	// where a cluster admin allowed it.
+	namespace, err := r.k8sclient.CoreV1().Namespaces().Get(ctx, instance.Namespace, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get namespace: %s. Error: %+v", instance.Namespace, err)
+	}
+	if namespace.Labels[labelAllowSnapshotExport] != "true" {
+		return fmt.Errorf("snapshot export isn't allowed in namespace: %s, a cluster admin has to label it "+
+			"with %s=true", instance.Namespace, labelAllowSnapshotExport)
+	}
+	// The data mover reads the clone as a raw block device, whatever the
+	// volume mode of the snapshotted volume.
+	content, err := r.snapshotterClient.SnapshotV1().VolumeSnapshotContents().Get(ctx,
+		*snapshot.Status.BoundVolumeSnapshotContentName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get VolumeSnapshotContent: %s. Error: %+v",
+			*snapshot.Status.BoundVolumeSnapshotContentName, err)
+	}
+	if content.Annotations[annAllowVolumeModeChange] != "true" {
+		return fmt.Errorf("VolumeSnapshotContent: %s of VolumeSnapshot: %s on namespace: %s can't be restored "+
+			"into a Block PVC, a cluster admin has to annotate it with %s=true", content.Name, snapshot.Name,
+			snapshot.Namespace, annAllowVolumeModeChange)
+	}
+
+	ownerReferences := getOwnerReferences(instance)
+	clonePvc := newClonePVC(instance, storageClassName, *snapshot.Status.RestoreSize, ownerReferences)
+	_, err = r.k8sclient.CoreV1().PersistentVolumeClaims(instance.Namespace).Create(ctx, clonePvc,
+		metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create PVC: %s on namespace: %s from VolumeSnapshot: %s. Error: %+v",
+			clonePvc.Name, clonePvc.Namespace, snapshot.Name, err)
+	}
+	instance.Status.ClonePvcName = clonePvc.Name
+
+	job := r.newDataMoverJob(instance, clonePvc.Name, ownerReferences)
+	_, err = r.k8sclient.BatchV1().Jobs(instance.Namespace).Create(ctx, job, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create data mover job: %s on namespace: %s. Error: %+v",
+			job.Name, job.Namespace, err)
+	}
+	instance.Status.JobName = job.Name
+	log.Infof("Created data mover job %s/%s reading PVC %s cloned from VolumeSnapshot %s", job.Namespace,
+		job.Name, clonePvc.Name, snapshot.Name)
+	return nil
+}
+
+// newClonePVC returns the temporary Block PVC restored from the snapshot of the
+// instance.
+func newClonePVC(instance *exportv1alpha1.CnsSnapshotExport, storageClassName string, size resource.Quantity,
+	ownerReferences []metav1.OwnerReference) *v1.PersistentVolumeClaim {
+	volumeMode := v1.PersistentVolumeBlock
+	apiGroup := "snapshot.storage.k8s.io"
+	return &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            instance.Name + clonePvcNameSuffix,
+			Namespace:       instance.Namespace,
+			OwnerReferences: ownerReferences,
+		},
+		Spec: v1.PersistentVolumeClaimSpec{
+			AccessModes:      []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+			StorageClassName: &storageClassName,
+			VolumeMode:       &volumeMode,
+			DataSource: &v1.TypedLocalObjectReference{
+				APIGroup: &apiGroup,
+				Kind:     "VolumeSnapshot",
+				Name:     instance.Spec.VolumeSnapshotName,
+			},
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceStorage: size},
+			},
+		},
+	}
+}
+
+// newDataMoverJob returns the job uploading the content of the clone PVC to
+// the bucket of the instance.
+func (r *ReconcileCnsSnapshotExport) newDataMoverJob(instance *exportv1alpha1.CnsSnapshotExport,
+	clonePvcName string, ownerReferences []metav1.OwnerReference) *batchv1.Job {
+	backoffLimit := int32(5)
+	automountServiceAccountToken := false
+	// Reading the block device requires root, but no capability.
+	runAsUser := int64(0)
+	allowPrivilegeEscalation := false
+	readOnlyRootFilesystem := true
+	secretKeyRef := func(key string) *v1.EnvVarSource {
+		return &v1.EnvVarSource{SecretKeyRef: &v1.SecretKeySelector{
+			LocalObjectReference: v1.LocalObjectReference{Name: instance.Spec.CredentialsSecretName},
+			Key:                  key,
+		}}
+	}
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            instance.Name + jobNameSuffix,
+			Namespace:       instance.Namespace,
+			OwnerReferences: ownerReferences,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{
+					RestartPolicy:                v1.RestartPolicyNever,
+					AutomountServiceAccountToken: &automountServiceAccountToken,
+					Containers: []v1.Container{{
+						Name:  dataMoverContainerName,
+						Image: r.dataMoverImage,
+						Args:  []string{"--operation-mode=" + string(common.OperationModeDataMover)},
+						Env: []v1.EnvVar{
+							{Name: datamover.EnvDevicePath, Value: dataMoverDevicePath},
+							{Name: datamover.EnvS3Endpoint, Value: instance.Spec.Endpoint},
+							{Name: datamover.EnvS3Region, Value: instance.Spec.Region},
+							{Name: datamover.EnvS3Bucket, Value: instance.Spec.Bucket},
+							{Name: datamover.EnvS3Key, Value: instance.Status.Key},
+							{Name: datamover.EnvS3AccessKeyID, ValueFrom: secretKeyRef(secretAccessKeyIDKey)},
+							{Name: datamover.EnvS3SecretAccessKey, ValueFrom: secretKeyRef(secretSecretAccessKeyKey)},
+						},
+						VolumeDevices: []v1.VolumeDevice{{Name: "source", DevicePath: dataMoverDevicePath}},
+						SecurityContext: &v1.SecurityContext{
+							RunAsUser:                &runAsUser,
+							AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+							ReadOnlyRootFilesystem:   &readOnlyRootFilesystem,
+							Capabilities:             &v1.Capabilities{Drop: []v1.Capability{"ALL"}},
+						},
+						TerminationMessagePolicy: v1.TerminationMessageFallbackToLogsOnError,
+					}},
+					Volumes: []v1.Volume{{
+						Name: "source",
+						VolumeSource: v1.VolumeSource{
+							PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+								ClaimName: clonePvcName,
+								ReadOnly:  true,
+							},
+						},
+					}},
+				},
+			},
+		},
+	}
+}
+
+// getJobTerminationMessage returns the termination message of the data mover
+// container of the last finished pod of the job. It is the Result of the data
+// mover if the job succeeded, and the end of its logs if it failed.
+func (r *ReconcileCnsSnapshotExport) getJobTerminationMessage(ctx context.Context,
+	job *batchv1.Job) (string, error) {
+	pods, err := r.k8sclient.CoreV1().Pods(job.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: "job-name=" + job.Name,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list the pods of data mover job %q. Error: %+v", job.Name, err)
+	}
+	var message string
+	var finishedAt time.Time
+	for _, pod := range pods.Items {
+		if job.Status.Succeeded > 0 && pod.Status.Phase != v1.PodSucceeded {
+			continue
+		}
+		for _, status := range pod.Status.ContainerStatuses {
+			terminated := status.State.Terminated
+			if status.Name == dataMoverContainerName && terminated != nil &&
+				!terminated.FinishedAt.Time.Before(finishedAt) {
+				message, finishedAt = terminated.Message, terminated.FinishedAt.Time
+			}
+		}
+	}
+	if job.Status.Succeeded > 0 && message == "" {
+		return "", fmt.Errorf("no result found for data mover job %q", job.Name)
+	}
+	return message, nil
+}
+
+// cleanup deletes the data mover job and the temporary clone of the instance.
+// Failures are logged, as the owner references of the instance let them be
+// garbage collected along with the instance.
+func (r *ReconcileCnsSnapshotExport) cleanup(ctx context.Context, instance *exportv1alpha1.CnsSnapshotExport) {
+	log := logger.GetLogger(ctx)
+	propagationPolicy := metav1.DeletePropagationBackground
+	if instance.Status.JobName != "" {
+		err := r.k8sclient.BatchV1().Jobs(instance.Namespace).Delete(ctx, instance.Status.JobName,
+			metav1.DeleteOptions{PropagationPolicy: &propagationPolicy})
+		if err != nil && !apierrors.IsNotFound(err) {
+			log.Errorf("Failed to delete data mover job %s/%s. Error: %+v", instance.Namespace,
+				instance.Status.JobName, err)
+		}
+	}
+	if instance.Status.ClonePvcName != "" {
+		err := r.k8sclient.CoreV1().PersistentVolumeClaims(instance.Namespace).Delete(ctx,
+			instance.Status.ClonePvcName, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			log.Errorf("Failed to delete PVC %s/%s. Error: %+v", instance.Namespace,
+				instance.Status.ClonePvcName, err)
+		}
+	}
+}
+
+// isJobFailed returns true if the job failed, after exhausting its retries.
+func isJobFailed(job *batchv1.Job) bool {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == v1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// getOwnerReferences returns the owner references making the instance the
+// controller of the objects created for it.
+func getOwnerReferences(instance *exportv1alpha1.CnsSnapshotExport) []metav1.OwnerReference {
+	isController := true
+	return []metav1.OwnerReference{{
+		APIVersion: internalapis.SchemeGroupVersion.String(),
+		Kind:       "CnsSnapshotExport",
+		Name:       instance.Name,
+		UID:        instance.UID,
+		Controller: &isController,
+	}}
+}
+
+// validateCnsSnapshotExportSpec validates the input params of a
+// CnsSnapshotExport instance.
+func (r *ReconcileCnsSnapshotExport) validateCnsSnapshotExportSpec(
+	instance *exportv1alpha1.CnsSnapshotExport) error {
+	if instance.Spec.VolumeSnapshotName == "" || instance.Spec.Endpoint == "" || instance.Spec.Bucket == "" ||
+		instance.Spec.CredentialsSecretName == "" {
+		return errors.New("VolumeSnapshotName, Endpoint, Bucket and CredentialsSecretName must be specified")
+	}
+	if len(instance.Name) > maxInstanceNameLength {
+		return fmt.Errorf("name of CnsSnapshotExport must be at most %d characters long", maxInstanceNameLength)
+	}
+	if r.dataMoverImage == "" {
+		return fmt.Errorf("the data mover image isn't set in the %s environment variable of the syncer",
+			envDataMoverImage)
+	}
+	return nil
+}
+
+// setInstanceError sets error and records an event on the CnsSnapshotExport
+// instance.
+func setInstanceError(ctx context.Context, r *ReconcileCnsSnapshotExport,
+	instance *exportv1alpha1.CnsSnapshotExport, errMsg string) {
+	log := logger.GetLogger(ctx)
+	instance.Status.Error = errMsg
+	err := updateCnsSnapshotExport(ctx, r.client, instance)
+	if err != nil {
+		log.Errorf("updateCnsSnapshotExport failed. err: %v", err)
+	}
+	recordEvent(ctx, r, instance, v1.EventTypeWarning, "CnsSnapshotExportFailed", errMsg)
+}
+
+// setInstanceFailed marks the CnsSnapshotExport instance as failed, so that it
+// is not retried.
+func setInstanceFailed(ctx context.Context, r *ReconcileCnsSnapshotExport,
+	instance *exportv1alpha1.CnsSnapshotExport, errMsg string) {
+	instance.Status.Phase = exportv1alpha1.ExportPhaseFailed
+	setInstanceError(ctx, r, instance, errMsg)
+}
+
+// recordEvent records the event, sets the backOffDuration for the instance
+// appropriately and logs the message.
+// backOffDuration is reset to 1 second on success and doubled on failure.
+func recordEvent(ctx context.Context, r *ReconcileCnsSnapshotExport,
+	instance *exportv1alpha1.CnsSnapshotExport, eventtype string, reason string, msg string) {
+	log := logger.GetLogger(ctx)
+	log.Debugf("Event type is %s", eventtype)
+	switch eventtype {
+	case v1.EventTypeWarning:
+		// Double backOff duration.
+		backOffDurationMapMutex.Lock()
+		backOffDuration[instance.Name] = backOffDuration[instance.Name] * 2
+		r.recorder.Event(instance, v1.EventTypeWarning, reason, msg)
+		backOffDurationMapMutex.Unlock()
+	case v1.EventTypeNormal:
+		// Reset backOff duration to one second.
+		backOffDurationMapMutex.Lock()
+		backOffDuration[instance.Name] = time.Second
+		r.recorder.Event(instance, v1.EventTypeNormal, reason, msg)
+		backOffDurationMapMutex.Unlock()
+	}
+}
+
+// updateCnsSnapshotExport updates the CnsSnapshotExport instance in K8S.
+func updateCnsSnapshotExport(ctx context.Context, client client.Client,
+	instance *exportv1alpha1.CnsSnapshotExport) error {
+	log := logger.GetLogger(ctx)
+	err := client.Update(ctx, instance)
+	if err != nil {
+		log.Errorf("Failed to update CnsSnapshotExport instance: %q on namespace: %q. Error: %+v",
+			instance.Name, instance.Namespace, err)
+	}
+	return err
+}
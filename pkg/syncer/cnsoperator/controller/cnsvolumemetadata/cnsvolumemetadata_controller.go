@@ -22,6 +22,7 @@ import (
 	"os"
 	"reflect"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -217,6 +218,12 @@ func (r *ReconcileCnsVolumeMetadata) Reconcile(ctx context.Context,
 		recordEvent(ctx, r, instance, v1.EventTypeWarning, msg)
 		return reconcile.Result{RequeueAfter: timeout}, nil
 	}
+	// Label instances created by guest clusters which didn't set the guest
+	// cluster ID label yet. The label is persisted by the next update of the
+	// instance.
+	if _, ok := instance.Labels[cnsv1alpha1.GuestClusterIDLabel]; !ok {
+		metav1.SetMetaDataLabel(&instance.ObjectMeta, cnsv1alpha1.GuestClusterIDLabel, instance.Spec.GuestClusterID)
+	}
 
 	// If deletion timestamp is set, instance is marked for deletion by k8s.
 	// Remove corresponding metadata from CNS.
@@ -374,7 +381,7 @@ func (r *ReconcileCnsVolumeMetadata) updateCnsMetadata(ctx context.Context,
 			success = false
 			continue
 		}
-		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != csitypes.Name {
+		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != csitypes.DriverName() {
 			continue
 		}
 
@@ -429,6 +436,15 @@ func validateReconileRequest(req *cnsv1alpha1.CnsVolumeMetadata) error {
 	if req.Spec.EntityName == "" || req.Spec.EntityType == "" || req.Spec.GuestClusterID == "" {
 		return errors.NewBadRequest("EntityName, EntityType and GuestClusterID are required parameters.")
 	}
+	// Several guest clusters can share a supervisor namespace. Make sure an
+	// instance can't be used to update the CNS metadata of another cluster.
+	if !strings.HasPrefix(req.Name, req.Spec.GuestClusterID+"-") {
+		return errors.NewBadRequest("Instance name should be prefixed with the GuestClusterID.")
+	}
+	if id, ok := req.Labels[cnsv1alpha1.GuestClusterIDLabel]; ok && id != req.Spec.GuestClusterID {
+		return errors.NewBadRequest(fmt.Sprintf("Label %q does not match GuestClusterID.",
+			cnsv1alpha1.GuestClusterIDLabel))
+	}
 	switch req.Spec.EntityType {
 	case cnsv1alpha1.CnsOperatorEntityTypePV:
 		if req.Spec.Namespace != "" {
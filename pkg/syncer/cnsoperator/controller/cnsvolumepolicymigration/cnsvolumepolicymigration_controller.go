@@ -0,0 +1,418 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cnsvolumepolicymigration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	pbmtypes "github.com/vmware/govmomi/pbm/types"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	apis "sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/cnsoperator"
+	volumes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+	migrationv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsoperator/cnsvolumepolicymigration/v1alpha1"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/syncer"
+)
+
+const (
+	defaultMaxWorkerThreadsForPolicyMigration = 4
+	// complianceCheckInterval is the interval at which the compliance of a
+	// volume with its new storage policy is checked, while the volume resyncs.
+	complianceCheckInterval = 30 * time.Second
+)
+
+// backOffDuration is a map of cnsvolumepolicymigration name's to the time
+// after which a request for this instance will be requeued. Initialized to
+// 1 second for new instances and for instances whose latest reconcile
+// operation succeeded. If the reconcile fails, backoff is incremented
+// exponentially.
+var (
+	backOffDuration         map[string]time.Duration
+	backOffDurationMapMutex = sync.Mutex{}
+)
+
+// Add creates a new CnsVolumePolicyMigration Controller and adds it to the
+// Manager, ConfigurationInfo and VirtualCenterTypes. The Manager will set
+// fields on the Controller and start it when the Manager is Started.
+func Add(mgr manager.Manager, clusterFlavor cnstypes.CnsClusterFlavor,
+	configInfo *config.ConfigurationInfo, volumeManager volumes.Manager) error {
+	ctx, log := logger.GetNewContextWithLogger()
+	if clusterFlavor != cnstypes.CnsClusterFlavorVanilla {
+		log.Debug("Not initializing the CnsVolumePolicyMigration Controller as its a non-Vanilla CSI deployment")
+		return nil
+	}
+
+	coCommonInterface, err := commonco.GetContainerOrchestratorInterface(ctx,
+		common.Kubernetes, clusterFlavor, &syncer.COInitParams)
+	if err != nil {
+		log.Errorf("failed to create CO agnostic interface. Err: %v", err)
+		return err
+	}
+	if !coCommonInterface.IsFSSEnabled(ctx, common.VolumePolicyMigration) {
+		log.Infof("Not initializing the CnsVolumePolicyMigration Controller as %q feature is disabled on the cluster",
+			common.VolumePolicyMigration)
+		return nil
+	}
+	if coCommonInterface.IsFSSEnabled(ctx, common.MultiVCenterCSITopology) && len(configInfo.Cfg.VirtualCenter) > 1 {
+		log.Infof("Not initializing the CnsVolumePolicyMigration Controller as it is a multi VC deployment.")
+		return nil
+	}
+
+	// Initializes kubernetes client.
+	k8sclient, err := k8s.NewClient(ctx)
+	if err != nil {
+		log.Errorf("Creating Kubernetes client failed. Err: %v", err)
+		return err
+	}
+
+	// eventBroadcaster broadcasts events on cnsvolumepolicymigration instances
+	// to the event sink.
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartRecordingToSink(
+		&typedcorev1.EventSinkImpl{
+			Interface: k8sclient.CoreV1().Events(""),
+		},
+	)
+	recorder := eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: apis.GroupName})
+	return add(mgr, newReconciler(mgr, configInfo, volumeManager, k8sclient, recorder))
+}
+
+// newReconciler returns a new reconcile.Reconciler.
+func newReconciler(mgr manager.Manager, configInfo *config.ConfigurationInfo, volumeManager volumes.Manager,
+	k8sclient clientset.Interface, recorder record.EventRecorder) reconcile.Reconciler {
+	return &ReconcileCnsVolumePolicyMigration{client: mgr.GetClient(), scheme: mgr.GetScheme(),
+		configInfo: configInfo, volumeManager: volumeManager, k8sclient: k8sclient, recorder: recorder}
+}
+
+// add adds a new Controller to mgr with r as the reconcile.Reconciler.
+func add(mgr manager.Manager, r reconcile.Reconciler) error {
+	ctx, log := logger.GetNewContextWithLogger()
+
+	// Create a new controller.
+	c, err := controller.New("cnsvolumepolicymigration-controller", mgr,
+		controller.Options{Reconciler: r, MaxConcurrentReconciles: defaultMaxWorkerThreadsForPolicyMigration})
+	if err != nil {
+		log.Errorf("Failed to create new CnsVolumePolicyMigration controller with error: %+v", err)
+		return err
+	}
+
+	backOffDuration = make(map[string]time.Duration)
+
+	// Watch for changes to primary resource CnsVolumePolicyMigration.
+	err = c.Watch(source.Kind(mgr.GetCache(), &migrationv1alpha1.CnsVolumePolicyMigration{}),
+		&handler.EnqueueRequestForObject{})
+	if err != nil {
+		log.Errorf("Failed to watch for changes to CnsVolumePolicyMigration resource with error: %+v", err)
+		return err
+	}
+	return nil
+}
+
+// blank assignment to verify that ReconcileCnsVolumePolicyMigration implements
+// reconcile.Reconciler.
+var _ reconcile.Reconciler = &ReconcileCnsVolumePolicyMigration{}
+
+// ReconcileCnsVolumePolicyMigration reconciles a CnsVolumePolicyMigration
+// object.
+type ReconcileCnsVolumePolicyMigration struct {
+	// This client, initialized using mgr.Client() above, is a split client
+	// that reads objects from the cache and writes to the apiserver.
+	client        client.Client
+	scheme        *runtime.Scheme
+	configInfo    *config.ConfigurationInfo
+	volumeManager volumes.Manager
+	k8sclient     clientset.Interface
+	recorder      record.EventRecorder
+}
+
+// Reconcile reads that state of the cluster for a CnsVolumePolicyMigration
+// object and changes the storage policy of the volume bound to the PVC in
+// CnsVolumePolicyMigration.Spec. Once CNS applied the new storage policy, the
+// volume is resynced in the background by vSAN, and the instance is requeued
+// until the volume complies with the new storage policy. The volume stays
+// attached and in use during the migration.
+// Note:
+// The Controller will requeue the Request to be processed again if the returned
+// error is non-nil or Result.Requeue is true. Otherwise, upon completion it
+// will remove the work from the queue.
+func (r *ReconcileCnsVolumePolicyMigration) Reconcile(ctx context.Context,
+	request reconcile.Request) (reconcile.Result, error) {
+	log := logger.GetLogger(ctx)
+	// Fetch the CnsVolumePolicyMigration instance.
+	instance := &migrationv1alpha1.CnsVolumePolicyMigration{}
+	err := r.client.Get(ctx, request.NamespacedName, instance)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Infof("CnsVolumePolicyMigration resource not found. Ignoring since object must be deleted.")
+			return reconcile.Result{}, nil
+		}
+		log.Errorf("Error reading the CnsVolumePolicyMigration with name: %q on namespace: %q. Err: %+v",
+			request.Name, request.Namespace, err)
+		// Error reading the object - return with err.
+		return reconcile.Result{}, err
+	}
+	if instance.Status.Phase == migrationv1alpha1.MigrationPhaseCompleted ||
+		instance.Status.Phase == migrationv1alpha1.MigrationPhaseFailed {
+		backOffDurationMapMutex.Lock()
+		delete(backOffDuration, instance.Name)
+		backOffDurationMapMutex.Unlock()
+		return reconcile.Result{}, nil
+	}
+	// Initialize backOffDuration for the instance, if required.
+	backOffDurationMapMutex.Lock()
+	var timeout time.Duration
+	if _, exists := backOffDuration[instance.Name]; !exists {
+		backOffDuration[instance.Name] = time.Second
+	}
+	timeout = backOffDuration[instance.Name]
+	backOffDurationMapMutex.Unlock()
+
+	log.Infof("Reconciling CnsVolumePolicyMigration with instance: %q from namespace: %q",
+		instance.Name, instance.Namespace)
+	if err := validateCnsVolumePolicyMigrationSpec(instance); err != nil {
+		log.Error(err.Error())
+		setInstanceFailed(ctx, r, instance, err.Error())
+		return reconcile.Result{}, nil
+	}
+	if instance.Status.Phase == "" {
+		instance.Status.Phase = migrationv1alpha1.MigrationPhasePending
+	}
+
+	if instance.Status.VolumeID == "" {
+		volumeID, err := r.getVolumeID(ctx, instance)
+		if err != nil {
+			log.Error(err.Error())
+			setInstanceError(ctx, r, instance, err.Error())
+			return reconcile.Result{RequeueAfter: timeout}, nil
+		}
+		instance.Status.VolumeID = volumeID
+	}
+	volumeID := instance.Status.VolumeID
+
+	if instance.Status.TargetStoragePolicyID == "" {
+		vc, err := cnsvsphere.GetVirtualCenterInstance(ctx, r.configInfo, false)
+		if err != nil {
+			msg := fmt.Sprintf("Failed to get vCenter instance. Error: %+v", err)
+			log.Error(msg)
+			setInstanceError(ctx, r, instance, msg)
+			return reconcile.Result{RequeueAfter: timeout}, nil
+		}
+		storagePolicyID, err := vc.GetStoragePolicyIDByName(ctx, instance.Spec.StoragePolicyName)
+		if err != nil {
+			msg := fmt.Sprintf("Failed to get the ID of storage policy %q. Error: %+v",
+				instance.Spec.StoragePolicyName, err)
+			log.Error(msg)
+			setInstanceError(ctx, r, instance, msg)
+			return reconcile.Result{RequeueAfter: timeout}, nil
+		}
+		instance.Status.TargetStoragePolicyID = storagePolicyID
+	}
+	targetStoragePolicyID := instance.Status.TargetStoragePolicyID
+
+	querySelection := &cnstypes.CnsQuerySelection{
+		Names: []string{
+			string(cnstypes.QuerySelectionNameTypePolicyId),
+			string(cnstypes.QuerySelectionNameTypeComplianceStatus),
+		},
+	}
+	volume, err := common.QueryVolumeByID(ctx, r.volumeManager, volumeID, querySelection)
+	if err != nil {
+		msg := fmt.Sprintf("Failed to query CNS volume: %s with error: %+v", volumeID, err)
+		log.Error(msg)
+		setInstanceError(ctx, r, instance, msg)
+		return reconcile.Result{RequeueAfter: timeout}, nil
+	}
+	if instance.Status.SourceStoragePolicyID == "" {
+		instance.Status.SourceStoragePolicyID = volume.StoragePolicyId
+	}
+
+	if volume.StoragePolicyId != targetStoragePolicyID {
+		instance.Status.Phase = migrationv1alpha1.MigrationPhaseReconfiguring
+		if err := updateCnsVolumePolicyMigration(ctx, r.client, instance); err != nil {
+			return reconcile.Result{RequeueAfter: timeout}, nil
+		}
+		log.Infof("Changing storage policy of volume %s from %q to %q", volumeID, volume.StoragePolicyId,
+			targetStoragePolicyID)
+		if err := r.volumeManager.ReconfigVolumePolicy(ctx, volumeID, targetStoragePolicyID); err != nil {
+			msg := fmt.Sprintf("Failed to change storage policy of volume %s to %q. Error: %+v",
+				volumeID, instance.Spec.StoragePolicyName, err)
+			log.Error(msg)
+			setInstanceError(ctx, r, instance, msg)
+			return reconcile.Result{RequeueAfter: timeout}, nil
+		}
+		// The compliance of the volume is checked on the next reconcile, once
+		// vSAN started to resync the volume.
+		instance.Status.Phase = migrationv1alpha1.MigrationPhaseWaitingForCompliance
+		instance.Status.ComplianceStatus = ""
+		instance.Status.Error = ""
+		if err := updateCnsVolumePolicyMigration(ctx, r.client, instance); err != nil {
+			return reconcile.Result{RequeueAfter: timeout}, nil
+		}
+		recordEvent(ctx, r, instance, v1.EventTypeNormal, "PolicyReconfigured",
+			fmt.Sprintf("Changed storage policy of volume %s to %q, waiting for compliance",
+				volumeID, instance.Spec.StoragePolicyName))
+		return reconcile.Result{RequeueAfter: complianceCheckInterval}, nil
+	}
+
+	previousComplianceStatus := instance.Status.ComplianceStatus
+	instance.Status.ComplianceStatus = volume.ComplianceStatus
+	instance.Status.Error = ""
+	if volume.ComplianceStatus == string(pbmtypes.PbmComplianceStatusNonCompliant) &&
+		previousComplianceStatus != volume.ComplianceStatus {
+		// The event is annotated with the volume ID, so that it can be
+		// reported to vCenter on the volume.
+		r.recorder.AnnotatedEventf(instance, map[string]string{common.AnnEventVolumeID: volumeID},
+			v1.EventTypeWarning, common.EventReasonPolicyNoncompliant,
+			"Volume %s of PVC %s/%s is noncompliant with storage policy %q", volumeID, instance.Namespace,
+			instance.Spec.PvcName, instance.Spec.StoragePolicyName)
+	}
+	if volume.ComplianceStatus != string(pbmtypes.PbmComplianceStatusCompliant) {
+		log.Infof("Volume %s is %q with storage policy %q, checking again in %v", volumeID,
+			volume.ComplianceStatus, instance.Spec.StoragePolicyName, complianceCheckInterval)
+		instance.Status.Phase = migrationv1alpha1.MigrationPhaseWaitingForCompliance
+		if err := updateCnsVolumePolicyMigration(ctx, r.client, instance); err != nil {
+			return reconcile.Result{RequeueAfter: timeout}, nil
+		}
+		return reconcile.Result{RequeueAfter: complianceCheckInterval}, nil
+	}
+	instance.Status.Phase = migrationv1alpha1.MigrationPhaseCompleted
+	if err := updateCnsVolumePolicyMigration(ctx, r.client, instance); err != nil {
+		return reconcile.Result{RequeueAfter: timeout}, nil
+	}
+	msg := fmt.Sprintf("Volume %s of PVC %s/%s is compliant with storage policy %q", volumeID,
+		instance.Namespace, instance.Spec.PvcName, instance.Spec.StoragePolicyName)
+	log.Info(msg)
+	recordEvent(ctx, r, instance, v1.EventTypeNormal, "CnsVolumePolicyMigrationSucceeded", msg)
+	backOffDurationMapMutex.Lock()
+	delete(backOffDuration, instance.Name)
+	backOffDurationMapMutex.Unlock()
+	return reconcile.Result{}, nil
+}
+
+// getVolumeID returns the ID of the volume bound to the PVC of the
+// CnsVolumePolicyMigration instance.
+func (r *ReconcileCnsVolumePolicyMigration) getVolumeID(ctx context.Context,
+	instance *migrationv1alpha1.CnsVolumePolicyMigration) (string, error) {
+	pvc, err := r.k8sclient.CoreV1().PersistentVolumeClaims(instance.Namespace).Get(ctx,
+		instance.Spec.PvcName, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get PVC: %s on namespace: %s. Error: %+v",
+			instance.Spec.PvcName, instance.Namespace, err)
+	}
+	if pvc.Status.Phase != v1.ClaimBound || pvc.Spec.VolumeName == "" {
+		return "", fmt.Errorf("PVC: %s on namespace: %s is not bound", pvc.Name, pvc.Namespace)
+	}
+	pv, err := r.k8sclient.CoreV1().PersistentVolumes().Get(ctx, pvc.Spec.VolumeName, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get PV: %s. Error: %+v", pvc.Spec.VolumeName, err)
+	}
+	if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != csitypes.DriverName() {
+		return "", fmt.Errorf("PV: %s is not provisioned by %q", pv.Name, csitypes.DriverName())
+	}
+	return pv.Spec.CSI.VolumeHandle, nil
+}
+
+// validateCnsVolumePolicyMigrationSpec validates the input params of a
+// CnsVolumePolicyMigration instance.
+func validateCnsVolumePolicyMigrationSpec(instance *migrationv1alpha1.CnsVolumePolicyMigration) error {
+	if instance.Spec.PvcName == "" || instance.Spec.StoragePolicyName == "" {
+		return errors.New("PvcName and StoragePolicyName must be specified")
+	}
+	return nil
+}
+
+// setInstanceError sets error and records an event on the
+// CnsVolumePolicyMigration instance.
+func setInstanceError(ctx context.Context, r *ReconcileCnsVolumePolicyMigration,
+	instance *migrationv1alpha1.CnsVolumePolicyMigration, errMsg string) {
+	log := logger.GetLogger(ctx)
+	instance.Status.Error = errMsg
+	err := updateCnsVolumePolicyMigration(ctx, r.client, instance)
+	if err != nil {
+		log.Errorf("updateCnsVolumePolicyMigration failed. err: %v", err)
+	}
+	recordEvent(ctx, r, instance, v1.EventTypeWarning, "CnsVolumePolicyMigrationFailed", errMsg)
+}
+
+// setInstanceFailed marks the CnsVolumePolicyMigration instance as failed,
+// so that it is not retried.
+func setInstanceFailed(ctx context.Context, r *ReconcileCnsVolumePolicyMigration,
+	instance *migrationv1alpha1.CnsVolumePolicyMigration, errMsg string) {
+	instance.Status.Phase = migrationv1alpha1.MigrationPhaseFailed
+	setInstanceError(ctx, r, instance, errMsg)
+}
+
+// recordEvent records the event, sets the backOffDuration for the instance
+// appropriately and logs the message.
+// backOffDuration is reset to 1 second on success and doubled on failure.
+func recordEvent(ctx context.Context, r *ReconcileCnsVolumePolicyMigration,
+	instance *migrationv1alpha1.CnsVolumePolicyMigration, eventtype string, reason string, msg string) {
+	log := logger.GetLogger(ctx)
+	log.Debugf("Event type is %s", eventtype)
+	switch eventtype {
+	case v1.EventTypeWarning:
+		// Double backOff duration.
+		backOffDurationMapMutex.Lock()
+		backOffDuration[instance.Name] = backOffDuration[instance.Name] * 2
+		r.recorder.Event(instance, v1.EventTypeWarning, reason, msg)
+		backOffDurationMapMutex.Unlock()
+	case v1.EventTypeNormal:
+		// Reset backOff duration to one second.
+		backOffDurationMapMutex.Lock()
+		backOffDuration[instance.Name] = time.Second
+		r.recorder.Event(instance, v1.EventTypeNormal, reason, msg)
+		backOffDurationMapMutex.Unlock()
+	}
+}
+
+// updateCnsVolumePolicyMigration updates the CnsVolumePolicyMigration instance
+// in K8S.
+func updateCnsVolumePolicyMigration(ctx context.Context, client client.Client,
+	instance *migrationv1alpha1.CnsVolumePolicyMigration) error {
+	log := logger.GetLogger(ctx)
+	err := client.Update(ctx, instance)
+	if err != nil {
+		log.Errorf("Failed to update CnsVolumePolicyMigration instance: %q on namespace: %q. Error: %+v",
+			instance.Name, instance.Namespace, err)
+	}
+	return err
+}
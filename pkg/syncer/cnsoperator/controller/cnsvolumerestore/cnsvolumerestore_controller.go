@@ -0,0 +1,572 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cnsvolumerestore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	vim25types "github.com/vmware/govmomi/vim25/types"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	apis "sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/cnsoperator"
+	volumes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+	cnsvolumerestorev1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsoperator/cnsvolumerestore/v1alpha1"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/syncer"
+)
+
+const (
+	defaultMaxWorkerThreadsForVolumeRestore = 4
+	restoredPvNamePrefix                    = "restored-pv-"
+)
+
+// backOffDuration is a map of cnsvolumerestore name's to the time after which
+// a request for this instance will be requeued. Initialized to 1 second for
+// new instances and for instances whose latest reconcile operation succeeded.
+// If the reconcile fails, backoff is incremented exponentially.
+var (
+	backOffDuration         map[string]time.Duration
+	backOffDurationMapMutex = sync.Mutex{}
+)
+
+// Add creates a new CnsVolumeRestore Controller and adds it to the Manager,
+// ConfigurationInfo and VirtualCenterTypes. The Manager will set fields on the
+// Controller and start it when the Manager is Started.
+func Add(mgr manager.Manager, clusterFlavor cnstypes.CnsClusterFlavor,
+	configInfo *config.ConfigurationInfo, volumeManager volumes.Manager) error {
+	ctx, log := logger.GetNewContextWithLogger()
+	if clusterFlavor != cnstypes.CnsClusterFlavorVanilla {
+		log.Debug("Not initializing the CnsVolumeRestore Controller as its a non-Vanilla CSI deployment")
+		return nil
+	}
+
+	coCommonInterface, err := commonco.GetContainerOrchestratorInterface(ctx,
+		common.Kubernetes, clusterFlavor, &syncer.COInitParams)
+	if err != nil {
+		log.Errorf("failed to create CO agnostic interface. Err: %v", err)
+		return err
+	}
+	if !coCommonInterface.IsFSSEnabled(ctx, common.ArchiveReclaim) {
+		log.Infof("Not initializing the CnsVolumeRestore Controller as %q feature is disabled on the cluster",
+			common.ArchiveReclaim)
+		return nil
+	}
+	if coCommonInterface.IsFSSEnabled(ctx, common.MultiVCenterCSITopology) && len(configInfo.Cfg.VirtualCenter) > 1 {
+		log.Infof("Not initializing the CnsVolumeRestore Controller as it is a multi VC deployment.")
+		return nil
+	}
+
+	// Initializes kubernetes client.
+	k8sclient, err := k8s.NewClient(ctx)
+	if err != nil {
+		log.Errorf("Creating Kubernetes client failed. Err: %v", err)
+		return err
+	}
+
+	// eventBroadcaster broadcasts events on cnsvolumerestore instances to the
+	// event sink.
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartRecordingToSink(
+		&typedcorev1.EventSinkImpl{
+			Interface: k8sclient.CoreV1().Events(""),
+		},
+	)
+	recorder := eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: apis.GroupName})
+	return add(mgr, newReconciler(mgr, configInfo, volumeManager, k8sclient, recorder))
+}
+
+// newReconciler returns a new reconcile.Reconciler.
+func newReconciler(mgr manager.Manager, configInfo *config.ConfigurationInfo, volumeManager volumes.Manager,
+	k8sclient clientset.Interface, recorder record.EventRecorder) reconcile.Reconciler {
+	return &ReconcileCnsVolumeRestore{client: mgr.GetClient(), scheme: mgr.GetScheme(), configInfo: configInfo,
+		volumeManager: volumeManager, k8sclient: k8sclient, recorder: recorder}
+}
+
+// add adds a new Controller to mgr with r as the reconcile.Reconciler.
+func add(mgr manager.Manager, r reconcile.Reconciler) error {
+	ctx, log := logger.GetNewContextWithLogger()
+
+	// Create a new controller.
+	c, err := controller.New("cnsvolumerestore-controller", mgr,
+		controller.Options{Reconciler: r, MaxConcurrentReconciles: defaultMaxWorkerThreadsForVolumeRestore})
+	if err != nil {
+		log.Errorf("Failed to create new CnsVolumeRestore controller with error: %+v", err)
+		return err
+	}
+
+	backOffDuration = make(map[string]time.Duration)
+
+	// Watch for changes to primary resource CnsVolumeRestore.
+	err = c.Watch(source.Kind(mgr.GetCache(), &cnsvolumerestorev1alpha1.CnsVolumeRestore{}),
+		&handler.EnqueueRequestForObject{})
+	if err != nil {
+		log.Errorf("Failed to watch for changes to CnsVolumeRestore resource with error: %+v", err)
+		return err
+	}
+	return nil
+}
+
+// blank assignment to verify that ReconcileCnsVolumeRestore implements
+// reconcile.Reconciler.
+var _ reconcile.Reconciler = &ReconcileCnsVolumeRestore{}
+
+// ReconcileCnsVolumeRestore reconciles a CnsVolumeRestore object.
+type ReconcileCnsVolumeRestore struct {
+	// This client, initialized using mgr.Client() above, is a split client
+	// that reads objects from the cache and writes to the apiserver.
+	client        client.Client
+	scheme        *runtime.Scheme
+	configInfo    *config.ConfigurationInfo
+	volumeManager volumes.Manager
+	k8sclient     clientset.Interface
+	recorder      record.EventRecorder
+}
+
+// Reconcile reads that state of the cluster for a CnsVolumeRestore object and
+// restores the archived volume in CnsVolumeRestore.Spec. The backing disk is
+// registered back with CNS using the storage policy recorded at archival, and
+// a PV and PVC are created for it with the recorded filesystem type and PVC
+// labels. The syncer then pushes the PV and PVC
+// metadata of the restored volume to CNS, as for any statically provisioned
+// volume.
+// Note:
+// The Controller will requeue the Request to be processed again if the returned
+// error is non-nil or Result.Requeue is true. Otherwise, upon completion it
+// will remove the work from the queue.
+func (r *ReconcileCnsVolumeRestore) Reconcile(ctx context.Context,
+	request reconcile.Request) (reconcile.Result, error) {
+	log := logger.GetLogger(ctx)
+	// Fetch the CnsVolumeRestore instance.
+	instance := &cnsvolumerestorev1alpha1.CnsVolumeRestore{}
+	err := r.client.Get(ctx, request.NamespacedName, instance)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Infof("CnsVolumeRestore resource not found. Ignoring since object must be deleted.")
+			return reconcile.Result{}, nil
+		}
+		log.Errorf("Error reading the CnsVolumeRestore with name: %q on namespace: %q. Err: %+v",
+			request.Name, request.Namespace, err)
+		// Error reading the object - return with err.
+		return reconcile.Result{}, err
+	}
+	if instance.Status.Restored {
+		backOffDurationMapMutex.Lock()
+		delete(backOffDuration, instance.Name)
+		backOffDurationMapMutex.Unlock()
+		return reconcile.Result{}, nil
+	}
+	// Initialize backOffDuration for the instance, if required.
+	backOffDurationMapMutex.Lock()
+	var timeout time.Duration
+	if _, exists := backOffDuration[instance.Name]; !exists {
+		backOffDuration[instance.Name] = time.Second
+	}
+	timeout = backOffDuration[instance.Name]
+	backOffDurationMapMutex.Unlock()
+
+	log.Infof("Reconciling CnsVolumeRestore with instance: %q from namespace: %q",
+		instance.Name, instance.Namespace)
+	if err := validateCnsVolumeRestoreSpec(instance); err != nil {
+		log.Error(err.Error())
+		setInstanceError(ctx, r, instance, err.Error())
+		return reconcile.Result{}, nil
+	}
+	sc, err := r.k8sclient.StorageV1().StorageClasses().Get(ctx, instance.Spec.StorageClassName,
+		metav1.GetOptions{})
+	if err != nil {
+		msg := fmt.Sprintf("Failed to get storage class %q. Error: %+v", instance.Spec.StorageClassName, err)
+		log.Error(msg)
+		setInstanceError(ctx, r, instance, msg)
+		return reconcile.Result{RequeueAfter: timeout}, nil
+	}
+	if sc.Provisioner != csitypes.DriverName() {
+		msg := fmt.Sprintf("Storage class %q is not provisioned by %q", sc.Name, csitypes.DriverName())
+		log.Error(msg)
+		setInstanceError(ctx, r, instance, msg)
+		return reconcile.Result{}, nil
+	}
+
+	volumeID := instance.Spec.VolumeID
+	volume, err := r.registerArchivedVolume(ctx, instance)
+	if err != nil {
+		log.Error(err.Error())
+		setInstanceError(ctx, r, instance, err.Error())
+		return reconcile.Result{RequeueAfter: timeout}, nil
+	}
+
+	fsType, pvcLabels, err := r.getArchivedVolumeAttributes(ctx, volumeID)
+	if err != nil {
+		log.Error(err.Error())
+		setInstanceError(ctx, r, instance, err.Error())
+		return reconcile.Result{RequeueAfter: timeout}, nil
+	}
+
+	accessMode := instance.Spec.AccessMode
+	if accessMode == "" {
+		accessMode = v1.ReadWriteOnce
+	}
+	capacityInMb := volume.BackingObjectDetails.GetCnsBackingObjectDetails().CapacityInMb
+	pvName := restoredPvNamePrefix + volumeID
+	_, err = r.k8sclient.CoreV1().PersistentVolumes().Get(ctx, pvName, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			msg := fmt.Sprintf("Failed to get PV: %s with error: %+v", pvName, err)
+			log.Error(msg)
+			setInstanceError(ctx, r, instance, msg)
+			return reconcile.Result{RequeueAfter: timeout}, nil
+		}
+		claimRef := &v1.ObjectReference{
+			Kind:       "PersistentVolumeClaim",
+			APIVersion: "v1",
+			Namespace:  instance.Namespace,
+			Name:       instance.Spec.PvcName,
+		}
+		pvSpec := getPersistentVolumeSpec(pvName, volumeID, capacityInMb, accessMode, sc.Name, fsType,
+			claimRef)
+		if _, err = r.k8sclient.CoreV1().PersistentVolumes().Create(ctx, pvSpec,
+			metav1.CreateOptions{}); err != nil {
+			msg := fmt.Sprintf("Failed to create PV: %s for volume %s with error: %+v", pvName, volumeID, err)
+			log.Error(msg)
+			setInstanceError(ctx, r, instance, msg)
+			return reconcile.Result{RequeueAfter: timeout}, nil
+		}
+		log.Infof("PV: %s is created successfully", pvName)
+	}
+
+	pvcSpec := getPersistentVolumeClaimSpec(instance.Spec.PvcName, instance.Namespace, capacityInMb,
+		sc.Name, accessMode, pvName, pvcLabels)
+	pvc, err := r.k8sclient.CoreV1().PersistentVolumeClaims(instance.Namespace).Create(ctx, pvcSpec,
+		metav1.CreateOptions{})
+	if err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			msg := fmt.Sprintf("Failed to create PVC: %s with error: %+v", instance.Spec.PvcName, err)
+			log.Error(msg)
+			setInstanceError(ctx, r, instance, msg)
+			return reconcile.Result{RequeueAfter: timeout}, nil
+		}
+		pvc, err = r.k8sclient.CoreV1().PersistentVolumeClaims(instance.Namespace).Get(ctx,
+			instance.Spec.PvcName, metav1.GetOptions{})
+		if err != nil {
+			msg := fmt.Sprintf("Failed to get PVC: %s on namespace: %s", instance.Spec.PvcName, instance.Namespace)
+			log.Error(msg)
+			setInstanceError(ctx, r, instance, msg)
+			return reconcile.Result{RequeueAfter: timeout}, nil
+		}
+		if pvc.Spec.VolumeName != pvName {
+			msg := fmt.Sprintf("Another PVC: %s already exists in namespace: %s which is not bound to PV: %s",
+				instance.Spec.PvcName, instance.Namespace, pvName)
+			log.Error(msg)
+			setInstanceError(ctx, r, instance, msg)
+			return reconcile.Result{RequeueAfter: timeout}, nil
+		}
+	}
+	log.Infof("PVC: %s is created for restored volume %s", pvc.Name, volumeID)
+
+	instance.Status.Restored = true
+	instance.Status.PvName = pvName
+	instance.Status.Error = ""
+	if err := updateCnsVolumeRestore(ctx, r.client, instance); err != nil {
+		return reconcile.Result{RequeueAfter: timeout}, nil
+	}
+	msg := fmt.Sprintf("Restored volume %s as PVC %s/%s", volumeID, instance.Namespace, pvc.Name)
+	log.Info(msg)
+	recordEvent(ctx, r, instance, v1.EventTypeNormal, msg)
+	backOffDurationMapMutex.Lock()
+	delete(backOffDuration, instance.Name)
+	backOffDurationMapMutex.Unlock()
+	return reconcile.Result{}, nil
+}
+
+// registerArchivedVolume registers the backing disk of the archived volume
+// with CNS and returns the CNS volume. Archived disks are only restored
+// within the retention period, and the archival metadata is replaced by the
+// instance restoring the volume so that the syncer doesn't purge the disk and
+// the registration is idempotent across retries.
+func (r *ReconcileCnsVolumeRestore) registerArchivedVolume(ctx context.Context,
+	instance *cnsvolumerestorev1alpha1.CnsVolumeRestore) (*cnstypes.CnsVolume, error) {
+	log := logger.GetLogger(ctx)
+	volumeID := instance.Spec.VolumeID
+	restoredBy := instance.Namespace + "/" + instance.Name
+	querySelection := &cnstypes.CnsQuerySelection{
+		Names: []string{
+			string(cnstypes.QuerySelectionNameTypeBackingObjectDetails),
+		},
+	}
+	volume, err := common.QueryVolumeByID(ctx, r.volumeManager, volumeID, querySelection)
+	if err == nil {
+		// The volume is already registered with CNS, either by a previous
+		// attempt of this instance or because it was never archived.
+		owner, err := r.volumeManager.RetrieveVStorageObjectMetadataValue(ctx, volumeID,
+			common.VStorageObjectMetadataRestoredBy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get metadata of volume %s. Error: %+v", volumeID, err)
+		}
+		if owner != restoredBy {
+			return nil, fmt.Errorf("volume %s is not archived", volumeID)
+		}
+		return volume, nil
+	}
+	if err.Error() != common.ErrNotFound.Error() {
+		return nil, fmt.Errorf("failed to query CNS volume: %s with error: %+v", volumeID, err)
+	}
+
+	archivedAt, err := r.volumeManager.RetrieveVStorageObjectMetadataValue(ctx, volumeID,
+		common.VStorageObjectMetadataArchivedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get archival time of volume %s. Error: %+v", volumeID, err)
+	}
+	if archivedAt == "" {
+		return nil, fmt.Errorf("volume %s is not archived", volumeID)
+	}
+	archivedTime, err := time.Parse(time.RFC3339, archivedAt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid archival time %q on volume %s", archivedAt, volumeID)
+	}
+	retention := time.Duration(r.configInfo.Cfg.Archive.RetentionInHours) * time.Hour
+	if time.Since(archivedTime) >= retention {
+		return nil, fmt.Errorf("volume %s archived at %s is past its retention period of %d hours",
+			volumeID, archivedAt, r.configInfo.Cfg.Archive.RetentionInHours)
+	}
+	storagePolicyID, err := r.volumeManager.RetrieveVStorageObjectMetadataValue(ctx, volumeID,
+		common.VStorageObjectMetadataStoragePolicyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get storage policy of volume %s. Error: %+v", volumeID, err)
+	}
+
+	createSpec := r.constructCreateSpec(volumeID, restoredPvNamePrefix+volumeID, storagePolicyID)
+	log.Debugf("CNS Volume create spec is: %+v", createSpec)
+	if _, _, err = r.volumeManager.CreateVolume(ctx, createSpec, nil); err != nil {
+		return nil, fmt.Errorf("failed to register volume %s with CNS. Error: %+v", volumeID, err)
+	}
+	log.Infof("Registered archived volume %s with CNS", volumeID)
+	err = r.volumeManager.UpdateVStorageObjectMetadata(ctx, volumeID,
+		map[string]string{common.VStorageObjectMetadataRestoredBy: restoredBy},
+		[]string{common.VStorageObjectMetadataArchivedAt, common.VStorageObjectMetadataStoragePolicyID})
+	if err != nil {
+		// Untag the CNS volume so that the disk remains archived.
+		if _, deleteErr := common.DeleteVolumeUtil(ctx, r.volumeManager, volumeID, false); deleteErr != nil {
+			log.Errorf("Failed to untag CNS volume: %s with error: %+v", volumeID, deleteErr)
+		}
+		return nil, fmt.Errorf("failed to clear archival metadata of volume %s. Error: %+v", volumeID, err)
+	}
+	volume, err = common.QueryVolumeByID(ctx, r.volumeManager, volumeID, querySelection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query CNS volume: %s with error: %+v", volumeID, err)
+	}
+	return volume, nil
+}
+
+// getArchivedVolumeAttributes returns the filesystem type and the PVC labels
+// recorded on the backing disk of the volume. The filesystem type defaults to
+// ext4 for volumes archived without one.
+func (r *ReconcileCnsVolumeRestore) getArchivedVolumeAttributes(ctx context.Context,
+	volumeID string) (string, map[string]string, error) {
+	fsType, err := r.volumeManager.RetrieveVStorageObjectMetadataValue(ctx, volumeID,
+		common.VStorageObjectMetadataFsType)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get filesystem type of volume %s. Error: %+v", volumeID, err)
+	}
+	if fsType == "" {
+		fsType = common.Ext4FsType
+	}
+	encodedLabels, err := r.volumeManager.RetrieveVStorageObjectMetadataValue(ctx, volumeID,
+		common.VStorageObjectMetadataPVCLabels)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get PVC labels of volume %s. Error: %+v", volumeID, err)
+	}
+	var pvcLabels map[string]string
+	if encodedLabels != "" {
+		if err := json.Unmarshal([]byte(encodedLabels), &pvcLabels); err != nil {
+			return "", nil, fmt.Errorf("invalid PVC labels %q on volume %s. Error: %+v", encodedLabels,
+				volumeID, err)
+		}
+	}
+	return fsType, pvcLabels, nil
+}
+
+// constructCreateSpec returns the spec registering the backing disk of an
+// archived volume with CNS under its original storage policy.
+func (r *ReconcileCnsVolumeRestore) constructCreateSpec(volumeID string, volumeName string,
+	storagePolicyID string) *cnstypes.CnsVolumeCreateSpec {
+	vcHost := r.configInfo.Cfg.Global.VCenterIP
+	var user string
+	if vcConfig, ok := r.configInfo.Cfg.VirtualCenter[vcHost]; ok {
+		user = vcConfig.User
+	}
+	createSpec := &cnstypes.CnsVolumeCreateSpec{
+		Name:       volumeName,
+		VolumeType: common.BlockVolumeType,
+		Metadata: cnstypes.CnsVolumeMetadata{
+			ContainerCluster: cnsvsphere.GetContainerCluster(r.configInfo.Cfg.Global.ClusterID, user,
+				cnstypes.CnsClusterFlavorVanilla, r.configInfo.Cfg.Global.ClusterDistribution),
+		},
+		BackingObjectDetails: &cnstypes.CnsBlockBackingDetails{
+			BackingDiskId: volumeID,
+		},
+	}
+	if storagePolicyID != "" {
+		createSpec.Profile = []vim25types.BaseVirtualMachineProfileSpec{
+			&vim25types.VirtualMachineDefinedProfileSpec{
+				ProfileId: storagePolicyID,
+			},
+		}
+	}
+	return createSpec
+}
+
+// validateCnsVolumeRestoreSpec validates the input params of a
+// CnsVolumeRestore instance.
+func validateCnsVolumeRestoreSpec(instance *cnsvolumerestorev1alpha1.CnsVolumeRestore) error {
+	if instance.Spec.VolumeID == "" || instance.Spec.PvcName == "" || instance.Spec.StorageClassName == "" {
+		return errors.New("VolumeID, PvcName and StorageClassName must be specified")
+	}
+	if instance.Spec.AccessMode != "" && instance.Spec.AccessMode != v1.ReadWriteOnce {
+		return fmt.Errorf("AccessMode: %s is not supported", instance.Spec.AccessMode)
+	}
+	return nil
+}
+
+// getPersistentVolumeSpec returns the spec of the PV for a restored volume.
+func getPersistentVolumeSpec(volumeName string, volumeID string, capacity int64,
+	accessMode v1.PersistentVolumeAccessMode, scName string, fsType string,
+	claimRef *v1.ObjectReference) *v1.PersistentVolume {
+	capacityInMb := strconv.FormatInt(capacity, 10) + "Mi"
+	return &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: volumeName,
+			Annotations: map[string]string{
+				"pv.kubernetes.io/provisioned-by": csitypes.DriverName(),
+			},
+		},
+		Spec: v1.PersistentVolumeSpec{
+			PersistentVolumeReclaimPolicy: v1.PersistentVolumeReclaimDelete,
+			Capacity: v1.ResourceList{
+				v1.ResourceStorage: resource.MustParse(capacityInMb),
+			},
+			PersistentVolumeSource: v1.PersistentVolumeSource{
+				CSI: &v1.CSIPersistentVolumeSource{
+					Driver:       csitypes.DriverName(),
+					VolumeHandle: volumeID,
+					FSType:       fsType,
+				},
+			},
+			AccessModes:      []v1.PersistentVolumeAccessMode{accessMode},
+			ClaimRef:         claimRef,
+			StorageClassName: scName,
+		},
+	}
+}
+
+// getPersistentVolumeClaimSpec returns the spec of the PVC bound to the PV
+// of a restored volume.
+func getPersistentVolumeClaimSpec(name string, namespace string, capacity int64,
+	storageClassName string, accessMode v1.PersistentVolumeAccessMode, pvName string,
+	labels map[string]string) *v1.PersistentVolumeClaim {
+	capacityInMb := strconv.FormatInt(capacity, 10) + "Mi"
+	return &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Spec: v1.PersistentVolumeClaimSpec{
+			AccessModes: []v1.PersistentVolumeAccessMode{accessMode},
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{
+					v1.ResourceStorage: resource.MustParse(capacityInMb),
+				},
+			},
+			StorageClassName: &storageClassName,
+			VolumeName:       pvName,
+		},
+	}
+}
+
+// setInstanceError sets error and records an event on the CnsVolumeRestore
+// instance.
+func setInstanceError(ctx context.Context, r *ReconcileCnsVolumeRestore,
+	instance *cnsvolumerestorev1alpha1.CnsVolumeRestore, errMsg string) {
+	log := logger.GetLogger(ctx)
+	instance.Status.Error = errMsg
+	err := updateCnsVolumeRestore(ctx, r.client, instance)
+	if err != nil {
+		log.Errorf("updateCnsVolumeRestore failed. err: %v", err)
+	}
+	recordEvent(ctx, r, instance, v1.EventTypeWarning, errMsg)
+}
+
+// recordEvent records the event, sets the backOffDuration for the instance
+// appropriately and logs the message.
+// backOffDuration is reset to 1 second on success and doubled on failure.
+func recordEvent(ctx context.Context, r *ReconcileCnsVolumeRestore,
+	instance *cnsvolumerestorev1alpha1.CnsVolumeRestore, eventtype string, msg string) {
+	log := logger.GetLogger(ctx)
+	log.Debugf("Event type is %s", eventtype)
+	switch eventtype {
+	case v1.EventTypeWarning:
+		// Double backOff duration.
+		backOffDurationMapMutex.Lock()
+		backOffDuration[instance.Name] = backOffDuration[instance.Name] * 2
+		r.recorder.Event(instance, v1.EventTypeWarning, "CnsVolumeRestoreFailed", msg)
+		backOffDurationMapMutex.Unlock()
+	case v1.EventTypeNormal:
+		// Reset backOff duration to one second.
+		backOffDurationMapMutex.Lock()
+		backOffDuration[instance.Name] = time.Second
+		r.recorder.Event(instance, v1.EventTypeNormal, "CnsVolumeRestoreSucceeded", msg)
+		backOffDurationMapMutex.Unlock()
+	}
+}
+
+// updateCnsVolumeRestore updates the CnsVolumeRestore instance in K8S.
+func updateCnsVolumeRestore(ctx context.Context, client client.Client,
+	instance *cnsvolumerestorev1alpha1.CnsVolumeRestore) error {
+	log := logger.GetLogger(ctx)
+	err := client.Update(ctx, instance)
+	if err != nil {
+		log.Errorf("Failed to update CnsVolumeRestore instance: %q on namespace: %q. Error: %+v",
+			instance.Name, instance.Namespace, err)
+	}
+	return err
+}
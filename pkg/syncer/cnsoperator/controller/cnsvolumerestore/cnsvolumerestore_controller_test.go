@@ -0,0 +1,291 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cnsvolumerestore
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/unittestcommon"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis"
+	cnsvolumerestorev1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsoperator/cnsvolumerestore/v1alpha1"
+)
+
+const (
+	testNamespace   = "test-ns"
+	testRestoreName = "test-restore"
+	testVolumeID    = "test-volume-id"
+	testPvcName     = "test-pvc"
+	testSCName      = "test-sc"
+)
+
+func newTestCnsVolumeRestore() *cnsvolumerestorev1alpha1.CnsVolumeRestore {
+	return &cnsvolumerestorev1alpha1.CnsVolumeRestore{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      testRestoreName,
+			Namespace: testNamespace,
+		},
+		Spec: cnsvolumerestorev1alpha1.CnsVolumeRestoreSpec{
+			VolumeID:         testVolumeID,
+			PvcName:          testPvcName,
+			StorageClassName: testSCName,
+		},
+	}
+}
+
+func newTestConfigInfo(retentionInHours int) *config.ConfigurationInfo {
+	return &config.ConfigurationInfo{
+		Cfg: &config.Config{
+			Archive: config.ArchiveConfig{RetentionInHours: retentionInHours},
+		},
+	}
+}
+
+func TestValidateCnsVolumeRestoreSpec(t *testing.T) {
+	tests := []struct {
+		name        string
+		update      func(instance *cnsvolumerestorev1alpha1.CnsVolumeRestore)
+		expectError bool
+	}{
+		{
+			name:   "Valid",
+			update: func(instance *cnsvolumerestorev1alpha1.CnsVolumeRestore) {},
+		},
+		{
+			name: "ReadWriteOnce",
+			update: func(instance *cnsvolumerestorev1alpha1.CnsVolumeRestore) {
+				instance.Spec.AccessMode = v1.ReadWriteOnce
+			},
+		},
+		{
+			name: "MissingVolumeID",
+			update: func(instance *cnsvolumerestorev1alpha1.CnsVolumeRestore) {
+				instance.Spec.VolumeID = ""
+			},
+			expectError: true,
+		},
+		{
+			name: "MissingPvcName",
+			update: func(instance *cnsvolumerestorev1alpha1.CnsVolumeRestore) {
+				instance.Spec.PvcName = ""
+			},
+			expectError: true,
+		},
+		{
+			name: "MissingStorageClassName",
+			update: func(instance *cnsvolumerestorev1alpha1.CnsVolumeRestore) {
+				instance.Spec.StorageClassName = ""
+			},
+			expectError: true,
+		},
+		{
+			name: "ReadWriteMany",
+			update: func(instance *cnsvolumerestorev1alpha1.CnsVolumeRestore) {
+				instance.Spec.AccessMode = v1.ReadWriteMany
+			},
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			instance := newTestCnsVolumeRestore()
+			test.update(instance)
+			err := validateCnsVolumeRestoreSpec(instance)
+			if test.expectError && err == nil {
+				t.Fatal("expected an error, got none")
+			}
+			if !test.expectError && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestRegisterArchivedVolume(t *testing.T) {
+	restoredBy := testNamespace + "/" + testRestoreName
+	tests := []struct {
+		name             string
+		registered       bool
+		metadata         map[string]string
+		expectedError    string
+		expectedMetadata map[string]string
+	}{
+		{
+			name: "WithinRetention",
+			metadata: map[string]string{
+				common.VStorageObjectMetadataArchivedAt:      time.Now().Add(-time.Hour).Format(time.RFC3339),
+				common.VStorageObjectMetadataStoragePolicyID: "policy-id",
+			},
+			expectedMetadata: map[string]string{common.VStorageObjectMetadataRestoredBy: restoredBy},
+		},
+		{
+			name: "RetentionExpired",
+			metadata: map[string]string{
+				common.VStorageObjectMetadataArchivedAt: time.Now().Add(-48 * time.Hour).Format(time.RFC3339),
+			},
+			expectedError: "past its retention period",
+		},
+		{
+			name:          "NotArchived",
+			metadata:      map[string]string{},
+			expectedError: "is not archived",
+		},
+		{
+			name:             "AlreadyRestoredByThisInstance",
+			registered:       true,
+			metadata:         map[string]string{common.VStorageObjectMetadataRestoredBy: restoredBy},
+			expectedMetadata: map[string]string{common.VStorageObjectMetadataRestoredBy: restoredBy},
+		},
+		{
+			name:          "AlreadyRestoredByAnotherInstance",
+			registered:    true,
+			metadata:      map[string]string{common.VStorageObjectMetadataRestoredBy: "other-ns/other-restore"},
+			expectedError: "is not archived",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			volumeManager := unittestcommon.NewFakeVolumeManager(map[string]map[string]string{
+				testVolumeID: test.metadata,
+			})
+			if test.registered {
+				volumeManager.Volumes[testVolumeID] = &cnstypes.CnsVolume{
+					VolumeId: cnstypes.CnsVolumeId{Id: testVolumeID},
+				}
+			}
+			r := &ReconcileCnsVolumeRestore{
+				configInfo:    newTestConfigInfo(24),
+				volumeManager: volumeManager,
+			}
+			volume, err := r.registerArchivedVolume(context.TODO(), newTestCnsVolumeRestore())
+			if test.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+					t.Fatalf("expected error containing %q, got: %v", test.expectedError, err)
+				}
+				if !test.registered && volumeManager.Volumes[testVolumeID] != nil {
+					t.Fatal("volume was registered with CNS")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if volume == nil || volume.VolumeId.Id != testVolumeID {
+				t.Fatalf("unexpected volume: %+v", volume)
+			}
+			if len(volumeManager.Metadata[testVolumeID]) != len(test.expectedMetadata) {
+				t.Fatalf("expected metadata %v, got %v", test.expectedMetadata, volumeManager.Metadata[testVolumeID])
+			}
+			for key, value := range test.expectedMetadata {
+				if volumeManager.Metadata[testVolumeID][key] != value {
+					t.Fatalf("expected metadata %v, got %v", test.expectedMetadata,
+						volumeManager.Metadata[testVolumeID])
+				}
+			}
+		})
+	}
+}
+
+func TestReconcilePVCBoundToAnotherPV(t *testing.T) {
+	instance := newTestCnsVolumeRestore()
+	s := scheme.Scheme
+	s.AddKnownTypes(internalapis.SchemeGroupVersion, instance)
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(s).
+		WithRuntimeObjects(instance).
+		Build()
+
+	sc := &storagev1.StorageClass{
+		ObjectMeta:  metav1.ObjectMeta{Name: testSCName},
+		Provisioner: csitypes.DriverName(),
+	}
+	pvc := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: testPvcName, Namespace: testNamespace},
+		Spec: v1.PersistentVolumeClaimSpec{
+			StorageClassName: &sc.Name,
+			VolumeName:       "other-pv",
+		},
+	}
+	k8sclient := k8sfake.NewSimpleClientset(sc, pvc)
+
+	volumeManager := unittestcommon.NewFakeVolumeManager(map[string]map[string]string{
+		testVolumeID: {common.VStorageObjectMetadataRestoredBy: testNamespace + "/" + testRestoreName},
+	})
+	volumeManager.Volumes[testVolumeID] = &cnstypes.CnsVolume{
+		VolumeId: cnstypes.CnsVolumeId{Id: testVolumeID},
+		BackingObjectDetails: &cnstypes.CnsBlockBackingDetails{
+			CnsBackingObjectDetails: cnstypes.CnsBackingObjectDetails{CapacityInMb: 1024},
+			BackingDiskId:           testVolumeID,
+		},
+	}
+
+	r := &ReconcileCnsVolumeRestore{
+		client:        fakeClient,
+		scheme:        s,
+		configInfo:    newTestConfigInfo(24),
+		volumeManager: volumeManager,
+		k8sclient:     k8sclient,
+		recorder:      record.NewFakeRecorder(1024),
+	}
+	backOffDuration = make(map[string]time.Duration)
+
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{Name: testRestoreName, Namespace: testNamespace},
+	}
+	res, err := r.Reconcile(context.TODO(), req)
+	if err != nil {
+		t.Fatalf("unexpected reconcile error: %v", err)
+	}
+	if res.RequeueAfter != time.Second {
+		t.Fatalf("expected requeue after %v, got %+v", time.Second, res)
+	}
+
+	updated := &cnsvolumerestorev1alpha1.CnsVolumeRestore{}
+	if err := r.client.Get(context.TODO(), req.NamespacedName, updated); err != nil {
+		t.Fatalf("get CnsVolumeRestore: %v", err)
+	}
+	if updated.Status.Restored {
+		t.Fatal("CnsVolumeRestore is marked restored")
+	}
+	if !strings.Contains(updated.Status.Error, "which is not bound to PV") {
+		t.Fatalf("unexpected error in status: %q", updated.Status.Error)
+	}
+	existing, err := k8sclient.CoreV1().PersistentVolumeClaims(testNamespace).Get(context.TODO(), testPvcName,
+		metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get PVC: %v", err)
+	}
+	if existing.Spec.VolumeName != "other-pv" {
+		t.Fatalf("existing PVC was modified: %+v", existing.Spec)
+	}
+}
@@ -48,6 +48,7 @@ import (
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/csidriverconfig"
 	csinodetopologyv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/csinodetopology/v1alpha1"
 	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/syncer"
@@ -241,6 +242,10 @@ func (r *ReconcileCSINodeTopology) reconcileForVanilla(ctx context.Context, requ
 		// Error reading the object - return with err.
 		return reconcile.Result{}, err
 	}
+	// Instances of a driver installed side by side are reconciled by its own syncer.
+	if !common.IsOwnCSINodeTopology(*instance) {
+		return reconcile.Result{}, nil
+	}
 	// If the CR status is already at Success, do not reconcile further.
 	if instance.Status.Status == csinodetopologyv1alpha1.CSINodeTopologySuccess {
 		log.Infof("CSINodeTopology instance with name %q is already at %q state. No need to "+
@@ -301,7 +306,7 @@ func (r *ReconcileCSINodeTopology) reconcileForVanilla(ctx context.Context, requ
 		if err != nil {
 			return reconcile.Result{RequeueAfter: timeout}, nil
 		}
-	} else if r.configInfo.Cfg.Labels.TopologyCategories != "" ||
+	} else if len(csidriverconfig.TopologyCategories()) != 0 || r.configInfo.Cfg.Labels.TopologyCategories != "" ||
 		(r.configInfo.Cfg.Labels.Zone != "" && r.configInfo.Cfg.Labels.Region != "") {
 		log.Infof("Detected a topology aware cluster")
 
@@ -349,7 +354,7 @@ func (r *ReconcileCSINodeTopology) reconcileForVanilla(ctx context.Context, requ
 // isTopologyEnabled checks if topology of cluster should be updated.
 // if cluster is not topology aware return false.
 func (r *ReconcileCSINodeTopology) isTopologyEnabled() bool {
-	if r.configInfo.Cfg.Labels.TopologyCategories == "" &&
+	if len(csidriverconfig.TopologyCategories()) == 0 && r.configInfo.Cfg.Labels.TopologyCategories == "" &&
 		r.configInfo.Cfg.Labels.Zone == "" && r.configInfo.Cfg.Labels.Region == "" {
 		return false
 	}
@@ -374,6 +379,10 @@ func (r *ReconcileCSINodeTopology) reconcileForGuest(ctx context.Context, reques
 		// Error reading the object - return with err.
 		return reconcile.Result{}, err
 	}
+	// Instances of a driver installed side by side are reconciled by its own syncer.
+	if !common.IsOwnCSINodeTopology(*instance) {
+		return reconcile.Result{}, nil
+	}
 	// TODO: If the CR status is already at Success, do not reconcile further.
 	// This is required only when NodeVM moves from one AZ to another AZ,
 	// otherwise there is no functional impact.
@@ -517,12 +526,19 @@ func getNodeTopologyInfo(ctx context.Context, nodeVM *cnsvsphere.VirtualMachine,
 	}()
 
 	// Create a map of TopologyCategories with category as key and value as empty string.
+	// The topology categories of the CSIDriverConfig take precedence over the vSphere config secret.
 	var isZoneRegion bool
 	topologyCategoriesMap := make(map[string]string)
+	categoryLabels := make(map[string]string)
 
 	zoneCat := strings.TrimSpace(cfg.Labels.Zone)
 	regionCat := strings.TrimSpace(cfg.Labels.Region)
-	if strings.TrimSpace(cfg.Labels.TopologyCategories) != "" {
+	if crCategories := csidriverconfig.TopologyCategories(); len(crCategories) != 0 {
+		for _, category := range crCategories {
+			topologyCategoriesMap[category.Name] = ""
+			categoryLabels[category.Name] = category.Label
+		}
+	} else if strings.TrimSpace(cfg.Labels.TopologyCategories) != "" {
 		categories := strings.Split(cfg.Labels.TopologyCategories, ",")
 		for _, cat := range categories {
 			topologyCategoriesMap[strings.TrimSpace(cat)] = ""
@@ -575,10 +591,29 @@ func getNodeTopologyInfo(ctx context.Context, nodeVM *cnsvsphere.VirtualMachine,
 		}
 	} else {
 		// Prefix user-defined topology labels with TopologyLabelsDomain name to distinctly
-		// identify the topology labels on the kubernetes node object added by our driver.
+		// identify the topology labels on the kubernetes node object added by our driver,
+		// unless the CSIDriverConfig sets the label of the category.
 		for key, val := range topologyCategoriesMap {
-			topologyLabels = append(topologyLabels,
-				csinodetopologyv1alpha1.TopologyLabel{Key: common.TopologyLabelsDomain + "/" + key, Value: val})
+			label, exists := categoryLabels[key]
+			if !exists {
+				label = common.TopologyLabelsDomain + "/" + key
+			}
+			topologyLabels = append(topologyLabels, csinodetopologyv1alpha1.TopologyLabel{Key: label, Value: val})
+		}
+		if cfg.Labels.HostTopology || cfg.Labels.ClusterTopology {
+			hostName, clusterName, err := nodeVM.GetHostAndClusterName(ctx)
+			if err != nil {
+				return nil, logger.LogNewErrorf(log, "failed to get host and cluster of nodeVM: %v. Error: %v",
+					nodeVM.Reference(), err)
+			}
+			if cfg.Labels.ClusterTopology && clusterName != "" {
+				topologyLabels = append(topologyLabels,
+					csinodetopologyv1alpha1.TopologyLabel{Key: common.TopologyLabelVSphereCluster, Value: clusterName})
+			}
+			if cfg.Labels.HostTopology {
+				topologyLabels = append(topologyLabels,
+					csinodetopologyv1alpha1.TopologyLabel{Key: common.TopologyLabelEsxiHost, Value: hostName})
+			}
 		}
 	}
 	return topologyLabels, nil
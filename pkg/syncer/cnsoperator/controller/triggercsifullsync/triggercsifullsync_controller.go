@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -45,6 +46,7 @@ import (
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
 	triggercsifullsyncv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsoperator/triggercsifullsync/v1alpha1"
 	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/syncer"
@@ -189,10 +191,16 @@ func (r *ReconcileTriggerCsiFullSync) Reconcile(ctx context.Context,
 	backOffDurationMapMutex.Unlock()
 
 	// Ignore TriggerCsiFullSync instances other than reserved
-	// "csifullsync" TriggerCsiFullSync instance.
-	if instance.Name != common.TriggerCsiFullSyncCRName {
+	// "csifullsync" TriggerCsiFullSync instance. The instances of drivers
+	// installed side by side are suffixed with their driver name.
+	triggerCsiFullSyncCRName := csitypes.InstanceObjectName(common.TriggerCsiFullSyncCRName)
+	if instance.Name != triggerCsiFullSyncCRName {
+		if instance.Name == common.TriggerCsiFullSyncCRName ||
+			strings.HasPrefix(instance.Name, common.TriggerCsiFullSyncCRName+".") {
+			return reconcile.Result{}, nil
+		}
 		msg := fmt.Sprintf("Only %q should be used to trigger full sync and not %q",
-			common.TriggerCsiFullSyncCRName, instance.Name)
+			triggerCsiFullSyncCRName, instance.Name)
 		log.Error(msg)
 		recordEvent(ctx, r, instance, v1.EventTypeWarning, msg)
 		return reconcile.Result{}, nil
@@ -214,6 +222,19 @@ func (r *ReconcileTriggerCsiFullSync) Reconcile(ctx context.Context,
 		return reconcile.Result{}, nil
 	}
 
+	if !syncer.SyncerSubsystems.MetadataSync {
+		// The metadata syncer runs in a dedicated deployment.
+		msg := fmt.Sprintf("Metadata syncer is not running in this container. Ignoring TriggerSyncID: %d",
+			instance.Spec.TriggerSyncID)
+		log.Warn(msg)
+		recordEvent(ctx, r, instance, v1.EventTypeWarning, msg)
+		return reconcile.Result{}, nil
+	}
+	if syncer.MetadataSyncer == nil {
+		log.Info("Metadata syncer is not initialized yet. Requeueing request.")
+		return reconcile.Result{RequeueAfter: timeout}, nil
+	}
+
 	// If the TriggerCsiFullSync instance is already in progress, update
 	// LastTriggerSyncID and raise an event that full sync is already in progress.
 	if instance.Status.InProgress && instance.Spec.TriggerSyncID == instance.Status.LastTriggerSyncID+1 {
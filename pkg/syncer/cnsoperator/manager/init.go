@@ -40,6 +40,7 @@ import (
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis"
 	internalapiscnsoperatorconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsoperator/config"
 	triggercsifullsyncv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsoperator/triggercsifullsync/v1alpha1"
@@ -216,6 +217,46 @@ func InitCnsOperator(ctx context.Context, clusterFlavor cnstypes.CnsClusterFlavo
 			log.Errorf("Failed to create %q CRD. Error: %+v", csinodetopology.CRDSingular, err)
 			return err
 		}
+		if cnsOperator.coCommonInterface.IsFSSEnabled(ctx, common.ArchiveReclaim) {
+			// Create CnsVolumeRestore CRD to restore archived volumes.
+			err = k8s.CreateCustomResourceDefinitionFromManifest(ctx,
+				internalapiscnsoperatorconfig.EmbedCnsVolumeRestoreFile,
+				internalapiscnsoperatorconfig.EmbedCnsVolumeRestoreFileName)
+			if err != nil {
+				log.Errorf("Failed to create %q CRD. Err: %+v", internalapis.CnsVolumeRestorePlural, err)
+				return err
+			}
+		}
+		if cnsOperator.coCommonInterface.IsFSSEnabled(ctx, common.VolumePolicyMigration) {
+			// Create CnsVolumePolicyMigration CRD to change the storage policy of volumes.
+			err = k8s.CreateCustomResourceDefinitionFromManifest(ctx,
+				internalapiscnsoperatorconfig.EmbedCnsVolumePolicyMigrationFile,
+				internalapiscnsoperatorconfig.EmbedCnsVolumePolicyMigrationFileName)
+			if err != nil {
+				log.Errorf("Failed to create %q CRD. Err: %+v", internalapis.CnsVolumePolicyMigrationPlural, err)
+				return err
+			}
+		}
+		if cnsOperator.coCommonInterface.IsFSSEnabled(ctx, common.SnapshotExport) {
+			// Create CnsSnapshotExport CRD to upload the content of snapshots.
+			err = k8s.CreateCustomResourceDefinitionFromManifest(ctx,
+				internalapiscnsoperatorconfig.EmbedCnsSnapshotExportFile,
+				internalapiscnsoperatorconfig.EmbedCnsSnapshotExportFileName)
+			if err != nil {
+				log.Errorf("Failed to create %q CRD. Err: %+v", internalapis.CnsSnapshotExportPlural, err)
+				return err
+			}
+		}
+		if cnsOperator.coCommonInterface.IsFSSEnabled(ctx, common.SnapshotChangedBlockTracking) {
+			// Create CnsSnapshotDelta CRD to query the changed areas of snapshots.
+			err = k8s.CreateCustomResourceDefinitionFromManifest(ctx,
+				internalapiscnsoperatorconfig.EmbedCnsSnapshotDeltaFile,
+				internalapiscnsoperatorconfig.EmbedCnsSnapshotDeltaFileName)
+			if err != nil {
+				log.Errorf("Failed to create %q CRD. Err: %+v", internalapis.CnsSnapshotDeltaPlural, err)
+				return err
+			}
+		}
 	} else if clusterFlavor == cnstypes.CnsClusterFlavorGuest {
 		if cnsOperator.coCommonInterface.IsFSSEnabled(ctx, common.TKGsHA) {
 			// Create CSINodeTopology CRD.
@@ -311,35 +352,38 @@ func InitCommonModules(ctx context.Context, clusterFlavor cnstypes.CnsClusterFla
 			return err
 		}
 		// Check if TriggerCsiFullSync instance is present. If not present,
-		// create the TriggerCsiFullSync instance with name "csifullsync".
+		// create the TriggerCsiFullSync instance with name "csifullsync", suffixed
+		// with the driver name for drivers with a non-default name.
 		// If present, update the TriggerCsiFullSync.Status.InProgress to false if
 		// a full sync is already running.
+		triggerCsiFullSyncCRName := csitypes.InstanceObjectName(triggerCsiFullSyncCRName)
 		triggerCsiFullSyncInstance := &triggercsifullsyncv1alpha1.TriggerCsiFullSync{}
-		key := k8stypes.NamespacedName{Namespace: "", Name: common.TriggerCsiFullSyncCRName}
+		key := k8stypes.NamespacedName{Namespace: "", Name: triggerCsiFullSyncCRName}
 		if err := cnsOperatorClient.Get(ctx, key, triggerCsiFullSyncInstance); err != nil {
 			if apierrors.IsNotFound(err) {
 				newtriggerCsiFullSyncInstance := triggercsifullsyncv1alpha1.CreateTriggerCsiFullSyncInstance()
+				newtriggerCsiFullSyncInstance.Name = triggerCsiFullSyncCRName
 				if err := cnsOperatorClient.Create(ctx, newtriggerCsiFullSyncInstance); err != nil {
 					log.Errorf("Failed to create TriggerCsiFullSync instance: %q. Error: %v",
-						common.TriggerCsiFullSyncCRName, err)
+						triggerCsiFullSyncCRName, err)
 					return err
 				}
 				log.Infof("Created the a new instance of %q TriggerCsiFullSync instance as it was not found.",
-					common.TriggerCsiFullSyncCRName)
+					triggerCsiFullSyncCRName)
 			} else {
 				log.Errorf("Failed to get TriggerCsiFullSync instance: %q. Error: %v",
-					common.TriggerCsiFullSyncCRName, err)
+					triggerCsiFullSyncCRName, err)
 				return err
 			}
 		}
 		if triggerCsiFullSyncInstance.Status.InProgress {
 			log.Infof("Found %q instance with InProgress set to true on syncer startup. "+
 				"Resetting InProgress field to false as no full sync is currently running",
-				common.TriggerCsiFullSyncCRName)
+				triggerCsiFullSyncCRName)
 			triggerCsiFullSyncInstance.Status.InProgress = false
 			if err := cnsOperatorClient.Update(ctx, triggerCsiFullSyncInstance); err != nil {
 				log.Errorf("Failed to update TriggerCsiFullSync instance: %q with Status.InProgress set to false. "+
-					"Error: %v", common.TriggerCsiFullSyncCRName, err)
+					"Error: %v", triggerCsiFullSyncCRName, err)
 				return err
 			}
 		}
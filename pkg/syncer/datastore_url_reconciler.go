@@ -0,0 +1,187 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	vimtypes "github.com/vmware/govmomi/vim25/types"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
+)
+
+const (
+	// datastoreURLChangedReason is the reason of the events recorded on the
+	// PVs and StorageClasses referring to a datastore whose URL changed.
+	datastoreURLChangedReason = "DatastoreURLChanged"
+)
+
+var (
+	// datastoreURLs maps a vCenter host to the URLs of its datastores keyed
+	// by the datastore managed object ID, as seen by the last reconcile.
+	datastoreURLs = make(map[string]map[string]string)
+	// datastoreURLsLock protects datastoreURLs.
+	datastoreURLsLock sync.Mutex
+)
+
+// datastoreURLChange describes the URL change of a datastore, e.g. after it
+// was renamed or remounted.
+type datastoreURLChange struct {
+	datastore vimtypes.ManagedObjectReference
+	oldURL    string
+	newURL    string
+}
+
+// fullSyncReconcileDatastoreURLs detects the datastores of the given vCenter
+// whose URL changed since the previous full sync and reports the change on
+// the affected PVs and StorageClasses.
+func fullSyncReconcileDatastoreURLs(ctx context.Context, metadataSyncer *metadataSyncInformer, vc string) {
+	log := logger.GetLogger(ctx)
+	changes, err := detectDatastoreURLChanges(ctx, metadataSyncer, vc)
+	if err != nil {
+		log.Errorf("FullSync for VC %s: failed to detect datastore URL changes. Err: %v", vc, err)
+		return
+	}
+	for _, change := range changes {
+		log.Infof("FullSync for VC %s: URL of datastore %q changed from %q to %q",
+			vc, change.datastore.Value, change.oldURL, change.newURL)
+		reportDatastoreURLChange(ctx, metadataSyncer, vc, change)
+	}
+}
+
+// detectDatastoreURLChanges lists the datastores of the given vCenter,
+// updates the cached datastore URLs and returns the datastores whose URL is
+// different from the one seen by the previous call.
+func detectDatastoreURLChanges(ctx context.Context, metadataSyncer *metadataSyncInformer,
+	vcHost string) ([]datastoreURLChange, error) {
+	var vcenter *cnsvsphere.VirtualCenter
+	var err error
+	if isMultiVCenterFssEnabled {
+		vcenter, err = cnsvsphere.GetVirtualCenterInstanceForVCenterHost(ctx, vcHost, true)
+	} else {
+		vcenter, err = cnsvsphere.GetVirtualCenterInstance(ctx, metadataSyncer.configInfo, false)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get vCenter instance. Err: %v", err)
+	}
+	datacenters, err := vcenter.GetDatacenters(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get datacenters. Err: %v", err)
+	}
+	current := make(map[string]string)
+	refs := make(map[string]vimtypes.ManagedObjectReference)
+	for _, dc := range datacenters {
+		dsURLInfoMap, err := dc.GetAllDatastores(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get datastores of datacenter %q. Err: %v", dc.InventoryPath, err)
+		}
+		for url, dsInfo := range dsURLInfoMap {
+			ref := dsInfo.Datastore.Reference()
+			current[ref.Value] = url
+			refs[ref.Value] = ref
+		}
+	}
+
+	datastoreURLsLock.Lock()
+	defer datastoreURLsLock.Unlock()
+	var changes []datastoreURLChange
+	if previous, ok := datastoreURLs[vcHost]; ok {
+		for moID, url := range current {
+			if oldURL, found := previous[moID]; found && oldURL != url {
+				changes = append(changes, datastoreURLChange{datastore: refs[moID], oldURL: oldURL, newURL: url})
+			}
+		}
+	}
+	datastoreURLs[vcHost] = current
+	return changes, nil
+}
+
+// reportDatastoreURLChange records events on the PVs of the CNS volumes placed
+// on the datastore and on the StorageClasses referring to its previous URL.
+func reportDatastoreURLChange(ctx context.Context, metadataSyncer *metadataSyncInformer, vcHost string,
+	change datastoreURLChange) {
+	log := logger.GetLogger(ctx)
+	volManager, err := getVolManagerForVcHost(ctx, vcHost, metadataSyncer)
+	if err != nil {
+		log.Errorf("reportDatastoreURLChange: failed to get volume manager for vCenter %q. Err: %v", vcHost, err)
+		return
+	}
+	queryFilter := cnstypes.CnsQueryFilter{
+		ContainerClusterIds: []string{metadataSyncer.configInfo.Cfg.Global.ClusterID},
+		Datastores:          []vimtypes.ManagedObjectReference{change.datastore},
+	}
+	querySelection := cnstypes.CnsQuerySelection{
+		Names: []string{string(cnstypes.QuerySelectionNameTypeDataStoreUrl)},
+	}
+	queryResult, err := volManager.QueryAllVolume(ctx, queryFilter, querySelection)
+	if err != nil {
+		log.Errorf("reportDatastoreURLChange: failed to query volumes on datastore %q. Err: %v",
+			change.datastore.Value, err)
+	} else if len(queryResult.Volumes) > 0 {
+		volumeIDs := make(map[string]bool, len(queryResult.Volumes))
+		for _, volume := range queryResult.Volumes {
+			volumeIDs[volume.VolumeId.Id] = true
+		}
+		pvs, err := metadataSyncer.pvLister.List(labels.Everything())
+		if err != nil {
+			log.Errorf("reportDatastoreURLChange: failed to list PVs. Err: %v", err)
+		}
+		for _, pv := range pvs {
+			if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != csitypes.DriverName() || !volumeIDs[pv.Spec.CSI.VolumeHandle] {
+				continue
+			}
+			generateEventOnObject(ctx, pv, v1.EventTypeNormal, datastoreURLChangedReason,
+				fmt.Sprintf("URL of datastore %q backing the volume changed from %q to %q",
+					change.datastore.Value, change.oldURL, change.newURL))
+		}
+	}
+
+	k8sClient, err := k8s.NewClient(ctx)
+	if err != nil {
+		log.Errorf("reportDatastoreURLChange: failed to create kubernetes client. Err: %v", err)
+		return
+	}
+	scList, err := k8sClient.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Errorf("reportDatastoreURLChange: failed to list StorageClasses. Err: %v", err)
+		return
+	}
+	for i := range scList.Items {
+		sc := &scList.Items[i]
+		if sc.Provisioner != csitypes.DriverName() {
+			continue
+		}
+		for param, value := range sc.Parameters {
+			if strings.ToLower(param) == common.AttributeDatastoreURL && value == change.oldURL {
+				generateEventOnObject(ctx, sc, v1.EventTypeWarning, datastoreURLChangedReason,
+					fmt.Sprintf("datastore %q referred by parameter %q is now reachable at %q. "+
+						"Recreate the StorageClass with the new URL", change.datastore.Value, param, change.newURL))
+			}
+		}
+	}
+}
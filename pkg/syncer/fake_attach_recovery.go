@@ -0,0 +1,153 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+)
+
+const (
+	// pvcConditionFakeAttached is the type of the PVC condition reporting
+	// whether the volume of the PVC is fake attached.
+	pvcConditionFakeAttached v1.PersistentVolumeClaimConditionType = "FakeAttached"
+
+	// fakeAttachedReasonInaccessible is the reason of the FakeAttached
+	// condition and event while the fake attached volume is inaccessible.
+	fakeAttachedReasonInaccessible = "VolumeInaccessible"
+	// fakeAttachedReasonReattaching is the reason of the FakeAttached
+	// condition and event once the VolumeAttachments of the fake attached
+	// volume are deleted to attach it again.
+	fakeAttachedReasonReattaching = "Reattaching"
+	// fakeAttachedReasonReattachFailed is the reason of the event recorded
+	// when the VolumeAttachments of a fake attached volume couldn't be deleted.
+	fakeAttachedReasonReattachFailed = "ReattachFailed"
+)
+
+// fakeAttachedVolume is a volume of a PVC with the fake attach annotation.
+type fakeAttachedVolume struct {
+	volumeID string
+	pvc      *v1.PersistentVolumeClaim
+	health   string
+}
+
+// recoverFakeAttachedVolumes reports the fake attached volumes on their PVCs
+// and reattaches the ones whose health returned to accessible. The
+// VolumeAttachments of such a volume are deleted, so that the
+// external-attacher detaches the fake attached volume, which clears its fake
+// attach annotation, and the attach/detach controller, finding the volume no
+// longer attached to the node of the pod using it, attaches it for real.
+func recoverFakeAttachedVolumes(ctx context.Context, k8sclient clientset.Interface,
+	volumes []fakeAttachedVolume) {
+	log := logger.GetLogger(ctx)
+	var accessibleVolumes []fakeAttachedVolume
+	for _, volume := range volumes {
+		if volume.health == common.VolHealthStatusAccessible {
+			accessibleVolumes = append(accessibleVolumes, volume)
+			continue
+		}
+		updateFakeAttachedCondition(ctx, k8sclient, volume.pvc, v1.ConditionTrue, fakeAttachedReasonInaccessible,
+			fmt.Sprintf("Volume %s is fake attached as it is inaccessible", volume.volumeID))
+	}
+	if len(accessibleVolumes) == 0 {
+		return
+	}
+
+	vaList, err := k8sclient.StorageV1().VolumeAttachments().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Errorf("recoverFakeAttachedVolumes: failed to list VolumeAttachments. Err: %v", err)
+		return
+	}
+	vaNamesByPV := make(map[string][]string)
+	for _, va := range vaList.Items {
+		if va.Spec.Attacher == csitypes.DriverName() && va.Spec.Source.PersistentVolumeName != nil {
+			pvName := *va.Spec.Source.PersistentVolumeName
+			vaNamesByPV[pvName] = append(vaNamesByPV[pvName], va.Name)
+		}
+	}
+	for _, volume := range accessibleVolumes {
+		pvc := volume.pvc
+		for _, vaName := range vaNamesByPV[pvc.Spec.VolumeName] {
+			log.Infof("recoverFakeAttachedVolumes: volume %s of pvc %s/%s is accessible again, deleting "+
+				"VolumeAttachment %s to attach it again", volume.volumeID, pvc.Namespace, pvc.Name, vaName)
+			err := k8sclient.StorageV1().VolumeAttachments().Delete(ctx, vaName, metav1.DeleteOptions{})
+			if err != nil && !apierrors.IsNotFound(err) {
+				msg := fmt.Sprintf("Failed to delete VolumeAttachment %s to attach the accessible fake attached "+
+					"volume %s again. Err: %v", vaName, volume.volumeID, err)
+				log.Errorf("recoverFakeAttachedVolumes: %s", msg)
+				generateEventOnObject(ctx, pvc, v1.EventTypeWarning, fakeAttachedReasonReattachFailed, msg)
+				continue
+			}
+			generateEventOnObject(ctx, pvc, v1.EventTypeNormal, fakeAttachedReasonReattaching,
+				fmt.Sprintf("Volume %s is accessible again, deleted VolumeAttachment %s to attach it again",
+					volume.volumeID, vaName))
+		}
+		updateFakeAttachedCondition(ctx, k8sclient, pvc, v1.ConditionFalse, fakeAttachedReasonReattaching,
+			fmt.Sprintf("Volume %s is accessible again and is being attached", volume.volumeID))
+	}
+}
+
+// updateFakeAttachedCondition sets the FakeAttached condition of the PVC,
+// recording an event when the condition changes.
+func updateFakeAttachedCondition(ctx context.Context, k8sclient clientset.Interface,
+	pvc *v1.PersistentVolumeClaim, status v1.ConditionStatus, reason, message string) {
+	log := logger.GetLogger(ctx)
+	for _, condition := range pvc.Status.Conditions {
+		if condition.Type == pvcConditionFakeAttached && condition.Status == status && condition.Reason == reason {
+			return
+		}
+	}
+	// The PVC from the lister may be stale after its volume health annotation
+	// update, so the status is updated on the PVC from the API server.
+	newPVC, err := k8sclient.CoreV1().PersistentVolumeClaims(pvc.Namespace).Get(ctx, pvc.Name, metav1.GetOptions{})
+	if err != nil {
+		log.Errorf("updateFakeAttachedCondition: failed to get pvc %s/%s. Err: %v", pvc.Namespace, pvc.Name, err)
+		return
+	}
+	conditions := []v1.PersistentVolumeClaimCondition{{
+		Type:               pvcConditionFakeAttached,
+		Status:             status,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	}}
+	for _, condition := range newPVC.Status.Conditions {
+		if condition.Type != pvcConditionFakeAttached {
+			conditions = append(conditions, condition)
+		}
+	}
+	newPVC.Status.Conditions = conditions
+	_, err = k8sclient.CoreV1().PersistentVolumeClaims(pvc.Namespace).UpdateStatus(ctx, newPVC,
+		metav1.UpdateOptions{})
+	if err != nil {
+		log.Errorf("updateFakeAttachedCondition: failed to update %s condition of pvc %s/%s. Err: %v",
+			pvcConditionFakeAttached, pvc.Namespace, pvc.Name, err)
+		return
+	}
+	if status == v1.ConditionTrue {
+		generateEventOnObject(ctx, pvc, v1.EventTypeWarning, fakeAttachedReasonInaccessible, message)
+	}
+}
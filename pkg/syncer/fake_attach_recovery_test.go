@@ -0,0 +1,77 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+)
+
+func TestRecoverFakeAttachedVolumes(t *testing.T) {
+	ctx := context.Background()
+	newPVC := func(name string) *v1.PersistentVolumeClaim {
+		return &v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   "default",
+				Annotations: map[string]string{common.AnnFakeAttached: "yes"},
+			},
+			Spec: v1.PersistentVolumeClaimSpec{VolumeName: "pv-" + name},
+		}
+	}
+	newVA := func(pvName string) *storagev1.VolumeAttachment {
+		return &storagev1.VolumeAttachment{
+			ObjectMeta: metav1.ObjectMeta{Name: "va-" + pvName},
+			Spec: storagev1.VolumeAttachmentSpec{
+				Attacher: csitypes.Name,
+				Source:   storagev1.VolumeAttachmentSource{PersistentVolumeName: &pvName},
+			},
+		}
+	}
+	accessiblePVC, inaccessiblePVC := newPVC("accessible"), newPVC("inaccessible")
+	k8sclient := testclient.NewSimpleClientset(accessiblePVC, inaccessiblePVC,
+		newVA("pv-accessible"), newVA("pv-inaccessible"))
+
+	recoverFakeAttachedVolumes(ctx, k8sclient, []fakeAttachedVolume{
+		{volumeID: "volume-1", pvc: accessiblePVC, health: common.VolHealthStatusAccessible},
+		{volumeID: "volume-2", pvc: inaccessiblePVC, health: common.VolHealthStatusInaccessible},
+	})
+
+	vaList, err := k8sclient.StorageV1().VolumeAttachments().List(ctx, metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, vaList.Items, 1)
+	assert.Equal(t, "va-pv-inaccessible", vaList.Items[0].Name)
+	for name, expected := range map[string]v1.ConditionStatus{
+		"accessible":   v1.ConditionFalse,
+		"inaccessible": v1.ConditionTrue,
+	} {
+		pvc, err := k8sclient.CoreV1().PersistentVolumeClaims("default").Get(ctx, name, metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Len(t, pvc.Status.Conditions, 1)
+		assert.Equal(t, pvcConditionFakeAttached, pvc.Status.Conditions[0].Type)
+		assert.Equal(t, expected, pvc.Status.Conditions[0].Status)
+	}
+}
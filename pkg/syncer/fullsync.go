@@ -19,6 +19,7 @@ package syncer
 import (
 	"context"
 	"errors"
+	"sort"
 	"sync"
 	"time"
 
@@ -31,6 +32,7 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/flowcontrol"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/migration"
 	volumes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
 	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
@@ -46,6 +48,10 @@ import (
 // metadata on CNS.
 func CsiFullSync(ctx context.Context, metadataSyncer *metadataSyncInformer, vc string) error {
 	log := logger.GetLogger(ctx)
+	if shuttingDown.Load() {
+		log.Infof("FullSync for VC %s: skipped as the syncer is shutting down", vc)
+		return nil
+	}
 	log.Infof("FullSync for VC %s: start", vc)
 	fullSyncStartTime := time.Now()
 	var migrationFeatureStateForFullSync bool
@@ -80,6 +86,9 @@ func CsiFullSync(ctx context.Context, metadataSyncer *metadataSyncInformer, vc s
 			(time.Since(fullSyncStartTime)).Seconds())
 	}()
 
+	// Metadata written to CNS by the metadata syncer after this time is more
+	// recent than the Kubernetes objects listed below, and is not overwritten.
+	snapshotTime := time.Now()
 	// Get K8s PVs in State "Bound", "Available" or "Released" for the given VC.
 	k8sPVs, err := getPVsInBoundAvailableOrReleasedForVc(ctx, metadataSyncer, vc)
 	if err != nil {
@@ -241,6 +250,10 @@ func CsiFullSync(ctx context.Context, metadataSyncer *metadataSyncInformer, vc s
 		}
 	}
 
+	for _, volume := range queryAllResult.Volumes {
+		common.SetVolumeType(volume.VolumeId.Id, volume.VolumeType)
+	}
+
 	vcHostObj, vcHostObjFound := metadataSyncer.configInfo.Cfg.VirtualCenter[vc]
 	if !vcHostObjFound {
 		log.Errorf("FullSync for VC %s: Failed to get VC host object.", vc)
@@ -287,15 +300,43 @@ func CsiFullSync(ctx context.Context, metadataSyncer *metadataSyncInformer, vc s
 		return err
 	}
 
+	var checkpoint *fullSyncCheckpoint
+	var rateLimiters fullSyncRateLimiters
+	if metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.ResumableFullSync) {
+		var checkpointErr error
+		checkpoint, checkpointErr = loadFullSyncCheckpoint(ctx, vc)
+		if checkpointErr != nil {
+			log.Warnf("FullSync for VC %s: failed to load the full sync checkpoint, this full sync "+
+				"will not be resumable. Err: %v", vc, checkpointErr)
+		}
+		rateLimiters = newFullSyncRateLimiters(ctx)
+	}
+
 	wg := sync.WaitGroup{}
 	wg.Add(3)
 	// Perform operations.
-	go fullSyncCreateVolumes(ctx, createSpecArray, metadataSyncer, &wg, migrationFeatureStateForFullSync, volManager, vc)
-	go fullSyncUpdateVolumes(ctx, updateSpecArray, metadataSyncer, &wg, volManager, vc)
-	go fullSyncDeleteVolumes(ctx, volToBeDeleted, metadataSyncer, &wg, migrationFeatureStateForFullSync, volManager, vc)
+	go fullSyncCreateVolumes(ctx, createSpecArray, metadataSyncer, &wg, migrationFeatureStateForFullSync, volManager, vc,
+		checkpoint, rateLimiters.create)
+	go fullSyncUpdateVolumes(ctx, updateSpecArray, metadataSyncer, &wg, volManager, vc, snapshotTime,
+		checkpoint, rateLimiters.update)
+	go fullSyncDeleteVolumes(ctx, volToBeDeleted, metadataSyncer, &wg, migrationFeatureStateForFullSync, volManager, vc,
+		checkpoint, rateLimiters.delete)
 	wg.Wait()
+	checkpoint.complete(ctx)
+
+	if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorVanilla &&
+		metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.OutOfBandResizeSync) {
+		fullSyncReconcileVolumeCapacity(ctx, k8sPVs, metadataSyncer, volManager, vc)
+	}
+	if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorVanilla &&
+		metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.DatastoreURLReconcile) {
+		fullSyncReconcileDatastoreURLs(ctx, metadataSyncer, vc)
+	}
 
 	cleanupCnsMaps(k8sPVMap, vc)
+	if isFullSyncConflictResolutionEnabled(ctx, metadataSyncer) {
+		metadataTracker.prune(snapshotTime, k8sPVMap)
+	}
 	log.Debugf("FullSync for VC %s: cnsDeletionMap at end of cycle: %v", vc, cnsDeletionMap)
 	log.Debugf("FullSync for VC %s: cnsCreationMap at end of cycle: %v", vc, cnsCreationMap)
 	log.Infof("FullSync for VC %s: end", vc)
@@ -476,9 +517,10 @@ func volumeInfoCRFullSync(ctx context.Context, metadataSyncer *metadataSyncInfor
 // fullSyncCreateVolumes creates volumes with given array of createSpec.
 // Before creating a volume, all current K8s volumes are retrieved.
 // If the volume is successfully created, it is removed from cnsCreationMap.
+// Volumes already processed according to the given checkpoint are skipped.
 func fullSyncCreateVolumes(ctx context.Context, createSpecArray []cnstypes.CnsVolumeCreateSpec,
 	metadataSyncer *metadataSyncInformer, wg *sync.WaitGroup, migrationFeatureStateForFullSync bool,
-	volManager volumes.Manager, vc string) {
+	volManager volumes.Manager, vc string, checkpoint *fullSyncCheckpoint, rateLimiter flowcontrol.RateLimiter) {
 	log := logger.GetLogger(ctx)
 	defer wg.Done()
 	currentK8sPVMap := make(map[string]*v1.PersistentVolume)
@@ -509,25 +551,29 @@ func fullSyncCreateVolumes(ctx context.Context, createSpecArray []cnstypes.CnsVo
 			currentK8sPVMap[volumeHandle] = pv
 		}
 	}
+	// Volumes are created in the order of their IDs, for the checkpoint to
+	// track the progress of this phase.
+	sort.SliceStable(createSpecArray, func(i, j int) bool {
+		return getCreateSpecVolumeID(createSpecArray[i]) < getCreateSpecVolumeID(createSpecArray[j])
+	})
 	for _, createSpec := range createSpecArray {
 		// Create volume if present in currentK8sPVMap.
-		var volumeID string
-		if createSpec.VolumeType == common.BlockVolumeType && createSpec.BackingObjectDetails != nil &&
-			createSpec.BackingObjectDetails.(*cnstypes.CnsBlockBackingDetails) != nil {
-			volumeID = createSpec.BackingObjectDetails.(*cnstypes.CnsBlockBackingDetails).BackingDiskId
-		} else if createSpec.VolumeType == common.FileVolumeType && createSpec.BackingObjectDetails != nil &&
-			createSpec.BackingObjectDetails.(*cnstypes.CnsVsanFileShareBackingDetails) != nil {
-			// We should never reach here in case of multi VC deployment as file share volumes are already filtered out.
-			volumeID = createSpec.BackingObjectDetails.(*cnstypes.CnsVsanFileShareBackingDetails).BackingFileId
-		} else {
+		volumeID := getCreateSpecVolumeID(createSpec)
+		if volumeID == "" {
 			log.Warnf("Skipping createSpec: %+v as VolumeType is unknown or BackingObjectDetails is not valid",
 				spew.Sdump(createSpec))
 			continue
 		}
+		if checkpoint.isProcessed(fullSyncPhaseCreate, volumeID) {
+			log.Debugf("FullSync for VC %s: volume %q was already processed by this full sync", vc, volumeID)
+			continue
+		}
 		if pv, existsInK8s := currentK8sPVMap[volumeID]; existsInK8s {
 			log.Debugf("FullSync for VC %s: Calling CreateVolume for volume id: %q with createSpec %+v",
 				vc, volumeID, spew.Sdump(createSpec))
+			waitFullSyncRateLimiter(rateLimiter)
 			_, _, err := volManager.CreateVolume(ctx, &createSpec, nil)
+			checkpoint.markProcessed(ctx, fullSyncPhaseCreate, volumeID)
 			if err != nil {
 				log.Warnf("FullSync for VC %s: Failed to create volume with the spec: %+v. "+
 					"Err: %+v", vc, spew.Sdump(createSpec), err)
@@ -557,12 +603,29 @@ func fullSyncCreateVolumes(ctx context.Context, createSpecArray []cnstypes.CnsVo
 
 }
 
+// getCreateSpecVolumeID returns the ID of the volume of the given createSpec,
+// or "" if its VolumeType is unknown or its BackingObjectDetails is not valid.
+func getCreateSpecVolumeID(createSpec cnstypes.CnsVolumeCreateSpec) string {
+	if createSpec.VolumeType == common.BlockVolumeType && createSpec.BackingObjectDetails != nil &&
+		createSpec.BackingObjectDetails.(*cnstypes.CnsBlockBackingDetails) != nil {
+		return createSpec.BackingObjectDetails.(*cnstypes.CnsBlockBackingDetails).BackingDiskId
+	}
+	if createSpec.VolumeType == common.FileVolumeType && createSpec.BackingObjectDetails != nil &&
+		createSpec.BackingObjectDetails.(*cnstypes.CnsVsanFileShareBackingDetails) != nil {
+		// We should never reach here in case of multi VC deployment as file share volumes are already filtered out.
+		return createSpec.BackingObjectDetails.(*cnstypes.CnsVsanFileShareBackingDetails).BackingFileId
+	}
+	return ""
+}
+
 // fullSyncDeleteVolumes deletes volumes with given array of volumeId.
 // Before deleting a volume, all current K8s volumes are retrieved.
 // If the volume is successfully deleted, it is removed from cnsDeletionMap.
+// Volumes already processed according to the given checkpoint are skipped.
 func fullSyncDeleteVolumes(ctx context.Context, volumeIDDeleteArray []cnstypes.CnsVolumeId,
 	metadataSyncer *metadataSyncInformer, wg *sync.WaitGroup,
-	migrationFeatureStateForFullSync bool, volManager volumes.Manager, vc string) {
+	migrationFeatureStateForFullSync bool, volManager volumes.Manager, vc string,
+	checkpoint *fullSyncCheckpoint, rateLimiter flowcontrol.RateLimiter) {
 	defer wg.Done()
 	log := logger.GetLogger(ctx)
 	deleteDisk := false
@@ -596,7 +659,8 @@ func fullSyncDeleteVolumes(ctx context.Context, volumeIDDeleteArray []cnstypes.C
 	var queryVolumeIds []cnstypes.CnsVolumeId
 	for _, volID := range volumeIDDeleteArray {
 		// Delete volume if not present in currentK8sPVMap.
-		if _, existsInK8s := currentK8sPVMap[volID.Id]; !existsInK8s {
+		if _, existsInK8s := currentK8sPVMap[volID.Id]; !existsInK8s &&
+			!checkpoint.isProcessed(fullSyncPhaseDelete, volID.Id) {
 			queryVolumeIds = append(queryVolumeIds, cnstypes.CnsVolumeId{Id: volID.Id})
 		}
 	}
@@ -614,68 +678,108 @@ func fullSyncDeleteVolumes(ctx context.Context, volumeIDDeleteArray []cnstypes.C
 		log.Errorf("FullSync for VC %s: fullSyncGetQueryResults failed to query volume metadata from vc. Err: %v", vc, err)
 		return
 	}
-	// Verify if Volume is not in use by any other Cluster before removing CNS tag
+	// Volumes are deleted in the order of their IDs, for the checkpoint to
+	// track the progress of this phase.
+	var queriedVolumes []cnstypes.CnsVolume
 	for _, queryResult := range allQueryResults {
-		for _, volume := range queryResult.Volumes {
-			inUsebyOtherK8SCluster := false
-			for _, metadata := range volume.Metadata.EntityMetadata {
-				if metadata.(*cnstypes.CnsKubernetesEntityMetadata).ClusterID != clusterIDforVolumeMetadata {
-					inUsebyOtherK8SCluster = true
-					log.Debugf("FullSync for VC %s: fullSyncDeleteVolumes: Volume: %q is "+
-						"in use by other cluster.", vc, volume.VolumeId.Id)
-					break
+		queriedVolumes = append(queriedVolumes, queryResult.Volumes...)
+	}
+	sort.SliceStable(queriedVolumes, func(i, j int) bool {
+		return queriedVolumes[i].VolumeId.Id < queriedVolumes[j].VolumeId.Id
+	})
+	// Verify if Volume is not in use by any other Cluster before removing CNS tag
+	for _, volume := range queriedVolumes {
+		inUsebyOtherK8SCluster := false
+		for _, metadata := range volume.Metadata.EntityMetadata {
+			if metadata.(*cnstypes.CnsKubernetesEntityMetadata).ClusterID != clusterIDforVolumeMetadata {
+				inUsebyOtherK8SCluster = true
+				log.Debugf("FullSync for VC %s: fullSyncDeleteVolumes: Volume: %q is "+
+					"in use by other cluster.", vc, volume.VolumeId.Id)
+				break
+			}
+		}
+		if !inUsebyOtherK8SCluster && isVolumeRetained(ctx, metadataSyncer, volManager, volume.VolumeId.Id) {
+			log.Infof("FullSync for VC %s: fullSyncDeleteVolumes: Skipping volume %q retained with a "+
+				"safety snapshot", vc, volume.VolumeId.Id)
+			delete(cnsDeletionMap[vc], volume.VolumeId.Id)
+			continue
+		}
+		if !inUsebyOtherK8SCluster {
+			log.Infof("FullSync for VC %s: fullSyncDeleteVolumes: Calling DeleteVolume for volume %v with delete disk %v",
+				vc, volume.VolumeId.Id, deleteDisk)
+			waitFullSyncRateLimiter(rateLimiter)
+			_, err := volManager.DeleteVolume(ctx, volume.VolumeId.Id, deleteDisk)
+			checkpoint.markProcessed(ctx, fullSyncPhaseDelete, volume.VolumeId.Id)
+			if err != nil {
+				log.Warnf("FullSync for VC %s: fullSyncDeleteVolumes: Failed to delete volume %s with error %+v",
+					vc, volume.VolumeId.Id, err)
+				continue
+			}
+
+			if isMultiVCenterFssEnabled && len(metadataSyncer.configInfo.Cfg.VirtualCenter) > 1 {
+				// Delete CNSVolumeInfo CR for the volume ID.
+				err = volumeInfoService.DeleteVolumeInfo(ctx, volume.VolumeId.Id)
+				if err != nil {
+					log.Errorf("failed to remove volumeID %q for vCenter %q from CNSVolumeInfo CR. Error: %+v",
+						volume.VolumeId.Id, vc, err)
 				}
 			}
-			if !inUsebyOtherK8SCluster {
-				log.Infof("FullSync for VC %s: fullSyncDeleteVolumes: Calling DeleteVolume for volume %v with delete disk %v",
-					vc, volume.VolumeId.Id, deleteDisk)
-				_, err := volManager.DeleteVolume(ctx, volume.VolumeId.Id, deleteDisk)
+
+			if migrationFeatureStateForFullSync {
+				err = volumeMigrationService.DeleteVolumeInfo(ctx, volume.VolumeId.Id)
+				// For non-migrated volumes DeleteVolumeInfo will not return
+				// error. So, the volume id will be deleted from cnsDeletionMap.
 				if err != nil {
-					log.Warnf("FullSync for VC %s: fullSyncDeleteVolumes: Failed to delete volume %s with error %+v",
+					log.Warnf("FullSync for VC %s: fullSyncDeleteVolumes: Failed to delete volume mapping CR for %s. Err: %+v",
 						vc, volume.VolumeId.Id, err)
 					continue
 				}
-
-				if isMultiVCenterFssEnabled && len(metadataSyncer.configInfo.Cfg.VirtualCenter) > 1 {
-					// Delete CNSVolumeInfo CR for the volume ID.
-					err = volumeInfoService.DeleteVolumeInfo(ctx, volume.VolumeId.Id)
-					if err != nil {
-						log.Errorf("failed to remove volumeID %q for vCenter %q from CNSVolumeInfo CR. Error: %+v",
-							volume.VolumeId.Id, vc, err)
-					}
-				}
-
-				if migrationFeatureStateForFullSync {
-					err = volumeMigrationService.DeleteVolumeInfo(ctx, volume.VolumeId.Id)
-					// For non-migrated volumes DeleteVolumeInfo will not return
-					// error. So, the volume id will be deleted from cnsDeletionMap.
-					if err != nil {
-						log.Warnf("FullSync for VC %s: fullSyncDeleteVolumes: Failed to delete volume mapping CR for %s. Err: %+v",
-							vc, volume.VolumeId.Id, err)
-						continue
-					}
-				}
 			}
-			// Delete volume from cnsDeletionMap which is successfully deleted from
-			// CNS.
-			delete(cnsDeletionMap[vc], volume.VolumeId.Id)
 		}
+		// Delete volume from cnsDeletionMap which is successfully deleted from
+		// CNS.
+		delete(cnsDeletionMap[vc], volume.VolumeId.Id)
 	}
 }
 
 // fullSyncUpdateVolumes update metadata for volumes with given array of
-// createSpec.
+// createSpec. Volumes already processed according to the given checkpoint
+// are skipped.
 func fullSyncUpdateVolumes(ctx context.Context, updateSpecArray []cnstypes.CnsVolumeMetadataUpdateSpec,
 	metadataSyncer *metadataSyncInformer, wg *sync.WaitGroup, volManager volumes.Manager,
-	vc string) {
+	vc string, snapshotTime time.Time, checkpoint *fullSyncCheckpoint, rateLimiter flowcontrol.RateLimiter) {
 	defer wg.Done()
 	log := logger.GetLogger(ctx)
+	conflictResolutionEnabled := isFullSyncConflictResolutionEnabled(ctx, metadataSyncer)
+	// Volumes are updated in the order of their IDs, for the checkpoint to
+	// track the progress of this phase.
+	sort.SliceStable(updateSpecArray, func(i, j int) bool {
+		return updateSpecArray[i].VolumeId.Id < updateSpecArray[j].VolumeId.Id
+	})
 	for _, updateSpec := range updateSpecArray {
+		if checkpoint.isProcessed(fullSyncPhaseUpdate, updateSpec.VolumeId.Id) {
+			log.Debugf("FullSync for VC %s: volume %q was already processed by this full sync",
+				vc, updateSpec.VolumeId.Id)
+			continue
+		}
 		log.Debugf("FullSync for VC %s: Calling UpdateVolumeMetadata for volume %s with updateSpec: %+v",
 			vc, updateSpec.VolumeId.Id, spew.Sdump(updateSpec))
-		if err := volManager.UpdateVolumeMetadata(ctx, &updateSpec); err != nil {
+		waitFullSyncRateLimiter(rateLimiter)
+		if !conflictResolutionEnabled {
+			if err := volManager.UpdateVolumeMetadata(ctx, &updateSpec); err != nil {
+				log.Warnf("FullSync for VC %s: UpdateVolumeMetadata failed with err %v", vc, err)
+			}
+			checkpoint.markProcessed(ctx, fullSyncPhaseUpdate, updateSpec.VolumeId.Id)
+			continue
+		}
+		_, err := metadataTracker.update(ctx, updateSpec.VolumeId.Id, metadataUpdateSourceFullSync, snapshotTime,
+			func() error {
+				return volManager.UpdateVolumeMetadata(ctx, &updateSpec)
+			})
+		if err != nil {
 			log.Warnf("FullSync for VC %s: UpdateVolumeMetadata failed with err %v", vc, err)
 		}
+		checkpoint.markProcessed(ctx, fullSyncPhaseUpdate, updateSpec.VolumeId.Id)
 	}
 }
 
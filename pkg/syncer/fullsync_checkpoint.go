@@ -0,0 +1,255 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/flowcontrol"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsfullsynccheckpoint"
+	checkpointv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsfullsynccheckpoint/v1alpha1"
+)
+
+var (
+	// fullSyncCheckpointService persists the progress of the full syncs.
+	fullSyncCheckpointService cnsfullsynccheckpoint.CheckpointService
+	// activeFullSyncCheckpoints are the checkpoints of the running full syncs
+	// by vCenter, flushed when the syncer shuts down.
+	activeFullSyncCheckpoints     = make(map[string]*fullSyncCheckpoint)
+	activeFullSyncCheckpointsLock sync.Mutex
+)
+
+// fullSyncPhase is a phase of full sync whose progress is checkpointed.
+type fullSyncPhase string
+
+const (
+	fullSyncPhaseCreate fullSyncPhase = "create"
+	fullSyncPhaseUpdate fullSyncPhase = "update"
+	fullSyncPhaseDelete fullSyncPhase = "delete"
+)
+
+// fullSyncCheckpoint tracks the progress of each phase of the full sync of a
+// vCenter, and periodically persists it in the CnsFullSyncCheckpoint of the
+// vCenter so that a full sync interrupted by a restart of the syncer does not
+// process the same volumes again. The phases process the volumes in the order
+// of their IDs, so that the progress of a phase is the cursor of the last
+// volume it processed. Volumes which failed, or were added with a lower ID
+// since the interrupted full sync started, are processed by the next full
+// sync. A nil fullSyncCheckpoint tracks nothing.
+type fullSyncCheckpoint struct {
+	lock      sync.Mutex
+	vc        string
+	startTime metav1.Time
+	// cursors are the IDs of the last volumes processed by each phase.
+	cursors map[fullSyncPhase]string
+	// pending is the number of volumes processed since the last save.
+	pending int
+}
+
+// fullSyncRateLimiters limit the rate of the CNS operations of each phase of
+// full sync. A nil rate limiter doesn't limit its phase.
+type fullSyncRateLimiters struct {
+	create flowcontrol.RateLimiter
+	update flowcontrol.RateLimiter
+	delete flowcontrol.RateLimiter
+}
+
+// loadFullSyncCheckpoint returns the checkpoint of a new full sync of the
+// given vCenter, which resumes the checkpoint of the previous full sync if it
+// was interrupted less than fullSyncCheckpointMaxAge after it started.
+func loadFullSyncCheckpoint(ctx context.Context, vc string) (*fullSyncCheckpoint, error) {
+	log := logger.GetLogger(ctx)
+	if fullSyncCheckpointService == nil {
+		service, err := cnsfullsynccheckpoint.InitCheckpointService(ctx)
+		if err != nil {
+			return nil, err
+		}
+		fullSyncCheckpointService = service
+	}
+	instance, err := fullSyncCheckpointService.GetCheckpoint(ctx, vc)
+	if err != nil {
+		return nil, err
+	}
+	checkpoint := &fullSyncCheckpoint{
+		vc:        vc,
+		startTime: metav1.Now(),
+		cursors:   make(map[fullSyncPhase]string),
+	}
+	if instance != nil {
+		if time.Since(instance.Spec.StartTime.Time) > fullSyncCheckpointMaxAge {
+			log.Infof("FullSync for VC %s: discarding the checkpoint of the full sync started at %v",
+				vc, instance.Spec.StartTime)
+		} else {
+			checkpoint.startTime = instance.Spec.StartTime
+			for phase, phaseCheckpoint := range instance.Spec.Phases {
+				checkpoint.cursors[fullSyncPhase(phase)] = phaseCheckpoint.LastProcessedVolumeID
+			}
+			log.Infof("FullSync for VC %s: resuming the full sync started at %v from cursors %v",
+				vc, instance.Spec.StartTime, checkpoint.cursors)
+		}
+	}
+	checkpoint.lock.Lock()
+	defer checkpoint.lock.Unlock()
+	if err := checkpoint.saveLocked(ctx); err != nil {
+		return nil, err
+	}
+	activeFullSyncCheckpointsLock.Lock()
+	activeFullSyncCheckpoints[vc] = checkpoint
+	activeFullSyncCheckpointsLock.Unlock()
+	return checkpoint, nil
+}
+
+// isProcessed returns true if the given volume was already processed by the
+// given phase of the full sync.
+func (checkpoint *fullSyncCheckpoint) isProcessed(phase fullSyncPhase, volumeID string) bool {
+	if checkpoint == nil {
+		return false
+	}
+	checkpoint.lock.Lock()
+	defer checkpoint.lock.Unlock()
+	cursor := checkpoint.cursors[phase]
+	return cursor != "" && volumeID <= cursor
+}
+
+// markProcessed advances the cursor of the given phase to the given volume,
+// and saves the checkpoint every fullSyncCheckpointFlushInterval volumes.
+func (checkpoint *fullSyncCheckpoint) markProcessed(ctx context.Context, phase fullSyncPhase, volumeID string) {
+	if checkpoint == nil {
+		return
+	}
+	log := logger.GetLogger(ctx)
+	checkpoint.lock.Lock()
+	defer checkpoint.lock.Unlock()
+	if volumeID > checkpoint.cursors[phase] {
+		checkpoint.cursors[phase] = volumeID
+	}
+	checkpoint.pending++
+	if checkpoint.pending >= fullSyncCheckpointFlushInterval {
+		if err := checkpoint.saveLocked(ctx); err != nil {
+			log.Warnf("FullSync for VC %s: failed to save the full sync checkpoint. Err: %v",
+				checkpoint.vc, err)
+		}
+	}
+}
+
+// complete deletes the checkpoint of a full sync which went through all the
+// volumes, so that the next full sync starts over.
+func (checkpoint *fullSyncCheckpoint) complete(ctx context.Context) {
+	if checkpoint == nil {
+		return
+	}
+	log := logger.GetLogger(ctx)
+	activeFullSyncCheckpointsLock.Lock()
+	if activeFullSyncCheckpoints[checkpoint.vc] == checkpoint {
+		delete(activeFullSyncCheckpoints, checkpoint.vc)
+	}
+	activeFullSyncCheckpointsLock.Unlock()
+	if err := fullSyncCheckpointService.DeleteCheckpoint(ctx, checkpoint.vc); err != nil {
+		log.Warnf("FullSync for VC %s: failed to delete the full sync checkpoint. Err: %v", checkpoint.vc, err)
+	}
+}
+
+// flushFullSyncCheckpoints saves the progress made by the running full syncs
+// since their last save, so that the full syncs resume from there after the
+// syncer restarts.
+func flushFullSyncCheckpoints(ctx context.Context) {
+	log := logger.GetLogger(ctx)
+	activeFullSyncCheckpointsLock.Lock()
+	checkpoints := make([]*fullSyncCheckpoint, 0, len(activeFullSyncCheckpoints))
+	for _, checkpoint := range activeFullSyncCheckpoints {
+		checkpoints = append(checkpoints, checkpoint)
+	}
+	activeFullSyncCheckpointsLock.Unlock()
+	for _, checkpoint := range checkpoints {
+		checkpoint.lock.Lock()
+		if checkpoint.pending > 0 {
+			if err := checkpoint.saveLocked(ctx); err != nil {
+				log.Warnf("FullSync for VC %s: failed to save the full sync checkpoint. Err: %v",
+					checkpoint.vc, err)
+			}
+		}
+		checkpoint.lock.Unlock()
+	}
+}
+
+// saveLocked persists the checkpoint. It must be called with the lock held.
+func (checkpoint *fullSyncCheckpoint) saveLocked(ctx context.Context) error {
+	phases := make(map[string]checkpointv1alpha1.CnsFullSyncPhaseCheckpoint, len(checkpoint.cursors))
+	for phase, cursor := range checkpoint.cursors {
+		phases[string(phase)] = checkpointv1alpha1.CnsFullSyncPhaseCheckpoint{LastProcessedVolumeID: cursor}
+	}
+	err := fullSyncCheckpointService.SaveCheckpoint(ctx, checkpointv1alpha1.CnsFullSyncCheckpointSpec{
+		VCenterServer: checkpoint.vc,
+		StartTime:     checkpoint.startTime,
+		Phases:        phases,
+	})
+	if err != nil {
+		return err
+	}
+	checkpoint.pending = 0
+	return nil
+}
+
+// newFullSyncRateLimiters returns the rate limiters of the phases of a full
+// sync. The rates default to defaultFullSyncCreateVolumeQPS,
+// defaultFullSyncUpdateVolumeQPS and defaultFullSyncDeleteVolumeQPS, and can
+// be overridden with the environment variables FULL_SYNC_CREATE_VOLUME_QPS,
+// FULL_SYNC_UPDATE_VOLUME_QPS and FULL_SYNC_DELETE_VOLUME_QPS.
+func newFullSyncRateLimiters(ctx context.Context) fullSyncRateLimiters {
+	return fullSyncRateLimiters{
+		create: newFullSyncRateLimiter(ctx, "FULL_SYNC_CREATE_VOLUME_QPS", defaultFullSyncCreateVolumeQPS),
+		update: newFullSyncRateLimiter(ctx, "FULL_SYNC_UPDATE_VOLUME_QPS", defaultFullSyncUpdateVolumeQPS),
+		delete: newFullSyncRateLimiter(ctx, "FULL_SYNC_DELETE_VOLUME_QPS", defaultFullSyncDeleteVolumeQPS),
+	}
+}
+
+// newFullSyncRateLimiter returns a rate limiter allowing the number of
+// operations per second set in the given environment variable, or defaultQPS
+// if it is not set or invalid.
+func newFullSyncRateLimiter(ctx context.Context, envVar string, defaultQPS float32) flowcontrol.RateLimiter {
+	log := logger.GetLogger(ctx)
+	qps := defaultQPS
+	if v := os.Getenv(envVar); v != "" {
+		if value, err := strconv.ParseFloat(v, 32); err == nil && value > 0 {
+			qps = float32(value)
+			log.Infof("FullSync: rate limit set in env variable %s is %v operations per second", envVar, qps)
+		} else {
+			log.Warnf("FullSync: rate limit set in env variable %s %s is invalid, will use the default "+
+				"rate limit of %v operations per second", envVar, v, defaultQPS)
+		}
+	}
+	burst := int(qps)
+	if burst < 1 {
+		burst = 1
+	}
+	return flowcontrol.NewTokenBucketRateLimiter(qps, burst)
+}
+
+// waitFullSyncRateLimiter blocks until the given rate limiter allows one more
+// CNS operation.
+func waitFullSyncRateLimiter(limiter flowcontrol.RateLimiter) {
+	if limiter != nil {
+		limiter.Accept()
+	}
+}
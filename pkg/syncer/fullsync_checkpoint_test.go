@@ -0,0 +1,120 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	checkpointv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsfullsynccheckpoint/v1alpha1"
+)
+
+// fakeCheckpointService keeps the CnsFullSyncCheckpoints in memory.
+type fakeCheckpointService struct {
+	checkpoints map[string]*checkpointv1alpha1.CnsFullSyncCheckpoint
+}
+
+func (f *fakeCheckpointService) GetCheckpoint(ctx context.Context,
+	vCenter string) (*checkpointv1alpha1.CnsFullSyncCheckpoint, error) {
+	return f.checkpoints[vCenter], nil
+}
+
+func (f *fakeCheckpointService) SaveCheckpoint(ctx context.Context,
+	spec checkpointv1alpha1.CnsFullSyncCheckpointSpec) error {
+	f.checkpoints[spec.VCenterServer] = &checkpointv1alpha1.CnsFullSyncCheckpoint{Spec: *spec.DeepCopy()}
+	return nil
+}
+
+func (f *fakeCheckpointService) DeleteCheckpoint(ctx context.Context, vCenter string) error {
+	delete(f.checkpoints, vCenter)
+	return nil
+}
+
+func TestFullSyncCheckpointPhases(t *testing.T) {
+	ctx := context.Background()
+	service := &fakeCheckpointService{checkpoints: make(map[string]*checkpointv1alpha1.CnsFullSyncCheckpoint)}
+	fullSyncCheckpointService = service
+	defer func() { fullSyncCheckpointService = nil }()
+
+	checkpoint, err := loadFullSyncCheckpoint(ctx, "vc-1")
+	if err != nil {
+		t.Fatalf("failed to load checkpoint: %v", err)
+	}
+	// Enough volumes are processed by the update phase for the checkpoint to
+	// be saved, while the create phase processed a single one.
+	checkpoint.markProcessed(ctx, fullSyncPhaseCreate, "volume-0001")
+	for i := 1; i < fullSyncCheckpointFlushInterval; i++ {
+		checkpoint.markProcessed(ctx, fullSyncPhaseUpdate, fmt.Sprintf("volume-%04d", i))
+	}
+	phases := service.checkpoints["vc-1"].Spec.Phases
+	if len(phases) != 2 || phases[string(fullSyncPhaseCreate)].LastProcessedVolumeID != "volume-0001" ||
+		phases[string(fullSyncPhaseUpdate)].LastProcessedVolumeID != "volume-0099" {
+		t.Fatalf("unexpected saved phases %v", phases)
+	}
+
+	// The resumed full sync skips the volumes processed by each phase only.
+	resumed, err := loadFullSyncCheckpoint(ctx, "vc-1")
+	if err != nil {
+		t.Fatalf("failed to resume checkpoint: %v", err)
+	}
+	for _, test := range []struct {
+		phase     fullSyncPhase
+		volumeID  string
+		processed bool
+	}{
+		{fullSyncPhaseCreate, "volume-0001", true},
+		{fullSyncPhaseCreate, "volume-0002", false},
+		{fullSyncPhaseUpdate, "volume-0002", true},
+		{fullSyncPhaseUpdate, "volume-0100", false},
+		{fullSyncPhaseDelete, "volume-0001", false},
+	} {
+		if processed := resumed.isProcessed(test.phase, test.volumeID); processed != test.processed {
+			t.Errorf("expected volume %q processed by phase %q to be %v", test.volumeID, test.phase,
+				test.processed)
+		}
+	}
+
+	resumed.complete(ctx)
+	if _, ok := service.checkpoints["vc-1"]; ok {
+		t.Errorf("expected the checkpoint of the completed full sync to be deleted")
+	}
+}
+
+func TestFlushFullSyncCheckpoints(t *testing.T) {
+	ctx := context.Background()
+	service := &fakeCheckpointService{checkpoints: make(map[string]*checkpointv1alpha1.CnsFullSyncCheckpoint)}
+	fullSyncCheckpointService = service
+	defer func() { fullSyncCheckpointService = nil }()
+
+	checkpoint, err := loadFullSyncCheckpoint(ctx, "vc-1")
+	if err != nil {
+		t.Fatalf("failed to load checkpoint: %v", err)
+	}
+	defer checkpoint.complete(ctx)
+	// Too few volumes are processed for the checkpoint to be saved, until it
+	// is flushed.
+	checkpoint.markProcessed(ctx, fullSyncPhaseCreate, "volume-0001")
+	if phases := service.checkpoints["vc-1"].Spec.Phases; len(phases) != 0 {
+		t.Fatalf("expected no saved phases before the flush, got %v", phases)
+	}
+	flushFullSyncCheckpoints(ctx)
+	phases := service.checkpoints["vc-1"].Spec.Phases
+	if phases[string(fullSyncPhaseCreate)].LastProcessedVolumeID != "volume-0001" {
+		t.Errorf("unexpected saved phases after the flush %v", phases)
+	}
+}
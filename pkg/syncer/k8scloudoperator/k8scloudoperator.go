@@ -248,7 +248,7 @@ func (k8sCloudOperator *k8sCloudOperator) getPVWithVolumeID(ctx context.Context,
 	for _, pv := range allPVs.Items {
 		// Verify if it is vsphere block driver and volumehandle matches the
 		// volume ID.
-		if pv.Spec.CSI != nil && pv.Spec.CSI.Driver == csitypes.Name && pv.Spec.CSI.VolumeHandle == volumeID {
+		if pv.Spec.CSI != nil && pv.Spec.CSI.Driver == csitypes.DriverName() && pv.Spec.CSI.VolumeHandle == volumeID {
 			log.Debugf("Found PV: %+v referring to volume ID: %s", pv, volumeID)
 			return &pv, nil
 		}
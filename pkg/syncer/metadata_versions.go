@@ -0,0 +1,177 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+
+	volumes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+// metadataUpdateSource is the sync path which wrote the metadata of a volume.
+type metadataUpdateSource string
+
+const (
+	metadataUpdateSourceIncremental metadataUpdateSource = "MetadataSyncer"
+	metadataUpdateSourceFullSync    metadataUpdateSource = "FullSync"
+)
+
+// volumeMetadataRecord is the version of the metadata last written to CNS for
+// a volume.
+type volumeMetadataRecord struct {
+	// version is incremented on each successful update of the metadata.
+	version uint64
+	// updatedAt is the time at which CNS acknowledged the last update.
+	updatedAt time.Time
+	source    metadataUpdateSource
+}
+
+// volumeMetadataTracker coordinates the updates of the volume metadata made
+// by full sync and by the metadata syncer.
+//
+// Full sync computes its updates from the Kubernetes objects listed at the
+// start of the cycle, which may be older than the metadata written since by
+// the metadata syncer. Updates of a volume are serialized, and an update of
+// full sync is dropped if the metadata of the volume was written after full
+// sync listed the Kubernetes objects, so that the last writer wins and both
+// paths converge on the most recent metadata.
+type volumeMetadataTracker struct {
+	lock    sync.Mutex
+	records map[string]*volumeMetadataRecord
+	// volumeLocks serializes the updates of each volume.
+	volumeLocks map[string]*sync.Mutex
+}
+
+// metadataTracker is the volume metadata tracker shared by full sync and the
+// metadata syncer.
+var metadataTracker = newVolumeMetadataTracker()
+
+func newVolumeMetadataTracker() *volumeMetadataTracker {
+	return &volumeMetadataTracker{
+		records:     make(map[string]*volumeMetadataRecord),
+		volumeLocks: make(map[string]*sync.Mutex),
+	}
+}
+
+// lockVolume locks the updates of the given volume and returns the function
+// unlocking them.
+func (t *volumeMetadataTracker) lockVolume(volumeID string) func() {
+	t.lock.Lock()
+	volumeLock, ok := t.volumeLocks[volumeID]
+	if !ok {
+		volumeLock = &sync.Mutex{}
+		t.volumeLocks[volumeID] = volumeLock
+	}
+	t.lock.Unlock()
+	volumeLock.Lock()
+	return volumeLock.Unlock
+}
+
+// getRecord returns a copy of the record of the given volume, if any.
+func (t *volumeMetadataTracker) getRecord(volumeID string) (volumeMetadataRecord, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	record, ok := t.records[volumeID]
+	if !ok {
+		return volumeMetadataRecord{}, false
+	}
+	return *record, true
+}
+
+// update writes the metadata of the given volume with updateFn, unless the
+// update comes from full sync and the metadata of the volume was written after
+// snapshotTime, the time at which full sync listed the Kubernetes objects.
+// It returns whether the update was applied.
+func (t *volumeMetadataTracker) update(ctx context.Context, volumeID string, source metadataUpdateSource,
+	snapshotTime time.Time, updateFn func() error) (bool, error) {
+	log := logger.GetLogger(ctx)
+	unlock := t.lockVolume(volumeID)
+	defer unlock()
+	record, found := t.getRecord(volumeID)
+	if source == metadataUpdateSourceFullSync && found && record.updatedAt.After(snapshotTime) {
+		log.Infof("Skipping metadata update of volume %q by %s: metadata version %d was written by %s "+
+			"at %v, after the snapshot taken at %v", volumeID, source, record.version, record.source,
+			record.updatedAt, snapshotTime)
+		return false, nil
+	}
+	if err := updateFn(); err != nil {
+		return false, err
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	newRecord, ok := t.records[volumeID]
+	if !ok {
+		newRecord = &volumeMetadataRecord{}
+		t.records[volumeID] = newRecord
+	}
+	newRecord.version++
+	newRecord.updatedAt = time.Now()
+	newRecord.source = source
+	log.Debugf("Metadata of volume %q updated by %s to version %d", volumeID, source, newRecord.version)
+	return true, nil
+}
+
+// forget removes the record and the lock of the given volume, once the volume
+// is deleted.
+func (t *volumeMetadataTracker) forget(volumeID string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	delete(t.records, volumeID)
+	delete(t.volumeLocks, volumeID)
+}
+
+// prune removes the records of the given volumes written before the given
+// time. Such records can no longer make an update of full sync be dropped,
+// once a full sync cycle of the vCenter of the volumes, listing the Kubernetes
+// objects after that time, has completed. The locks of the volumes are kept,
+// as an update may be waiting on them.
+func (t *volumeMetadataTracker) prune(before time.Time, volumeIDs map[string]string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for volumeID := range volumeIDs {
+		if record, ok := t.records[volumeID]; ok && record.updatedAt.Before(before) {
+			delete(t.records, volumeID)
+		}
+	}
+}
+
+// isFullSyncConflictResolutionEnabled returns whether the volume metadata
+// updates go through the metadata tracker.
+func isFullSyncConflictResolutionEnabled(ctx context.Context, metadataSyncer *metadataSyncInformer) bool {
+	return metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.FullSyncConflictResolution)
+}
+
+// updateVolumeMetadata updates the metadata of a volume on behalf of the
+// metadata syncer, recording the new version of the metadata so that a full
+// sync cycle in progress does not overwrite it.
+func updateVolumeMetadata(ctx context.Context, metadataSyncer *metadataSyncInformer, cnsVolumeMgr volumes.Manager,
+	updateSpec *cnstypes.CnsVolumeMetadataUpdateSpec) error {
+	if !isFullSyncConflictResolutionEnabled(ctx, metadataSyncer) {
+		return cnsVolumeMgr.UpdateVolumeMetadata(ctx, updateSpec)
+	}
+	_, err := metadataTracker.update(ctx, updateSpec.VolumeId.Id, metadataUpdateSourceIncremental, time.Time{},
+		func() error {
+			return cnsVolumeMgr.UpdateVolumeMetadata(ctx, updateSpec)
+		})
+	return err
+}
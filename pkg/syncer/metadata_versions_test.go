@@ -0,0 +1,73 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVolumeMetadataTrackerLastWriterWins(t *testing.T) {
+	ctx := context.Background()
+	tracker := newVolumeMetadataTracker()
+	var writes []metadataUpdateSource
+	writeFn := func(source metadataUpdateSource) func() error {
+		return func() error {
+			writes = append(writes, source)
+			return nil
+		}
+	}
+
+	// Full sync lists the Kubernetes objects, then the metadata syncer writes
+	// more recent metadata before full sync updates the volume.
+	snapshotTime := time.Now()
+	applied, err := tracker.update(ctx, "vol-1", metadataUpdateSourceIncremental, time.Time{},
+		writeFn(metadataUpdateSourceIncremental))
+	assert.NoError(t, err)
+	assert.True(t, applied)
+	applied, err = tracker.update(ctx, "vol-1", metadataUpdateSourceFullSync, snapshotTime,
+		writeFn(metadataUpdateSourceFullSync))
+	assert.NoError(t, err)
+	assert.False(t, applied)
+	assert.Equal(t, []metadataUpdateSource{metadataUpdateSourceIncremental}, writes)
+
+	// The next full sync cycle lists the Kubernetes objects after the update.
+	applied, err = tracker.update(ctx, "vol-1", metadataUpdateSourceFullSync, time.Now(),
+		writeFn(metadataUpdateSourceFullSync))
+	assert.NoError(t, err)
+	assert.True(t, applied)
+	record, found := tracker.getRecord("vol-1")
+	assert.True(t, found)
+	assert.Equal(t, uint64(2), record.version)
+	assert.Equal(t, metadataUpdateSourceFullSync, record.source)
+
+	// Failed updates are not recorded.
+	applied, err = tracker.update(ctx, "vol-2", metadataUpdateSourceIncremental, time.Time{},
+		func() error { return errors.New("update failed") })
+	assert.Error(t, err)
+	assert.False(t, applied)
+	_, found = tracker.getRecord("vol-2")
+	assert.False(t, found)
+
+	tracker.prune(time.Now(), map[string]string{"vol-1": ""})
+	_, found = tracker.getRecord("vol-1")
+	assert.False(t, found)
+}
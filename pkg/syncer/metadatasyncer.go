@@ -40,7 +40,6 @@ import (
 	"k8s.io/client-go/informers"
 	clientset "k8s.io/client-go/kubernetes"
 	restclient "k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
@@ -63,6 +62,7 @@ import (
 	cnsvolumeinfov1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsvolumeinfo/v1alpha1"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsvolumeoperationrequest"
 	cnsvolumeoperationrequestv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsvolumeoperationrequest/v1alpha1"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/csidriverconfig"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/csinodetopology"
 	csinodetopologyv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/csinodetopology/v1alpha1"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/featurestates"
@@ -81,6 +81,9 @@ var (
 
 	// MetadataSyncer instance for the syncer container.
 	MetadataSyncer *metadataSyncInformer
+	// SyncerSubsystems are the subsystems run by the syncer container, as
+	// declared by its operation mode.
+	SyncerSubsystems = common.OperationModeSubsystems{VolumeMaps: true, MetadataSync: true, Operators: true}
 
 	// Contains list of clusterComputeResourceMoIds on which supervisor cluster is deployed.
 	clusterComputeResourceMoIds = make([]string, 0)
@@ -114,8 +117,9 @@ func newInformer() *metadataSyncInformer {
 }
 
 // getFullSyncIntervalInMin returns the FullSyncInterval.
-// If environment variable FULL_SYNC_INTERVAL_MINUTES is set and valid,
-// return the interval value read from environment variable.
+// If the CSIDriverConfig sets the interval, return it. Else if environment
+// variable FULL_SYNC_INTERVAL_MINUTES is set and valid, return the interval
+// value read from environment variable.
 // Otherwise, use the default value 30 minutes.
 func getFullSyncIntervalInMin(ctx context.Context) int {
 	log := logger.GetLogger(ctx)
@@ -137,12 +141,13 @@ func getFullSyncIntervalInMin(ctx context.Context) int {
 				"is invalid, will use the default interval", v)
 		}
 	}
-	return fullSyncIntervalInMin
+	return csidriverconfig.FullSyncIntervalInMin(fullSyncIntervalInMin)
 }
 
 // getVolumeHealthIntervalInMin returns the VolumeHealthInterval.
-// If environment variable VOLUME_HEALTH_STATUS_INTERVAL_MINUTES is set and valid,
-// return the interval value read from environment variable.
+// If the CSIDriverConfig sets the interval, return it. Else if environment
+// variable VOLUME_HEALTH_STATUS_INTERVAL_MINUTES is set and valid, return the
+// interval value read from environment variable.
 // Otherwise, use the default value 5 minutes.
 func getVolumeHealthIntervalInMin(ctx context.Context) int {
 	log := logger.GetLogger(ctx)
@@ -161,7 +166,7 @@ func getVolumeHealthIntervalInMin(ctx context.Context) int {
 				"is invalid, will use the default interval", v)
 		}
 	}
-	return volumeHealthIntervalInMin
+	return csidriverconfig.VolumeHealthIntervalInMin(volumeHealthIntervalInMin)
 }
 
 // getPVtoBackingDiskObjectIdIntervalInMin returns pv to backingdiskobjectid interval.
@@ -187,6 +192,21 @@ func getPVtoBackingDiskObjectIdIntervalInMin(ctx context.Context) int {
 	return pvtoBackingDiskObjectIdIntervalInMin
 }
 
+// resetTickerOnIntervalChange resets the given ticker if the interval in
+// minutes returned by getInterval differs from the current one, e.g. after
+// it was changed in the CSIDriverConfig, and updates the current interval.
+func resetTickerOnIntervalChange(ctx context.Context, ticker *time.Ticker, currentIntervalInMin *int,
+	getInterval func(ctx context.Context) int) {
+	log := logger.GetLogger(ctx)
+	intervalInMin := getInterval(ctx)
+	if intervalInMin == *currentIntervalInMin {
+		return
+	}
+	log.Infof("Interval changed from %d to %d minutes. Resetting the ticker", *currentIntervalInMin, intervalInMin)
+	ticker.Reset(time.Duration(intervalInMin) * time.Minute)
+	*currentIntervalInMin = intervalInMin
+}
+
 // InitMetadataSyncer initializes the Metadata Sync Informer.
 func InitMetadataSyncer(ctx context.Context, clusterFlavor cnstypes.CnsClusterFlavor,
 	configInfo *cnsconfig.ConfigurationInfo) error {
@@ -515,7 +535,9 @@ func InitMetadataSyncer(ctx context.Context, clusterFlavor cnstypes.CnsClusterFl
 	}
 	err = metadataSyncer.k8sInformerManager.AddPVListener(
 		ctx,
-		nil, // Add.
+		func(obj interface{}) { // Add.
+			pvAdded(obj, k8sClient, metadataSyncer)
+		},
 		func(oldObj interface{}, newObj interface{}) { // Update.
 			pvUpdated(oldObj, newObj, metadataSyncer)
 		},
@@ -549,12 +571,23 @@ func InitMetadataSyncer(ctx context.Context, clusterFlavor cnstypes.CnsClusterFl
 	}
 	log.Infof("Initialized metadata syncer")
 
-	fullSyncTicker := time.NewTicker(time.Duration(getFullSyncIntervalInMin(ctx)) * time.Minute)
+	if metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.CSIDriverConfig) {
+		err = csidriverconfig.StartCSIDriverConfigService(ctx)
+		if err != nil {
+			return logger.LogNewErrorf(log, "failed to start CSIDriverConfig service. Error: %v", err)
+		}
+	}
+
+	fullSyncIntervalInMin := getFullSyncIntervalInMin(ctx)
+	fullSyncTicker := time.NewTicker(time.Duration(fullSyncIntervalInMin) * time.Minute)
 	defer fullSyncTicker.Stop()
 	// Trigger full sync.
 	// If TriggerCsiFullSync feature gate is enabled, use TriggerCsiFullSync to
 	// trigger full sync. If not, directly invoke full sync methods.
-	if metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.TriggerCsiFullSync) {
+	// The TriggerCsiFullSync API is served by the CNS operators, which may run
+	// in another container.
+	if metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.TriggerCsiFullSync) &&
+		SyncerSubsystems.Operators {
 		log.Infof("%q feature flag is enabled. Using TriggerCsiFullSync API to trigger full sync",
 			common.TriggerCsiFullSync)
 		// Get a config to talk to the apiserver.
@@ -572,6 +605,7 @@ func InitMetadataSyncer(ctx context.Context, clusterFlavor cnstypes.CnsClusterFl
 		go func() {
 			for ; true; <-fullSyncTicker.C {
 				ctx, log = logger.GetNewContextWithLogger()
+				resetTickerOnIntervalChange(ctx, fullSyncTicker, &fullSyncIntervalInMin, getFullSyncIntervalInMin)
 				log.Infof("periodic fullSync is triggered")
 				triggerCsiFullSyncInstance, err := getTriggerCsiFullSyncInstance(ctx, cnsOperatorClient)
 				if err != nil {
@@ -604,6 +638,7 @@ func InitMetadataSyncer(ctx context.Context, clusterFlavor cnstypes.CnsClusterFl
 
 		go func() {
 			for ; true; <-fullSyncTicker.C {
+				resetTickerOnIntervalChange(ctx, fullSyncTicker, &fullSyncIntervalInMin, getFullSyncIntervalInMin)
 				log.Infof("fullSync is triggered")
 				if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorGuest {
 					err := PvcsiFullSync(ctx, metadataSyncer)
@@ -718,7 +753,127 @@ func InitMetadataSyncer(ctx context.Context, clusterFlavor cnstypes.CnsClusterFl
 		}
 	}
 
-	volumeHealthTicker := time.NewTicker(time.Duration(getVolumeHealthIntervalInMin(ctx)) * time.Minute)
+	// Trigger volume IO statistics collection on vanilla cluster.
+	if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorVanilla &&
+		metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.VolumeIOStats) {
+		volumeIOStatsTicker := time.NewTicker(time.Duration(getVolumeIOStatsIntervalInMin(ctx)) * time.Minute)
+		defer volumeIOStatsTicker.Stop()
+		go func() {
+			for ; true; <-volumeIOStatsTicker.C {
+				ctx, log := logger.GetNewContextWithLogger()
+				log.Debug("volume IO statistics collection is triggered")
+				csiCollectVolumeIOStats(ctx, k8sClient, metadataSyncer)
+			}
+		}()
+	}
+
+	// Report the significant events of the volumes to vCenter.
+	if metadataSyncer.clusterFlavor != cnstypes.CnsClusterFlavorGuest &&
+		metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.VCenterEventReporter) {
+		err = startVCenterEventReporter(ctx, k8sClient, metadataSyncer)
+		if err != nil {
+			return logger.LogNewErrorf(log, "failed to start vCenter event reporter. Err: %v", err)
+		}
+	}
+
+	// Trigger purge of expired archived and retained volumes on vanilla cluster.
+	archiveReclaimEnabled := metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.ArchiveReclaim)
+	deletionProtectionEnabled := metadataSyncer.coCommonInterface.IsFSSEnabled(ctx,
+		common.VolumeDeletionProtection)
+	if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorVanilla &&
+		(archiveReclaimEnabled || deletionProtectionEnabled) {
+		archivedVolumeGCTicker := time.NewTicker(time.Duration(getArchivedVolumeGCIntervalInMin(ctx)) * time.Minute)
+		defer archivedVolumeGCTicker.Stop()
+		go func() {
+			for ; true; <-archivedVolumeGCTicker.C {
+				ctx, log := logger.GetNewContextWithLogger()
+				if archiveReclaimEnabled {
+					log.Debug("purge of expired archived volumes is triggered")
+					csiPurgeArchivedVolumes(ctx, metadataSyncer)
+				}
+				if deletionProtectionEnabled {
+					log.Debug("purge of expired retained volumes is triggered")
+					csiPurgeRetainedVolumes(ctx, metadataSyncer)
+				}
+			}
+		}()
+	}
+
+	// Trigger generation of StorageClasses from storage policies on vanilla cluster.
+	if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorVanilla &&
+		metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.StoragePolicyStorageClassMapper) {
+		storagePolicyMapperTicker := time.NewTicker(
+			time.Duration(getStoragePolicyMapperIntervalInMin(ctx)) * time.Minute)
+		defer storagePolicyMapperTicker.Stop()
+		go func() {
+			for ; true; <-storagePolicyMapperTicker.C {
+				ctx, log := logger.GetNewContextWithLogger()
+				log.Debug("mapping of storage policies to StorageClasses is triggered")
+				csiMapStoragePoliciesToStorageClasses(ctx, k8sClient, metadataSyncer)
+			}
+		}()
+	}
+
+	// Trigger the check of the accessibility of the PVs from the nodes on vanilla cluster.
+	if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorVanilla &&
+		metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.PVAccessibilityReporting) {
+		pvAccessibilityTicker := time.NewTicker(time.Duration(getPVAccessibilityIntervalInMin(ctx)) * time.Minute)
+		defer pvAccessibilityTicker.Stop()
+		go func() {
+			for ; true; <-pvAccessibilityTicker.C {
+				ctx, log := logger.GetNewContextWithLogger()
+				log.Debug("check of the accessibility of the PVs from the nodes is triggered")
+				csiReportPVAccessibility(ctx, k8sClient, metadataSyncer)
+			}
+		}()
+	}
+
+	// Trigger the collection of the VolumeAttachments of deleted nodes on vanilla cluster.
+	if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorVanilla &&
+		metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.StaleVolumeAttachmentGC) {
+		staleVolumeAttachmentGCTicker := time.NewTicker(
+			time.Duration(getStaleVolumeAttachmentGCIntervalInMin(ctx)) * time.Minute)
+		defer staleVolumeAttachmentGCTicker.Stop()
+		go func() {
+			for ; true; <-staleVolumeAttachmentGCTicker.C {
+				ctx, log := logger.GetNewContextWithLogger()
+				log.Debug("collection of stale VolumeAttachments is triggered")
+				csiCollectStaleVolumeAttachments(ctx, k8sClient)
+			}
+		}()
+	}
+
+	// Trigger the update of the node affinity of relocated PVs on vanilla cluster.
+	if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorVanilla &&
+		metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.RelocatedPVNodeAffinity) {
+		relocatedPVNodeAffinityTicker := time.NewTicker(
+			time.Duration(getRelocatedPVNodeAffinityIntervalInMin(ctx)) * time.Minute)
+		defer relocatedPVNodeAffinityTicker.Stop()
+		go func() {
+			for ; true; <-relocatedPVNodeAffinityTicker.C {
+				ctx, log := logger.GetNewContextWithLogger()
+				log.Debug("update of the node affinity of relocated PVs is triggered")
+				csiUpdateRelocatedPVNodeAffinity(ctx, k8sClient, metadataSyncer)
+			}
+		}()
+	}
+
+	// Trigger the reporting of the anonymized usage of the driver on vanilla cluster.
+	if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorVanilla &&
+		metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.UsageReporting) {
+		usageReportTicker := time.NewTicker(time.Duration(getUsageReportIntervalInMin(ctx)) * time.Minute)
+		defer usageReportTicker.Stop()
+		go func() {
+			for ; true; <-usageReportTicker.C {
+				ctx, log := logger.GetNewContextWithLogger()
+				log.Debug("reporting of the usage of the driver is triggered")
+				csiReportUsage(ctx, metadataSyncer)
+			}
+		}()
+	}
+
+	volumeHealthIntervalInMin := getVolumeHealthIntervalInMin(ctx)
+	volumeHealthTicker := time.NewTicker(time.Duration(volumeHealthIntervalInMin) * time.Minute)
 	defer volumeHealthTicker.Stop()
 
 	// Trigger get volume health status.
@@ -726,6 +881,8 @@ func InitMetadataSyncer(ctx context.Context, clusterFlavor cnstypes.CnsClusterFl
 		go func() {
 			for ; true; <-volumeHealthTicker.C {
 				ctx, log = logger.GetNewContextWithLogger()
+				resetTickerOnIntervalChange(ctx, volumeHealthTicker, &volumeHealthIntervalInMin,
+					getVolumeHealthIntervalInMin)
 				if !metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.VolumeHealth) {
 					log.Warnf("VolumeHealth feature is disabled on the cluster")
 				} else {
@@ -791,17 +948,9 @@ func initCnsVolumeOperationRequestCRInformer(ctx context.Context, cfg *restclien
 			cnsvolumeoperationrequest.CRDSingular, err)
 	}
 	cnsvolumeoperationrequestInformer := dynInformer.Informer()
-	_, err = cnsvolumeoperationrequestInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc: func(obj interface{}) {
-			cnsvolumeoperationrequestCRAdded(obj)
-		},
-		UpdateFunc: func(oldObj interface{}, newObj interface{}) {
-			cnsvolumeoperationrequestCRUpdated(oldObj, newObj)
-		},
-		DeleteFunc: func(obj interface{}) {
-			cnsvolumeoperationrequestCRDeleted(obj)
-		},
-	})
+	_, err = cnsvolumeoperationrequestInformer.AddEventHandler(k8s.NewEventHandler(
+		cnsvolumeoperationrequest.CRDPlural, cnsvolumeoperationrequestCRAdded,
+		cnsvolumeoperationrequestCRUpdated, cnsvolumeoperationrequestCRDeleted))
 	if err != nil {
 		return logger.LogNewErrorf(log, "failed to add event handler on informer for %q CR. Error: %v",
 			cnsvolumeoperationrequest.CRDPlural, err)
@@ -826,17 +975,8 @@ func startTopologyCRInformer(ctx context.Context, cfg *restclient.Config) error
 	}
 	csiNodeTopologyInformer := dynInformer.Informer()
 	// TODO: Multi-VC: Use a RWLock to guard simultaneous updates to topologyVCMap
-	_, err = csiNodeTopologyInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc: func(obj interface{}) {
-			topoCRAdded(obj)
-		},
-		UpdateFunc: func(oldObj interface{}, newObj interface{}) {
-			topoCRUpdated(oldObj, newObj)
-		},
-		DeleteFunc: func(obj interface{}) {
-			topoCRDeleted(obj)
-		},
-	})
+	_, err = csiNodeTopologyInformer.AddEventHandler(k8s.NewEventHandler(csinodetopology.CRDPlural,
+		topoCRAdded, topoCRUpdated, topoCRDeleted))
 	if err != nil {
 		return logger.LogNewErrorf(log, "failed to add event handler on informer for %q CR. Error: %v",
 			csinodetopology.CRDPlural, err)
@@ -1167,6 +1307,9 @@ func topoCRAdded(obj interface{}) {
 			csinodetopology.CRDSingular, err)
 		return
 	}
+	if !common.IsOwnCSINodeTopology(nodeTopoObj) {
+		return
+	}
 	// Check if Status is set to Success.
 	if nodeTopoObj.Status.Status != csinodetopologyv1alpha1.CSINodeTopologySuccess {
 		log.Infof("topoCRAdded: CSINodeTopology instance %q not yet ready. Status: %q",
@@ -1199,6 +1342,9 @@ func topoCRUpdated(oldObj interface{}, newObj interface{}) {
 			csinodetopology.CRDSingular, err)
 		return
 	}
+	if !common.IsOwnCSINodeTopology(newNodeTopoObj) {
+		return
+	}
 	// Check if there is any change in the topology labels.
 	oldTopoLabelsMap := make(map[string]string)
 	for _, label := range oldNodeTopoObj.Status.TopologyLabels {
@@ -1240,6 +1386,9 @@ func topoCRDeleted(obj interface{}) {
 			obj, csinodetopology.CRDSingular, err)
 		return
 	}
+	if !common.IsOwnCSINodeTopology(nodeTopoObj) {
+		return
+	}
 	// Delete topology labels from MetadataSyncer.topologyVCMap if the status of the CR was set to Success.
 	if nodeTopoObj.Status.Status == csinodetopologyv1alpha1.CSINodeTopologySuccess {
 		removeLabelsFromTopologyVCMap(ctx, nodeTopoObj)
@@ -1323,7 +1472,7 @@ func getTriggerCsiFullSyncInstance(ctx context.Context,
 	log := logger.GetLogger(ctx)
 	log.Info("get triggercsifullsync instance")
 	triggerCsiFullSyncInstance := &triggercsifullsyncv1alpha1.TriggerCsiFullSync{}
-	key := k8stypes.NamespacedName{Namespace: "", Name: common.TriggerCsiFullSyncCRName}
+	key := k8stypes.NamespacedName{Namespace: "", Name: csitypes.InstanceObjectName(common.TriggerCsiFullSyncCRName)}
 	if err := client.Get(ctx, key, triggerCsiFullSyncInstance); err != nil {
 		log.Errorf("error get triggercsifullsync instance %+v", err)
 		return nil, err
@@ -1615,7 +1764,7 @@ func pvcUpdated(oldObj, newObj interface{}, metadataSyncer *metadataSyncInformer
 			return
 		}
 		// Verify if pv is vsphere csi volume.
-		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != csitypes.Name {
+		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != csitypes.DriverName() {
 			log.Debugf("PVCUpdated: Not a vSphere CSI Volume")
 			return
 		}
@@ -1680,7 +1829,7 @@ func pvcDeleted(obj interface{}, metadataSyncer *metadataSyncInformer) {
 			return
 		}
 		// Verify if pv is vSphere csi volume.
-		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != csitypes.Name {
+		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != csitypes.DriverName() {
 			log.Debugf("PVCDeleted: Not a vSphere CSI Volume")
 			return
 		}
@@ -1759,7 +1908,7 @@ func pvUpdated(oldObj, newObj interface{}, metadataSyncer *metadataSyncInformer)
 			return
 		}
 		// Verify if pv is a vSphere csi volume.
-		if newPv.Spec.CSI == nil || newPv.Spec.CSI.Driver != csitypes.Name {
+		if newPv.Spec.CSI == nil || newPv.Spec.CSI.Driver != csitypes.DriverName() {
 			log.Debugf("PVUpdated: PV is not a vSphere CSI Volume: %+v", newPv)
 			return
 		}
@@ -1821,7 +1970,7 @@ func pvDeleted(obj interface{}, metadataSyncer *metadataSyncInformer) {
 			return
 		}
 		// Verify if pv is a vSphere csi volume.
-		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != csitypes.Name {
+		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != csitypes.DriverName() {
 			log.Debugf("PVDeleted: Not a vSphere CSI Volume. PV: %+v", pv)
 			return
 		}
@@ -2063,7 +2212,7 @@ func csiPVCUpdated(ctx context.Context, pvc *v1.PersistentVolumeClaim,
 	}
 
 	log.Debugf("PVCUpdated: Calling UpdateVolumeMetadata with updateSpec: %+v", spew.Sdump(updateSpec))
-	if err := cnsVolumeMgr.UpdateVolumeMetadata(ctx, updateSpec); err != nil {
+	if err := updateVolumeMetadata(ctx, metadataSyncer, cnsVolumeMgr, updateSpec); err != nil {
 		log.Errorf("PVCUpdated: UpdateVolumeMetadata failed with err %v", err)
 	}
 }
@@ -2136,7 +2285,7 @@ func csiPVCDeleted(ctx context.Context, pvc *v1.PersistentVolumeClaim,
 	log.Debugf("PVCDeleted: Calling UpdateVolumeMetadata for volume %s with updateSpec: %+v",
 		updateSpec.VolumeId.Id, spew.Sdump(updateSpec))
 
-	if err := cnsVolumeMgr.UpdateVolumeMetadata(ctx, updateSpec); err != nil {
+	if err := updateVolumeMetadata(ctx, metadataSyncer, cnsVolumeMgr, updateSpec); err != nil {
 		log.Errorf("PVCDeleted: UpdateVolumeMetadata failed with err %v", err)
 	}
 }
@@ -2355,7 +2504,7 @@ func csiPVUpdated(ctx context.Context, newPv *v1.PersistentVolume, oldPv *v1.Per
 
 	log.Debugf("PVUpdated: Calling UpdateVolumeMetadata for volume %q with updateSpec: %+v",
 		updateSpec.VolumeId.Id, spew.Sdump(updateSpec))
-	if err := cnsVolumeMgr.UpdateVolumeMetadata(ctx, updateSpec); err != nil {
+	if err := updateVolumeMetadata(ctx, metadataSyncer, cnsVolumeMgr, updateSpec); err != nil {
 		log.Errorf("PVUpdated: UpdateVolumeMetadata failed with err %v", err)
 		return
 	}
@@ -2485,7 +2634,7 @@ func csiPVDeleted(ctx context.Context, pv *v1.PersistentVolume, metadataSyncer *
 
 		log.Debugf("PVDeleted: Calling UpdateVolumeMetadata for volume %s with updateSpec: %+v",
 			updateSpec.VolumeId.Id, spew.Sdump(updateSpec))
-		if err := cnsVolumeMgr.UpdateVolumeMetadata(ctx, updateSpec); err != nil {
+		if err := updateVolumeMetadata(ctx, metadataSyncer, cnsVolumeMgr, updateSpec); err != nil {
 			log.Errorf("PVDeleted: UpdateVolumeMetadata failed with err %v", err)
 			return
 		}
@@ -2511,6 +2660,7 @@ func csiPVDeleted(ctx context.Context, pv *v1.PersistentVolume, metadataSyncer *
 				log.Errorf("PVDeleted: Failed to delete volume %q with error %+v", pv.Spec.CSI.VolumeHandle, err)
 				return
 			}
+			metadataTracker.forget(pv.Spec.CSI.VolumeHandle)
 		}
 
 	} else {
@@ -2556,6 +2706,8 @@ func csiPVDeleted(ctx context.Context, pv *v1.PersistentVolume, metadataSyncer *
 
 		if _, err := cnsVolumeMgr.DeleteVolume(ctx, volumeHandle, false); err != nil {
 			log.Errorf("PVDeleted: Failed to delete disk %s with error %+v", volumeHandle, err)
+		} else {
+			metadataTracker.forget(volumeHandle)
 		}
 		if migrationFeatureEnabled && pv.Spec.VsphereVolume != nil {
 			// Delete the cnsvspherevolumemigration crd instance when PV is deleted.
@@ -2715,7 +2867,7 @@ func csiUpdatePod(ctx context.Context, pod *v1.Pod, metadataSyncer *metadataSync
 
 		log.Debugf("Calling UpdateVolumeMetadata for volume %s with updateSpec: %+v",
 			updateSpec.VolumeId.Id, spew.Sdump(updateSpec))
-		if err := cnsVolumeMgr.UpdateVolumeMetadata(ctx, updateSpec); err != nil {
+		if err := updateVolumeMetadata(ctx, metadataSyncer, cnsVolumeMgr, updateSpec); err != nil {
 			log.Errorf("UpdateVolumeMetadata failed for volume %s with err: %v", volume.Name, err)
 		}
 
@@ -2986,14 +3138,15 @@ func startStoragePolicyQuotaCRInformer(ctx context.Context, cfg *restclient.Conf
 			cnsoperatorv1alpha1.CnsStoragePolicyQuotaSingular, err)
 	}
 	policyQuotaInformer := dynInformer.Informer()
-	_, err = policyQuotaInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc: func(obj interface{}) {
+	_, err = policyQuotaInformer.AddEventHandler(k8s.NewEventHandler(
+		cnsoperatorv1alpha1.CnsStoragePolicyQuotaPlural,
+		func(obj interface{}) { // Add.
 			policyQuotaCRAdded(obj, metadataSyncer)
 		},
-		DeleteFunc: func(obj interface{}) {
+		nil,
+		func(obj interface{}) { // Delete.
 			policyQuotaCRDeleted(obj, metadataSyncer)
-		},
-	})
+		}))
 	if err != nil {
 		return logger.LogNewErrorf(log, "failed to add event handler on informer for %q CR. Error: %v",
 			cnsoperatorv1alpha1.CnsStoragePolicyQuotaSingular, err)
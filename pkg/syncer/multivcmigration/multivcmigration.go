@@ -0,0 +1,403 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package multivcmigration migrates the volumes of a cluster provisioned with
+// a single vCenter to a multi vCenter configuration of the vSphere config
+// secret, without re-provisioning them. It is run in the syncer image started
+// in the MULTI_VC_MIGRATION operation mode, once the secret lists the new
+// vCenters.
+//
+// The migration validates the multi vCenter configuration, finds the vCenter
+// of every CSI volume, records it in the CnsVolumeInfo CR of the volume, and
+// sets the topology of the original vCenter as node affinity on the PVs of
+// its volumes which were provisioned without topology, so that their pods are
+// not scheduled on the nodes of the other vCenters. It is idempotent, and
+// only reports the changes it would make in dry run mode, which is the
+// default.
+package multivcmigration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	cnsconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsvolumeinfo"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
+)
+
+const (
+	// EnvSourceVCenter is the vCenter of the single vCenter configuration,
+	// on which the existing volumes were provisioned.
+	EnvSourceVCenter = "MULTI_VC_MIGRATION_SOURCE_VCENTER"
+	// EnvSourceTopology is the topology of the nodes of the source vCenter,
+	// as comma separated <topology label>=<value> pairs, e.g.
+	// "topology.csi.vmware.com/k8s-zone=zone-a". It is set as node affinity
+	// on the PVs of the source vCenter provisioned without topology.
+	EnvSourceTopology = "MULTI_VC_MIGRATION_SOURCE_TOPOLOGY"
+	// EnvDryRun disables the dry run mode when set to "false".
+	EnvDryRun = "MULTI_VC_MIGRATION_DRY_RUN"
+	// EnvResultPath is the path of the file the JSON encoded Result is written
+	// to. Defaults to the termination message path of the container.
+	EnvResultPath = "MULTI_VC_MIGRATION_RESULT_PATH"
+
+	// defaultResultPath is the default termination message path of a
+	// container.
+	defaultResultPath = "/dev/termination-log"
+	// queryBatchSize is the number of volumes looked up per CNS query.
+	queryBatchSize = 100
+)
+
+// Params are the parameters of a migration.
+type Params struct {
+	SourceVCenter  string
+	SourceTopology map[string]string
+	DryRun         bool
+	ResultPath     string
+}
+
+// Result is the outcome of a migration.
+type Result struct {
+	// DryRun is true if the changes were only reported.
+	DryRun bool `json:"dryRun"`
+	// Volumes is the number of CSI volumes of the cluster.
+	Volumes int `json:"volumes"`
+	// VolumesByVCenter is the number of volumes found on each vCenter.
+	VolumesByVCenter map[string]int `json:"volumesByVCenter"`
+	// VolumeInfosRecorded is the number of volumes whose vCenter is recorded
+	// in their CnsVolumeInfo CR.
+	VolumeInfosRecorded int `json:"volumeInfosRecorded"`
+	// TopologyAdded lists the PVs the source topology was set on.
+	TopologyAdded []string `json:"topologyAdded,omitempty"`
+	// WithoutTopology lists the PVs left without topology, as no source
+	// topology was given.
+	WithoutTopology []string `json:"withoutTopology,omitempty"`
+	// NotFound lists the PVs whose volume was found on none of the vCenters.
+	NotFound []string `json:"notFound,omitempty"`
+}
+
+// ParamsFromEnv returns the migration parameters set in the environment.
+func ParamsFromEnv() (Params, error) {
+	params := Params{
+		SourceVCenter: strings.TrimSpace(os.Getenv(EnvSourceVCenter)),
+		DryRun:        true,
+		ResultPath:    os.Getenv(EnvResultPath),
+	}
+	if params.SourceVCenter == "" {
+		return Params{}, fmt.Errorf("environment variable %s is not set", EnvSourceVCenter)
+	}
+	if v := os.Getenv(EnvDryRun); v != "" {
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			return Params{}, fmt.Errorf("environment variable %s must be a boolean, got %q", EnvDryRun, v)
+		}
+		params.DryRun = dryRun
+	}
+	var err error
+	params.SourceTopology, err = parseTopology(os.Getenv(EnvSourceTopology))
+	if err != nil {
+		return Params{}, fmt.Errorf("invalid environment variable %s. Error: %v", EnvSourceTopology, err)
+	}
+	if params.ResultPath == "" {
+		params.ResultPath = defaultResultPath
+	}
+	return params, nil
+}
+
+// parseTopology parses comma separated <topology label>=<value> pairs.
+func parseTopology(value string) (map[string]string, error) {
+	topology := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, val, found := strings.Cut(pair, "=")
+		key, val = strings.TrimSpace(key), strings.TrimSpace(val)
+		if !found || key == "" || val == "" {
+			return nil, fmt.Errorf("expected <topology label>=<value>, got %q", pair)
+		}
+		if !strings.HasPrefix(key, common.TopologyLabelsDomain+"/") {
+			return nil, fmt.Errorf("topology label %q is not in the %q domain", key, common.TopologyLabelsDomain)
+		}
+		topology[key] = val
+	}
+	return topology, nil
+}
+
+// validateConfig checks that the vSphere config is a multi vCenter
+// configuration including the source vCenter, whose topology categories
+// include the labels of the source topology.
+func validateConfig(cfg *cnsconfig.Config, params Params) error {
+	if len(cfg.VirtualCenter) < 2 {
+		return fmt.Errorf("the vSphere config lists %d vCenter, a multi vCenter configuration lists "+
+			"at least 2", len(cfg.VirtualCenter))
+	}
+	if _, ok := cfg.VirtualCenter[params.SourceVCenter]; !ok {
+		return fmt.Errorf("source vCenter %q is not in the vSphere config", params.SourceVCenter)
+	}
+	if cfg.Labels.TopologyCategories == "" {
+		return fmt.Errorf("topology-categories are not set in the vSphere config, which is required " +
+			"for a multi vCenter configuration")
+	}
+	categories := make(map[string]bool)
+	for _, category := range strings.Split(cfg.Labels.TopologyCategories, ",") {
+		categories[common.TopologyLabelsDomain+"/"+strings.TrimSpace(category)] = true
+	}
+	for key := range params.SourceTopology {
+		if !categories[key] {
+			return fmt.Errorf("source topology label %q is not one of the topology-categories %q",
+				key, cfg.Labels.TopologyCategories)
+		}
+	}
+	return nil
+}
+
+// Run migrates the volumes of the cluster to the multi vCenter configuration
+// and writes the Result to the result path.
+func Run(ctx context.Context, params Params) (*Result, error) {
+	log := logger.GetLogger(ctx)
+	cfg, err := cnsconfig.GetConfig(ctx)
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to read the vSphere config. Error: %v", err)
+	}
+	if err := validateConfig(cfg, params); err != nil {
+		return nil, logger.LogNewErrorf(log, "invalid multi vCenter configuration. Error: %v", err)
+	}
+	vcs, err := connectVCenters(ctx, cfg, params.SourceVCenter)
+	if err != nil {
+		return nil, err
+	}
+	k8sClient, err := k8s.NewClient(ctx)
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to create kubernetes client. Error: %v", err)
+	}
+	var volumeInfoService cnsvolumeinfo.VolumeInfoService
+	if !params.DryRun {
+		volumeInfoService, err = cnsvolumeinfo.InitVolumeInfoService(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	result, err := migrate(ctx, k8sClient, volumeInfoService, vcs, params)
+	if err != nil {
+		return nil, err
+	}
+	if params.ResultPath != "" {
+		data, err := json.Marshal(result)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(params.ResultPath, data, 0644); err != nil {
+			log.Warnf("failed to write the result to %q. Error: %v", params.ResultPath, err)
+		}
+	}
+	return result, nil
+}
+
+// vCenterQuerier looks up the volumes of a vCenter.
+type vCenterQuerier interface {
+	host() string
+	queryVolumeIDs(ctx context.Context, volumeIDs []string) (map[string]bool, error)
+}
+
+// vCenter looks up volumes with the CNS client of a vCenter.
+type vCenter struct {
+	vc *cnsvsphere.VirtualCenter
+}
+
+func (v vCenter) host() string {
+	return v.vc.Config.Host
+}
+
+// queryVolumeIDs returns the IDs of the given volumes which exist on the
+// vCenter.
+func (v vCenter) queryVolumeIDs(ctx context.Context, volumeIDs []string) (map[string]bool, error) {
+	filter := cnstypes.CnsQueryFilter{
+		Cursor: &cnstypes.CnsCursor{Limit: int64(len(volumeIDs))},
+	}
+	for _, volumeID := range volumeIDs {
+		filter.VolumeIds = append(filter.VolumeIds, cnstypes.CnsVolumeId{Id: volumeID})
+	}
+	queryResult, err := v.vc.CnsClient.QueryVolume(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]bool)
+	for _, volume := range queryResult.Volumes {
+		found[volume.VolumeId.Id] = true
+	}
+	return found, nil
+}
+
+// connectVCenters connects to the vCenters of the config, returning the
+// source vCenter first.
+func connectVCenters(ctx context.Context, cfg *cnsconfig.Config, sourceVCenter string) ([]vCenterQuerier, error) {
+	log := logger.GetLogger(ctx)
+	vcConfigs, err := cnsvsphere.GetVirtualCenterConfigs(ctx, cfg)
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to get the vCenter configs. Error: %v", err)
+	}
+	var vcs []vCenterQuerier
+	for _, vcConfig := range vcConfigs {
+		vc, err := cnsvsphere.GetVirtualCenterInstanceForVCenterConfig(ctx, vcConfig, false)
+		if err != nil {
+			return nil, logger.LogNewErrorf(log, "failed to connect to vCenter %q. Error: %v", vcConfig.Host, err)
+		}
+		if err := vc.ConnectCns(ctx); err != nil {
+			return nil, logger.LogNewErrorf(log, "failed to connect to CNS of vCenter %q. Error: %v",
+				vcConfig.Host, err)
+		}
+		vcs = append(vcs, vCenter{vc: vc})
+	}
+	sort.SliceStable(vcs, func(i, j int) bool {
+		return vcs[i].host() == sourceVCenter && vcs[j].host() != sourceVCenter
+	})
+	return vcs, nil
+}
+
+// migrate records the vCenter of the CSI volumes of the cluster and sets the
+// source topology on the PVs of the source vCenter without topology.
+func migrate(ctx context.Context, k8sClient clientset.Interface, volumeInfoService cnsvolumeinfo.VolumeInfoService,
+	vcs []vCenterQuerier, params Params) (*Result, error) {
+	log := logger.GetLogger(ctx)
+	pvList, err := k8sClient.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to list PVs. Error: %v", err)
+	}
+	pvs := make(map[string]*v1.PersistentVolume)
+	for i := range pvList.Items {
+		pv := &pvList.Items[i]
+		if pv.Spec.CSI != nil && pv.Spec.CSI.Driver == csitypes.DriverName() {
+			pvs[pv.Spec.CSI.VolumeHandle] = pv
+		}
+	}
+	result := &Result{
+		DryRun:           params.DryRun,
+		Volumes:          len(pvs),
+		VolumesByVCenter: make(map[string]int),
+	}
+	volumeVCenters, err := findVolumeVCenters(ctx, vcs, pvs)
+	if err != nil {
+		return nil, err
+	}
+
+	volumeIDs := make([]string, 0, len(pvs))
+	for volumeID := range pvs {
+		volumeIDs = append(volumeIDs, volumeID)
+	}
+	sort.Strings(volumeIDs)
+	for _, volumeID := range volumeIDs {
+		pv := pvs[volumeID]
+		vcHost, found := volumeVCenters[volumeID]
+		if !found {
+			log.Warnf("Volume %q of PV %q is not found on any vCenter", volumeID, pv.Name)
+			result.NotFound = append(result.NotFound, pv.Name)
+			continue
+		}
+		result.VolumesByVCenter[vcHost]++
+		if !params.DryRun {
+			if err := volumeInfoService.CreateVolumeInfo(ctx, volumeID, vcHost); err != nil {
+				return nil, err
+			}
+		}
+		result.VolumeInfosRecorded++
+		if vcHost != params.SourceVCenter || pv.Spec.NodeAffinity != nil {
+			continue
+		}
+		if len(params.SourceTopology) == 0 {
+			result.WithoutTopology = append(result.WithoutTopology, pv.Name)
+			continue
+		}
+		if !params.DryRun {
+			// The node affinity of a PV can be set once, if it was not set.
+			pv.Spec.NodeAffinity = getNodeAffinity(params.SourceTopology)
+			if _, err := k8sClient.CoreV1().PersistentVolumes().Update(ctx, pv, metav1.UpdateOptions{}); err != nil {
+				return nil, logger.LogNewErrorf(log, "failed to set the topology of PV %q. Error: %v", pv.Name, err)
+			}
+			log.Infof("Set topology %v on PV %q", params.SourceTopology, pv.Name)
+		}
+		result.TopologyAdded = append(result.TopologyAdded, pv.Name)
+	}
+	log.Infof("Multi vCenter migration of %d volumes done, dry run: %t. Volumes per vCenter: %v, "+
+		"topology added to %d PVs, %d PVs without topology, %d volumes not found", result.Volumes, result.DryRun,
+		result.VolumesByVCenter, len(result.TopologyAdded), len(result.WithoutTopology), len(result.NotFound))
+	return result, nil
+}
+
+// findVolumeVCenters returns the vCenter of each of the given volumes found
+// on the vCenters. The vCenters are queried in order, only for the volumes
+// not found on the previous ones.
+func findVolumeVCenters(ctx context.Context, vcs []vCenterQuerier, pvs map[string]*v1.PersistentVolume) (
+	map[string]string, error) {
+	log := logger.GetLogger(ctx)
+	volumeVCenters := make(map[string]string)
+	for _, vc := range vcs {
+		var remaining []string
+		for volumeID := range pvs {
+			if _, found := volumeVCenters[volumeID]; !found {
+				remaining = append(remaining, volumeID)
+			}
+		}
+		for start := 0; start < len(remaining); start += queryBatchSize {
+			batch := remaining[start:min(start+queryBatchSize, len(remaining))]
+			found, err := vc.queryVolumeIDs(ctx, batch)
+			if err != nil {
+				return nil, logger.LogNewErrorf(log, "failed to query volumes on vCenter %q. Error: %v",
+					vc.host(), err)
+			}
+			for volumeID := range found {
+				volumeVCenters[volumeID] = vc.host()
+			}
+		}
+	}
+	return volumeVCenters, nil
+}
+
+// getNodeAffinity returns the node affinity requiring the given topology.
+func getNodeAffinity(topology map[string]string) *v1.VolumeNodeAffinity {
+	keys := make([]string, 0, len(topology))
+	for key := range topology {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var requirements []v1.NodeSelectorRequirement
+	for _, key := range keys {
+		requirements = append(requirements, v1.NodeSelectorRequirement{
+			Key:      key,
+			Operator: v1.NodeSelectorOpIn,
+			Values:   []string{topology[key]},
+		})
+	}
+	return &v1.VolumeNodeAffinity{
+		Required: &v1.NodeSelector{
+			NodeSelectorTerms: []v1.NodeSelectorTerm{{MatchExpressions: requirements}},
+		},
+	}
+}
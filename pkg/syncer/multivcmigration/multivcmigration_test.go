@@ -0,0 +1,154 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package multivcmigration
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	cnsconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsvolumeinfo"
+)
+
+const zoneLabel = "topology.csi.vmware.com/k8s-zone"
+
+type fakeVCenter struct {
+	vcHost  string
+	volumes map[string]bool
+}
+
+func (v fakeVCenter) host() string {
+	return v.vcHost
+}
+
+func (v fakeVCenter) queryVolumeIDs(ctx context.Context, volumeIDs []string) (map[string]bool, error) {
+	found := make(map[string]bool)
+	for _, volumeID := range volumeIDs {
+		if v.volumes[volumeID] {
+			found[volumeID] = true
+		}
+	}
+	return found, nil
+}
+
+type fakeVolumeInfoService struct {
+	cnsvolumeinfo.VolumeInfoService
+	vCenters map[string]string
+}
+
+func (s *fakeVolumeInfoService) CreateVolumeInfo(ctx context.Context, volumeID string, vCenter string) error {
+	s.vCenters[volumeID] = vCenter
+	return nil
+}
+
+func newPV(name, volumeID string, nodeAffinity *v1.VolumeNodeAffinity) *v1.PersistentVolume {
+	return &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: v1.PersistentVolumeSpec{
+			PersistentVolumeSource: v1.PersistentVolumeSource{
+				CSI: &v1.CSIPersistentVolumeSource{Driver: csitypes.DriverName(), VolumeHandle: volumeID},
+			},
+			NodeAffinity: nodeAffinity,
+		},
+	}
+}
+
+func TestParseTopology(t *testing.T) {
+	topology, err := parseTopology(zoneLabel + "=zone-a, topology.csi.vmware.com/k8s-region=region-1")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{zoneLabel: "zone-a", "topology.csi.vmware.com/k8s-region": "region-1"},
+		topology)
+	topology, err = parseTopology("")
+	assert.NoError(t, err)
+	assert.Empty(t, topology)
+	_, err = parseTopology(zoneLabel)
+	assert.Error(t, err)
+	_, err = parseTopology("topology.kubernetes.io/zone=zone-a")
+	assert.Error(t, err)
+}
+
+func TestValidateConfig(t *testing.T) {
+	cfg := &cnsconfig.Config{
+		VirtualCenter: map[string]*cnsconfig.VirtualCenterConfig{"vc1": {}, "vc2": {}},
+	}
+	cfg.Labels.TopologyCategories = "k8s-region,k8s-zone"
+	params := Params{SourceVCenter: "vc1", SourceTopology: map[string]string{zoneLabel: "zone-a"}}
+	assert.NoError(t, validateConfig(cfg, params))
+
+	params.SourceVCenter = "vc3"
+	assert.Error(t, validateConfig(cfg, params))
+	params.SourceVCenter = "vc1"
+
+	params.SourceTopology = map[string]string{"topology.csi.vmware.com/k8s-rack": "rack-1"}
+	assert.Error(t, validateConfig(cfg, params))
+
+	delete(cfg.VirtualCenter, "vc2")
+	assert.Error(t, validateConfig(cfg, Params{SourceVCenter: "vc1"}))
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	existingAffinity := getNodeAffinity(map[string]string{zoneLabel: "zone-b"})
+	k8sClient := fake.NewSimpleClientset(
+		newPV("pv-1", "vol-1", nil),
+		newPV("pv-2", "vol-2", existingAffinity),
+		newPV("pv-3", "vol-3", nil),
+		newPV("pv-4", "vol-4", nil))
+	vcs := []vCenterQuerier{
+		fakeVCenter{vcHost: "vc1", volumes: map[string]bool{"vol-1": true, "vol-2": true}},
+		fakeVCenter{vcHost: "vc2", volumes: map[string]bool{"vol-3": true}},
+	}
+	params := Params{SourceVCenter: "vc1", SourceTopology: map[string]string{zoneLabel: "zone-a"}, DryRun: true}
+
+	// A dry run doesn't change the PVs.
+	result, err := migrate(ctx, k8sClient, nil, vcs, params)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, result.Volumes)
+	assert.Equal(t, map[string]int{"vc1": 2, "vc2": 1}, result.VolumesByVCenter)
+	assert.Equal(t, []string{"pv-1"}, result.TopologyAdded)
+	assert.Equal(t, []string{"pv-4"}, result.NotFound)
+	pv, err := k8sClient.CoreV1().PersistentVolumes().Get(ctx, "pv-1", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Nil(t, pv.Spec.NodeAffinity)
+
+	volumeInfoService := &fakeVolumeInfoService{vCenters: make(map[string]string)}
+	params.DryRun = false
+	result, err = migrate(ctx, k8sClient, volumeInfoService, vcs, params)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, result.VolumeInfosRecorded)
+	assert.Equal(t, map[string]string{"vol-1": "vc1", "vol-2": "vc1", "vol-3": "vc2"}, volumeInfoService.vCenters)
+	pv, err = k8sClient.CoreV1().PersistentVolumes().Get(ctx, "pv-1", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, getNodeAffinity(params.SourceTopology), pv.Spec.NodeAffinity)
+	pv, err = k8sClient.CoreV1().PersistentVolumes().Get(ctx, "pv-2", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, existingAffinity, pv.Spec.NodeAffinity)
+
+	// Without source topology, the PVs without topology are reported.
+	k8sClient = fake.NewSimpleClientset(newPV("pv-1", "vol-1", nil))
+	params.SourceTopology = nil
+	result, err = migrate(ctx, k8sClient, volumeInfoService, vcs, params)
+	assert.NoError(t, err)
+	assert.Empty(t, result.TopologyAdded)
+	assert.Equal(t, []string{"pv-1"}, result.WithoutTopology)
+}
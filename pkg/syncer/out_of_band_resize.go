@@ -0,0 +1,133 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"os"
+	"strconv"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	volumes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/utils"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
+)
+
+// annOutOfBandResizedCapacity is the annotation set on a PVC whose volume was
+// grown outside of kubernetes, with the capacity detected on CNS as value.
+const annOutOfBandResizedCapacity = "cns.vmware.com/out-of-band-resized-capacity"
+
+// isPVCAnnotationOnOutOfBandResizeEnabled returns false if environment
+// variable ANNOTATE_PVC_ON_OUT_OF_BAND_RESIZE is set to false, otherwise true.
+func isPVCAnnotationOnOutOfBandResizeEnabled(ctx context.Context) bool {
+	log := logger.GetLogger(ctx)
+	if v := os.Getenv("ANNOTATE_PVC_ON_OUT_OF_BAND_RESIZE"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err == nil {
+			return enabled
+		}
+		log.Warnf("OutOfBandResize: env variable ANNOTATE_PVC_ON_OUT_OF_BAND_RESIZE %q is invalid, "+
+			"PVCs will be annotated", v)
+	}
+	return true
+}
+
+// fullSyncReconcileVolumeCapacity updates the capacity of the bound CSI block
+// PVs whose volume on CNS has been grown outside of kubernetes, e.g. by
+// extending the FCD in vCenter. Volumes being expanded through their PVC are
+// left to the external-resizer.
+func fullSyncReconcileVolumeCapacity(ctx context.Context, k8sPVs []*v1.PersistentVolume,
+	metadataSyncer *metadataSyncInformer, volManager volumes.Manager, vc string) {
+	log := logger.GetLogger(ctx)
+	pvsByVolumeID := make(map[string]*v1.PersistentVolume)
+	var volumeIDs []cnstypes.CnsVolumeId
+	for _, pv := range k8sPVs {
+		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != csitypes.DriverName() || pv.Status.Phase != v1.VolumeBound ||
+			pv.Spec.ClaimRef == nil {
+			continue
+		}
+		pvsByVolumeID[pv.Spec.CSI.VolumeHandle] = pv
+		volumeIDs = append(volumeIDs, cnstypes.CnsVolumeId{Id: pv.Spec.CSI.VolumeHandle})
+	}
+	if len(volumeIDs) == 0 {
+		return
+	}
+	volumeDetailsMap, err := utils.QueryVolumeDetailsUtil(ctx, volManager, volumeIDs)
+	if err != nil {
+		log.Errorf("FullSync for VC %s: failed to query capacity of volumes. Err: %v", vc, err)
+		return
+	}
+	k8sClient, err := k8s.NewClient(ctx)
+	if err != nil {
+		log.Errorf("FullSync for VC %s: failed to create kubernetes client. Err: %v", vc, err)
+		return
+	}
+	annotatePVC := isPVCAnnotationOnOutOfBandResizeEnabled(ctx)
+	for volumeID, volumeDetails := range volumeDetailsMap {
+		pv, ok := pvsByVolumeID[volumeID]
+		if !ok || volumeDetails.VolumeType != common.BlockVolumeType || volumeDetails.SizeInMB <= 0 {
+			continue
+		}
+		cnsCapacity := resource.NewQuantity(volumeDetails.SizeInMB*common.MbInBytes, resource.BinarySI)
+		pvCapacity := pv.Spec.Capacity[v1.ResourceStorage]
+		if cnsCapacity.Cmp(pvCapacity) <= 0 {
+			continue
+		}
+		pvc, err := metadataSyncer.pvcLister.PersistentVolumeClaims(pv.Spec.ClaimRef.Namespace).Get(
+			pv.Spec.ClaimRef.Name)
+		if err != nil {
+			log.Warnf("FullSync for VC %s: failed to get PVC %s/%s of PV %q. Err: %v", vc,
+				pv.Spec.ClaimRef.Namespace, pv.Spec.ClaimRef.Name, pv.Name, err)
+			continue
+		}
+		if requested, ok := pvc.Spec.Resources.Requests[v1.ResourceStorage]; ok && requested.Cmp(*cnsCapacity) >= 0 {
+			// The volume was expanded through the PVC, the external-resizer
+			// updates the PV once ControllerExpandVolume succeeds.
+			continue
+		}
+		log.Infof("FullSync for VC %s: volume %q was resized outside of kubernetes, updating capacity of PV %q "+
+			"from %s to %s", vc, volumeID, pv.Name, pvCapacity.String(), cnsCapacity.String())
+		updatedPV := pv.DeepCopy()
+		if updatedPV.Spec.Capacity == nil {
+			updatedPV.Spec.Capacity = v1.ResourceList{}
+		}
+		updatedPV.Spec.Capacity[v1.ResourceStorage] = *cnsCapacity
+		_, err = k8sClient.CoreV1().PersistentVolumes().Update(ctx, updatedPV, metav1.UpdateOptions{})
+		if err != nil {
+			log.Errorf("FullSync for VC %s: failed to update capacity of PV %q. Err: %v", vc, pv.Name, err)
+			continue
+		}
+		if !annotatePVC || pvc.Annotations[annOutOfBandResizedCapacity] == cnsCapacity.String() {
+			continue
+		}
+		updatedPVC := pvc.DeepCopy()
+		metav1.SetMetaDataAnnotation(&updatedPVC.ObjectMeta, annOutOfBandResizedCapacity, cnsCapacity.String())
+		_, err = k8sClient.CoreV1().PersistentVolumeClaims(pvc.Namespace).Update(ctx, updatedPVC,
+			metav1.UpdateOptions{})
+		if err != nil {
+			log.Errorf("FullSync for VC %s: failed to annotate PVC %s/%s with the capacity of volume %q. Err: %v",
+				vc, pvc.Namespace, pvc.Name, volumeID, err)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	clientset "k8s.io/client-go/kubernetes"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+)
+
+const (
+	// pvcConditionVolumeUnschedulable is the type of the PVC condition
+	// reporting whether the node affinity of the bound PV matches none of
+	// the nodes on which the driver is registered.
+	pvcConditionVolumeUnschedulable v1.PersistentVolumeClaimConditionType = "VolumeUnschedulable"
+
+	// volumeUnschedulableReasonNoAccessibleNode is the reason of the
+	// VolumeUnschedulable condition and event while no node matches the
+	// node affinity of the PV.
+	volumeUnschedulableReasonNoAccessibleNode = "NoAccessibleNode"
+	// volumeUnschedulableReasonAccessibleNodeFound is the reason of the
+	// VolumeUnschedulable condition and event once a node matches the node
+	// affinity of the PV again.
+	volumeUnschedulableReasonAccessibleNodeFound = "AccessibleNodeFound"
+)
+
+// getPVAccessibilityIntervalInMin returns the interval at which the
+// accessibility of the PVs from the nodes is checked. If environment
+// variable PV_ACCESSIBILITY_INTERVAL_MINUTES is set and valid, return the
+// interval value read from environment variable.
+// Otherwise, use the default value 5 minutes.
+func getPVAccessibilityIntervalInMin(ctx context.Context) int {
+	log := logger.GetLogger(ctx)
+	pvAccessibilityIntervalInMin := defaultPVAccessibilityIntervalInMin
+	if v := os.Getenv("PV_ACCESSIBILITY_INTERVAL_MINUTES"); v != "" {
+		if value, err := strconv.Atoi(v); err == nil && value > 0 {
+			pvAccessibilityIntervalInMin = value
+			log.Infof("PVAccessibility: interval is set to %d minutes", pvAccessibilityIntervalInMin)
+		} else {
+			log.Warnf("PVAccessibility: interval set in env variable PV_ACCESSIBILITY_INTERVAL_MINUTES %s "+
+				"is invalid, will use the default interval", v)
+		}
+	}
+	return pvAccessibilityIntervalInMin
+}
+
+// csiReportPVAccessibility checks the node affinity of the bound PVs of the
+// driver against the labels of the nodes on which the driver is registered,
+// and reports the PVs matching none of them, e.g. after their datastore was
+// unmounted from a zone, on their PVC. Pods using such a PVC stay Pending.
+func csiReportPVAccessibility(ctx context.Context, k8sClient clientset.Interface,
+	metadataSyncer *metadataSyncInformer) {
+	log := logger.GetLogger(ctx)
+	pvs, err := metadataSyncer.pvLister.List(labels.Everything())
+	if err != nil {
+		log.Errorf("csiReportPVAccessibility: failed to list PVs. Err: %v", err)
+		return
+	}
+	reportPVAccessibility(ctx, k8sClient, pvs)
+}
+
+// reportPVAccessibility sets the VolumeUnschedulable condition on the PVCs
+// of the given PVs.
+func reportPVAccessibility(ctx context.Context, k8sClient clientset.Interface, pvs []*v1.PersistentVolume) {
+	log := logger.GetLogger(ctx)
+	csiNodes, err := k8sClient.StorageV1().CSINodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Errorf("reportPVAccessibility: failed to list CSINodes. Err: %v", err)
+		return
+	}
+	registeredNodes := make(map[string]bool)
+	for _, csiNode := range csiNodes.Items {
+		for _, driver := range csiNode.Spec.Drivers {
+			if driver.Name == csitypes.DriverName() {
+				registeredNodes[csiNode.Name] = true
+			}
+		}
+	}
+	nodeList, err := k8sClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Errorf("reportPVAccessibility: failed to list nodes. Err: %v", err)
+		return
+	}
+	var nodes []*v1.Node
+	for i := range nodeList.Items {
+		if registeredNodes[nodeList.Items[i].Name] {
+			nodes = append(nodes, &nodeList.Items[i])
+		}
+	}
+	if len(nodes) == 0 {
+		// The driver is not registered on any node yet, e.g. while the
+		// cluster starts, every PV would be reported.
+		log.Debugf("reportPVAccessibility: driver is not registered on any node, skipping")
+		return
+	}
+
+	pvcList, err := k8sClient.CoreV1().PersistentVolumeClaims(v1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Errorf("reportPVAccessibility: failed to list PVCs. Err: %v", err)
+		return
+	}
+	pvcs := make(map[string]*v1.PersistentVolumeClaim, len(pvcList.Items))
+	for i := range pvcList.Items {
+		pvcs[string(pvcList.Items[i].UID)] = &pvcList.Items[i]
+	}
+
+	for _, pv := range pvs {
+		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != csitypes.DriverName() || pv.Status.Phase != v1.VolumeBound ||
+			pv.Spec.ClaimRef == nil || pv.Spec.NodeAffinity == nil || pv.Spec.NodeAffinity.Required == nil {
+			continue
+		}
+		accessible := false
+		for _, node := range nodes {
+			if nodeMatchesNodeSelector(node, pv.Spec.NodeAffinity.Required) {
+				accessible = true
+				break
+			}
+		}
+		pvc, ok := pvcs[string(pv.Spec.ClaimRef.UID)]
+		if !ok {
+			continue
+		}
+		if accessible {
+			updateVolumeUnschedulableCondition(ctx, k8sClient, pv, pvc, v1.ConditionFalse,
+				volumeUnschedulableReasonAccessibleNodeFound,
+				fmt.Sprintf("Node affinity of PV %s matches a node again", pv.Name))
+			continue
+		}
+		updateVolumeUnschedulableCondition(ctx, k8sClient, pv, pvc, v1.ConditionTrue,
+			volumeUnschedulableReasonNoAccessibleNode,
+			fmt.Sprintf("Node affinity of PV %s matches none of the nodes of the vSphere CSI driver, "+
+				"pods using the volume can't be scheduled. The datastore of the volume may no longer be "+
+				"accessible from the nodes of its topology", pv.Name))
+	}
+}
+
+// updateVolumeUnschedulableCondition sets the VolumeUnschedulable condition
+// of the PVC, recording an event on the PV and the PVC when the condition
+// changes. A False condition is only set over a True condition.
+func updateVolumeUnschedulableCondition(ctx context.Context, k8sClient clientset.Interface,
+	pv *v1.PersistentVolume, pvc *v1.PersistentVolumeClaim, status v1.ConditionStatus, reason, message string) {
+	log := logger.GetLogger(ctx)
+	var previous *v1.PersistentVolumeClaimCondition
+	for i := range pvc.Status.Conditions {
+		if pvc.Status.Conditions[i].Type == pvcConditionVolumeUnschedulable {
+			previous = &pvc.Status.Conditions[i]
+		}
+	}
+	if previous == nil && status == v1.ConditionFalse {
+		return
+	}
+	if previous != nil && previous.Status == status {
+		return
+	}
+	conditions := []v1.PersistentVolumeClaimCondition{{
+		Type:               pvcConditionVolumeUnschedulable,
+		Status:             status,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	}}
+	for _, condition := range pvc.Status.Conditions {
+		if condition.Type != pvcConditionVolumeUnschedulable {
+			conditions = append(conditions, condition)
+		}
+	}
+	newPVC := pvc.DeepCopy()
+	newPVC.Status.Conditions = conditions
+	_, err := k8sClient.CoreV1().PersistentVolumeClaims(pvc.Namespace).UpdateStatus(ctx, newPVC,
+		metav1.UpdateOptions{})
+	if err != nil {
+		log.Errorf("updateVolumeUnschedulableCondition: failed to update %s condition of pvc %s/%s. Err: %v",
+			pvcConditionVolumeUnschedulable, pvc.Namespace, pvc.Name, err)
+		return
+	}
+	eventType := v1.EventTypeNormal
+	if status == v1.ConditionTrue {
+		eventType = v1.EventTypeWarning
+	}
+	log.Infof("updateVolumeUnschedulableCondition: %s", message)
+	generateEventOnObject(ctx, pv, eventType, reason, message)
+	generateEventOnObject(ctx, pvc, eventType, reason, message)
+}
+
+// nodeMatchesNodeSelector returns true if the node matches any term of the
+// node selector, following the semantics of the scheduler: the terms are
+// ORed while the requirements of a term are ANDed.
+func nodeMatchesNodeSelector(node *v1.Node, nodeSelector *v1.NodeSelector) bool {
+	nodeFields := labels.Set{"metadata.name": node.Name}
+	for _, term := range nodeSelector.NodeSelectorTerms {
+		if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+			continue
+		}
+		if nodeSelectorRequirementsMatch(term.MatchExpressions, labels.Set(node.Labels)) &&
+			nodeSelectorRequirementsMatch(term.MatchFields, nodeFields) {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeSelectorRequirementsMatch returns true if the given set matches all
+// the node selector requirements.
+func nodeSelectorRequirementsMatch(requirements []v1.NodeSelectorRequirement, set labels.Set) bool {
+	operators := map[v1.NodeSelectorOperator]selection.Operator{
+		v1.NodeSelectorOpIn:           selection.In,
+		v1.NodeSelectorOpNotIn:        selection.NotIn,
+		v1.NodeSelectorOpExists:       selection.Exists,
+		v1.NodeSelectorOpDoesNotExist: selection.DoesNotExist,
+		v1.NodeSelectorOpGt:           selection.GreaterThan,
+		v1.NodeSelectorOpLt:           selection.LessThan,
+	}
+	for _, requirement := range requirements {
+		operator, ok := operators[requirement.Operator]
+		if !ok {
+			return false
+		}
+		r, err := labels.NewRequirement(requirement.Key, operator, requirement.Values)
+		if err != nil || !r.Matches(set) {
+			return false
+		}
+	}
+	return true
+}
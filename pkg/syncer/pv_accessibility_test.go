@@ -0,0 +1,138 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+)
+
+func TestReportPVAccessibility(t *testing.T) {
+	ctx := context.Background()
+	zoneKey := "topology.csi.vmware.com/k8s-zone"
+	newNode := func(name, zone string) *v1.Node {
+		return &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{zoneKey: zone}}}
+	}
+	newCSINode := func(name string) *storagev1.CSINode {
+		return &storagev1.CSINode{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: storagev1.CSINodeSpec{Drivers: []storagev1.CSINodeDriver{
+				{Name: csitypes.Name, NodeID: name, TopologyKeys: []string{zoneKey}},
+			}},
+		}
+	}
+	newPVC := func(name string, conditions ...v1.PersistentVolumeClaimCondition) *v1.PersistentVolumeClaim {
+		return &v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID("uid-" + name)},
+			Spec:       v1.PersistentVolumeClaimSpec{VolumeName: "pv-" + name},
+			Status:     v1.PersistentVolumeClaimStatus{Conditions: conditions},
+		}
+	}
+	newPV := func(name, zone string) *v1.PersistentVolume {
+		return &v1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "pv-" + name},
+			Spec: v1.PersistentVolumeSpec{
+				PersistentVolumeSource: v1.PersistentVolumeSource{
+					CSI: &v1.CSIPersistentVolumeSource{Driver: csitypes.Name, VolumeHandle: "volume-" + name},
+				},
+				ClaimRef: &v1.ObjectReference{Namespace: "default", Name: name, UID: types.UID("uid-" + name)},
+				NodeAffinity: &v1.VolumeNodeAffinity{Required: &v1.NodeSelector{
+					NodeSelectorTerms: []v1.NodeSelectorTerm{{MatchExpressions: []v1.NodeSelectorRequirement{
+						{Key: zoneKey, Operator: v1.NodeSelectorOpIn, Values: []string{zone}},
+					}}},
+				}},
+			},
+			Status: v1.PersistentVolumeStatus{Phase: v1.VolumeBound},
+		}
+	}
+	unschedulable := v1.PersistentVolumeClaimCondition{
+		Type:   pvcConditionVolumeUnschedulable,
+		Status: v1.ConditionTrue,
+		Reason: volumeUnschedulableReasonNoAccessibleNode,
+	}
+	// zone-c has a node on which the driver isn't registered.
+	k8sclient := testclient.NewSimpleClientset(
+		newNode("node-a", "zone-a"), newNode("node-c", "zone-c"), newCSINode("node-a"),
+		newPVC("accessible"), newPVC("inaccessible"), newPVC("recovered", unschedulable),
+		newPVC("unregistered"))
+
+	reportPVAccessibility(ctx, k8sclient, []*v1.PersistentVolume{
+		newPV("accessible", "zone-a"), newPV("inaccessible", "zone-b"), newPV("recovered", "zone-a"),
+		newPV("unregistered", "zone-c"),
+	})
+
+	for name, expected := range map[string]v1.ConditionStatus{
+		"accessible":   "",
+		"inaccessible": v1.ConditionTrue,
+		"recovered":    v1.ConditionFalse,
+		"unregistered": v1.ConditionTrue,
+	} {
+		pvc, err := k8sclient.CoreV1().PersistentVolumeClaims("default").Get(ctx, name, metav1.GetOptions{})
+		assert.NoError(t, err)
+		if expected == "" {
+			assert.Empty(t, pvc.Status.Conditions, name)
+			continue
+		}
+		assert.Len(t, pvc.Status.Conditions, 1, name)
+		assert.Equal(t, pvcConditionVolumeUnschedulable, pvc.Status.Conditions[0].Type, name)
+		assert.Equal(t, expected, pvc.Status.Conditions[0].Status, name)
+	}
+}
+
+func TestNodeMatchesNodeSelector(t *testing.T) {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", Labels: map[string]string{
+		"topology.csi.vmware.com/k8s-region": "region-1",
+		"topology.csi.vmware.com/k8s-zone":   "zone-1",
+	}}}
+	term := func(requirements ...v1.NodeSelectorRequirement) v1.NodeSelectorTerm {
+		return v1.NodeSelectorTerm{MatchExpressions: requirements}
+	}
+	in := func(key string, values ...string) v1.NodeSelectorRequirement {
+		return v1.NodeSelectorRequirement{Key: key, Operator: v1.NodeSelectorOpIn, Values: values}
+	}
+	tests := []struct {
+		name     string
+		terms    []v1.NodeSelectorTerm
+		expected bool
+	}{
+		{"matching term", []v1.NodeSelectorTerm{term(in("topology.csi.vmware.com/k8s-zone", "zone-1"))}, true},
+		{"requirements of a term are ANDed", []v1.NodeSelectorTerm{term(
+			in("topology.csi.vmware.com/k8s-region", "region-1"),
+			in("topology.csi.vmware.com/k8s-zone", "zone-2"))}, false},
+		{"terms are ORed", []v1.NodeSelectorTerm{
+			term(in("topology.csi.vmware.com/k8s-zone", "zone-2")),
+			term(in("topology.csi.vmware.com/k8s-zone", "zone-1")),
+		}, true},
+		{"missing label", []v1.NodeSelectorTerm{term(in("topology.csi.vmware.com/esxi-host", "host-1"))}, false},
+		{"empty term", []v1.NodeSelectorTerm{{}}, false},
+		{"matching field", []v1.NodeSelectorTerm{{MatchFields: []v1.NodeSelectorRequirement{
+			in("metadata.name", "node-1")}}}, true},
+	}
+	for _, test := range tests {
+		matches := nodeMatchesNodeSelector(node, &v1.NodeSelector{NodeSelectorTerms: test.terms})
+		assert.Equal(t, test.expected, matches, test.name)
+	}
+}
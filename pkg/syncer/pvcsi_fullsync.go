@@ -22,7 +22,9 @@ import (
 	"time"
 
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	cnsvolumemetadatav1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/cnsoperator/cnsvolumemetadata/v1alpha1"
@@ -68,24 +70,15 @@ func PvcsiFullSync(ctx context.Context, metadataSyncer *metadataSyncInformer) er
 		return err
 	}
 
-	// Get list of cnsvolumemetadata objects that exist in the given supervisor
-	// cluster namespace.
-	supervisorNamespaceList := &cnsvolumemetadatav1alpha1.CnsVolumeMetadataList{}
-	err = metadataSyncer.cnsOperatorClient.List(ctx, supervisorNamespaceList, client.InNamespace(supervisorNamespace))
+	// Get list of cnsvolumemetadata objects created by this guest cluster in
+	// the given supervisor cluster namespace.
+	supervisorCnsVolumeMetadataList, err := listGuestClusterCnsVolumeMetadatas(ctx, metadataSyncer,
+		supervisorNamespace)
 	if err != nil {
 		log.Warnf("FullSync: Failed to get CnsVolumeMetadatas from supervisor cluster. Err: %v", err)
 		return err
 	}
 
-	supervisorCnsVolumeMetadataList := cnsvolumemetadatav1alpha1.CnsVolumeMetadataList{}
-	// Remove cnsvolumemetadata object from supervisorCnsVolumeMetadataList that
-	// do not belong to this guest cluster.
-	for _, object := range supervisorNamespaceList.Items {
-		if object.Spec.GuestClusterID == metadataSyncer.configInfo.Cfg.GC.TanzuKubernetesClusterUID {
-			supervisorCnsVolumeMetadataList.Items = append(supervisorCnsVolumeMetadataList.Items, object)
-		}
-	}
-
 	// guestObjectsMap maintains a mapping of cnsvolumemetadata objects names to
 	// their spec. Used by pvcsi full sync for quick look-up to check existence
 	// in the guest cluster.
@@ -142,6 +135,47 @@ func PvcsiFullSync(ctx context.Context, metadataSyncer *metadataSyncInformer) er
 	return nil
 }
 
+// listGuestClusterCnsVolumeMetadatas returns the cnsvolumemetadata objects
+// created by this guest cluster in the given supervisor namespace, leaving out
+// the ones of the other guest clusters sharing the namespace. Objects created
+// before they were labelled with the guest cluster ID are labelled as well.
+func listGuestClusterCnsVolumeMetadatas(ctx context.Context, metadataSyncer *metadataSyncInformer,
+	supervisorNamespace string) (*cnsvolumemetadatav1alpha1.CnsVolumeMetadataList, error) {
+	log := logger.GetLogger(ctx)
+	guestClusterID := metadataSyncer.configInfo.Cfg.GC.TanzuKubernetesClusterUID
+	list := &cnsvolumemetadatav1alpha1.CnsVolumeMetadataList{}
+	err := metadataSyncer.cnsOperatorClient.List(ctx, list, client.InNamespace(supervisorNamespace),
+		client.MatchingLabels{cnsvolumemetadatav1alpha1.GuestClusterIDLabel: guestClusterID})
+	if err != nil {
+		return nil, err
+	}
+
+	unlabelled, err := labels.NewRequirement(cnsvolumemetadatav1alpha1.GuestClusterIDLabel,
+		selection.DoesNotExist, nil)
+	if err != nil {
+		return nil, err
+	}
+	legacyList := &cnsvolumemetadatav1alpha1.CnsVolumeMetadataList{}
+	err = metadataSyncer.cnsOperatorClient.List(ctx, legacyList, client.InNamespace(supervisorNamespace),
+		client.MatchingLabelsSelector{Selector: labels.NewSelector().Add(*unlabelled)})
+	if err != nil {
+		return nil, err
+	}
+	for i := range legacyList.Items {
+		object := &legacyList.Items[i]
+		if object.Spec.GuestClusterID != guestClusterID {
+			continue
+		}
+		metav1.SetMetaDataLabel(&object.ObjectMeta, cnsvolumemetadatav1alpha1.GuestClusterIDLabel, guestClusterID)
+		if err := metadataSyncer.cnsOperatorClient.Update(ctx, object); err != nil {
+			log.Warnf("FullSync: Failed to label CnsVolumeMetadata %v with the guest cluster ID. Err: %v",
+				object.Name, err)
+		}
+		list.Items = append(list.Items, *object)
+	}
+	return list, nil
+}
+
 // createCnsVolumeMetadataList creates cnsvolumemetadata objects from the API
 // server using the input k8s client.
 // All objects that can be created are added to returnList. This includes
@@ -0,0 +1,339 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	clientset "k8s.io/client-go/kubernetes"
+
+	volumes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/utils"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+)
+
+const (
+	// nodeAffinityUpdatedReason is the reason of the event recorded on a PV
+	// whose node affinity was updated after its volume was relocated.
+	nodeAffinityUpdatedReason = "NodeAffinityUpdated"
+	// nodeAffinityMigrationRequiredReason is the reason of the event recorded
+	// on a PV and its PVC when the node affinity of the PV can't be updated
+	// after its volume was relocated.
+	nodeAffinityMigrationRequiredReason = "NodeAffinityMigrationRequired"
+)
+
+// pvNodeAffinityMigrationPlan is the value of the
+// AnnNodeAffinityMigrationPlan annotation.
+type pvNodeAffinityMigrationPlan struct {
+	// DatastoreURL is the datastore the volume was relocated to.
+	DatastoreURL string `json:"datastoreURL"`
+	// TopologySegments are the topology segments from which all nodes can
+	// access the datastore, to set as the node affinity of the recreated PV.
+	TopologySegments []map[string]string `json:"topologySegments"`
+	// Steps are the steps to recreate the PV and its PVC.
+	Steps []string `json:"steps"`
+}
+
+// volumeDatastore identifies the datastore of a volume.
+type volumeDatastore struct {
+	vcHost       string
+	datastoreURL string
+}
+
+// getRelocatedPVNodeAffinityIntervalInMin returns the interval at which the
+// node affinity of the PVs is checked against the datastore of their volume.
+// If environment variable RELOCATED_PV_NODE_AFFINITY_INTERVAL_MINUTES is set
+// and valid, return the interval value read from environment variable.
+// Otherwise, use the default value 10 minutes.
+func getRelocatedPVNodeAffinityIntervalInMin(ctx context.Context) int {
+	log := logger.GetLogger(ctx)
+	relocatedPVNodeAffinityIntervalInMin := defaultRelocatedPVNodeAffinityIntervalInMin
+	if v := os.Getenv("RELOCATED_PV_NODE_AFFINITY_INTERVAL_MINUTES"); v != "" {
+		if value, err := strconv.Atoi(v); err == nil && value > 0 {
+			relocatedPVNodeAffinityIntervalInMin = value
+			log.Infof("RelocatedPVNodeAffinity: interval is set to %d minutes", relocatedPVNodeAffinityIntervalInMin)
+		} else {
+			log.Warnf("RelocatedPVNodeAffinity: interval set in env variable "+
+				"RELOCATED_PV_NODE_AFFINITY_INTERVAL_MINUTES %s is invalid, will use the default interval", v)
+		}
+	}
+	return relocatedPVNodeAffinityIntervalInMin
+}
+
+// csiUpdateRelocatedPVNodeAffinity checks the node affinity of the PVs of the
+// driver against the topology of the datastore of their volume. The volume of
+// a PV may have been relocated, through CNS, to a datastore accessible from
+// other zones than the ones of its node affinity, in which case the node
+// affinity is updated.
+func csiUpdateRelocatedPVNodeAffinity(ctx context.Context, k8sClient clientset.Interface,
+	metadataSyncer *metadataSyncInformer) {
+	log := logger.GetLogger(ctx)
+	if !isTopologyAwareDeployment(metadataSyncer) {
+		return
+	}
+	if nodeMgr == nil {
+		log.Debugf("csiUpdateRelocatedPVNodeAffinity: node manager is not initialized, skipping")
+		return
+	}
+	pvs, err := metadataSyncer.pvLister.List(labels.Everything())
+	if err != nil {
+		log.Errorf("csiUpdateRelocatedPVNodeAffinity: failed to list PVs. Err: %v", err)
+		return
+	}
+	var volumeIDs []cnstypes.CnsVolumeId
+	var candidatePVs []*v1.PersistentVolume
+	for _, pv := range pvs {
+		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != csitypes.DriverName() || pv.DeletionTimestamp != nil ||
+			pv.Spec.NodeAffinity == nil || pv.Spec.NodeAffinity.Required == nil {
+			continue
+		}
+		volumeIDs = append(volumeIDs, cnstypes.CnsVolumeId{Id: pv.Spec.CSI.VolumeHandle})
+		candidatePVs = append(candidatePVs, pv)
+	}
+	if len(candidatePVs) == 0 {
+		return
+	}
+	datastores := queryVolumeDatastores(ctx, metadataSyncer, volumeIDs)
+	topologySegments := make(map[volumeDatastore][]map[string]string)
+	for _, pv := range candidatePVs {
+		datastore, ok := datastores[pv.Spec.CSI.VolumeHandle]
+		if !ok {
+			continue
+		}
+		segments, ok := topologySegments[datastore]
+		if !ok {
+			segments, err = getDatastoreTopologySegments(ctx, datastore.vcHost, datastore.datastoreURL)
+			if err != nil {
+				log.Errorf("csiUpdateRelocatedPVNodeAffinity: failed to get topology of datastore %q. Err: %v",
+					datastore.datastoreURL, err)
+				continue
+			}
+			topologySegments[datastore] = segments
+		}
+		if err := updateRelocatedPVNodeAffinity(ctx, k8sClient, pv, datastore.datastoreURL,
+			segments); err != nil {
+			log.Errorf("csiUpdateRelocatedPVNodeAffinity: failed to update node affinity of PV %q. Err: %v",
+				pv.Name, err)
+		}
+	}
+}
+
+// queryVolumeDatastores returns the datastore of each of the given volumes
+// found in CNS, keyed by volume ID.
+func queryVolumeDatastores(ctx context.Context, metadataSyncer *metadataSyncInformer,
+	volumeIDs []cnstypes.CnsVolumeId) map[string]volumeDatastore {
+	log := logger.GetLogger(ctx)
+	volumeManagers := map[string]volumes.Manager{metadataSyncer.host: metadataSyncer.volumeManager}
+	if isMultiVCenterFssEnabled {
+		volumeManagers = metadataSyncer.volumeManagers
+	}
+	datastores := make(map[string]volumeDatastore)
+	for vcHost, volumeManager := range volumeManagers {
+		volumeDetails, err := utils.QueryVolumeDetailsUtil(ctx, volumeManager, volumeIDs)
+		if err != nil {
+			log.Errorf("failed to query volumes on vCenter %q. Err: %v", vcHost, err)
+			continue
+		}
+		for volumeID, details := range volumeDetails {
+			if details.DatastoreUrl != "" {
+				datastores[volumeID] = volumeDatastore{vcHost: vcHost, datastoreURL: details.DatastoreUrl}
+			}
+		}
+	}
+	return datastores
+}
+
+// updateRelocatedPVNodeAffinity updates the node affinity of the PV to the
+// given topology segments of the datastore of its volume if the current node
+// affinity selects nodes outside of them. When Kubernetes rejects the change,
+// as it does unless the MutablePVNodeAffinity feature gate is enabled, the
+// steps to recreate the PV are recorded in the AnnNodeAffinityMigrationPlan
+// annotation of the PV and reported in events instead.
+func updateRelocatedPVNodeAffinity(ctx context.Context, k8sClient clientset.Interface,
+	pv *v1.PersistentVolume, datastoreURL string, topologySegments []map[string]string) error {
+	log := logger.GetLogger(ctx)
+	nodeAffinity := getNodeAffinityForTopologySegments(topologySegments)
+	if nodeAffinity == nil {
+		return nil
+	}
+	currentSegments, ok := getTopologySegmentsFromNodeSelector(pv.Spec.NodeAffinity.Required)
+	if !ok {
+		log.Debugf("Node affinity of PV %q is not made of topology segments, skipping", pv.Name)
+		return nil
+	}
+	if areTopologySegmentsAccessible(currentSegments, topologySegments) {
+		return nil
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"nodeAffinity": nodeAffinity,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = k8sClient.CoreV1().PersistentVolumes().Patch(ctx, pv.Name, types.MergePatchType, patch,
+		metav1.PatchOptions{})
+	if err == nil {
+		message := fmt.Sprintf("Updated node affinity of PV %s to topology %v of datastore %s the volume "+
+			"was relocated to", pv.Name, topologySegments, datastoreURL)
+		log.Info(message)
+		generateEventOnObject(ctx, pv, v1.EventTypeNormal, nodeAffinityUpdatedReason, message)
+		return nil
+	}
+	if !apierrors.IsInvalid(err) {
+		return logger.LogNewErrorf(log, "failed to patch node affinity on PV %q. Err: %v", pv.Name, err)
+	}
+
+	log.Infof("Node affinity of PV %q is immutable, recording a migration plan. Err: %v", pv.Name, err)
+	plan := pvNodeAffinityMigrationPlan{
+		DatastoreURL:     datastoreURL,
+		TopologySegments: topologySegments,
+		Steps: []string{
+			fmt.Sprintf("set the reclaim policy of PV %s to Retain", pv.Name),
+		},
+	}
+	if pv.Spec.ClaimRef != nil {
+		plan.Steps = append(plan.Steps,
+			fmt.Sprintf("stop the pods using PVC %s/%s, then delete the PVC", pv.Spec.ClaimRef.Namespace,
+				pv.Spec.ClaimRef.Name))
+	}
+	plan.Steps = append(plan.Steps,
+		fmt.Sprintf("delete PV %s", pv.Name),
+		fmt.Sprintf("recreate PV %s with volume handle %s and the node affinity of topologySegments",
+			pv.Name, pv.Spec.CSI.VolumeHandle))
+	if pv.Spec.ClaimRef != nil {
+		plan.Steps = append(plan.Steps,
+			fmt.Sprintf("recreate PVC %s/%s with volumeName %s", pv.Spec.ClaimRef.Namespace,
+				pv.Spec.ClaimRef.Name, pv.Name))
+	}
+	planValue, err := json.Marshal(plan)
+	if err != nil {
+		return err
+	}
+	if pv.Annotations[common.AnnNodeAffinityMigrationPlan] == string(planValue) {
+		// The plan was already reported.
+		return nil
+	}
+	patch, err = json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				common.AnnNodeAffinityMigrationPlan: string(planValue),
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = k8sClient.CoreV1().PersistentVolumes().Patch(ctx, pv.Name, types.MergePatchType, patch,
+		metav1.PatchOptions{})
+	if err != nil {
+		return logger.LogNewErrorf(log, "failed to annotate PV %q with its migration plan. Err: %v", pv.Name, err)
+	}
+	message := fmt.Sprintf("Volume of PV %s was relocated to datastore %s which is only accessible from "+
+		"topology %v. The node affinity of the PV can't be updated, recreate the PV following the %s "+
+		"annotation", pv.Name, datastoreURL, topologySegments, common.AnnNodeAffinityMigrationPlan)
+	generateEventOnObject(ctx, pv, v1.EventTypeWarning, nodeAffinityMigrationRequiredReason, message)
+	if pv.Spec.ClaimRef != nil {
+		pvc, err := k8sClient.CoreV1().PersistentVolumeClaims(pv.Spec.ClaimRef.Namespace).Get(ctx,
+			pv.Spec.ClaimRef.Name, metav1.GetOptions{})
+		if err != nil {
+			log.Warnf("failed to get PVC %s/%s of PV %q. Err: %v", pv.Spec.ClaimRef.Namespace,
+				pv.Spec.ClaimRef.Name, pv.Name, err)
+		} else {
+			generateEventOnObject(ctx, pvc, v1.EventTypeWarning, nodeAffinityMigrationRequiredReason, message)
+		}
+	}
+	return nil
+}
+
+// getTopologySegmentsFromNodeSelector converts the node selector of a PV into
+// topology segments, the reverse of getNodeAffinityForTopologySegments. The
+// values of an In requirement expand into one segment each. It returns false
+// if the node selector has requirements other than In requirements on labels.
+func getTopologySegmentsFromNodeSelector(nodeSelector *v1.NodeSelector) ([]map[string]string, bool) {
+	var segments []map[string]string
+	for _, term := range nodeSelector.NodeSelectorTerms {
+		if len(term.MatchFields) != 0 {
+			return nil, false
+		}
+		termSegments := []map[string]string{{}}
+		for _, requirement := range term.MatchExpressions {
+			if requirement.Operator != v1.NodeSelectorOpIn {
+				return nil, false
+			}
+			var expanded []map[string]string
+			for _, segment := range termSegments {
+				for _, value := range requirement.Values {
+					expandedSegment := map[string]string{requirement.Key: value}
+					for key, value := range segment {
+						expandedSegment[key] = value
+					}
+					expanded = append(expanded, expandedSegment)
+				}
+			}
+			termSegments = expanded
+		}
+		segments = append(segments, termSegments...)
+	}
+	return segments, true
+}
+
+// areTopologySegmentsAccessible returns true if each of the given segments
+// matches one of the accessible segments. Two segments match if they have a
+// label in common and agree on the values of all their common labels, so that
+// a segment with the zone label only matches an accessible segment with both
+// the region and the zone labels.
+func areTopologySegmentsAccessible(segments []map[string]string, accessibleSegments []map[string]string) bool {
+	for _, segment := range segments {
+		matched := false
+		for _, accessibleSegment := range accessibleSegments {
+			commonLabels := 0
+			agree := true
+			for key, value := range segment {
+				if accessibleValue, ok := accessibleSegment[key]; ok {
+					commonLabels++
+					if accessibleValue != value {
+						agree = false
+						break
+					}
+				}
+			}
+			if agree && commonLabels > 0 {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
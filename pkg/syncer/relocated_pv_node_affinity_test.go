@@ -0,0 +1,129 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+)
+
+func TestUpdateRelocatedPVNodeAffinity(t *testing.T) {
+	ctx := context.Background()
+	regionKey := "topology.csi.vmware.com/k8s-region"
+	zoneKey := "topology.csi.vmware.com/k8s-zone"
+	datastoreURL := "ds:///vmfs/volumes/vsan:52f1c2d8e3a4b5c6-7d8e9f0a1b2c3d4e/"
+	newPV := func(name string, zones ...string) *v1.PersistentVolume {
+		return &v1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: v1.PersistentVolumeSpec{
+				PersistentVolumeSource: v1.PersistentVolumeSource{
+					CSI: &v1.CSIPersistentVolumeSource{Driver: csitypes.Name, VolumeHandle: "volume-" + name},
+				},
+				ClaimRef: &v1.ObjectReference{Namespace: "default", Name: "pvc-" + name},
+				NodeAffinity: &v1.VolumeNodeAffinity{Required: &v1.NodeSelector{
+					NodeSelectorTerms: []v1.NodeSelectorTerm{{MatchExpressions: []v1.NodeSelectorRequirement{
+						{Key: zoneKey, Operator: v1.NodeSelectorOpIn, Values: zones},
+					}}},
+				}},
+			},
+		}
+	}
+	// The volumes were relocated to a datastore accessible from zone-b only.
+	topologySegments := []map[string]string{{regionKey: "region-1", zoneKey: "zone-b"}}
+
+	t.Run("accessible", func(t *testing.T) {
+		pv := newPV("accessible", "zone-b")
+		k8sclient := testclient.NewSimpleClientset(pv)
+		err := updateRelocatedPVNodeAffinity(ctx, k8sclient, pv, datastoreURL, topologySegments)
+		assert.NoError(t, err)
+		for _, action := range k8sclient.Actions() {
+			assert.NotEqual(t, "patch", action.GetVerb())
+		}
+	})
+
+	t.Run("mutable", func(t *testing.T) {
+		pv := newPV("mutable", "zone-a", "zone-b")
+		k8sclient := testclient.NewSimpleClientset(pv)
+		err := updateRelocatedPVNodeAffinity(ctx, k8sclient, pv, datastoreURL, topologySegments)
+		assert.NoError(t, err)
+		updated, err := k8sclient.CoreV1().PersistentVolumes().Get(ctx, pv.Name, metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, getNodeAffinityForTopologySegments(topologySegments), updated.Spec.NodeAffinity)
+		assert.NotContains(t, updated.Annotations, common.AnnNodeAffinityMigrationPlan)
+	})
+
+	t.Run("immutable", func(t *testing.T) {
+		pv := newPV("immutable", "zone-a")
+		k8sclient := testclient.NewSimpleClientset(pv)
+		k8sclient.PrependReactor("patch", "persistentvolumes",
+			func(action k8stesting.Action) (bool, runtime.Object, error) {
+				patch := action.(k8stesting.PatchAction).GetPatch()
+				var patched map[string]interface{}
+				_ = json.Unmarshal(patch, &patched)
+				if _, ok := patched["spec"]; !ok {
+					return false, nil, nil
+				}
+				return true, nil, apierrors.NewInvalid(schema.GroupKind{Kind: "PersistentVolume"}, pv.Name,
+					field.ErrorList{field.Invalid(field.NewPath("spec", "nodeAffinity"), nil,
+						"field is immutable")})
+			})
+		err := updateRelocatedPVNodeAffinity(ctx, k8sclient, pv, datastoreURL, topologySegments)
+		assert.NoError(t, err)
+		updated, err := k8sclient.CoreV1().PersistentVolumes().Get(ctx, pv.Name, metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, pv.Spec.NodeAffinity, updated.Spec.NodeAffinity)
+		var plan pvNodeAffinityMigrationPlan
+		assert.NoError(t, json.Unmarshal([]byte(updated.Annotations[common.AnnNodeAffinityMigrationPlan]), &plan))
+		assert.Equal(t, datastoreURL, plan.DatastoreURL)
+		assert.Equal(t, topologySegments, plan.TopologySegments)
+		assert.NotEmpty(t, plan.Steps)
+	})
+}
+
+func TestAreTopologySegmentsAccessible(t *testing.T) {
+	accessibleSegments := []map[string]string{
+		{"region": "region-1", "zone": "zone-a"},
+		{"region": "region-1", "zone": "zone-b"},
+	}
+	tests := []struct {
+		name     string
+		segments []map[string]string
+		expected bool
+	}{
+		{"same segments", accessibleSegments, true},
+		{"subset of the labels", []map[string]string{{"zone": "zone-b"}}, true},
+		{"inaccessible zone", []map[string]string{{"zone": "zone-a"}, {"zone": "zone-c"}}, false},
+		{"no common label", []map[string]string{{"host": "host-1"}}, false},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, areTopologySegmentsAccessible(test.segments, accessibleSegments), test.name)
+	}
+}
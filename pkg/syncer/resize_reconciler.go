@@ -35,6 +35,7 @@ import (
 	"k8s.io/client-go/util/workqueue"
 
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
 )
 
 // ResizeReconciler is the interface of resizeReconciler.
@@ -109,9 +110,8 @@ func newResizeReconciler(
 	// FileSystemResizePending is not removed from SV PVC  when syncer is down
 	// and FileSystemResizePending was removed from a TKG PVC.
 	// https://github.com/kubernetes-sigs/vsphere-csi-driver/issues/591
-	_, err := pvcInformer.Informer().AddEventHandlerWithResyncPeriod(cache.ResourceEventHandlerFuncs{
-		UpdateFunc: rc.updatePVC,
-	}, resyncPeriod)
+	_, err := pvcInformer.Informer().AddEventHandlerWithResyncPeriod(k8s.NewEventHandler(
+		"resize-pvc", nil, rc.updatePVC, nil), resyncPeriod)
 	if err != nil {
 		return nil, logger.LogNewErrorf(log, "failed to add event handler on PVC informer. Error: %v", err)
 	}
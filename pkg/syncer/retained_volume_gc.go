@@ -0,0 +1,152 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"time"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+
+	volumes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+// isVolumeRetained returns true if the volume was retained with a safety
+// snapshot when its PV was deleted. Full sync must not remove such volumes
+// from CNS, as they are deleted by csiPurgeRetainedVolumes once their
+// retention period is over.
+func isVolumeRetained(ctx context.Context, metadataSyncer *metadataSyncInformer, volManager volumes.Manager,
+	volumeID string) bool {
+	log := logger.GetLogger(ctx)
+	if !metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.VolumeDeletionProtection) {
+		return false
+	}
+	retainedAt, err := volManager.RetrieveVStorageObjectMetadataValue(ctx, volumeID,
+		common.VStorageObjectMetadataRetainedAt)
+	if err != nil {
+		// Keep the volume until its metadata can be checked.
+		log.Warnf("failed to get retention time of volume %q. Err: %v", volumeID, err)
+		return true
+	}
+	return retainedAt != ""
+}
+
+// csiPurgeRetainedVolumes deletes the volumes retained with a safety
+// snapshot, along with their safety snapshot, whose retention period has
+// expired.
+func csiPurgeRetainedVolumes(ctx context.Context, metadataSyncer *metadataSyncInformer) {
+	log := logger.GetLogger(ctx)
+	log.Debugf("csiPurgeRetainedVolumes: start")
+	retention := time.Duration(metadataSyncer.configInfo.Cfg.DeletionProtection.SnapshotRetentionInHours) *
+		time.Hour
+	if isMultiVCenterFssEnabled && len(metadataSyncer.volumeManagers) > 0 {
+		for vcHost, volumeManager := range metadataSyncer.volumeManagers {
+			purgeRetainedVolumes(ctx, vcHost, volumeManager, retention)
+		}
+	} else {
+		purgeRetainedVolumes(ctx, metadataSyncer.host, metadataSyncer.volumeManager, retention)
+	}
+	log.Debugf("csiPurgeRetainedVolumes: end")
+}
+
+// purgeRetainedVolumes deletes the expired retained volumes on a single
+// vCenter.
+func purgeRetainedVolumes(ctx context.Context, vcHost string, volumeManager volumes.Manager,
+	retention time.Duration) {
+	log := logger.GetLogger(ctx)
+	volumeIDs, err := volumeManager.ListVStorageObjectsWithMetadataKey(ctx, common.VStorageObjectMetadataRetainedAt)
+	if err != nil {
+		log.Errorf("purgeRetainedVolumes: failed to list retained volumes on vCenter %q. Err: %v", vcHost, err)
+		return
+	}
+	for _, volumeID := range volumeIDs {
+		retainedAt, err := volumeManager.RetrieveVStorageObjectMetadataValue(ctx, volumeID,
+			common.VStorageObjectMetadataRetainedAt)
+		if err != nil {
+			log.Warnf("purgeRetainedVolumes: failed to get retention time of volume %q. Err: %v", volumeID, err)
+			continue
+		}
+		retainedTime, err := time.Parse(time.RFC3339, retainedAt)
+		if err != nil {
+			log.Warnf("purgeRetainedVolumes: invalid retention time %q on volume %q. Err: %v",
+				retainedAt, volumeID, err)
+			continue
+		}
+		if time.Since(retainedTime) < retention {
+			continue
+		}
+		deleted, err := deleteRetainedVolume(ctx, volumeManager, volumeID)
+		if err != nil {
+			log.Errorf("purgeRetainedVolumes: failed to delete retained volume %q on vCenter %q. Err: %v",
+				volumeID, vcHost, err)
+			continue
+		}
+		if !deleted {
+			continue
+		}
+		log.Infof("purgeRetainedVolumes: deleted volume %q retained at %s", volumeID, retainedAt)
+	}
+}
+
+// deleteRetainedVolume deletes the safety snapshot of a retained volume and
+// then the volume. The volume is kept, and false is returned, as long as it
+// has other snapshots, which belong to VolumeSnapshots of the user.
+func deleteRetainedVolume(ctx context.Context, volumeManager volumes.Manager, volumeID string) (bool, error) {
+	log := logger.GetLogger(ctx)
+	safetySnapshotID, err := volumeManager.RetrieveVStorageObjectMetadataValue(ctx, volumeID,
+		common.VStorageObjectMetadataSafetySnapshotID)
+	if err != nil {
+		return false, err
+	}
+	// The volume has other snapshots if any of its first two snapshots isn't
+	// the safety snapshot, so there's no need to page through all of them.
+	result, err := volumeManager.QuerySnapshots(ctx, cnstypes.CnsSnapshotQueryFilter{
+		SnapshotQuerySpecs: []cnstypes.CnsSnapshotQuerySpec{{VolumeId: cnstypes.CnsVolumeId{Id: volumeID}}},
+		Cursor:             &cnstypes.CnsCursor{Offset: 0, Limit: 2},
+	})
+	if err != nil {
+		return false, err
+	}
+	hasSafetySnapshot := false
+	var entries []cnstypes.CnsSnapshotQueryResultEntry
+	if result != nil {
+		entries = result.Entries
+	}
+	for _, entry := range entries {
+		if entry.Error != nil {
+			continue
+		}
+		snapshotID := volumeID + common.VSphereCSISnapshotIdDelimiter + entry.Snapshot.SnapshotId.Id
+		if snapshotID != safetySnapshotID {
+			log.Infof("deleteRetainedVolume: volume %q still has snapshot %q, keeping it until the snapshot "+
+				"is deleted", volumeID, snapshotID)
+			return false, nil
+		}
+		hasSafetySnapshot = true
+	}
+	if hasSafetySnapshot {
+		if err := common.DeleteSnapshotUtil(ctx, volumeManager, safetySnapshotID); err != nil {
+			return false, err
+		}
+	}
+	if _, err := common.DeleteVolumeUtil(ctx, volumeManager, volumeID, true); err != nil {
+		return false, err
+	}
+	return true, nil
+}
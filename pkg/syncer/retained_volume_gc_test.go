@@ -0,0 +1,66 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/unittestcommon"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+)
+
+func TestPurgeRetainedVolumes(t *testing.T) {
+	ctx := context.Background()
+	retention := 24 * time.Hour
+	expired := time.Now().Add(-2 * retention).UTC().Format(time.RFC3339)
+	recent := time.Now().UTC().Format(time.RFC3339)
+	retainedMetadata := func(retainedAt, volumeID string) map[string]string {
+		return map[string]string{
+			common.VStorageObjectMetadataRetainedAt:       retainedAt,
+			common.VStorageObjectMetadataSafetySnapshotID: volumeID + "+safety-" + volumeID,
+		}
+	}
+	volumeManager := unittestcommon.NewFakeVolumeManager(map[string]map[string]string{
+		"volume-expired":       retainedMetadata(expired, "volume-expired"),
+		"volume-user-snapshot": retainedMetadata(expired, "volume-user-snapshot"),
+		"volume-recent":        retainedMetadata(recent, "volume-recent"),
+	})
+	volumeManager.Snapshots = map[string][]string{
+		"volume-expired":       {"safety-volume-expired"},
+		"volume-user-snapshot": {"safety-volume-user-snapshot", "user-snapshot"},
+		"volume-recent":        {"safety-volume-recent"},
+	}
+
+	purgeRetainedVolumes(ctx, "vc-1", volumeManager, retention)
+	// Only the expired volume without snapshots of the user is deleted, along
+	// with its safety snapshot.
+	assert.Equal(t, []string{"volume-expired"}, volumeManager.DeletedVolumes)
+	assert.Empty(t, volumeManager.Snapshots["volume-expired"])
+	assert.Equal(t, []string{"safety-volume-user-snapshot", "user-snapshot"},
+		volumeManager.Snapshots["volume-user-snapshot"])
+	assert.Equal(t, []string{"safety-volume-recent"}, volumeManager.Snapshots["volume-recent"])
+
+	// The volume is deleted once the snapshot of the user is.
+	volumeManager.Snapshots["volume-user-snapshot"] = []string{"safety-volume-user-snapshot"}
+	purgeRetainedVolumes(ctx, "vc-1", volumeManager, retention)
+	assert.Equal(t, []string{"volume-expired", "volume-user-snapshot"}, volumeManager.DeletedVolumes)
+	assert.Empty(t, volumeManager.Snapshots["volume-user-snapshot"])
+}
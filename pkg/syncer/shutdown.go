@@ -0,0 +1,50 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"sync/atomic"
+
+	volumes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/utils"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+// shuttingDown is true once the syncer started draining on shutdown.
+var shuttingDown atomic.Bool
+
+// Shutdown drains the syncer before it exits, so that rolling upgrades don't
+// strand half-finished operations. No new full sync is started, then the
+// syncer waits, bounded by X_CSI_SHUTDOWN_DRAIN_TIMEOUT, for the CNS tasks
+// already invoked to be persisted in the operation store. The progress of the
+// running full syncs and the other registered caches are flushed last.
+func Shutdown(ctx context.Context) {
+	log := logger.GetLogger(ctx)
+	timeout := utils.GetShutdownDrainTimeout(ctx)
+	log.Infof("Draining in-flight operations for up to %v", timeout)
+	shuttingDown.Store(true)
+	drainCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if err := volumes.WaitForTaskRecords(drainCtx); err != nil {
+		log.Warnf("in-flight CNS tasks may be invoked again after the restart. Err: %v", err)
+	}
+	flushFullSyncCheckpoints(drainCtx)
+	utils.FlushCaches(drainCtx)
+	log.Info("Done draining in-flight operations")
+}
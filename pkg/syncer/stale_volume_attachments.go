@@ -0,0 +1,226 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	clientset "k8s.io/client-go/kubernetes"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+)
+
+const (
+	// staleVolumeAttachmentGracePeriod is the time for which the node of a
+	// VolumeAttachment must have been found deleted before the
+	// VolumeAttachment is deleted, so that a node being re-registered under
+	// the same name keeps its volumes.
+	staleVolumeAttachmentGracePeriod = 10 * time.Minute
+
+	// staleVolumeAttachmentReasonDeleted is the reason of the event recorded
+	// on the PV when its VolumeAttachment to a deleted node is deleted.
+	staleVolumeAttachmentReasonDeleted = "StaleVolumeAttachmentDeleted"
+	// staleVolumeAttachmentReasonDeleteFailed is the reason of the event
+	// recorded on the PV when its VolumeAttachment to a deleted node couldn't
+	// be deleted.
+	staleVolumeAttachmentReasonDeleteFailed = "StaleVolumeAttachmentDeleteFailed"
+)
+
+// staleVolumeAttachmentsFirstSeen holds the time at which each
+// VolumeAttachment was first found with a deleted node. It is only accessed
+// by the stale VolumeAttachment GC goroutine.
+var staleVolumeAttachmentsFirstSeen = make(map[string]time.Time)
+
+// getStaleVolumeAttachmentGCIntervalInMin returns the interval at which the
+// stale VolumeAttachments are collected. If environment variable
+// STALE_VOLUME_ATTACHMENT_GC_INTERVAL_MINUTES is set and valid, return the
+// interval value read from environment variable.
+// Otherwise, use the default value 5 minutes.
+func getStaleVolumeAttachmentGCIntervalInMin(ctx context.Context) int {
+	log := logger.GetLogger(ctx)
+	staleVolumeAttachmentGCIntervalInMin := defaultStaleVolumeAttachmentGCIntervalInMin
+	if v := os.Getenv("STALE_VOLUME_ATTACHMENT_GC_INTERVAL_MINUTES"); v != "" {
+		if value, err := strconv.Atoi(v); err == nil && value > 0 {
+			staleVolumeAttachmentGCIntervalInMin = value
+			log.Infof("StaleVolumeAttachmentGC: interval is set to %d minutes", staleVolumeAttachmentGCIntervalInMin)
+		} else {
+			log.Warnf("StaleVolumeAttachmentGC: interval set in env variable "+
+				"STALE_VOLUME_ATTACHMENT_GC_INTERVAL_MINUTES %s is invalid, will use the default interval", v)
+		}
+	}
+	return staleVolumeAttachmentGCIntervalInMin
+}
+
+// csiCollectStaleVolumeAttachments deletes the VolumeAttachments of the
+// driver whose node was deleted, e.g. after the node VM crashed and was
+// removed from the cluster. Such a VolumeAttachment blocks the attach of its
+// ReadWriteOnce volume to the surviving nodes until it is deleted.
+func csiCollectStaleVolumeAttachments(ctx context.Context, k8sClient clientset.Interface) {
+	collectStaleVolumeAttachments(ctx, k8sClient, staleVolumeAttachmentsFirstSeen, time.Now())
+}
+
+// collectStaleVolumeAttachments deletes the VolumeAttachments of the driver
+// which are stale, i.e. whose node doesn't exist, whose volume is not used by
+// any pod still scheduled to the node, and which were already found with a
+// deleted node staleVolumeAttachmentGracePeriod before now. firstSeen is
+// updated with the VolumeAttachments found stale.
+func collectStaleVolumeAttachments(ctx context.Context, k8sClient clientset.Interface,
+	firstSeen map[string]time.Time, now time.Time) {
+	log := logger.GetLogger(ctx)
+	vaList, err := k8sClient.StorageV1().VolumeAttachments().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Errorf("collectStaleVolumeAttachments: failed to list VolumeAttachments. Err: %v", err)
+		return
+	}
+	nodeList, err := k8sClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Errorf("collectStaleVolumeAttachments: failed to list nodes. Err: %v", err)
+		return
+	}
+	nodes := make(map[string]bool)
+	for _, node := range nodeList.Items {
+		nodes[node.Name] = true
+	}
+
+	seen := make(map[string]bool)
+	for i := range vaList.Items {
+		va := &vaList.Items[i]
+		if va.Spec.Attacher != csitypes.DriverName() || va.Spec.Source.PersistentVolumeName == nil ||
+			va.DeletionTimestamp != nil || nodes[va.Spec.NodeName] {
+			continue
+		}
+		seen[va.Name] = true
+		if _, ok := firstSeen[va.Name]; !ok {
+			log.Infof("collectStaleVolumeAttachments: node %s of VolumeAttachment %s doesn't exist",
+				va.Spec.NodeName, va.Name)
+			firstSeen[va.Name] = now
+		}
+		if now.Sub(firstSeen[va.Name]) < staleVolumeAttachmentGracePeriod {
+			continue
+		}
+		deleteStaleVolumeAttachment(ctx, k8sClient, va)
+	}
+	// Forget the VolumeAttachments which were deleted or whose node is back.
+	for vaName := range firstSeen {
+		if !seen[vaName] {
+			delete(firstSeen, vaName)
+		}
+	}
+}
+
+// deleteStaleVolumeAttachment deletes the given VolumeAttachment to a deleted
+// node, unless a pod still scheduled to the node uses its volume. The
+// external-attacher then detaches the volume from the node VM, if it still
+// exists, before removing the VolumeAttachment.
+func deleteStaleVolumeAttachment(ctx context.Context, k8sClient clientset.Interface,
+	va *storagev1.VolumeAttachment) {
+	log := logger.GetLogger(ctx)
+	pvName := *va.Spec.Source.PersistentVolumeName
+	pv, err := k8sClient.CoreV1().PersistentVolumes().Get(ctx, pvName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		pv = nil
+	} else if err != nil {
+		log.Errorf("deleteStaleVolumeAttachment: failed to get PV %s. Err: %v", pvName, err)
+		return
+	}
+	if pv != nil && pv.Spec.ClaimRef != nil {
+		inUse, err := isPVCUsedOnNode(ctx, k8sClient, pv.Spec.ClaimRef.Namespace, pv.Spec.ClaimRef.Name,
+			va.Spec.NodeName)
+		if err != nil {
+			log.Errorf("deleteStaleVolumeAttachment: failed to list pods of node %s. Err: %v",
+				va.Spec.NodeName, err)
+			return
+		}
+		if inUse {
+			log.Infof("deleteStaleVolumeAttachment: pvc %s/%s is still used by a pod of deleted node %s, "+
+				"not deleting VolumeAttachment %s", pv.Spec.ClaimRef.Namespace, pv.Spec.ClaimRef.Name,
+				va.Spec.NodeName, va.Name)
+			return
+		}
+	}
+
+	// Annotate the VolumeAttachment first, so that the orchestrator forgets
+	// the node for the volume even if the VolumeAttachment is removed while
+	// still reported as attached.
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{common.AnnNodeDeleted: "yes"},
+		},
+	})
+	if err != nil {
+		log.Errorf("deleteStaleVolumeAttachment: failed to create patch for VolumeAttachment %s. Err: %v",
+			va.Name, err)
+		return
+	}
+	_, err = k8sClient.StorageV1().VolumeAttachments().Patch(ctx, va.Name, types.MergePatchType, patch,
+		metav1.PatchOptions{})
+	if err == nil {
+		err = k8sClient.StorageV1().VolumeAttachments().Delete(ctx, va.Name, metav1.DeleteOptions{})
+	}
+	if err != nil && !apierrors.IsNotFound(err) {
+		msg := fmt.Sprintf("Failed to delete VolumeAttachment %s of volume %s to deleted node %s. Err: %v",
+			va.Name, pvName, va.Spec.NodeName, err)
+		log.Errorf("deleteStaleVolumeAttachment: %s", msg)
+		if pv != nil {
+			generateEventOnObject(ctx, pv, v1.EventTypeWarning, staleVolumeAttachmentReasonDeleteFailed, msg)
+		}
+		return
+	}
+	msg := fmt.Sprintf("Deleted VolumeAttachment %s of volume %s to deleted node %s", va.Name, pvName,
+		va.Spec.NodeName)
+	log.Infof("deleteStaleVolumeAttachment: %s", msg)
+	if pv != nil {
+		generateEventOnObject(ctx, pv, v1.EventTypeNormal, staleVolumeAttachmentReasonDeleted, msg)
+	}
+}
+
+// isPVCUsedOnNode returns true if a pod scheduled to the given node, which
+// is neither succeeded nor failed, uses the given PVC.
+func isPVCUsedOnNode(ctx context.Context, k8sClient clientset.Interface, namespace, pvcName,
+	nodeName string) (bool, error) {
+	pods, err := k8sClient.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", nodeName).String(),
+	})
+	if err != nil {
+		return false, err
+	}
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName != nodeName || pod.Status.Phase == v1.PodSucceeded ||
+			pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		for _, volume := range pod.Spec.Volumes {
+			if volume.PersistentVolumeClaim != nil && volume.PersistentVolumeClaim.ClaimName == pvcName {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+)
+
+func TestCollectStaleVolumeAttachments(t *testing.T) {
+	ctx := context.Background()
+	newVA := func(name, nodeName string) *storagev1.VolumeAttachment {
+		pvName := "pv-" + name
+		return &storagev1.VolumeAttachment{
+			ObjectMeta: metav1.ObjectMeta{Name: "va-" + name},
+			Spec: storagev1.VolumeAttachmentSpec{
+				Attacher: csitypes.Name,
+				NodeName: nodeName,
+				Source:   storagev1.VolumeAttachmentSource{PersistentVolumeName: &pvName},
+			},
+			Status: storagev1.VolumeAttachmentStatus{Attached: true},
+		}
+	}
+	newPV := func(name string) *v1.PersistentVolume {
+		return &v1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "pv-" + name},
+			Spec: v1.PersistentVolumeSpec{
+				ClaimRef: &v1.ObjectReference{Namespace: "default", Name: "pvc-" + name},
+			},
+		}
+	}
+	newPod := func(name, nodeName string, phase v1.PodPhase) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "pod-" + name, Namespace: "default"},
+			Spec: v1.PodSpec{
+				NodeName: nodeName,
+				Volumes: []v1.Volume{{
+					Name: "data",
+					VolumeSource: v1.VolumeSource{
+						PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: "pvc-" + name},
+					},
+				}},
+			},
+			Status: v1.PodStatus{Phase: phase},
+		}
+	}
+	k8sClient := testclient.NewSimpleClientset(
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}},
+		// The node of the volume exists.
+		newVA("alive", "node-1"), newPV("alive"),
+		// The node of the volume was deleted and its pod is gone.
+		newVA("stale", "node-2"), newPV("stale"),
+		// The node of the volume was deleted but its pod is still running.
+		newVA("in-use", "node-2"), newPV("in-use"), newPod("in-use", "node-2", v1.PodRunning),
+		// The node of the volume was deleted and its pod completed.
+		newVA("completed", "node-2"), newPV("completed"), newPod("completed", "node-2", v1.PodSucceeded),
+	)
+	listVAs := func() []string {
+		vaList, err := k8sClient.StorageV1().VolumeAttachments().List(ctx, metav1.ListOptions{})
+		assert.NoError(t, err)
+		var names []string
+		for _, va := range vaList.Items {
+			names = append(names, va.Name)
+		}
+		return names
+	}
+
+	firstSeen := make(map[string]time.Time)
+	now := time.Now()
+	collectStaleVolumeAttachments(ctx, k8sClient, firstSeen, now)
+	assert.ElementsMatch(t, []string{"va-alive", "va-stale", "va-in-use", "va-completed"}, listVAs(),
+		"no VolumeAttachment should be deleted within the grace period")
+	assert.Len(t, firstSeen, 3)
+
+	collectStaleVolumeAttachments(ctx, k8sClient, firstSeen, now.Add(staleVolumeAttachmentGracePeriod))
+	assert.ElementsMatch(t, []string{"va-alive", "va-in-use"}, listVAs())
+
+	collectStaleVolumeAttachments(ctx, k8sClient, firstSeen, now.Add(2*staleVolumeAttachmentGracePeriod))
+	assert.Equal(t, map[string]time.Time{"va-in-use": now}, firstSeen,
+		"deleted VolumeAttachments should be forgotten")
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientset "k8s.io/client-go/kubernetes"
+
+	volumes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/utils"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/placementengine"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+)
+
+// pvAdded generates node affinity for statically provisioned PVs in
+// topology aware vanilla deployments.
+func pvAdded(obj interface{}, k8sClient clientset.Interface, metadataSyncer *metadataSyncInformer) {
+	ctx, log := logger.GetNewContextWithLogger()
+	pv, ok := obj.(*v1.PersistentVolume)
+	if pv == nil || !ok {
+		log.Warnf("PVAdded: unrecognized object %+v", obj)
+		return
+	}
+	if metadataSyncer.clusterFlavor != cnstypes.CnsClusterFlavorVanilla ||
+		!metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.StaticPVNodeAffinity) ||
+		!isTopologyAwareDeployment(metadataSyncer) {
+		return
+	}
+	if nodeMgr == nil {
+		// Node VMs are discovered through the CSINodeTopology informer,
+		// without it accessible nodes cannot be computed.
+		log.Debugf("PVAdded: node manager is not initialized, skipping node affinity for PV %q", pv.Name)
+		return
+	}
+	if !needsStaticPVNodeAffinity(ctx, pv) {
+		return
+	}
+	if err := csiGenerateStaticPVNodeAffinity(ctx, k8sClient, metadataSyncer, pv); err != nil {
+		log.Errorf("PVAdded: failed to generate node affinity for static PV %q. Err: %v", pv.Name, err)
+	}
+}
+
+// needsStaticPVNodeAffinity returns true if the given PV is a statically
+// provisioned PV of this driver without node affinity.
+func needsStaticPVNodeAffinity(ctx context.Context, pv *v1.PersistentVolume) bool {
+	return pv.Spec.CSI != nil && pv.Spec.CSI.Driver == csitypes.DriverName() && pv.Spec.NodeAffinity == nil &&
+		pv.DeletionTimestamp == nil && !isDynamicallyCreatedVolume(ctx, pv)
+}
+
+// isTopologyAwareDeployment returns true if topology categories are
+// configured in the vSphere config secret.
+func isTopologyAwareDeployment(metadataSyncer *metadataSyncInformer) bool {
+	labels := metadataSyncer.configInfo.Cfg.Labels
+	return labels.TopologyCategories != "" || labels.Zone != "" || labels.Region != ""
+}
+
+// csiGenerateStaticPVNodeAffinity finds the datastore backing the volume of a
+// statically provisioned PV, computes the topology segments from which all
+// nodes can access that datastore and patches them as the node affinity of
+// the PV.
+func csiGenerateStaticPVNodeAffinity(ctx context.Context, k8sClient clientset.Interface,
+	metadataSyncer *metadataSyncInformer, pv *v1.PersistentVolume) error {
+	log := logger.GetLogger(ctx)
+	volumeID := pv.Spec.CSI.VolumeHandle
+	vcHost, datastoreURL, err := findVolumeDatastore(ctx, metadataSyncer, volumeID)
+	if err != nil {
+		return err
+	}
+	topologySegments, err := getDatastoreTopologySegments(ctx, vcHost, datastoreURL)
+	if err != nil {
+		return err
+	}
+	nodeAffinity := getNodeAffinityForTopologySegments(topologySegments)
+	if nodeAffinity == nil {
+		log.Infof("No topology segments found for datastore %q, not setting node affinity on PV %q",
+			datastoreURL, pv.Name)
+		return nil
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"nodeAffinity": nodeAffinity,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = k8sClient.CoreV1().PersistentVolumes().Patch(ctx, pv.Name, types.MergePatchType, patch,
+		metav1.PatchOptions{})
+	if err != nil {
+		return logger.LogNewErrorf(log, "failed to patch node affinity on PV %q. Err: %v", pv.Name, err)
+	}
+	log.Infof("Set node affinity %+v on static PV %q backed by datastore %q", topologySegments, pv.Name,
+		datastoreURL)
+	return nil
+}
+
+// getDatastoreTopologySegments returns the topology segments from which all
+// nodes can access the given datastore.
+func getDatastoreTopologySegments(ctx context.Context, vcHost string, datastoreURL string) (
+	[]map[string]string, error) {
+	log := logger.GetLogger(ctx)
+	vc, err := cnsvsphere.GetVirtualCenterManager(ctx).GetVirtualCenter(ctx, vcHost)
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to get vCenter %q. Err: %v", vcHost, err)
+	}
+	allNodeVMs, err := nodeMgr.GetAllNodesByVC(ctx, vcHost)
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to get node VMs on vCenter %q. Err: %v", vcHost, err)
+	}
+	accessibleNodes, err := common.GetNodeVMsWithAccessToDatastore(ctx, vc, datastoreURL, allNodeVMs)
+	if err != nil || len(accessibleNodes) == 0 {
+		return nil, logger.LogNewErrorf(log, "failed to find nodes with access to datastore %q. Err: %v",
+			datastoreURL, err)
+	}
+	var accessibleNodeNames []string
+	for _, vmRef := range accessibleNodes {
+		vmUUID, err := cnsvsphere.GetUUIDFromVMReference(ctx, vc, vmRef.Reference())
+		if err != nil {
+			return nil, err
+		}
+		nodeName, err := nodeMgr.GetNodeNameByUUID(ctx, vmUUID)
+		if err != nil {
+			return nil, err
+		}
+		accessibleNodeNames = append(accessibleNodeNames, nodeName)
+	}
+	topologySegments, err := placementengine.GetTopologyInfoFromNodes(ctx,
+		placementengine.VanillaRetrieveTopologyInfoParams{
+			VCHost:       vcHost,
+			NodeNames:    accessibleNodeNames,
+			DatastoreURL: datastoreURL,
+		})
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to find accessible topologies for datastore %q. Err: %v",
+			datastoreURL, err)
+	}
+	return topologySegments, nil
+}
+
+// findVolumeDatastore returns the vCenter host and datastore URL of the
+// given volume.
+func findVolumeDatastore(ctx context.Context, metadataSyncer *metadataSyncInformer, volumeID string) (
+	string, string, error) {
+	log := logger.GetLogger(ctx)
+	volumeManagers := map[string]volumes.Manager{metadataSyncer.host: metadataSyncer.volumeManager}
+	if isMultiVCenterFssEnabled {
+		volumeManagers = metadataSyncer.volumeManagers
+	}
+	for vcHost, volumeManager := range volumeManagers {
+		volumeDetails, err := utils.QueryVolumeDetailsUtil(ctx, volumeManager,
+			[]cnstypes.CnsVolumeId{{Id: volumeID}})
+		if err != nil {
+			log.Debugf("failed to query volume %q on vCenter %q. Err: %v", volumeID, vcHost, err)
+			continue
+		}
+		if details, ok := volumeDetails[volumeID]; ok && details.DatastoreUrl != "" {
+			return vcHost, details.DatastoreUrl, nil
+		}
+	}
+	return "", "", logger.LogNewErrorf(log, "volume %q is not found in CNS", volumeID)
+}
+
+// getNodeAffinityForTopologySegments converts topology segments into PV
+// node affinity. Each segment becomes a node selector term, so the terms are
+// ORed while the labels within a segment are ANDed.
+func getNodeAffinityForTopologySegments(topologySegments []map[string]string) *v1.VolumeNodeAffinity {
+	var terms []v1.NodeSelectorTerm
+	for _, segment := range topologySegments {
+		if len(segment) == 0 {
+			continue
+		}
+		keys := make([]string, 0, len(segment))
+		for key := range segment {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		var expressions []v1.NodeSelectorRequirement
+		for _, key := range keys {
+			expressions = append(expressions, v1.NodeSelectorRequirement{
+				Key:      key,
+				Operator: v1.NodeSelectorOpIn,
+				Values:   []string{segment[key]},
+			})
+		}
+		terms = append(terms, v1.NodeSelectorTerm{MatchExpressions: expressions})
+	}
+	if len(terms) == 0 {
+		return nil
+	}
+	return &v1.VolumeNodeAffinity{
+		Required: &v1.NodeSelector{NodeSelectorTerms: terms},
+	}
+}
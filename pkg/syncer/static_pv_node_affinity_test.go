@@ -0,0 +1,159 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+)
+
+func TestGetNodeAffinityForTopologySegments(t *testing.T) {
+	tests := []struct {
+		name             string
+		topologySegments []map[string]string
+		expected         *v1.VolumeNodeAffinity
+	}{
+		{
+			name:     "no segments",
+			expected: nil,
+		},
+		{
+			name:             "empty segments",
+			topologySegments: []map[string]string{{}, {}},
+			expected:         nil,
+		},
+		{
+			name: "multi-label segments",
+			topologySegments: []map[string]string{
+				{"topology.csi.vmware.com/k8s-zone": "zone-a", "topology.csi.vmware.com/k8s-region": "region-1"},
+				{},
+				{"topology.csi.vmware.com/k8s-zone": "zone-b"},
+			},
+			expected: &v1.VolumeNodeAffinity{
+				Required: &v1.NodeSelector{
+					NodeSelectorTerms: []v1.NodeSelectorTerm{
+						{
+							MatchExpressions: []v1.NodeSelectorRequirement{
+								{
+									Key:      "topology.csi.vmware.com/k8s-region",
+									Operator: v1.NodeSelectorOpIn,
+									Values:   []string{"region-1"},
+								},
+								{
+									Key:      "topology.csi.vmware.com/k8s-zone",
+									Operator: v1.NodeSelectorOpIn,
+									Values:   []string{"zone-a"},
+								},
+							},
+						},
+						{
+							MatchExpressions: []v1.NodeSelectorRequirement{
+								{
+									Key:      "topology.csi.vmware.com/k8s-zone",
+									Operator: v1.NodeSelectorOpIn,
+									Values:   []string{"zone-b"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, getNodeAffinityForTopologySegments(test.topologySegments))
+		})
+	}
+}
+
+func TestNeedsStaticPVNodeAffinity(t *testing.T) {
+	newPV := func(mutate func(pv *v1.PersistentVolume)) *v1.PersistentVolume {
+		pv := &v1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "static-pv"},
+			Spec: v1.PersistentVolumeSpec{
+				PersistentVolumeSource: v1.PersistentVolumeSource{
+					CSI: &v1.CSIPersistentVolumeSource{Driver: csitypes.DriverName(), VolumeHandle: "volume-1"},
+				},
+			},
+		}
+		if mutate != nil {
+			mutate(pv)
+		}
+		return pv
+	}
+	tests := []struct {
+		name     string
+		pv       *v1.PersistentVolume
+		expected bool
+	}{
+		{
+			name:     "static PV without node affinity",
+			pv:       newPV(nil),
+			expected: true,
+		},
+		{
+			name: "node affinity already set",
+			pv: newPV(func(pv *v1.PersistentVolume) {
+				pv.Spec.NodeAffinity = getNodeAffinityForTopologySegments([]map[string]string{
+					{"topology.csi.vmware.com/k8s-zone": "zone-a"},
+				})
+			}),
+			expected: false,
+		},
+		{
+			name: "dynamically provisioned PV",
+			pv: newPV(func(pv *v1.PersistentVolume) {
+				pv.Spec.CSI.VolumeAttributes = map[string]string{attribCSIProvisionerID: "1700000000000-1234"}
+			}),
+			expected: false,
+		},
+		{
+			name: "PV of another driver",
+			pv: newPV(func(pv *v1.PersistentVolume) {
+				pv.Spec.CSI.Driver = "other.csi.example.com"
+			}),
+			expected: false,
+		},
+		{
+			name: "non CSI PV",
+			pv: newPV(func(pv *v1.PersistentVolume) {
+				pv.Spec.CSI = nil
+			}),
+			expected: false,
+		},
+		{
+			name: "PV being deleted",
+			pv: newPV(func(pv *v1.PersistentVolume) {
+				now := metav1.Now()
+				pv.DeletionTimestamp = &now
+			}),
+			expected: false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, needsStaticPVNodeAffinity(context.Background(), test.pv))
+		})
+	}
+}
@@ -0,0 +1,243 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	pbmtypes "github.com/vmware/govmomi/pbm/types"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+)
+
+const (
+	// storagePolicyStorageClassMarker marks, in their description, the
+	// storage policies of vCenter for which a StorageClass is generated.
+	storagePolicyStorageClassMarker = "[k8s-storageclass]"
+	// storagePolicyMapperManagedLabel is the label of the StorageClasses
+	// generated from storage policies. StorageClasses without it are never
+	// modified by the mapper.
+	storagePolicyMapperManagedLabel = "csi.vsphere.vmware.com/generated-from-storage-policy"
+	// storagePolicyMapperPolicyIDAnnotation records the ID of the storage
+	// policy a StorageClass was generated from.
+	storagePolicyMapperPolicyIDAnnotation = "csi.vsphere.vmware.com/storage-policy-id"
+)
+
+// invalidStorageClassNameChars matches the characters which are not allowed
+// in the name of a StorageClass.
+var invalidStorageClassNameChars = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+// getStoragePolicyMapperIntervalInMin returns the interval at which the
+// StorageClasses are reconciled with the storage policies of vCenter. If
+// environment variable STORAGE_POLICY_MAPPER_INTERVAL_MINUTES is set and
+// valid, return the interval value read from environment variable.
+// Otherwise, use the default value 10 minutes.
+func getStoragePolicyMapperIntervalInMin(ctx context.Context) int {
+	log := logger.GetLogger(ctx)
+	storagePolicyMapperIntervalInMin := defaultStoragePolicyMapperIntervalInMin
+	if v := os.Getenv("STORAGE_POLICY_MAPPER_INTERVAL_MINUTES"); v != "" {
+		if value, err := strconv.Atoi(v); err == nil && value > 0 {
+			storagePolicyMapperIntervalInMin = value
+			log.Infof("StoragePolicyMapper: interval is set to %d minutes", storagePolicyMapperIntervalInMin)
+		} else {
+			log.Warnf("StoragePolicyMapper: interval set in env variable STORAGE_POLICY_MAPPER_INTERVAL_MINUTES %s "+
+				"is invalid, will use the default interval", v)
+		}
+	}
+	return storagePolicyMapperIntervalInMin
+}
+
+// csiMapStoragePoliciesToStorageClasses generates a StorageClass for each
+// storage policy marked with storagePolicyStorageClassMarker, and deletes the
+// generated StorageClasses whose storage policy was removed or unmarked.
+func csiMapStoragePoliciesToStorageClasses(ctx context.Context, k8sClient clientset.Interface,
+	metadataSyncer *metadataSyncInformer) {
+	log := logger.GetLogger(ctx)
+	log.Debugf("csiMapStoragePoliciesToStorageClasses: start")
+	var vcenters []*cnsvsphere.VirtualCenter
+	if isMultiVCenterFssEnabled {
+		vcconfigs, err := cnsvsphere.GetVirtualCenterConfigs(ctx, metadataSyncer.configInfo.Cfg)
+		if err != nil {
+			log.Errorf("StoragePolicyMapper: failed to get VirtualCenterConfigs. Err: %v", err)
+			return
+		}
+		for _, vcconfig := range vcconfigs {
+			vcenter, err := cnsvsphere.GetVirtualCenterInstanceForVCenterHost(ctx, vcconfig.Host, true)
+			if err != nil {
+				log.Errorf("StoragePolicyMapper: failed to get vCenter instance for %q. Err: %v",
+					vcconfig.Host, err)
+				return
+			}
+			vcenters = append(vcenters, vcenter)
+		}
+	} else {
+		vcenter, err := cnsvsphere.GetVirtualCenterInstance(ctx, metadataSyncer.configInfo, false)
+		if err != nil {
+			log.Errorf("StoragePolicyMapper: failed to get vCenter instance. Err: %v", err)
+			return
+		}
+		vcenters = append(vcenters, vcenter)
+	}
+
+	var profiles []*pbmtypes.PbmCapabilityProfile
+	for _, vcenter := range vcenters {
+		vcProfiles, err := vcenter.PbmQueryStorageProfiles(ctx)
+		if err != nil {
+			// Without the full list of storage policies, generated StorageClasses
+			// could be deleted by mistake, so nothing is reconciled.
+			log.Errorf("StoragePolicyMapper: failed to query storage policies of vCenter %q. Err: %v",
+				vcenter.Config.Host, err)
+			return
+		}
+		profiles = append(profiles, vcProfiles...)
+	}
+	reconcileStoragePolicyStorageClasses(ctx, k8sClient, getDesiredStorageClasses(ctx, profiles))
+	log.Debugf("csiMapStoragePoliciesToStorageClasses: end")
+}
+
+// getDesiredStorageClasses returns the StorageClasses to generate for the
+// marked storage policies, keyed by name. Storage policies with the same
+// name in several vCenters map to the same StorageClass.
+func getDesiredStorageClasses(ctx context.Context,
+	profiles []*pbmtypes.PbmCapabilityProfile) map[string]*storagev1.StorageClass {
+	log := logger.GetLogger(ctx)
+	sort.Slice(profiles, func(i, j int) bool {
+		if profiles[i].Name != profiles[j].Name {
+			return profiles[i].Name < profiles[j].Name
+		}
+		return profiles[i].ProfileId.UniqueId < profiles[j].ProfileId.UniqueId
+	})
+	policyNames := make(map[string]string)
+	desired := make(map[string]*storagev1.StorageClass)
+	for _, profile := range profiles {
+		if !strings.Contains(profile.Description, storagePolicyStorageClassMarker) {
+			continue
+		}
+		name := getStorageClassNameForStoragePolicy(profile.Name)
+		if name == "" {
+			log.Warnf("StoragePolicyMapper: no valid StorageClass name for storage policy %q", profile.Name)
+			continue
+		}
+		if policyName, exists := policyNames[name]; exists {
+			if policyName != profile.Name {
+				log.Warnf("StoragePolicyMapper: storage policies %q and %q map to the same StorageClass %q, "+
+					"skipping storage policy %q", policyName, profile.Name, name, profile.Name)
+			}
+			continue
+		}
+		policyNames[name] = profile.Name
+		allowVolumeExpansion := true
+		desired[name] = &storagev1.StorageClass{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Labels:      map[string]string{storagePolicyMapperManagedLabel: "true"},
+				Annotations: map[string]string{storagePolicyMapperPolicyIDAnnotation: profile.ProfileId.UniqueId},
+			},
+			Provisioner:          csitypes.DriverName(),
+			Parameters:           map[string]string{common.AttributeStoragePolicyName: profile.Name},
+			AllowVolumeExpansion: &allowVolumeExpansion,
+		}
+	}
+	return desired
+}
+
+// getStorageClassNameForStoragePolicy returns the name of the StorageClass
+// generated for the given storage policy name, or an empty string if the
+// storage policy name has no valid character.
+func getStorageClassNameForStoragePolicy(policyName string) string {
+	name := invalidStorageClassNameChars.ReplaceAllString(strings.ToLower(policyName), "-")
+	if len(name) > 253 {
+		name = name[:253]
+	}
+	return strings.Trim(name, "-.")
+}
+
+// reconcileStoragePolicyStorageClasses creates the desired StorageClasses
+// which are missing, recreates the generated StorageClasses whose storage
+// policy changed and deletes the generated StorageClasses which are no
+// longer desired.
+func reconcileStoragePolicyStorageClasses(ctx context.Context, k8sClient clientset.Interface,
+	desired map[string]*storagev1.StorageClass) {
+	log := logger.GetLogger(ctx)
+	scClient := k8sClient.StorageV1().StorageClasses()
+	scList, err := scClient.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Errorf("StoragePolicyMapper: failed to list StorageClasses. Err: %v", err)
+		return
+	}
+	existing := make(map[string]*storagev1.StorageClass)
+	for i := range scList.Items {
+		existing[scList.Items[i].Name] = &scList.Items[i]
+	}
+
+	for name, sc := range existing {
+		if sc.Labels[storagePolicyMapperManagedLabel] != "true" {
+			continue
+		}
+		desiredSC, found := desired[name]
+		if found && sc.Provisioner == desiredSC.Provisioner && reflect.DeepEqual(sc.Parameters, desiredSC.Parameters) {
+			continue
+		}
+		// The parameters of a StorageClass are immutable, so the StorageClass
+		// is deleted, and created again below if its storage policy changed.
+		if err := scClient.Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			log.Errorf("StoragePolicyMapper: failed to delete StorageClass %q. Err: %v", name, err)
+			continue
+		}
+		log.Infof("StoragePolicyMapper: deleted StorageClass %q generated from storage policy %q", name,
+			sc.Parameters[common.AttributeStoragePolicyName])
+		if found {
+			// Keep the annotations set on the StorageClass, such as the
+			// default StorageClass annotation.
+			for key, value := range sc.Annotations {
+				if _, exists := desiredSC.Annotations[key]; !exists {
+					desiredSC.Annotations[key] = value
+				}
+			}
+		}
+		delete(existing, name)
+	}
+
+	for name, desiredSC := range desired {
+		if sc, exists := existing[name]; exists {
+			if sc.Labels[storagePolicyMapperManagedLabel] != "true" {
+				log.Warnf("StoragePolicyMapper: StorageClass %q already exists and was not generated from "+
+					"storage policy %q, skipping it", name, desiredSC.Parameters[common.AttributeStoragePolicyName])
+			}
+			continue
+		}
+		if _, err := scClient.Create(ctx, desiredSC, metav1.CreateOptions{}); err != nil {
+			log.Errorf("StoragePolicyMapper: failed to create StorageClass %q. Err: %v", name, err)
+			continue
+		}
+		log.Infof("StoragePolicyMapper: created StorageClass %q for storage policy %q", name,
+			desiredSC.Parameters[common.AttributeStoragePolicyName])
+	}
+}
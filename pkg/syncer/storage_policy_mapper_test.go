@@ -0,0 +1,85 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	pbmtypes "github.com/vmware/govmomi/pbm/types"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+)
+
+func newTestStorageProfile(id, name, description string) *pbmtypes.PbmCapabilityProfile {
+	return &pbmtypes.PbmCapabilityProfile{
+		PbmProfile: pbmtypes.PbmProfile{
+			ProfileId:   pbmtypes.PbmProfileId{UniqueId: id},
+			Name:        name,
+			Description: description,
+		},
+	}
+}
+
+func TestGetStorageClassNameForStoragePolicy(t *testing.T) {
+	assert.Equal(t, "vsan-default-storage-policy", getStorageClassNameForStoragePolicy("vSAN Default Storage Policy"))
+	assert.Equal(t, "gold", getStorageClassNameForStoragePolicy("_Gold_"))
+	assert.Equal(t, "", getStorageClassNameForStoragePolicy("***"))
+}
+
+func TestReconcileStoragePolicyStorageClasses(t *testing.T) {
+	ctx := context.Background()
+	unmanaged := &storagev1.StorageClass{
+		ObjectMeta:  metav1.ObjectMeta{Name: "silver"},
+		Provisioner: "csi.vsphere.vmware.com",
+	}
+	removed := &storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "bronze",
+			Labels: map[string]string{storagePolicyMapperManagedLabel: "true"},
+		},
+		Provisioner: "csi.vsphere.vmware.com",
+		Parameters:  map[string]string{common.AttributeStoragePolicyName: "Bronze"},
+	}
+	k8sClient := testclient.NewSimpleClientset(unmanaged, removed)
+
+	desired := getDesiredStorageClasses(ctx, []*pbmtypes.PbmCapabilityProfile{
+		newTestStorageProfile("id-gold", "Gold", "Tier 1 [k8s-storageclass]"),
+		newTestStorageProfile("id-silver", "Silver", "[k8s-storageclass]"),
+		newTestStorageProfile("id-internal", "Internal", "not exposed to kubernetes"),
+	})
+	assert.Len(t, desired, 2)
+	reconcileStoragePolicyStorageClasses(ctx, k8sClient, desired)
+
+	scList, err := k8sClient.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	assert.NoError(t, err)
+	storageClasses := make(map[string]storagev1.StorageClass)
+	for _, sc := range scList.Items {
+		storageClasses[sc.Name] = sc
+	}
+	// The StorageClass of the removed storage policy is deleted.
+	assert.NotContains(t, storageClasses, "bronze")
+	// The StorageClass which was not generated is left untouched.
+	assert.Nil(t, storageClasses["silver"].Labels)
+	// A StorageClass is generated for the new storage policy.
+	assert.Equal(t, "Gold", storageClasses["gold"].Parameters[common.AttributeStoragePolicyName])
+	assert.Equal(t, "id-gold", storageClasses["gold"].Annotations[storagePolicyMapperPolicyIDAnnotation])
+}
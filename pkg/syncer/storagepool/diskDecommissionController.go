@@ -388,8 +388,8 @@ func (w *DiskDecommController) shouldEnterDiskDecommission(ctx context.Context,
 	log := logger.GetLogger(ctx)
 	spName := sp.GetName()
 	driver, found, _ := unstructured.NestedString(sp.Object, "spec", "driver")
-	if !found || driver != csitypes.Name || spName == "" {
-		log.Warnf("StoragePool watch event for %v does not correspond to %v driver.", spName, csitypes.Name)
+	if !found || driver != csitypes.DriverName() || spName == "" {
+		log.Warnf("StoragePool watch event for %v does not correspond to %v driver.", spName, csitypes.DriverName())
 		return false
 	}
 	drainMode, found, err := unstructured.NestedString(sp.Object, "spec", "parameters", drainModeField)
@@ -506,7 +506,7 @@ func deleteStoragePool(ctx context.Context, spName string) error {
 		return err
 	}
 	driver, found, err := unstructured.NestedString(sp.Object, "spec", "driver")
-	if found && err == nil && driver == csitypes.Name {
+	if found && err == nil && driver == csitypes.DriverName() {
 		log.Infof("Deleting StoragePool %s", spName)
 		err := spClient.Resource(*spResource).Delete(ctx, spName, *metav1.NewDeleteOptions(0))
 		if err != nil {
@@ -547,7 +547,7 @@ func (state *intendedState) createUnstructuredStoragePool(ctx context.Context) *
 				},
 			},
 			"spec": map[string]interface{}{
-				"driver": csitypes.Name,
+				"driver": csitypes.DriverName(),
 				"parameters": map[string]interface{}{
 					"datastoreUrl": state.url,
 				},
@@ -284,7 +284,7 @@ func deleteStoragePools(ctx context.Context, validStoragePoolNames map[string]bo
 		spName := sp.GetName()
 		if _, valid := validStoragePoolNames[spName]; !valid {
 			driver, found, err := unstructured.NestedString(sp.Object, "spec", "driver")
-			if found && err == nil && driver == csitypes.Name {
+			if found && err == nil && driver == csitypes.DriverName() {
 				log.Infof("Deleting StoragePool %s", spName)
 				err := spClient.Resource(*spResource).Delete(ctx, spName, *metav1.NewDeleteOptions(0))
 				if err != nil {
@@ -262,7 +262,7 @@ func updateSPTypeInSC(ctx context.Context, scName, dsType string) error {
 // function since vSphere CSI supports case insensitive parameters in the
 // StorageClass.
 func getStoragePolicyIDFromSC(sc *v1.StorageClass) string {
-	if sc.Provisioner != types.Name {
+	if sc.Provisioner != types.DriverName() {
 		return ""
 	}
 	// vSphere CSI supports case insensitive parameters.
@@ -41,6 +41,27 @@ const (
 	// queryVolumeLimit is the page size, which should be set in the cursor when syncer container need to
 	// query many volumes using QueryVolume API
 	queryVolumeLimit = int64(500)
+	// minQueryVolumeLimit and maxQueryVolumeLimit bound the page size of the
+	// QueryVolume calls when it adapts to the response times of vCenter.
+	minQueryVolumeLimit = int64(50)
+	maxQueryVolumeLimit = int64(5000)
+	// queryVolumeTargetLatency is the response time of a QueryVolume call above
+	// which the page size is reduced.
+	queryVolumeTargetLatency = 30 * time.Second
+	// maxQueryVolumeRetries is the number of times a failed QueryVolume call
+	// is retried with a reduced page size.
+	maxQueryVolumeRetries = 3
+	// fullSyncCheckpointFlushInterval is the number of volumes processed by
+	// full sync between two updates of its CnsFullSyncCheckpoint.
+	fullSyncCheckpointFlushInterval = 100
+	// fullSyncCheckpointMaxAge is the age above which the checkpoint of an
+	// interrupted full sync is discarded instead of resumed.
+	fullSyncCheckpointMaxAge = 2 * defaultFullSyncIntervalInMin * time.Minute
+	// default rate limits, in operations per second, of the create, update
+	// and delete phases of full sync.
+	defaultFullSyncCreateVolumeQPS = 5
+	defaultFullSyncUpdateVolumeQPS = 20
+	defaultFullSyncDeleteVolumeQPS = 5
 
 	// key for HealthStatus annotation on PVC
 	annVolumeHealth = "volumehealth.storage.kubernetes.io/health"
@@ -67,6 +88,20 @@ const (
 
 	// default interval for pv to backingdiskobjectid mapping
 	defaultPVtoBackingDiskObjectIdIntervalInMin = 10
+	// default interval for volume IO statistics collection.
+	defaultVolumeIOStatsIntervalInMin = 1
+	// default interval for purging expired archived volumes.
+	defaultArchivedVolumeGCIntervalInMin = 60
+	// default interval for mapping storage policies to StorageClasses.
+	defaultStoragePolicyMapperIntervalInMin = 10
+	// default interval for checking the accessibility of the PVs from the nodes.
+	defaultPVAccessibilityIntervalInMin = 5
+	// default interval for collecting stale VolumeAttachments.
+	defaultStaleVolumeAttachmentGCIntervalInMin = 5
+	// default interval for updating the node affinity of relocated PVs.
+	defaultRelocatedPVNodeAffinityIntervalInMin = 10
+	// default interval for reporting the usage of the driver.
+	defaultUsageReportIntervalInMin = 1440
 )
 
 var (
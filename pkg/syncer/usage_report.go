@@ -0,0 +1,221 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsusagereport"
+	usagereportv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsusagereport/v1alpha1"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
+)
+
+const (
+	// usageReportEndpointEnv is the environment variable holding the URL to
+	// which the usage reports are posted as JSON. The usage reports are only
+	// saved in the CnsUsageReport CR of the cluster when it is not set.
+	usageReportEndpointEnv = "USAGE_REPORT_ENDPOINT"
+	// usageReportPushTimeout is the timeout of posting a usage report to
+	// the usage report endpoint.
+	usageReportPushTimeout = 30 * time.Second
+)
+
+// volumeSizeRange is a capacity range counted in the VolumeSizes of the usage
+// reports.
+type volumeSizeRange struct {
+	name string
+	// maxBytes is the exclusive upper bound of the range, 0 for no bound.
+	maxBytes int64
+}
+
+// volumeSizeRanges are the capacity ranges counted in the VolumeSizes of the
+// usage reports, by increasing capacity.
+var volumeSizeRanges = []volumeSizeRange{
+	{name: "0-1Gi", maxBytes: 1 << 30},
+	{name: "1Gi-10Gi", maxBytes: 10 << 30},
+	{name: "10Gi-100Gi", maxBytes: 100 << 30},
+	{name: "100Gi-1Ti", maxBytes: 1 << 40},
+	{name: "1Ti+"},
+}
+
+// usageReportService saves the usage reports in the CnsUsageReport CR.
+var usageReportService cnsusagereport.UsageReportService
+
+// getUsageReportIntervalInMin returns the interval at which the usage of the
+// driver is reported. If environment variable
+// USAGE_REPORT_INTERVAL_MINUTES is set and valid, return the interval value
+// read from environment variable.
+// Otherwise, use the default value 1440 minutes.
+func getUsageReportIntervalInMin(ctx context.Context) int {
+	log := logger.GetLogger(ctx)
+	usageReportIntervalInMin := defaultUsageReportIntervalInMin
+	if v := os.Getenv("USAGE_REPORT_INTERVAL_MINUTES"); v != "" {
+		if value, err := strconv.Atoi(v); err == nil && value > 0 {
+			usageReportIntervalInMin = value
+			log.Infof("UsageReport: interval is set to %d minutes", usageReportIntervalInMin)
+		} else {
+			log.Warnf("UsageReport: interval set in env variable USAGE_REPORT_INTERVAL_MINUTES %s "+
+				"is invalid, will use the default interval", v)
+		}
+	}
+	return usageReportIntervalInMin
+}
+
+// csiReportUsage aggregates the anonymized usage counts of the driver in the
+// cluster, saves them in the CnsUsageReport CR and posts them to the usage
+// report endpoint if one is configured. The reports only hold counts and the
+// names of the enabled features, never names or identifiers of volumes,
+// workloads or infrastructure.
+func csiReportUsage(ctx context.Context, metadataSyncer *metadataSyncInformer) {
+	log := logger.GetLogger(ctx)
+	pvs, err := metadataSyncer.pvLister.List(labels.Everything())
+	if err != nil {
+		log.Errorf("csiReportUsage: failed to list PVs. Err: %v", err)
+		return
+	}
+	var enabledFeatures []string
+	for feature := range common.KnownFeatureStates {
+		if metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, feature) {
+			enabledFeatures = append(enabledFeatures, feature)
+		}
+	}
+	spec := buildUsageReport(pvs, countSnapshots(ctx), enabledFeatures)
+
+	if usageReportService == nil {
+		service, err := cnsusagereport.InitUsageReportService(ctx)
+		if err != nil {
+			log.Errorf("csiReportUsage: failed to initialize the usage report service. Err: %v", err)
+			return
+		}
+		usageReportService = service
+	}
+	if err := usageReportService.SaveUsageReport(ctx, spec); err != nil {
+		log.Errorf("csiReportUsage: failed to save the usage report. Err: %v", err)
+	}
+	if endpoint := os.Getenv(usageReportEndpointEnv); endpoint != "" {
+		if err := pushUsageReport(ctx, endpoint, spec); err != nil {
+			log.Warnf("csiReportUsage: failed to post the usage report. Err: %v", err)
+		}
+	}
+}
+
+// buildUsageReport returns the usage report of the given PVs, of which only
+// the ones of the driver are counted, and of the given number of snapshots
+// and enabled features.
+func buildUsageReport(pvs []*v1.PersistentVolume, snapshots int64,
+	enabledFeatures []string) usagereportv1alpha1.CnsUsageReportSpec {
+	spec := usagereportv1alpha1.CnsUsageReportSpec{
+		ReportTime:  metav1.Now(),
+		VolumeSizes: make(map[string]int64),
+		Snapshots:   snapshots,
+	}
+	var totalCapacity int64
+	for _, pv := range pvs {
+		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != csitypes.DriverName() {
+			continue
+		}
+		if common.IsFileVolumePV(pv) {
+			spec.FileVolumes++
+		} else {
+			spec.BlockVolumes++
+		}
+		capacity := pv.Spec.Capacity[v1.ResourceStorage]
+		totalCapacity += capacity.Value()
+		spec.VolumeSizes[volumeSizeRangeName(capacity)]++
+	}
+	spec.TotalCapacityGiB = totalCapacity >> 30
+	spec.EnabledFeatures = append([]string(nil), enabledFeatures...)
+	sort.Strings(spec.EnabledFeatures)
+	return spec
+}
+
+// volumeSizeRangeName returns the name of the capacity range of the given
+// volume capacity.
+func volumeSizeRangeName(capacity resource.Quantity) string {
+	for _, sizeRange := range volumeSizeRanges {
+		if sizeRange.maxBytes == 0 || capacity.Value() < sizeRange.maxBytes {
+			return sizeRange.name
+		}
+	}
+	return volumeSizeRanges[len(volumeSizeRanges)-1].name
+}
+
+// countSnapshots returns the number of VolumeSnapshotContents of the driver,
+// or 0 if they can't be listed, e.g. when the snapshot CRDs aren't installed.
+func countSnapshots(ctx context.Context) int64 {
+	log := logger.GetLogger(ctx)
+	snapshotterClient, err := k8s.NewSnapshotterClient(ctx)
+	if err != nil {
+		log.Warnf("countSnapshots: failed to create snapshotter client. Err: %v", err)
+		return 0
+	}
+	contents, err := snapshotterClient.SnapshotV1().VolumeSnapshotContents().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Warnf("countSnapshots: failed to list VolumeSnapshotContents. Err: %v", err)
+		return 0
+	}
+	var snapshots int64
+	for _, content := range contents.Items {
+		if content.Spec.Driver == csitypes.DriverName() {
+			snapshots++
+		}
+	}
+	return snapshots
+}
+
+// pushUsageReport posts the given usage report as JSON to the given
+// endpoint.
+func pushUsageReport(ctx context.Context, endpoint string, spec usagereportv1alpha1.CnsUsageReportSpec) error {
+	log := logger.GetLogger(ctx)
+	body, err := json.Marshal(spec)
+	if err != nil {
+		return logger.LogNewErrorf(log, "failed to marshal the usage report. Err: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, usageReportPushTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return logger.LogNewErrorf(log, "failed to create the request to the usage report endpoint. Err: %v",
+			err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return logger.LogNewErrorf(log, "failed to post the usage report. Err: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return logger.LogNewErrorf(log, "usage report endpoint replied with status %q", resp.Status)
+	}
+	log.Debugf("Posted the usage report to the usage report endpoint")
+	return nil
+}
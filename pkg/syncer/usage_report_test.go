@@ -0,0 +1,86 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+	usagereportv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsusagereport/v1alpha1"
+)
+
+func TestBuildUsageReport(t *testing.T) {
+	newPV := func(name, driver, diskType, capacity string) *v1.PersistentVolume {
+		return &v1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: v1.PersistentVolumeSpec{
+				Capacity: v1.ResourceList{v1.ResourceStorage: resource.MustParse(capacity)},
+				PersistentVolumeSource: v1.PersistentVolumeSource{
+					CSI: &v1.CSIPersistentVolumeSource{
+						Driver:           driver,
+						VolumeHandle:     "volume-" + name,
+						VolumeAttributes: map[string]string{common.AttributeDiskType: diskType},
+					},
+				},
+			},
+		}
+	}
+	pvs := []*v1.PersistentVolume{
+		newPV("block-small", csitypes.Name, common.DiskTypeBlockVolume, "512Mi"),
+		newPV("block-large", csitypes.Name, common.DiskTypeBlockVolume, "2Ti"),
+		newPV("file", csitypes.Name, common.DiskTypeFileVolume, "5Gi"),
+		newPV("other-driver", "other.csi.example.com", common.DiskTypeBlockVolume, "5Gi"),
+	}
+
+	spec := buildUsageReport(pvs, 3, []string{"b-feature", "a-feature"})
+	assert.Equal(t, int64(2), spec.BlockVolumes)
+	assert.Equal(t, int64(1), spec.FileVolumes)
+	assert.Equal(t, int64(2053), spec.TotalCapacityGiB)
+	assert.Equal(t, map[string]int64{"0-1Gi": 1, "1Gi-10Gi": 1, "1Ti+": 1}, spec.VolumeSizes)
+	assert.Equal(t, int64(3), spec.Snapshots)
+	assert.Equal(t, []string{"a-feature", "b-feature"}, spec.EnabledFeatures)
+}
+
+func TestPushUsageReport(t *testing.T) {
+	var received usagereportv1alpha1.CnsUsageReportSpec
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer server.Close()
+
+	spec := usagereportv1alpha1.CnsUsageReportSpec{BlockVolumes: 4, Snapshots: 2}
+	assert.NoError(t, pushUsageReport(context.Background(), server.URL, spec))
+	assert.Equal(t, int64(4), received.BlockVolumes)
+	assert.Equal(t, int64(2), received.Snapshots)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	assert.Error(t, pushUsageReport(context.Background(), failing.URL, spec))
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"google.golang.org/grpc/codes"
 	"k8s.io/apimachinery/pkg/api/meta"
@@ -22,6 +23,7 @@ import (
 	cnstypes "github.com/vmware/govmomi/cns/types"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
 	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
 
@@ -61,7 +63,7 @@ func getPVsInBoundAvailableOrReleased(ctx context.Context,
 		return nil, err
 	}
 	for _, pv := range allPVs {
-		if (pv.Spec.CSI != nil && pv.Spec.CSI.Driver == csitypes.Name) ||
+		if (pv.Spec.CSI != nil && pv.Spec.CSI.Driver == csitypes.DriverName()) ||
 			(metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.CSIMigration) && pv.Spec.VsphereVolume != nil &&
 				isValidvSphereVolume(ctx, pv)) {
 			log.Debugf("FullSync: pv %v is in state %v", pv.Name, pv.Status.Phase)
@@ -85,7 +87,7 @@ func getBoundPVs(ctx context.Context, metadataSyncer *metadataSyncInformer) ([]*
 		return nil, err
 	}
 	for _, pv := range allPVs {
-		if pv.Spec.CSI != nil && pv.Spec.CSI.Driver == csitypes.Name {
+		if pv.Spec.CSI != nil && pv.Spec.CSI.Driver == csitypes.DriverName() {
 			log.Debugf("getBoundPVs: pv %s with volumeHandle %s is in state %v",
 				pv.Name, pv.Spec.CSI.VolumeHandle, pv.Status.Phase)
 			if pv.Status.Phase == v1.VolumeBound {
@@ -166,7 +168,7 @@ func IsValidVolume(ctx context.Context, volume v1.Volume, pod *v1.Pod,
 			return false, nil, nil
 		}
 	} else {
-		if pv.Spec.CSI.Driver != csitypes.Name {
+		if pv.Spec.CSI.Driver != csitypes.DriverName() {
 			log.Debugf("Pod %s in namespace %s has a volume %s which is not provisioned by vSphere CSI driver",
 				pod.Name, pod.Namespace, pv.Name)
 			return false, nil, nil
@@ -175,6 +177,11 @@ func IsValidVolume(ctx context.Context, volume v1.Volume, pod *v1.Pod,
 	return true, pv, pvc
 }
 
+// queryVolumeBatchSize adapts the page size of the QueryVolume calls of full
+// sync to the response times of vCenter, across full sync cycles.
+var queryVolumeBatchSize = utils.NewAdaptiveBatchSize(queryVolumeLimit, minQueryVolumeLimit,
+	maxQueryVolumeLimit, queryVolumeTargetLatency)
+
 // fullSyncGetQueryResults returns list of CnsQueryResult retrieved using
 // queryFilter with offset and limit to query volumes using pagination
 // if volumeIds is empty, then all volumes from CNS will be retrieved by
@@ -193,15 +200,35 @@ func fullSyncGetQueryResults(ctx context.Context, volumeIds []cnstypes.CnsVolume
 	if clusterID != "" {
 		queryFilter.ContainerClusterIds = []string{clusterID}
 	}
+	adaptiveBatchSize := metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.AdaptiveQueryBatchSize)
 	var allQueryResults []*cnstypes.CnsQueryResult
+	retries := 0
 	for {
+		if adaptiveBatchSize {
+			queryFilter.Cursor.Limit = queryVolumeBatchSize.Size()
+		}
 		log.Debugf("Query volumes with offset: %v and limit: %v", queryFilter.Cursor.Offset, queryFilter.Cursor.Limit)
+		start := time.Now()
 		queryResult, err := utils.QueryVolumeUtil(ctx, volumeManager, queryFilter, nil,
 			metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.AsyncQueryVolume))
+		if adaptiveBatchSize {
+			latency := time.Since(start)
+			if queryVolumeBatchSize.Observe(latency, err) {
+				log.Infof("QueryVolume with limit %v took %v, using limit %v for the next queries",
+					queryFilter.Cursor.Limit, latency, queryVolumeBatchSize.Size())
+			}
+			if err != nil && retries < maxQueryVolumeRetries {
+				retries++
+				log.Warnf("QueryVolume with offset %v and limit %v failed, retrying with limit %v. Err: %v",
+					queryFilter.Cursor.Offset, queryFilter.Cursor.Limit, queryVolumeBatchSize.Size(), err)
+				continue
+			}
+		}
 		if err != nil {
 			return nil, logger.LogNewErrorCodef(log, codes.Internal,
 				"queryVolumeUtil failed with err=%+v", err.Error())
 		}
+		retries = 0
 		if queryResult == nil {
 			log.Info("Observed empty queryResult")
 			break
@@ -212,7 +239,8 @@ func fullSyncGetQueryResults(ctx context.Context, volumeIds []cnstypes.CnsVolume
 			log.Info("Metadata retrieved for all requested volumes")
 			break
 		}
-		queryFilter.Cursor = &queryResult.Cursor
+		cursor := queryResult.Cursor
+		queryFilter.Cursor = &cursor
 	}
 	return allQueryResults, nil
 }
@@ -256,18 +284,18 @@ func isValidvSphereVolumeClaim(ctx context.Context, pvcMetadata metav1.ObjectMet
 	log := logger.GetLogger(ctx)
 	// Checking if the migrated-to annotation is found in the PVC metadata.
 	if annotation, annMigratedToFound := pvcMetadata.Annotations[common.AnnMigratedTo]; annMigratedToFound {
-		if annotation == csitypes.Name &&
+		if annotation == csitypes.DriverName() &&
 			(pvcMetadata.Annotations[common.AnnBetaStorageProvisioner] == common.InTreePluginName ||
 				pvcMetadata.Annotations[common.AnnStorageProvisioner] == common.InTreePluginName) {
 			log.Debugf("%v annotation found with value %q for PVC: %q",
-				common.AnnMigratedTo, csitypes.Name, pvcMetadata.Name)
+				common.AnnMigratedTo, csitypes.DriverName(), pvcMetadata.Name)
 			return true
 		}
 	} else { // Checking if the PVC was provisioned by CSI.
-		if pvcMetadata.Annotations[common.AnnBetaStorageProvisioner] == csitypes.Name ||
-			pvcMetadata.Annotations[common.AnnStorageProvisioner] == csitypes.Name {
+		if pvcMetadata.Annotations[common.AnnBetaStorageProvisioner] == csitypes.DriverName() ||
+			pvcMetadata.Annotations[common.AnnStorageProvisioner] == csitypes.DriverName() {
 			log.Debugf("%v or %v annotation found with value %q for PVC: %q",
-				common.AnnBetaStorageProvisioner, common.AnnStorageProvisioner, csitypes.Name, pvcMetadata.Name)
+				common.AnnBetaStorageProvisioner, common.AnnStorageProvisioner, csitypes.DriverName(), pvcMetadata.Name)
 			return true
 		}
 	}
@@ -291,36 +319,28 @@ func isValidvSphereVolume(ctx context.Context, pv *v1.PersistentVolume) bool {
 	}
 	// Check if the migrated-to annotation is found on the PV.
 	if annotation, annMigratedToFound := pv.ObjectMeta.Annotations[common.AnnMigratedTo]; annMigratedToFound {
-		if annotation == csitypes.Name &&
+		if annotation == csitypes.DriverName() &&
 			pv.ObjectMeta.Annotations[common.AnnDynamicallyProvisioned] == common.InTreePluginName {
 			log.Debugf("%v annotation found with value %q for PV: %q",
-				common.AnnMigratedTo, csitypes.Name, pv.Name)
+				common.AnnMigratedTo, csitypes.DriverName(), pv.Name)
 			return true
 		}
 	}
-	if pv.ObjectMeta.Annotations[common.AnnDynamicallyProvisioned] == csitypes.Name {
+	if pv.ObjectMeta.Annotations[common.AnnDynamicallyProvisioned] == csitypes.DriverName() {
 		log.Debugf("%v annotation found with value %q for PV: %q",
-			common.AnnDynamicallyProvisioned, csitypes.Name, pv.Name)
+			common.AnnDynamicallyProvisioned, csitypes.DriverName(), pv.Name)
 		return true
 	}
 	return false
 }
 
-// IsMultiAttachAllowed helps check accessModes on the PV and return true if
-// volume can be attached to multiple nodes.
+// IsMultiAttachAllowed returns true if the volume of the PV is a file volume,
+// which can be attached to multiple nodes.
 func IsMultiAttachAllowed(pv *v1.PersistentVolume) bool {
 	if pv == nil {
 		return false
 	}
-	if len(pv.Spec.AccessModes) == 0 {
-		return false
-	}
-	for _, accessMode := range pv.Spec.AccessModes {
-		if accessMode == v1.ReadWriteMany || accessMode == v1.ReadOnlyMany {
-			return true
-		}
-	}
-	return false
+	return common.IsFileVolumePV(pv)
 }
 
 // initVolumeMigrationService is a helper method to initialize
@@ -693,6 +713,13 @@ func createVolumeOnMultiVc(ctx context.Context, pv *v1.PersistentVolume,
 
 func generateEventOnPv(ctx context.Context, pv *v1.PersistentVolume,
 	eventType string, failureReason string, errorMsg string) {
+	generateEventOnObject(ctx, pv, eventType, failureReason, errorMsg)
+}
+
+// generateEventOnObject records an event with the given type, reason and
+// message on the given kubernetes object.
+func generateEventOnObject(ctx context.Context, object runtime.Object,
+	eventType string, reason string, message string) {
 	log := logger.GetLogger(ctx)
 
 	eventBroadcaster := record.NewBroadcaster()
@@ -704,7 +731,7 @@ func generateEventOnPv(ctx context.Context, pv *v1.PersistentVolume,
 
 	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: k8sClient.CoreV1().Events("")})
 	eventRecorder := eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: syncerComponent})
-	eventRecorder.Event(pv, eventType, failureReason, errorMsg)
+	eventRecorder.Event(object, eventType, reason, message)
 }
 
 func createCnsVolume(ctx context.Context, pv *v1.PersistentVolume,
@@ -11,7 +11,12 @@ import (
 	cnstypes "github.com/vmware/govmomi/cns/types"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
 
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/unittestcommon"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/syncer/k8scloudoperator"
 )
 
@@ -476,3 +481,79 @@ func TestHasClusterDistributionSet(t *testing.T) {
 		})
 	}
 }
+
+// newFullSyncMetadataSyncer returns a metadata syncer listing bound PVs of the
+// given drivers, keyed by PV name.
+func newFullSyncMetadataSyncer(t *testing.T, pvDrivers map[string]string) *metadataSyncInformer {
+	pvIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for name, driver := range pvDrivers {
+		err := pvIndexer.Add(&corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: corev1.PersistentVolumeSpec{
+				PersistentVolumeSource: corev1.PersistentVolumeSource{
+					CSI: &corev1.CSIPersistentVolumeSource{Driver: driver, VolumeHandle: "volume-" + name},
+				},
+			},
+			Status: corev1.PersistentVolumeStatus{Phase: corev1.VolumeBound},
+		})
+		if err != nil {
+			t.Fatalf("failed to add PV %q to the indexer: %v", name, err)
+		}
+	}
+	coCommonInterface, err := unittestcommon.GetFakeContainerOrchestratorInterface(common.Kubernetes)
+	if err != nil {
+		t.Fatalf("failed to create the fake container orchestrator: %v", err)
+	}
+	return &metadataSyncInformer{
+		pvLister:          corelisters.NewPersistentVolumeLister(pvIndexer),
+		coCommonInterface: coCommonInterface,
+	}
+}
+
+func TestGetPVsInBoundAvailableOrReleasedDriverName(t *testing.T) {
+	ctx := context.Background()
+	renamedDriver := "csi.example.com"
+	t.Setenv(csitypes.EnvVarDriverName, renamedDriver)
+	metadataSyncer := newFullSyncMetadataSyncer(t, map[string]string{
+		"pv-renamed": renamedDriver,
+		"pv-default": csitypes.Name,
+	})
+
+	pvs, err := getPVsInBoundAvailableOrReleased(ctx, metadataSyncer)
+	assert.NoError(t, err)
+	// Only the PVs of the renamed driver are synced by its instance.
+	if assert.Len(t, pvs, 1) {
+		assert.Equal(t, "pv-renamed", pvs[0].Name)
+	}
+}
+
+func TestGetPVsInBoundAvailableOrReleasedSideBySide(t *testing.T) {
+	ctx := context.Background()
+	secondDriver := "csi-b.vsphere.vmware.com"
+	metadataSyncer := newFullSyncMetadataSyncer(t, map[string]string{
+		"pv-a-1": csitypes.Name,
+		"pv-a-2": csitypes.Name,
+		"pv-b-1": secondDriver,
+	})
+	// Each instance syncs its own PVs only, so that neither untags the
+	// volumes of the other one from CNS.
+	for _, test := range []struct {
+		name        string
+		driverName  string
+		expectedPVs []string
+	}{
+		{"default instance", "", []string{"pv-a-1", "pv-a-2"}},
+		{"second instance", secondDriver, []string{"pv-b-1"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(csitypes.EnvVarDriverName, test.driverName)
+			pvs, err := getPVsInBoundAvailableOrReleased(ctx, metadataSyncer)
+			assert.NoError(t, err)
+			var pvNames []string
+			for _, pv := range pvs {
+				pvNames = append(pvNames, pv.Name)
+			}
+			assert.ElementsMatch(t, test.expectedPVs, pvNames)
+		})
+	}
+}
@@ -0,0 +1,254 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vmware/govmomi/event"
+	vim25types "github.com/vmware/govmomi/vim25/types"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	clientset "k8s.io/client-go/kubernetes"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
+)
+
+const (
+	// vCenterEventTypeIDPrefix prefixes the reason of a Kubernetes event to
+	// form the type ID of the event posted to vCenter.
+	vCenterEventTypeIDPrefix = "com.vmware.cns.csi."
+	// vCenterEventObjectType is the object type of the events posted to
+	// vCenter for a CNS volume.
+	vCenterEventObjectType = "CnsVolume"
+	// vCenterEventReportInterval is the minimum interval between two events
+	// posted to vCenter for the same reason and Kubernetes object, so that a
+	// recurring failure doesn't flood the vCenter events.
+	vCenterEventReportInterval = time.Hour
+	// eventReasonProvisioningFailed is the reason of the event recorded by
+	// the external-provisioner on a PVC whose volume failed to be created.
+	eventReasonProvisioningFailed = "ProvisioningFailed"
+	// eventReasonFailedAttachVolume is the reason of the event recorded by
+	// the attach detach controller on a pod whose volume failed to be
+	// attached in time.
+	eventReasonFailedAttachVolume = "FailedAttachVolume"
+)
+
+// attachFailedVolumeNameRegex extracts the name of the PV from the message
+// of a FailedAttachVolume event.
+var attachFailedVolumeNameRegex = regexp.MustCompile(`for volume "([^"]+)"`)
+
+// vCenterEventReporter posts the significant Kubernetes warning events of
+// the volumes of the driver to vCenter, so that VI admins see the problems
+// of the cluster in their console.
+type vCenterEventReporter struct {
+	metadataSyncer *metadataSyncInformer
+	// lock protects lastReported.
+	lock sync.Mutex
+	// lastReported holds the time at which an event was last posted to
+	// vCenter for a reason and Kubernetes object.
+	lastReported map[string]time.Time
+}
+
+// startVCenterEventReporter watches the Kubernetes warning events and posts
+// those of the volumes of the driver to vCenter.
+func startVCenterEventReporter(ctx context.Context, k8sClient clientset.Interface,
+	metadataSyncer *metadataSyncInformer) error {
+	log := logger.GetLogger(ctx)
+	reporter := &vCenterEventReporter{
+		metadataSyncer: metadataSyncer,
+		lastReported:   make(map[string]time.Time),
+	}
+	informerFactory := informers.NewSharedInformerFactoryWithOptions(k8sClient, 0,
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.FieldSelector = fields.OneTermEqualSelector("type", v1.EventTypeWarning).String()
+		}))
+	eventInformer := informerFactory.Core().V1().Events().Informer()
+	// Only new events are reported. The updates of an event merely count its
+	// recurrences.
+	_, err := eventInformer.AddEventHandler(k8s.NewEventHandler("vcenter-event-reporter",
+		func(obj interface{}) {
+			kubeEvent, ok := obj.(*v1.Event)
+			if !ok {
+				return
+			}
+			ctx, _ := logger.GetNewContextWithLogger()
+			reporter.report(ctx, kubeEvent)
+		}, nil, nil))
+	if err != nil {
+		return logger.LogNewErrorf(log, "failed to add event handler to the events informer. Err: %v", err)
+	}
+	stopCh := make(chan struct{})
+	informerFactory.Start(stopCh)
+	log.Info("vCenter event reporter started")
+	return nil
+}
+
+// report posts the given Kubernetes event to vCenter if it is a significant
+// event of a volume of the driver which wasn't reported recently.
+func (reporter *vCenterEventReporter) report(ctx context.Context, kubeEvent *v1.Event) {
+	log := logger.GetLogger(ctx)
+	// Events older than the report interval were recorded before the
+	// reporter started and are not reported again.
+	if time.Since(getKubeEventTime(kubeEvent)) > vCenterEventReportInterval {
+		return
+	}
+	volumeID, ok := reporter.getEventVolumeID(ctx, kubeEvent)
+	if !ok {
+		return
+	}
+	key := kubeEvent.Reason + "/" + kubeEvent.InvolvedObject.Namespace + "/" + kubeEvent.InvolvedObject.Name
+	reporter.lock.Lock()
+	if lastReported, found := reporter.lastReported[key]; found &&
+		time.Since(lastReported) < vCenterEventReportInterval {
+		reporter.lock.Unlock()
+		return
+	}
+	reporter.lastReported[key] = time.Now()
+	for k, lastReported := range reporter.lastReported {
+		if time.Since(lastReported) >= vCenterEventReportInterval {
+			delete(reporter.lastReported, k)
+		}
+	}
+	reporter.lock.Unlock()
+
+	vcEvent := reporter.toVCenterEvent(kubeEvent, volumeID)
+	for _, vcHost := range reporter.getVCenterHosts(ctx, volumeID) {
+		vc, err := cnsvsphere.GetVirtualCenterInstanceForVCenterHost(ctx, vcHost, true)
+		if err != nil {
+			log.Warnf("vCenter event reporter: failed to get vCenter %q to post event %q of %s %s/%s. Err: %v",
+				vcHost, kubeEvent.Reason, kubeEvent.InvolvedObject.Kind, kubeEvent.InvolvedObject.Namespace,
+				kubeEvent.InvolvedObject.Name, err)
+			continue
+		}
+		err = event.NewManager(vc.Client.Client).PostEvent(ctx, vcEvent)
+		if err != nil {
+			log.Warnf("vCenter event reporter: failed to post event %q of %s %s/%s to vCenter %q. Err: %v",
+				kubeEvent.Reason, kubeEvent.InvolvedObject.Kind, kubeEvent.InvolvedObject.Namespace,
+				kubeEvent.InvolvedObject.Name, vcHost, err)
+			continue
+		}
+		log.Infof("vCenter event reporter: posted event %q of %s %s/%s to vCenter %q", kubeEvent.Reason,
+			kubeEvent.InvolvedObject.Kind, kubeEvent.InvolvedObject.Namespace, kubeEvent.InvolvedObject.Name,
+			vcHost)
+	}
+}
+
+// getEventVolumeID returns whether the given Kubernetes event is a
+// significant event of a volume of the driver, along with the ID of the CNS
+// volume it pertains to, which is empty if the volume doesn't exist yet.
+func (reporter *vCenterEventReporter) getEventVolumeID(ctx context.Context, kubeEvent *v1.Event) (string, bool) {
+	log := logger.GetLogger(ctx)
+	switch kubeEvent.Reason {
+	case eventReasonProvisioningFailed:
+		// The external-provisioner records its events with the name of the
+		// driver as component.
+		return "", strings.HasPrefix(kubeEvent.Source.Component, csitypes.DriverName()) ||
+			strings.HasPrefix(kubeEvent.ReportingController, csitypes.DriverName())
+	case eventReasonFailedAttachVolume:
+		match := attachFailedVolumeNameRegex.FindStringSubmatch(kubeEvent.Message)
+		if match == nil {
+			return "", false
+		}
+		pv, err := reporter.metadataSyncer.pvLister.Get(match[1])
+		if err != nil {
+			log.Debugf("vCenter event reporter: failed to get PV %q of event %q. Err: %v",
+				match[1], kubeEvent.Reason, err)
+			return "", false
+		}
+		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != csitypes.DriverName() {
+			return "", false
+		}
+		return pv.Spec.CSI.VolumeHandle, true
+	case common.EventReasonPolicyNoncompliant:
+		volumeID, ok := kubeEvent.Annotations[common.AnnEventVolumeID]
+		return volumeID, ok
+	}
+	return "", false
+}
+
+// getVCenterHosts returns the vCenters to which an event of the given volume
+// is posted, i.e. the vCenter of the volume, or all the vCenters if it is not
+// known.
+func (reporter *vCenterEventReporter) getVCenterHosts(ctx context.Context, volumeID string) []string {
+	log := logger.GetLogger(ctx)
+	if !isMultiVCenterFssEnabled || len(reporter.metadataSyncer.configInfo.Cfg.VirtualCenter) <= 1 {
+		return []string{reporter.metadataSyncer.host}
+	}
+	if volumeID != "" && volumeInfoService != nil {
+		vcHost, err := volumeInfoService.GetvCenterForVolumeID(ctx, volumeID)
+		if err == nil {
+			return []string{vcHost}
+		}
+		log.Debugf("vCenter event reporter: failed to get the vCenter of volume %q. Err: %v", volumeID, err)
+	}
+	vcHosts := make([]string, 0, len(reporter.metadataSyncer.configInfo.Cfg.VirtualCenter))
+	for vcHost := range reporter.metadataSyncer.configInfo.Cfg.VirtualCenter {
+		vcHosts = append(vcHosts, vcHost)
+	}
+	return vcHosts
+}
+
+// toVCenterEvent converts the given Kubernetes event of the given volume to
+// a vCenter event.
+func (reporter *vCenterEventReporter) toVCenterEvent(kubeEvent *v1.Event, volumeID string) *vim25types.EventEx {
+	object := kubeEvent.InvolvedObject
+	objectName := object.Name
+	if object.Namespace != "" {
+		objectName = object.Namespace + "/" + object.Name
+	}
+	vcEvent := &vim25types.EventEx{
+		EventTypeId: vCenterEventTypeIDPrefix + kubeEvent.Reason,
+		Severity:    string(vim25types.EventEventSeverityWarning),
+		Message: fmt.Sprintf("Kubernetes cluster %q: %s %s: %s",
+			reporter.metadataSyncer.configInfo.Cfg.Global.ClusterID, object.Kind, objectName, kubeEvent.Message),
+		Arguments: []vim25types.KeyAnyValue{
+			{Key: "clusterId", Value: reporter.metadataSyncer.configInfo.Cfg.Global.ClusterID},
+			{Key: "kind", Value: object.Kind},
+			{Key: "namespace", Value: object.Namespace},
+			{Key: "name", Value: object.Name},
+		},
+	}
+	vcEvent.CreatedTime = getKubeEventTime(kubeEvent)
+	if volumeID != "" {
+		vcEvent.ObjectId = volumeID
+		vcEvent.ObjectType = vCenterEventObjectType
+		vcEvent.ObjectName = objectName
+	}
+	return vcEvent
+}
+
+// getKubeEventTime returns the time at which the given Kubernetes event last
+// occurred. The events recorded with the events.k8s.io API only set their
+// event time.
+func getKubeEventTime(kubeEvent *v1.Event) time.Time {
+	if !kubeEvent.LastTimestamp.IsZero() {
+		return kubeEvent.LastTimestamp.Time
+	}
+	return kubeEvent.EventTime.Time
+}
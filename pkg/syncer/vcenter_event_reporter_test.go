@@ -0,0 +1,132 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	cnsconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+)
+
+func TestGetEventVolumeID(t *testing.T) {
+	ctx := context.Background()
+	pvIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	err := pvIndexer.Add(&v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pvc-1"},
+		Spec: v1.PersistentVolumeSpec{
+			PersistentVolumeSource: v1.PersistentVolumeSource{
+				CSI: &v1.CSIPersistentVolumeSource{Driver: csitypes.DriverName(), VolumeHandle: "fcd-1"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to add PV to the indexer: %v", err)
+	}
+	reporter := &vCenterEventReporter{
+		metadataSyncer: &metadataSyncInformer{pvLister: corelisters.NewPersistentVolumeLister(pvIndexer)},
+	}
+	tests := []struct {
+		name             string
+		kubeEvent        *v1.Event
+		expectedVolumeID string
+		expectedReported bool
+	}{
+		{
+			name: "provisioning failed by the driver",
+			kubeEvent: &v1.Event{
+				Reason: eventReasonProvisioningFailed,
+				Source: v1.EventSource{Component: csitypes.DriverName() + "_controller-0_uid"},
+			},
+			expectedReported: true,
+		},
+		{
+			name: "provisioning failed by another driver",
+			kubeEvent: &v1.Event{
+				Reason: eventReasonProvisioningFailed,
+				Source: v1.EventSource{Component: "ebs.csi.aws.com_controller-0_uid"},
+			},
+		},
+		{
+			name: "attach failed for a volume of the driver",
+			kubeEvent: &v1.Event{
+				Reason:  eventReasonFailedAttachVolume,
+				Message: `AttachVolume.Attach failed for volume "pvc-1" : timed out waiting for external-attacher`,
+			},
+			expectedVolumeID: "fcd-1",
+			expectedReported: true,
+		},
+		{
+			name: "attach failed for an unknown volume",
+			kubeEvent: &v1.Event{
+				Reason:  eventReasonFailedAttachVolume,
+				Message: `AttachVolume.Attach failed for volume "pvc-2" : timed out waiting for external-attacher`,
+			},
+		},
+		{
+			name: "policy noncompliant",
+			kubeEvent: &v1.Event{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{common.AnnEventVolumeID: "fcd-3"}},
+				Reason:     common.EventReasonPolicyNoncompliant,
+			},
+			expectedVolumeID: "fcd-3",
+			expectedReported: true,
+		},
+		{
+			name:      "other event",
+			kubeEvent: &v1.Event{Reason: "FailedMount"},
+		},
+	}
+	for _, test := range tests {
+		volumeID, reported := reporter.getEventVolumeID(ctx, test.kubeEvent)
+		if volumeID != test.expectedVolumeID || reported != test.expectedReported {
+			t.Errorf("%s: expected (%q, %t), got (%q, %t)", test.name, test.expectedVolumeID,
+				test.expectedReported, volumeID, reported)
+		}
+	}
+}
+
+func TestToVCenterEvent(t *testing.T) {
+	cfg := &cnsconfig.Config{}
+	cfg.Global.ClusterID = "cluster-1"
+	reporter := &vCenterEventReporter{
+		metadataSyncer: &metadataSyncInformer{configInfo: &cnsconfig.ConfigurationInfo{Cfg: cfg}},
+	}
+	kubeEvent := &v1.Event{
+		InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: "ns", Name: "pod-1"},
+		Reason:         eventReasonFailedAttachVolume,
+		Message:        "attach timed out",
+	}
+	vcEvent := reporter.toVCenterEvent(kubeEvent, "fcd-1")
+	if vcEvent.EventTypeId != vCenterEventTypeIDPrefix+eventReasonFailedAttachVolume {
+		t.Errorf("unexpected event type ID %q", vcEvent.EventTypeId)
+	}
+	if vcEvent.ObjectId != "fcd-1" || vcEvent.ObjectName != "ns/pod-1" {
+		t.Errorf("unexpected event object %q %q", vcEvent.ObjectId, vcEvent.ObjectName)
+	}
+	expectedMessage := `Kubernetes cluster "cluster-1": Pod ns/pod-1: attach timed out`
+	if vcEvent.Message != expectedMessage {
+		t.Errorf("expected message %q, got %q", expectedMessage, vcEvent.Message)
+	}
+}
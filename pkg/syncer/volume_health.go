@@ -89,6 +89,7 @@ func csiGetVolumeHealthStatus(ctx context.Context, k8sclient clientset.Interface
 
 	accessibleVolumeCount := 0
 	inaccessibleVolumeCount := 0
+	var fakeAttachedVolumes []fakeAttachedVolume
 	for volID, pvc := range volumeHandleToPvcMap {
 		var volHealthStatusAnn string
 		if volHealthStatus, ok := volumeIdToHealthStatusMap[volID]; ok {
@@ -117,6 +118,10 @@ func csiGetVolumeHealthStatus(ctx context.Context, k8sclient clientset.Interface
 			volHealthStatusAnn = common.VolHealthStatusInaccessible
 			updateVolumeHealthStatus(ctx, k8sclient, pvc, volHealthStatusAnn)
 		}
+		if pvc.Annotations[common.AnnFakeAttached] == "yes" {
+			fakeAttachedVolumes = append(fakeAttachedVolumes,
+				fakeAttachedVolume{volumeID: volID, pvc: pvc, health: volHealthStatusAnn})
+		}
 		switch volHealthStatusAnn {
 		case common.VolHealthStatusAccessible:
 			accessibleVolumeCount += 1
@@ -128,6 +133,10 @@ func csiGetVolumeHealthStatus(ctx context.Context, k8sclient clientset.Interface
 		prometheus.PrometheusAccessibleVolumes).Set(float64(accessibleVolumeCount))
 	prometheus.VolumeHealthGaugeVec.WithLabelValues(
 		prometheus.PrometheusInaccessibleVolumes).Set(float64(inaccessibleVolumeCount))
+	if len(fakeAttachedVolumes) > 0 &&
+		metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.FakeAttachRecovery) {
+		recoverFakeAttachedVolumes(ctx, k8sclient, fakeAttachedVolumes)
+	}
 
 	log.Infof("GetVolumeHealthStatus: end")
 }
@@ -35,6 +35,7 @@ import (
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
 	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
 )
 
 // VolumeHealthReconciler is the interface for volume health reconciler.
@@ -157,20 +158,14 @@ func NewVolumeHealthReconciler(
 		},
 	}
 
-	_, err := svcPVCInformer.Informer().AddEventHandlerWithResyncPeriod(cache.ResourceEventHandlerFuncs{
-		AddFunc:    rc.svcAddPVC,
-		UpdateFunc: rc.svcUpdatePVC,
-		DeleteFunc: rc.svcAddPVC,
-	}, resyncPeriod)
+	_, err := svcPVCInformer.Informer().AddEventHandlerWithResyncPeriod(k8s.NewEventHandler(
+		"supervisor-pvc", rc.svcAddPVC, rc.svcUpdatePVC, rc.svcAddPVC), resyncPeriod)
 	if err != nil {
 		return nil, logger.LogNewErrorf(log, "failed to add event handler on PVC informer. Error: %v", err)
 	}
 
-	_, err = tkgPVInformer.Informer().AddEventHandlerWithResyncPeriod(cache.ResourceEventHandlerFuncs{
-		AddFunc:    nil,
-		UpdateFunc: rc.tkgUpdatePV,
-		DeleteFunc: rc.tkgDeletePV,
-	}, resyncPeriod)
+	_, err = tkgPVInformer.Informer().AddEventHandlerWithResyncPeriod(k8s.NewEventHandler(
+		"guest-pv", nil, rc.tkgUpdatePV, rc.tkgDeletePV), resyncPeriod)
 	if err != nil {
 		return nil, logger.LogNewErrorf(log, "failed to add event handler on PV informer. Error: %v", err)
 	}
@@ -193,7 +188,7 @@ func NewVolumeHealthReconciler(
 		return nil, err
 	}
 	for _, pv := range pvs.Items {
-		if pv.Spec.CSI != nil && pv.Spec.CSI.Driver == csitypes.Name && pv.Status.Phase == v1.VolumeBound {
+		if pv.Spec.CSI != nil && pv.Spec.CSI.Driver == csitypes.DriverName() && pv.Status.Phase == v1.VolumeBound {
 			// Add to volumeHandleToPVs.
 			rc.volumeHandleToPVs.add(pv.Spec.CSI.VolumeHandle, pv.Name)
 		}
@@ -219,7 +214,7 @@ func (rc *volumeHealthReconciler) tkgUpdatePV(oldObj, newObj interface{}) {
 	if !ok {
 		return
 	}
-	if newPv.Spec.CSI != nil && newPv.Spec.CSI.Driver == csitypes.Name &&
+	if newPv.Spec.CSI != nil && newPv.Spec.CSI.Driver == csitypes.DriverName() &&
 		oldPv.Status.Phase != v1.VolumeBound && newPv.Status.Phase == v1.VolumeBound {
 		// Add to volumeHandleToPVs.
 		rc.volumeHandleToPVs.add(newPv.Spec.CSI.VolumeHandle, newPv.Name)
@@ -237,7 +232,7 @@ func (rc *volumeHealthReconciler) tkgDeletePV(obj interface{}) {
 	if !ok {
 		return
 	}
-	if pv.Spec.CSI != nil && pv.Spec.CSI.Driver == csitypes.Name {
+	if pv.Spec.CSI != nil && pv.Spec.CSI.Driver == csitypes.DriverName() {
 		// Remove from volumeHandleToPVs.
 		rc.volumeHandleToPVs.remove(pv.Spec.CSI.VolumeHandle, pv.Name)
 	}
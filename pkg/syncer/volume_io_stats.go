@@ -0,0 +1,289 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/performance"
+	"github.com/vmware/govmomi/vim25/types"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/node"
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+)
+
+const (
+	// Virtual disk performance counters collected for each attached volume.
+	perfCounterDiskReadIOPS        = "virtualDisk.numberReadAveraged.average"
+	perfCounterDiskWriteIOPS       = "virtualDisk.numberWriteAveraged.average"
+	perfCounterDiskReadLatency     = "virtualDisk.totalReadLatency.average"
+	perfCounterDiskWriteLatency    = "virtualDisk.totalWriteLatency.average"
+	perfCounterDiskReadThroughput  = "virtualDisk.read.average"
+	perfCounterDiskWriteThroughput = "virtualDisk.write.average"
+
+	// realtimePerfInterval is the sampling interval in seconds of the
+	// realtime performance statistics for a VM.
+	realtimePerfInterval = 20
+)
+
+var volumeIOStatsCounters = []string{
+	perfCounterDiskReadIOPS,
+	perfCounterDiskWriteIOPS,
+	perfCounterDiskReadLatency,
+	perfCounterDiskWriteLatency,
+	perfCounterDiskReadThroughput,
+	perfCounterDiskWriteThroughput,
+}
+
+// reportedVolumeIOStats is the set of PVCs whose IO statistics were exported
+// by the last collection. It is only accessed by the collection goroutine.
+var reportedVolumeIOStats = make(map[volumeIOStatsLabels]struct{})
+
+// volumeIOStatsLabels are the PVC labels of the volume IO statistics.
+type volumeIOStatsLabels struct {
+	namespace string
+	name      string
+}
+
+// attachedVolume is a CSI volume attached to a node, along with the PVC it
+// is bound to.
+type attachedVolume struct {
+	volumeID string
+	pvc      *v1.PersistentVolumeClaim
+}
+
+// getVolumeIOStatsIntervalInMin returns the interval for volume IO statistics
+// collection. If environment variable VOLUME_IO_STATS_INTERVAL_MINUTES is set
+// and valid, return the interval value read from environment variable.
+// Otherwise, use the default value 1 minute.
+func getVolumeIOStatsIntervalInMin(ctx context.Context) int {
+	log := logger.GetLogger(ctx)
+	volumeIOStatsIntervalInMin := defaultVolumeIOStatsIntervalInMin
+	if v := os.Getenv("VOLUME_IO_STATS_INTERVAL_MINUTES"); v != "" {
+		if value, err := strconv.Atoi(v); err == nil && value > 0 {
+			volumeIOStatsIntervalInMin = value
+			log.Infof("VolumeIOStats: interval is set to %d minutes", volumeIOStatsIntervalInMin)
+		} else {
+			log.Warnf("VolumeIOStats: interval set in env variable VOLUME_IO_STATS_INTERVAL_MINUTES %s "+
+				"is invalid, will use the default interval", v)
+		}
+	}
+	return volumeIOStatsIntervalInMin
+}
+
+// csiCollectVolumeIOStats collects IOPS, latency and throughput of every
+// attached CSI block volume from the vCenter performance manager and exports
+// them as Prometheus metrics labeled by PVC namespace and name.
+func csiCollectVolumeIOStats(ctx context.Context, k8sClient clientset.Interface,
+	metadataSyncer *metadataSyncInformer) {
+	log := logger.GetLogger(ctx)
+	log.Debugf("csiCollectVolumeIOStats: start")
+	nodeVolumes, err := getAttachedVolumesByNode(ctx, k8sClient, metadataSyncer)
+	if err != nil {
+		log.Errorf("csiCollectVolumeIOStats: failed to get attached volumes. Err: %v", err)
+		return
+	}
+	// The gauges are updated in place, and only the series of the volumes which
+	// are no longer attached are deleted afterwards, so that a scrape during
+	// the collection still sees the series of the other volumes.
+	reported := make(map[volumeIOStatsLabels]struct{})
+	nodeManager := node.GetManager(ctx)
+	for nodeName, volumes := range nodeVolumes {
+		nodeVM, err := nodeManager.GetNodeVMByNameAndUpdateCache(ctx, nodeName)
+		if err == nil {
+			err = collectNodeVolumeIOStats(ctx, nodeVM, volumes, reported)
+		}
+		if err != nil {
+			log.Warnf("csiCollectVolumeIOStats: failed to collect IO stats for node %q. Err: %v", nodeName, err)
+			// The last statistics of the volumes of the node are kept until
+			// the next collection.
+			for _, volume := range volumes {
+				labels := volumeIOStatsLabels{namespace: volume.pvc.Namespace, name: volume.pvc.Name}
+				if _, ok := reportedVolumeIOStats[labels]; ok {
+					reported[labels] = struct{}{}
+				}
+			}
+		}
+	}
+	deleteStaleVolumeIOStats(reportedVolumeIOStats, reported)
+	reportedVolumeIOStats = reported
+	log.Debugf("csiCollectVolumeIOStats: end")
+}
+
+// deleteStaleVolumeIOStats deletes the IO statistics of the PVCs which were
+// previously reported but aren't reported anymore, e.g. as their volume got
+// detached or deleted.
+func deleteStaleVolumeIOStats(previous, current map[volumeIOStatsLabels]struct{}) {
+	for labels := range previous {
+		if _, ok := current[labels]; ok {
+			continue
+		}
+		for _, direction := range []string{prometheus.PrometheusReadDirection, prometheus.PrometheusWriteDirection} {
+			prometheus.VolumeIOPSGaugeVec.DeleteLabelValues(labels.namespace, labels.name, direction)
+			prometheus.VolumeLatencyGaugeVec.DeleteLabelValues(labels.namespace, labels.name, direction)
+			prometheus.VolumeThroughputGaugeVec.DeleteLabelValues(labels.namespace, labels.name, direction)
+		}
+	}
+}
+
+// getAttachedVolumesByNode returns the attached CSI volumes bound to PVCs,
+// grouped by node name.
+func getAttachedVolumesByNode(ctx context.Context, k8sClient clientset.Interface,
+	metadataSyncer *metadataSyncInformer) (map[string][]attachedVolume, error) {
+	log := logger.GetLogger(ctx)
+	vaList, err := k8sClient.StorageV1().VolumeAttachments().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	nodeVolumes := make(map[string][]attachedVolume)
+	for _, va := range vaList.Items {
+		if va.Spec.Attacher != csitypes.DriverName() || !va.Status.Attached ||
+			va.Spec.Source.PersistentVolumeName == nil {
+			continue
+		}
+		pv, err := metadataSyncer.pvLister.Get(*va.Spec.Source.PersistentVolumeName)
+		if err != nil {
+			log.Debugf("failed to get PV %q for VolumeAttachment %q. Err: %v",
+				*va.Spec.Source.PersistentVolumeName, va.Name, err)
+			continue
+		}
+		if pv.Spec.CSI == nil || pv.Spec.ClaimRef == nil {
+			continue
+		}
+		pvc, err := metadataSyncer.pvcLister.PersistentVolumeClaims(pv.Spec.ClaimRef.Namespace).Get(
+			pv.Spec.ClaimRef.Name)
+		if err != nil {
+			log.Debugf("failed to get PVC %s/%s for PV %q. Err: %v",
+				pv.Spec.ClaimRef.Namespace, pv.Spec.ClaimRef.Name, pv.Name, err)
+			continue
+		}
+		nodeVolumes[va.Spec.NodeName] = append(nodeVolumes[va.Spec.NodeName], attachedVolume{
+			volumeID: pv.Spec.CSI.VolumeHandle,
+			pvc:      pvc,
+		})
+	}
+	return nodeVolumes, nil
+}
+
+// collectNodeVolumeIOStats queries the realtime virtual disk counters of the
+// node VM and records them for each of the given volumes, adding the labels of
+// the recorded volumes to reported.
+func collectNodeVolumeIOStats(ctx context.Context, nodeVM *cnsvsphere.VirtualMachine,
+	volumes []attachedVolume, reported map[volumeIOStatsLabels]struct{}) error {
+	log := logger.GetLogger(ctx)
+	devices, err := nodeVM.Device(ctx)
+	if err != nil {
+		return err
+	}
+	volumeIDToInstance := getVolumeIDToDiskInstanceMap(devices)
+
+	perfManager := performance.NewManager(nodeVM.Client())
+	spec := types.PerfQuerySpec{
+		MaxSample:  1,
+		IntervalId: realtimePerfInterval,
+	}
+	sample, err := perfManager.SampleByName(ctx, spec, volumeIOStatsCounters,
+		[]types.ManagedObjectReference{nodeVM.Reference()})
+	if err != nil {
+		return err
+	}
+	result, err := perfManager.ToMetricSeries(ctx, sample)
+	if err != nil {
+		return err
+	}
+	// Latest value of each counter, keyed by disk instance and counter name.
+	instanceStats := make(map[string]map[string]float64)
+	for _, entityMetric := range result {
+		for _, series := range entityMetric.Value {
+			if len(series.Value) == 0 {
+				continue
+			}
+			if _, ok := instanceStats[series.Instance]; !ok {
+				instanceStats[series.Instance] = make(map[string]float64)
+			}
+			instanceStats[series.Instance][series.Name] = float64(series.Value[len(series.Value)-1])
+		}
+	}
+	for _, volume := range volumes {
+		instance, ok := volumeIDToInstance[volume.volumeID]
+		if !ok {
+			log.Debugf("volume %q is not found on node VM %v", volume.volumeID, nodeVM)
+			continue
+		}
+		stats, ok := instanceStats[instance]
+		if !ok {
+			continue
+		}
+		namespace, name := volume.pvc.Namespace, volume.pvc.Name
+		reported[volumeIOStatsLabels{namespace: namespace, name: name}] = struct{}{}
+		prometheus.VolumeIOPSGaugeVec.WithLabelValues(namespace, name,
+			prometheus.PrometheusReadDirection).Set(stats[perfCounterDiskReadIOPS])
+		prometheus.VolumeIOPSGaugeVec.WithLabelValues(namespace, name,
+			prometheus.PrometheusWriteDirection).Set(stats[perfCounterDiskWriteIOPS])
+		prometheus.VolumeLatencyGaugeVec.WithLabelValues(namespace, name,
+			prometheus.PrometheusReadDirection).Set(stats[perfCounterDiskReadLatency])
+		prometheus.VolumeLatencyGaugeVec.WithLabelValues(namespace, name,
+			prometheus.PrometheusWriteDirection).Set(stats[perfCounterDiskWriteLatency])
+		prometheus.VolumeThroughputGaugeVec.WithLabelValues(namespace, name,
+			prometheus.PrometheusReadDirection).Set(stats[perfCounterDiskReadThroughput])
+		prometheus.VolumeThroughputGaugeVec.WithLabelValues(namespace, name,
+			prometheus.PrometheusWriteDirection).Set(stats[perfCounterDiskWriteThroughput])
+	}
+	return nil
+}
+
+// getVolumeIDToDiskInstanceMap maps the FCD ID of every virtual disk in the
+// device list to its performance counter instance name, e.g. "scsi0:1".
+func getVolumeIDToDiskInstanceMap(devices object.VirtualDeviceList) map[string]string {
+	volumeIDToInstance := make(map[string]string)
+	for _, device := range devices.SelectByType((*types.VirtualDisk)(nil)) {
+		disk := device.(*types.VirtualDisk)
+		if disk.VDiskId == nil || disk.UnitNumber == nil {
+			continue
+		}
+		controller := devices.FindByKey(disk.ControllerKey)
+		if controller == nil {
+			continue
+		}
+		var prefix string
+		switch controller.(type) {
+		case types.BaseVirtualSCSIController:
+			prefix = "scsi"
+		case *types.VirtualNVMEController:
+			prefix = "nvme"
+		case types.BaseVirtualSATAController:
+			prefix = "sata"
+		case *types.VirtualIDEController:
+			prefix = "ide"
+		default:
+			continue
+		}
+		busNumber := controller.(types.BaseVirtualController).GetVirtualController().BusNumber
+		volumeIDToInstance[disk.VDiskId.Id] = fmt.Sprintf("%s%d:%d", prefix, busNumber, *disk.UnitNumber)
+	}
+	return volumeIDToInstance
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"testing"
+
+	prometheusclient "github.com/prometheus/client_golang/prometheus"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
+)
+
+func TestGetVolumeIDToDiskInstanceMap(t *testing.T) {
+	unitNumber := int32(1)
+	devices := object.VirtualDeviceList{
+		&types.ParaVirtualSCSIController{
+			VirtualSCSIController: types.VirtualSCSIController{
+				VirtualController: types.VirtualController{
+					VirtualDevice: types.VirtualDevice{Key: 1000},
+					BusNumber:     0,
+				},
+			},
+		},
+		&types.VirtualNVMEController{
+			VirtualController: types.VirtualController{
+				VirtualDevice: types.VirtualDevice{Key: 31000},
+				BusNumber:     2,
+			},
+		},
+		&types.VirtualDisk{
+			VirtualDevice: types.VirtualDevice{Key: 2001, ControllerKey: 1000, UnitNumber: &unitNumber},
+			VDiskId:       &types.ID{Id: "fcd-1"},
+		},
+		&types.VirtualDisk{
+			VirtualDevice: types.VirtualDevice{Key: 2002, ControllerKey: 31000, UnitNumber: &unitNumber},
+			VDiskId:       &types.ID{Id: "fcd-2"},
+		},
+		// Non-FCD disk must be skipped.
+		&types.VirtualDisk{
+			VirtualDevice: types.VirtualDevice{Key: 2003, ControllerKey: 1000, UnitNumber: &unitNumber},
+		},
+	}
+	expected := map[string]string{
+		"fcd-1": "scsi0:1",
+		"fcd-2": "nvme2:1",
+	}
+	actual := getVolumeIDToDiskInstanceMap(devices)
+	if len(actual) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, actual)
+	}
+	for volumeID, instance := range expected {
+		if actual[volumeID] != instance {
+			t.Errorf("expected instance %q for volume %q, got %q", instance, volumeID, actual[volumeID])
+		}
+	}
+}
+
+func TestDeleteStaleVolumeIOStats(t *testing.T) {
+	defer prometheus.VolumeIOPSGaugeVec.Reset()
+	attached := volumeIOStatsLabels{namespace: "default", name: "pvc-attached"}
+	detached := volumeIOStatsLabels{namespace: "default", name: "pvc-detached"}
+	for _, labels := range []volumeIOStatsLabels{attached, detached} {
+		for _, direction := range []string{prometheus.PrometheusReadDirection, prometheus.PrometheusWriteDirection} {
+			prometheus.VolumeIOPSGaugeVec.WithLabelValues(labels.namespace, labels.name, direction).Set(1)
+		}
+	}
+
+	deleteStaleVolumeIOStats(map[volumeIOStatsLabels]struct{}{attached: {}, detached: {}},
+		map[volumeIOStatsLabels]struct{}{attached: {}})
+	metrics := make(chan prometheusclient.Metric, 4)
+	prometheus.VolumeIOPSGaugeVec.Collect(metrics)
+	close(metrics)
+	// Only the read and write series of the attached volume are left.
+	if len(metrics) != 2 {
+		t.Fatalf("expected 2 series after deleting the stale ones, got %d", len(metrics))
+	}
+	for _, direction := range []string{prometheus.PrometheusReadDirection, prometheus.PrometheusWriteDirection} {
+		if prometheus.VolumeIOPSGaugeVec.DeleteLabelValues(detached.namespace, detached.name, direction) {
+			t.Errorf("expected the %s series of %v to be deleted", direction, detached)
+		}
+	}
+}